@@ -0,0 +1,9 @@
+// Command terraform-pr-generator generates terraform plans for a module and
+// a PR-ready markdown report of them.
+package main
+
+import "github.com/backendken/terraform-pr-generator/internal/planner"
+
+func main() {
+	planner.Execute()
+}
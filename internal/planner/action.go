@@ -0,0 +1,185 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newActionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "action",
+		Short: "Run as a GitHub Action step",
+		Long: `Runs a plan configured through GitHub Action inputs and publishes the results
+as step outputs, so workflows don't need to scrape the log.
+
+Inputs are read from INPUT_* environment variables: INPUT_MODULE names the
+module, INPUT_ARGS holds arguments forwarded to the plans, and every flag of
+the main command is available as INPUT_<FLAG> with dashes as underscores, e.g.
+INPUT_GITHUB_COMMENT=true or INPUT_VAR_FILE (one file per line). Flags given
+on the command line work too; inputs win.
+
+Outputs are appended to $GITHUB_OUTPUT (printed to stdout outside Actions):
+report_path, output_dir, has_changes, has_destroys, change_total, add_count,
+change_count, destroy_count, incomplete and warnings.`,
+		Args:        cobra.NoArgs,
+		Run:         runAction,
+		Annotations: map[string]string{findsRepoRoot: "true"},
+	}
+	addPlanFlags(cmd)
+	return cmd
+}
+
+func runAction(cmd *cobra.Command, args []string) {
+	moduleName := strings.TrimSpace(os.Getenv("INPUT_MODULE"))
+	if moduleName == "" {
+		errorColor.Println("❌ Error: the module input (INPUT_MODULE) is required")
+		os.Exit(1)
+	}
+	if err := applyActionInputs(cmd.Flags()); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	applyConsoleFlags(cmd)
+
+	pg, err := newPlanGenerator(cmd, moduleName, "")
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if extra := os.Getenv("INPUT_ARGS"); strings.TrimSpace(extra) != "" {
+		if pg.ExtraArgs, err = splitCommandLine(extra); err != nil {
+			errorColor.Printf("❌ Error: invalid args input: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if pg.scratch {
+		errorColor.Println("❌ Error: --stdout without --output keeps no report for the action outputs; set output too")
+		os.Exit(1)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	runErr := pg.RunContext(ctx)
+	if _, drift := runErr.(*errDrift); runErr != nil && !drift {
+		exitOnRunError(runErr)
+	}
+	if err := writeActionOutputs(pg.actionOutputs()); err != nil {
+		errorColor.Printf("❌ Error: writing step outputs: %v\n", err)
+		os.Exit(1)
+	}
+	if runErr != nil {
+		exitOnRunError(runErr)
+	}
+}
+
+// applyActionInputs sets each flag from its INPUT_* variable. GitHub keeps
+// dashes in input names, so both spellings are accepted. Empty inputs
+// leave the flag (and thus the config file) alone; list flags take one
+// value per line.
+func applyActionInputs(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(flag.Name)
+		value, ok := os.LookupEnv("INPUT_" + strings.ReplaceAll(name, "-", "_"))
+		if !ok {
+			value = os.Getenv("INPUT_" + name)
+		}
+		if value = strings.TrimSpace(value); value == "" {
+			return
+		}
+		values := []string{value}
+		if t := flag.Value.Type(); t == "stringArray" || t == "stringSlice" {
+			values = strings.Split(value, "\n")
+		}
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			if setErr := flags.Set(flag.Name, v); setErr != nil {
+				err = fmt.Errorf("invalid %s input %q: %v", strings.ReplaceAll(flag.Name, "-", "_"), v, setErr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// planTotals adds up the resources every region plan changes, and tells
+// whether any plan errored before its summary.
+func planTotals(results []*PartitionResult) (total PlanCounts, incomplete bool) {
+	for _, result := range results {
+		for _, env := range result.Environments {
+			for _, region := range env.Regions {
+				if counts, ok := ParsePlanCounts(env.Plans[region]); ok {
+					total.Add += counts.Add
+					total.Change += counts.Change
+					total.Destroy += counts.Destroy
+				}
+				incomplete = incomplete || env.Incomplete[region]
+			}
+		}
+	}
+	return total, incomplete
+}
+
+// actionOutputs summarizes the finished run for later workflow steps.
+func (pg *PlanGenerator) actionOutputs() map[string]string {
+	total, incomplete := planTotals(pg.results)
+	outputDir, _ := filepath.Abs(pg.OutputDir)
+	changes := total.Add + total.Change + total.Destroy
+	costChange := ""
+	if pg.cost != nil {
+		costChange = fmt.Sprintf("%.2f", pg.cost.Diff)
+	}
+	return map[string]string{
+		"report_path":         filepath.Join(outputDir, "pr-ready.md"),
+		"output_dir":          outputDir,
+		"has_changes":         fmt.Sprint(changes > 0),
+		"has_destroys":        fmt.Sprint(total.Destroy > 0),
+		"change_total":        fmt.Sprint(changes),
+		"add_count":           fmt.Sprint(total.Add),
+		"change_count":        fmt.Sprint(total.Change),
+		"destroy_count":       fmt.Sprint(total.Destroy),
+		"incomplete":          fmt.Sprint(incomplete),
+		"warnings":            fmt.Sprint(len(allWarnings(pg.results))),
+		"monthly_cost_change": costChange,
+	}
+}
+
+// writeActionOutputs appends outputs to $GITHUB_OUTPUT, or prints them
+// when run outside GitHub Actions.
+func writeActionOutputs(outputs map[string]string) error {
+	var names []string
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s\n", name, outputs[name])
+	}
+
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		fmt.Print(b.String())
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
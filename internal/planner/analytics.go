@@ -0,0 +1,236 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var resourceChangeRegex = regexp.MustCompile(`^\s*# (\S+) (?:will|must) be (.+?)\s*$`)
+
+// resourceStats counts how often one resource address changed across runs.
+type resourceStats struct {
+	Address string
+	Runs    int            // distinct runs it changed in
+	States  int            // environment/region plans it changed in
+	Actions map[string]int // e.g. "updated in-place" -> count
+}
+
+// historyRun is one past run directory of the analysed module.
+type historyRun struct {
+	Dir      string
+	Manifest *RunManifest
+}
+
+func newAnalyticsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "analytics <module_name>",
+		Short: "Report which resources change most often across past runs",
+		Long: `Reads past run directories of a module (any directory with a manifest.json
+under --runs-dir) and ranks resource addresses by how many runs changed them.
+Resources that change in most PRs are usually unstable (computed values,
+drifting tags) and good candidates for lifecycle ignore_changes.
+
+Runs of the same git commit are counted once, keeping the latest.
+
+Examples:
+  terraform-pr-generator analytics s3_malware_protection
+  terraform-pr-generator analytics s3_malware_protection --runs-dir ~/plans --top 10`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeModules(1),
+		Run:               runAnalytics,
+	}
+
+	cmd.Flags().StringArray("runs-dir", []string{"."}, "Directory holding run directories, or a run directory itself (repeatable)")
+	cmd.Flags().Int("top", 20, "Number of resources to list (0 for all)")
+	return cmd
+}
+
+func runAnalytics(cmd *cobra.Command, args []string) {
+	moduleName := args[0]
+	roots, _ := cmd.Flags().GetStringArray("runs-dir")
+	top, _ := cmd.Flags().GetInt("top")
+
+	runs, err := findRuns(roots, moduleName)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(runs) == 0 {
+		errorColor.Printf("❌ Error: no runs of module %s found in %s\n", moduleName, strings.Join(roots, ", "))
+		os.Exit(1)
+	}
+
+	stats, err := resourceFrequency(runs)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	infoColor.Printf("📈 Resource change frequency for %s (%d runs)\n\n", moduleName, len(runs))
+	if len(stats) == 0 {
+		fmt.Fprintln(console, "No resource changes found in these runs.")
+		return
+	}
+	if top > 0 && len(stats) > top {
+		stats = stats[:top]
+	}
+
+	width := len("ADDRESS")
+	for _, s := range stats {
+		if len(s.Address) > width {
+			width = len(s.Address)
+		}
+	}
+	// The table itself is data and goes to stdout
+	fmt.Printf("  %-7s %-6s  %-*s  %s\n", "RUNS", "STATES", width, "ADDRESS", "ACTIONS")
+	for _, s := range stats {
+		fmt.Printf("  %-7s %-6d  %-*s  %s\n", fmt.Sprintf("%d/%d", s.Runs, len(runs)), s.States, width, s.Address, formatActions(s.Actions))
+	}
+
+	var unstable []string
+	for _, s := range stats {
+		if s.Runs*2 >= len(runs) && len(runs) > 1 && s.Actions["updated in-place"] > 0 {
+			unstable = append(unstable, s.Address)
+		}
+	}
+	if len(unstable) > 0 {
+		fmt.Fprintln(console)
+		warningColor.Println("💡 Updated in at least half of the runs, consider lifecycle ignore_changes:")
+		for _, address := range unstable {
+			fmt.Fprintf(console, "  - %s\n", address)
+		}
+	}
+}
+
+// findRuns collects the run directories of moduleName under roots, keeping
+// only the latest run per git commit.
+func findRuns(roots []string, moduleName string) ([]*historyRun, error) {
+	var dirs []string
+	for _, root := range roots {
+		if _, err := os.Stat(filepath.Join(root, manifestFile)); err == nil {
+			dirs = append(dirs, root)
+			continue
+		}
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read runs directory: %v", err)
+		}
+		for _, entry := range entries {
+			dir := filepath.Join(root, entry.Name())
+			if _, err := os.Stat(filepath.Join(dir, manifestFile)); entry.IsDir() && err == nil {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	latest := make(map[string]*historyRun)
+	var runs []*historyRun
+	for _, dir := range dirs {
+		manifest, err := readManifest(dir)
+		if err != nil {
+			warningColor.Printf("⚠️  Skipping %s: %v\n", dir, err)
+			continue
+		}
+		if manifest.Module != moduleName {
+			continue
+		}
+		run := &historyRun{Dir: dir, Manifest: manifest}
+		if manifest.GitCommit == "" {
+			runs = append(runs, run)
+			continue
+		}
+		if prev, ok := latest[manifest.GitCommit]; !ok || manifest.StartedAt.After(prev.Manifest.StartedAt) {
+			latest[manifest.GitCommit] = run
+		}
+	}
+	for _, run := range latest {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Manifest.StartedAt.Before(runs[j].Manifest.StartedAt)
+	})
+	return runs, nil
+}
+
+// resourceFrequency parses every run's plans files with the config the run
+// used and counts changed resource addresses, most frequent first.
+func resourceFrequency(runs []*historyRun) ([]*resourceStats, error) {
+	byAddress := make(map[string]*resourceStats)
+	for _, run := range runs {
+		cfg, err := run.Manifest.config()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", run.Dir, err)
+		}
+		pg := &PlanGenerator{ModuleName: run.Manifest.Module, OutputDir: run.Dir, Config: cfg}
+
+		seen := make(map[string]bool)
+		for _, p := range cfg.Partitions {
+			result, err := pg.parsePlansFile(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", run.Dir, err)
+			}
+			for _, env := range result.Environments {
+				for _, region := range env.Regions {
+					for _, line := range strings.Split(env.Plans[region], "\n") {
+						m := resourceChangeRegex.FindStringSubmatch(line)
+						if m == nil {
+							continue
+						}
+						s := byAddress[m[1]]
+						if s == nil {
+							s = &resourceStats{Address: m[1], Actions: make(map[string]int)}
+							byAddress[m[1]] = s
+						}
+						s.States++
+						s.Actions[m[2]]++
+						if !seen[m[1]] {
+							seen[m[1]] = true
+							s.Runs++
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var stats []*resourceStats
+	for _, s := range byAddress {
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Runs != stats[j].Runs {
+			return stats[i].Runs > stats[j].Runs
+		}
+		if stats[i].States != stats[j].States {
+			return stats[i].States > stats[j].States
+		}
+		return stats[i].Address < stats[j].Address
+	})
+	return stats, nil
+}
+
+// formatActions renders action counts as "updated in-place ×3, replaced ×1",
+// most frequent first.
+func formatActions(actions map[string]int) string {
+	var names []string
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if actions[names[i]] != actions[names[j]] {
+			return actions[names[i]] > actions[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s ×%d", name, actions[name])
+	}
+	return strings.Join(parts, ", ")
+}
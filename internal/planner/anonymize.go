@@ -0,0 +1,137 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// anonymizeRule finds values of a kind to pseudonymize: the text of group
+// in each match of regex.
+type anonymizeRule struct {
+	kind  string
+	regex *regexp.Regexp
+	group int
+}
+
+// anonymizeRules are applied in order: bucket names before the hostnames
+// they're part of.
+var anonymizeRules = []anonymizeRule{
+	{"bucket", regexp.MustCompile(`arn:aws[a-z-]*:s3:::([a-z0-9][a-z0-9.-]*[a-z0-9])`), 1},
+	{"bucket", regexp.MustCompile(`s3://([a-z0-9][a-z0-9.-]*[a-z0-9])`), 1},
+	{"bucket", regexp.MustCompile(`\b([a-z0-9][a-z0-9.-]*[a-z0-9])\.s3[.-][a-z0-9.-]*amazonaws\.com`), 1},
+	{"bucket", regexp.MustCompile(`\bbucket(?:_name)?"?\s*[=:]\s*"([a-z0-9][a-z0-9.-]*[a-z0-9])"`), 1},
+	{"role", regexp.MustCompile(`:role/((?:[\w+=,.@-]+/)*[\w+=,.@-]+)`), 1},
+	{"role", regexp.MustCompile(`\brole_name"?\s*[=:]\s*"([\w+=,.@-]+)"`), 1},
+	{"account", regexp.MustCompile(`\b([0-9]{12})\b`), 1},
+	{"host", regexp.MustCompile(`(?i)\b((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+(?:com|net|org|io|dev|app|cloud|internal|local|lan|corp|intra|co|us|uk|de|eu|info|biz))\b`), 1},
+}
+
+// publicDomains are left alone by --anonymize: they name services, not
+// infrastructure, and example.com hosts the pseudonyms.
+var publicDomains = []string{"amazonaws.com", "amazonaws.com.cn", "amazon.com", "hashicorp.com", "terraform.io", "github.com", "githubusercontent.com", "example.com"}
+
+// anonymizer pseudonymizes account IDs, bucket and role names and hostnames
+// in the plans of an --anonymize run, each value always the same way, so
+// the plans can be shared without revealing the infrastructure.
+type anonymizer struct {
+	mu sync.Mutex
+	// pseudonyms maps each kind's values to theirs, numbered in the order
+	// they're seen.
+	pseudonyms map[string]string
+	// issued are the pseudonyms handed out, left alone when text is
+	// anonymized again.
+	issued map[string]bool
+	counts map[string]int
+}
+
+func newAnonymizer() *anonymizer {
+	return &anonymizer{pseudonyms: make(map[string]string), issued: make(map[string]bool), counts: make(map[string]int)}
+}
+
+// anonymize replaces the values in text with their pseudonyms.
+func (a *anonymizer) anonymize(text []byte) []byte {
+	for _, rule := range anonymizeRules {
+		matches := rule.regex.FindAllSubmatchIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		var b bytes.Buffer
+		last := 0
+		for _, m := range matches {
+			start, end := m[2*rule.group], m[2*rule.group+1]
+			b.Write(text[last:start])
+			b.WriteString(a.pseudonym(rule.kind, string(text[start:end])))
+			last = end
+		}
+		b.Write(text[last:])
+		text = b.Bytes()
+	}
+	return text
+}
+
+// pseudonym is value's stand-in: account IDs stay twelve digits and
+// hostnames stay hostnames.
+func (a *anonymizer) pseudonym(kind, value string) string {
+	if kind == "host" {
+		value = strings.ToLower(value)
+		if publicHost(value) {
+			return value
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.issued[value] {
+		return value
+	}
+	key := kind + " " + value
+	if pseudonym, ok := a.pseudonyms[key]; ok {
+		return pseudonym
+	}
+	a.counts[kind]++
+	n := a.counts[kind]
+	var pseudonym string
+	switch kind {
+	case "account":
+		pseudonym = fmt.Sprintf("%012d", n)
+	case "host":
+		pseudonym = fmt.Sprintf("host-%d.example.com", n)
+	default:
+		pseudonym = fmt.Sprintf("%s-%d", kind, n)
+	}
+	a.pseudonyms[key] = pseudonym
+	a.issued[pseudonym] = true
+	return pseudonym
+}
+
+func publicHost(host string) bool {
+	for _, domain := range publicDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAnonymizedNote says what --anonymize replaced.
+func (pg *PlanGenerator) writeAnonymizedNote(output *os.File) {
+	if pg.anonymizer == nil {
+		return
+	}
+	a := pg.anonymizer
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var counts []string
+	for _, kind := range []struct{ key, name string }{{"account", "account ID"}, {"bucket", "bucket"}, {"role", "role"}, {"host", "hostname"}} {
+		if n := a.counts[kind.key]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s(s)", n, kind.name))
+		}
+	}
+	if len(counts) == 0 {
+		return
+	}
+	output.WriteString(fmt.Sprintf("> %sAnonymized: %s replaced by stable pseudonyms; state paths and environment names are as in the repository.\n\n", pg.renderer().Icon("🥸"), strings.Join(counts, ", ")))
+}
@@ -0,0 +1,29 @@
+package planner
+
+import "testing"
+
+func TestAnonymize(t *testing.T) {
+	a := newAnonymizer()
+	text := `  + bucket = "acme-prod-logs"
+  + arn    = "arn:aws:s3:::acme-prod-logs"
+  + role   = "arn:aws:iam::123456789012:role/acme-deployer"
+  + owner  = "123456789012"
+  + host   = "db.acme.internal"
+  + api    = "sts.us-east-1.amazonaws.com"
+`
+	want := `  + bucket = "bucket-1"
+  + arn    = "arn:aws:s3:::bucket-1"
+  + role   = "arn:aws:iam::000000000001:role/role-1"
+  + owner  = "000000000001"
+  + host   = "host-1.example.com"
+  + api    = "sts.us-east-1.amazonaws.com"
+`
+	got := string(a.anonymize([]byte(text)))
+	if got != want {
+		t.Errorf("anonymize =\n%s\nwant\n%s", got, want)
+	}
+	// Anonymizing again is stable
+	if again := string(a.anonymize([]byte(got))); again != want {
+		t.Errorf("anonymizing twice =\n%s", again)
+	}
+}
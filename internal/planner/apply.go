@@ -0,0 +1,204 @@
+package planner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// applyStep is one state to apply, with the report section it belongs to.
+type applyStep struct {
+	State     *State
+	Partition *Partition
+	Unit      *applyUnit
+}
+
+func newApplyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply --from <run_dir>",
+		Short: "Apply the saved plans of a reviewed run",
+		Long: `Applies the binary plans a --save-plans run wrote, so exactly what was
+reviewed in the PR is rolled out instead of re-planning after merge.
+
+States are applied one at a time in the suggested apply order (lower
+environment tiers first, terragrunt dependencies respected), asking for
+confirmation before each environment. Applying stops at the first failure.
+
+Examples:
+  terraform-pr-generator apply --from pr-plans-20250604-143022
+  terraform-pr-generator apply --from pr-plans-20250604-143022 --env staging
+  terraform-pr-generator apply --from pr-plans-20250604-143022 --select 'region=us-east-*'`,
+		Args: cobra.NoArgs,
+		Run:  runApply,
+	}
+
+	cmd.Flags().String("from", "", "Run directory created with --save-plans (required)")
+	cmd.Flags().StringSlice("env", nil, "Only apply these environments")
+	cmd.Flags().String("select", "", "Only apply states matching an expression, e.g. 'env=staging && region=us-east-*'")
+	cmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation before each environment")
+	cmd.MarkFlagRequired("from")
+	return cmd
+}
+
+func runApply(cmd *cobra.Command, args []string) {
+	runDir, _ := cmd.Flags().GetString("from")
+	envs, _ := cmd.Flags().GetStringSlice("env")
+	yes, _ := cmd.Flags().GetBool("yes")
+	selectExpr, _ := cmd.Flags().GetString("select")
+
+	var sel selector
+	if selectExpr != "" {
+		var err error
+		if sel, err = parseSelector(selectExpr); err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	pg, steps, err := planApply(runDir, envs, sel)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	input := bufio.NewReader(os.Stdin)
+	for i := 0; i < len(steps); {
+		// Group consecutive steps of the same environment for confirmation
+		env := steps[i].Unit.Env
+		j := i
+		for j < len(steps) && steps[j].Unit.Env == env {
+			j++
+		}
+		group := steps[i:j]
+
+		boldColor.Printf("\n🌍 %s: %d state(s)\n", env, len(group))
+		for _, step := range group {
+			fmt.Fprintf(console, "  - %s (%s)\n", step.State, step.Unit.Region)
+		}
+		if !yes && !confirm(input, fmt.Sprintf("Apply %d state(s) in %s?", len(group), env)) {
+			warningColor.Printf("⏹️  Stopped before %s; later environments were not applied\n", env)
+			os.Exit(1)
+		}
+
+		for _, step := range group {
+			if err := pg.applyState(step); err != nil {
+				errorColor.Printf("❌ Error: %v\n", err)
+				os.Exit(1)
+			}
+			successColor.Printf("✅ Applied %s\n", step.State)
+		}
+		i = j
+	}
+	successColor.Println("\n✅ All saved plans applied")
+}
+
+// planApply loads a run's saved plans, narrowed to envs and sel when given,
+// and orders them for applying. The returned generator carries the run's
+// module and config.
+func planApply(runDir string, envs []string, sel selector) (*PlanGenerator, []*applyStep, error) {
+	manifest, err := readManifest(runDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var states []*State
+	for _, state := range manifest.States {
+		if state.PlanFile != "" {
+			states = append(states, state)
+		}
+	}
+	if len(states) == 0 {
+		return nil, nil, fmt.Errorf("%s has no saved plans (was it run with --targeted --save-plans?)", runDir)
+	}
+	if err := verifyRun(runDir, manifest); err != nil {
+		return nil, nil, err
+	}
+	if head := gitHead(); manifest.GitCommit != "" && head != manifest.GitCommit {
+		warningColor.Printf("⚠️  Checkout is at %s but the plans were made at %s\n", shortSHA(head), shortSHA(manifest.GitCommit))
+	}
+
+	cfg, err := manifest.config()
+	if err != nil {
+		return nil, nil, err
+	}
+	pg := &PlanGenerator{ModuleName: manifest.Module, OutputDir: runDir, Config: cfg}
+	results, err := pg.collectResults()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	units := make(map[string]*applyUnit)
+	key := func(p *Partition, env, region string) string { return p.Name + "/" + env + "/" + region }
+	position := make(map[*applyUnit]int)
+	for i, unit := range pg.applyOrder(results, states) {
+		units[key(unit.Partition, unit.Env, unit.Region)] = unit
+		position[unit] = i
+	}
+
+	var steps []*applyStep
+	for _, state := range states {
+		unit := pg.unitForState(state, units, key)
+		if unit == nil {
+			return nil, nil, fmt.Errorf("no plan found in the report for %s", state)
+		}
+		if len(envs) > 0 && !contains(envs, unit.Env) {
+			continue
+		}
+		if sel != nil && !sel.match(pg.selectorFieldsOf(state)) {
+			continue
+		}
+		if unit.Incomplete {
+			return nil, nil, fmt.Errorf("the plan for %s (%s) is incomplete and can't be applied", state, unit.label())
+		}
+		if _, err := os.Stat(filepath.Join(runDir, state.PlanFile)); err != nil {
+			return nil, nil, fmt.Errorf("saved plan for %s is missing: %v", state, err)
+		}
+		steps = append(steps, &applyStep{State: state, Partition: unit.Partition, Unit: unit})
+	}
+	if len(steps) == 0 {
+		return nil, nil, fmt.Errorf("no saved plans match the --env/--select filters")
+	}
+
+	// Apply order first, input order within a section
+	sort.SliceStable(steps, func(i, j int) bool {
+		return position[steps[i].Unit] < position[steps[j].Unit]
+	})
+	return pg, steps, nil
+}
+
+// applyState runs the runner's apply command for one saved plan, streaming
+// its output to the terminal.
+func (pg *PlanGenerator) applyState(step *applyStep) error {
+	planFile, err := filepath.Abs(filepath.Join(pg.OutputDir, step.State.PlanFile))
+	if err != nil {
+		return err
+	}
+	argv, err := pg.Config.Runner.ApplyCommand(step.Partition, pg.ModuleName, step.State.Path, planFile)
+	if err != nil {
+		return err
+	}
+
+	infoColor.Printf("🚀 Applying %s\n", step.State)
+	c := exec.Command(argv[0], argv[1:]...)
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	if step.State.Workspace != "" {
+		c.Env = append(os.Environ(), "TF_WORKSPACE="+step.State.Workspace)
+	}
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("apply failed for %s: %v", step.State, err)
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on stderr, defaulting to no.
+func confirm(input *bufio.Reader, question string) bool {
+	warningColor.Printf("%s [y/N] ", question)
+	answer, _ := input.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
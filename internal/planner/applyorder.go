@@ -0,0 +1,218 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EnvironmentTier groups environments that are applied together; tiers are
+// applied in the order they're configured.
+type EnvironmentTier struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+
+	regex *regexp.Regexp
+}
+
+// DefaultTiers promote changes from development through staging to production.
+func DefaultTiers() []*EnvironmentTier {
+	return []*EnvironmentTier{
+		{Name: "development", Pattern: `dev|sandbox|test`},
+		{Name: "staging", Pattern: `stag|qa|uat|preprod`},
+		{Name: "production", Pattern: `prod`},
+	}
+}
+
+var dependencyPathRegex = regexp.MustCompile(`config_path\s*=\s*"([^"]+)"`)
+
+// applyUnit is one environment/region section of the report.
+type applyUnit struct {
+	Partition  *Partition
+	Env        string
+	Region     string
+	Tier       int // index in EnvironmentTiers, -1 if none matches
+	Incomplete bool
+	DependsOn  map[*applyUnit]bool
+}
+
+func (u *applyUnit) label() string {
+	return fmt.Sprintf("%s / %s", u.Env, u.Region)
+}
+
+// tierOf returns the index of the first tier matching env, -1 if none
+// does.
+func (c *Config) tierOf(env string) int {
+	for i, tier := range c.EnvironmentTiers {
+		if tier.regex.MatchString(env) {
+			return i
+		}
+	}
+	return -1
+}
+
+// tierRank is when environments of tier are applied: in tier order, with
+// unmatched ones (-1) just before the last tier.
+func (c *Config) tierRank(tier int) int {
+	if tier < 0 {
+		return 2*len(c.EnvironmentTiers) - 3
+	}
+	return 2 * tier
+}
+
+// applyOrder orders the report's sections so lower tiers come first and
+// every state is applied after the states it depends on.
+func (pg *PlanGenerator) applyOrder(results []*PartitionResult, states []*State) []*applyUnit {
+	units := make(map[string]*applyUnit)
+	var all []*applyUnit
+	key := func(p *Partition, env, region string) string { return p.Name + "/" + env + "/" + region }
+
+	for _, result := range results {
+		for _, env := range result.Environments {
+			for _, region := range env.Regions {
+				unit := &applyUnit{
+					Partition:  result.Partition,
+					Env:        env.Name,
+					Region:     region,
+					Tier:       pg.Config.tierOf(env.Name),
+					Incomplete: env.Incomplete[region],
+					DependsOn:  make(map[*applyUnit]bool),
+				}
+				units[key(result.Partition, env.Name, region)] = unit
+				all = append(all, unit)
+			}
+		}
+	}
+
+	// Map planned state directories to their report section, then turn
+	// terragrunt dependency blocks into edges between sections.
+	unitOf := make(map[string]*applyUnit)
+	for _, state := range states {
+		if unit := pg.unitForState(state, units, key); unit != nil {
+			unitOf[filepath.Clean(state.Path)] = unit
+		}
+	}
+	for _, state := range states {
+		unit := unitOf[filepath.Clean(state.Path)]
+		if unit == nil {
+			continue
+		}
+		for _, dep := range stateDependencies(state.Path) {
+			if depUnit := unitOf[dep]; depUnit != nil && depUnit != unit {
+				unit.DependsOn[depUnit] = true
+			}
+		}
+	}
+
+	// Kahn's algorithm, always picking the lowest tier that's ready.
+	less := func(a, b *applyUnit) bool {
+		if rankA, rankB := pg.Config.tierRank(a.Tier), pg.Config.tierRank(b.Tier); rankA != rankB {
+			return rankA < rankB
+		}
+		if a.Env != b.Env {
+			return a.Env < b.Env
+		}
+		return a.Region < b.Region
+	}
+	done := make(map[*applyUnit]bool)
+	var ordered []*applyUnit
+	for len(ordered) < len(all) {
+		var ready []*applyUnit
+		for _, unit := range all {
+			if done[unit] {
+				continue
+			}
+			blocked := false
+			for dep := range unit.DependsOn {
+				if !done[dep] {
+					blocked = true
+					break
+				}
+			}
+			if !blocked {
+				ready = append(ready, unit)
+			}
+		}
+		if len(ready) == 0 {
+			// Dependency cycle: fall back to tier order for the rest.
+			for _, unit := range all {
+				if !done[unit] {
+					ready = append(ready, unit)
+				}
+			}
+		}
+		sort.Slice(ready, func(i, j int) bool { return less(ready[i], ready[j]) })
+		done[ready[0]] = true
+		ordered = append(ordered, ready[0])
+	}
+	return ordered
+}
+
+func (pg *PlanGenerator) unitForState(state *State, units map[string]*applyUnit, key func(*Partition, string, string) string) *applyUnit {
+	p := pg.Config.PartitionFor(state.String())
+	if p == nil {
+		return nil
+	}
+	env, region := state.Env, state.Region
+	path := filepath.ToSlash(state.Path) + "/"
+	if env == "" {
+		env, _ = p.MatchEnv(path)
+	}
+	if region == "" {
+		region, _ = p.MatchRegion(path)
+	}
+	return units[key(p, env, region)]
+}
+
+// stateDependencies returns the cleaned paths of the dependency blocks in a
+// state's terragrunt.hcl.
+func stateDependencies(dir string) []string {
+	data, err := os.ReadFile(filepath.Join(dir, "terragrunt.hcl"))
+	if err != nil {
+		return nil
+	}
+	var deps []string
+	for _, m := range dependencyPathRegex.FindAllStringSubmatch(string(data), -1) {
+		path := m[1]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		deps = append(deps, filepath.Clean(path))
+	}
+	return deps
+}
+
+// writeApplyOrder appends the suggested apply order as a checklist.
+func (pg *PlanGenerator) writeApplyOrder(results []*PartitionResult, output *os.File) {
+	ordered := pg.applyOrder(results, pg.plannedStates)
+	if len(ordered) == 0 {
+		return
+	}
+
+	output.WriteString("## Suggested apply order\n\n")
+	for i, unit := range ordered {
+		line := fmt.Sprintf("%d. [ ] `%s`", i+1, unit.label())
+		if pg.PlainReport {
+			line = fmt.Sprintf("%d. `%s`", i+1, unit.label())
+		}
+		if unit.Tier >= 0 {
+			line += fmt.Sprintf(" (%s)", pg.Config.EnvironmentTiers[unit.Tier].Name)
+		}
+		if len(unit.DependsOn) > 0 {
+			var deps []string
+			for dep := range unit.DependsOn {
+				deps = append(deps, "`"+dep.label()+"`")
+			}
+			sort.Strings(deps)
+			line += " — after " + strings.Join(deps, ", ")
+		}
+		if unit.Incomplete {
+			line += " — " + pg.renderer().Icon("⚠️") + "plan incomplete, re-plan before applying"
+		}
+		output.WriteString(line + "\n")
+	}
+	output.WriteString("\n")
+}
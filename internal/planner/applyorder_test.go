@@ -0,0 +1,34 @@
+package planner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyOrderTiers(t *testing.T) {
+	pg := newTestGenerator(t, nil)
+	plan := map[string]string{"us-east-1": "Plan: 1 to add, 0 to change, 0 to destroy."}
+	results := testResults(commercialPartition(t),
+		testEnvironment("production", plan),
+		testEnvironment("ops", plan),
+		testEnvironment("staging", plan),
+		testEnvironment("dev", plan),
+	)
+
+	var order []string
+	for _, unit := range pg.applyOrder(results, nil) {
+		order = append(order, unit.Env)
+	}
+	if got, want := strings.Join(order, " "), "dev staging ops production"; got != want {
+		t.Errorf("apply order = %q, want %q", got, want)
+	}
+
+	pg.Config.EnvironmentTiers = pg.Config.EnvironmentTiers[2:]
+	order = nil
+	for _, unit := range pg.applyOrder(results, nil) {
+		order = append(order, unit.Env)
+	}
+	if got, want := strings.Join(order, " "), "dev ops staging production"; got != want {
+		t.Errorf("apply order with only a production tier = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package planner
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The markers delimiting the approval checklist, for bots that parse it.
+// The start marker also names the module and the commit planned.
+const (
+	approvalsStart = "<!-- tfprgen:approvals:start"
+	approvalsEnd   = "<!-- tfprgen:approvals:end -->"
+)
+
+// writeApprovals writes a checklist with a checkbox per environment, so
+// reviewers approve each environment's plan explicitly. For a branch
+// protection bot to check that every box is ticked, each line between the
+// markers reads "- [ ] `<partition>/<environment>`: N to add, N to change,
+// N to destroy", the destroys possibly in bold behind an icon, optionally
+// followed by " — " and a note.
+func (pg *PlanGenerator) writeApprovals(results []*PartitionResult, output io.StringWriter) {
+	var lines []string
+	for _, result := range results {
+		for _, env := range result.Environments {
+			var counts PlanCounts
+			incomplete := false
+			for _, region := range env.Regions {
+				if c, ok := ParsePlanCounts(env.Plans[region]); ok {
+					counts.Add += c.Add
+					counts.Change += c.Change
+					counts.Destroy += c.Destroy
+				}
+				incomplete = incomplete || env.Incomplete[region]
+			}
+			destroys := fmt.Sprintf("%d to destroy", counts.Destroy)
+			if counts.Destroy > 0 {
+				destroys = pg.renderer().Icon("🔥") + "**" + destroys + "**"
+			}
+			line := fmt.Sprintf("- [ ] `%s/%s`: %d to add, %d to change, %s", result.Partition.Name, env.Name, counts.Add, counts.Change, destroys)
+			if incomplete {
+				line += " — " + pg.renderer().Icon("⚠️") + "plan incomplete, not all changes are counted"
+			}
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	output.WriteString("## " + pg.renderer().Icon("✅") + "Environment approvals\n\n")
+	output.WriteString("Tick an environment once you've reviewed its plan and approve it; an updated report starts with every box cleared.\n\n")
+	start := approvalsStart + " module=" + pg.ModuleName
+	if head := gitHead(); head != "" {
+		start += " commit=" + head
+	}
+	output.WriteString(start + " -->\n")
+	output.WriteString(strings.Join(lines, "\n") + "\n")
+	output.WriteString(approvalsEnd + "\n\n")
+}
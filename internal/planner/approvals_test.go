@@ -0,0 +1,29 @@
+package planner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteApprovals(t *testing.T) {
+	pg := newTestGenerator(t, nil)
+	results := testResults(pg.Config.Partitions[0],
+		testEnvironment("production", map[string]string{
+			"eu-west-1": "Plan: 1 to add, 0 to change, 1 to destroy.",
+			"us-east-1": "Plan: 0 to add, 2 to change, 1 to destroy.",
+		}),
+		testEnvironment("staging", map[string]string{"us-east-1": "Error: boom"}, "us-east-1"),
+	)
+	var b strings.Builder
+	pg.writeApprovals(results, &b)
+	for _, want := range []string{
+		"<!-- tfprgen:approvals:start module=vpc",
+		"- [ ] `commercial/production`: 1 to add, 2 to change, 🔥 **2 to destroy**\n",
+		"- [ ] `commercial/staging`: 0 to add, 0 to change, 0 to destroy — ⚠️ plan incomplete",
+		"<!-- tfprgen:approvals:end -->",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("checklist lacks %q:\n%s", want, b.String())
+		}
+	}
+}
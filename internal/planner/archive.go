@@ -0,0 +1,82 @@
+package planner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// archivePath is where --archive writes the run: next to the output
+// directory, or in the current directory for --stdout scratch runs whose
+// output directory is removed.
+func (pg *PlanGenerator) archivePath() string {
+	if pg.scratch {
+		return filepath.Base(pg.OutputDir) + ".tar.gz"
+	}
+	return filepath.Clean(pg.OutputDir) + ".tar.gz"
+}
+
+// writeArchive packs the output directory into a .tar.gz whose entries
+// sit under the directory's name, so it unpacks like the original.
+func (pg *PlanGenerator) writeArchive(path string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	root := filepath.Base(filepath.Clean(pg.OutputDir))
+	err = filepath.WalkDir(pg.OutputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(pg.OutputDir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(root, rel))
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,93 @@
+package planner
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// artifactoryStorage is a folder of an Artifactory generic repository,
+// artifactory://host/artifactory/repo/prefix, deployed to through the REST
+// API (artifactory+http:// for instances without TLS). Requests carry
+// ARTIFACTORY_ACCESS_TOKEN, or else ARTIFACTORY_USER and
+// ARTIFACTORY_PASSWORD. Artifactory doesn't presign, so links are plain
+// and readers need access to the repository.
+type artifactoryStorage struct {
+	BaseURL string // without trailing slash
+	http    *http.Client
+}
+
+func newArtifactoryStorage(u *url.URL) (Storage, error) {
+	scheme := "https"
+	if u.Scheme == "artifactory+http" {
+		scheme = "http"
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("missing repository path (artifactory://host/artifactory/repo/prefix)")
+	}
+	return &artifactoryStorage{
+		BaseURL: fmt.Sprintf("%s://%s/%s", scheme, u.Host, strings.Trim(u.Path, "/")),
+		http:    &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *artifactoryStorage) location(dir, rel string) string {
+	return s.BaseURL + "/" + runKey("", dir, rel)
+}
+
+// upload deploys every file of the run directory.
+func (s *artifactoryStorage) upload(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return s.deploy(path, s.location(dir, filepath.ToSlash(rel)))
+	})
+}
+
+func (s *artifactoryStorage) deploy(path, target string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT", target, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	if token := os.Getenv("ARTIFACTORY_ACCESS_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if user := os.Getenv("ARTIFACTORY_USER"); user != "" {
+		req.SetBasicAuth(user, os.Getenv("ARTIFACTORY_PASSWORD"))
+	}
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("deploying %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("deploying %s: %s: %s", target, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (s *artifactoryStorage) presign(dir, rel string, expires time.Duration) (string, error) {
+	return s.location(dir, rel), nil
+}
+
+func (s *artifactoryStorage) expiring() bool { return false }
@@ -0,0 +1,365 @@
+package planner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// auditLogEnv overrides audit.log.
+	auditLogEnv = "TFPRGEN_AUDIT_LOG"
+	// auditTokenEnv is sent as a bearer token to audit.url, keeping the
+	// secret out of the config file.
+	auditTokenEnv = "TFPRGEN_AUDIT_TOKEN"
+	// auditLockWait is how long a run waits for another to finish
+	// appending to the audit log.
+	auditLockWait = 10 * time.Second
+)
+
+// AuditConfig appends a record of every run, with who ran it, to a
+// hash-chained log file and/or posts it to a remote collector.
+type AuditConfig struct {
+	// Log is the local audit log, e.g. ~/.tfprgen/audit.log.
+	Log string `yaml:"log"`
+	// URL receives every record as a JSON POST, e.g. a SIEM's HTTP
+	// collector.
+	URL string `yaml:"url"`
+	// Required fails the run when its record can't be written, instead of
+	// warning.
+	Required bool `yaml:"required"`
+}
+
+func (c AuditConfig) validate() error {
+	if c.URL == "" {
+		return nil
+	}
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q isn't an http(s) URL", c.URL)
+	}
+	return nil
+}
+
+// auditLogPath is audit.log, unless TFPRGEN_AUDIT_LOG is set, with ~
+// expanded.
+func (c AuditConfig) auditLogPath() string {
+	path := c.Log
+	if env := os.Getenv(auditLogEnv); env != "" {
+		path = env
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// auditRecord is one run in the audit log. Hash is the SHA-256 of the
+// record's JSON without it, Prev included, so altering or removing a
+// record breaks the chain after it.
+type auditRecord struct {
+	Time         string   `json:"time"`
+	User         string   `json:"user"`
+	GitUser      string   `json:"git_user,omitempty"`
+	CIActor      string   `json:"ci_actor,omitempty"`
+	Host         string   `json:"host,omitempty"`
+	Module       string   `json:"module"`
+	Branch       string   `json:"branch,omitempty"`
+	Commit       string   `json:"commit,omitempty"`
+	Dirty        bool     `json:"dirty,omitempty"`
+	Targeted     bool     `json:"targeted"`
+	Destroy      bool     `json:"destroy,omitempty"`
+	Environments []string `json:"environments"` // partition/env
+	States       int      `json:"states"`
+	Outcome      string   `json:"outcome"` // succeeded, failed or drift
+	Error        string   `json:"error,omitempty"`
+	Output       string   `json:"output,omitempty"`
+	ReportSHA256 string   `json:"report_sha256,omitempty"`
+	Prev         string   `json:"prev"`
+	Hash         string   `json:"hash,omitempty"`
+}
+
+func (r auditRecord) digest() string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// auditIdentity fills in who runs the generator: the OS user, git's
+// user.email, the CI actor that triggered the job and the host.
+func auditIdentity(r *auditRecord) {
+	if u, err := user.Current(); err == nil {
+		r.User = u.Username
+	} else {
+		r.User = os.Getenv("USER")
+	}
+	if out, err := commandOutput(exec.Command("git", "config", "user.email")); err == nil {
+		r.GitUser = strings.TrimSpace(string(out))
+	}
+	for _, env := range []string{"GITHUB_ACTOR", "GITLAB_USER_LOGIN", "BUILD_REQUESTEDFOR"} {
+		if actor := os.Getenv(env); actor != "" {
+			r.CIActor = actor
+			break
+		}
+	}
+	r.Host, _ = os.Hostname()
+}
+
+// recordAudit writes the finished run's audit record. It returns an error
+// only when audit.required is set; otherwise failures are warnings.
+func (pg *PlanGenerator) recordAudit(runErr error) error {
+	cfg := pg.Config.Audit
+	logPath := cfg.auditLogPath()
+	if logPath == "" && cfg.URL == "" {
+		return nil
+	}
+	record := &auditRecord{
+		Time:         time.Now().UTC().Format(time.RFC3339),
+		Module:       pg.ModuleName,
+		Commit:       gitHead(),
+		Dirty:        gitDirty(),
+		Targeted:     len(pg.plannedStates) > 0,
+		Destroy:      pg.Destroy,
+		Environments: []string{},
+		States:       len(pg.timings),
+		Outcome:      runStatus(runErr),
+		Output:       pg.keptOutput(runErr),
+	}
+	auditIdentity(record)
+	if out, err := commandOutput(exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")); err == nil {
+		record.Branch = strings.TrimSpace(string(out))
+	}
+	if runErr != nil {
+		record.Error = runErr.Error()
+	}
+	for _, result := range pg.results {
+		for _, env := range result.Environments {
+			record.Environments = append(record.Environments, result.Partition.Name+"/"+env.Name)
+		}
+	}
+	if report, err := os.ReadFile(filepath.Join(pg.OutputDir, "pr-ready.md")); err == nil {
+		sum := sha256.Sum256(report)
+		record.ReportSHA256 = hex.EncodeToString(sum[:])
+	}
+
+	var errs []string
+	if logPath != "" {
+		if err := appendAudit(logPath, record); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", logPath, err))
+		}
+	} else {
+		record.Hash = record.digest()
+	}
+	if cfg.URL != "" {
+		if err := postAudit(cfg.URL, record); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", cfg.URL, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	if cfg.Required {
+		return fmt.Errorf("writing the audit record (audit.required): %s", strings.Join(errs, "; "))
+	}
+	warningColor.Printf("⚠️  Couldn't write the audit record: %s\n", strings.Join(errs, "; "))
+	return nil
+}
+
+// appendAudit chains record to the last one in the log and appends it,
+// holding path.lock so parallel runs don't fork the chain.
+func appendAudit(path string, record *auditRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	unlock, err := lockAuditLog(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	records, err := readAuditLog(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(records) > 0 {
+		record.Prev = records[len(records)-1].Hash
+	}
+	record.Hash = record.digest()
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lockAuditLog creates the lock file, waiting up to auditLockWait for
+// another run to remove it. A lock older than that was left by a run that
+// died and is taken over.
+func lockAuditLog(lock string) (func(), error) {
+	deadline := time.Now().Add(auditLockWait)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(lock); statErr == nil && time.Since(info.ModTime()) > auditLockWait {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by another run", lock)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func readAuditLog(path string) ([]*auditRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []*auditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		record := &auditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return records, fmt.Errorf("line %d: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// verifyAuditChain checks every record's hash and its link to the one
+// before, returning how many records are intact before the first that
+// isn't.
+func verifyAuditChain(records []*auditRecord) (int, error) {
+	prev := ""
+	for i, r := range records {
+		if r.Prev != prev {
+			return i, fmt.Errorf("record %d (%s, %s) doesn't follow record %d: a record was removed or reordered", i+1, r.Time, r.Module, i)
+		}
+		if r.digest() != r.Hash {
+			return i, fmt.Errorf("record %d (%s, %s) was altered: its hash doesn't match", i+1, r.Time, r.Module)
+		}
+		prev = r.Hash
+	}
+	return len(records), nil
+}
+
+func postAudit(target string, record *auditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv(auditTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the audit log of runs",
+	}
+	verify := &cobra.Command{
+		Use:   "verify [audit_log]",
+		Short: "Check that no audit record was altered, removed or reordered",
+		Long: `Checks the hash chain of the audit log (default: audit.log of the config,
+or TFPRGEN_AUDIT_LOG): every record's hash must match its content and name
+the record before it. Exits 1 at the first record that doesn't.
+
+Examples:
+  terraform-pr-generator audit verify
+  terraform-pr-generator audit verify /var/log/tfprgen/audit.log`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  runAuditVerify,
+	}
+	verify.Flags().StringP("config", "c", "", "Path to a YAML config file (default: .tfprgen.yaml in the repo root)")
+	cmd.AddCommand(verify)
+	return cmd
+}
+
+func runAuditVerify(cmd *cobra.Command, args []string) {
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	} else {
+		configPath, _ := cmd.Flags().GetString("config")
+		if configPath == "" {
+			configPath = FindConfigFile(".")
+		}
+		cfg, err := LoadConfig(configPath)
+		if err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		if path = cfg.Audit.auditLogPath(); path == "" {
+			errorColor.Println("❌ Error: no audit log configured (audit.log or TFPRGEN_AUDIT_LOG)")
+			os.Exit(1)
+		}
+	}
+	records, err := readAuditLog(path)
+	if err != nil {
+		errorColor.Printf("❌ Error: %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	intact, err := verifyAuditChain(records)
+	if err != nil {
+		errorColor.Printf("❌ %s: %v (%d record(s) before it are intact)\n", path, err, intact)
+		os.Exit(1)
+	}
+	successColor.Printf("✅ %s: %d record(s), chain intact\n", path, len(records))
+	if len(records) > 0 {
+		last := records[len(records)-1]
+		fmt.Fprintf(console, "Last record: %s, %s by %s, hash %s\n", last.Time, last.Module, last.User, last.Hash)
+	}
+}
@@ -0,0 +1,39 @@
+package planner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	for _, module := range []string{"s3mod", "iam", "s3mod"} {
+		if err := appendAudit(path, &auditRecord{Module: module, User: "ci", Outcome: "succeeded"}); err != nil {
+			t.Fatalf("appendAudit: %v", err)
+		}
+	}
+	records, err := readAuditLog(path)
+	if err != nil {
+		t.Fatalf("readAuditLog: %v", err)
+	}
+	if n, err := verifyAuditChain(records); err != nil || n != 3 {
+		t.Fatalf("verifying the log: %d intact, %v", n, err)
+	}
+	if records[1].Prev != records[0].Hash {
+		t.Errorf("record 2 follows %q, want record 1's hash", records[1].Prev)
+	}
+
+	// Editing a record or removing one breaks the chain.
+	records[1].User = "someone-else"
+	if n, err := verifyAuditChain(records); err == nil || n != 1 {
+		t.Errorf("altered record: %d intact, %v", n, err)
+	}
+	records, _ = readAuditLog(path)
+	if n, err := verifyAuditChain([]*auditRecord{records[0], records[2]}); err == nil || n != 1 {
+		t.Errorf("removed record: %d intact, %v", n, err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("the lock file was left behind: %v", err)
+	}
+}
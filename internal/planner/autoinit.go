@@ -0,0 +1,65 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// initRequiredRegex matches the errors terraform fails a plan with when
+// the state directory isn't initialized, or not for its current config.
+var initRequiredRegex = regexp.MustCompile(`(?i)(please run "?terraform init|run "terraform init"|Module not installed|Backend initialization required|Required plugins are not installed|Could not load plugin|Inconsistent dependency lock file|missing or corrupted provider plugins)`)
+
+// initRequiredError is a plan failing because its state needs initializing.
+type initRequiredError struct {
+	Err error
+}
+
+func (e *initRequiredError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *initRequiredError) Unwrap() error {
+	return e.Err
+}
+
+// stateInit initializes one state directory for --auto-init, once however
+// many of its workspaces need it.
+type stateInit struct {
+	once sync.Once
+	err  error
+}
+
+// planInitialized runs a targeted plan and, with --auto-init, initializes
+// a state whose plan failed for lack of init through runner.init, then
+// plans it again.
+func (pg *PlanGenerator) planInitialized(ctx context.Context, p *Partition, state *State) ([]byte, error) {
+	output, err := pg.planState(ctx, p, state)
+	var initErr *initRequiredError
+	if !pg.AutoInit || !errors.As(err, &initErr) || ctx.Err() != nil {
+		return output, err
+	}
+
+	pg.flushMu.Lock()
+	if pg.autoInits == nil {
+		pg.autoInits = make(map[string]*stateInit)
+	}
+	dirInit := pg.autoInits[state.Path]
+	if dirInit == nil {
+		dirInit = &stateInit{}
+		pg.autoInits[state.Path] = dirInit
+	}
+	pg.flushMu.Unlock()
+
+	dirInit.once.Do(func() {
+		warningColor.Printf("🔧 %s isn't initialized, running init and planning again (--auto-init)\n", state.Path)
+		pg.progress.log(state.String(), "--- not initialized, running init (--auto-init) ---")
+		dirInit.err = pg.initState(ctx, state)
+	})
+	if dirInit.err != nil {
+		return nil, fmt.Errorf("%v; --auto-init then failed: %v", err, dirInit.err)
+	}
+	return pg.planState(ctx, p, state)
+}
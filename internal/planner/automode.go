@@ -0,0 +1,155 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Planning modes accepted by --mode.
+const (
+	modeFull     = "full"
+	modeTargeted = "targeted"
+	modeAuto     = "auto"
+)
+
+// AutoModeConfig controls how --mode auto picks between targeted and full
+// planning.
+type AutoModeConfig struct {
+	// Base is the ref the branch is diffed against. GITHUB_BASE_REF wins
+	// when set, so pull request workflows need no configuration.
+	Base string `yaml:"base"`
+	// SharedPaths are globs (with ** for any number of directories) of
+	// files included by many states; changing one escalates to plan_all.
+	SharedPaths []string `yaml:"shared_paths"`
+}
+
+// DefaultAutoMode escalates on root-level terragrunt config and the usual
+// shared include files.
+func DefaultAutoMode() AutoModeConfig {
+	return AutoModeConfig{
+		Base: "origin/main",
+		SharedPaths: []string{
+			"*.hcl",
+			"_envcommon/**",
+			"**/account.hcl",
+			"**/env.hcl",
+			"**/region.hcl",
+			"**/common.hcl",
+		},
+	}
+}
+
+// parseMode checks a --mode value.
+func parseMode(mode string) error {
+	switch mode {
+	case modeFull, modeTargeted, modeAuto:
+		return nil
+	}
+	return fmt.Errorf("invalid mode %q (supported: full, targeted, auto)", mode)
+}
+
+// chooseMode inspects the branch's changes and reports whether targeted
+// planning is safe, with the reason for the decision.
+func (pg *PlanGenerator) chooseMode() (targeted bool, reason string) {
+	base := pg.baseRef()
+	changed, err := changedFiles(base)
+	if err != nil {
+		return false, fmt.Sprintf("could not diff against %s (%v)", base, err)
+	}
+
+	var shared []string
+	moduleFiles := 0
+	moduleDir := filepath.ToSlash(pg.moduleDir()) + "/"
+	for _, file := range changed {
+		if strings.HasPrefix(file, moduleDir) {
+			moduleFiles++
+			continue
+		}
+		for _, pattern := range pg.Config.AutoMode.SharedPaths {
+			if matchGlob(pattern, file) {
+				shared = append(shared, file)
+				break
+			}
+		}
+	}
+
+	if len(shared) > 0 {
+		const maxListed = 3
+		listed := shared
+		if len(listed) > maxListed {
+			listed = listed[:maxListed]
+		}
+		reason = fmt.Sprintf("shared config changed: %s", strings.Join(listed, ", "))
+		if len(shared) > maxListed {
+			reason += fmt.Sprintf(" and %d more", len(shared)-maxListed)
+		}
+		return false, reason
+	}
+	if moduleFiles == 0 {
+		return true, fmt.Sprintf("no shared config changed since %s", base)
+	}
+	return true, fmt.Sprintf("%d file(s) changed inside %s, no shared config", moduleFiles, moduleDir)
+}
+
+// baseRef is the ref the branch is compared against: the pull request's
+// base branch in GitHub Actions, otherwise auto_mode.base.
+func (pg *PlanGenerator) baseRef() string {
+	if ref := os.Getenv("GITHUB_BASE_REF"); ref != "" {
+		return "origin/" + ref
+	}
+	return pg.Config.AutoMode.Base
+}
+
+// forkPoint is the commit this branch forked from base at.
+func forkPoint(base string) (string, error) {
+	out, err := commandOutput(exec.Command("git", "merge-base", base, "HEAD"))
+	if err != nil {
+		return "", fmt.Errorf("git merge-base failed: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// changedFiles lists files changed on this branch since it forked from
+// base, including uncommitted changes.
+func changedFiles(base string) ([]string, error) {
+	fork, err := forkPoint(base)
+	if err != nil {
+		return nil, err
+	}
+	out, err := commandOutput(exec.Command("git", "diff", "--name-only", fork))
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %v", err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// matchGlob matches a slash-separated path against a pattern where "**"
+// stands for any number of directories and other segments use path.Match.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
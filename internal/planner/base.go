@@ -0,0 +1,198 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// baseDir is the subdirectory of the output directory holding the plans of
+// the merge-base (--base).
+const baseDir = "base"
+
+// compareWithBase plans the merge-base of the branch and --base in a
+// temporary worktree and leaves out of results the region plans that are
+// the same there, i.e. changes that predate the branch, like drift. When
+// the base can't be planned the report keeps every plan.
+func (pg *PlanGenerator) compareWithBase(results []*PartitionResult) {
+	fork, err := forkPoint(pg.Base)
+	if err != nil {
+		warningColor.Printf("⚠️  --base: %v; the report has every plan\n", err)
+		return
+	}
+	infoColor.Printf("🔀 Planning the merge-base %.12s of %s to leave out unchanged plans...\n", fork, pg.Base)
+	base, err := pg.planBase(fork)
+	if err != nil {
+		warningColor.Printf("⚠️  --base: %v; the report has every plan\n", err)
+		return
+	}
+	pg.baseUnchanged = dropUnchangedPlans(results, base)
+	successColor.Printf("🔀 Left out %d region plan(s) that are the same on %s\n", len(pg.baseUnchanged), pg.Base)
+}
+
+// planBase checks out fork in a temporary worktree and plans there what the
+// run planned: its targeted states, or each partition's plan_all. The
+// plans go to the base subdirectory of the output directory.
+func (pg *PlanGenerator) planBase(fork string) ([]*PartitionResult, error) {
+	prefix, err := commandOutput(exec.Command("git", "rev-parse", "--show-prefix"))
+	if err != nil {
+		return nil, fmt.Errorf("git rev-parse failed: %v", err)
+	}
+	tmp, err := os.MkdirTemp("", "tfprgen-base-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	worktree := filepath.Join(tmp, "checkout")
+	if out, err := commandOutput(exec.Command("git", "worktree", "add", "--detach", worktree, fork)); err != nil {
+		return nil, fmt.Errorf("git worktree add failed: %v\n%s", err, strings.TrimSpace(string(out)))
+	}
+	defer commandOutput(exec.Command("git", "worktree", "remove", "--force", worktree))
+	// Plans run from the same directory of the base checkout
+	workDir := filepath.Join(worktree, strings.TrimSpace(string(prefix)))
+
+	base := &PlanGenerator{ModuleName: pg.ModuleName, OutputDir: filepath.Join(pg.OutputDir, baseDir), Config: pg.Config}
+	if err := os.MkdirAll(base.OutputDir, 0755); err != nil {
+		return nil, err
+	}
+	groups := make(map[*Partition][]*State)
+	for _, state := range pg.plannedStates {
+		if p := pg.Config.PartitionFor(state.String()); p != nil {
+			groups[p] = append(groups[p], state)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, p := range pg.Config.Partitions {
+		outputFile := filepath.Join(base.OutputDir, p.OutputFile)
+		if len(pg.plannedStates) > 0 && len(groups[p]) == 0 {
+			os.WriteFile(outputFile, []byte(p.EmptyPlaceholder()), 0644)
+			continue
+		}
+		wg.Add(1)
+		go func(p *Partition, outputFile string) {
+			defer wg.Done()
+			var err error
+			if len(pg.plannedStates) > 0 {
+				err = pg.planBaseStates(p, groups[p], workDir, outputFile)
+			} else {
+				err = pg.planBasePartition(p, workDir, outputFile)
+			}
+			if err != nil {
+				warningColor.Printf("⚠️  --base: %s: %v\n", p.Name, err)
+			}
+		}(p, outputFile)
+	}
+	wg.Wait()
+	if err := pg.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return base.collectResults()
+}
+
+// planBaseStates plans a partition's targeted states in the base checkout,
+// initialized first since it has no .terraform directories. States that
+// can't be planned there, e.g. because the branch adds them, are left out
+// so their plans are kept.
+func (pg *PlanGenerator) planBaseStates(p *Partition, states []*State, workDir, outputFile string) error {
+	outputs := make([][]byte, len(states))
+	var wg sync.WaitGroup
+	for i, state := range states {
+		wg.Add(1)
+		go func(i int, state *State) {
+			defer wg.Done()
+			pool := pg.poolFor(p)
+			pool.acquire()
+			defer pool.releaseUntimed()
+			if _, err := os.Stat(filepath.Join(workDir, state.Path)); err != nil {
+				return
+			}
+			var output bytes.Buffer
+			if pg.Config.Runner.Init != "" {
+				argv, err := pg.Config.Runner.InitCommand(p, pg.ModuleName, state.Path)
+				if err != nil || pg.execute(pg.ctx, &Command{Args: argv, Dir: workDir, Env: pg.commandEnv(state), Stdout: &output, Stderr: &output}) != nil {
+					return
+				}
+			}
+			argv, err := pg.Config.Runner.PlanCommand(p, pg.ModuleName, state.Path, pg.planArgs())
+			if err != nil {
+				return
+			}
+			output.Reset()
+			// Masked like the branch's plans, so they compare alike
+			if pg.execute(pg.ctx, &Command{Args: argv, Dir: workDir, Env: pg.commandEnv(state), Stdout: &output, Stderr: io.Discard, maskCount: new(int)}) == nil {
+				outputs[i] = output.Bytes()
+			}
+		}(i, state)
+	}
+	wg.Wait()
+
+	var b bytes.Buffer
+	for i, state := range states {
+		if outputs[i] != nil {
+			fmt.Fprintln(&b, state.Header())
+			b.Write(outputs[i])
+			b.WriteString("\n")
+		}
+	}
+	return os.WriteFile(outputFile, b.Bytes(), 0644)
+}
+
+// planBasePartition runs a partition's plan_all in the base checkout.
+func (pg *PlanGenerator) planBasePartition(p *Partition, workDir, outputFile string) error {
+	argv, err := pg.Config.Runner.PlanAllCommand(p, pg.ModuleName, pg.planArgs())
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var stderr bytes.Buffer
+	if err := pg.execute(pg.ctx, &Command{Args: argv, Dir: workDir, Env: pg.partitionEnv(p), Stdout: file, Stderr: &stderr, maskCount: new(int)}); err != nil {
+		return pg.commandError(err, p.Name+"-base", stderr.Bytes())
+	}
+	return nil
+}
+
+// dropUnchangedPlans removes from results the region plans that are the
+// same in base, and environments left without any, returning their
+// partition/env/region. Incomplete plans are always kept.
+func dropUnchangedPlans(results, base []*PartitionResult) []string {
+	basePlans := regionPlans(base).plans
+	var dropped []string
+	for _, result := range results {
+		var envs []*Environment
+		for _, env := range result.Environments {
+			var regions []string
+			for _, region := range env.Regions {
+				key := regionKey{result.Partition.Name, env.Name, region}
+				if before, ok := basePlans[key]; ok && !env.Incomplete[region] && normalizePlan(before) == normalizePlan(env.Plans[region]) {
+					dropped = append(dropped, key.String())
+					continue
+				}
+				regions = append(regions, region)
+			}
+			if len(regions) > 0 {
+				env.Regions = regions
+				envs = append(envs, env)
+			}
+		}
+		result.Environments = envs
+	}
+	return dropped
+}
+
+// writeBaseNote says which region plans --base left out.
+func (pg *PlanGenerator) writeBaseNote(output *os.File) {
+	if pg.Base == "" || len(pg.baseUnchanged) == 0 {
+		return
+	}
+	output.WriteString(fmt.Sprintf("> %sCompared with `%s`: %d region plan(s) that are the same there, e.g. pre-existing drift, are left out: %s\n\n",
+		pg.renderer().Icon("🔀"), pg.Base, len(pg.baseUnchanged), strings.Join(pg.baseUnchanged, ", ")))
+}
@@ -0,0 +1,28 @@
+package planner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDropUnchangedPlans(t *testing.T) {
+	commercial := &Partition{Name: "commercial"}
+	drift := "  # aws_s3_bucket.logs will be updated in-place\nPlan: 0 to add, 1 to change, 0 to destroy."
+	results := testResults(commercial,
+		testEnvironment("staging", map[string]string{"us-east-1": drift + "\n\n"}),
+		testEnvironment("production", map[string]string{"us-east-1": drift, "us-west-2": "Plan: 1 to add, 0 to change, 0 to destroy.", "eu-west-1": "Error: boom"}, "eu-west-1"),
+	)
+	base := testResults(commercial,
+		testEnvironment("staging", map[string]string{"us-east-1": drift}),
+		testEnvironment("production", map[string]string{"us-east-1": drift, "us-west-2": "No changes.", "eu-west-1": "Error: boom"}),
+	)
+
+	dropped := dropUnchangedPlans(results, base)
+	if want := "commercial/staging/us-east-1 commercial/production/us-east-1"; strings.Join(dropped, " ") != want {
+		t.Errorf("dropped %v, want %s", dropped, want)
+	}
+	envs := results[0].Environments
+	if len(envs) != 1 || envs[0].Name != "production" || strings.Join(envs[0].Regions, " ") != "eu-west-1 us-west-2" {
+		t.Errorf("kept %+v", envs)
+	}
+}
@@ -0,0 +1,310 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// BatchConfig is a batch file: the repositories and modules the batch
+// subcommand plans in one invocation, e.g. to roll a provider bump out
+// everywhere.
+type BatchConfig struct {
+	// Title heads the consolidated report and the comments; "Terraform
+	// plans" by default.
+	Title string       `yaml:"title"`
+	Repos []*BatchRepo `yaml:"repos"`
+}
+
+// BatchRepo is one repository checkout of a batch and the modules planned
+// in it.
+type BatchRepo struct {
+	// Path is the checkout, relative to the batch file.
+	Path string `yaml:"path"`
+	// Name labels the repository in the report; the path's base name by
+	// default.
+	Name    string   `yaml:"name"`
+	Modules []string `yaml:"modules"`
+	// Config is the repository's config file, relative to its checkout;
+	// the .tfprgen.yaml found there by default.
+	Config string `yaml:"config"`
+	// PR is the pull request the repository's plans are commented on, none
+	// if 0. Repo is its GitHub repository (owner/name), by default the one
+	// the origin remote points to.
+	PR   int    `yaml:"pr"`
+	Repo string `yaml:"repo"`
+}
+
+// batchRun is the outcome of planning one module of a batch.
+type batchRun struct {
+	repo       *BatchRepo
+	module     string
+	dir        string // the run's output directory
+	totals     PlanCounts
+	incomplete bool
+	err        error
+}
+
+func newBatchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "batch <batch_file> [-- plan args...]",
+		Short: "Plan modules across several repositories into one report",
+		Long: `Plans every module a batch file lists, in each of its repository checkouts,
+and writes one consolidated report, batch-report.md, with a summary table and
+every run's report. Each repository with a pr gets one comment with the
+reports of its modules. Every flag of the main command applies to all the
+runs; --output names the batch's directory (default: batch-<timestamp>).
+
+  title: AWS provider 5.x rollout
+  repos:
+    - path: ../infra-core
+      modules: [vpc, iam_roles]
+      pr: 412
+    - path: ../infra-data
+      name: data
+      modules: [s3_malware_protection]
+      config: ci/.tfprgen.yaml
+      repo: acme/infra-data
+      pr: 87
+
+Paths are relative to the batch file. Comments authenticate with GITHUB_TOKEN,
+else the gh CLI's login.
+
+Examples:
+  terraform-pr-generator batch provider-bump.yaml --targeted
+  terraform-pr-generator batch provider-bump.yaml --output batch-aws-5 -- -refresh=false`,
+		Args: moduleArgs,
+		Run:  runBatch,
+	}
+	addPlanFlags(cmd)
+	return cmd
+}
+
+func runBatch(cmd *cobra.Command, args []string) {
+	batch, err := loadBatch(args[0])
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	var extraArgs []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		extraArgs = args[dash:]
+	}
+	outputDir, _ := cmd.Flags().GetString("output")
+	if outputDir == "" {
+		outputDir = "batch-" + time.Now().Format("20060102-150405")
+	}
+	if outputDir, err = filepath.Abs(outputDir); err == nil {
+		err = os.MkdirAll(outputDir, 0755)
+	}
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	var runs []*batchRun
+	for _, repo := range batch.Repos {
+		if ctx.Err() != nil {
+			break
+		}
+		boldColor.Printf("\n📦 %s (%s)\n", repo.Name, repo.Path)
+		var repoRuns []*batchRun
+		if err := os.Chdir(repo.Path); err != nil {
+			errorColor.Printf("❌ %s: %v\n", repo.Name, err)
+			for _, module := range repo.Modules {
+				repoRuns = append(repoRuns, &batchRun{repo: repo, module: module, err: err})
+			}
+		} else {
+			for _, module := range repo.Modules {
+				if ctx.Err() != nil {
+					break
+				}
+				repoRuns = append(repoRuns, planBatchModule(ctx, cmd, repo, module, filepath.Join(outputDir, repo.Name, module), extraArgs))
+			}
+			// The comment's repository can come from the checkout's remote
+			batch.comment(repo, repoRuns)
+		}
+		runs = append(runs, repoRuns...)
+		if err := os.Chdir(cwd); err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	reportPath := filepath.Join(outputDir, "batch-report.md")
+	if err := os.WriteFile(reportPath, []byte(batch.report(runs)), 0644); err != nil {
+		errorColor.Printf("❌ Error: writing the batch report: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(console)
+	var lines [][]string
+	failed := 0
+	for _, run := range runs {
+		lines = append(lines, []string{run.repo.Name, run.module, run.result(), run.plan()})
+		if run.err != nil {
+			failed++
+		}
+	}
+	printTable([]string{"REPOSITORY", "MODULE", "RESULT", "PLAN"}, lines)
+	successColor.Printf("📄 Batch report: %s\n", reportPath)
+	if err := ctx.Err(); err != nil {
+		errorColor.Printf("❌ Error: interrupted after %d of %d run(s)\n", len(runs), batch.runCount())
+		os.Exit(1)
+	}
+	if failed > 0 {
+		errorColor.Printf("❌ Error: %d of %d run(s) failed\n", failed, len(runs))
+		os.Exit(1)
+	}
+}
+
+// loadBatch reads a batch file, resolving its paths against the file's
+// directory.
+func loadBatch(path string) (*BatchConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file %s: %v", path, err)
+	}
+	batch := &BatchConfig{}
+	if err := yaml.Unmarshal(data, batch); err != nil {
+		return nil, fmt.Errorf("failed to parse batch file %s: %v", path, err)
+	}
+	if len(batch.Repos) == 0 {
+		return nil, fmt.Errorf("batch file %s lists no repos", path)
+	}
+	base, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for i, repo := range batch.Repos {
+		if repo.Path == "" {
+			return nil, fmt.Errorf("repos[%d]: path is required", i)
+		}
+		if !filepath.IsAbs(repo.Path) {
+			repo.Path = filepath.Join(base, repo.Path)
+		}
+		if repo.Name == "" {
+			repo.Name = filepath.Base(repo.Path)
+		}
+		if len(repo.Modules) == 0 {
+			return nil, fmt.Errorf("repos[%d] (%s): modules is required", i, repo.Name)
+		}
+		if names[repo.Name] {
+			return nil, fmt.Errorf("repos[%d]: %s is listed twice; give the repos names to tell them apart", i, repo.Name)
+		}
+		names[repo.Name] = true
+	}
+	return batch, nil
+}
+
+// planBatchModule plans one module of a batch from its repository's
+// checkout, the current directory.
+func planBatchModule(ctx context.Context, cmd *cobra.Command, repo *BatchRepo, module, dir string, extraArgs []string) *batchRun {
+	run := &batchRun{repo: repo, module: module, dir: dir}
+	pg, err := newPlanGenerator(cmd, module, repo.Config)
+	if err != nil {
+		errorColor.Printf("❌ %s: %s: %v\n", repo.Name, module, err)
+		run.err = err
+		return run
+	}
+	pg.OutputDir = dir
+	pg.ExtraArgs = append(pg.ExtraArgs, extraArgs...)
+	// The batch comments once per repository, with all its modules
+	pg.GitHubComment = false
+	pg.PRDescription = false
+
+	run.err = pg.RunContext(ctx)
+	run.totals, run.incomplete = planTotals(pg.results)
+	if run.err != nil {
+		errorColor.Printf("❌ %s: %s: %v\n", repo.Name, module, run.err)
+	}
+	return run
+}
+
+func (b *BatchConfig) runCount() int {
+	n := 0
+	for _, repo := range b.Repos {
+		n += len(repo.Modules)
+	}
+	return n
+}
+
+// result sums a run up for the summary tables.
+func (r *batchRun) result() string {
+	switch {
+	case r.err != nil:
+		message, _, _ := strings.Cut(r.err.Error(), "\n")
+		return "❌ " + message
+	case r.incomplete:
+		return "⚠️ incomplete"
+	}
+	return "✅"
+}
+
+// plan is the run's plan totals, "-" for a run that failed without plans.
+func (r *batchRun) plan() string {
+	if r.err != nil && r.totals == (PlanCounts{}) {
+		return "-"
+	}
+	return describeCounts(&r.totals)
+}
+
+// report renders runs as markdown: a summary table, then each run's
+// pr-ready.md.
+func (b *BatchConfig) report(runs []*batchRun) string {
+	title := b.Title
+	if title == "" {
+		title = "Terraform plans"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## %s\n\n", title)
+	sb.WriteString("| Repository | Module | Result | Plan |\n| --- | --- | --- | --- |\n")
+	for _, run := range runs {
+		fmt.Fprintf(&sb, "| %s | `%s` | %s | %s |\n", run.repo.Name, run.module, strings.ReplaceAll(run.result(), "|", `\|`), run.plan())
+	}
+	sb.WriteString("\n")
+	for _, run := range runs {
+		fmt.Fprintf(&sb, "## %s: `%s`\n\n", run.repo.Name, run.module)
+		report, err := os.ReadFile(filepath.Join(run.dir, "pr-ready.md"))
+		if run.err != nil && (run.dir == "" || err != nil) {
+			fmt.Fprintf(&sb, "_No report: %v_\n\n", run.err)
+			continue
+		} else if err != nil {
+			sb.WriteString("_No report was written._\n\n")
+			continue
+		}
+		sb.Write(report)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// comment posts a repository's runs on its pull request, if it has one.
+// Failures only warn.
+func (b *BatchConfig) comment(repo *BatchRepo, runs []*batchRun) {
+	if repo.PR == 0 || len(runs) == 0 {
+		return
+	}
+	client, err := repoClient(repo.Repo, "origin")
+	if err == nil {
+		_, err = client.createComment(repo.PR, truncateComment(b.report(runs)))
+	}
+	if err != nil {
+		warningColor.Printf("⚠️  Commenting on %s's PR #%d failed: %v\n", repo.Name, repo.PR, err)
+		return
+	}
+	successColor.Printf("💬 Commented on %s#%d\n", client.repo, repo.PR)
+}
@@ -0,0 +1,29 @@
+package planner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadBatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "batch.yaml")
+	os.WriteFile(path, []byte("repos:\n  - path: infra\n    modules: [vpc]\n    pr: 3\n  - path: /abs/infra\n    name: other\n    modules: [iam]\n"), 0644)
+	batch, err := loadBatch(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo := batch.Repos[0]; repo.Path != filepath.Join(dir, "infra") || repo.Name != "infra" || repo.PR != 3 {
+		t.Errorf("repos[0] = %+v", repo)
+	}
+	if batch.runCount() != 2 {
+		t.Errorf("runCount() = %d, want 2", batch.runCount())
+	}
+
+	os.WriteFile(path, []byte("repos:\n  - path: a/infra\n    modules: [vpc]\n  - path: b/infra\n    modules: [vpc]\n"), 0644)
+	if _, err := loadBatch(path); err == nil || !strings.Contains(err.Error(), "listed twice") {
+		t.Errorf("duplicate names: got %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxConsumersListed caps the states listed per shared file in the report.
+const maxConsumersListed = 20
+
+// sharedChange is a file changed on the branch that other states read
+// (an included hcl file, shared locals, a local data-only module), with
+// the states reading it that the run doesn't plan.
+type sharedChange struct {
+	File      string   `json:"file"`
+	Consumers []string `json:"consumers"`
+}
+
+var (
+	// findInParentRegex matches find_in_parent_folders() with an optional
+	// file name.
+	findInParentRegex = regexp.MustCompile(`find_in_parent_folders\(\s*(?:"([^"]*)")?\s*\)`)
+	// readFileRegex matches functions reading a literal path.
+	readFileRegex = regexp.MustCompile(`\b(?:read_terragrunt_config|file|templatefile)\(\s*"([^"]+)"`)
+)
+
+// checkBlastRadius reports unplanned states reading shared files changed
+// on the branch and, with --include-consumers, adds them to a targeted
+// run's states (narrowed by --select like the others).
+func (pg *PlanGenerator) checkBlastRadius(targeted bool, states []*State) []*State {
+	planned := states
+	if !targeted {
+		paths, err := findModuleStates(pg.Config.Runner.WorkingDir, pg.ModuleName)
+		if err != nil {
+			warningColor.Printf("⚠️  Can't check the blast radius of shared changes: %v\n", err)
+			return states
+		}
+		planned = statesFromPaths(paths)
+	}
+	changes, err := pg.findBlastRadius(pg.baseRef(), planned)
+	if err != nil {
+		if pg.Verbose {
+			warningColor.Printf("⚠️  Can't check the blast radius of shared changes: %v\n", err)
+		}
+		return states
+	}
+	consumers := unplannedConsumers(changes)
+	if len(consumers) == 0 {
+		return states
+	}
+	pg.blastRadius = changes
+
+	if !pg.IncludeConsumers {
+		warningColor.Printf("💥 %d unplanned state(s) read shared files changed on this branch; pass --include-consumers to plan them\n", len(consumers))
+		return states
+	}
+	if !targeted {
+		warningColor.Println("⚠️  --include-consumers only applies to targeted runs; consumers are listed in the report instead")
+		return states
+	}
+	added := statesFromPaths(consumers)
+	if pg.selector != nil {
+		added = pg.selectStates(added, pg.selector)
+	}
+	if len(added) == 0 {
+		return states
+	}
+	pg.consumersIncluded = true
+	successColor.Printf("💥 Added %d state(s) reading shared files changed on this branch\n", len(added))
+	if pg.Verbose {
+		for _, state := range added {
+			fmt.Fprintf(console, "  - %s\n", state)
+		}
+	}
+	return append(states, added...)
+}
+
+// findBlastRadius lists files changed since the fork point with base that
+// states outside planned read, because targeted discovery by module name
+// doesn't see them. Only terragrunt states are inspected.
+func (pg *PlanGenerator) findBlastRadius(base string, planned []*State) ([]*sharedChange, error) {
+	changed, err := changedFiles(base)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	dirs, err := findModuleStates(pg.Config.Runner.WorkingDir, "")
+	if err != nil {
+		return nil, err
+	}
+
+	isPlanned := make(map[string]bool)
+	for _, state := range planned {
+		isPlanned[filepath.Clean(state.Path)] = true
+	}
+	byFile := make(map[string]*sharedChange)
+	for _, dir := range dirs {
+		if isPlanned[filepath.Clean(dir)] {
+			continue
+		}
+		inputs := stateInputs(dir)
+		for _, file := range changed {
+			if insideDir(file, filepath.ToSlash(filepath.Clean(dir))) || !readsFile(inputs, file) {
+				continue
+			}
+			change := byFile[file]
+			if change == nil {
+				change = &sharedChange{File: file}
+				byFile[file] = change
+			}
+			change.Consumers = append(change.Consumers, dir)
+		}
+	}
+
+	var changes []*sharedChange
+	for _, change := range byFile {
+		sort.Strings(change.Consumers)
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].File < changes[j].File })
+	return changes, nil
+}
+
+// stateInputs lists the files and directories (slash-separated, relative
+// to the repo root) a state's terragrunt config reads: parent includes,
+// read_terragrunt_config and file() paths and local module sources,
+// following included hcl files.
+func stateInputs(dir string) []string {
+	var inputs []string
+	seen := make(map[string]bool)
+	var visit func(config string)
+	visit = func(config string) {
+		if seen[config] {
+			return
+		}
+		seen[config] = true
+		data, err := os.ReadFile(config)
+		if err != nil {
+			return
+		}
+		add := func(path string) {
+			path = filepath.ToSlash(filepath.Clean(path))
+			if !contains(inputs, path) {
+				inputs = append(inputs, path)
+			}
+			if strings.HasSuffix(path, ".hcl") {
+				visit(path)
+			}
+		}
+		content := string(data)
+		for _, m := range findInParentRegex.FindAllStringSubmatch(content, -1) {
+			if found := findInParent(dir, m[1]); found != "" {
+				add(found)
+			}
+		}
+		for _, m := range readFileRegex.FindAllStringSubmatch(content, -1) {
+			if path, ok := localPath(dir, m[1]); ok {
+				add(path)
+			}
+		}
+		for _, line := range strings.Split(content, "\n") {
+			if m := sourceRegex.FindStringSubmatch(line); m != nil {
+				// dir//subdir sources copy the whole of dir.
+				source, _, _ := strings.Cut(m[1], "//")
+				if path, ok := localPath(dir, source); ok {
+					add(path)
+				}
+			}
+		}
+	}
+	visit(filepath.Join(dir, "terragrunt.hcl"))
+	return inputs
+}
+
+// findInParent resolves find_in_parent_folders(name) for a state, which
+// searches from the state's parent directory upwards.
+func findInParent(dir, name string) string {
+	if name == "" {
+		name = "terragrunt.hcl"
+	}
+	for current := filepath.Dir(filepath.Clean(dir)); ; {
+		candidate := filepath.Join(current, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return ""
+		}
+		current = parent
+	}
+}
+
+// localPath resolves a path written in a state's config relative to the
+// state, understanding ${get_terragrunt_dir()}. Remote sources and other
+// interpolations aren't local paths.
+func localPath(dir, path string) (string, bool) {
+	path = strings.TrimPrefix(path, "${get_terragrunt_dir()}/")
+	if strings.Contains(path, "${") || strings.Contains(path, "::") || strings.Contains(path, "://") {
+		return "", false
+	}
+	if filepath.IsAbs(path) {
+		return "", false
+	}
+	if !strings.HasPrefix(path, ".") && !strings.Contains(path, "/") {
+		// A bare name is a file next to the config.
+		return filepath.Join(dir, path), true
+	}
+	if !strings.HasPrefix(path, ".") {
+		// Likely a registry source such as org/module/aws.
+		return "", false
+	}
+	return filepath.Join(dir, path), true
+}
+
+// readsFile reports whether file is one of inputs or inside one of them.
+func readsFile(inputs []string, file string) bool {
+	for _, input := range inputs {
+		if file == input || insideDir(file, input) {
+			return true
+		}
+	}
+	return false
+}
+
+func insideDir(file, dir string) bool {
+	return dir == "." || strings.HasPrefix(file, dir+"/")
+}
+
+// unplannedConsumers lists every state reading a shared change once.
+func unplannedConsumers(changes []*sharedChange) []string {
+	var consumers []string
+	for _, change := range changes {
+		for _, consumer := range change.Consumers {
+			if !contains(consumers, consumer) {
+				consumers = append(consumers, consumer)
+			}
+		}
+	}
+	sort.Strings(consumers)
+	return consumers
+}
+
+// writeBlastRadius renders the shared changes section.
+func (pg *PlanGenerator) writeBlastRadius(output *os.File) {
+	if len(pg.blastRadius) == 0 {
+		return
+	}
+	output.WriteString("## " + pg.renderer().Icon("💥") + "Blast radius\n\n")
+	if pg.consumersIncluded {
+		output.WriteString("This PR changes files that other states read; those states were added to the plan (`--include-consumers`):\n\n")
+	} else {
+		output.WriteString("This PR changes files that these states read, but they were **not planned**. Consider planning them too (`--include-consumers`):\n\n")
+	}
+	for _, change := range pg.blastRadius {
+		output.WriteString(fmt.Sprintf("- `%s`\n", change.File))
+		for i, consumer := range change.Consumers {
+			if i == maxConsumersListed {
+				output.WriteString(fmt.Sprintf("  - … and %d more\n", len(change.Consumers)-maxConsumersListed))
+				break
+			}
+			output.WriteString(fmt.Sprintf("  - `%s`\n", filepath.ToSlash(consumer)))
+		}
+	}
+	output.WriteString("\n")
+}
@@ -0,0 +1,149 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// truncatedSection is a plan cut down to its resource headers, errors and
+// summary to keep the report within MaxOutputBytes.
+type truncatedSection struct {
+	// URL links the full plan: signed in the --upload destination, a
+	// secret gist, or else its path in the output directory.
+	URL       string
+	Bytes     int
+	Condensed string
+}
+
+// budgetSection is a region plan that can be truncated.
+type budgetSection struct {
+	key, name, env, region string
+	content                string
+	condensed              string
+}
+
+// fitOutputBudget truncates the largest plans of pr-ready.md, biggest
+// first, until the report fits in MaxOutputBytes, then writes it again.
+// Plans already linked are left alone; a report that doesn't fit even then
+// is kept with a warning.
+func (pg *PlanGenerator) fitOutputBudget(results []*PartitionResult, mismatch string) error {
+	if pg.MaxOutputBytes <= 0 {
+		return nil
+	}
+	path := filepath.Join(pg.OutputDir, "pr-ready.md")
+	for {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		excess := int(info.Size()) - pg.MaxOutputBytes
+		if excess <= 0 {
+			return nil
+		}
+		sections := pg.budgetSections(results)
+		if len(sections) == 0 {
+			warningColor.Printf("⚠️  pr-ready.md is %d KB, over --max-output-bytes by %d KB even with every plan truncated\n", info.Size()/1024, excess/1024)
+			return nil
+		}
+		for _, section := range sections {
+			if excess <= 0 {
+				break
+			}
+			if pg.truncated == nil {
+				pg.truncated = make(map[string]*truncatedSection)
+			}
+			pg.truncated[section.key] = &truncatedSection{
+				URL:       pg.linkFullPlan(section),
+				Bytes:     len(section.content),
+				Condensed: section.condensed,
+			}
+			excess -= len(section.content) - len(section.condensed)
+			if pg.Verbose {
+				fmt.Fprintf(console, "✂️  Truncated the %s %s plan (%d KB) to fit --max-output-bytes\n", section.env, section.region, len(section.content)/1024)
+			}
+		}
+		if err := pg.generatePRMarkdown(results, mismatch); err != nil {
+			return err
+		}
+	}
+}
+
+// budgetSections are the embedded plans that truncating makes smaller,
+// largest first; merged environments count once.
+func (pg *PlanGenerator) budgetSections(results []*PartitionResult) []*budgetSection {
+	var sections []*budgetSection
+	for _, result := range results {
+		for _, group := range pg.environmentGroups(result) {
+			env := group[0]
+			for _, region := range env.Regions {
+				key := sectionKey(result.Partition, env.Name, region)
+				if pg.offloaded[key] != nil || pg.truncated[key] != nil {
+					continue
+				}
+				content := collapseForEach(env.Plans[region], pg.CollapseForEach)
+				condensed := condensePlan(content)
+				if len(condensed) >= len(content) {
+					continue
+				}
+				sections = append(sections, &budgetSection{
+					key:       key,
+					name:      fmt.Sprintf("%s-%s-%s-%s.txt", pg.ModuleName, result.Partition.Name, env.Name, region),
+					env:       env.Name,
+					region:    region,
+					content:   content,
+					condensed: condensed,
+				})
+			}
+		}
+	}
+	sort.SliceStable(sections, func(i, j int) bool { return len(sections[i].content) > len(sections[j].content) })
+	return sections
+}
+
+// condensePlan keeps a plan's resource action headers, errors and Plan:
+// summary line.
+func condensePlan(content string) string {
+	var kept []string
+	for _, line := range strings.Split(content, "\n") {
+		if resourceHeaderRegex.MatchString(line) || errorLineRegex.MatchString(line) || planCountsRegex.MatchString(line) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// linkFullPlan keeps a truncated plan in full, where the report links it:
+// the --upload destination or a gist like oversized plans, else the
+// sections/ directory of the run.
+func (pg *PlanGenerator) linkFullPlan(section *budgetSection) string {
+	var url string
+	var err error
+	switch token := os.Getenv("GIST_TOKEN"); {
+	case pg.upload != nil:
+		url, err = pg.uploadSection(section.name, section.content)
+	case token != "":
+		gists := newGitHubAPI()
+		gists.token = token
+		url, err = gists.createGist(fmt.Sprintf("terraform plan: %s %s %s", pg.ModuleName, section.env, section.region), section.name, section.content)
+	}
+	if err != nil {
+		warningColor.Printf("⚠️  Couldn't link the full %s %s plan, keeping it in the output directory: %v\n", section.env, section.region, err)
+	}
+	if url != "" && err == nil {
+		return url
+	}
+	rel := filepath.ToSlash(filepath.Join("sections", section.name))
+	if err := os.MkdirAll(filepath.Join(pg.OutputDir, "sections"), 0755); err == nil {
+		os.WriteFile(filepath.Join(pg.OutputDir, rel), []byte(section.content), 0644)
+	}
+	return rel
+}
+
+// truncatedNote heads a truncated section, linking the full plan.
+func (pg *PlanGenerator) truncatedNote(section *truncatedSection) string {
+	return fmt.Sprintf("> %sShortened to its resource headers to fit the report's size budget (%d KB in full): [view the full plan](%s)\n\n",
+		pg.renderer().Icon("✂️"), section.Bytes/1024, section.URL)
+}
@@ -0,0 +1,47 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFitOutputBudget(t *testing.T) {
+	pg := newTestGenerator(t, nil)
+	var big strings.Builder
+	big.WriteString("Terraform will perform the following actions:\n\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&big, "  # aws_s3_object.file[%d] will be created\n  + resource \"aws_s3_object\" \"file\" {\n      + key = \"objects/%d\"\n    }\n\n", i, i)
+	}
+	big.WriteString("Plan: 200 to add, 0 to change, 0 to destroy.")
+	small := "No changes. Your infrastructure matches the configuration."
+	results := testResults(pg.Config.Partitions[0],
+		testEnvironment("production", map[string]string{"us-east-1": small}),
+		testEnvironment("staging", map[string]string{"us-east-1": big.String()}),
+	)
+	pg.MaxOutputBytes = 12000
+	if err := pg.generatePRMarkdown(results, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.fitOutputBudget(results, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	report, _ := os.ReadFile(filepath.Join(pg.OutputDir, "pr-ready.md"))
+	if len(report) > pg.MaxOutputBytes {
+		t.Errorf("report is %d bytes, over the budget of %d", len(report), pg.MaxOutputBytes)
+	}
+	for _, want := range []string{"# aws_s3_object.file[199] will be created", "Plan: 200 to add", "(sections/vpc-commercial-staging-us-east-1.txt)", small} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report lacks %q", want)
+		}
+	}
+	if strings.Contains(string(report), `key = "objects/1"`) {
+		t.Error("report still has the truncated plan's attributes")
+	}
+	if full, err := os.ReadFile(filepath.Join(pg.OutputDir, "sections", "vpc-commercial-staging-us-east-1.txt")); err != nil || string(full) != big.String() {
+		t.Errorf("full plan not kept: %v", err)
+	}
+}
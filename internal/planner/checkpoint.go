@@ -0,0 +1,163 @@
+package planner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// checkpointDir holds what a run has finished so far: the output of each
+// planned state, and a marker per partition whose plan_all succeeded. It
+// lets --resume pick up an interrupted run, and is removed once the run
+// completes.
+const checkpointDir = "checkpoints"
+
+// checkpointPath is where the checkpoint of a state or partition named name
+// is kept, named after its hash since state paths have slashes.
+func (pg *PlanGenerator) checkpointPath(kind, name string) string {
+	sum := sha256.Sum256([]byte(kind + " " + name))
+	return filepath.Join(pg.OutputDir, checkpointDir, kind+"-"+hex.EncodeToString(sum[:8]))
+}
+
+// writeCheckpoint writes a checkpoint through a temporary file, so a crash
+// never leaves half of one.
+func writeCheckpoint(path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+// planCheckpointed plans a targeted state, checkpointing its output once it
+// succeeds. A --resume run reuses the checkpoint of a state the interrupted
+// run finished instead, unless its saved plan (--save-plans) is missing or
+// the run is anonymized: checkpoints carry the interrupted run's pseudonyms.
+func (pg *PlanGenerator) planCheckpointed(ctx context.Context, p *Partition, state *State) ([]byte, error) {
+	path := pg.checkpointPath("state", state.String())
+	if pg.resuming && !pg.Anonymize {
+		output, err := os.ReadFile(path)
+		if err == nil && state.PlanFile != "" {
+			_, err = os.Stat(filepath.Join(pg.OutputDir, state.PlanFile))
+		}
+		if err == nil {
+			pg.progress.log(state.String(), "--- planned before the interruption, reusing its output (--resume) ---")
+			return output, nil
+		}
+	}
+	output, err := pg.planWithHooks(ctx, p, state)
+	if err == nil && ctx.Err() == nil {
+		writeCheckpoint(path, output)
+	}
+	return output, err
+}
+
+// checkpointPartition records that a partition's plan_all succeeded.
+func (pg *PlanGenerator) checkpointPartition(p *Partition) {
+	writeCheckpoint(pg.checkpointPath("partition", p.Name), []byte(p.OutputFile+"\n"))
+}
+
+// resumedPartition tells whether a --resume run keeps the plans file of a
+// partition whose plan_all the interrupted run finished.
+func (pg *PlanGenerator) resumedPartition(p *Partition) bool {
+	if !pg.resuming || pg.Anonymize {
+		return false
+	}
+	if _, err := os.Stat(pg.checkpointPath("partition", p.Name)); err != nil {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(pg.OutputDir, p.OutputFile)); err != nil {
+		return false
+	}
+	if pg.Verbose {
+		fmt.Fprintf(console, "  ♻️  Keeping the %s plans finished before the interruption\n", p.Label)
+	}
+	return true
+}
+
+// dropCheckpoints removes the checkpoints of a completed run.
+func (pg *PlanGenerator) dropCheckpoints() {
+	os.RemoveAll(filepath.Join(pg.OutputDir, checkpointDir))
+}
+
+// resumeRun continues the interrupted run in runDir with the module, states,
+// config and plan arguments of its manifest.json, planning only what it
+// hadn't finished. Other flags apply as usual.
+func resumeRun(cmd *cobra.Command, runDir string, args []string) {
+	if err := checkResumable(cmd, args); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	manifest, err := readManifest(runDir)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(manifest.Checksums) > 0 {
+		successColor.Printf("✅ %s completed; nothing to resume\n", runDir)
+		return
+	}
+	if len(args) == 1 && args[0] != manifest.Module {
+		errorColor.Printf("❌ Error: %s is a run of %s, not %s\n", runDir, manifest.Module, args[0])
+		os.Exit(1)
+	}
+	pg, err := newPlanGenerator(cmd, manifest.Module, manifest.ConfigFile)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if manifest.Runner != "" && manifest.Runner != pg.Config.Runner.Name {
+		if err := pg.Config.SetRunner(manifest.Runner); err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	pg.OutputDir = runDir
+	pg.ModuleRoot = manifest.ModuleRoot
+	pg.Targeted = manifest.Targeted
+	pg.AutoMode = false
+	pg.modeReason = manifest.ModeReason
+	pg.States = manifest.States
+	pg.VarFiles = manifest.VarFiles
+	pg.Targets = manifest.Targets
+	pg.Select, pg.selector = manifest.Select, nil
+	pg.Destroy = manifest.Destroy
+	pg.ExtraArgs = manifest.ExtraArgs
+	for _, state := range manifest.States {
+		pg.SavePlans = pg.SavePlans || state.PlanFile != ""
+	}
+	pg.resuming = true
+
+	if head := gitHead(); manifest.GitCommit != "" && head != "" && head != manifest.GitCommit {
+		warningColor.Printf("⚠️  HEAD moved to %s since the interrupted run planned %s; the plans it finished are kept as they are\n", shortSHA(head), shortSHA(manifest.GitCommit))
+	}
+	infoColor.Printf("⏯️  Resuming %s\n", runDir)
+
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := pg.RunContext(ctx); err != nil {
+		exitOnRunError(err)
+	}
+}
+
+// checkResumable rejects the arguments a resumed run takes from its
+// manifest, and the flags that would plan it somewhere else.
+func checkResumable(cmd *cobra.Command, args []string) error {
+	if cmd.ArgsLenAtDash() >= 0 {
+		return fmt.Errorf("--resume plans with the arguments recorded in the run's manifest.json; drop the ones after --")
+	}
+	for _, flag := range []string{"output", "stdout", "watch", "hook"} {
+		if cmd.Flags().Changed(flag) {
+			return fmt.Errorf("--resume continues the run in its own output directory, so it can't be combined with --%s", flag)
+		}
+	}
+	return nil
+}
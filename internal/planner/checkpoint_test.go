@@ -0,0 +1,47 @@
+package planner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanCheckpointed(t *testing.T) {
+	fake := &fakeExecutor{stdout: stagingPlan}
+	pg := newTestGenerator(t, fake)
+	pg.pool = newWorkerPool(1, false, false)
+	p := pg.Config.Partitions[0]
+	state := &State{Path: "terragrunt_vpc/organizations/staging/us-east-1"}
+	if _, err := pg.planCheckpointed(context.Background(), p, state); err != nil {
+		t.Fatalf("planCheckpointed: %v", err)
+	}
+
+	// Resumed, the finished state isn't planned again
+	pg.resuming = true
+	fake.stdout = "changed"
+	output, err := pg.planCheckpointed(context.Background(), p, state)
+	if err != nil {
+		t.Fatalf("resumed planCheckpointed: %v", err)
+	}
+	if string(output) != stagingPlan || len(fake.commands) != 1 {
+		t.Errorf("resumed output = %q after %d commands, want the checkpoint after 1", output, len(fake.commands))
+	}
+	other := &State{Path: "terragrunt_vpc/organizations/staging/us-west-2"}
+	if output, _ := pg.planCheckpointed(context.Background(), p, other); string(output) != "changed" {
+		t.Errorf("unfinished state output = %q, want a fresh plan", output)
+	}
+
+	pg.checkpointPartition(p)
+	if pg.resumedPartition(p) {
+		t.Error("resumed a partition whose plans file is missing")
+	}
+	os.WriteFile(filepath.Join(pg.OutputDir, p.OutputFile), []byte(stagingPlan), 0644)
+	if !pg.resumedPartition(p) {
+		t.Error("a finished partition is planned again")
+	}
+	pg.markComplete()
+	if _, err := os.Stat(filepath.Join(pg.OutputDir, checkpointDir)); !os.IsNotExist(err) {
+		t.Errorf("checkpoints kept after the run completed: %v", err)
+	}
+}
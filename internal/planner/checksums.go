@@ -0,0 +1,144 @@
+package planner
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// signatureFile holds the HMAC of manifest.json when a signing key is set.
+const signatureFile = manifestFile + ".sig"
+
+// signingKeyEnv names the environment variable holding the shared secret
+// manifests are signed and verified with.
+const signingKeyEnv = "TFPRGEN_SIGNING_KEY"
+
+// sealManifest records the SHA-256 of every file in the output directory
+// in manifest.json, and signs the manifest if a signing key is set. It runs
+// once all outputs are written, so apply can prove the plans it applies
+// are the reviewed ones.
+func (pg *PlanGenerator) sealManifest() error {
+	manifest, err := readManifest(pg.OutputDir)
+	if err != nil {
+		return err
+	}
+	manifest.Checksums, err = checksumDir(pg.OutputDir)
+	if err != nil {
+		return fmt.Errorf("hashing outputs: %v", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if err := os.WriteFile(filepath.Join(pg.OutputDir, manifestFile), data, 0644); err != nil {
+		return err
+	}
+
+	key := os.Getenv(signingKeyEnv)
+	if key == "" {
+		return nil
+	}
+	if pg.Verbose {
+		fmt.Fprintln(console, "🔏 Signing manifest")
+	}
+	return os.WriteFile(filepath.Join(pg.OutputDir, signatureFile), []byte(signManifest(data, key)+"\n"), 0644)
+}
+
+// checksumDir hashes every file below dir except the manifest and its
+// signature, keyed by slash-separated relative path.
+func checksumDir(dir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// The --log-file log goes on past sealing
+		if rel == manifestFile || rel == signatureFile || rel == debugLogFile {
+			return nil
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		sums[rel] = sum
+		return nil
+	})
+	return sums, err
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func signManifest(data []byte, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyRun checks a run directory against its sealed manifest: every
+// recorded file must be present and unchanged, and if the run was signed
+// (or a signing key is set) the signature must match.
+func verifyRun(runDir string, manifest *RunManifest) error {
+	if len(manifest.Checksums) == 0 {
+		return fmt.Errorf("%s has no output checksums; it didn't complete or predates checksummed manifests", runDir)
+	}
+
+	var problems []string
+	for rel, want := range manifest.Checksums {
+		got, err := hashFile(filepath.Join(runDir, filepath.FromSlash(rel)))
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: %v", rel, err))
+		case got != want:
+			problems = append(problems, fmt.Sprintf("%s: checksum mismatch", rel))
+		}
+	}
+	sort.Strings(problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("%s was modified after it was generated:\n  %s", runDir, strings.Join(problems, "\n  "))
+	}
+
+	key := os.Getenv(signingKeyEnv)
+	signature, err := os.ReadFile(filepath.Join(runDir, signatureFile))
+	switch {
+	case err != nil && key == "":
+		return nil
+	case err != nil:
+		return fmt.Errorf("%s is not signed but %s is set", runDir, signingKeyEnv)
+	case key == "":
+		return fmt.Errorf("%s is signed; set %s to verify it", runDir, signingKeyEnv)
+	}
+	data, err := os.ReadFile(filepath.Join(runDir, manifestFile))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(strings.TrimSpace(string(signature))), []byte(signManifest(data, key))) {
+		return fmt.Errorf("%s: manifest signature does not match", runDir)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// cleanCandidate is a run directory clean may delete.
+type cleanCandidate struct {
+	Dir      string
+	Manifest *RunManifest
+}
+
+func newCleanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clean [dir]",
+		Short: "Delete old run directories",
+		Long: `Deletes stale run directories in dir (default: the current directory),
+keeping the newest --keep runs of each module. With --max-age, runs older
+than that are deleted too, even among the newest.
+
+Only directories named like the tool's output (the output_dir template of
+the config, plus reproduce's -reproduce-TIMESTAMP suffix) and holding a
+readable manifest.json are considered; anything else is left alone. An
+--archive .tar.gz next to a deleted run is deleted with it.
+
+Examples:
+  terraform-pr-generator clean --keep 5
+  terraform-pr-generator clean --keep 3 --max-age 720h --dry-run`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  runClean,
+	}
+
+	cmd.Flags().Int("keep", 5, "Newest runs to keep per module")
+	cmd.Flags().Duration("max-age", 0, "Also delete runs older than this, e.g. 720h (0 disables)")
+	cmd.Flags().Bool("dry-run", false, "Only list what would be deleted")
+	cmd.Flags().StringP("config", "c", "", "Path to a YAML config file (default: .tfprgen.yaml in the repo root)")
+	return cmd
+}
+
+func runClean(cmd *cobra.Command, args []string) {
+	root := "."
+	if len(args) > 0 {
+		root = args[0]
+	}
+	keep, _ := cmd.Flags().GetInt("keep")
+	maxAge, _ := cmd.Flags().GetDuration("max-age")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	configPath, _ := cmd.Flags().GetString("config")
+
+	if keep < 0 || maxAge < 0 {
+		errorColor.Println("❌ Error: --keep and --max-age must not be negative")
+		os.Exit(1)
+	}
+	if configPath == "" {
+		configPath = FindConfigFile(".")
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	stale, kept, err := staleRuns(root, cfg, keep, maxAge, time.Now())
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(stale) == 0 {
+		successColor.Printf("✅ Nothing to clean (%d run(s) kept)\n", kept)
+		return
+	}
+
+	for _, run := range stale {
+		age := runAge(time.Since(run.Manifest.StartedAt))
+		if dryRun {
+			fmt.Fprintf(console, "  would delete %s (%s, %s old)\n", run.Dir, run.Manifest.Module, age)
+			continue
+		}
+		if err := os.RemoveAll(run.Dir); err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Remove(filepath.Clean(run.Dir) + ".tar.gz")
+		fmt.Fprintf(console, "  🗑️  deleted %s (%s, %s old)\n", run.Dir, run.Manifest.Module, age)
+	}
+	if dryRun {
+		infoColor.Printf("🧹 Would delete %d run(s), keeping %d\n", len(stale), kept)
+	} else {
+		successColor.Printf("🧹 Deleted %d run(s), kept %d\n", len(stale), kept)
+	}
+}
+
+// staleRuns lists the run directories in root to delete, oldest first,
+// and how many runs are kept.
+func staleRuns(root string, cfg *Config, keep int, maxAge time.Duration, now time.Time) ([]*cleanCandidate, int, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %v", root, err)
+	}
+
+	byModule := make(map[string][]*cleanCandidate)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, manifestFile)); err != nil {
+			continue
+		}
+		manifest, err := readManifest(dir)
+		if err != nil || manifest.Module == "" || manifest.StartedAt.IsZero() {
+			warningColor.Printf("⚠️  Skipping %s: no usable manifest\n", dir)
+			continue
+		}
+		if !runDirRegex(cfg, manifest.Module).MatchString(entry.Name()) {
+			continue
+		}
+		byModule[manifest.Module] = append(byModule[manifest.Module], &cleanCandidate{Dir: dir, Manifest: manifest})
+	}
+
+	var stale []*cleanCandidate
+	kept := 0
+	for _, runs := range byModule {
+		sort.Slice(runs, func(i, j int) bool {
+			return runs[i].Manifest.StartedAt.After(runs[j].Manifest.StartedAt)
+		})
+		for i, run := range runs {
+			if i >= keep || (maxAge > 0 && now.Sub(run.Manifest.StartedAt) > maxAge) {
+				stale = append(stale, run)
+			} else {
+				kept++
+			}
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].Manifest.StartedAt.Before(stale[j].Manifest.StartedAt)
+	})
+	return stale, kept, nil
+}
+
+// runDirRegex matches the directory names the output_dir template yields
+// for module, with any timestamp, plus reproduce's suffixes.
+func runDirRegex(cfg *Config, module string) *regexp.Regexp {
+	const marker = "\x00TIMESTAMP\x00"
+	name, err := cfg.renderOutputDir(module, marker)
+	if err != nil {
+		return regexp.MustCompile(`$^`)
+	}
+	pattern := strings.ReplaceAll(regexp.QuoteMeta(filepath.Base(name)), regexp.QuoteMeta(marker), `\d{8}-\d{6}`)
+	return regexp.MustCompile(`^` + pattern + `(?:-reproduce-\d{8}-\d{6})*$`)
+}
+
+// runAge formats an age in days, or hours for recent runs.
+func runAge(d time.Duration) string {
+	if d < 48*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
@@ -0,0 +1,78 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommands lists the commands that can take text on stdin onto
+// the clipboard on this platform, in order of preference.
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		// clip.exe reads the console code page and mangles the emoji
+		return [][]string{
+			{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command",
+				"[Console]::InputEncoding = [Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())"},
+			{"clip.exe"},
+		}
+	}
+	var commands [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append(commands, []string{"wl-copy"})
+	}
+	return append(commands,
+		[]string{"xclip", "-selection", "clipboard"},
+		[]string{"xsel", "--clipboard", "--input"},
+		// WSL, where the Windows clipboard is the one that matters
+		[]string{"clip.exe"},
+	)
+}
+
+// clipboardCommand is the first of clipboardCommands installed, nil for
+// none.
+func clipboardCommand() []string {
+	for _, argv := range clipboardCommands() {
+		if _, err := exec.LookPath(argv[0]); err == nil {
+			return argv
+		}
+	}
+	return nil
+}
+
+// copyToClipboard places text onto the system clipboard (--copy).
+func copyToClipboard(text []byte) error {
+	argv := clipboardCommand()
+	if argv == nil {
+		var names []string
+		for _, command := range clipboardCommands() {
+			names = append(names, command[0])
+		}
+		return fmt.Errorf("no clipboard command found (tried %s)", strings.Join(names, ", "))
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = bytes.NewReader(text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v %s", argv[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// clipboardHint is the shell command line copying path to the clipboard,
+// for the summary's quick commands.
+func clipboardHint(path string) string {
+	argv := clipboardCommand()
+	switch {
+	case argv == nil:
+		return ""
+	case runtime.GOOS == "windows":
+		return fmt.Sprintf("Get-Content -Raw %s | Set-Clipboard", path)
+	}
+	return fmt.Sprintf("cat %s | %s", path, strings.Join(shellQuoteAll(argv), " "))
+}
@@ -0,0 +1,111 @@
+package planner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// indexedResourceRegex matches a resource header with a for_each/count index,
+// e.g. `  # aws_s3_bucket.this["logs"] will be created`.
+var indexedResourceRegex = regexp.MustCompile(`^(\s*)# (.+)\[("(?:[^"\\]|\\.)*"|\d+)\] ((?:will|must) be .*|has .*|is .*)$`)
+
+var resourceHeaderRegex = regexp.MustCompile(`^\s*# \S+ (?:(?:will|must) be|has|is) `)
+
+const keyPlaceholder = "<key>"
+
+// planBlock is one resource change of a plan body.
+type planBlock struct {
+	lines []string
+	// Set for indexed resources only.
+	indent, base, key, action string
+}
+
+// collapseForEach merges runs of near-identical for_each/count instances
+// (same resource, same action, same attribute delta apart from the key)
+// into one templated entry listing the keys. Groups smaller than threshold
+// are left alone.
+func collapseForEach(body string, threshold int) string {
+	if threshold < 2 {
+		return body
+	}
+
+	lines := strings.Split(body, "\n")
+	var preamble, trailer []string
+	var blocks []*planBlock
+	for i, line := range lines {
+		if isPlanSummary(line) {
+			trailer = lines[i:]
+			break
+		}
+		if resourceHeaderRegex.MatchString(line) {
+			block := &planBlock{lines: []string{line}}
+			if m := indexedResourceRegex.FindStringSubmatch(line); m != nil {
+				block.indent, block.base, block.key, block.action = m[1], m[2], m[3], m[4]
+			}
+			blocks = append(blocks, block)
+			continue
+		}
+		if len(blocks) == 0 {
+			preamble = append(preamble, line)
+		} else {
+			blocks[len(blocks)-1].lines = append(blocks[len(blocks)-1].lines, line)
+		}
+	}
+
+	// Group indexed blocks by resource, action and key-normalized body.
+	groups := make(map[string][]*planBlock)
+	for _, block := range blocks {
+		if block.key == "" {
+			continue
+		}
+		signature := block.base + "\x00" + block.action + "\x00" + block.normalizedBody()
+		groups[signature] = append(groups[signature], block)
+	}
+
+	out := append([]string{}, preamble...)
+	emitted := make(map[string]bool)
+	for _, block := range blocks {
+		if block.key == "" {
+			out = append(out, block.lines...)
+			continue
+		}
+		signature := block.base + "\x00" + block.action + "\x00" + block.normalizedBody()
+		group := groups[signature]
+		if len(group) < threshold {
+			out = append(out, block.lines...)
+			continue
+		}
+		if emitted[signature] {
+			continue
+		}
+		emitted[signature] = true
+
+		keys := make([]string, len(group))
+		for i, member := range group {
+			keys[i] = member.key
+		}
+		out = append(out,
+			fmt.Sprintf("%s# %s[%s] %s (collapsed %d for_each instances)", block.indent, block.base, keyPlaceholder, block.action, len(group)),
+			fmt.Sprintf("%s#   keys: %s", block.indent, strings.Join(keys, ", ")))
+		out = append(out, strings.Split(block.normalizedBody(), "\n")...)
+	}
+	out = append(out, trailer...)
+	return strings.Join(out, "\n")
+}
+
+// normalizedBody is the block without its header, with a for_each key
+// replaced so instances that differ only by key compare equal. count
+// indices and very short keys are only replaced as whole quoted strings to
+// avoid rewriting unrelated digits and words.
+func (b *planBlock) normalizedBody() string {
+	body := strings.Join(b.lines[1:], "\n")
+	if !strings.HasPrefix(b.key, `"`) {
+		return body
+	}
+	key := strings.Trim(b.key, `"`)
+	if len(key) < 3 {
+		return strings.ReplaceAll(body, b.key, `"`+keyPlaceholder+`"`)
+	}
+	return strings.ReplaceAll(body, key, keyPlaceholder)
+}
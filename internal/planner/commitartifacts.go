@@ -0,0 +1,122 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// defaultArtifactsDir is where --commit-artifacts puts each module's
+// report, below the repository root.
+const defaultArtifactsDir = ".pr-plans"
+
+// CommitArtifacts commits the report of every run to the branch, for
+// review processes that want plan evidence tracked in git.
+type CommitArtifacts struct {
+	// Enabled commits the reports on every run, like --commit-artifacts.
+	Enabled bool `yaml:"enabled"`
+	// Dir is the directory each module's reports go to a subdirectory of;
+	// .pr-plans by default.
+	Dir string `yaml:"dir"`
+	// JSON commits report.json along with pr-ready.md.
+	JSON bool `yaml:"json"`
+	// Remote is the git remote the commit is pushed to; origin by default.
+	Remote string `yaml:"remote"`
+	// Message is the commit message; "Add <module> plans for <commit>" by
+	// default. Add [skip ci] to keep the commit from planning again.
+	Message string `yaml:"message"`
+}
+
+func (c CommitArtifacts) validate() error {
+	if c.Dir == "" {
+		return nil
+	}
+	if filepath.IsAbs(c.Dir) || !filepath.IsLocal(c.Dir) {
+		return fmt.Errorf("dir %q must be a relative path inside the repository", c.Dir)
+	}
+	return nil
+}
+
+// commitArtifacts copies the run's reports to <dir>/<module> and commits
+// them to the current branch, then pushes it. Only those files are
+// committed, whatever else is staged.
+func (pg *PlanGenerator) commitArtifacts(results []*PartitionResult, reports map[string]string) error {
+	cfg := pg.Config.CommitArtifacts
+	if cfg.Dir == "" {
+		cfg.Dir = defaultArtifactsDir
+	}
+	if cfg.Remote == "" {
+		cfg.Remote = "origin"
+	}
+	branch, err := currentBranch()
+	if err != nil {
+		return err
+	}
+	commit := gitHead()
+
+	files := map[string]string{"pr-ready.md": reports["markdown"]}
+	if cfg.JSON {
+		files["report.json"] = reports["json"]
+		if files["report.json"] == "" {
+			files["report.json"] = filepath.Join(pg.OutputDir, formatFiles["json"])
+			if err := pg.writeJSON(files["report.json"], results); err != nil {
+				return fmt.Errorf("writing report.json: %v", err)
+			}
+		}
+	}
+	dir := filepath.Join(cfg.Dir, pg.ModuleName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var paths []string
+	for name, src := range files {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+		paths = append(paths, path)
+	}
+
+	if err := runGit(append([]string{"add", "--"}, paths...)...); err != nil {
+		return err
+	}
+	if diff := exec.Command("git", append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...); diff.Run() == nil {
+		fmt.Fprintf(console, "📎 %s is unchanged; nothing to commit\n", dir)
+		return nil
+	}
+	message := cfg.Message
+	if message == "" {
+		message = fmt.Sprintf("Add %s plans for %.12s", pg.ModuleName, commit)
+	}
+	if err := runGit(append([]string{"commit", "--quiet", "-m", message, "--"}, paths...)...); err != nil {
+		return err
+	}
+	infoColor.Printf("📎 Committed %s; pushing %s to %s\n", dir, branch, cfg.Remote)
+	return runGit("push", cfg.Remote, branch)
+}
+
+// currentBranch is the branch checked out, which --commit-artifacts
+// commits to.
+func currentBranch() (string, error) {
+	out, err := commandOutput(exec.Command("git", "symbolic-ref", "--quiet", "--short", "HEAD"))
+	if err != nil {
+		return "", fmt.Errorf("HEAD is detached (pull_request workflows check out a merge commit); check out the pull request's branch to commit to")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// runGit runs a git command with its output on the console.
+func runGit(args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Stdout, cmd.Stderr = console, console
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s failed: %v", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,395 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Statuses of a region plan or resource between two compared runs.
+const (
+	compareAdded     = "added"
+	compareRemoved   = "removed"
+	compareChanged   = "changed"
+	compareUnchanged = "unchanged"
+)
+
+// comparedRun identifies one side of a comparison.
+type comparedRun struct {
+	Dir       string    `json:"dir"`
+	Module    string    `json:"module"`
+	StartedAt time.Time `json:"started_at"`
+	GitCommit string    `json:"git_commit,omitempty"`
+}
+
+// regionComparison is how one region plan differs between the runs.
+type regionComparison struct {
+	Partition string                `json:"partition"`
+	Env       string                `json:"env"`
+	Region    string                `json:"region"`
+	Status    string                `json:"status"`
+	Before    *PlanCounts           `json:"before,omitempty"`
+	After     *PlanCounts           `json:"after,omitempty"`
+	Resources []*resourceComparison `json:"resources,omitempty"`
+}
+
+// resourceComparison is a resource change that differs between the runs.
+type resourceComparison struct {
+	Address string   `json:"address"`
+	Status  string   `json:"status"`
+	Before  string   `json:"before,omitempty"` // action, e.g. "created"
+	After   string   `json:"after,omitempty"`
+	Diff    []string `json:"diff,omitempty"` // line diff of the resource's plan
+}
+
+type runComparison struct {
+	A       *comparedRun        `json:"a"`
+	B       *comparedRun        `json:"b"`
+	Regions []*regionComparison `json:"regions"`
+}
+
+func newCompareCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compare <run_dir_a> <run_dir_b>",
+		Short: "Show which region plans changed between two runs",
+		Long: `Compares two run directories, e.g. before and after a review fix commit,
+and reports which region plans changed, appeared or disappeared, down to the
+resource changes that differ, so reviewers only re-read what actually
+changed. Each run is parsed with the config recorded in its manifest.json.
+
+The comparison is written to stdout as markdown, or JSON with --format json.
+
+Examples:
+  terraform-pr-generator compare pr-plans-20250604-143022 pr-plans-20250605-091500
+  terraform-pr-generator compare old new --format json | jq '.regions[] | select(.status != "unchanged")'`,
+		Args: cobra.ExactArgs(2),
+		Run:  runCompare,
+	}
+
+	cmd.Flags().String("format", "markdown", "Output format: markdown or json")
+	return cmd
+}
+
+func runCompare(cmd *cobra.Command, args []string) {
+	format, _ := cmd.Flags().GetString("format")
+	if format != "markdown" && format != "json" {
+		errorColor.Printf("❌ Error: invalid format %q (supported: markdown, json)\n", format)
+		os.Exit(1)
+	}
+
+	comparison, err := compareRuns(args[0], args[1])
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if comparison.A.Module != comparison.B.Module {
+		warningColor.Printf("⚠️  Comparing runs of different modules (%s and %s)\n", comparison.A.Module, comparison.B.Module)
+	}
+
+	if format == "json" {
+		data, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(append(data, '\n'))
+		return
+	}
+	writeComparison(os.Stdout, comparison)
+}
+
+// compareRuns parses both runs and pairs up their region plans.
+func compareRuns(dirA, dirB string) (*runComparison, error) {
+	runA, resultsA, err := loadRunResults(dirA)
+	if err != nil {
+		return nil, err
+	}
+	runB, resultsB, err := loadRunResults(dirB)
+	if err != nil {
+		return nil, err
+	}
+
+	return compareResults(runA, resultsA, runB, resultsB), nil
+}
+
+// compareResults pairs up the region plans of two runs, in report order.
+func compareResults(runA *comparedRun, resultsA []*PartitionResult, runB *comparedRun, resultsB []*PartitionResult) *runComparison {
+	plansA, plansB := regionPlans(resultsA), regionPlans(resultsB)
+	comparison := &runComparison{A: runA, B: runB, Regions: []*regionComparison{}}
+	for _, key := range mergeKeys(plansA.keys, plansB.keys) {
+		region := &regionComparison{Partition: key.Partition, Env: key.Env, Region: key.Region}
+		before, inA := plansA.plans[key]
+		after, inB := plansB.plans[key]
+		if inA {
+			if counts, ok := ParsePlanCounts(before); ok {
+				region.Before = &counts
+			}
+		}
+		if inB {
+			if counts, ok := ParsePlanCounts(after); ok {
+				region.After = &counts
+			}
+		}
+		switch {
+		case !inA:
+			region.Status = compareAdded
+		case !inB:
+			region.Status = compareRemoved
+		case normalizePlan(before) == normalizePlan(after):
+			region.Status = compareUnchanged
+		default:
+			region.Status = compareChanged
+			region.Resources = compareResources(before, after)
+		}
+		comparison.Regions = append(comparison.Regions, region)
+	}
+	return comparison
+}
+
+// loadRunResults parses a run directory's plans files with the config the
+// run was made with.
+func loadRunResults(dir string) (*comparedRun, []*PartitionResult, error) {
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := manifest.config()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", dir, err)
+	}
+	pg := &PlanGenerator{ModuleName: manifest.Module, OutputDir: dir, Config: cfg}
+	results, err := pg.collectResults()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", dir, err)
+	}
+	run := &comparedRun{Dir: filepath.Clean(dir), Module: manifest.Module, StartedAt: manifest.StartedAt, GitCommit: manifest.GitCommit}
+	return run, results, nil
+}
+
+// regionKey identifies a region plan of a run.
+type regionKey struct {
+	Partition, Env, Region string
+}
+
+func (k regionKey) String() string {
+	return k.Partition + "/" + k.Env + "/" + k.Region
+}
+
+// orderedPlans are region plans, in report order.
+type orderedPlans struct {
+	keys  []regionKey
+	plans map[regionKey]string
+}
+
+func regionPlans(results []*PartitionResult) orderedPlans {
+	plans := orderedPlans{plans: make(map[regionKey]string)}
+	for _, result := range results {
+		for _, env := range result.Environments {
+			for _, region := range env.Regions {
+				key := regionKey{result.Partition.Name, env.Name, region}
+				plans.keys = append(plans.keys, key)
+				plans.plans[key] = env.Plans[region]
+			}
+		}
+	}
+	return plans
+}
+
+// mergeKeys lists a's keys followed by those only in b.
+func mergeKeys[K comparable](a, b []K) []K {
+	keys := append([]K{}, a...)
+	for _, key := range b {
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// normalizePlan makes the plan stable (see stablePlan) and drops blank
+// lines, which differ between runs without meaning anything.
+func normalizePlan(body string) string {
+	var lines []string
+	for _, line := range strings.Split(stablePlan(body), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// resourceChange is one resource's entry in a plan body.
+type resourceChange struct {
+	action string
+	lines  []string
+}
+
+// planResources splits a plan body into its resource changes, by address.
+func planResources(body string) ([]string, map[string]*resourceChange) {
+	var addresses []string
+	changes := make(map[string]*resourceChange)
+	var current *resourceChange
+	for _, line := range strings.Split(normalizePlan(body), "\n") {
+		if isPlanSummary(line) {
+			break
+		}
+		if m := resourceChangeRegex.FindStringSubmatch(line); m != nil {
+			current = &resourceChange{action: m[2]}
+			if _, ok := changes[m[1]]; !ok {
+				addresses = append(addresses, m[1])
+			}
+			changes[m[1]] = current
+		}
+		if current != nil {
+			current.lines = append(current.lines, line)
+		}
+	}
+	return addresses, changes
+}
+
+// compareResources lists the resource changes that differ between two
+// plans of a region.
+func compareResources(before, after string) []*resourceComparison {
+	addressesA, changesA := planResources(before)
+	addressesB, changesB := planResources(after)
+
+	var resources []*resourceComparison
+	for _, address := range mergeKeys(addressesA, addressesB) {
+		a, b := changesA[address], changesB[address]
+		r := &resourceComparison{Address: address}
+		switch {
+		case a == nil:
+			r.Status, r.After, r.Diff = compareAdded, b.action, diffLines(nil, b.lines)
+		case b == nil:
+			r.Status, r.Before, r.Diff = compareRemoved, a.action, diffLines(a.lines, nil)
+		case strings.Join(a.lines, "\n") != strings.Join(b.lines, "\n"):
+			r.Status, r.Before, r.After, r.Diff = compareChanged, a.action, b.action, diffLines(a.lines, b.lines)
+		default:
+			continue
+		}
+		resources = append(resources, r)
+	}
+	return resources
+}
+
+// diffLines is a line diff of a and b in unified diff notation (without
+// hunk headers), based on their longest common subsequence.
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			diff = append(diff, " "+a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			diff = append(diff, "+"+b[j])
+			j++
+		default:
+			diff = append(diff, "-"+a[i])
+			i++
+		}
+	}
+	return diff
+}
+
+// writeComparison renders a comparison as markdown.
+func writeComparison(output *os.File, c *runComparison) {
+	counts := make(map[string]int)
+	for _, region := range c.Regions {
+		counts[region.Status]++
+	}
+
+	output.WriteString("## Plan changes between runs\n\n")
+	output.WriteString(fmt.Sprintf("- Before: `%s` (%s)\n", c.A.Dir, describeComparedRun(c.A)))
+	output.WriteString(fmt.Sprintf("- After: `%s` (%s)\n\n", c.B.Dir, describeComparedRun(c.B)))
+	if counts[compareUnchanged] == len(c.Regions) {
+		output.WriteString("No region plan changed.\n")
+		return
+	}
+	output.WriteString(fmt.Sprintf("**%d changed, %d new, %d gone, %d unchanged** region plans.\n\n",
+		counts[compareChanged], counts[compareAdded], counts[compareRemoved], counts[compareUnchanged]))
+
+	var unchanged []string
+	for _, region := range c.Regions {
+		name := fmt.Sprintf("%s / %s / %s", region.Partition, region.Env, region.Region)
+		if region.Status == compareUnchanged {
+			unchanged = append(unchanged, name)
+			continue
+		}
+		output.WriteString(fmt.Sprintf("### %s (%s)\n\n", name, region.Status))
+		output.WriteString(fmt.Sprintf("%s → %s\n\n", describeCounts(region.Before), describeCounts(region.After)))
+		if region.Status != compareChanged {
+			continue
+		}
+		if len(region.Resources) == 0 {
+			output.WriteString("Only output outside resource changes differs.\n\n")
+			continue
+		}
+		for _, r := range region.Resources {
+			switch r.Status {
+			case compareAdded:
+				output.WriteString(fmt.Sprintf("- `%s`: now %s\n", r.Address, r.After))
+			case compareRemoved:
+				output.WriteString(fmt.Sprintf("- `%s`: no longer %s\n", r.Address, r.Before))
+			default:
+				if r.Before != r.After {
+					output.WriteString(fmt.Sprintf("- `%s`: %s, was %s\n", r.Address, r.After, r.Before))
+				} else {
+					output.WriteString(fmt.Sprintf("- `%s`: %s differently\n", r.Address, r.After))
+				}
+			}
+		}
+		output.WriteString("\n<details>\n<summary>Diff</summary>\n\n```diff\n")
+		for _, r := range region.Resources {
+			output.WriteString(strings.Join(r.Diff, "\n") + "\n")
+		}
+		output.WriteString("```\n\n</details>\n\n")
+	}
+
+	if len(unchanged) > 0 {
+		output.WriteString("### Unchanged\n\n")
+		for _, name := range unchanged {
+			output.WriteString(fmt.Sprintf("- %s\n", name))
+		}
+		output.WriteString("\n")
+	}
+}
+
+func describeComparedRun(run *comparedRun) string {
+	description := fmt.Sprintf("%s, %s", run.Module, run.StartedAt.Local().Format("2006-01-02 15:04"))
+	if run.GitCommit != "" {
+		description += ", " + shortSHA(run.GitCommit)
+	}
+	return description
+}
+
+func describeCounts(counts *PlanCounts) string {
+	if counts == nil {
+		return "no plan summary"
+	}
+	return fmt.Sprintf("`+%d ~%d -%d`", counts.Add, counts.Change, counts.Destroy)
+}
@@ -0,0 +1,36 @@
+package planner
+
+import "testing"
+
+func TestCompareResults(t *testing.T) {
+	p := commercialPartition(t)
+	added := "Plan: 1 to add, 0 to change, 0 to destroy."
+	before := testResults(p,
+		testEnvironment("team/blue", map[string]string{"us-east-1": added, "us-west-2": added}),
+		testEnvironment("staging", map[string]string{"us-east-1": added}),
+	)
+	after := testResults(p,
+		testEnvironment("team/blue", map[string]string{"us-east-1": added, "us-west-2": "Plan: 2 to add, 0 to change, 0 to destroy."}),
+		testEnvironment("production", map[string]string{"us-east-1": added}),
+	)
+
+	comparison := compareResults(&comparedRun{}, before, &comparedRun{}, after)
+	want := []regionComparison{
+		{Partition: "commercial", Env: "team/blue", Region: "us-east-1", Status: compareUnchanged},
+		{Partition: "commercial", Env: "team/blue", Region: "us-west-2", Status: compareChanged},
+		{Partition: "commercial", Env: "staging", Region: "us-east-1", Status: compareRemoved},
+		{Partition: "commercial", Env: "production", Region: "us-east-1", Status: compareAdded},
+	}
+	if len(comparison.Regions) != len(want) {
+		t.Fatalf("got %d regions, want %d: %+v", len(comparison.Regions), len(want), comparison.Regions)
+	}
+	for i, got := range comparison.Regions {
+		if got.Partition != want[i].Partition || got.Env != want[i].Env || got.Region != want[i].Region || got.Status != want[i].Status {
+			t.Errorf("region %d = %s/%s/%s %s, want %s/%s/%s %s", i, got.Partition, got.Env, got.Region, got.Status,
+				want[i].Partition, want[i].Env, want[i].Region, want[i].Status)
+		}
+	}
+	if changed := comparison.Regions[1]; changed.Before == nil || changed.After == nil || changed.After.Add != 2 {
+		t.Errorf("changed region counts = %+v -> %+v", changed.Before, changed.After)
+	}
+}
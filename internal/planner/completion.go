@@ -0,0 +1,156 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Print a shell completion script",
+		Long: `Prints the completion script of a shell on stdout. Besides commands and
+flags it completes module names, read from the terragrunt_<module>
+directories of the repository the command line is typed in (or of --chdir),
+with their descriptions where the shell shows them.
+
+Examples:
+  # bash, for the current shell or for good
+  source <(terraform-pr-generator completion bash)
+  terraform-pr-generator completion bash > /etc/bash_completion.d/terraform-pr-generator
+
+  # zsh
+  terraform-pr-generator completion zsh > "${fpath[1]}/_terraform-pr-generator"
+
+  # fish
+  terraform-pr-generator completion fish > ~/.config/fish/completions/terraform-pr-generator.fish`,
+		Args:                  cobra.ExactArgs(1),
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		DisableFlagsInUseLine: true,
+		Run:                   runCompletion,
+	}
+}
+
+func runCompletion(cmd *cobra.Command, args []string) {
+	root := cmd.Root()
+	var err error
+	switch args[0] {
+	case "bash":
+		err = root.GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		err = root.GenZshCompletion(os.Stdout)
+	case "fish":
+		err = root.GenFishCompletion(os.Stdout, true)
+	case "powershell":
+		err = root.GenPowerShellCompletionWithDesc(os.Stdout)
+	default:
+		err = fmt.Errorf("unsupported shell %q (supported: bash, zsh, fish, powershell)", args[0])
+	}
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// completeModules completes module name arguments, at most max of them (0
+// for any number), from the repository the command would run in: --chdir,
+// else the one the current directory is in.
+func completeModules(max int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if max > 0 && len(args) >= max {
+			// Past the module, e.g. at arguments for the plan commands
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		root := completionRoot(cmd)
+		modules, err := findModules(root, commandModuleRoots(cmd, root))
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var completions []string
+		for i, name := range moduleNames(modules) {
+			if !strings.HasPrefix(name, toComplete) || contains(args, name) {
+				continue
+			}
+			if description := moduleDescription(filepath.Join(root, modules[i].Dir())); description != "" {
+				name += "\t" + description
+			}
+			completions = append(completions, name)
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completionRoot is the repository root a command line being completed
+// would run from. Completion doesn't run the commands' pre-run, so it
+// follows --chdir and walks up like changeDir would.
+func completionRoot(cmd *cobra.Command) string {
+	dir, _ := cmd.Flags().GetString("chdir")
+	if dir == "" {
+		dir = "."
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	if root := findRepoRoot(abs); root != "" {
+		return root
+	}
+	return abs
+}
+
+// registerPlanFlagCompletions completes the values of the plan flags that
+// take a fixed set of them, or files of some kind.
+func registerPlanFlagCompletions(cmd *cobra.Command) {
+	fixed := map[string][]string{
+		"mode":             {modeFull, modeTargeted, modeAuto},
+		"runner":           {"kitman", "terragrunt", "terraform"},
+		"security-scanner": {"checkov", "tfsec"},
+	}
+	for flag, values := range fixed {
+		cmd.RegisterFlagCompletionFunc(flag, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp))
+	}
+	cmd.RegisterFlagCompletionFunc("format", completeFormats)
+	cmd.RegisterFlagCompletionFunc("config", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return []string{"yaml", "yml"}, cobra.ShellCompDirectiveFilterFileExt
+	})
+	cmd.RegisterFlagCompletionFunc("var-file", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return []string{"tfvars", "json"}, cobra.ShellCompDirectiveFilterFileExt
+	})
+	for _, flag := range []string{"output", "record", "replay", "policy-dir"} {
+		cmd.RegisterFlagCompletionFunc(flag, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		})
+	}
+}
+
+// completeFormats completes --format with the built-in formats and the
+// formatters of the config in use. The flag takes a comma-separated list,
+// so formats already listed are kept in front.
+func completeFormats(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	formats := []string{"junit", "json", "csv"}
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = FindConfigFile(completionRoot(cmd))
+	}
+	if cfg, err := LoadConfig(configPath); err == nil {
+		for _, f := range cfg.Formatters {
+			formats = append(formats, f.Name)
+		}
+	}
+
+	listed, prefix := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		listed, prefix = toComplete[:i+1], toComplete[i+1:]
+	}
+	var completions []string
+	for _, format := range formats {
+		if strings.HasPrefix(format, prefix) && !contains(strings.Split(listed, ","), format) {
+			completions = append(completions, listed+format)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
@@ -0,0 +1,579 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are looked up in the repo root when --config isn't given.
+var configFileNames = []string{".tfprgen.yaml", ".tfprgen.yml"}
+
+// Config holds the settings that used to be hard-coded in the generator,
+// plus team-wide defaults for the command-line flags.
+type Config struct {
+	// OutputDir is a text/template for the output directory name with
+	// {{.Module}} and {{.Timestamp}} available.
+	OutputDir string `yaml:"output_dir"`
+	Parallel  string `yaml:"parallel"`
+	Targeted  bool   `yaml:"targeted"`
+	Mode      string `yaml:"mode"` // full, targeted or auto; wins over targeted
+	Verbose   bool   `yaml:"verbose"`
+	// ModuleRoots are the directories holding terragrunt_<module>
+	// directories, relative to the repository root, for monorepos with
+	// several; just the repository root when empty.
+	ModuleRoots []string `yaml:"module_roots"`
+	// EnvironmentOrder lists globs of environment names the report shows
+	// first, in that order; the others follow alphabetically.
+	EnvironmentOrder []string `yaml:"environment_order"`
+	// CollapseForEach is the minimum number of identical for_each instances
+	// merged into one markdown entry; 0 disables collapsing.
+	CollapseForEach int `yaml:"collapse_for_each"`
+	// MergeIdentical shows environments with identical plans in every region
+	// once, under one heading listing them all.
+	MergeIdentical bool `yaml:"merge_identical"`
+	// ApplyOrder appends a suggested apply order checklist to the report.
+	ApplyOrder bool `yaml:"apply_order"`
+	// ApprovalChecklist appends a checkbox per environment to the report,
+	// for reviewers to approve each environment's plan.
+	ApprovalChecklist bool `yaml:"approval_checklist"`
+	// Graph draws the targeted states and their dependencies as a Mermaid
+	// graph in the report.
+	Graph bool `yaml:"graph"`
+	// Normalize strips timestamps, sorts maps and collapses whitespace in
+	// plan bodies, so consecutive runs show only real changes.
+	Normalize bool `yaml:"normalize"`
+	// GitHubComment streams progress and the final report into a pull
+	// request comment.
+	GitHubComment bool `yaml:"github_comment"`
+	// UpdatePRDescription puts the final report between marker comments in
+	// the pull request's description.
+	UpdatePRDescription bool `yaml:"update_pr_description"`
+	// ReleaseNotes embeds the release notes of module versions bumped on
+	// the branch in the report.
+	ReleaseNotes bool `yaml:"release_notes"`
+	// Upload is an s3://, gs://, az:// or artifactory:// location run
+	// directories are copied to.
+	Upload string `yaml:"upload"`
+	// Archive packs the output directory into a .tar.gz next to it.
+	Archive bool `yaml:"archive"`
+	// PlainReport renders the report without emoji or HTML.
+	PlainReport bool `yaml:"plain_report"`
+	// MaxSectionBytes is the largest region plan embedded in the report;
+	// larger ones are linked via Upload or a gist. 0 embeds everything.
+	MaxSectionBytes int `yaml:"max_section_bytes"`
+	// MaxOutputBytes is the size budget of pr-ready.md; the largest plans
+	// are truncated until it fits. 0 is no limit.
+	MaxOutputBytes int `yaml:"max_output_bytes"`
+	// IncludeConsumers adds states reading shared files changed on the
+	// branch to targeted runs.
+	IncludeConsumers bool `yaml:"include_consumers"`
+	// Init initializes targeted states with a shared provider cache before
+	// planning.
+	Init bool `yaml:"init"`
+	// AutoInit initializes targeted states whose plan failed for lack of
+	// init, then plans them again.
+	AutoInit bool `yaml:"auto_init"`
+	// Precheck runs fmt and validate checks on the module before planning.
+	Precheck bool `yaml:"precheck"`
+	// LogFile writes a debug log of every run to debug.log in its output
+	// directory.
+	LogFile bool `yaml:"log_file"`
+	// Retries is how many times a failed targeted plan is run again.
+	Retries int `yaml:"retries"`
+	// KeepGoing reports failed plans rather than failing the run on the
+	// first.
+	KeepGoing bool `yaml:"keep_going"`
+	// RemovePartial removes the output directory of a failed run rather
+	// than leaving it marked INCOMPLETE.
+	RemovePartial bool `yaml:"remove_partial"`
+	// Masking rules mask sensitive text, like internal hostnames or license
+	// keys, in the plans' output before it's written anywhere.
+	Masking []*MaskRule `yaml:"masking"`
+	// Incremental reuses the cached plans of targeted states whose inputs
+	// haven't changed.
+	Incremental bool `yaml:"incremental"`
+	// History records every run in ~/.tfprgen/history.db.
+	History bool `yaml:"history"`
+	// CheckCredentials checks the AWS credentials of the planned
+	// partitions before any plan starts.
+	CheckCredentials bool `yaml:"check_credentials"`
+	// PolicyDir holds Rego policies conftest evaluates against every
+	// targeted state's plan JSON.
+	PolicyDir string `yaml:"policy_dir"`
+	// Cost estimates the monthly cost change of saved plans with
+	// Infracost, when it's installed.
+	Cost bool `yaml:"cost"`
+	// Lint runs tflint on the configuration of the planned states.
+	Lint bool `yaml:"lint"`
+	// WarningsAsErrors fails the run if parsing produced any warnings.
+	WarningsAsErrors bool               `yaml:"warnings_as_errors"`
+	AutoMode         AutoModeConfig     `yaml:"auto_mode"`
+	EnvironmentTiers []*EnvironmentTier `yaml:"environment_tiers"`
+	Runner           RunnerConfig       `yaml:"runner"`
+	Hooks            Hooks              `yaml:"hooks"`
+	Formatters       []*Formatter       `yaml:"formatters"`
+	Security         SecurityConfig     `yaml:"security"`
+	TagPolicy        TagPolicy          `yaml:"tag_policy"`
+	Drift            DriftConfig        `yaml:"drift"`
+	PrePush          PrePushConfig      `yaml:"pre_push"`
+	Tracing          TracingConfig      `yaml:"tracing"`
+	Metrics          MetricsConfig      `yaml:"metrics"`
+	Audit            AuditConfig        `yaml:"audit"`
+	CommitStatus     CommitStatusConfig `yaml:"commit_status"`
+	PR               PRConfig           `yaml:"pr"`
+	CommitArtifacts  CommitArtifacts    `yaml:"commit_artifacts"`
+	TerraformCloud   TFCConfig          `yaml:"terraform_cloud"`
+	Spacelift        SpaceliftConfig    `yaml:"spacelift"`
+	Env0             Env0Config         `yaml:"env0"`
+	Jira             JiraConfig         `yaml:"jira"`
+	Email            EmailConfig        `yaml:"email"`
+	Partitions       []*Partition       `yaml:"partitions"`
+	AWSCredentials   []*AWSCredentials  `yaml:"aws_credentials"`
+
+	// Path is the file the config was loaded from, empty for the defaults.
+	Path string `yaml:"-"`
+	// Contents is the raw file, recorded in run manifests for reproduce.
+	Contents string `yaml:"-"`
+}
+
+// Partition describes a group of accounts (e.g. commercial AWS, GovCloud)
+// that is planned as a unit and written to its own plans file.
+type Partition struct {
+	Name          string   `yaml:"name"`
+	Label         string   `yaml:"label"`
+	Icon          string   `yaml:"icon"`
+	Organizations []string `yaml:"organizations"`
+	Regions       []string `yaml:"regions"`
+	RunnerArgs    []string `yaml:"runner_args"`  // extra arguments for every plan command
+	ExcludeDirs   []string `yaml:"exclude_dirs"` // globs skipped by terragrunt run-all
+	OutputFile    string   `yaml:"output_file"`
+	PathPattern   string   `yaml:"path_pattern"`   // assigns targeted states to this partition
+	EnvPattern    string   `yaml:"env_pattern"`    // (?P<env>...) or the first capture group
+	RegionPattern string   `yaml:"region_pattern"` // (?P<region>...) or the first capture group
+
+	// PathLayout is a shorthand for the two patterns above, e.g.
+	// "live/{env}/{region}". Other {placeholders} match any one segment.
+	PathLayout string `yaml:"path_layout"`
+
+	// EnvironmentNames renames captured environments, e.g. to map account
+	// IDs to readable names in account-ID-based layouts.
+	EnvironmentNames map[string]string `yaml:"environment_names"`
+
+	// Parallel gives the partition's targeted plans their own worker pool
+	// of this size (or "auto") instead of the shared --parallelism one,
+	// e.g. to go easy on harder rate-limited credentials.
+	Parallel string `yaml:"parallel"`
+
+	pathRegex   *regexp.Regexp
+	envRegex    *regexp.Regexp
+	regionRegex *regexp.Regexp
+}
+
+// DefaultConfig returns the commercial + GovCloud layout of the elon repos.
+func DefaultConfig() *Config {
+	return &Config{
+		OutputDir: "pr-plans-{{.Timestamp}}",
+		Parallel:  "1",
+		Runner:    DefaultRunner(),
+		AutoMode:  DefaultAutoMode(),
+		PrePush:   PrePushConfig{Select: "env=staging"},
+
+		MaxSectionBytes:  30000,
+		History:          true,
+		CheckCredentials: true,
+		Cost:             true,
+
+		EnvironmentTiers: DefaultTiers(),
+		Partitions: []*Partition{
+			{
+				Name:          "commercial",
+				Label:         "Commercial",
+				Icon:          "🏢",
+				OutputFile:    "commercial-plans.txt",
+				ExcludeDirs:   []string{"**/govcloud-*/**"},
+				EnvPattern:    `/organizations/([^/]+)/`,
+				RegionPattern: `/([a-z]{2}-[a-z]+-[0-9])/`,
+			},
+			{
+				Name:          "govcloud",
+				Label:         "GovCloud",
+				Icon:          "🏛️",
+				Organizations: []string{"govcloud-staging", "govcloud-production"},
+				Regions:       []string{"us-gov-west-1"},
+				OutputFile:    "govcloud-plans.txt",
+				PathPattern:   `govcloud`,
+				EnvPattern:    `(govcloud-[^/]+)`,
+				RegionPattern: `(us-gov-[a-z]+-[0-9])`,
+			},
+		},
+	}
+}
+
+// LoadConfig reads the config file at path, or returns the defaults when
+// path is empty. Keys missing from the file keep their default values.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		return ParseConfig(nil, "")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+	return ParseConfig(data, path)
+}
+
+// ParseConfig parses config file contents on top of the defaults. path is
+// only used for error messages and Config.Path.
+func ParseConfig(data []byte, path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if len(data) > 0 {
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+		}
+		cfg.Path = path
+		cfg.Contents = string(data)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SetRunner replaces the configured runner with a built-in preset.
+func (c *Config) SetRunner(name string) error {
+	runner, err := RunnerPreset(name)
+	if err != nil {
+		return err
+	}
+	if err := runner.compile(); err != nil {
+		return err
+	}
+	c.Runner = runner
+	return nil
+}
+
+// FindConfigFile walks up from dir to the repo root (the first directory
+// containing .git) looking for a .tfprgen.yaml. It returns "" if none exists.
+func FindConfigFile(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, name := range configFileNames {
+			candidate := filepath.Join(dir, name)
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// moduleRoots are the directories modules are looked up in, in order.
+func (c *Config) moduleRoots() []string {
+	if len(c.ModuleRoots) == 0 {
+		return []string{"."}
+	}
+	roots := make([]string, len(c.ModuleRoots))
+	for i, root := range c.ModuleRoots {
+		roots[i] = filepath.Clean(root)
+	}
+	return roots
+}
+
+// OutputDirName renders the output_dir template for a run.
+func (c *Config) OutputDirName(moduleName string, now time.Time) (string, error) {
+	return c.renderOutputDir(moduleName, now.Format("20060102-150405"))
+}
+
+func (c *Config) renderOutputDir(moduleName, timestamp string) (string, error) {
+	tmpl, err := template.New("output_dir").Parse(c.OutputDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid output_dir template: %v", err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, struct {
+		Module    string
+		Timestamp string
+	}{moduleName, timestamp})
+	if err != nil {
+		return "", fmt.Errorf("invalid output_dir template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Partitions) == 0 {
+		return fmt.Errorf("config must define at least one partition")
+	}
+	if _, err := c.OutputDirName("module", time.Now()); err != nil {
+		return err
+	}
+	if _, _, err := parseParallel(c.Parallel); err != nil {
+		return err
+	}
+	if c.MaxSectionBytes < 0 {
+		return fmt.Errorf("max_section_bytes must not be negative")
+	}
+	if c.MaxOutputBytes < 0 {
+		return fmt.Errorf("max_output_bytes must not be negative")
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	if err := c.Runner.compile(); err != nil {
+		return err
+	}
+	if err := c.Hooks.validate(); err != nil {
+		return err
+	}
+	if err := c.validateFormatters(); err != nil {
+		return err
+	}
+	if err := validateMaskRules(c.Masking); err != nil {
+		return err
+	}
+	if err := c.Security.validate(); err != nil {
+		return fmt.Errorf("security: %v", err)
+	}
+	if err := c.TagPolicy.validate(); err != nil {
+		return fmt.Errorf("tag_policy: %v", err)
+	}
+	if err := c.Tracing.validate(); err != nil {
+		return fmt.Errorf("tracing: %v", err)
+	}
+	if err := c.Metrics.validate(); err != nil {
+		return fmt.Errorf("metrics: %v", err)
+	}
+	if err := c.Audit.validate(); err != nil {
+		return fmt.Errorf("audit: %v", err)
+	}
+	if err := c.CommitStatus.validate(); err != nil {
+		return fmt.Errorf("commit_status: %v", err)
+	}
+	if err := c.PR.validate(); err != nil {
+		return fmt.Errorf("pr: %v", err)
+	}
+	if err := c.CommitArtifacts.validate(); err != nil {
+		return fmt.Errorf("commit_artifacts: %v", err)
+	}
+	if err := c.TerraformCloud.validate(); err != nil {
+		return fmt.Errorf("terraform_cloud: %v", err)
+	}
+	if err := c.Spacelift.validate(); err != nil {
+		return fmt.Errorf("spacelift: %v", err)
+	}
+	if err := c.Env0.validate(); err != nil {
+		return fmt.Errorf("env0: %v", err)
+	}
+	if err := c.Jira.validate(); err != nil {
+		return fmt.Errorf("jira: %v", err)
+	}
+	if err := c.Email.validate(); err != nil {
+		return fmt.Errorf("email: %v", err)
+	}
+	for _, pattern := range c.EnvironmentOrder {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("environment_order: bad pattern %q: %v", pattern, err)
+		}
+	}
+	for _, root := range c.ModuleRoots {
+		if filepath.IsAbs(root) || !filepath.IsLocal(root) {
+			return fmt.Errorf("module_roots: %q must be a relative path inside the repository", root)
+		}
+	}
+	if c.Drift.Schedule != "" {
+		if _, err := parseCron(c.Drift.Schedule); err != nil {
+			return fmt.Errorf("drift: %v", err)
+		}
+	}
+	if c.Mode != "" {
+		if err := parseMode(c.Mode); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range c.AutoMode.SharedPaths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("auto_mode: invalid shared path %q: %v", pattern, err)
+		}
+	}
+	for _, tier := range c.EnvironmentTiers {
+		var err error
+		if tier.regex, err = regexp.Compile(tier.Pattern); err != nil {
+			return fmt.Errorf("environment tier %s: invalid pattern: %v", tier.Name, err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range c.Partitions {
+		if p.Name == "" {
+			return fmt.Errorf("partition is missing a name")
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("duplicate partition %q", p.Name)
+		}
+		seen[p.Name] = true
+
+		if p.Label == "" {
+			p.Label = p.Name
+		}
+		if p.OutputFile == "" {
+			p.OutputFile = p.Name + "-plans.txt"
+		}
+		if p.Parallel != "" {
+			if _, _, err := parseParallel(p.Parallel); err != nil {
+				return fmt.Errorf("partition %s: %v", p.Name, err)
+			}
+		}
+		if p.PathLayout != "" {
+			envPattern, regionPattern, err := compilePathLayout(p.PathLayout)
+			if err != nil {
+				return fmt.Errorf("partition %s: invalid path_layout: %v", p.Name, err)
+			}
+			if p.EnvPattern == "" {
+				p.EnvPattern = envPattern
+			}
+			if p.RegionPattern == "" {
+				p.RegionPattern = regionPattern
+			}
+		}
+		if p.EnvPattern == "" || p.RegionPattern == "" {
+			return fmt.Errorf("partition %s needs env_pattern and region_pattern (or path_layout)", p.Name)
+		}
+
+		var err error
+		if p.PathPattern != "" {
+			if p.pathRegex, err = regexp.Compile(p.PathPattern); err != nil {
+				return fmt.Errorf("partition %s: invalid path_pattern: %v", p.Name, err)
+			}
+		}
+		if p.envRegex, err = compileCapture(p.EnvPattern, "env"); err != nil {
+			return fmt.Errorf("partition %s: invalid env_pattern: %v", p.Name, err)
+		}
+		if p.regionRegex, err = compileCapture(p.RegionPattern, "region"); err != nil {
+			return fmt.Errorf("partition %s: invalid region_pattern: %v", p.Name, err)
+		}
+	}
+
+	for i, cred := range c.AWSCredentials {
+		if cred.Profile == "" && cred.RoleARN == "" {
+			return fmt.Errorf("aws_credentials[%d] needs a profile or role_arn", i)
+		}
+		if _, err := path.Match(cred.Env, ""); err != nil {
+			return fmt.Errorf("aws_credentials[%d]: invalid env %q: %v", i, cred.Env, err)
+		}
+		if cred.Partition != "" && !seen[cred.Partition] {
+			return fmt.Errorf("aws_credentials[%d]: unknown partition %q", i, cred.Partition)
+		}
+	}
+	return nil
+}
+
+// compileCapture compiles an extraction pattern and checks it can capture.
+func compileCapture(pattern, name string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("%q has no capture group for the %s", pattern, name)
+	}
+	return re, nil
+}
+
+// compilePathLayout turns "live/{env}/{region}" into env and region
+// patterns matching that directory layout anywhere in a line.
+func compilePathLayout(layout string) (envPattern, regionPattern string, err error) {
+	segments := strings.Split(strings.Trim(layout, "/"), "/")
+	build := func(capture string) string {
+		parts := make([]string, len(segments))
+		for i, segment := range segments {
+			switch {
+			case segment == "{"+capture+"}":
+				parts[i] = fmt.Sprintf("(?P<%s>[^/\\s]+)", capture)
+			case segment == "*" || (strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")):
+				parts[i] = `[^/\s]+`
+			default:
+				parts[i] = regexp.QuoteMeta(segment)
+			}
+		}
+		return `(?:^|/)` + strings.Join(parts, "/") + `(?:/|\s|$)`
+	}
+
+	if !strings.Contains(layout, "{env}") || !strings.Contains(layout, "{region}") {
+		return "", "", fmt.Errorf("%q must contain {env} and {region}", layout)
+	}
+	return build("env"), build("region"), nil
+}
+
+// MatchEnv extracts the environment name from a line of runner output.
+func (p *Partition) MatchEnv(line string) (string, bool) {
+	env, ok := matchCapture(p.envRegex, line, "env")
+	if !ok {
+		return "", false
+	}
+	if name, exists := p.EnvironmentNames[env]; exists {
+		env = name
+	}
+	return env, true
+}
+
+// MatchRegion extracts the region from a line of runner output.
+func (p *Partition) MatchRegion(line string) (string, bool) {
+	return matchCapture(p.regionRegex, line, "region")
+}
+
+// matchCapture returns the named group if the pattern has one, otherwise
+// the first capture group.
+func matchCapture(re *regexp.Regexp, line, name string) (string, bool) {
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+	if i := re.SubexpIndex(name); i > 0 {
+		return m[i], m[i] != ""
+	}
+	return m[1], m[1] != ""
+}
+
+// PartitionFor returns the partition a targeted state path belongs to: the
+// first partition whose path_pattern matches, otherwise the first partition
+// without a path_pattern.
+func (c *Config) PartitionFor(path string) *Partition {
+	var fallback *Partition
+	for _, p := range c.Partitions {
+		if p.pathRegex == nil {
+			if fallback == nil {
+				fallback = p
+			}
+			continue
+		}
+		if p.pathRegex.MatchString(path) {
+			return p
+		}
+	}
+	return fallback
+}
+
+// EmptyPlaceholder is written to the plans file when a partition has no
+// affected states, so the file still exists for the user to inspect.
+func (p *Partition) EmptyPlaceholder() string {
+	return fmt.Sprintf("No %s plans needed\n", p.Label)
+}
@@ -0,0 +1,164 @@
+package planner
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-colorable"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+// console is where progress output goes: stderr, with ANSI colors
+// translated on legacy Windows consoles and emoji replaced by ASCII
+// markers where they can't be shown.
+var console io.Writer = os.Stderr
+
+// consoleTTY is set when stderr is a terminal, where progress can redraw a
+// status line in place.
+var consoleTTY bool
+
+// consoleASCII is set when the console can't show emoji and symbols.
+var consoleASCII bool
+
+// asciiMarkers replaces the emoji and symbols of progress output. Others
+// fall back to "*".
+var asciiMarkers = strings.NewReplacer(
+	"\ufe0f", "", // emoji presentation selector
+	"❌", "[x]",
+	"⚠", "[!]",
+	"✅", "[ok]",
+	"💥", "[!!]",
+	"🔥", "[!!]",
+	"💡", "[i]",
+	"🚀", ">>",
+	"⏳", "...",
+	"→", "->",
+	"—", "-",
+	"×", "x",
+	"…", "...",
+)
+
+func init() {
+	fd := os.Stderr.Fd()
+	// CI agents that run commands in a pseudo-terminal still log to a file
+	tty := (isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)) && !ciConsole()
+	consoleTTY = tty && os.Getenv("TERM") != "dumb"
+	color.NoColor = os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !tty
+	setConsole(!unicodeConsole(tty))
+}
+
+// ciConsole tells whether the console is a CI job's log, by the variables
+// CI services set.
+func ciConsole() bool {
+	for _, name := range []string{"CI", "JENKINS_URL", "TEAMCITY_VERSION", "BUILD_BUILDID"} {
+		if value := os.Getenv(name); value != "" && value != "false" && value != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// setConsole points progress output at stderr, through asciiWriter if
+// ascii is set.
+func setConsole(ascii bool) {
+	consoleASCII = ascii
+	console = colorable.NewColorableStderr()
+	if ascii {
+		console = asciiWriter{console}
+	}
+	color.Output = console
+}
+
+// applyConsoleFlags drops colors for --no-color, forces plain ASCII output
+// without colors for --ascii and sets up --log-format and --log-level.
+func applyConsoleFlags(cmd *cobra.Command) {
+	if noColor, _ := cmd.Flags().GetBool("no-color"); noColor {
+		color.NoColor = true
+	}
+	if ascii, _ := cmd.Flags().GetBool("ascii"); ascii {
+		color.NoColor = true
+		setConsole(true)
+	}
+	format, _ := cmd.Flags().GetString("log-format")
+	level, _ := cmd.Flags().GetString("log-level")
+	if err := setLogging(format, level); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// unicodeConsole guesses whether stderr shows emoji. Legacy Windows
+// consoles and pipes read by Windows agents don't; Windows Terminal, VS
+// Code, ConEmu and mintty do. Elsewhere the locale decides, and with none
+// set only a terminal is trusted: CI agents such as Jenkins run in the C
+// locale.
+func unicodeConsole(tty bool) bool {
+	if runtime.GOOS == "windows" {
+		return tty && (os.Getenv("WT_SESSION") != "" || os.Getenv("TERM_PROGRAM") != "" ||
+			os.Getenv("ConEmuANSI") == "ON" || isatty.IsCygwinTerminal(os.Stderr.Fd()))
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := strings.ToLower(os.Getenv(name)); locale != "" {
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return tty && os.Getenv("TERM") != "dumb"
+}
+
+// asciiWriter replaces emoji and other symbols with ASCII markers.
+type asciiWriter struct{ w io.Writer }
+
+func (a asciiWriter) Write(p []byte) (int, error) {
+	text := asciiMarkers.Replace(string(p))
+	var b strings.Builder
+	for _, r := range text {
+		if r >= 0x2190 && r < 0x2C00 || r >= 0x1F000 {
+			b.WriteByte('*')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	if _, err := io.WriteString(a.w, b.String()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// prefixWriter writes whole lines to w, each behind prefix, so concurrent
+// commands echoed to the console stay readable. Flush writes a last
+// unterminated line.
+type prefixWriter struct {
+	w       io.Writer
+	prefix  string
+	pending []byte
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.pending = append(pw.pending, p...)
+	var lines []byte
+	for {
+		i := bytes.IndexByte(pw.pending, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(append(lines, pw.prefix...), pw.pending[:i+1]...)
+		pw.pending = pw.pending[i+1:]
+	}
+	if len(lines) > 0 {
+		if _, err := pw.w.Write(lines); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (pw *prefixWriter) Flush() {
+	if len(pw.pending) > 0 {
+		pw.Write([]byte("\n"))
+	}
+}
@@ -0,0 +1,188 @@
+package planner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// costReport is the monthly cost change of the run's saved plans, as
+// Infracost estimates it, by environment.
+type costReport struct {
+	Currency     string        `json:"currency"`
+	Past         float64       `json:"past_monthly"`
+	Monthly      float64       `json:"monthly"`
+	Diff         float64       `json:"diff_monthly"`
+	Environments []*costImpact `json:"environments"`
+}
+
+// costImpact is the monthly cost change of one environment's states.
+type costImpact struct {
+	Environment string  `json:"environment"`
+	States      int     `json:"states"`
+	Past        float64 `json:"past_monthly"`
+	Monthly     float64 `json:"monthly"`
+	Diff        float64 `json:"diff_monthly"`
+}
+
+// infracostDiff is the part of `infracost diff --format json` output the
+// report uses. Costs are decimal strings, null without priced resources.
+type infracostDiff struct {
+	Currency             string  `json:"currency"`
+	PastTotalMonthlyCost *string `json:"pastTotalMonthlyCost"`
+	TotalMonthlyCost     *string `json:"totalMonthlyCost"`
+}
+
+// estimatesCosts tells whether the run's cost change can be estimated:
+// targeted states save JSON plans, and infracost is installed (or its
+// output recorded, when replaying).
+func (pg *PlanGenerator) estimatesCosts() bool {
+	if !pg.Cost || !pg.SavePlans || len(pg.plannedStates) == 0 || pg.Config.Runner.Show == "" || pg.ctx.Err() != nil {
+		return false
+	}
+	if pg.replaying() {
+		return true
+	}
+	_, err := exec.LookPath("infracost")
+	return err == nil
+}
+
+// estimateCosts runs infracost diff on the JSON plan of every planned
+// state and adds the results up by environment. Estimates missing a state
+// would understate the change, so any failure fails them all.
+func (pg *PlanGenerator) estimateCosts() (*costReport, error) {
+	report := &costReport{}
+	byEnv := make(map[string]*costImpact)
+	for _, state := range pg.plannedStates {
+		path := filepath.Join(pg.OutputDir, state.planJSONName())
+		if _, err := os.Stat(path); err != nil {
+			// Failed plans save nothing; they're listed as failures
+			continue
+		}
+		diff, err := pg.infracostDiff(state, path)
+		if err != nil {
+			return nil, err
+		}
+		if report.Currency == "" {
+			report.Currency = diff.Currency
+		}
+		past, monthly := parseCost(diff.PastTotalMonthlyCost), parseCost(diff.TotalMonthlyCost)
+
+		env := state.Path
+		if p := pg.Config.PartitionFor(state.String()); p != nil {
+			if name, ok := p.MatchEnv(state.String()); ok {
+				env = name
+			}
+		}
+		impact := byEnv[env]
+		if impact == nil {
+			impact = &costImpact{Environment: env}
+			byEnv[env] = impact
+			report.Environments = append(report.Environments, impact)
+		}
+		impact.States++
+		impact.Past += past
+		impact.Monthly += monthly
+		impact.Diff += monthly - past
+		report.Past += past
+		report.Monthly += monthly
+		report.Diff += monthly - past
+	}
+	if len(report.Environments) == 0 {
+		return nil, nil
+	}
+	sort.Slice(report.Environments, func(i, j int) bool {
+		return environmentLess(pg.Config.EnvironmentOrder, report.Environments[i].Environment, report.Environments[j].Environment)
+	})
+	// Sums of decimal costs pick up float noise
+	for _, impact := range report.Environments {
+		impact.Past, impact.Monthly, impact.Diff = roundCents(impact.Past), roundCents(impact.Monthly), roundCents(impact.Diff)
+	}
+	report.Past, report.Monthly, report.Diff = roundCents(report.Past), roundCents(report.Monthly), roundCents(report.Diff)
+	return report, nil
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// infracostDiff prices the changes of a state's JSON plan.
+func (pg *PlanGenerator) infracostDiff(state *State, planJSON string) (*infracostDiff, error) {
+	abs, _ := filepath.Abs(planJSON)
+	var stdout, stderr bytes.Buffer
+	err := pg.execute(pg.ctx, &Command{
+		Args:   []string{"infracost", "diff", "--path", abs, "--format", "json", "--no-color"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", state, pg.commandError(err, state.String()+"-infracost", stderr.Bytes()))
+	}
+	var diff infracostDiff
+	if err := json.Unmarshal(stdout.Bytes(), &diff); err != nil {
+		return nil, fmt.Errorf("reading infracost output for %s: %v", state, err)
+	}
+	return &diff, nil
+}
+
+// parseCost reads an Infracost cost, 0 when it's null.
+func parseCost(cost *string) float64 {
+	if cost == nil {
+		return 0
+	}
+	value, _ := strconv.ParseFloat(*cost, 64)
+	return value
+}
+
+// formatCost renders an amount of currency, with its sign when signed.
+func formatCost(amount float64, currency string, signed bool) string {
+	sign := ""
+	switch {
+	case amount < 0:
+		sign = "-"
+	case signed && amount > 0:
+		sign = "+"
+	}
+	amount = math.Abs(amount)
+	if currency == "" || currency == "USD" {
+		return fmt.Sprintf("%s$%.2f", sign, amount)
+	}
+	return fmt.Sprintf("%s%.2f %s", sign, amount, currency)
+}
+
+// formatCostChange renders a cost change with its percentage of the cost
+// before, where there was one.
+func formatCostChange(past, diff float64, currency string) string {
+	change := formatCost(diff, currency, true)
+	if past != 0 && diff != 0 {
+		change += fmt.Sprintf(" (%+.0f%%)", diff/past*100)
+	}
+	return change
+}
+
+// writeCostImpact renders the cost impact section.
+func (pg *PlanGenerator) writeCostImpact(output *os.File) {
+	if pg.cost == nil {
+		return
+	}
+	currency := pg.cost.Currency
+	output.WriteString("## " + pg.renderer().Icon("💰") + "Cost impact\n\n")
+	output.WriteString("Monthly cost estimate of the planned changes by [Infracost](https://www.infracost.io):\n\n")
+	output.WriteString("| Environment | Before | After | Change |\n|---|---|---|---|\n")
+	for _, impact := range pg.cost.Environments {
+		output.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", impact.Environment,
+			formatCost(impact.Past, currency, false), formatCost(impact.Monthly, currency, false), formatCostChange(impact.Past, impact.Diff, currency)))
+	}
+	if len(pg.cost.Environments) > 1 {
+		output.WriteString(fmt.Sprintf("| **Total** | %s | %s | **%s** |\n",
+			formatCost(pg.cost.Past, currency, false), formatCost(pg.cost.Monthly, currency, false), formatCostChange(pg.cost.Past, pg.cost.Diff, currency)))
+	}
+	output.WriteString("\n")
+}
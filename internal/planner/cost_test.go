@@ -0,0 +1,44 @@
+package planner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateCostsByEnvironment(t *testing.T) {
+	fake := &fakeExecutor{stdout: `{"currency":"USD","pastTotalMonthlyCost":"10.1","totalMonthlyCost":"12.3"}`}
+	pg := newTestGenerator(t, fake)
+	pg.plannedStates = []*State{
+		{Path: "terragrunt_vpc/organizations/staging/us-east-1"},
+		{Path: "terragrunt_vpc/organizations/staging/us-west-2"},
+		{Path: "terragrunt_vpc/organizations/production/us-east-1"},
+	}
+	for i, state := range pg.plannedStates {
+		state.PlanFile = state.planFileName()
+		if i == 2 {
+			// A failed plan saves no JSON and isn't priced
+			continue
+		}
+		path := filepath.Join(pg.OutputDir, state.planJSONName())
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("{}"), 0644)
+	}
+
+	cost, err := pg.estimateCosts()
+	if err != nil {
+		t.Fatalf("estimateCosts: %v", err)
+	}
+	if len(fake.commands) != 2 || fake.commands[0][0] != "infracost" {
+		t.Fatalf("commands = %v, want infracost for the 2 saved plans", fake.commands)
+	}
+	if len(cost.Environments) != 1 || cost.Environments[0].Environment != "staging" || cost.Environments[0].States != 2 {
+		t.Fatalf("environments = %+v, want staging with 2 states", cost.Environments[0])
+	}
+	if cost.Diff != 4.4 || cost.Monthly != 24.6 {
+		t.Errorf("diff = %v, monthly = %v, want 4.4 and 24.6", cost.Diff, cost.Monthly)
+	}
+	if got := formatCostChange(cost.Past, cost.Diff, cost.Currency); got != "+$4.40 (+22%)" {
+		t.Errorf("change = %q", got)
+	}
+}
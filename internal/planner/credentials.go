@@ -0,0 +1,356 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// credentialsTimeout bounds one `aws sts` call.
+const credentialsTimeout = 30 * time.Second
+
+// expiredSSORegex matches the AWS CLI's errors for an expired or missing
+// SSO login.
+var expiredSSORegex = regexp.MustCompile(`(?i)(token has expired|sso session .*(has expired|is invalid)|error loading sso token|expiredtoken|refresh failed|sso_start_url)`)
+
+// inheritedCredentialVars are the inherited variables selecting AWS
+// credentials, dropped from plans given an aws_credentials entry: static
+// keys would win over its profile.
+var inheritedCredentialVars = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_DEFAULT_PROFILE"}
+
+// AWSCredentials picks the AWS credentials of the plans of some
+// environments (aws_credentials): a profile, or a role assumed for them.
+type AWSCredentials struct {
+	Env       string `yaml:"env"`       // environment glob; empty for every environment
+	Partition string `yaml:"partition"` // partition name; empty for every partition
+	Profile   string `yaml:"profile"`   // AWS_PROFILE, or the profile role_arn is assumed with
+	RoleARN   string `yaml:"role_arn"`  // role assumed once per run for the plans
+}
+
+// AWSCredentialsFor is the first aws_credentials entry matching a partition
+// and environment, nil when the plans keep the inherited credentials. env
+// is "" for a full run's plan_all, which covers every environment of the
+// partition and only matches entries without env.
+func (c *Config) AWSCredentialsFor(p *Partition, env string) *AWSCredentials {
+	for _, cred := range c.AWSCredentials {
+		if cred.Partition != "" && cred.Partition != p.Name {
+			continue
+		}
+		if cred.Env != "" {
+			if ok, _ := path.Match(cred.Env, env); env == "" || !ok {
+				continue
+			}
+		}
+		return cred
+	}
+	return nil
+}
+
+// credentialSources lists the credentials a partition's plans may use: its
+// plan_all's (nil for the inherited ones), then the entries of single
+// environments.
+func (c *Config) credentialSources(p *Partition) []*AWSCredentials {
+	sources := []*AWSCredentials{c.AWSCredentialsFor(p, "")}
+	for _, cred := range c.AWSCredentials {
+		if cred.Env != "" && (cred.Partition == "" || cred.Partition == p.Name) {
+			sources = append(sources, cred)
+		}
+	}
+	return sources
+}
+
+// credentialsName names the credentials of a partition in messages.
+func credentialsName(p *Partition, cred *AWSCredentials) string {
+	switch {
+	case cred == nil:
+		return p.Name
+	case cred.RoleARN != "":
+		return fmt.Sprintf("%s role %s", p.Name, cred.RoleARN)
+	default:
+		return fmt.Sprintf("%s profile %s", p.Name, cred.Profile)
+	}
+}
+
+// stateCredentials is the aws_credentials entry of a targeted state, nil
+// for the inherited credentials.
+func (pg *PlanGenerator) stateCredentials(state *State) *AWSCredentials {
+	p := pg.Config.PartitionFor(state.String())
+	if p == nil {
+		return nil
+	}
+	env, _, _ := stateRegion(p, state)
+	return pg.Config.AWSCredentialsFor(p, env)
+}
+
+// credentialsEnv is what the environment of a plan using cred sets: its
+// AWS_PROFILE, or the session credentials of its role once assumeRoles
+// assumed it.
+func (pg *PlanGenerator) credentialsEnv(cred *AWSCredentials) []string {
+	switch {
+	case cred == nil:
+		return nil
+	case cred.RoleARN != "":
+		return pg.assumedRoles[cred]
+	default:
+		return []string{"AWS_PROFILE=" + cred.Profile}
+	}
+}
+
+// partitionEnv is the environment of a partition's plan_all, nil when it's
+// just the inherited one.
+func (pg *PlanGenerator) partitionEnv(p *Partition) []string {
+	creds := pg.credentialsEnv(pg.Config.AWSCredentialsFor(p, ""))
+	if creds == nil {
+		return nil
+	}
+	return append(inheritedEnv(true), creds...)
+}
+
+// inheritedEnv is the environment child processes inherit, without the
+// variables selecting AWS credentials when dropCredentials is set.
+func inheritedEnv(dropCredentials bool) []string {
+	if !dropCredentials {
+		return os.Environ()
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !contains(inheritedCredentialVars, name) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// usedCredentials lists the partitions a run plans and the credentials each
+// of their plans use, each pair once.
+func (pg *PlanGenerator) usedCredentials(targeted bool, states []*State) (partitions []*Partition, creds []*AWSCredentials) {
+	if !targeted {
+		for _, p := range pg.Config.Partitions {
+			partitions = append(partitions, p)
+			creds = append(creds, pg.Config.AWSCredentialsFor(p, ""))
+		}
+		return partitions, creds
+	}
+	type source struct {
+		p    *Partition
+		cred *AWSCredentials
+	}
+	seen := make(map[source]bool)
+	for _, p := range pg.Config.Partitions {
+		for _, state := range states {
+			if pg.Config.PartitionFor(state.String()) != p {
+				continue
+			}
+			cred := pg.stateCredentials(state)
+			if !seen[source{p, cred}] {
+				seen[source{p, cred}] = true
+				partitions = append(partitions, p)
+				creds = append(creds, cred)
+			}
+		}
+	}
+	return partitions, creds
+}
+
+// assumeRoles assumes the aws_credentials roles the run's plans use, once
+// each before any plan starts. The session credentials last an hour, the
+// STS default.
+func (pg *PlanGenerator) assumeRoles(targeted bool, states []*State) error {
+	pg.assumedRoles = make(map[*AWSCredentials][]string)
+	partitions, creds := pg.usedCredentials(targeted, states)
+	for i, cred := range creds {
+		if cred == nil || cred.RoleARN == "" || pg.assumedRoles[cred] != nil {
+			continue
+		}
+		env, err := assumeRole(pg.ctx, partitions[i], cred)
+		if err != nil {
+			return fmt.Errorf("no plans were started: %v", err)
+		}
+		if pg.Verbose {
+			fmt.Fprintf(console, "  🔑 Assumed %s for the plans of %s\n", cred.RoleARN, credentialsScope(cred))
+		}
+		pg.assumedRoles[cred] = env
+	}
+	return nil
+}
+
+// credentialsScope describes the plans an aws_credentials entry is for.
+func credentialsScope(cred *AWSCredentials) string {
+	scope := "every environment"
+	if cred.Env != "" {
+		scope = "env " + cred.Env
+	}
+	if cred.Partition != "" {
+		scope += " of " + cred.Partition
+	}
+	return scope
+}
+
+// assumeRole assumes an aws_credentials role, with its profile if it has
+// one, returning the variables handing its session to a plan.
+func assumeRole(ctx context.Context, p *Partition, cred *AWSCredentials) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, credentialsTimeout)
+	defer cancel()
+	args := []string{"sts", "assume-role", "--role-arn", cred.RoleARN, "--role-session-name", "terraform-pr-generator", "--output", "json"}
+	region := partitionRegion(p)
+	if region != "" {
+		args = append(args, "--region", region)
+	}
+	cmd := exec.CommandContext(ctx, "aws", args...)
+	if cred.Profile != "" {
+		cmd.Env = append(inheritedEnv(true), "AWS_PROFILE="+cred.Profile)
+	}
+	output, err := commandOutput(cmd)
+	if err != nil {
+		return nil, credentialsError(credentialsName(p, cred), region, err)
+	}
+	var assumed struct {
+		Credentials struct {
+			AccessKeyID     string `json:"AccessKeyId"`
+			SecretAccessKey string `json:"SecretAccessKey"`
+			SessionToken    string `json:"SessionToken"`
+		} `json:"Credentials"`
+	}
+	if err := json.Unmarshal(output, &assumed); err != nil {
+		return nil, fmt.Errorf("reading the session of %s: %v", cred.RoleARN, err)
+	}
+	return []string{
+		"AWS_ACCESS_KEY_ID=" + assumed.Credentials.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY=" + assumed.Credentials.SecretAccessKey,
+		"AWS_SESSION_TOKEN=" + assumed.Credentials.SessionToken,
+	}, nil
+}
+
+// callerIdentity is who a partition's AWS credentials authenticate as.
+type callerIdentity struct {
+	Account string `json:"Account"`
+	Arn     string `json:"Arn"`
+}
+
+// partitionRegion is the region a partition's credentials are checked
+// in: its first region, or "" for the AWS CLI's default.
+func partitionRegion(p *Partition) string {
+	if len(p.Regions) > 0 {
+		return p.Regions[0]
+	}
+	return ""
+}
+
+// awsPartition is the AWS partition ARNs of a region's accounts are in, ""
+// when the region isn't known.
+func awsPartition(region string) string {
+	switch {
+	case region == "":
+		return ""
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	default:
+		return "aws"
+	}
+}
+
+// checkAWSCredentials asks STS who a partition's plans run as with the
+// credentials cred and env select (nil for the inherited ones), failing
+// with what to do about it when they're missing, expired or for another
+// AWS partition.
+func checkAWSCredentials(ctx context.Context, p *Partition, cred *AWSCredentials, env []string) (*callerIdentity, error) {
+	ctx, cancel := context.WithTimeout(ctx, credentialsTimeout)
+	defer cancel()
+	args := []string{"sts", "get-caller-identity", "--output", "json"}
+	region := partitionRegion(p)
+	if region != "" {
+		args = append(args, "--region", region)
+	}
+	cmd := exec.CommandContext(ctx, "aws", args...)
+	if env != nil {
+		cmd.Env = append(inheritedEnv(true), env...)
+	}
+	name := credentialsName(p, cred)
+	output, err := commandOutput(cmd)
+	if err != nil {
+		return nil, credentialsError(name, region, err)
+	}
+
+	var identity callerIdentity
+	if err := json.Unmarshal(output, &identity); err != nil {
+		return nil, fmt.Errorf("reading the %s caller identity: %v", name, err)
+	}
+	// arn:PARTITION:sts::ACCOUNT:assumed-role/...
+	if want := awsPartition(region); want != "" {
+		if fields := strings.SplitN(identity.Arn, ":", 3); len(fields) == 3 && fields[1] != want {
+			return &identity, fmt.Errorf("the %s credentials are for the %s partition (%s), not %s; select the %s profile or role", name, fields[1], identity.Arn, want, p.Label)
+		}
+	}
+	return &identity, nil
+}
+
+// checkCredentials checks the AWS credentials of the partitions a run
+// plans, all at once, before any plan starts: an expired SSO session
+// otherwise fails every plan of its partition, one after the other. The
+// check is skipped without the aws CLI, since the runner may bring its own
+// credentials.
+func (pg *PlanGenerator) checkCredentials(targeted bool, states []*State) error {
+	if _, err := exec.LookPath("aws"); err != nil {
+		if pg.Verbose {
+			fmt.Fprintln(console, "  → The aws CLI isn't in PATH; not checking credentials")
+		}
+		return nil
+	}
+	partitions, creds := pg.usedCredentials(targeted, states)
+
+	identities := make([]*callerIdentity, len(partitions))
+	errs := make([]error, len(partitions))
+	var wg sync.WaitGroup
+	for i, p := range partitions {
+		wg.Add(1)
+		go func(i int, p *Partition) {
+			defer wg.Done()
+			identities[i], errs[i] = checkAWSCredentials(pg.ctx, p, creds[i], pg.credentialsEnv(creds[i]))
+		}(i, p)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, p := range partitions {
+		if errs[i] != nil {
+			failed = append(failed, errs[i].Error())
+		} else if pg.Verbose {
+			fmt.Fprintf(console, "  🔑 %s credentials: %s\n", p.Label, identities[i].Arn)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("no plans were started, the AWS credentials check failed:\n  %s\n(--no-credentials-check skips the check)", strings.Join(failed, "\n  "))
+	}
+	return nil
+}
+
+// credentialsError explains a failed `aws sts` call with the credentials
+// called name.
+func credentialsError(name, region string, err error) error {
+	var stderr string
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		stderr = strings.TrimSpace(string(exitErr.Stderr))
+	}
+	switch {
+	case expiredSSORegex.MatchString(stderr):
+		return fmt.Errorf("your %s SSO session expired: run `aws sso login` with its profile, then try again", name)
+	case strings.Contains(stderr, "Unable to locate credentials"):
+		return fmt.Errorf("no AWS credentials found for %s: set AWS_PROFILE or run `aws configure sso`", name)
+	case strings.Contains(stderr, "InvalidClientTokenId") && region != "":
+		return fmt.Errorf("the %s credentials aren't valid in %s, likely credentials of another AWS partition", name, region)
+	case stderr != "":
+		lines := strings.Split(stderr, "\n")
+		return fmt.Errorf("checking the %s credentials: %s", name, lines[len(lines)-1])
+	}
+	return fmt.Errorf("checking the %s credentials: %v", name, err)
+}
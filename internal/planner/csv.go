@@ -0,0 +1,124 @@
+package planner
+
+import (
+	"encoding/csv"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// csvChangeRegex is a resource change's header in a plan, including the
+// tainted resources resourceChangeRegex leaves out.
+var csvChangeRegex = regexp.MustCompile(`^\s*# (\S+) (?:will be|must be|is tainted, so must be) (.+?)\s*$`)
+
+// forcesReplacementRegex is an attribute or block of a replaced resource
+// that forces its replacement, e.g. `~ ami = "a" -> "b" # forces replacement`.
+var forcesReplacementRegex = regexp.MustCompile(`^\s*(?:[-+~]|-/\+|\+/-)\s+"?([^\s"=\[{]+)"?.*# forces replacement\s*$`)
+
+// csvActions are the actions of the changes.csv rows, named like in
+// structured run output, by how plan output words them.
+var csvActions = map[string]string{
+	"created":                "create",
+	"updated in-place":       "update",
+	"destroyed":              "delete",
+	"replaced":               "replace",
+	"replaced, as requested": "replace",
+	"read during apply":      "read",
+	"imported":               "import",
+}
+
+// csvChange is one resource action of a region plan.
+type csvChange struct {
+	address, action, reason string
+}
+
+// writeCSV writes a row per resource action of every region plan, for
+// pivoting and filtering fleet-wide changes in a spreadsheet. The replace
+// reason is the attributes forcing a replacement, "tainted", or "requested"
+// for -replace. Incomplete plans list the changes printed before they
+// errored.
+func (pg *PlanGenerator) writeCSV(path string, results []*PartitionResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"partition", "environment", "region", "address", "type", "action", "replace_reason"})
+	for _, result := range results {
+		for _, env := range result.Environments {
+			for _, region := range env.Regions {
+				for _, c := range planChanges(env.Plans[region]) {
+					w.Write([]string{result.Partition.Name, env.Name, region, c.address, resourceType(c.address), c.action, c.reason})
+				}
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// planChanges lists the resource actions of a plan body, in plan order.
+func planChanges(body string) []*csvChange {
+	var changes []*csvChange
+	var current *csvChange
+	var forcing []string
+	done := func() {
+		if current != nil && current.reason == "" && len(forcing) > 0 {
+			current.reason = strings.Join(forcing, " ")
+		}
+		forcing = nil
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if isPlanSummary(line) {
+			break
+		}
+		if m := csvChangeRegex.FindStringSubmatch(line); m != nil {
+			done()
+			current = &csvChange{address: m[1], action: m[2]}
+			if action, ok := csvActions[m[2]]; ok {
+				current.action = action
+			}
+			switch {
+			case strings.Contains(line, "is tainted"):
+				current.reason = "tainted"
+			case m[2] == "replaced, as requested":
+				current.reason = "requested"
+			}
+			changes = append(changes, current)
+			continue
+		}
+		if m := forcesReplacementRegex.FindStringSubmatch(line); m != nil && current != nil && current.action == "replace" && !slices.Contains(forcing, m[1]) {
+			forcing = append(forcing, m[1])
+		}
+	}
+	done()
+	return changes
+}
+
+// resourceType is the type of the resource at address, without its
+// modules, e.g. aws_s3_bucket for module.logs["a"].aws_s3_bucket.this.
+func resourceType(address string) string {
+	for strings.HasPrefix(address, "module.") {
+		rest := address[len("module."):]
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			return ""
+		}
+		if rest[end] == '[' {
+			key := strings.Index(rest[end:], "].")
+			if key < 0 {
+				return ""
+			}
+			end += key + 1
+		}
+		address = rest[end+1:]
+	}
+	address = strings.TrimPrefix(address, "data.")
+	typ, _, _ := strings.Cut(address, ".")
+	return typ
+}
@@ -0,0 +1,44 @@
+package planner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	pg := newTestGenerator(t, nil)
+	plan := `Terraform will perform the following actions:
+
+  # module.logs["eu"].aws_s3_bucket.this must be replaced
+-/+ resource "aws_s3_bucket" "this" {
+      ~ bucket = "logs-a" -> "logs-b" # forces replacement
+    }
+
+  # aws_instance.scanner is tainted, so must be replaced
+-/+ resource "aws_instance" "scanner" {
+    }
+
+  # data.aws_iam_policy_document.scan will be read during apply
+ <= data "aws_iam_policy_document" "scan" {
+    }
+
+Plan: 2 to add, 0 to change, 2 to destroy.`
+	results := testResults(pg.Config.Partitions[0], testEnvironment("production", map[string]string{"us-east-1": plan}))
+	path := filepath.Join(t.TempDir(), "changes.csv")
+	if err := pg.writeCSV(path, results); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `partition,environment,region,address,type,action,replace_reason
+commercial,production,us-east-1,"module.logs[""eu""].aws_s3_bucket.this",aws_s3_bucket,replace,bucket
+commercial,production,us-east-1,aws_instance.scanner,aws_instance,replace,tainted
+commercial,production,us-east-1,data.aws_iam_policy_document.scan,aws_iam_policy_document,read,
+`
+	if string(data) != want {
+		t.Errorf("changes.csv:\n%s\nwant:\n%s", data, want)
+	}
+}
@@ -0,0 +1,242 @@
+package planner
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dashboardPageSize is how many runs a page of the dashboard lists.
+const dashboardPageSize = 50
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+var dashboardTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"when": func(t time.Time) string { return t.Format("2006-01-02 15:04 MST") },
+	"took": func(d time.Duration) string { return d.Round(time.Second).String() },
+	"counts": func(c PlanCounts) string {
+		return fmt.Sprintf("+%d ~%d -%d", c.Add, c.Change, c.Destroy)
+	},
+	"path": url.PathEscape,
+}).ParseFS(dashboardFiles, "dashboard/*.html"))
+
+// dashboardRegion is a region plan of a run page.
+type dashboardRegion struct {
+	*historyResult
+	// HasPlan is set when the recorded report holds the region's plan.
+	HasPlan bool
+}
+
+// planLine is a line of a plan, classed by the change it shows.
+type planLine struct {
+	Text, Class string
+}
+
+// handleDashboard serves the web UI browsing the history database:
+// /ui/ lists runs, /ui/runs/{id} shows one and
+// /ui/runs/{id}/plans/{partition}/{env}/{region} a region's plan.
+func (s *planServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	// The path is split before unescaping, since an environment or region
+	// may contain a slash
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/ui/")
+	if rest == "style.css" {
+		css, _ := dashboardFiles.ReadFile("dashboard/style.css")
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		w.Write(css)
+		return
+	}
+
+	db, err := openHistory()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	if rest == "" {
+		s.dashboardRuns(w, r, db)
+		return
+	}
+	parts := strings.Split(rest, "/")
+	for i, part := range parts {
+		if parts[i], err = url.PathUnescape(part); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if parts[0] != "runs" || len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	entry, results, err := loadHistory(db, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	switch {
+	case len(parts) == 2:
+		s.dashboardRun(w, entry, results)
+	case len(parts) == 3 && parts[2] == "report.md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(entry.Report))
+	case len(parts) == 6 && parts[2] == "plans":
+		s.dashboardPlan(w, entry, regionKey{parts[3], parts[4], parts[5]})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *planServer) dashboardRuns(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	filter := historyFilter{
+		Module: query.Get("module"),
+		Status: query.Get("status"),
+		// One more tells whether there's a next page
+		Limit:  dashboardPageSize + 1,
+		Offset: (page - 1) * dashboardPageSize,
+	}
+	runs, err := listHistory(db, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	more := len(runs) > dashboardPageSize
+	if more {
+		runs = runs[:dashboardPageSize]
+	}
+
+	pageLink := func(page int) string {
+		q := url.Values{}
+		for _, key := range []string{"module", "status"} {
+			if v := query.Get(key); v != "" {
+				q.Set(key, v)
+			}
+		}
+		q.Set("page", strconv.Itoa(page))
+		return "/ui/?" + q.Encode()
+	}
+	data := map[string]any{
+		"Title":  "Runs",
+		"Runs":   runs,
+		"Module": filter.Module,
+		"Status": filter.Status,
+	}
+	if page > 1 {
+		data["Previous"] = pageLink(page - 1)
+	}
+	if more {
+		data["Next"] = pageLink(page + 1)
+	}
+	renderDashboard(w, "runs.html", data)
+}
+
+func (s *planServer) dashboardRun(w http.ResponseWriter, entry *historyEntry, results []*historyResult) {
+	plans := make(map[regionKey]bool)
+	for _, section := range parseReportMarkdown(entry.Report) {
+		for _, region := range section.Regions {
+			plans[regionKey{section.Partition, section.Environment, region.Region}] = true
+		}
+	}
+	var regions []*dashboardRegion
+	var totals PlanCounts
+	for _, r := range results {
+		hasPlan := plans[regionKey{r.Partition, r.Env, r.Region}] || plans[regionKey{"", r.Env, r.Region}]
+		regions = append(regions, &dashboardRegion{historyResult: r, HasPlan: hasPlan})
+		if r.Counts != nil {
+			totals.Add += r.Counts.Add
+			totals.Change += r.Counts.Change
+			totals.Destroy += r.Counts.Destroy
+		}
+	}
+	renderDashboard(w, "run.html", map[string]any{
+		"Title":   fmt.Sprintf("Run %d: %s", entry.ID, entry.Module),
+		"Run":     entry,
+		"Regions": regions,
+		"Totals":  totals,
+	})
+}
+
+// dashboardPlan shows the plan of a region. Reports without partition
+// markers match it in any partition.
+func (s *planServer) dashboardPlan(w http.ResponseWriter, entry *historyEntry, key regionKey) {
+	var block string
+	for _, section := range parseReportMarkdown(entry.Report) {
+		for _, rr := range section.Regions {
+			if (section.Partition == key.Partition || section.Partition == "") && section.Environment == key.Env && rr.Region == key.Region {
+				block = rr.Block
+			}
+		}
+	}
+	if block == "" {
+		http.Error(w, fmt.Sprintf("run %d's report has no plan for %s", entry.ID, key), http.StatusNotFound)
+		return
+	}
+	renderDashboard(w, "plan.html", map[string]any{
+		"Title":     fmt.Sprintf("Run %d: %s %s", entry.ID, entry.Module, key),
+		"Run":       entry,
+		"Partition": key.Partition,
+		"Env":       key.Env,
+		"Region":    key.Region,
+		"Lines":     planLines(block),
+	})
+}
+
+// planLines turns a region's <details> block into plan lines, dropping the
+// HTML and code fences around them.
+func planLines(block string) []planLine {
+	var lines []planLine
+	for _, line := range strings.Split(block, "\n") {
+		if line == "<details>" || line == "</details>" || strings.HasPrefix(line, "```") || summaryRegex.MatchString(line) {
+			continue
+		}
+		class := ""
+		switch trimmed := strings.TrimSpace(line); {
+		case strings.HasPrefix(trimmed, "-/+"), strings.HasPrefix(trimmed, "+/-"):
+			class = "replace"
+		case strings.HasPrefix(trimmed, "+"):
+			class = "add"
+		case strings.HasPrefix(trimmed, "-"):
+			class = "destroy"
+		case strings.HasPrefix(trimmed, "~"):
+			class = "change"
+		}
+		lines = append(lines, planLine{Text: line, Class: class})
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0].Text) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1].Text) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func renderDashboard(w http.ResponseWriter, page string, data map[string]any) {
+	var out strings.Builder
+	if err := dashboardTemplates.ExecuteTemplate(&out, page, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(out.String()))
+}
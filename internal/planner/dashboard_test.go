@@ -0,0 +1,151 @@
+package planner
+
+import (
+	"html"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dashboardEnv is an environment name with HTML and URL path characters.
+const dashboardEnv = `<img src=x onerror=alert(1)>/blue#1?a&b`
+
+// dashboardServer is a server over a history database holding a run of
+// platform/vpc, which planned dashboardEnv and staging in the commercial
+// partition and production in both partitions.
+func dashboardServer(t *testing.T) *planServer {
+	t.Helper()
+	t.Setenv(historyDBEnv, filepath.Join(t.TempDir(), "history.db"))
+	pg := newTestGenerator(t, nil)
+	results := testResults(commercialPartition(t),
+		testEnvironment(dashboardEnv, map[string]string{"us-east-1": `  # aws_s3_bucket.this will be created
+  + resource "aws_s3_bucket" "this" {
+      + tags = { "Owner" = "<ops>" }
+    }
+
+Plan: 1 to add, 0 to change, 0 to destroy.`}),
+		testEnvironment("production", map[string]string{"us-east-1": "  ~ commercial_setting = 1\n\nPlan: 0 to add, 1 to change, 0 to destroy."}),
+		testEnvironment("staging", map[string]string{"us-west-2": "No changes. Your infrastructure matches the configuration."}),
+	)
+	results = append(results, testResults(pg.Config.Partitions[1],
+		testEnvironment("production", map[string]string{"us-east-1": "  ~ govcloud_setting = 1\n\nPlan: 0 to add, 1 to change, 0 to destroy."}),
+	)...)
+	var report strings.Builder
+	for _, result := range results {
+		pg.writePartitionMarkdown(result, &report)
+	}
+	entry := &historyEntry{
+		Module:    "platform/vpc",
+		StartedAt: time.Now(),
+		Duration:  time.Minute,
+		Status:    "failed",
+		Error:     "<script>alert(1)</script>",
+		Report:    report.String(),
+	}
+	if err := saveHistory(entry, results); err != nil {
+		t.Fatalf("saveHistory: %v", err)
+	}
+	return newPlanServer(nil, t.TempDir(), "", "")
+}
+
+func TestDashboardRuns(t *testing.T) {
+	s := dashboardServer(t)
+	for _, tc := range []struct {
+		target string
+		want   []string
+	}{
+		{"/ui/", []string{`<a href="/ui/runs/1">1</a>`, `<a href="/ui/?module=platform%2fvpc">platform/vpc</a>`, "&#43;1 ~2 -0 in 4 region(s)"}},
+		{"/ui/?module=platform/vpc&status=failed", []string{`<a href="/ui/runs/1">1</a>`, `value="platform/vpc"`, `<option value="failed" selected>`}},
+		{"/ui/?module=dns", []string{"No recorded runs match."}},
+	} {
+		w := serveRequest(t, s, "GET", tc.target, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", tc.target, w.Code, w.Body)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("%s doesn't have %s:\n%s", tc.target, want, w.Body)
+			}
+		}
+	}
+}
+
+func TestDashboardRunAndPlan(t *testing.T) {
+	s := dashboardServer(t)
+	w := serveRequest(t, s, "GET", "/ui/runs/1", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("run page: status = %d: %s", w.Code, w.Body)
+	}
+	page := w.Body.String()
+	for _, want := range []string{
+		"<h1>Run 1: platform/vpc</h1>",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		"<td>&lt;img src=x onerror=alert(1)&gt;/blue#1?a&amp;b</td>",
+		`<a href="/ui/runs/1/plans/commercial/staging/us-west-2">us-west-2</a>`,
+		`<a href="/ui/runs/1/plans/commercial/production/us-east-1">us-east-1</a>`,
+		`<a href="/ui/runs/1/plans/govcloud/production/us-east-1">us-east-1</a>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("run page doesn't have %s:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "<script>") || strings.Contains(page, "<img") {
+		t.Errorf("run page has unescaped HTML:\n%s", page)
+	}
+
+	links := regexp.MustCompile(`href="(/ui/runs/1/plans/[^"]*/us-east-1)"`).FindStringSubmatch(page)
+	if links == nil {
+		t.Fatalf("run page doesn't link to the us-east-1 plan:\n%s", page)
+	}
+	link := html.UnescapeString(links[1])
+	if want := "/ui/runs/1/plans/commercial/%3Cimg%20src=x%20onerror=alert%281%29%3E%2Fblue%231%3Fa&b/us-east-1"; link != want {
+		t.Errorf("plan link = %s, want %s", link, want)
+	}
+
+	w = serveRequest(t, s, "GET", link, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("plan page: status = %d: %s", w.Code, w.Body)
+	}
+	page = w.Body.String()
+	for _, want := range []string{
+		"<h1>commercial / &lt;img src=x onerror=alert(1)&gt;/blue#1?a&amp;b / us-east-1</h1>",
+		`<span class="add">      &#43; tags = { &#34;Owner&#34; = &#34;&lt;ops&gt;&#34; }</span>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("plan page doesn't have %s:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "<img") || strings.Contains(page, "<ops>") {
+		t.Errorf("plan page has unescaped HTML:\n%s", page)
+	}
+
+	for _, target := range []string{
+		"/ui/runs/1/plans/commercial/staging/us-east-1",
+		"/ui/runs/1/plans/govcloud/staging/us-west-2",
+		"/ui/runs/1/plans/staging/us-west-2",
+		"/ui/runs/1/plans/commercial/%3Cimg%20src=x%20onerror=alert%281%29%3E/blue%231%3Fa&b/us-east-1",
+		"/ui/runs/2",
+		"/ui/runs/one",
+	} {
+		if w := serveRequest(t, s, "GET", target, "", nil); w.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", target, w.Code, http.StatusNotFound)
+		}
+	}
+
+	// Both partitions plan production/us-east-1; each page shows its own
+	settings := map[string]string{"commercial": "commercial_setting", "govcloud": "govcloud_setting"}
+	for partition, want := range settings {
+		w := serveRequest(t, s, "GET", "/ui/runs/1/plans/"+partition+"/production/us-east-1", "", nil)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s production plan: status %d, want %s in:\n%s", partition, w.Code, want, w.Body)
+		}
+		for other, setting := range settings {
+			if other != partition && strings.Contains(w.Body.String(), setting) {
+				t.Errorf("%s production plan shows the %s plan:\n%s", partition, other, w.Body)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package planner
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// debugLogFile is the --log-file log of a run, in its output directory.
+const debugLogFile = "debug.log"
+
+// debugLog writes the --log-file log of the current run: all console
+// output, whatever the verbosity, and every command run with its
+// duration, exit status and stderr. nil when there's none.
+var debugLog *slog.Logger
+
+// openDebugLog starts the run's --log-file log, teeing console output into
+// it. close stops it, recording how the run ended.
+func (pg *PlanGenerator) openDebugLog() (close func(runErr error), err error) {
+	file, err := os.Create(filepath.Join(pg.OutputDir, debugLogFile))
+	if err != nil {
+		return nil, fmt.Errorf("--log-file: %v", err)
+	}
+	debugLog = slog.New(slog.NewTextHandler(file, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	saved := console
+	console = io.MultiWriter(console, &logWriter{logger: debugLog})
+
+	cwd, _ := os.Getwd()
+	debugLog.Debug("run started", "args", os.Args[1:], "dir", cwd, "config", pg.Config.Path, "runner", pg.Config.Runner.Name, "module", pg.ModuleName, "output", pg.OutputDir)
+	started := time.Now()
+	return func(runErr error) {
+		if runErr != nil {
+			debugLog.Error("run failed", "took", time.Since(started), "error", runErr)
+		} else {
+			debugLog.Info("run finished", "took", time.Since(started))
+		}
+		console = saved
+		debugLog = nil
+		file.Close()
+	}, nil
+}
+
+// traceCommands prints every command run, with its directory,
+// environment overrides and duration (-vv).
+var traceCommands bool
+
+// logCommand records a finished command in the --log-file log and traces
+// it with -vv. stderr is what it printed there, if captured.
+func logCommand(cmd *Command, began time.Time, err error, stderr []byte) {
+	took := time.Since(began).Round(time.Millisecond)
+	env := envOverrides(cmd.Env)
+	for i, kv := range env {
+		// Assumed roles' session credentials
+		if name, _, _ := strings.Cut(kv, "="); strings.Contains(name, "SECRET") || strings.Contains(name, "TOKEN") {
+			env[i] = name + "=<redacted>"
+		}
+	}
+	if traceCommands {
+		dir := cmd.Dir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		status := "ok"
+		if err != nil {
+			status = err.Error()
+		}
+		trace := fmt.Sprintf("    $ %s\n      dir: %s, took %s, %s\n", strings.Join(shellQuoteAll(cmd.Args), " "), dir, took, status)
+		if len(env) > 0 {
+			trace += "      env: " + strings.Join(env, " ") + "\n"
+		}
+		fmt.Fprint(console, trace)
+	}
+	if debugLog == nil {
+		return
+	}
+	attrs := []any{"argv", cmd.Args, "took", took}
+	if cmd.Dir != "" {
+		attrs = append(attrs, "dir", cmd.Dir)
+	}
+	if len(env) > 0 {
+		attrs = append(attrs, "env", env)
+	}
+	if text := strings.TrimRight(string(stderr), "\n"); text != "" {
+		attrs = append(attrs, "stderr", text)
+	}
+	if err != nil {
+		debugLog.Warn("command failed", append(attrs, "error", err)...)
+		return
+	}
+	debugLog.Debug("command finished", attrs...)
+}
+
+// commandOutput is cmd.Output, logged and traced like the run's other
+// commands.
+func commandOutput(cmd *exec.Cmd) ([]byte, error) {
+	began := time.Now()
+	output, err := cmd.Output()
+	var stderr []byte
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		stderr = exitErr.Stderr
+	}
+	logCommand(&Command{Args: cmd.Args, Dir: cmd.Dir, Env: cmd.Env}, began, err, stderr)
+	return output, err
+}
+
+// envOverrides are the variables a command's environment sets or changes
+// from the inherited one.
+func envOverrides(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	inherited := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		inherited[kv] = true
+	}
+	var overrides []string
+	for _, kv := range env {
+		if !inherited[kv] {
+			overrides = append(overrides, kv)
+		}
+	}
+	return overrides
+}
@@ -0,0 +1,91 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The markers delimiting the report in a pull request's description.
+// Everything outside them, e.g. a review template, is left alone.
+const (
+	descriptionStart = "<!-- tfprgen:start -->"
+	descriptionEnd   = "<!-- tfprgen:end -->"
+)
+
+// prDescription keeps the report in a pull request's description rather
+// than a comment. A nil *prDescription does nothing, and API failures only
+// warn so they never fail the run itself.
+type prDescription struct {
+	client *githubClient
+	pr     int
+	final  bool // the final report is in
+}
+
+// startDescription checks up front that the pull request can be updated,
+// rather than after the plans ran.
+func (pg *PlanGenerator) startDescription() error {
+	client, err := pg.githubClient()
+	if err != nil {
+		return fmt.Errorf("--update-pr-description: %v", err)
+	}
+	pr, err := pullRequestNumber(pg.PRNumber)
+	if err != nil {
+		return fmt.Errorf("--update-pr-description: %v", err)
+	}
+	pg.description = &prDescription{client: client, pr: pr}
+	return nil
+}
+
+// finish puts the final report between the markers.
+func (d *prDescription) finish(report string) {
+	if d == nil {
+		return
+	}
+	d.final = true
+	if d.update(report) {
+		successColor.Printf("📝 Updated PR #%d description\n", d.pr)
+	}
+}
+
+// fail puts an error message between the markers, unless the final
+// report is already there.
+func (d *prDescription) fail(err error) {
+	if d == nil || d.final {
+		return
+	}
+	d.update(fmt.Sprintf("❌ **Terraform plan generation failed**\n\n```\n%v\n```\n", err))
+}
+
+func (d *prDescription) update(section string) bool {
+	body, err := d.client.pullRequestBody(d.pr)
+	if err == nil {
+		err = d.client.updatePullRequestBody(d.pr, replaceMarkedSection(body, section))
+	}
+	if err != nil {
+		warningColor.Printf("⚠️  Updating PR #%d description failed: %v\n", d.pr, err)
+		return false
+	}
+	return true
+}
+
+// replaceMarkedSection replaces what's between the markers in body with
+// section, or appends the markers and section when body has none. The
+// section is truncated to keep the description within GitHub's limit.
+func replaceMarkedSection(body, section string) string {
+	before, after := strings.TrimRight(body, "\r\n"), ""
+	if start := strings.Index(body, descriptionStart); start >= 0 {
+		before = body[:start]
+		if end := strings.Index(body[start:], descriptionEnd); end >= 0 {
+			after = body[start+end+len(descriptionEnd):]
+		}
+	} else if before != "" {
+		before += "\n\n"
+	}
+	section = strings.TrimRight(section, "\n")
+	limit := githubCommentLimit - len(before) - len(after) - len(descriptionStart) - len(descriptionEnd) - 2
+	if len(section) > limit {
+		const note = "\n\n… truncated, see the full report in the workflow artifacts."
+		section = strings.ToValidUTF8(section[:max(limit-len(note), 0)], "") + note
+	}
+	return before + descriptionStart + "\n" + section + "\n" + descriptionEnd + after
+}
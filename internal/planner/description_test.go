@@ -0,0 +1,27 @@
+package planner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceMarkedSection(t *testing.T) {
+	section := descriptionStart + "\nreport\n" + descriptionEnd
+	for _, tt := range []struct {
+		name, body, want string
+	}{
+		{"empty", "", section},
+		{"appended", "## Why\r\nBecause.\r\n", "## Why\r\nBecause.\n\n" + section},
+		{"replaced", "## Why\n" + descriptionStart + "\nold\nreport\n" + descriptionEnd + "\n## Checklist", "## Why\n" + section + "\n## Checklist"},
+		{"no end marker", "## Why\n" + descriptionStart + "\nold", "## Why\n" + section},
+	} {
+		if got := replaceMarkedSection(tt.body, "report\n"); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	long := replaceMarkedSection("template", strings.Repeat("x", githubCommentLimit))
+	if len(long) > githubCommentLimit || !strings.HasSuffix(long, "workflow artifacts.\n"+descriptionEnd) {
+		t.Errorf("a long report wasn't truncated to fit: %d bytes", len(long))
+	}
+}
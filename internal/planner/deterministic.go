@@ -0,0 +1,24 @@
+package planner
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// fixedTime is the time of --deterministic runs: SOURCE_DATE_EPOCH, the
+// reproducible builds convention, else the Unix epoch.
+func fixedTime() time.Time {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC()
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// now is the time recorded in the run's output.
+func (pg *PlanGenerator) now() time.Time {
+	if pg.Deterministic {
+		return fixedTime()
+	}
+	return time.Now()
+}
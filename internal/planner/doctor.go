@@ -0,0 +1,255 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// versionTimeout bounds a binary's version command.
+const versionTimeout = 10 * time.Second
+
+func newDoctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the environment before a run",
+		Long: `Checks what a run needs before it's started, and how to fix what's
+missing:
+
+  - the repository root, which runs are started from
+  - the config file, if any
+  - the runner's binary and the terragrunt and terraform binaries it
+    drives, with their versions
+  - affected-modules.sh, which targeted runs need
+  - the AWS credentials of each partition (aws sts get-caller-identity in
+    its first region), which must be valid and of the right AWS partition
+
+Exits with status 1 when a check fails; warnings don't fail it.
+
+Examples:
+  terraform-pr-generator doctor
+  terraform-pr-generator doctor --targeted --runner terragrunt`,
+		Args:        cobra.NoArgs,
+		Run:         runDoctor,
+		Annotations: map[string]string{findsRepoRoot: "true"},
+	}
+
+	cmd.Flags().StringP("config", "c", "", "Path to a YAML config file (default: .tfprgen.yaml in the repo root)")
+	cmd.Flags().String("runner", "", "Built-in runner to check: kitman, terragrunt or terraform (default: from config, else kitman)")
+	cmd.Flags().BoolP("targeted", "t", false, "Check for targeted runs, which need affected-modules.sh (default: from config)")
+	return cmd
+}
+
+// doctor prints check results and counts the failed ones.
+type doctor struct {
+	failed, warned int
+}
+
+func (d *doctor) pass(name, detail string) {
+	successColor.Printf("✅ %s: %s\n", name, detail)
+}
+
+func (d *doctor) warn(name, detail, fix string) {
+	d.warned++
+	warningColor.Printf("⚠️  %s: %s\n", name, detail)
+	if fix != "" {
+		fmt.Fprintf(console, "   → %s\n", fix)
+	}
+}
+
+func (d *doctor) fail(name, detail, fix string) {
+	d.failed++
+	errorColor.Printf("❌ %s: %s\n", name, detail)
+	if fix != "" {
+		fmt.Fprintf(console, "   → %s\n", fix)
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	d := &doctor{}
+	d.checkRepoRoot()
+
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = FindConfigFile(".")
+	}
+	cfg, err := LoadConfig(configPath)
+	if err == nil {
+		if runner, _ := cmd.Flags().GetString("runner"); runner != "" {
+			err = cfg.SetRunner(runner)
+		}
+	}
+	switch {
+	case err != nil:
+		d.fail("config", err.Error(), "fix the config file, or pass another with --config")
+		// The remaining checks go by the defaults
+		cfg = DefaultConfig()
+	case cfg.Path == "":
+		d.pass("config", "none found, using the defaults")
+	default:
+		d.pass("config", cfg.Path)
+	}
+
+	targeted, _ := cmd.Flags().GetBool("targeted")
+	if !cmd.Flags().Changed("targeted") {
+		targeted = cfg.Targeted || cfg.Mode == modeTargeted
+	}
+	for _, binary := range runnerBinaries(&cfg.Runner) {
+		d.checkBinary(binary)
+	}
+	d.checkAffectedScript(targeted)
+	d.checkCredentials(cfg)
+
+	fmt.Fprintln(console)
+	switch {
+	case d.failed > 0:
+		errorColor.Printf("❌ %d check(s) failed, %d warning(s)\n", d.failed, d.warned)
+		os.Exit(1)
+	case d.warned > 0:
+		warningColor.Printf("⚠️  All checks passed, with %d warning(s)\n", d.warned)
+	default:
+		successColor.Println("✅ All checks passed")
+	}
+}
+
+// checkRepoRoot checks that the current directory is the root of a git
+// checkout, or holds its modules: state paths, affected-modules.sh and the
+// config are looked up from there.
+func (d *doctor) checkRepoRoot() {
+	if _, err := exec.LookPath("git"); err != nil {
+		d.fail("git", "not found in PATH", "install git; automatic mode, history and the report's revisions use it")
+		return
+	}
+	out, err := commandOutput(exec.Command("git", "rev-parse", "--show-toplevel"))
+	if err != nil {
+		d.fail("repository root", "not inside a git checkout", "run from the root of the infrastructure repository")
+		return
+	}
+	root := strings.TrimSpace(string(out))
+	cwd, _ := os.Getwd()
+	switch {
+	case sameDir(cwd, root):
+		d.pass("repository root", root)
+	case isRepoRoot(cwd):
+		// Modules below the root of the checkout, e.g. in a monorepo
+		d.pass("repository root", cwd)
+	default:
+		d.warn("repository root", fmt.Sprintf("running from %s, below the root %s", cwd, root), fmt.Sprintf("cd %s, or pass --chdir", shellQuote(root)))
+	}
+}
+
+func sameDir(a, b string) bool {
+	a, errA := filepath.EvalSymlinks(a)
+	b, errB := filepath.EvalSymlinks(b)
+	return errA == nil && errB == nil && a == b
+}
+
+// runnerBinaries lists the binaries a runner needs: its own, and the
+// terragrunt and terraform binaries the kitman and terragrunt runners
+// drive.
+func runnerBinaries(r *RunnerConfig) []string {
+	binaries := []string{r.Binary}
+	switch r.Name {
+	case "kitman":
+		binaries = append(binaries, "terragrunt", "terraform")
+	case "terragrunt":
+		binaries = append(binaries, "terraform")
+	}
+	return binaries
+}
+
+// checkBinary checks that a binary is in PATH, reporting its version.
+func (d *doctor) checkBinary(binary string) {
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		d.fail(binary, "not found in PATH", fmt.Sprintf("install %s, or pick another runner with --runner or runner.name", binary))
+		return
+	}
+	version := binaryVersion(binary)
+	if version == "" {
+		d.warn(binary, fmt.Sprintf("%s, version unknown", path), fmt.Sprintf("check that `%s --version` works", binary))
+		return
+	}
+	d.pass(binary, fmt.Sprintf("%s (%s)", version, path))
+}
+
+// binaryVersion is the first line of a binary's version output, "" when
+// it has none.
+func binaryVersion(binary string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	arg := "--version"
+	if filepath.Base(binary) == "terraform" {
+		// -version also works, but prints an upgrade notice on stderr
+		arg = "version"
+	}
+	cmd := exec.CommandContext(ctx, binary, arg)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// checkAffectedScript checks for the affected-modules.sh targeted runs
+// need, failing without it only for targeted runs.
+func (d *doctor) checkAffectedScript(targeted bool) {
+	info, err := os.Stat("affected-modules.sh")
+	switch {
+	case err != nil && targeted:
+		d.fail("affected-modules.sh", "not found in the current directory", "run from the repository root, or drop --targeted")
+	case err != nil:
+		d.warn("affected-modules.sh", "not found in the current directory; only targeted runs need it", "")
+	case info.Mode()&0111 == 0:
+		d.fail("affected-modules.sh", "not executable", "chmod +x affected-modules.sh")
+	default:
+		d.pass("affected-modules.sh", "found")
+	}
+}
+
+// checkCredentials checks the AWS credentials of every partition, and
+// those aws_credentials picks for its environments.
+func (d *doctor) checkCredentials(cfg *Config) {
+	if _, err := exec.LookPath("aws"); err != nil {
+		d.warn("AWS credentials", "can't check them, the aws CLI isn't in PATH", "install the AWS CLI v2")
+		return
+	}
+	ctx := context.Background()
+	for _, p := range cfg.Partitions {
+		for _, cred := range cfg.credentialSources(p) {
+			name := p.Label + " credentials"
+			if cred != nil {
+				name = fmt.Sprintf("%s credentials (%s)", p.Label, credentialsScope(cred))
+			}
+			var env []string
+			switch {
+			case cred == nil:
+			case cred.RoleARN != "":
+				var err error
+				if env, err = assumeRole(ctx, p, cred); err != nil {
+					d.fail(name, err.Error(), "check that the role's trust policy lets your credentials assume it")
+					continue
+				}
+			default:
+				env = []string{"AWS_PROFILE=" + cred.Profile}
+			}
+			identity, err := checkAWSCredentials(ctx, p, cred, env)
+			if err != nil {
+				d.fail(name, err.Error(), "")
+				continue
+			}
+			d.pass(name, fmt.Sprintf("%s (account %s)", identity.Arn, identity.Account))
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// driftExitCode is the exit status of --expect-no-changes runs that found
+// changes, distinct from 1 for runs that failed.
+const driftExitCode = 2
+
+// maxDriftResources caps the resources listed per region in drift reports.
+const maxDriftResources = 10
+
+// driftedRegion is a region plan that isn't empty.
+type driftedRegion struct {
+	Partition, Env, Region string
+	Counts                 PlanCounts
+	Incomplete             bool
+	Resources              []string // "address action"
+}
+
+// errDrift is returned by Run when --expect-no-changes found changes.
+type errDrift struct{ regions int }
+
+func (e *errDrift) Error() string {
+	return fmt.Sprintf("drift detected in %d region(s)", e.regions)
+}
+
+// findDrift lists the region plans with changes, or that are incomplete
+// and so can't be shown to be clean.
+func findDrift(results []*PartitionResult) []*driftedRegion {
+	var drifted []*driftedRegion
+	for _, result := range results {
+		for _, env := range result.Environments {
+			for _, region := range env.Regions {
+				body := env.Plans[region]
+				counts, _ := ParsePlanCounts(body)
+				incomplete := env.Incomplete[region]
+				if !incomplete && counts.Add+counts.Change+counts.Destroy == 0 {
+					continue
+				}
+				d := &driftedRegion{Partition: result.Partition.Name, Env: env.Name, Region: region, Counts: counts, Incomplete: incomplete}
+				for _, line := range strings.Split(body, "\n") {
+					if m := resourceChangeRegex.FindStringSubmatch(line); m != nil {
+						d.Resources = append(d.Resources, m[1]+" "+m[2])
+					}
+				}
+				drifted = append(drifted, d)
+			}
+		}
+	}
+	return drifted
+}
+
+// checkDrift prints a concise drift report and returns errDrift if any
+// region plan changes something.
+func (pg *PlanGenerator) checkDrift() error {
+	if !pg.ExpectNoChanges {
+		return nil
+	}
+	if len(pg.drift) == 0 {
+		successColor.Println("✅ No drift: every plan is empty")
+		return nil
+	}
+	warningColor.Printf("🌊 Drift in %d region(s):\n", len(pg.drift))
+	for _, d := range pg.drift {
+		status := fmt.Sprintf("+%d ~%d -%d", d.Counts.Add, d.Counts.Change, d.Counts.Destroy)
+		if d.Incomplete {
+			status = "plan incomplete"
+		}
+		fmt.Fprintf(console, "  %s/%s/%s: %s\n", d.Partition, d.Env, d.Region, status)
+		for i, resource := range d.Resources {
+			if i == maxDriftResources {
+				fmt.Fprintf(console, "    … and %d more\n", len(d.Resources)-maxDriftResources)
+				break
+			}
+			fmt.Fprintf(console, "    - %s\n", resource)
+		}
+	}
+	return &errDrift{regions: len(pg.drift)}
+}
+
+// writeDriftNote states the outcome of a drift check at the top of the
+// report.
+func (pg *PlanGenerator) writeDriftNote(output *os.File) {
+	if !pg.ExpectNoChanges {
+		return
+	}
+	if len(pg.drift) == 0 {
+		output.WriteString(fmt.Sprintf("> %sDrift check: no changes in any region.\n\n", pg.renderer().Icon("✅")))
+		return
+	}
+	var regions []string
+	for _, d := range pg.drift {
+		regions = append(regions, d.Env+"/"+d.Region)
+	}
+	output.WriteString(fmt.Sprintf("> %s**Drift check failed**: %d region(s) show changes: %s\n\n", pg.renderer().Icon("🌊"), len(pg.drift), strings.Join(regions, ", ")))
+}
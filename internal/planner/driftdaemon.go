@@ -0,0 +1,315 @@
+package planner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// slackWebhookEnv overrides drift.slack_webhook, keeping the secret out of
+// the config file.
+const slackWebhookEnv = "TFPRGEN_SLACK_WEBHOOK"
+
+// driftIssueLabel marks the issues the drift daemon opens and closes.
+const driftIssueLabel = "drift"
+
+// DriftConfig configures the drift subcommand.
+type DriftConfig struct {
+	// Schedule is a cron expression, e.g. "0 6 * * 1-5".
+	Schedule string `yaml:"schedule"`
+	// Modules are planned on every check.
+	Modules []string `yaml:"modules"`
+	// SlackWebhook is an incoming webhook URL notified of drift.
+	SlackWebhook string `yaml:"slack_webhook"`
+	// GitHubIssues keeps one issue per drifted module open in
+	// GITHUB_REPOSITORY, closing it once the drift is gone.
+	GitHubIssues bool `yaml:"github_issues"`
+}
+
+func newDriftCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drift [module_name...]",
+		Short: "Plan modules on a schedule and report drift",
+		Long: `Runs full plans of the configured modules on a cron schedule, like
+--expect-no-changes, recording every run in the history database. When a
+module's drift appears or changes, a Slack webhook and/or a GitHub issue per
+module are notified; the issue is closed once the module is clean again.
+
+Configure it in .tfprgen.yaml:
+
+  drift:
+    schedule: "0 6 * * 1-5"
+    modules: [s3_malware_protection, vpc]
+    slack_webhook: https://hooks.slack.com/services/...  # or TFPRGEN_SLACK_WEBHOOK
+    github_issues: true  # needs GITHUB_TOKEN and GITHUB_REPOSITORY
+
+Modules given as arguments replace the configured ones. Run it from the repo
+root and keep the checkout current (e.g. a git pull in a pre_run hook).
+
+Examples:
+  terraform-pr-generator drift
+  terraform-pr-generator drift s3_malware_protection --schedule "*/30 * * * *"
+  terraform-pr-generator drift --once`,
+		ValidArgsFunction: completeModules(0),
+		Run:               runDrift,
+		Annotations:       map[string]string{findsRepoRoot: "true"},
+	}
+	flags := cmd.Flags()
+	flags.String("schedule", "", "Cron expression (default: drift.schedule from the config)")
+	flags.Bool("once", false, "Check once now and exit, with status 2 if any module drifted")
+	flags.StringP("config", "c", "", "Path to a YAML config file (default: .tfprgen.yaml in the repo root)")
+	addVerboseFlag(flags)
+	flags.String("runner", "", "Built-in runner to plan with: kitman, terragrunt or terraform (default: from config, else kitman)")
+	flags.String("select", "", "Only plan states matching a selector expression")
+	flags.StringArray("var-file", nil, "tfvars file passed as -var-file to every plan (repeatable)")
+	flags.Bool("archive", false, "Pack each run's output directory into a .tar.gz next to it")
+	return cmd
+}
+
+func runDrift(cmd *cobra.Command, args []string) {
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = FindConfigFile(".")
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	modules := cfg.Drift.Modules
+	if len(args) > 0 {
+		modules = args
+	}
+	if len(modules) == 0 {
+		errorColor.Println("❌ Error: no modules to check: pass them as arguments or set drift.modules")
+		os.Exit(1)
+	}
+	once, _ := cmd.Flags().GetBool("once")
+	spec := cfg.Drift.Schedule
+	if cmd.Flags().Changed("schedule") {
+		spec, _ = cmd.Flags().GetString("schedule")
+	}
+	var schedule *cronSchedule
+	if !once {
+		if spec == "" {
+			errorColor.Println("❌ Error: no schedule: pass --schedule, set drift.schedule or use --once")
+			os.Exit(1)
+		}
+		if schedule, err = parseCron(spec); err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	notifier, err := newDriftNotifier(cfg.Drift)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	d := &driftDaemon{cmd: cmd, configPath: configPath, modules: modules, notifier: notifier, last: make(map[string]string)}
+	if once {
+		if d.check() {
+			os.Exit(driftExitCode)
+		}
+		return
+	}
+
+	// A signal lets the current check finish before exiting
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	infoColor.Printf("🌊 Watching %d module(s) for drift on %q\n", len(modules), spec)
+	for {
+		next := schedule.next(time.Now())
+		fmt.Fprintf(console, "⏰ Next check at %s\n", next.Format("2006-01-02 15:04 MST"))
+		select {
+		case <-time.After(time.Until(next)):
+		case <-stop:
+			fmt.Fprintln(console, "Stopped")
+			return
+		}
+		d.check()
+	}
+}
+
+// driftDaemon checks modules for drift and notifies when it changes.
+type driftDaemon struct {
+	cmd        *cobra.Command
+	configPath string
+	modules    []string
+	notifier   *driftNotifier
+	// last is each module's drift at its previous check, "" if it was
+	// clean, so unchanged drift isn't notified again.
+	last map[string]string
+}
+
+// check plans every module once and reports whether any drifted. Runs that
+// fail are logged and otherwise skipped: they say nothing about drift.
+func (d *driftDaemon) check() bool {
+	drifted := false
+	for _, module := range d.modules {
+		// Configs are reloaded so a pulled checkout's changes apply
+		pg, err := newPlanGenerator(d.cmd, module, d.configPath)
+		if err != nil {
+			errorColor.Printf("❌ %s: %v\n", module, err)
+			continue
+		}
+		pg.Targeted = false
+		pg.AutoMode = false
+		pg.ExpectNoChanges = true
+		pg.History = true
+
+		err = pg.Run()
+		if _, ok := err.(*errDrift); err != nil && !ok {
+			errorColor.Printf("❌ %s: drift check failed: %v\n", module, err)
+			continue
+		}
+		drifted = drifted || len(pg.drift) > 0
+
+		signature := driftSignature(pg.drift)
+		previous, seen := d.last[module]
+		d.last[module] = signature
+		switch {
+		case signature == previous && seen:
+		case signature != "":
+			d.notifier.drifted(module, pg.drift, filepath.Join(pg.OutputDir, "pr-ready.md"))
+		case seen:
+			d.notifier.resolved(module)
+		default:
+			// Clean on the first check: an issue left open before a
+			// restart is stale
+			d.notifier.closeIssue(module)
+		}
+	}
+	return drifted
+}
+
+// driftSignature identifies a module's drift: the regions and resources
+// changed, "" for none.
+func driftSignature(drift []*driftedRegion) string {
+	var lines []string
+	for _, r := range drift {
+		lines = append(lines, fmt.Sprintf("%s/%s/%s %v %v %s", r.Partition, r.Env, r.Region, r.Counts, r.Incomplete, strings.Join(r.Resources, ",")))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// driftNotifier posts drift to Slack and GitHub issues. Failures only warn,
+// so a notification outage doesn't stop the daemon.
+type driftNotifier struct {
+	slack  string
+	github *githubClient
+	http   *http.Client
+}
+
+func newDriftNotifier(cfg DriftConfig) (*driftNotifier, error) {
+	n := &driftNotifier{slack: cfg.SlackWebhook, http: &http.Client{Timeout: 30 * time.Second}}
+	if webhook := os.Getenv(slackWebhookEnv); webhook != "" {
+		n.slack = webhook
+	}
+	if cfg.GitHubIssues {
+		client, err := newGitHubClient()
+		if err != nil {
+			return nil, fmt.Errorf("drift.github_issues: %v", err)
+		}
+		n.github = client
+	}
+	return n, nil
+}
+
+// drifted notifies of a module's new or changed drift.
+func (n *driftNotifier) drifted(module string, drift []*driftedRegion, reportPath string) {
+	var summary strings.Builder
+	for _, r := range drift {
+		status := fmt.Sprintf("+%d ~%d -%d", r.Counts.Add, r.Counts.Change, r.Counts.Destroy)
+		if r.Incomplete {
+			status = "plan incomplete"
+		}
+		fmt.Fprintf(&summary, "- `%s/%s/%s`: %s\n", r.Partition, r.Env, r.Region, status)
+	}
+
+	if n.slack != "" {
+		text := fmt.Sprintf("🌊 Drift in *%s* (%d region(s)):\n%s", module, len(drift), summary.String())
+		if err := n.postSlack(text); err != nil {
+			warningColor.Printf("⚠️  Couldn't notify Slack: %v\n", err)
+		}
+	}
+	if n.github == nil {
+		return
+	}
+	body := fmt.Sprintf("🌊 Drift detected in `%s` at %s in %d region(s):\n\n%s", module, time.Now().Format("2006-01-02 15:04 MST"), len(drift), summary.String())
+	if report, err := os.ReadFile(reportPath); err == nil && len(body)+len(report) < githubCommentLimit {
+		body += "\n" + string(report)
+	}
+	title := driftIssueTitle(module)
+	issue, err := n.github.findOpenIssue(driftIssueLabel, title)
+	if err == nil && issue == nil {
+		issue, err = n.github.createIssue(title, body, []string{driftIssueLabel})
+		if err == nil {
+			infoColor.Printf("📝 Opened drift issue %s\n", issue.URL)
+		}
+	} else if err == nil {
+		_, err = n.github.createComment(issue.Number, body)
+	}
+	if err != nil {
+		warningColor.Printf("⚠️  Couldn't update the drift issue: %v\n", err)
+	}
+}
+
+// resolved notifies that a drifted module is clean again and closes its
+// issue.
+func (n *driftNotifier) resolved(module string) {
+	if n.slack != "" {
+		if err := n.postSlack(fmt.Sprintf("✅ Drift in *%s* is resolved", module)); err != nil {
+			warningColor.Printf("⚠️  Couldn't notify Slack: %v\n", err)
+		}
+	}
+	n.closeIssue(module)
+}
+
+// closeIssue closes the module's drift issue, if open.
+func (n *driftNotifier) closeIssue(module string) {
+	if n.github == nil {
+		return
+	}
+	issue, err := n.github.findOpenIssue(driftIssueLabel, driftIssueTitle(module))
+	if err == nil && issue != nil {
+		if _, err = n.github.createComment(issue.Number, "✅ No drift anymore: every plan is empty."); err == nil {
+			err = n.github.closeIssue(issue.Number)
+		}
+	}
+	if err != nil {
+		warningColor.Printf("⚠️  Couldn't close the drift issue: %v\n", err)
+	}
+}
+
+func driftIssueTitle(module string) string {
+	return fmt.Sprintf("Drift detected in %s", module)
+}
+
+func (n *driftNotifier) postSlack(text string) error {
+	data, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	resp, err := n.http.Post(n.slack, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package planner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDriftPlansWithPlanFlagDefaults(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".tfprgen.yaml")
+	os.WriteFile(configPath, []byte("upload: s3://plans-bucket/reports\n"), 0644)
+
+	// drift doesn't define most plan flags, e.g. --upload-expires
+	pg, err := newPlanGenerator(newDriftCmd(), "vpc", configPath)
+	if err != nil {
+		t.Fatalf("newPlanGenerator: %v", err)
+	}
+	if pg.UploadExpires != maxUploadExpiry || pg.MaxSectionBytes != 30000 || pg.Slowest != 5 {
+		t.Errorf("upload expiry %v, max section bytes %d and slowest %d, want the plan flags' defaults", pg.UploadExpires, pg.MaxSectionBytes, pg.Slowest)
+	}
+}
@@ -0,0 +1,200 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// listedCommand is one command a run would start, as listed by --dry-run and
+// --emit-script.
+type listedCommand struct {
+	Group string // partition label, or "--init"
+	State string // state it plans or initializes; empty for plan_all
+	Line  string // shell command line, or "" for a skipped state
+}
+
+// commandsOnly tells whether the run only lists its commands (--dry-run,
+// --emit-script) rather than running them.
+func (pg *PlanGenerator) commandsOnly() bool {
+	return pg.DryRun || pg.EmitScript != ""
+}
+
+// listCommands prints or writes the commands the run would start instead
+// of running them.
+func (pg *PlanGenerator) listCommands(targeted bool, states []*State) error {
+	commands, err := pg.plannedCommands(targeted, states)
+	if err != nil {
+		return err
+	}
+	if pg.DryRun {
+		printDryRun(commands)
+	}
+	if pg.EmitScript != "" {
+		if err := pg.writeScript(commands); err != nil {
+			return err
+		}
+	}
+
+	plans := 0
+	for _, c := range commands {
+		if c.Line != "" && c.Group != "--init" {
+			plans++
+		}
+	}
+	var verbs []string
+	if pg.DryRun {
+		verbs = append(verbs, "Dry run")
+	}
+	if pg.EmitScript != "" {
+		verbs = append(verbs, "Wrote "+pg.EmitScript)
+	}
+	verb := strings.Join(verbs, "; ")
+	if targeted {
+		infoColor.Printf("🧪 %s: %d plan command(s) for %d state(s); nothing was run\n", verb, plans, len(states))
+	} else {
+		infoColor.Printf("🧪 %s: %d plan_all command(s), one per partition; nothing was run\n", verb, plans)
+	}
+	return nil
+}
+
+// plannedCommands lists the commands a run would start, grouped by
+// partition: in targeted runs one plan per state, after targeting and
+// --select, behind the init commands of --init; in full runs one plan_all
+// per partition.
+func (pg *PlanGenerator) plannedCommands(targeted bool, states []*State) ([]listedCommand, error) {
+	var commands []listedCommand
+	if !targeted {
+		for _, p := range pg.Config.Partitions {
+			argv, err := pg.Config.Runner.PlanAllCommand(p, pg.ModuleName, pg.planArgs())
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, listedCommand{Group: p.Label, Line: commandLine(argv, nil)})
+		}
+		return commands, nil
+	}
+
+	if pg.Init {
+		if pg.Config.Runner.Init == "" {
+			return nil, fmt.Errorf("--init: runner.init isn't set for the %s runner", pg.Config.Runner.Name)
+		}
+		seenDir := make(map[string]bool)
+		for _, state := range states {
+			p := pg.Config.PartitionFor(state.String())
+			if p == nil || seenDir[state.Path] {
+				continue
+			}
+			seenDir[state.Path] = true
+			argv, err := pg.Config.Runner.InitCommand(p, pg.ModuleName, state.Path)
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, listedCommand{Group: "--init", State: state.Path, Line: commandLine(argv, nil)})
+		}
+	}
+	for _, p := range pg.Config.Partitions {
+		for _, state := range states {
+			if pg.Config.PartitionFor(state.String()) != p {
+				continue
+			}
+			argv, err := pg.stateCommand(p, state)
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, listedCommand{Group: p.Label, State: state.String(), Line: commandLine(argv, pg.commandEnv(state))})
+		}
+	}
+	for _, state := range states {
+		if pg.Config.PartitionFor(state.String()) == nil {
+			commands = append(commands, listedCommand{State: state.String()})
+		}
+	}
+	return commands, nil
+}
+
+// printDryRun prints commands to stdout, one per line under a comment
+// naming their partition (--dry-run).
+func printDryRun(commands []listedCommand) {
+	group := ""
+	for _, c := range commands {
+		if c.Line == "" {
+			fmt.Printf("# %s: no partition matches it, skipped\n", c.State)
+			continue
+		}
+		if c.Group != group {
+			group = c.Group
+			fmt.Printf("# %s\n", group)
+		}
+		fmt.Println(c.Line)
+	}
+}
+
+// writeScript writes commands to the --emit-script shell script. It runs
+// from the directory the run was started in, or $PLAN_DIR, and carries on
+// past failed commands, exiting non-zero if any failed, so each state's
+// commands can also be copied out and run on their own.
+func (pg *PlanGenerator) writeScript(commands []listedCommand) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("--emit-script: %v", err)
+	}
+	var b strings.Builder
+	b.WriteString("#!/usr/bin/env bash\n")
+	fmt.Fprintf(&b, "# Plan commands of terraform-pr-generator %s, written %s.\n", strings.Join(shellQuoteAll(os.Args[1:]), " "), time.Now().Format(time.RFC3339))
+	b.WriteString("# Set PLAN_DIR to run them from another checkout.\n")
+	b.WriteString("set -uo pipefail\n\n")
+	fmt.Fprintf(&b, "cd \"${PLAN_DIR:-%s}\" || exit 1\n", strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(cwd))
+	b.WriteString("status=0\n")
+	if pg.SavePlans {
+		// Where the plans' -out files go
+		dir, _ := filepath.Abs(filepath.Join(pg.OutputDir, "tfplans"))
+		fmt.Fprintf(&b, "mkdir -p %s\n", shellQuote(dir))
+	}
+	group := ""
+	for _, c := range commands {
+		if c.Line == "" {
+			fmt.Fprintf(&b, "\n# %s: no partition matches it, skipped\n", c.State)
+			continue
+		}
+		if c.Group != group {
+			group = c.Group
+			fmt.Fprintf(&b, "\n# === %s ===\n", group)
+		}
+		if c.State != "" {
+			fmt.Fprintf(&b, "# %s\n", c.State)
+		}
+		if c.Group == "--init" {
+			// Plans of an uninitialized state can only fail
+			fmt.Fprintf(&b, "%s || exit 1\n", c.Line)
+		} else {
+			fmt.Fprintf(&b, "%s || status=1\n", c.Line)
+		}
+	}
+	b.WriteString("\nexit $status\n")
+	if err := os.WriteFile(pg.EmitScript, []byte(b.String()), 0755); err != nil {
+		return fmt.Errorf("--emit-script: %v", err)
+	}
+	return nil
+}
+
+// commandLine renders argv as a shell command line, prefixed with the
+// variables env sets over the inherited environment.
+func commandLine(argv []string, env []string) string {
+	var words []string
+	for _, kv := range envOverrides(env) {
+		name, value, _ := strings.Cut(kv, "=")
+		words = append(words, name+"="+shellQuote(value))
+	}
+	return strings.Join(append(words, shellQuoteAll(argv)...), " ")
+}
+
+func shellQuoteAll(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return quoted
+}
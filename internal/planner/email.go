@@ -0,0 +1,155 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/backendken/terraform-pr-generator/pkg/render"
+)
+
+// EmailConfig is where --email sends the report, e.g. to a change advisory
+// board notified by email rather than on GitHub.
+type EmailConfig struct {
+	// Enabled emails the report of every completed run, like --email.
+	Enabled bool `yaml:"enabled"`
+	// SMTP is the server's host:port, e.g. smtp.example.com:587. TLS is
+	// negotiated with STARTTLS when the server offers it, and
+	// SMTP_USERNAME and SMTP_PASSWORD authenticate.
+	SMTP string `yaml:"smtp"`
+	From string `yaml:"from"`
+	// To are the recipients, e.g. a distribution list.
+	To []string `yaml:"to"`
+}
+
+func (c EmailConfig) validate() error {
+	if c.SMTP != "" {
+		if _, _, err := net.SplitHostPort(c.SMTP); err != nil {
+			return fmt.Errorf("smtp must be host:port, got %q", c.SMTP)
+		}
+	}
+	if c.From != "" {
+		if _, err := mail.ParseAddress(c.From); err != nil {
+			return fmt.Errorf("from: %v", err)
+		}
+	}
+	for _, to := range c.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("to: %q: %v", to, err)
+		}
+	}
+	return nil
+}
+
+// ready tells what's missing to send email, nil if nothing is.
+func (c EmailConfig) ready() error {
+	var missing []string
+	if c.SMTP == "" {
+		missing = append(missing, "email.smtp")
+	}
+	if c.From == "" {
+		missing = append(missing, "email.from")
+	}
+	if len(c.To) == 0 {
+		missing = append(missing, "email.to")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the config doesn't set %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// emailReport sends the report to email.to: the markdown as the plain text
+// part, rendered like --open as the HTML one.
+func (pg *PlanGenerator) emailReport(results []*PartitionResult, markdownPath string) error {
+	report, err := os.ReadFile(markdownPath)
+	if err != nil {
+		return err
+	}
+	cfg := pg.Config.Email
+	from, _ := mail.ParseAddress(cfg.From) // checked by validate
+	var to, headerTo []string
+	for _, recipient := range cfg.To {
+		address, _ := mail.ParseAddress(recipient)
+		to = append(to, address.Address)
+		headerTo = append(headerTo, address.String())
+	}
+	msg, err := emailMessage(from.String(), headerTo, pg.emailSubject(results), string(report), render.HTML("Terraform plan: "+pg.ModuleName, string(report)))
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		host, _, _ := net.SplitHostPort(cfg.SMTP)
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	if err := smtp.SendMail(cfg.SMTP, auth, from.Address, to, msg); err != nil {
+		return fmt.Errorf("sending through %s: %v", cfg.SMTP, err)
+	}
+	successColor.Printf("📧 Emailed the report to %s\n", strings.Join(cfg.To, ", "))
+	return nil
+}
+
+// emailSubject gives the module and the plan totals, so the plans can be
+// triaged from the inbox.
+func (pg *PlanGenerator) emailSubject(results []*PartitionResult) string {
+	total, incomplete := planTotals(results)
+	subject := fmt.Sprintf("Terraform plan: %s: %d to add, %d to change, %d to destroy", pg.ModuleName, total.Add, total.Change, total.Destroy)
+	if pg.Destroy {
+		subject = "[DESTROY PLAN] " + subject
+	}
+	if n := len(pg.failures); n > 0 {
+		subject += fmt.Sprintf(", %d failed", n)
+	} else if incomplete {
+		subject += ", incomplete"
+	}
+	return subject
+}
+
+// emailMessage is a multipart/alternative message with text and html
+// bodies, quoted-printable so long plan lines stay within SMTP's limits.
+func emailMessage(from string, to []string, subject, text, html string) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,51 @@
+package planner
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestEmailMessage(t *testing.T) {
+	long := strings.Repeat("x", 2000)
+	raw, err := emailMessage("Planner <planner@example.com>", []string{"cab@example.com"}, "Terraform plan: s3 — 1 to add", "# Plan\n"+long, "<h1>Plan</h1>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != "Terraform plan: s3 — 1 to add" {
+		t.Errorf("Subject = %q", subject)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part) // quoted-printable is decoded by the reader
+		bodies = append(bodies, part.Header.Get("Content-Type")+": "+strings.ReplaceAll(string(body), "\r\n", "\n"))
+	}
+	if len(bodies) != 2 || bodies[0] != "text/plain; charset=utf-8: # Plan\n"+long || bodies[1] != "text/html; charset=utf-8: <h1>Plan</h1>" {
+		t.Errorf("parts = %q", bodies)
+	}
+	for _, line := range strings.Split(string(raw), "\r\n") {
+		if len(line) > 998 {
+			t.Fatalf("line of %d bytes exceeds SMTP's limit", len(line))
+		}
+	}
+}
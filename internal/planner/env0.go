@@ -0,0 +1,238 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEnv0APIURL = "https://api.env0.com"
+	env0AppURL        = "https://app.env0.com"
+)
+
+// Env0Config plans the targeted states of env0 environments in plan
+// deployments of the commit being planned, which has to be pushed.
+type Env0Config struct {
+	// APIURL is env0's API; https://api.env0.com by default.
+	APIURL         string `yaml:"api_url"`
+	OrganizationID string `yaml:"organization_id"`
+	// Environments map states to environment names; the first matching a
+	// state plans it.
+	Environments []*RemoteStack `yaml:"environments"`
+}
+
+func (c Env0Config) validate() error {
+	if len(c.Environments) > 0 && c.OrganizationID == "" {
+		return fmt.Errorf("organization_id is required")
+	}
+	return validateRemoteStacks("environments", c.Environments)
+}
+
+// env0Done are the statuses a plan deployment ends in; WAITING_FOR_USER is
+// a finished plan awaiting approval to apply.
+var env0Done = []string{"SUCCESS", "WAITING_FOR_USER", "FAILURE", "CANCELLED", "TIMEOUT", "ABORTED", "INTERNAL_FAILURE"}
+
+type env0Environment struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ProjectID string `json:"projectId"`
+}
+
+// env0Client is the slice of env0's API plan deployments need.
+type env0Client struct {
+	apiURL string
+	auth   string
+	org    string
+	http   *http.Client
+}
+
+// env0Client is the run's env0 client, created on first use.
+func (pg *PlanGenerator) env0Client() (*env0Client, error) {
+	pg.remoteMu.Lock()
+	defer pg.remoteMu.Unlock()
+	if pg.env0 == nil {
+		client, err := newEnv0Client(pg.Config.Env0)
+		if err != nil {
+			return nil, err
+		}
+		pg.env0 = client
+	}
+	return pg.env0, nil
+}
+
+// newEnv0Client authenticates with the API key in ENV0_API_KEY and
+// ENV0_API_SECRET.
+func newEnv0Client(cfg Env0Config) (*env0Client, error) {
+	key, secret := os.Getenv("ENV0_API_KEY"), os.Getenv("ENV0_API_SECRET")
+	if key == "" || secret == "" {
+		return nil, fmt.Errorf("ENV0_API_KEY and ENV0_API_SECRET are not set")
+	}
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = defaultEnv0APIURL
+	}
+	return &env0Client{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		auth:   "Basic " + base64.StdEncoding.EncodeToString([]byte(key+":"+secret)),
+		org:    cfg.OrganizationID,
+		http:   &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// do sends a request with payload, if any, encoded as JSON and decodes the
+// response into result.
+func (c *env0Client) do(method, path string, payload, result any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", c.auth)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if result == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("%s %s: unexpected response: %.200s", method, path, data)
+	}
+	return nil
+}
+
+// planEnv0 plans state in a plan deployment of the environment named name
+// on the current commit and returns its plan step's log as the state's
+// output. Deployments are cancelled with the state, and once their plan
+// awaits approval.
+func (pg *PlanGenerator) planEnv0(ctx context.Context, state *State, name string) ([]byte, error) {
+	client, err := pg.env0Client()
+	if err != nil {
+		return nil, err
+	}
+	var environments []*env0Environment
+	if err := client.do("GET", "/environments?organizationId="+url.QueryEscape(client.org)+"&name="+url.QueryEscape(name), nil, &environments); err != nil {
+		return nil, fmt.Errorf("environment %s: %v", name, err)
+	}
+	i := slices.IndexFunc(environments, func(e *env0Environment) bool { return e.Name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("environment %s not found", name)
+	}
+	env := environments[i]
+	commit := gitHead()
+	if commit == "" {
+		return nil, fmt.Errorf("environment %s: the commit to plan is unknown outside a git repository", name)
+	}
+
+	var deployment struct {
+		ID string `json:"id"`
+	}
+	if err := client.do("POST", "/environments/"+env.ID+"/deployments", map[string]any{
+		"deploymentType":    "prPlan",
+		"blueprintRevision": commit,
+	}, &deployment); err != nil {
+		return nil, fmt.Errorf("environment %s: starting a plan deployment: %v", name, err)
+	}
+	deploymentURL := fmt.Sprintf("%s/p/%s/environments/%s/deployments/%s", env0AppURL, env.ProjectID, env.ID, deployment.ID)
+	pg.recordRemoteRun(state, "env0", deploymentURL)
+
+	var status string
+	err = waitRemote(ctx, func() (bool, error) {
+		var result struct {
+			Status string `json:"status"`
+		}
+		if err := client.do("GET", "/environments/deployments/"+deployment.ID, nil, &result); err != nil {
+			return false, err
+		}
+		status = result.Status
+		return slices.Contains(env0Done, status), nil
+	})
+	if ctx.Err() != nil || status == "WAITING_FOR_USER" {
+		client.do("PUT", "/environments/deployments/"+deployment.ID+"/cancel", nil, nil)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("deployment %s: %v", deploymentURL, err)
+	}
+	log, err := client.planLog(deployment.ID)
+	if err != nil {
+		return nil, fmt.Errorf("deployment %s: reading the plan log: %v", deploymentURL, err)
+	}
+	output := remotePlanOutput(log)
+	if status != "SUCCESS" && status != "WAITING_FOR_USER" {
+		return nil, pg.commandError(fmt.Errorf("deployment %s: %s", deploymentURL, strings.ToLower(status)), state.String(), output)
+	}
+	return output, nil
+}
+
+// planLog reads the log of a deployment's plan step, page by page.
+func (c *env0Client) planLog(deployment string) ([]byte, error) {
+	var steps []struct {
+		Name string `json:"name"`
+	}
+	if err := c.do("GET", "/deployments/"+deployment+"/steps", nil, &steps); err != nil {
+		return nil, err
+	}
+	step := ""
+	for _, s := range steps {
+		if strings.Contains(strings.ToLower(s.Name), "plan") {
+			step = s.Name
+		}
+	}
+	if step == "" {
+		return nil, fmt.Errorf("the deployment has no plan step")
+	}
+	var b bytes.Buffer
+	path := "/deployments/" + deployment + "/steps/" + url.PathEscape(step) + "/log"
+	query := ""
+	for {
+		var page struct {
+			Events []struct {
+				Message string `json:"message"`
+			} `json:"events"`
+			HasMoreLogs   bool  `json:"hasMoreLogs"`
+			NextStartTime int64 `json:"nextStartTime"`
+		}
+		if err := c.do("GET", path+query, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, e := range page.Events {
+			b.WriteString(strings.TrimSuffix(e.Message, "\n") + "\n")
+		}
+		if !page.HasMoreLogs || len(page.Events) == 0 {
+			return b.Bytes(), nil
+		}
+		query = fmt.Sprintf("?startTime=%d", page.NextStartTime)
+	}
+}
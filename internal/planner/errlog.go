@@ -0,0 +1,58 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errorLogDir is the output subdirectory keeping the stderr of failed plan
+// commands: one log per targeted state, or per partition for full runs.
+const errorLogDir = "errors"
+
+// stderrTailLines is how much of a failed command's stderr its error shows.
+const stderrTailLines = 10
+
+var logNameReplacer = strings.NewReplacer("/", "_", "\\", "_", ":", "_", "@", "_")
+
+// commandError adds the tail of a failed command's stderr to err and writes
+// all of it to errors/<name>.log in the output directory. Failures on a
+// held state lock say who holds it; those for lack of init are flagged for
+// --auto-init.
+func (pg *PlanGenerator) commandError(err error, name string, stderr []byte) (failure error) {
+	if lock := parseStateLock(stderr); lock != nil {
+		defer func() { failure = &stateLockError{Lock: lock, Err: failure} }()
+	} else if initRequiredRegex.Match(stderr) {
+		defer func() { failure = &initRequiredError{Err: failure} }()
+	} else if throttledRegex.Match(stderr) {
+		defer func() { failure = &throttledError{Err: failure} }()
+	}
+	// Local plans' stderr is masked already, remote runs pass their log
+	stderr, _ = pg.maskBytes(stderr)
+	text := strings.TrimRight(string(stderr), "\n")
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("%v (no stderr)", err)
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) > stderrTailLines {
+		lines = lines[len(lines)-stderrTailLines:]
+	}
+	tail := "    " + strings.Join(lines, "\n    ")
+
+	dir := filepath.Join(pg.OutputDir, errorLogDir)
+	path := pg.errorLogPath(name)
+	if mkErr := os.MkdirAll(dir, 0755); mkErr != nil {
+		return fmt.Errorf("%v, stderr:\n%s", err, tail)
+	}
+	if writeErr := os.WriteFile(path, stderr, 0644); writeErr != nil {
+		return fmt.Errorf("%v, stderr:\n%s", err, tail)
+	}
+	return fmt.Errorf("%v, stderr (full log: %s):\n%s", err, path, tail)
+}
+
+// errorLogPath is where the stderr of the failed command for name, a
+// targeted state or a full run partition, is kept.
+func (pg *PlanGenerator) errorLogPath(name string) string {
+	return filepath.Join(pg.OutputDir, errorLogDir, logNameReplacer.Replace(strings.Trim(filepath.ToSlash(name), "./"))+".log")
+}
@@ -0,0 +1,73 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// Command is a command of a run for an Executor to run.
+type Command struct {
+	// Args holds the command line, starting with the command.
+	Args []string
+	// Dir is the directory to run in, "" for the current one.
+	Dir string
+	// Env is the command's environment, nil for the inherited one.
+	Env []string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// maskCount opts a plan command into the config's masking rules, which
+	// mask its output before anything reads it, counting the matches
+	// replaced.
+	maskCount *int
+}
+
+// Executor runs the commands of a run: the runner's plans and inits,
+// affected-modules.sh and the hooks. Run returns once the command exited,
+// with an error if it failed or ctx ended first.
+//
+// The default executor runs them as local processes. Tests can drive a
+// PlanGenerator with a fake one, without kitman or terraform installed.
+type Executor interface {
+	Run(ctx context.Context, cmd *Command) error
+}
+
+// localExecutor runs commands as local processes. Each runs in its own
+// process group, so cancelling ctx interrupts the whole tree (the runner,
+// terragrunt and the terraform processes under it) rather than orphaning
+// it, and kills what's left after interruptGrace.
+type localExecutor struct{}
+
+func (localExecutor) Run(ctx context.Context, c *Command) error {
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	setProcessGroup(cmd)
+	cmd.Dir, cmd.Env = c.Dir, c.Env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = c.Stdin, c.Stdout, c.Stderr
+	return cmd.Run()
+}
+
+// execute runs a command of the run with pg.Executor, logging and tracing
+// it with what it printed on stderr.
+func (pg *PlanGenerator) execute(ctx context.Context, cmd *Command) error {
+	executor := pg.Executor
+	if executor == nil {
+		executor = localExecutor{}
+	}
+	var stderr bytes.Buffer
+	if cmd.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, &stderr)
+	} else {
+		cmd.Stderr = &stderr
+	}
+	flush := pg.maskCommand(cmd)
+	began := time.Now()
+	err := executor.Run(ctx, cmd)
+	flush()
+	logCommand(cmd, began, err, stderr.Bytes())
+	return err
+}
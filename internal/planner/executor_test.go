@@ -0,0 +1,47 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPlanStateRunsRunner(t *testing.T) {
+	fake := &fakeExecutor{stdout: stagingPlan}
+	pg := newTestGenerator(t, fake)
+	pg.Targets = []string{"aws_vpc.this"}
+	state := &State{Path: "terragrunt_vpc/organizations/staging/us-east-1"}
+
+	output, err := pg.planState(context.Background(), pg.Config.Partitions[0], state)
+	if err != nil {
+		t.Fatalf("planState: %v", err)
+	}
+	if string(output) != stagingPlan {
+		t.Errorf("output = %q, want the runner's stdout", output)
+	}
+	if len(fake.commands) != 1 {
+		t.Fatalf("ran %d commands, want 1", len(fake.commands))
+	}
+	argv := strings.Join(fake.commands[0], " ")
+	if !strings.HasPrefix(argv, "kitman ") || !strings.Contains(argv, state.Path) || !strings.Contains(argv, "-target=aws_vpc.this") {
+		t.Errorf("command = %q", argv)
+	}
+}
+
+func TestPlanStateFailure(t *testing.T) {
+	fake := &fakeExecutor{
+		stderr: "Error: Error acquiring the state lock\n",
+		err:    errors.New("exit status 1"),
+	}
+	pg := newTestGenerator(t, fake)
+	state := &State{Path: "terragrunt_vpc/organizations/staging/us-east-1"}
+
+	_, err := pg.planState(context.Background(), pg.Config.Partitions[0], state)
+	if err == nil {
+		t.Fatal("planState succeeded for a failing runner")
+	}
+	if !strings.Contains(err.Error(), "exit status 1") || !strings.Contains(err.Error(), "acquiring the state lock") {
+		t.Errorf("error = %v, want the exit status and the stderr tail", err)
+	}
+}
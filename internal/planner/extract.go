@@ -0,0 +1,171 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// partitionMarker starts the sections of a partition in a report.
+const partitionMarker = "<!-- tfprgen:partition %s -->"
+
+var (
+	envHeadingRegex      = regexp.MustCompile(`^## \[environment: ([^\]]+)\]`)
+	summaryRegex         = regexp.MustCompile(`^<summary>([^<]*)</summary>`)
+	partitionMarkerRegex = regexp.MustCompile(`^<!-- tfprgen:partition (.+) -->$`)
+)
+
+// reportSection is one environment heading of a rendered pr-ready.md with
+// its per-region <details> blocks.
+type reportSection struct {
+	// Partition is "" in reports without partition markers: plain ones
+	// and those of earlier versions.
+	Partition   string
+	Environment string
+	Heading     string
+	Regions     []reportRegion
+}
+
+type reportRegion struct {
+	Region string
+	Block  string // the whole <details>...</details> block
+}
+
+// parseReportMarkdown splits a rendered report back into its sections.
+func parseReportMarkdown(content string) []*reportSection {
+	var sections []*reportSection
+	var current *reportSection
+	var block []string
+	var region, partition string
+	inDetails := false
+
+	for _, line := range strings.Split(content, "\n") {
+		if m := partitionMarkerRegex.FindStringSubmatch(line); m != nil && !inDetails {
+			partition = m[1]
+			continue
+		}
+		if m := envHeadingRegex.FindStringSubmatch(line); m != nil && !inDetails {
+			current = &reportSection{Partition: partition, Environment: m[1], Heading: line}
+			sections = append(sections, current)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if line == "<details>" && !inDetails {
+			inDetails = true
+			block = []string{line}
+			region = ""
+			continue
+		}
+		if !inDetails {
+			continue
+		}
+		block = append(block, line)
+		if m := summaryRegex.FindStringSubmatch(line); m != nil && region == "" {
+			if fields := strings.Fields(m[1]); len(fields) > 0 {
+				region = fields[0]
+			}
+		}
+		if line == "</details>" {
+			current.Regions = append(current.Regions, reportRegion{Region: region, Block: strings.Join(block, "\n")})
+			inDetails = false
+		}
+	}
+	return sections
+}
+
+func newExtractCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "extract <run_dir|report.md>",
+		Short: "Extract one environment/region from a generated report",
+		Long: `Pulls a single environment (optionally a single region) out of an existing
+pr-ready.md into its own markdown file, e.g. to paste into an incident ticket.
+
+Examples:
+  terraform-pr-generator extract pr-plans-20250604-143022 --env production
+  terraform-pr-generator extract pr-plans-20250604-143022 --env staging --region us-east-1 -o -`,
+		Args: cobra.ExactArgs(1),
+		Run:  runExtract,
+	}
+
+	cmd.Flags().StringP("env", "e", "", "Environment to extract (required)")
+	cmd.Flags().StringP("region", "r", "", "Only extract this region")
+	cmd.Flags().StringP("output", "o", "", "Output file, or - for stdout (default: pr-ready-<env>[-<region>].md next to the report)")
+	cmd.MarkFlagRequired("env")
+	return cmd
+}
+
+func runExtract(cmd *cobra.Command, args []string) {
+	envName, _ := cmd.Flags().GetString("env")
+	region, _ := cmd.Flags().GetString("region")
+	outputPath, _ := cmd.Flags().GetString("output")
+
+	reportPath := args[0]
+	if info, err := os.Stat(reportPath); err == nil && info.IsDir() {
+		reportPath = filepath.Join(reportPath, "pr-ready.md")
+	}
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	extracted, err := extractSection(string(content), envName, region)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if outputPath == "-" {
+		fmt.Print(extracted)
+		return
+	}
+	if outputPath == "" {
+		name := "pr-ready-" + envName
+		if region != "" {
+			name += "-" + region
+		}
+		outputPath = filepath.Join(filepath.Dir(reportPath), name+".md")
+	}
+	if err := os.WriteFile(outputPath, []byte(extracted), 0644); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	successColor.Printf("✅ Extracted %s to %s\n", strings.TrimSuffix(envName+"/"+region, "/"), outputPath)
+}
+
+// extractSection renders a standalone report holding only envName (and
+// region, if given).
+func extractSection(content, envName, region string) (string, error) {
+	var available []string
+	for _, section := range parseReportMarkdown(content) {
+		available = append(available, section.Environment)
+		if section.Environment != envName {
+			continue
+		}
+
+		var out strings.Builder
+		out.WriteString("**Terraform plan**\n\n")
+		out.WriteString(section.Heading + "\n\n")
+		found := false
+		var regions []string
+		for _, r := range section.Regions {
+			regions = append(regions, r.Region)
+			if region != "" && r.Region != region {
+				continue
+			}
+			out.WriteString(r.Block + "\n\n")
+			found = true
+		}
+		if !found {
+			return "", fmt.Errorf("region %s not found in environment %s (available: %s)", region, envName, strings.Join(regions, ", "))
+		}
+		return out.String(), nil
+	}
+	return "", fmt.Errorf("environment %s not found in report (available: %s)", envName, strings.Join(available, ", "))
+}
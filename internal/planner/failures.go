@@ -0,0 +1,65 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// stateFailure is a targeted state, or a full run partition's plan_all,
+// that failed while --keep-going let the run go on.
+type stateFailure struct {
+	Partition *Partition
+	Name      string
+	Err       error
+}
+
+// recordFailure keeps a failure for the report instead of failing the run.
+func (pg *PlanGenerator) recordFailure(p *Partition, name string, err error) {
+	errorColor.Printf("❌ %s failed, going on (--keep-going): %v\n", name, err)
+	pg.flushMu.Lock()
+	defer pg.flushMu.Unlock()
+	pg.failures = append(pg.failures, &stateFailure{Partition: p, Name: name, Err: err})
+}
+
+// keepPartial keeps what a failed plan_all wrote to outputFile.partial,
+// so the report shows the states it planned.
+func (pg *PlanGenerator) keepPartial(outputFile string) error {
+	pg.flushMu.Lock()
+	defer pg.flushMu.Unlock()
+	return os.Rename(outputFile+".partial", outputFile)
+}
+
+// writeFailures renders the "Failed states" section listing each failure
+// with its error, stderr tail included.
+func (pg *PlanGenerator) writeFailures(output io.StringWriter) {
+	if len(pg.failures) == 0 {
+		return
+	}
+	sort.Slice(pg.failures, func(i, j int) bool { return pg.failures[i].Name < pg.failures[j].Name })
+
+	output.WriteString("## " + pg.renderer().Icon("❌") + "Failed states\n\n")
+	output.WriteString("These plans failed, so their changes are missing from the report:\n\n")
+	for _, f := range pg.failures {
+		pg.renderer().OpenSection(output, 3, f.Name)
+		var lockErr *stateLockError
+		if errors.As(f.Err, &lockErr) {
+			output.WriteString("> " + pg.renderer().Icon("🔒") + lockHint(lockErr.Lock) + "\n\n")
+		}
+		var throttleErr *throttledError
+		if errors.As(f.Err, &throttleErr) {
+			output.WriteString("> " + pg.renderer().Icon("🐢") + throttleHint() + "\n\n")
+		}
+		output.WriteString("```\n" + strings.TrimSpace(f.Err.Error()) + "\n```\n\n")
+		pg.renderer().CloseSection(output)
+	}
+}
+
+// failedError fails a run whose reports are out but had states fail under
+// --keep-going.
+func (pg *PlanGenerator) failedError() error {
+	return fmt.Errorf("%d plan(s) failed; see the Failed states section of the report", len(pg.failures))
+}
@@ -0,0 +1,154 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newFormatCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "format --input <dir>",
+		Short: "Render plans captured elsewhere into pr-ready.md without planning",
+		Long: `Parses plans files that were already captured, e.g. by a CI job running the
+plans itself, into pr-ready.md: the environment and region parsing and the
+markdown of a run, without running anything.
+
+The input directory holds the runner's output in files named after the
+partitions' output_file, as a run writes them (commercial-plans.txt and
+govcloud-plans.txt by default). A run directory's manifest.json, if there
+is one, gives the module and config; otherwise pass --module.
+
+Examples:
+  terraform-pr-generator format --input ci-plans/ --module s3_malware_protection
+  terraform-pr-generator format --input ci-plans/ --module vpc --output report/ --format json
+  terraform-pr-generator format --input pr-plans-20250604-143022 --stdout | gh pr comment -F -`,
+		Args: cobra.NoArgs,
+		Run:  runFormat,
+	}
+
+	cmd.Flags().String("input", "", "Directory of plans files named after the partitions' output_file")
+	cmd.Flags().String("module", "", "Module the plans are of (default: from the input's manifest.json)")
+	cmd.Flags().StringP("config", "c", "", "Path to a YAML config file (default: the input's manifest.json, else .tfprgen.yaml in the repo root)")
+	cmd.Flags().StringP("output", "o", "", "Directory to write pr-ready.md to (default: the input directory)")
+	cmd.Flags().StringSlice("format", nil, "Additional report formats to write alongside pr-ready.md: junit, json, csv or a configured formatter")
+	cmd.Flags().Bool("stdout", false, "Print pr-ready.md to stdout")
+	cmd.MarkFlagRequired("input")
+	return cmd
+}
+
+func runFormat(cmd *cobra.Command, args []string) {
+	input, _ := cmd.Flags().GetString("input")
+	module, _ := cmd.Flags().GetString("module")
+	configPath, _ := cmd.Flags().GetString("config")
+	output, _ := cmd.Flags().GetString("output")
+	formats, _ := cmd.Flags().GetStringSlice("format")
+	toStdout, _ := cmd.Flags().GetBool("stdout")
+
+	var cfg *Config
+	var err error
+	root := ""
+	manifest, manifestErr := readManifest(input)
+	if manifestErr == nil && module == "" {
+		module, root = manifest.Module, manifest.ModuleRoot
+	}
+	switch {
+	case configPath != "":
+		cfg, err = LoadConfig(configPath)
+	case manifestErr == nil:
+		cfg, err = manifest.config()
+	default:
+		cfg, err = LoadConfig(FindConfigFile("."))
+	}
+	if err == nil && module == "" {
+		err = fmt.Errorf("--module is required: %s has no manifest.json", input)
+	}
+	if err == nil {
+		err = validateFormats(formats, cfg)
+	}
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if output == "" {
+		output = input
+	}
+
+	pg := &PlanGenerator{
+		ModuleName:      module,
+		ModuleRoot:      root,
+		OutputDir:       input,
+		Config:          cfg,
+		Formats:         formats,
+		CollapseForEach: cfg.CollapseForEach,
+		MergeIdentical:  cfg.MergeIdentical,
+		Normalize:       cfg.Normalize,
+		PlainReport:     cfg.PlainReport,
+		MaxOutputBytes:  cfg.MaxOutputBytes,
+	}
+	reports, err := pg.formatPlans(output)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if toStdout {
+		report, err := os.ReadFile(reports[0])
+		if err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(report)
+		return
+	}
+	for _, report := range reports {
+		boldColor.Printf("📄 %s\n", report)
+	}
+}
+
+// formatPlans parses the plans files in the output directory and writes
+// pr-ready.md and the Formats into output. It returns the reports' paths,
+// pr-ready.md first.
+func (pg *PlanGenerator) formatPlans(output string) ([]string, error) {
+	var found, expected []string
+	for _, p := range pg.Config.Partitions {
+		expected = append(expected, p.OutputFile)
+		if _, err := os.Stat(filepath.Join(pg.OutputDir, p.OutputFile)); err == nil {
+			found = append(found, p.OutputFile)
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("no plans files in %s (expected %s)", pg.OutputDir, strings.Join(expected, ", "))
+	}
+	if pg.Verbose {
+		fmt.Fprintf(console, "📥 Formatting %s\n", strings.Join(found, ", "))
+	}
+	results, err := pg.collectResults()
+	if err != nil {
+		return nil, err
+	}
+
+	pg.OutputDir = output
+	if err := os.MkdirAll(output, 0755); err != nil {
+		return nil, err
+	}
+	if err := pg.generatePRMarkdown(results, ""); err != nil {
+		return nil, fmt.Errorf("generating PR markdown: %v", err)
+	}
+	if err := pg.fitOutputBudget(results, ""); err != nil {
+		return nil, fmt.Errorf("generating PR markdown: %v", err)
+	}
+	reports := []string{filepath.Join(output, "pr-ready.md")}
+	for _, format := range pg.Formats {
+		path, err := pg.writeFormat(format, results)
+		if err != nil {
+			return nil, fmt.Errorf("generating %s report: %v", format, err)
+		}
+		if path != "" {
+			reports = append(reports, path)
+		}
+	}
+	return reports, nil
+}
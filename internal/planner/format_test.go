@@ -0,0 +1,30 @@
+package planner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatPlans(t *testing.T) {
+	pg := newTestGenerator(t, nil)
+	pg.Formats = []string{"json"}
+	if _, err := pg.formatPlans(t.TempDir()); err == nil || !strings.Contains(err.Error(), "commercial-plans.txt") {
+		t.Errorf("err = %v, want the expected plans files", err)
+	}
+	os.WriteFile(filepath.Join(pg.OutputDir, "commercial-plans.txt"), []byte(stagingPlan), 0644)
+
+	output := filepath.Join(t.TempDir(), "report")
+	reports, err := pg.formatPlans(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || reports[0] != filepath.Join(output, "pr-ready.md") || reports[1] != filepath.Join(output, "report.json") {
+		t.Fatalf("reports = %v", reports)
+	}
+	report, _ := os.ReadFile(reports[0])
+	if !strings.Contains(string(report), "[environment: staging]") || !strings.Contains(string(report), "Plan: 1 to add") {
+		t.Errorf("report lacks the staging plan:\n%s", report)
+	}
+}
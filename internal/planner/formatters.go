@@ -0,0 +1,103 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Formatter is an external report format: a command receiving report.json
+// on stdin, run when --format names it. Output, if set, is the file its
+// stdout is written to in the output directory; formatters writing files
+// of their own find the directory in TFPRGEN_OUTPUT_DIR.
+type Formatter struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+	Output  string `yaml:"output"`
+}
+
+func (c *Config) validateFormatters() error {
+	seen := make(map[string]bool)
+	for i, f := range c.Formatters {
+		switch {
+		case f.Name == "":
+			return fmt.Errorf("formatters[%d] needs a name", i)
+		case f.Name == "markdown" || formatFiles[f.Name] != "":
+			return fmt.Errorf("formatter %s: %s is a built-in format", f.Name, f.Name)
+		case seen[f.Name]:
+			return fmt.Errorf("duplicate formatter %q", f.Name)
+		case f.Output != "" && (filepath.IsAbs(f.Output) || filepath.Base(f.Output) != f.Output):
+			return fmt.Errorf("formatter %s: output must be a file name in the output directory", f.Name)
+		}
+		seen[f.Name] = true
+		argv, err := splitCommandLine(f.Command)
+		if err == nil && len(argv) == 0 {
+			err = fmt.Errorf("empty command")
+		}
+		if err != nil {
+			return fmt.Errorf("formatter %s: invalid command %q: %v", f.Name, f.Command, err)
+		}
+	}
+	return nil
+}
+
+// formatter is the configured formatter named name, nil for none.
+func (c *Config) formatter(name string) *Formatter {
+	for _, f := range c.Formatters {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// formatFile is the file a report format is written to in the output
+// directory, "" for a formatter without an output.
+func (pg *PlanGenerator) formatFile(format string) string {
+	if f := pg.Config.formatter(format); f != nil {
+		return f.Output
+	}
+	return formatFiles[format]
+}
+
+// runFormatter feeds the JSON report to a formatter and returns the path
+// of its output, "" when it has none.
+func (pg *PlanGenerator) runFormatter(f *Formatter, results []*PartitionResult) (string, error) {
+	data, err := pg.jsonReport(results)
+	if err != nil {
+		return "", err
+	}
+	argv, _ := splitCommandLine(f.Command) // checked by Config.validate
+	absOutput, _ := filepath.Abs(pg.OutputDir)
+	cmd := &Command{
+		Args:   argv,
+		Env:    append(os.Environ(), "TFPRGEN_FORMAT="+f.Name, "TFPRGEN_MODULE="+pg.ModuleName, "TFPRGEN_OUTPUT_DIR="+absOutput),
+		Stdin:  bytes.NewReader(data),
+		Stdout: os.Stderr, // stdout is reserved for data
+		Stderr: os.Stderr,
+	}
+
+	path := ""
+	var file *os.File
+	if f.Output != "" {
+		path = filepath.Join(pg.OutputDir, f.Output)
+		if file, err = os.Create(path); err != nil {
+			return "", err
+		}
+		cmd.Stdout = file
+	}
+	if pg.Verbose {
+		fmt.Fprintf(console, "🧩 Running %s formatter: %s\n", f.Name, f.Command)
+	}
+	err = pg.execute(pg.ctx, cmd)
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s formatter %q failed: %v", f.Name, f.Command, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,57 @@
+package planner
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// gitHead returns the current commit SHA, or "" outside a git checkout.
+func gitHead() string {
+	out, err := commandOutput(exec.Command("git", "rev-parse", "HEAD"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// gitDirty reports whether the working tree has uncommitted changes.
+func gitDirty() bool {
+	out, err := commandOutput(exec.Command("git", "status", "--porcelain"))
+	if err != nil {
+		return false
+	}
+	return len(strings.TrimSpace(string(out))) > 0
+}
+
+// groupRevision is the commit checked out when a partition's plans started
+// and when they finished.
+type groupRevision struct {
+	Partition string
+	Start     string
+	End       string
+}
+
+// revisionMismatch describes the commits the plan groups ran against when
+// they were not all the same, e.g. because someone pulled mid-run. It
+// returns "" for a consistent run.
+func revisionMismatch(revisions []*groupRevision) string {
+	seen := make(map[string]bool)
+	var parts []string
+	for _, r := range revisions {
+		if r == nil {
+			continue
+		}
+		seen[r.Start] = true
+		seen[r.End] = true
+		desc := fmt.Sprintf("%s at %s", r.Partition, shortSHA(r.Start))
+		if r.End != r.Start {
+			desc += " → " + shortSHA(r.End)
+		}
+		parts = append(parts, desc)
+	}
+	if len(seen) <= 1 {
+		return ""
+	}
+	return strings.Join(parts, ", ")
+}
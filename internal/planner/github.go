@@ -0,0 +1,481 @@
+package planner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// githubCommentLimit is GitHub's maximum comment body size.
+const githubCommentLimit = 65536
+
+// commentUpdateInterval throttles progress edits to stay clear of GitHub's
+// secondary rate limits; the final edit is never throttled.
+const commentUpdateInterval = 15 * time.Second
+
+var pullRefRegex = regexp.MustCompile(`^refs/pull/(\d+)/`)
+
+// githubClient is the small slice of the GitHub REST API the generator
+// needs: pull requests, their comments, descriptions and files, commit statuses, releases, gists
+// and drift issues.
+type githubClient struct {
+	apiURL string
+	token  string
+	repo   string // owner/name
+	http   *http.Client
+}
+
+// newGitHubClient reads the token and repository from the environment
+// GitHub Actions provides.
+func newGitHubClient() (*githubClient, error) {
+	client := newGitHubAPI()
+	if client.token == "" {
+		return nil, fmt.Errorf("GITHUB_TOKEN is not set")
+	}
+	if client.repo == "" {
+		return nil, fmt.Errorf("GITHUB_REPOSITORY is not set")
+	}
+	return client, nil
+}
+
+// newGitHubAPI is a client for reading from any repository. GITHUB_TOKEN is
+// optional here: public repositories work without it, at a lower rate limit.
+func newGitHubAPI() *githubClient {
+	apiURL := os.Getenv("GITHUB_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	return &githubClient{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		token:  os.Getenv("GITHUB_TOKEN"),
+		repo:   os.Getenv("GITHUB_REPOSITORY"),
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// pullRequestNumber returns number if set, otherwise the pull request the
+// current GitHub Actions run was triggered for.
+func pullRequestNumber(number int) (int, error) {
+	if number > 0 {
+		return number, nil
+	}
+	if m := pullRefRegex.FindStringSubmatch(os.Getenv("GITHUB_REF")); m != nil {
+		return strconv.Atoi(m[1])
+	}
+	return 0, fmt.Errorf("pull request number unknown: pass --pr-number outside pull_request workflows")
+}
+
+// do sends a request with payload, if any, encoded as JSON.
+func (c *githubClient) do(method, path string, payload any) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.apiURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func (c *githubClient) createComment(pr int, body string) (int64, error) {
+	data, err := c.do("POST", fmt.Sprintf("/repos/%s/issues/%d/comments", c.repo, pr), map[string]string{"body": body})
+	if err != nil {
+		return 0, err
+	}
+	var comment struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(data, &comment); err != nil {
+		return 0, fmt.Errorf("failed to parse comment response: %v", err)
+	}
+	return comment.ID, nil
+}
+
+func (c *githubClient) updateComment(id int64, body string) error {
+	_, err := c.do("PATCH", fmt.Sprintf("/repos/%s/issues/comments/%d", c.repo, id), map[string]string{"body": body})
+	return err
+}
+
+// pullRequestBody returns a pull request's description.
+func (c *githubClient) pullRequestBody(pr int) (string, error) {
+	data, err := c.do("GET", fmt.Sprintf("/repos/%s/pulls/%d", c.repo, pr), nil)
+	if err != nil {
+		return "", err
+	}
+	var pull struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal(data, &pull); err != nil {
+		return "", fmt.Errorf("failed to parse pull request response: %v", err)
+	}
+	return pull.Body, nil
+}
+
+func (c *githubClient) updatePullRequestBody(pr int, body string) error {
+	_, err := c.do("PATCH", fmt.Sprintf("/repos/%s/pulls/%d", c.repo, pr), map[string]string{"body": body})
+	return err
+}
+
+// githubPull is a pull request of the repository.
+type githubPull struct {
+	Number int    `json:"number"`
+	URL    string `json:"html_url"`
+}
+
+func (c *githubClient) defaultBranch() (string, error) {
+	data, err := c.do("GET", "/repos/"+c.repo, nil)
+	if err != nil {
+		return "", err
+	}
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := json.Unmarshal(data, &repo); err != nil {
+		return "", fmt.Errorf("failed to parse repository response: %v", err)
+	}
+	return repo.DefaultBranch, nil
+}
+
+// openPullForBranch returns the open pull request from branch, nil if
+// there is none.
+func (c *githubClient) openPullForBranch(branch string) (*githubPull, error) {
+	owner, _, _ := strings.Cut(c.repo, "/")
+	data, err := c.do("GET", fmt.Sprintf("/repos/%s/pulls?state=open&head=%s", c.repo, url.QueryEscape(owner+":"+branch)), nil)
+	if err != nil {
+		return nil, err
+	}
+	var pulls []*githubPull
+	if err := json.Unmarshal(data, &pulls); err != nil {
+		return nil, fmt.Errorf("failed to parse pull requests response: %v", err)
+	}
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+	return pulls[0], nil
+}
+
+func (c *githubClient) createPull(title, head, base, body string, draft bool) (*githubPull, error) {
+	data, err := c.do("POST", fmt.Sprintf("/repos/%s/pulls", c.repo), map[string]any{
+		"title": title,
+		"head":  head,
+		"base":  base,
+		"body":  body,
+		"draft": draft,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var pull githubPull
+	if err := json.Unmarshal(data, &pull); err != nil {
+		return nil, fmt.Errorf("failed to parse pull request response: %v", err)
+	}
+	return &pull, nil
+}
+
+// createStatus sets a commit status on sha, linked to targetURL if set.
+func (c *githubClient) createStatus(sha string, status *commitStatus, targetURL string) error {
+	payload := map[string]string{"state": status.State, "context": status.Context, "description": status.Description}
+	if targetURL != "" {
+		payload["target_url"] = targetURL
+	}
+	_, err := c.do("POST", fmt.Sprintf("/repos/%s/statuses/%s", c.repo, sha), payload)
+	return err
+}
+
+// pullRequestFiles lists the paths a pull request changes, including the
+// old paths of renamed files. GitHub lists at most 3000.
+func (c *githubClient) pullRequestFiles(pr int) ([]string, error) {
+	var paths []string
+	for page := 1; page <= 30; page++ {
+		data, err := c.do("GET", fmt.Sprintf("/repos/%s/pulls/%d/files?per_page=100&page=%d", c.repo, pr, page), nil)
+		if err != nil {
+			return nil, err
+		}
+		var files []struct {
+			Filename         string `json:"filename"`
+			PreviousFilename string `json:"previous_filename"`
+		}
+		if err := json.Unmarshal(data, &files); err != nil {
+			return nil, fmt.Errorf("failed to parse pull request files response: %v", err)
+		}
+		for _, f := range files {
+			paths = append(paths, f.Filename)
+			if f.PreviousFilename != "" {
+				paths = append(paths, f.PreviousFilename)
+			}
+		}
+		if len(files) < 100 {
+			break
+		}
+	}
+	return paths, nil
+}
+
+// githubIssue is an issue of the repository.
+type githubIssue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	URL    string `json:"html_url"`
+}
+
+// findOpenIssue returns the open issue with the given label and title, or
+// nil if there is none.
+func (c *githubClient) findOpenIssue(label, title string) (*githubIssue, error) {
+	data, err := c.do("GET", fmt.Sprintf("/repos/%s/issues?state=open&labels=%s&per_page=100", c.repo, url.QueryEscape(label)), nil)
+	if err != nil {
+		return nil, err
+	}
+	var issues []*githubIssue
+	if err := json.Unmarshal(data, &issues); err != nil {
+		return nil, fmt.Errorf("failed to parse issues response: %v", err)
+	}
+	for _, issue := range issues {
+		if issue.Title == title {
+			return issue, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *githubClient) createIssue(title, body string, labels []string) (*githubIssue, error) {
+	data, err := c.do("POST", fmt.Sprintf("/repos/%s/issues", c.repo), map[string]any{"title": title, "body": body, "labels": labels})
+	if err != nil {
+		return nil, err
+	}
+	var issue githubIssue
+	if err := json.Unmarshal(data, &issue); err != nil {
+		return nil, fmt.Errorf("failed to parse issue response: %v", err)
+	}
+	return &issue, nil
+}
+
+func (c *githubClient) closeIssue(number int) error {
+	_, err := c.do("PATCH", fmt.Sprintf("/repos/%s/issues/%d", c.repo, number), map[string]string{"state": "closed"})
+	return err
+}
+
+// githubRelease is one published release of a repository.
+type githubRelease struct {
+	Tag    string                `json:"tag_name"`
+	Name   string                `json:"name"`
+	Body   string                `json:"body"`
+	URL    string                `json:"html_url"`
+	Assets []*githubReleaseAsset `json:"assets"`
+}
+
+// githubReleaseAsset is a file attached to a release. URL is its API URL,
+// which also serves assets of private repositories.
+type githubReleaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+}
+
+// asset is the release's asset named name, nil without one.
+func (r *githubRelease) asset(name string) *githubReleaseAsset {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// releases lists a repository's latest releases, newest first.
+func (c *githubClient) releases(repo string) ([]*githubRelease, error) {
+	data, err := c.do("GET", fmt.Sprintf("/repos/%s/releases?per_page=100", repo), nil)
+	if err != nil {
+		return nil, err
+	}
+	var releases []*githubRelease
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return nil, fmt.Errorf("failed to parse releases response: %v", err)
+	}
+	return releases, nil
+}
+
+// release is a repository's release tagged tag, or its latest one for
+// "latest".
+func (c *githubClient) release(repo, tag string) (*githubRelease, error) {
+	path := fmt.Sprintf("/repos/%s/releases/latest", repo)
+	if tag != "latest" {
+		path = fmt.Sprintf("/repos/%s/releases/tags/%s", repo, url.PathEscape(tag))
+	}
+	data, err := c.do("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var release githubRelease
+	if err := json.Unmarshal(data, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release response: %v", err)
+	}
+	return &release, nil
+}
+
+// downloadAsset fetches a release asset's contents.
+func (c *githubClient) downloadAsset(asset *githubReleaseAsset) ([]byte, error) {
+	req, err := http.NewRequest("GET", asset.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	// Binaries take longer than API calls
+	client := *c.http
+	client.Timeout = 5 * time.Minute
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("downloading %s: %s", asset.Name, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// createGist creates a secret gist holding one file and returns its URL.
+func (c *githubClient) createGist(description, filename, content string) (string, error) {
+	data, err := c.do("POST", "/gists", map[string]any{
+		"description": description,
+		"public":      false,
+		"files":       map[string]any{filename: map[string]string{"content": content}},
+	})
+	if err != nil {
+		return "", err
+	}
+	var gist struct {
+		URL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(data, &gist); err != nil {
+		return "", fmt.Errorf("failed to parse gist response: %v", err)
+	}
+	return gist.URL, nil
+}
+
+// commentStream keeps one pull request comment up to date while plans run:
+// a "plans in progress" placeholder, partial results as plans finish and
+// finally the full report. A nil *commentStream does nothing, and API
+// failures only warn so they never fail the run itself.
+type commentStream struct {
+	client *githubClient
+	pr     int
+	id     int64
+
+	mu         sync.Mutex
+	final      bool // the final report is posted
+	done       int
+	total      int
+	unit       string // what done/total count, e.g. "states"
+	lastUpdate time.Time
+}
+
+// startCommentStream posts the initial placeholder comment.
+func startCommentStream(client *githubClient, pr int, module string) (*commentStream, error) {
+	body := fmt.Sprintf("⏳ **Terraform plans in progress** for `%s`\n\nThis comment updates as environments finish.\n", module)
+	id, err := client.createComment(pr, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to post progress comment: %v", err)
+	}
+	return &commentStream{client: client, pr: pr, id: id, lastUpdate: time.Now()}, nil
+}
+
+// expect adds total units of work to wait for.
+func (s *commentStream) expect(total int, unit string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total += total
+	s.unit = unit
+}
+
+// progress records n finished units and, unless an edit happened recently,
+// rewrites the comment with render's partial report.
+func (s *commentStream) progress(n int, render func() string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done += n
+	if time.Since(s.lastUpdate) < commentUpdateInterval && s.done < s.total {
+		return
+	}
+	s.lastUpdate = time.Now()
+	header := fmt.Sprintf("⏳ **Terraform plans in progress** — %d/%d %s planned, updated %s\n\n",
+		s.done, s.total, s.unit, time.Now().UTC().Format("15:04:05 UTC"))
+	s.edit(header + render())
+}
+
+// finish replaces the comment with the final report.
+func (s *commentStream) finish(body string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.final = true
+	s.edit(body)
+}
+
+// fail marks the comment as belonging to a failed run, unless it already
+// has the final report, e.g. one listing failed states.
+func (s *commentStream) fail(err error) {
+	if s == nil || s.final {
+		return
+	}
+	s.finish(fmt.Sprintf("❌ **Terraform plan generation failed**\n\n```\n%v\n```\n", err))
+}
+
+func (s *commentStream) edit(body string) {
+	if err := s.client.updateComment(s.id, truncateComment(body)); err != nil {
+		warningColor.Printf("⚠️  Updating PR comment failed: %v\n", err)
+	}
+}
+
+// truncateComment cuts body to GitHub's comment size limit.
+func truncateComment(body string) string {
+	if len(body) <= githubCommentLimit {
+		return body
+	}
+	const note = "\n\n… truncated, see the full report in the workflow artifacts.\n"
+	return strings.ToValidUTF8(body[:githubCommentLimit-len(note)], "") + note
+}
@@ -0,0 +1,114 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxGraphStates caps the states drawn in the dependency graph; GitHub
+// renders larger Mermaid graphs unreadably, if at all.
+const maxGraphStates = 150
+
+// stateEdges are the terragrunt dependency blocks between states, as the
+// indexes of the dependent state and of its dependency. Workspaces of a
+// state directory share its dependencies.
+func stateEdges(states []*State) [][2]int {
+	indexes := make(map[string][]int)
+	for i, state := range states {
+		path := filepath.Clean(state.Path)
+		indexes[path] = append(indexes[path], i)
+	}
+	var edges [][2]int
+	for i, state := range states {
+		for _, dep := range stateDependencies(state.Path) {
+			for _, j := range indexes[dep] {
+				if j != i {
+					edges = append(edges, [2]int{i, j})
+				}
+			}
+		}
+	}
+	return edges
+}
+
+// writeDependencyGraph renders the targeted states as a Mermaid graph: the
+// module leads to the states using it, and states other planned states
+// depend on (terragrunt dependency blocks) lead on to those, so the graph
+// shows how far a change reaches. Failed states are highlighted.
+func (pg *PlanGenerator) writeDependencyGraph(output *os.File) {
+	if !pg.Graph || len(pg.plannedStates) == 0 {
+		return
+	}
+	output.WriteString("## " + pg.renderer().Icon("🕸️") + "Dependency graph\n\n")
+	if len(pg.plannedStates) > maxGraphStates {
+		output.WriteString(fmt.Sprintf("%d states are too many to draw (more than %d); see the plans below.\n\n", len(pg.plannedStates), maxGraphStates))
+		return
+	}
+
+	failed := make(map[string]bool)
+	for _, failure := range pg.failures {
+		failed[failure.Name] = true
+	}
+	dependent := make(map[int]bool)
+	for _, edge := range stateEdges(pg.plannedStates) {
+		dependent[edge[0]] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	fmt.Fprintf(&b, "  module[\"%s\"]\n", mermaidLabel(filepath.ToSlash(pg.moduleDir())))
+	var failedIDs []string
+	for i, state := range pg.plannedStates {
+		fmt.Fprintf(&b, "  s%d[\"%s\"]\n", i, mermaidLabel(filepath.ToSlash(state.String())))
+		if !dependent[i] {
+			fmt.Fprintf(&b, "  module --> s%d\n", i)
+		}
+		if failed[state.String()] {
+			failedIDs = append(failedIDs, fmt.Sprintf("s%d", i))
+		}
+	}
+	for _, edge := range stateEdges(pg.plannedStates) {
+		fmt.Fprintf(&b, "  s%d --> s%d\n", edge[1], edge[0])
+	}
+	if len(failedIDs) > 0 {
+		b.WriteString("  classDef failed fill:#fdd,stroke:#c00\n")
+		fmt.Fprintf(&b, "  class %s failed\n", strings.Join(failedIDs, ","))
+	}
+
+	output.WriteString("```mermaid\n" + b.String() + "```\n\n")
+}
+
+// mermaidLabel escapes a node label for a quoted Mermaid string.
+func mermaidLabel(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+// writeGraphDot writes the dependency graph of the targeted states to
+// GraphDot in Graphviz DOT, like terragrunt graph-dependencies does for a
+// whole tree: every state is a node, with an edge from each state to the
+// states it depends on.
+func (pg *PlanGenerator) writeGraphDot(targeted bool, states []*State) error {
+	if !targeted {
+		warningColor.Println("⚠️  --graph-dot only applies to targeted runs; plan_all doesn't list the states it plans")
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("digraph {\n")
+	for _, state := range states {
+		fmt.Fprintf(&b, "\t%s;\n", strconv.Quote(filepath.ToSlash(state.String())))
+	}
+	for _, edge := range stateEdges(states) {
+		fmt.Fprintf(&b, "\t%s -> %s;\n", strconv.Quote(filepath.ToSlash(states[edge[0]].String())), strconv.Quote(filepath.ToSlash(states[edge[1]].String())))
+	}
+	b.WriteString("}\n")
+	if err := os.WriteFile(pg.GraphDot, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("--graph-dot: %v", err)
+	}
+	if pg.Verbose {
+		fmt.Fprintf(console, "🕸️  Wrote the dependency graph of %d state(s) to %s\n", len(states), pg.GraphDot)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteDependencyGraph(t *testing.T) {
+	pg := newTestGenerator(t, nil)
+	pg.Graph = true
+	root := t.TempDir()
+	vpc, eks := filepath.Join(root, "staging", "vpc"), filepath.Join(root, "staging", "eks")
+	os.MkdirAll(vpc, 0755)
+	os.MkdirAll(eks, 0755)
+	os.WriteFile(filepath.Join(eks, "terragrunt.hcl"), []byte("dependency \"vpc\" {\n  config_path = \"../vpc\"\n}\n"), 0644)
+	pg.plannedStates = []*State{{Path: vpc}, {Path: eks}}
+	pg.failures = []*stateFailure{{Name: eks, Err: fmt.Errorf("exit status 1")}}
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "graph.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pg.writeDependencyGraph(file)
+	file.Close()
+	graph, _ := os.ReadFile(file.Name())
+	for _, want := range []string{"```mermaid\ngraph LR\n", "module --> s0\n", "s0 --> s1\n", "class s1 failed\n"} {
+		if !strings.Contains(string(graph), want) {
+			t.Errorf("graph lacks %q:\n%s", want, graph)
+		}
+	}
+	if strings.Contains(string(graph), "module --> s1") {
+		t.Errorf("dependent state linked to the module:\n%s", graph)
+	}
+
+	pg.GraphDot = filepath.Join(t.TempDir(), "out.dot")
+	if err := pg.writeGraphDot(true, pg.plannedStates); err != nil {
+		t.Fatal(err)
+	}
+	dot, _ := os.ReadFile(pg.GraphDot)
+	if want := fmt.Sprintf("\t%q -> %q;\n", filepath.ToSlash(eks), filepath.ToSlash(vpc)); !strings.HasPrefix(string(dot), "digraph {\n") || !strings.Contains(string(dot), want) {
+		t.Errorf("DOT graph lacks %q:\n%s", want, dot)
+	}
+}
@@ -0,0 +1,165 @@
+package planner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// grepHit is a run of matching plan lines, with their context, in one
+// resource change of a region plan.
+type grepHit struct {
+	Location string // partition/env/region
+	Resource string // the resource change the lines are in, "" before the first
+	Lines    []string
+}
+
+func newGrepCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "grep <pattern> --from <run_dir>",
+		Short: "Search the plans of a run, showing each match's region and resource",
+		Long: `Searches every region plan of a run directory for a regular expression
+and prints the matching lines under the partition/env/region and resource
+change they belong to, instead of paging through the plans files. Each run
+is parsed with the config recorded in its manifest.json.
+
+Like grep, it exits 1 when nothing matches.
+
+Examples:
+  terraform-pr-generator grep 'aws_iam_role.foo' --from pr-plans-20250604-143022
+  terraform-pr-generator grep -F 'policy = jsonencode(' --from pr-plans-20250604-143022 --context 3
+  terraform-pr-generator grep -i 'force_destroy' --from pr-plans-20250604-143022 --env production`,
+		Args: cobra.ExactArgs(1),
+		Run:  runGrep,
+	}
+
+	cmd.Flags().String("from", "", "Run directory to search the plans of (required)")
+	cmd.Flags().StringSlice("env", nil, "Only search these environments")
+	cmd.Flags().BoolP("fixed-strings", "F", false, "Match the pattern literally instead of as a regular expression")
+	cmd.Flags().BoolP("ignore-case", "i", false, "Match case-insensitively")
+	cmd.Flags().Int("context", 0, "Show N lines of the resource change around each match")
+	cmd.MarkFlagRequired("from")
+	return cmd
+}
+
+func runGrep(cmd *cobra.Command, args []string) {
+	runDir, _ := cmd.Flags().GetString("from")
+	envs, _ := cmd.Flags().GetStringSlice("env")
+	fixed, _ := cmd.Flags().GetBool("fixed-strings")
+	ignoreCase, _ := cmd.Flags().GetBool("ignore-case")
+	context, _ := cmd.Flags().GetInt("context")
+
+	expr := args[0]
+	if fixed {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		errorColor.Printf("❌ Error: invalid pattern: %v\n", err)
+		os.Exit(1)
+	}
+
+	_, results, err := loadRunResults(runDir)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	plans := regionPlans(results)
+	var hits []*grepHit
+	matches, regions, searched := 0, 0, 0
+	for _, key := range plans.keys {
+		if len(envs) > 0 && !contains(envs, key.Env) {
+			continue
+		}
+		searched++
+		found, n := grepPlan(key.String(), plans.plans[key], re, context)
+		if n > 0 {
+			hits = append(hits, found...)
+			matches += n
+			regions++
+		}
+	}
+	writeGrepHits(os.Stdout, hits)
+
+	if matches == 0 {
+		fmt.Fprintf(console, "No matches in %d region plan(s)\n", searched)
+		os.Exit(1)
+	}
+	fmt.Fprintf(console, "🔍 %d matching line(s) in %d of %d region plan(s)\n", matches, regions, searched)
+}
+
+// grepPlan finds the lines of a region plan matching re, with context
+// lines around them that stay within the same resource change. It returns
+// the hits and how many lines matched.
+func grepPlan(location, body string, re *regexp.Regexp, context int) ([]*grepHit, int) {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	resources := make([]string, len(lines))
+	var matched []int
+	current := ""
+	for i, line := range lines {
+		if m := resourceChangeRegex.FindStringSubmatch(line); m != nil {
+			current = m[1]
+		} else if isPlanSummary(line) {
+			current = ""
+		}
+		resources[i] = current
+		if re.MatchString(line) {
+			matched = append(matched, i)
+		}
+	}
+
+	var hits []*grepHit
+	var hit *grepHit
+	last := -1 // the last line added to hit
+	for _, i := range matched {
+		from, to := i-context, i+context
+		for from < i && (from < 0 || from <= last || resources[from] != resources[i]) {
+			from++
+		}
+		for to > i && (to >= len(lines) || resources[to] != resources[i]) {
+			to--
+		}
+		if hit == nil || from > last+1 || resources[from] != hit.Resource {
+			hit = &grepHit{Location: location, Resource: resources[i]}
+			hits = append(hits, hit)
+		}
+		for j := from; j <= to; j++ {
+			if j > last {
+				hit.Lines = append(hit.Lines, strings.TrimRight(lines[j], " \t"))
+				last = j
+			}
+		}
+	}
+	return hits, len(matched)
+}
+
+// writeGrepHits prints hits under a partition/env/region and resource
+// heading, which is only repeated when it changes.
+func writeGrepHits(w io.Writer, hits []*grepHit) {
+	heading := ""
+	for _, hit := range hits {
+		h := hit.Location
+		if hit.Resource != "" {
+			h += "  " + hit.Resource
+		}
+		if h != heading {
+			if heading != "" {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintln(w, h)
+			heading = h
+		} else {
+			fmt.Fprintln(w, "    --")
+		}
+		for _, line := range hit.Lines {
+			fmt.Fprintln(w, "    "+line)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package planner
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGrepPlan(t *testing.T) {
+	body := `  # aws_iam_role.foo will be created
+  + resource "aws_iam_role" "foo" {
+      + name = "foo"
+    }
+
+  # aws_iam_role.bar will be created
+  + resource "aws_iam_role" "bar" {
+      + name = "bar"
+    }
+
+Plan: 2 to add, 0 to change, 0 to destroy.`
+	hits, n := grepPlan("commercial/staging/us-east-1", body, regexp.MustCompile(`name = "(foo|bar)"`), 2)
+	if n != 2 || len(hits) != 2 {
+		t.Fatalf("got %d match(es) in %d hit(s), want 2 in 2", n, len(hits))
+	}
+	// Context stays within the resource change
+	if hits[0].Resource != "aws_iam_role.foo" || len(hits[0].Lines) != 5 || !strings.Contains(hits[0].Lines[0], "aws_iam_role.foo") {
+		t.Errorf("first hit = %+v", hits[0])
+	}
+	if hits[1].Resource != "aws_iam_role.bar" || len(hits[1].Lines) != 5 || strings.HasPrefix(hits[1].Lines[4], "Plan:") {
+		t.Errorf("second hit = %+v", hits[1])
+	}
+}
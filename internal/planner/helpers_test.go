@@ -0,0 +1,74 @@
+package planner
+
+import (
+	"context"
+	"io"
+	"sort"
+	"testing"
+)
+
+// fakeExecutor records the commands it's given and answers them with
+// canned output.
+type fakeExecutor struct {
+	commands [][]string
+	stdout   string
+	stderr   string
+	err      error
+}
+
+func (f *fakeExecutor) Run(ctx context.Context, cmd *Command) error {
+	f.commands = append(f.commands, cmd.Args)
+	io.WriteString(cmd.Stdout, f.stdout)
+	io.WriteString(cmd.Stderr, f.stderr)
+	return f.err
+}
+
+// newTestGenerator is a generator for the vpc module with the default
+// config, writing to a temporary directory and running its commands
+// through executor.
+func newTestGenerator(t *testing.T, executor Executor) *PlanGenerator {
+	t.Helper()
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return &PlanGenerator{ModuleName: "vpc", OutputDir: t.TempDir(), Config: cfg, Executor: executor, ctx: context.Background()}
+}
+
+func commercialPartition(t *testing.T) *Partition {
+	t.Helper()
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return cfg.Partitions[0]
+}
+
+const stagingPlan = `Running in /repo/terragrunt_vpc/organizations/staging/us-east-1/
+Terraform will perform the following actions:
+
+  # aws_vpc.this will be created
+  + resource "aws_vpc" "this" {}
+
+Plan: 1 to add, 0 to change, 0 to destroy.
+`
+
+// testEnvironment is a parsed environment with a plan per region, as
+// PlanParser returns it, with its regions sorted and those listed in
+// incomplete marked so.
+func testEnvironment(name string, plans map[string]string, incomplete ...string) *Environment {
+	env := &Environment{Name: name, Plans: plans, Incomplete: make(map[string]bool)}
+	for region := range plans {
+		env.Regions = append(env.Regions, region)
+	}
+	sort.Strings(env.Regions)
+	for _, region := range incomplete {
+		env.Incomplete[region] = true
+	}
+	return env
+}
+
+// testResults are the results of a run planning envs in partition p.
+func testResults(p *Partition, envs ...*Environment) []*PartitionResult {
+	return []*PartitionResult{{Partition: p, Environments: envs}}
+}
@@ -0,0 +1,487 @@
+package planner
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	_ "modernc.org/sqlite"
+)
+
+// historyDBEnv overrides where run history is kept.
+const historyDBEnv = "TFPRGEN_HISTORY_DB"
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	module      TEXT NOT NULL,
+	started_at  INTEGER NOT NULL, -- unix seconds
+	duration_ms INTEGER NOT NULL,
+	status      TEXT NOT NULL,    -- succeeded, failed or drift
+	error       TEXT NOT NULL,
+	targeted    INTEGER NOT NULL,
+	git_commit  TEXT NOT NULL,
+	output      TEXT NOT NULL,    -- where the run's files are kept, if anywhere
+	report      TEXT NOT NULL     -- pr-ready.md as rendered
+);
+CREATE INDEX IF NOT EXISTS runs_module ON runs (module, started_at);
+CREATE TABLE IF NOT EXISTS results (
+	run_id     INTEGER NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+	partition  TEXT NOT NULL,
+	env        TEXT NOT NULL,
+	region     TEXT NOT NULL,
+	adds       INTEGER,           -- NULL without a Plan: summary
+	changes    INTEGER,
+	destroys   INTEGER,
+	incomplete INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS results_run ON results (run_id);
+`
+
+// historyEntry is one recorded run.
+type historyEntry struct {
+	ID        int64
+	Module    string
+	StartedAt time.Time
+	Duration  time.Duration
+	Status    string
+	Error     string
+	Targeted  bool
+	Commit    string
+	Output    string
+	Report    string
+}
+
+// historyResult is one region plan of a recorded run.
+type historyResult struct {
+	Partition, Env, Region string
+	Counts                 *PlanCounts
+	Incomplete             bool
+}
+
+// historyDBPath is ~/.tfprgen/history.db unless TFPRGEN_HISTORY_DB is set.
+func historyDBPath() (string, error) {
+	if path := os.Getenv(historyDBEnv); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tfprgen", "history.db"), nil
+}
+
+// openHistory opens the history database, creating it if needed.
+func openHistory() (*sql.DB, error) {
+	path, err := historyDBPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	// Parallel runs may finish at the same time
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening history %s: %v", path, err)
+	}
+	return db, nil
+}
+
+// recordHistory adds the finished run to the history. Failing to record
+// is only a warning; the run itself is what matters.
+func (pg *PlanGenerator) recordHistory(started time.Time, results []*PartitionResult, runErr error) {
+	entry := &historyEntry{
+		Module:    pg.ModuleName,
+		StartedAt: started,
+		Duration:  time.Since(started),
+		Status:    runStatus(runErr),
+		Targeted:  len(pg.plannedStates) > 0,
+		Commit:    gitHead(),
+		Output:    pg.keptOutput(runErr),
+	}
+	if runErr != nil {
+		entry.Error = runErr.Error()
+	}
+	if report, err := os.ReadFile(filepath.Join(pg.OutputDir, "pr-ready.md")); err == nil {
+		entry.Report = string(report)
+	}
+	if err := saveHistory(entry, results); err != nil {
+		warningColor.Printf("⚠️  Couldn't record the run in the history: %v\n", err)
+	}
+}
+
+// runStatus is how a run that returned runErr ended: succeeded, failed or
+// drift (--expect-no-changes found changes).
+func runStatus(runErr error) string {
+	if _, drift := runErr.(*errDrift); drift {
+		return "drift"
+	} else if runErr != nil {
+		return "failed"
+	}
+	return "succeeded"
+}
+
+// keptOutput is where the run's files can be found afterwards: the output
+// directory, or for scratch runs the archive or upload if any.
+func (pg *PlanGenerator) keptOutput(runErr error) string {
+	if !pg.scratch || runErr != nil {
+		abs, _ := filepath.Abs(pg.OutputDir)
+		return abs
+	}
+	if pg.Archive {
+		abs, _ := filepath.Abs(pg.archivePath())
+		return abs
+	}
+	if pg.upload != nil {
+		return pg.upload.location(pg.OutputDir, "")
+	}
+	return ""
+}
+
+func saveHistory(entry *historyEntry, results []*PartitionResult) error {
+	db, err := openHistory()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`INSERT INTO runs (module, started_at, duration_ms, status, error, targeted, git_commit, output, report)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Module, entry.StartedAt.Unix(), entry.Duration.Milliseconds(), entry.Status, entry.Error,
+		entry.Targeted, entry.Commit, entry.Output, entry.Report)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		for _, env := range result.Environments {
+			for _, region := range env.Regions {
+				var adds, changes, destroys sql.NullInt64
+				if counts, ok := ParsePlanCounts(env.Plans[region]); ok {
+					adds = sql.NullInt64{Int64: int64(counts.Add), Valid: true}
+					changes = sql.NullInt64{Int64: int64(counts.Change), Valid: true}
+					destroys = sql.NullInt64{Int64: int64(counts.Destroy), Valid: true}
+				}
+				_, err := tx.Exec(`INSERT INTO results (run_id, partition, env, region, adds, changes, destroys, incomplete)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+					id, result.Partition.Name, env.Name, region, adds, changes, destroys, env.Incomplete[region])
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func newHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List and inspect past runs",
+		Long: `Every run is recorded in ~/.tfprgen/history.db (TFPRGEN_HISTORY_DB overrides
+the location): module, start time, duration, outcome, per-region plan results,
+where its files were kept and the rendered report, so past plans can be looked
+up after their run directories are gone.
+
+Examples:
+  terraform-pr-generator history list s3_malware_protection
+  terraform-pr-generator history list --since 2025-06-03 --until 2025-06-04
+  terraform-pr-generator history show 42
+  terraform-pr-generator history show 42 --report > plan.md`,
+	}
+
+	list := &cobra.Command{
+		Use:               "list [module_name]",
+		Short:             "List recorded runs, newest first",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeModules(1),
+		Run:               runHistoryList,
+	}
+	list.Flags().String("since", "", "Only runs started on or after this date (YYYY-MM-DD, local time)")
+	list.Flags().String("until", "", "Only runs started before the end of this date (YYYY-MM-DD, local time)")
+	list.Flags().Int("limit", 20, "Number of runs to list (0 for all)")
+
+	show := &cobra.Command{
+		Use:   "show <run_id>",
+		Short: "Show a recorded run's results",
+		Args:  cobra.ExactArgs(1),
+		Run:   runHistoryShow,
+	}
+	show.Flags().Bool("report", false, "Print the run's rendered pr-ready.md to stdout instead")
+
+	cmd.AddCommand(list, show)
+	return cmd
+}
+
+func runHistoryList(cmd *cobra.Command, args []string) {
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+	limit, _ := cmd.Flags().GetInt("limit")
+
+	filter := historyFilter{Limit: limit}
+	if len(args) > 0 {
+		filter.Module = args[0]
+	}
+	if err := filter.setDates(since, until); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	db, err := openHistory()
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	runs, err := listHistory(db, filter)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(runs) == 0 {
+		fmt.Fprintln(console, "No recorded runs match.")
+		return
+	}
+
+	var lines [][]string
+	for _, run := range runs {
+		lines = append(lines, []string{
+			strconv.FormatInt(run.ID, 10),
+			run.StartedAt.Format("2006-01-02 15:04"),
+			run.Module,
+			run.Mode(),
+			run.Duration.Round(time.Second).String(),
+			run.Status,
+			fmt.Sprintf("+%d ~%d -%d in %d region(s)", run.Totals.Add, run.Totals.Change, run.Totals.Destroy, run.Regions),
+		})
+	}
+	// The table itself is data and goes to stdout
+	printTable([]string{"ID", "STARTED", "MODULE", "MODE", "DURATION", "STATUS", "CHANGES"}, lines)
+}
+
+// historyFilter selects recorded runs; zero fields don't filter.
+type historyFilter struct {
+	Module       string
+	Status       string
+	Since, Until time.Time
+	Limit        int
+	Offset       int
+}
+
+// setDates sets Since and Until from --since and --until dates
+// (YYYY-MM-DD, local time); Until includes its whole day.
+func (f *historyFilter) setDates(since, until string) error {
+	for _, bound := range []struct {
+		value string
+		days  int
+		dest  *time.Time
+	}{{since, 0, &f.Since}, {until, 1, &f.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", bound.value, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", bound.value)
+		}
+		*bound.dest = day.AddDate(0, 0, bound.days)
+	}
+	return nil
+}
+
+// historySummary is a recorded run with its plans' totals.
+type historySummary struct {
+	historyEntry
+	Totals  PlanCounts
+	Regions int
+}
+
+// listHistory returns the matching runs, newest first, without their
+// reports.
+func listHistory(db *sql.DB, filter historyFilter) ([]*historySummary, error) {
+	query := `SELECT r.id, r.module, r.started_at, r.duration_ms, r.status, r.targeted,
+		COALESCE(SUM(s.adds), 0), COALESCE(SUM(s.changes), 0), COALESCE(SUM(s.destroys), 0), COUNT(s.run_id)
+		FROM runs r LEFT JOIN results s ON s.run_id = r.id WHERE 1 = 1`
+	var params []any
+	if filter.Module != "" {
+		query += " AND r.module = ?"
+		params = append(params, filter.Module)
+	}
+	if filter.Status != "" {
+		query += " AND r.status = ?"
+		params = append(params, filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND r.started_at >= ?"
+		params = append(params, filter.Since.Unix())
+	}
+	if !filter.Until.IsZero() {
+		query += " AND r.started_at < ?"
+		params = append(params, filter.Until.Unix())
+	}
+	query += " GROUP BY r.id ORDER BY r.started_at DESC, r.id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var runs []*historySummary
+	for rows.Next() {
+		run := &historySummary{}
+		var started, durationMs int64
+		if err := rows.Scan(&run.ID, &run.Module, &started, &durationMs, &run.Status, &run.Targeted,
+			&run.Totals.Add, &run.Totals.Change, &run.Totals.Destroy, &run.Regions); err != nil {
+			return nil, err
+		}
+		run.StartedAt = time.Unix(started, 0)
+		run.Duration = time.Duration(durationMs) * time.Millisecond
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Mode names the run's planning mode.
+func (e *historyEntry) Mode() string {
+	if e.Targeted {
+		return "targeted"
+	}
+	return "full"
+}
+
+func runHistoryShow(cmd *cobra.Command, args []string) {
+	showReport, _ := cmd.Flags().GetBool("report")
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		errorColor.Printf("❌ Error: invalid run id %q\n", args[0])
+		os.Exit(1)
+	}
+
+	db, err := openHistory()
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	entry, results, err := loadHistory(db, id)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if showReport {
+		if entry.Report == "" {
+			errorColor.Printf("❌ Error: run %d has no recorded report\n", id)
+			os.Exit(1)
+		}
+		fmt.Print(entry.Report)
+		return
+	}
+
+	fmt.Printf("Run %d: %s (%s)\n", entry.ID, entry.Module, entry.Mode())
+	fmt.Printf("  Started:  %s\n", entry.StartedAt.Format("2006-01-02 15:04:05 MST"))
+	fmt.Printf("  Duration: %s\n", entry.Duration.Round(time.Second))
+	fmt.Printf("  Status:   %s\n", entry.Status)
+	if entry.Error != "" {
+		fmt.Printf("  Error:    %s\n", entry.Error)
+	}
+	if entry.Commit != "" {
+		fmt.Printf("  Commit:   %s\n", entry.Commit)
+	}
+	if entry.Output != "" {
+		fmt.Printf("  Output:   %s\n", entry.Output)
+	}
+	if len(results) == 0 {
+		return
+	}
+
+	fmt.Println()
+	var lines [][]string
+	for _, r := range results {
+		counts := "no changes"
+		switch {
+		case r.Incomplete:
+			counts = "incomplete"
+		case r.Counts != nil:
+			counts = fmt.Sprintf("+%d ~%d -%d", r.Counts.Add, r.Counts.Change, r.Counts.Destroy)
+		}
+		lines = append(lines, []string{r.Partition, r.Env, r.Region, counts})
+	}
+	printTable([]string{"PARTITION", "ENV", "REGION", "PLAN"}, lines)
+}
+
+// loadHistory reads one recorded run and its results.
+func loadHistory(db *sql.DB, id int64) (*historyEntry, []*historyResult, error) {
+	entry := &historyEntry{ID: id}
+	var started, durationMs int64
+	err := db.QueryRow(`SELECT module, started_at, duration_ms, status, error, targeted, git_commit, output, report
+		FROM runs WHERE id = ?`, id).Scan(&entry.Module, &started, &durationMs, &entry.Status, &entry.Error,
+		&entry.Targeted, &entry.Commit, &entry.Output, &entry.Report)
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("no recorded run %d", id)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	entry.StartedAt = time.Unix(started, 0)
+	entry.Duration = time.Duration(durationMs) * time.Millisecond
+
+	rows, err := db.Query(`SELECT partition, env, region, adds, changes, destroys, incomplete
+		FROM results WHERE run_id = ? ORDER BY partition, env, region`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var results []*historyResult
+	for rows.Next() {
+		r := &historyResult{}
+		var adds, changes, destroys sql.NullInt64
+		if err := rows.Scan(&r.Partition, &r.Env, &r.Region, &adds, &changes, &destroys, &r.Incomplete); err != nil {
+			return nil, nil, err
+		}
+		if adds.Valid {
+			r.Counts = &PlanCounts{Add: int(adds.Int64), Change: int(changes.Int64), Destroy: int(destroys.Int64)}
+		}
+		results = append(results, r)
+	}
+	return entry, results, rows.Err()
+}
+
+// printTable writes left-aligned columns to stdout.
+func printTable(header []string, lines [][]string) {
+	widths := make([]int, len(header))
+	for _, line := range append([][]string{header}, lines...) {
+		for i, cell := range line {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	for _, line := range append([][]string{header}, lines...) {
+		cells := make([]string, len(line))
+		for i, cell := range line {
+			cells[i] = fmt.Sprintf("%-*s", widths[i], cell)
+		}
+		fmt.Println("  " + strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+}
@@ -0,0 +1,219 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Hooks are user commands run at fixed points of a run. Each receives the
+// run context as JSON on stdin and as TFPRGEN_* environment variables; a
+// failing hook fails the run, or the state for pre_state and post_state.
+type Hooks struct {
+	PreRun     []string `yaml:"pre_run"`     // before any plans start
+	PreState   []string `yaml:"pre_state"`   // before each targeted state's plan
+	PostState  []string `yaml:"post_state"`  // after each targeted state's plan, failed or not
+	PostGroup  []string `yaml:"post_group"`  // after each partition's plans finish
+	PostRender []string `yaml:"post_render"` // after pr-ready.md and extra formats are written
+	PrePublish []string `yaml:"pre_publish"` // last, before the report is handed off
+	PostRun    []string `yaml:"post_run"`    // once the run is over, failed or not
+}
+
+// hookContext is the JSON document passed to hooks on stdin.
+type hookContext struct {
+	Hook      string   `json:"hook"`
+	Module    string   `json:"module"`
+	OutputDir string   `json:"output_dir"`
+	Manifest  string   `json:"manifest"`
+	Targeted  bool     `json:"targeted"`
+	States    []*State `json:"states,omitempty"`
+	GitCommit string   `json:"git_commit,omitempty"`
+
+	// Partition and PlansFile are set for post_group.
+	Partition string `json:"partition,omitempty"`
+	PlansFile string `json:"plans_file,omitempty"`
+
+	// State is set for pre_state and post_state.
+	State *State `json:"state,omitempty"`
+
+	// Reports maps each written report format to its path, from post_render on.
+	Reports map[string]string `json:"reports,omitempty"`
+
+	// Status and Error are set for post_state and post_run: "succeeded" or
+	// "failed", and why.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// env is the context as environment variables, for hooks that are plain
+// commands rather than scripts reading stdin.
+func (c *hookContext) env() []string {
+	env := []string{
+		"TFPRGEN_HOOK=" + c.Hook,
+		"TFPRGEN_MODULE=" + c.Module,
+		"TFPRGEN_OUTPUT_DIR=" + c.OutputDir,
+		"TFPRGEN_MANIFEST=" + c.Manifest,
+	}
+	optional := []struct{ name, value string }{
+		{"TFPRGEN_GIT_COMMIT", c.GitCommit},
+		{"TFPRGEN_PARTITION", c.Partition},
+		{"TFPRGEN_PLANS_FILE", c.PlansFile},
+		{"TFPRGEN_STATUS", c.Status},
+		{"TFPRGEN_ERROR", c.Error},
+	}
+	if c.State != nil {
+		optional = append(optional, []struct{ name, value string }{
+			{"TFPRGEN_STATE", c.State.Path},
+			{"TFPRGEN_WORKSPACE", c.State.Workspace},
+		}...)
+	}
+	for _, v := range optional {
+		if v.value != "" {
+			env = append(env, v.name+"="+v.value)
+		}
+	}
+	return env
+}
+
+func (h *Hooks) validate() error {
+	for name, commands := range h.byName() {
+		for _, command := range commands {
+			argv, err := splitCommandLine(command)
+			if err == nil && len(argv) == 0 {
+				err = fmt.Errorf("empty command")
+			}
+			if err != nil {
+				return fmt.Errorf("invalid %s hook %q: %v", name, command, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (h *Hooks) byName() map[string][]string {
+	return map[string][]string{
+		"pre_run":     h.PreRun,
+		"pre_state":   h.PreState,
+		"post_state":  h.PostState,
+		"post_group":  h.PostGroup,
+		"post_render": h.PostRender,
+		"pre_publish": h.PrePublish,
+		"post_run":    h.PostRun,
+	}
+}
+
+// newHookContext returns the context shared by every hook of this run.
+func (pg *PlanGenerator) newHookContext(targeted bool, states []*State) *hookContext {
+	return &hookContext{
+		Module:    pg.ModuleName,
+		OutputDir: pg.OutputDir,
+		Manifest:  filepath.Join(pg.OutputDir, manifestFile),
+		Targeted:  targeted,
+		States:    states,
+		GitCommit: gitHead(),
+	}
+}
+
+// runHooks runs the commands configured for hook in order, stopping at the
+// first failure. Their output goes straight to the terminal.
+func (pg *PlanGenerator) runHooks(hook string, ctx hookContext) error {
+	return pg.runHooksEnv(hook, ctx, os.Environ())
+}
+
+// runHooksEnv is runHooks with the environment the context is added to.
+func (pg *PlanGenerator) runHooksEnv(hook string, ctx hookContext, env []string) error {
+	commands := pg.Config.Hooks.byName()[hook]
+	if len(commands) == 0 {
+		return nil
+	}
+	ctx.Hook = hook
+	data, err := json.Marshal(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, command := range commands {
+		argv, _ := splitCommandLine(command) // checked by Config.validate
+		if pg.Verbose {
+			fmt.Fprintf(console, "🪝 Running %s hook: %s\n", hook, command)
+		}
+		err := pg.execute(pg.ctx, &Command{
+			Args:   argv,
+			Env:    append(env[:len(env):len(env)], ctx.env()...),
+			Stdin:  bytes.NewReader(data),
+			Stdout: os.Stderr, // stdout is reserved for data
+			Stderr: os.Stderr,
+		})
+		if err != nil {
+			return fmt.Errorf("%s hook %q failed: %v", hook, command, err)
+		}
+	}
+	return nil
+}
+
+// runPostGroupHooks runs the post_group hooks for a finished partition.
+func (pg *PlanGenerator) runPostGroupHooks(p *Partition) error {
+	ctx := *pg.hookCtx
+	ctx.Partition = p.Name
+	ctx.PlansFile = filepath.Join(pg.OutputDir, p.OutputFile)
+	return pg.runHooks("post_group", ctx)
+}
+
+// planWithHooks plans a targeted state between its pre_state and post_state
+// hooks, which run with the state's credentials. A failing hook fails the
+// state.
+func (pg *PlanGenerator) planWithHooks(ctx context.Context, p *Partition, state *State) ([]byte, error) {
+	hooks := pg.Config.Hooks
+	if len(hooks.PreState) == 0 && len(hooks.PostState) == 0 {
+		return pg.planCached(ctx, p, state)
+	}
+	stateCtx := *pg.hookCtx
+	stateCtx.States = nil
+	stateCtx.Partition = p.Name
+	stateCtx.State = state
+	env := pg.commandEnv(state)
+	if env == nil {
+		env = os.Environ()
+	}
+
+	if err := pg.runHooksEnv("pre_state", stateCtx, env); err != nil {
+		return nil, err
+	}
+	output, err := pg.planCached(ctx, p, state)
+	if ctx.Err() != nil {
+		// Cancelled or interrupted, so there's no outcome to report
+		return output, err
+	}
+	stateCtx.Status = stateSucceeded
+	if err != nil {
+		stateCtx.Status, stateCtx.Error = stateFailed, err.Error()
+	}
+	if hookErr := pg.runHooksEnv("post_state", stateCtx, env); hookErr != nil && err == nil {
+		return nil, hookErr
+	}
+	return output, err
+}
+
+// runPostRunHooks runs the post_run hooks of a run that got as far as
+// pre_run, and returns the run's outcome: runErr, or a failing hook's error
+// if the run succeeded. They don't run for interrupted runs.
+func (pg *PlanGenerator) runPostRunHooks(runErr error) error {
+	if pg.hookCtx == nil || pg.ctx.Err() != nil {
+		return runErr
+	}
+	ctx := *pg.hookCtx
+	ctx.Status = stateSucceeded
+	if runErr != nil {
+		ctx.Status, ctx.Error = stateFailed, runErr.Error()
+	}
+	if err := pg.runHooks("post_run", ctx); err != nil {
+		if runErr == nil {
+			return err
+		}
+		warningColor.Printf("⚠️  %v\n", err)
+	}
+	return runErr
+}
@@ -0,0 +1,42 @@
+package planner
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStateHooksAroundPlan(t *testing.T) {
+	fake := &fakeExecutor{stdout: stagingPlan}
+	pg := newTestGenerator(t, fake)
+	pg.Config.Hooks.PreState = []string{"./warm-cache.sh"}
+	pg.Config.Hooks.PostState = []string{"./notify.sh --state"}
+	state := &State{Path: "terragrunt_vpc/organizations/staging/us-east-1"}
+	pg.hookCtx = pg.newHookContext(true, []*State{state})
+	pg.pool = newWorkerPool(1, false, false)
+
+	if _, err := pg.planWithHooks(context.Background(), pg.Config.Partitions[0], state); err != nil {
+		t.Fatalf("planWithHooks: %v", err)
+	}
+	if len(fake.commands) != 3 {
+		t.Fatalf("ran %d commands, want the plan between two hooks", len(fake.commands))
+	}
+	if fake.commands[0][0] != "./warm-cache.sh" || fake.commands[1][0] != "kitman" || fake.commands[2][0] != "./notify.sh" {
+		t.Errorf("ran %v", fake.commands)
+	}
+}
+
+func TestHookContextEnv(t *testing.T) {
+	ctx := hookContext{Hook: "post_state", Module: "vpc", State: &State{Path: "live/staging"}, Status: stateFailed}
+	env := make(map[string]bool)
+	for _, kv := range ctx.env() {
+		env[kv] = true
+	}
+	for _, want := range []string{"TFPRGEN_HOOK=post_state", "TFPRGEN_MODULE=vpc", "TFPRGEN_STATE=live/staging", "TFPRGEN_STATUS=failed"} {
+		if !env[want] {
+			t.Errorf("env lacks %s", want)
+		}
+	}
+	if env["TFPRGEN_ERROR="] {
+		t.Error("env sets TFPRGEN_ERROR without an error")
+	}
+}
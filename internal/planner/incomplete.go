@@ -0,0 +1,49 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// incompleteFile marks an output directory whose run hasn't finished or
+// failed, so its partial plans aren't mistaken for real results.
+const incompleteFile = "INCOMPLETE"
+
+// markIncomplete writes the output directory's marker, saying why the run
+// isn't complete.
+func (pg *PlanGenerator) markIncomplete(reason string) {
+	text := fmt.Sprintf("This run of %s %s.\nIts plans and reports may be partial: don't review or apply them.\n", pg.moduleLabel(), reason)
+	if err := os.WriteFile(filepath.Join(pg.OutputDir, incompleteFile), []byte(text), 0644); err == nil {
+		pg.outputMarked = true
+	}
+}
+
+// markComplete removes the marker, and the checkpoints kept for --resume,
+// once every output is written.
+func (pg *PlanGenerator) markComplete() {
+	os.Remove(filepath.Join(pg.OutputDir, incompleteFile))
+	pg.dropCheckpoints()
+}
+
+// finishOutputDir leaves a failed run's output directory marked
+// incomplete with the error, or removes it with RemovePartial if the run
+// created it (scratch directories are kept for debugging).
+func (pg *PlanGenerator) finishOutputDir(runErr error) {
+	if !pg.outputMarked {
+		return
+	}
+	if runErr == nil {
+		pg.markComplete()
+		return
+	}
+	if pg.RemovePartial && pg.createdOutputDir && !pg.scratch {
+		if err := os.RemoveAll(pg.OutputDir); err != nil {
+			warningColor.Printf("⚠️  Couldn't remove the partial output in %s: %v\n", pg.OutputDir, err)
+			return
+		}
+		warningColor.Printf("🧹 Removed the partial output in %s (--remove-partial)\n", pg.OutputDir)
+		return
+	}
+	pg.markIncomplete("failed: " + runErr.Error())
+}
@@ -0,0 +1,37 @@
+package planner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFinishOutputDir(t *testing.T) {
+	pg := newTestGenerator(t, nil)
+	marker := filepath.Join(pg.OutputDir, incompleteFile)
+	pg.markIncomplete("is still running, or was killed")
+	pg.finishOutputDir(nil)
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("a finished run is still marked incomplete: %v", err)
+	}
+
+	pg.markIncomplete("is still running, or was killed")
+	pg.finishOutputDir(errors.New("staging plans failed"))
+	if text, _ := os.ReadFile(marker); !strings.Contains(string(text), "vpc failed: staging plans failed") {
+		t.Errorf("marker = %q, want the run's error", text)
+	}
+
+	// Only a directory the run created is removed
+	pg.RemovePartial = true
+	pg.finishOutputDir(errors.New("interrupted"))
+	if _, err := os.Stat(pg.OutputDir); err != nil {
+		t.Errorf("removed a directory the run didn't create: %v", err)
+	}
+	pg.createdOutputDir = true
+	pg.finishOutputDir(errors.New("interrupted"))
+	if _, err := os.Stat(pg.OutputDir); !os.IsNotExist(err) {
+		t.Errorf("partial output kept with --remove-partial: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package planner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// stateInputExts are the files of a state's parent directories hashed as
+// its inputs: terragrunt configs included from parent folders and the
+// variables they read.
+var stateInputExts = map[string]bool{".hcl": true, ".tf": true, ".tfvars": true, ".json": true, ".yaml": true, ".yml": true}
+
+// planCached runs a targeted plan with --incremental: a state whose inputs
+// hash the same as at its last complete plan reuses that plan's output
+// instead of planning again. Fresh complete plans are cached for the next
+// run. Binary plans (--save-plans) can't be reused, and anonymized runs
+// have pseudonyms of their own, so those states always plan.
+func (pg *PlanGenerator) planCached(ctx context.Context, p *Partition, state *State) ([]byte, error) {
+	if !pg.Incremental || state.PlanFile != "" || pg.Anonymize {
+		return pg.planWithRetries(ctx, p, state)
+	}
+	path, err := pg.planCachePath(p, state)
+	if err != nil {
+		warningColor.Printf("⚠️  --incremental: can't hash the inputs of %s, planning it: %v\n", state, err)
+		return pg.planWithRetries(ctx, p, state)
+	}
+	if output, err := os.ReadFile(path); err == nil {
+		pg.progress.log(state.String(), "--- inputs unchanged, reusing the cached plan (--incremental) ---")
+		pg.flushMu.Lock()
+		pg.reusedStates = append(pg.reusedStates, state.String())
+		pg.flushMu.Unlock()
+		return output, nil
+	}
+
+	output, err := pg.planWithRetries(ctx, p, state)
+	if err == nil && planComplete(output, p) {
+		if mkErr := os.MkdirAll(filepath.Dir(path), 0755); mkErr == nil {
+			os.WriteFile(path, output, 0644)
+		}
+	}
+	return output, err
+}
+
+// planComplete tells whether every region plan in a state's output reached
+// its summary, so it's worth reusing.
+func planComplete(output []byte, p *Partition) bool {
+	environments, _ := parsePlans(string(output), p)
+	for _, env := range environments {
+		for _, incomplete := range env.Incomplete {
+			if incomplete {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// planCachePath is where the plan of a state is cached, named after the
+// hash of everything its plan depends on locally: the plan command and its
+// var files, the module, the state's directory and the terragrunt inputs of
+// its parent directories.
+func (pg *PlanGenerator) planCachePath(p *Partition, state *State) (string, error) {
+	h := sha256.New()
+	argv, err := pg.Config.Runner.PlanCommand(p, pg.ModuleName, state.Path, pg.planArgs())
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "argv %q\nstate %s env=%s region=%s\n", argv, state, state.Env, state.Region)
+	for _, path := range pg.VarFiles {
+		sum, err := hashFile(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "var-file %s %s\n", path, sum)
+	}
+
+	moduleDir := pg.moduleDir()
+	if _, err := os.Stat(moduleDir); err == nil {
+		sum, err := hashTree(moduleDir)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "module %s\n", sum)
+	}
+	sum, err := hashTree(state.Path)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "state %s\n", sum)
+	for _, rule := range pg.Config.Masking {
+		fmt.Fprintf(h, "mask %q %q\n", rule.Pattern, rule.Replacement)
+	}
+
+	for dir := filepath.Dir(filepath.Clean(state.Path)); ; dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return "", err
+		}
+		var names []string
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && stateInputExts[strings.ToLower(filepath.Ext(entry.Name()))] {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sum, err := hashFile(filepath.Join(dir, name))
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "input %s %s\n", filepath.ToSlash(filepath.Join(dir, name)), sum)
+		}
+		if parent := filepath.Dir(dir); dir == "." || parent == dir {
+			break
+		}
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "tfprgen", "plans", hex.EncodeToString(h.Sum(nil))+".txt"), nil
+}
+
+// reusedRegion tells whether a region plan was reused from the cache.
+func (pg *PlanGenerator) reusedRegion(p *Partition, env, region string) bool {
+	for _, name := range pg.reusedStates {
+		if stateInRegion(p, name, env, region) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pluginCacheEnv is terraform's shared provider cache; --init points every
+// init and plan at one unless it is already set.
+const pluginCacheEnv = "TF_PLUGIN_CACHE_DIR"
+
+// initStates initializes the states' directories before any plan runs, so
+// plans only time planning. Each provider version is downloaded once: the
+// first directory needing a provider version not cached yet is initialized
+// on its own to fill the cache, then the rest in parallel from it (the
+// cache isn't safe for concurrent writers). Directories without a lock file
+// can't be told apart and are initialized on their own too.
+func (pg *PlanGenerator) initStates(states []*State) error {
+	if pg.Config.Runner.Init == "" {
+		return fmt.Errorf("--init: runner.init isn't set for the %s runner", pg.Config.Runner.Name)
+	}
+	if os.Getenv(pluginCacheEnv) == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return fmt.Errorf("--init: %v", err)
+		}
+		pg.pluginCache = filepath.Join(dir, "tfprgen", "plugin-cache")
+		if err := os.MkdirAll(pg.pluginCache, 0755); err != nil {
+			return fmt.Errorf("--init: %v", err)
+		}
+	}
+
+	// Workspaces of a directory share its .terraform
+	var dirs []*State
+	seenDir := make(map[string]bool)
+	for _, state := range states {
+		if !seenDir[state.Path] {
+			seenDir[state.Path] = true
+			dirs = append(dirs, state)
+		}
+	}
+
+	cached := make(map[string]bool)
+	var seeds, rest []*State
+	for _, state := range dirs {
+		providers := lockedProviders(state.Path)
+		fresh := len(providers) == 0
+		for _, provider := range providers {
+			if !cached[provider] {
+				cached[provider] = true
+				fresh = true
+			}
+		}
+		if fresh {
+			seeds = append(seeds, state)
+		} else {
+			rest = append(rest, state)
+		}
+	}
+
+	infoColor.Printf("🔧 Initializing %d state directories (%d to fill the provider cache, %d from it)...\n", len(dirs), len(seeds), len(rest))
+	start := time.Now()
+	for _, state := range seeds {
+		if err := pg.initState(pg.ctx, state); err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(rest))
+	for i, state := range rest {
+		wg.Add(1)
+		go func(i int, state *State) {
+			defer wg.Done()
+			pool := pg.poolFor(pg.Config.PartitionFor(state.String()))
+			pool.acquire()
+			defer pool.releaseUntimed()
+			errs[i] = pg.initState(pg.ctx, state)
+		}(i, state)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	took := ""
+	if !pg.Deterministic {
+		took = " in " + time.Since(start).Round(time.Second).String()
+	}
+	successColor.Printf("🔧 Initialized %d state directories%s (%d provider versions)\n", len(dirs), took, len(cached))
+	return nil
+}
+
+// initState runs the runner's init command for one state.
+func (pg *PlanGenerator) initState(ctx context.Context, state *State) error {
+	p := pg.Config.PartitionFor(state.String())
+	if p == nil {
+		// runTargetedPlans skips it as well
+		return nil
+	}
+	argv, err := pg.Config.Runner.InitCommand(p, pg.ModuleName, state.Path)
+	if err != nil {
+		return err
+	}
+	if pg.Verbose {
+		fmt.Fprintf(console, "    Initializing: %s\n", state.Path)
+	}
+	var output bytes.Buffer
+	err = pg.execute(ctx, &Command{Args: argv, Env: pg.commandEnv(state), Stdout: &output, Stderr: &output})
+	if err != nil {
+		return fmt.Errorf("failed to init %s: %v\n%s", state.Path, err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// commandEnv is the environment of a state's runner commands, nil when
+// it's just the inherited one.
+func (pg *PlanGenerator) commandEnv(state *State) []string {
+	var env []string
+	if state.Workspace != "" {
+		env = append(env, "TF_WORKSPACE="+state.Workspace)
+	}
+	if pg.pluginCache != "" {
+		env = append(env, pluginCacheEnv+"="+pg.pluginCache)
+	}
+	creds := pg.credentialsEnv(pg.stateCredentials(state))
+	if env == nil && creds == nil {
+		return nil
+	}
+	return append(inheritedEnv(creds != nil), append(env, creds...)...)
+}
+
+// lockedProviders lists the "source version" pairs pinned in a state's
+// .terraform.lock.hcl, empty without one.
+func lockedProviders(dir string) []string {
+	data, err := os.ReadFile(filepath.Join(dir, ".terraform.lock.hcl"))
+	if err != nil {
+		return nil
+	}
+	var providers []string
+	for _, lock := range parseLockFile(string(data)) {
+		providers = append(providers, lock.Source+" "+lock.Version)
+	}
+	sort.Strings(providers)
+	return providers
+}
@@ -0,0 +1,91 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// interruptGrace is how long an interrupted command has to exit, e.g.
+// for terraform to release its state lock, before it's killed.
+const interruptGrace = 10 * time.Second
+
+// interruptContext is cancelled on the first SIGINT or SIGTERM, which
+// interrupts the run: its plans are stopped and the report covers those
+// finished. A second signal quits right away. stop releases the signals.
+func interruptContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			warningColor.Println("\n⛔ Interrupted: stopping the plans and reporting those finished (interrupt again to quit now)")
+			cancel()
+		case <-done:
+			signal.Stop(signals)
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		cancel()
+	}
+}
+
+// interrupted tells whether the run was cancelled as a whole before its
+// plans were done.
+func (pg *PlanGenerator) interrupted() bool {
+	return pg.stopped != nil
+}
+
+// stopReason is why an interrupted run's states weren't planned:
+// "timeout" once --timeout passed, else "interrupted".
+func (pg *PlanGenerator) stopReason() string {
+	if pg.stopped == context.DeadlineExceeded {
+		return "timeout"
+	}
+	return "interrupted"
+}
+
+// interruptedRegion tells whether an incomplete region plan was cut short
+// by the interruption rather than by an error.
+func (pg *PlanGenerator) interruptedRegion(p *Partition, env, region string) bool {
+	for _, name := range pg.interruptedStates {
+		if stateInRegion(p, name, env, region) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeInterruptedNote lists the states an interrupted run didn't finish
+// and those cancelled from the TUI.
+func (pg *PlanGenerator) writeInterruptedNote(output io.StringWriter) {
+	if pg.interrupted() {
+		if pg.stopReason() == "timeout" {
+			output.WriteString(fmt.Sprintf("> %s**Timed out:** the run reached its %s `--timeout` before every state was planned, so this report is partial.\n", pg.renderer().Icon("⏰"), pg.Timeout))
+		} else {
+			output.WriteString("> " + pg.renderer().Icon("⛔") + "**Interrupted:** the run was cancelled before every state was planned, so this report is partial.\n")
+		}
+		sort.Strings(pg.interruptedStates)
+		if len(pg.interruptedStates) > 0 {
+			output.WriteString(">\n")
+		}
+		for _, name := range pg.interruptedStates {
+			output.WriteString(fmt.Sprintf("> - `%s`: not planned (%s)\n", name, pg.stopReason()))
+		}
+		output.WriteString("\n")
+	}
+	if len(pg.cancelledStates) > 0 {
+		sort.Strings(pg.cancelledStates)
+		output.WriteString("> " + pg.renderer().Icon("⊘") + "Cancelled during the run, so not planned: `" + strings.Join(pg.cancelledStates, "`, `") + "`.\n\n")
+	}
+}
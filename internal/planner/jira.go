@@ -0,0 +1,237 @@
+package planner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// jiraKeyRegex is a Jira issue key, e.g. OPS-123.
+var jiraKeyRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]+-[0-9]+$`)
+
+// jiraBranchKeyRegex finds issue keys in branch names, like
+// feature/OPS-123-rotate-keys.
+var jiraBranchKeyRegex = regexp.MustCompile(`(?i)(?:^|[^A-Za-z0-9])([A-Z][A-Z0-9_]+-[0-9]+)`)
+
+var jiraProjectRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]+$`)
+
+// JiraConfig links runs to the Jira issue of the change they plan, for
+// change management: the report links the issue, and the plan summary is
+// commented on it when JIRA_API_TOKEN is set.
+type JiraConfig struct {
+	// URL is the Jira site, e.g. https://example.atlassian.net; JIRA_URL
+	// by default. Issue keys are only looked for in branch names when
+	// either is set.
+	URL string `yaml:"url"`
+	// Projects are the project keys looked for in branch names, e.g. [OPS],
+	// in any case, so names like release-2024 aren't taken for an issue;
+	// without them only upper case keys are.
+	Projects []string `yaml:"projects"`
+}
+
+func (c JiraConfig) validate() error {
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("url must be an http(s) URL, got %q", c.URL)
+		}
+	}
+	for _, project := range c.Projects {
+		if !jiraProjectRegex.MatchString(project) {
+			return fmt.Errorf("projects: %q isn't a project key, like OPS", project)
+		}
+	}
+	return nil
+}
+
+// site is the Jira site issues are linked to, "" if none is configured.
+func (c JiraConfig) site() string {
+	site := c.URL
+	if site == "" {
+		site = os.Getenv("JIRA_URL")
+	}
+	return strings.TrimSuffix(site, "/")
+}
+
+// branchKey is the first issue key in branch, "" if it has none.
+func (c JiraConfig) branchKey(branch string) string {
+	for _, m := range jiraBranchKeyRegex.FindAllStringSubmatch(branch, -1) {
+		key := m[1]
+		project, _, _ := strings.Cut(strings.ToUpper(key), "-")
+		switch {
+		case len(c.Projects) > 0 && slices.Contains(c.Projects, project):
+			return strings.ToUpper(key)
+		case len(c.Projects) == 0 && jiraKeyRegex.MatchString(key):
+			return key
+		}
+	}
+	return ""
+}
+
+// jiraClient comments on Jira issues through the REST API, authenticated
+// with JIRA_USER and JIRA_API_TOKEN (Jira Cloud), or with JIRA_API_TOKEN
+// alone as a personal access token (Data Center).
+type jiraClient struct {
+	url   string
+	user  string
+	token string
+	http  *http.Client
+}
+
+// issueURL is where the issue with key is browsed.
+func issueURL(site, key string) string {
+	return site + "/browse/" + key
+}
+
+// startJira finds the run's Jira issue, --jira or else a key in the branch
+// name, and its client if JIRA_API_TOKEN is set. The scratch runs of
+// rerun-failed have none: the run they're merged into is commented on.
+func (pg *PlanGenerator) startJira() {
+	site := pg.Config.Jira.site()
+	if site == "" || pg.rerun {
+		return
+	}
+	if pg.Jira == "" {
+		branch := os.Getenv("GITHUB_HEAD_REF")
+		if branch == "" {
+			branch, _ = currentBranch()
+		}
+		if pg.Jira = pg.Config.Jira.branchKey(branch); pg.Jira == "" {
+			return
+		}
+	}
+	token := os.Getenv("JIRA_API_TOKEN")
+	if token == "" {
+		if pg.Verbose {
+			fmt.Fprintf(console, "🎫 Linking %s; set JIRA_API_TOKEN to also comment the plan summary on it\n", pg.Jira)
+		}
+		return
+	}
+	pg.jira = &jiraClient{url: site, user: os.Getenv("JIRA_USER"), token: token, http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// comment adds body, in Jira's wiki markup, as a comment on the issue.
+func (c *jiraClient) comment(key, body string) error {
+	data, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.url+"/rest/api/2/issue/"+url.PathEscape(key)+"/comment", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// postJiraComment comments the plan summary on the run's Jira issue.
+// Failures only warn: the report is out either way.
+func (pg *PlanGenerator) postJiraComment(results []*PartitionResult) {
+	if pg.jira == nil {
+		return
+	}
+	if err := pg.jira.comment(pg.Jira, pg.jiraSummary(results)); err != nil {
+		warningColor.Printf("⚠️  Commenting on %s failed: %v\n", pg.Jira, err)
+		return
+	}
+	successColor.Printf("🎫 Commented the plan summary on %s\n", pg.Jira)
+}
+
+// jiraSummary is the plan summary commented on the Jira issue: the totals,
+// a row per region plan and links to the pull request and workflow run.
+func (pg *PlanGenerator) jiraSummary(results []*PartitionResult) string {
+	var b strings.Builder
+	title := "Terraform plan: " + pg.ModuleName
+	if pg.Destroy {
+		title += " (DESTROY PLAN)"
+	}
+	fmt.Fprintf(&b, "h3. %s\n\n", title)
+	total, incomplete := planTotals(results)
+	fmt.Fprintf(&b, "*%d to add, %d to change, %d to destroy*", total.Add, total.Change, total.Destroy)
+	if head := gitHead(); head != "" {
+		fmt.Fprintf(&b, " at commit {{%s}}", shortSHA(head))
+	}
+	b.WriteString(".\n")
+	if incomplete {
+		b.WriteString("(!) Some plans errored before their summary; their changes aren't counted.\n")
+	}
+	if len(pg.failures) > 0 {
+		var names []string
+		for _, f := range pg.failures {
+			names = append(names, f.Name)
+		}
+		fmt.Fprintf(&b, "(x) %d plan(s) failed: %s\n", len(names), strings.Join(names, ", "))
+	}
+
+	b.WriteString("\n||Environment||Region||Add||Change||Destroy||\n")
+	for _, result := range results {
+		for _, env := range result.Environments {
+			for _, region := range env.Regions {
+				if env.Incomplete[region] {
+					fmt.Fprintf(&b, "|%s|%s|(!)|(!)|(!)|\n", env.Name, region)
+					continue
+				}
+				counts, _ := ParsePlanCounts(env.Plans[region])
+				fmt.Fprintf(&b, "|%s|%s|%d|%d|%d|\n", env.Name, region, counts.Add, counts.Change, counts.Destroy)
+			}
+		}
+	}
+
+	var links []string
+	if url := pullRequestURL(pg.PRNumber); url != "" {
+		links = append(links, fmt.Sprintf("[Pull request|%s]", url))
+	}
+	if url := workflowRunURL(); url != "" {
+		links = append(links, fmt.Sprintf("[Workflow run|%s]", url))
+	}
+	if len(links) > 0 {
+		fmt.Fprintf(&b, "\n%s\n", strings.Join(links, " · "))
+	}
+	return b.String()
+}
+
+// pullRequestURL is the pull request the run is for, "" outside pull
+// requests.
+func pullRequestURL(number int) string {
+	repo := os.Getenv("GITHUB_REPOSITORY")
+	pr, err := pullRequestNumber(number)
+	if repo == "" || err != nil {
+		return ""
+	}
+	server := os.Getenv("GITHUB_SERVER_URL")
+	if server == "" {
+		server = "https://github.com"
+	}
+	return fmt.Sprintf("%s/%s/pull/%d", strings.TrimSuffix(server, "/"), repo, pr)
+}
+
+// writeJiraNote links the run's Jira issue.
+func (pg *PlanGenerator) writeJiraNote(output *os.File) {
+	if pg.Jira == "" {
+		return
+	}
+	output.WriteString(fmt.Sprintf("> %sJira: [%s](%s)\n\n", pg.renderer().Icon("🎫"), pg.Jira, issueURL(pg.Config.Jira.site(), pg.Jira)))
+}
@@ -0,0 +1,22 @@
+package planner
+
+import "testing"
+
+func TestJiraBranchKey(t *testing.T) {
+	for _, tc := range []struct {
+		projects []string
+		branch   string
+		want     string
+	}{
+		{nil, "feature/OPS-123-rotate-keys", "OPS-123"},
+		{nil, "ops-123-rotate-keys", ""},
+		{nil, "release-2024", ""},
+		{[]string{"OPS"}, "ops-123-rotate-keys", "OPS-123"},
+		{[]string{"OPS"}, "release-2024/ops-7", "OPS-7"},
+		{[]string{"OPS"}, "feature/INFRA-9", ""},
+	} {
+		if got := (JiraConfig{Projects: tc.projects}).branchKey(tc.branch); got != tc.want {
+			t.Errorf("branchKey(%q) with projects %v = %q, want %q", tc.branch, tc.projects, got, tc.want)
+		}
+	}
+}
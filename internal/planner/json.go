@@ -0,0 +1,159 @@
+package planner
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+type jsonReport struct {
+	Module     string           `json:"module"`
+	Destroy    bool             `json:"destroy,omitempty"`
+	Partitions []*jsonPartition `json:"partitions"`
+	Warnings   []string         `json:"warnings"`
+	// Policy is what the --policy-dir policies found in the plans.
+	Policy *policyResult `json:"policy,omitempty"`
+	// Security is what the security scanner found in the targeted states.
+	Security *securityResult `json:"security,omitempty"`
+	// TagPolicy lists new resources missing required tags.
+	TagPolicy *tagPolicyResult `json:"tag_policy,omitempty"`
+	// Lint lists what tflint found, once for all the states it affects.
+	Lint *lintResult `json:"lint,omitempty"`
+	// Cost is the monthly cost change Infracost estimates for the saved
+	// plans.
+	Cost *costReport `json:"cost,omitempty"`
+	// VersionSkew lists module and provider versions that differ between
+	// the module's states.
+	VersionSkew []*versionSkew `json:"version_skew,omitempty"`
+	// ToolVersions are the terraform and terragrunt versions each planned
+	// state ran with.
+	ToolVersions []*stateTools `json:"tool_versions,omitempty"`
+	// Timings are how long each state's plan took, slowest first; left
+	// out with --deterministic.
+	Timings []*stateTiming `json:"timings,omitempty"`
+	// SharedChanges lists files changed on the branch that other states
+	// read, with the states discovery didn't plan.
+	SharedChanges []*sharedChange `json:"shared_changes,omitempty"`
+	// Failed lists the plans that failed under --keep-going.
+	Failed []*jsonFailure `json:"failed,omitempty"`
+	// Masked counts the values the masking rules replaced per state, or
+	// per partition in full runs.
+	Masked map[string]int `json:"masked,omitempty"`
+	// Generator is the build of the generator that produced the report.
+	Generator BuildInfo `json:"generator"`
+}
+
+type jsonFailure struct {
+	Partition string `json:"partition"`
+	Name      string `json:"name"`
+	Error     string `json:"error"`
+	// Lock is who holds the state lock the plan failed on, if it did.
+	Lock *jsonLock `json:"lock,omitempty"`
+}
+
+type jsonLock struct {
+	ID        string `json:"id,omitempty"`
+	Who       string `json:"who,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	Created   string `json:"created,omitempty"`
+}
+
+type jsonPartition struct {
+	Name         string             `json:"name"`
+	Environments []*jsonEnvironment `json:"environments"`
+	Warnings     []string           `json:"warnings,omitempty"`
+}
+
+type jsonEnvironment struct {
+	Name    string        `json:"name"`
+	Regions []*jsonRegion `json:"regions"`
+}
+
+type jsonRegion struct {
+	Region     string      `json:"region"`
+	Incomplete bool        `json:"incomplete,omitempty"`
+	Counts     *PlanCounts `json:"counts,omitempty"`
+	Plan       string      `json:"plan"`
+	// Attempts is set when a state of the region was retried.
+	Attempts int `json:"attempts,omitempty"`
+	// Cached is set when the region's plan was reused (--incremental).
+	Cached bool `json:"cached,omitempty"`
+}
+
+// writeJSON writes the parsed results, including parse warnings, for
+// tooling that would otherwise scrape pr-ready.md.
+func (pg *PlanGenerator) writeJSON(path string, results []*PartitionResult) error {
+	data, err := pg.jsonReport(results)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// jsonReport renders report.json, which formatters also receive.
+func (pg *PlanGenerator) jsonReport(results []*PartitionResult) ([]byte, error) {
+	report := jsonReport{
+		Module:        pg.ModuleName,
+		Destroy:       pg.Destroy,
+		Warnings:      allWarnings(results),
+		Policy:        pg.policy,
+		Security:      pg.security,
+		TagPolicy:     pg.tagPolicy,
+		Lint:          pg.lint,
+		Cost:          pg.cost,
+		VersionSkew:   pg.skew,
+		ToolVersions:  pg.toolVersions,
+		SharedChanges: pg.blastRadius,
+		Masked:        pg.maskedCounts(),
+		Generator:     CurrentBuild(),
+	}
+	if !pg.Deterministic {
+		report.Timings = pg.timings
+	}
+	if report.Warnings == nil {
+		report.Warnings = []string{}
+	}
+
+	for _, f := range pg.failures {
+		failure := &jsonFailure{Partition: f.Partition.Name, Name: f.Name, Error: f.Err.Error()}
+		var lockErr *stateLockError
+		if errors.As(f.Err, &lockErr) {
+			failure.Lock = &jsonLock{ID: lockErr.Lock.ID, Who: lockErr.Lock.Who, Operation: lockErr.Lock.Operation, Created: lockErr.Lock.Created}
+		}
+		report.Failed = append(report.Failed, failure)
+	}
+
+	for _, result := range results {
+		partition := &jsonPartition{
+			Name:         result.Partition.Name,
+			Environments: []*jsonEnvironment{},
+			Warnings:     result.Warnings,
+		}
+		for _, env := range result.Environments {
+			environment := &jsonEnvironment{Name: env.Name}
+			for _, region := range env.Regions {
+				r := &jsonRegion{
+					Region:     region,
+					Incomplete: env.Incomplete[region],
+					Plan:       env.Plans[region],
+				}
+				if counts, ok := ParsePlanCounts(r.Plan); ok {
+					r.Counts = &counts
+				}
+				if attempts := pg.regionAttempts(result.Partition, env.Name, region); attempts > 1 {
+					r.Attempts = attempts
+				}
+				r.Cached = pg.reusedRegion(result.Partition, env.Name, region)
+				environment.Regions = append(environment.Regions, r)
+			}
+			partition.Environments = append(partition.Environments, environment)
+		}
+		report.Partitions = append(report.Partitions, partition)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
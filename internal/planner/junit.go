@@ -0,0 +1,100 @@
+package planner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemErr string          `xml:"system-err,omitempty"` // parse warnings
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnit maps every planned state to a JUnit test case: one suite per
+// partition, one case per environment/region. Incomplete plans are failures;
+// plans that destroy resources pass but carry a warning message, and plans
+// failed under --keep-going are cases of their own. Parse warnings go to
+// each suite's system-err.
+func (pg *PlanGenerator) writeJUnit(path string, results []*PartitionResult) error {
+	suites := junitTestSuites{Name: fmt.Sprintf("terraform-pr-generator %s", pg.ModuleName)}
+	if pg.Destroy {
+		suites.Name += " (destroy plan)"
+	}
+
+	for _, result := range results {
+		suite := junitTestSuite{Name: result.Partition.Name}
+		if len(result.Warnings) > 0 {
+			suite.SystemErr = "WARNING: " + strings.Join(result.Warnings, "\nWARNING: ")
+		}
+		for _, env := range result.Environments {
+			for _, region := range env.Regions {
+				body := env.Plans[region]
+				tc := junitTestCase{
+					Name:      region,
+					ClassName: fmt.Sprintf("%s.%s", result.Partition.Name, env.Name),
+				}
+				if env.Incomplete[region] {
+					tc.Failure = &junitFailure{
+						Message: "plan did not complete (no Plan: summary found)",
+						Type:    "IncompletePlan",
+						Body:    body,
+					}
+					suite.Failures++
+				} else if counts, ok := ParsePlanCounts(body); ok && counts.Destroy > 0 {
+					tc.SystemOut = fmt.Sprintf("WARNING: plan destroys %d resource(s)\n\n%s", counts.Destroy, body)
+				} else {
+					tc.SystemOut = body
+				}
+				suite.Cases = append(suite.Cases, tc)
+				suite.Tests++
+			}
+		}
+		for _, f := range pg.failures {
+			if f.Partition != result.Partition {
+				continue
+			}
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      f.Name,
+				ClassName: result.Partition.Name,
+				Failure:   &junitFailure{Message: "plan failed", Type: "FailedPlan", Body: f.Err.Error()},
+			})
+			suite.Tests++
+			suite.Failures++
+		}
+		suites.Suites = append(suites.Suites, suite)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
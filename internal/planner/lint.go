@@ -0,0 +1,266 @@
+package planner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// lintSeverities orders tflint severities, most severe first, with their
+// icons.
+var lintSeverities = []struct{ name, icon string }{
+	{"error", "🔴"},
+	{"warning", "🟡"},
+	{"notice", "🔵"},
+}
+
+// lintResult is what tflint found in the configuration of the planned
+// states.
+type lintResult struct {
+	// Dirs is how many directories were linted.
+	Dirs     int            `json:"dirs"`
+	Findings []*lintFinding `json:"findings"`
+}
+
+// lintFinding is one issue tflint reports, once for all the states it
+// affects: the states of a module share its source, so every region would
+// otherwise repeat it.
+type lintFinding struct {
+	Rule     string   `json:"rule"`
+	Severity string   `json:"severity"`
+	Message  string   `json:"message"`
+	File     string   `json:"file"` // slash-separated, relative to the repo root
+	Line     int      `json:"line"`
+	Link     string   `json:"link,omitempty"`
+	States   []string `json:"states"` // env/region, or the state's path
+}
+
+// tflintOutput is `tflint --format json` output.
+type tflintOutput struct {
+	Issues []struct {
+		Rule struct {
+			Name     string `json:"name"`
+			Severity string `json:"severity"`
+			Link     string `json:"link"`
+		} `json:"rule"`
+		Message string `json:"message"`
+		Range   struct {
+			Filename string `json:"filename"`
+			Start    struct {
+				Line int `json:"line"`
+			} `json:"start"`
+		} `json:"range"`
+	} `json:"issues"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// tflintInstalled tells whether tflint can run, or its output was
+// recorded, when replaying.
+func (pg *PlanGenerator) tflintInstalled() bool {
+	if pg.replaying() {
+		return true
+	}
+	_, err := exec.LookPath("tflint")
+	return err == nil
+}
+
+// lintDirs lists the directories with the terraform configuration of a
+// state: the module's directory, the state's own directory where it has
+// .tf files (workspace runners, plain terraform states) and the local
+// module sources of its terragrunt config.
+func (pg *PlanGenerator) lintDirs(dir string) []string {
+	var dirs []string
+	if module := pg.moduleDir(); !pg.Config.Runner.Workspaces && hasTerraformFiles(module) {
+		dirs = append(dirs, module)
+	}
+	if hasTerraformFiles(dir) {
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	for _, input := range stateInputs(dir) {
+		if info, err := os.Stat(input); err == nil && info.IsDir() && hasTerraformFiles(input) && !contains(dirs, filepath.FromSlash(input)) {
+			dirs = append(dirs, filepath.FromSlash(input))
+		}
+	}
+	return dirs
+}
+
+func hasTerraformFiles(dir string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	return len(matches) > 0
+}
+
+// lintStates runs tflint once in every directory the planned states are
+// configured from (in full runs, the module's states plan_all covers) and
+// merges the findings shared by states. A directory tflint can't lint
+// fails the run's lint, since its findings would silently be missing.
+func (pg *PlanGenerator) lintStates() (*lintResult, error) {
+	states := pg.plannedStates
+	if states == nil {
+		dirs, err := findModuleStates(pg.Config.Runner.WorkingDir, pg.ModuleName)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			if pg.Config.PartitionFor(filepath.ToSlash(dir)+"/") != nil {
+				states = append(states, &State{Path: dir})
+			}
+		}
+	}
+
+	result := &lintResult{Findings: []*lintFinding{}}
+	byDir := make(map[string][]*lintFinding)
+	byKey := make(map[string]*lintFinding)
+	for _, state := range states {
+		location := pg.stateLocation(state.Path)
+		if state.Workspace != "" {
+			location = state.String()
+		}
+		for _, dir := range pg.lintDirs(state.Path) {
+			findings, linted := byDir[dir]
+			if !linted {
+				var err error
+				if findings, err = pg.tflint(dir); err != nil {
+					return nil, err
+				}
+				byDir[dir] = findings
+				result.Dirs++
+			}
+			for _, f := range findings {
+				key := fmt.Sprintf("%s\x00%s\x00%d\x00%s", f.Rule, f.File, f.Line, f.Message)
+				merged := byKey[key]
+				if merged == nil {
+					merged = &lintFinding{Rule: f.Rule, Severity: f.Severity, Message: f.Message, File: f.File, Line: f.Line, Link: f.Link}
+					byKey[key] = merged
+					result.Findings = append(result.Findings, merged)
+				}
+				if !contains(merged.States, location) {
+					merged.States = append(merged.States, location)
+				}
+			}
+		}
+	}
+	for _, f := range result.Findings {
+		sort.Strings(f.States)
+	}
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		a, b := result.Findings[i], result.Findings[j]
+		if a.Severity != b.Severity {
+			return lintSeverityRank(a.Severity) < lintSeverityRank(b.Severity)
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return result, nil
+}
+
+// tflint lints one directory. tflint exits non-zero when it finds issues,
+// so --force keeps the exit code for errors running it.
+func (pg *PlanGenerator) tflint(dir string) ([]*lintFinding, error) {
+	var stdout, stderr bytes.Buffer
+	err := pg.execute(pg.ctx, &Command{
+		Args:   []string{"tflint", "--format", "json", "--force", "--no-color"},
+		Dir:    dir,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	name := "tflint-" + strings.ReplaceAll(filepath.ToSlash(dir), "/", "_")
+	var output tflintOutput
+	if jsonErr := json.Unmarshal(stdout.Bytes(), &output); jsonErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", dir, pg.commandError(err, name, stderr.Bytes()))
+		}
+		return nil, fmt.Errorf("reading tflint output for %s: %v", dir, jsonErr)
+	}
+	if len(output.Errors) > 0 {
+		return nil, fmt.Errorf("tflint in %s: %s", dir, output.Errors[0].Message)
+	}
+	var findings []*lintFinding
+	for _, issue := range output.Issues {
+		severity := strings.ToLower(issue.Rule.Severity)
+		if lintSeverityRank(severity) == len(lintSeverities) {
+			severity = "notice"
+		}
+		findings = append(findings, &lintFinding{
+			Rule:     issue.Rule.Name,
+			Severity: severity,
+			Message:  issue.Message,
+			File:     filepath.ToSlash(filepath.Join(dir, issue.Range.Filename)),
+			Line:     issue.Range.Start.Line,
+			Link:     issue.Rule.Link,
+		})
+	}
+	return findings, nil
+}
+
+func lintSeverityRank(severity string) int {
+	for i, s := range lintSeverities {
+		if s.name == severity {
+			return i
+		}
+	}
+	return len(lintSeverities)
+}
+
+// lintCounts summarizes findings as "1 error, 2 warning".
+func lintCounts(findings []*lintFinding) string {
+	var parts []string
+	for _, s := range lintSeverities {
+		n := 0
+		for _, f := range findings {
+			if f.Severity == s.name {
+				n++
+			}
+		}
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, s.name))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeLintFindings renders the tflint findings, one collapsed list per
+// severity.
+func (pg *PlanGenerator) writeLintFindings(output *os.File) {
+	r := pg.lint
+	if r == nil || r.Dirs == 0 {
+		return
+	}
+	if len(r.Findings) == 0 {
+		output.WriteString(fmt.Sprintf("> %stflint found no issues in %d configuration director(ies).\n\n", pg.renderer().Icon("🧹"), r.Dirs))
+		return
+	}
+	output.WriteString("## " + pg.renderer().Icon("🧹") + "Lint findings\n\n")
+	output.WriteString(fmt.Sprintf("tflint found %d issue(s) in %d configuration director(ies): %s.\n\n", len(r.Findings), r.Dirs, lintCounts(r.Findings)))
+	for _, s := range lintSeverities {
+		var findings []*lintFinding
+		for _, f := range r.Findings {
+			if f.Severity == s.name {
+				findings = append(findings, f)
+			}
+		}
+		if len(findings) == 0 {
+			continue
+		}
+		label := strings.ToUpper(s.name[:1]) + s.name[1:]
+		pg.renderer().OpenSection(output, 3, fmt.Sprintf("%s%s (%d)", pg.renderer().Icon(s.icon), label, len(findings)))
+		for _, f := range findings {
+			line := fmt.Sprintf("- `%s` %s: `%s:%d`", f.Rule, f.Message, f.File, f.Line)
+			if f.Link != "" {
+				line += fmt.Sprintf(" ([rule](%s))", f.Link)
+			}
+			line += " — " + strings.Join(f.States, ", ")
+			output.WriteString(line + "\n")
+		}
+		output.WriteString("\n")
+		pg.renderer().CloseSection(output)
+	}
+}
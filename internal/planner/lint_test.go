@@ -0,0 +1,38 @@
+package planner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintStatesMergesSharedFindings(t *testing.T) {
+	fake := &fakeExecutor{stdout: `{"issues":[{"rule":{"name":"terraform_unused_declarations","severity":"warning"},"message":"variable \"foo\" is declared but not used","range":{"filename":"main.tf","start":{"line":3}}}],"errors":[]}`}
+	pg := newTestGenerator(t, fake)
+	root := t.TempDir()
+	module := filepath.Join(root, "modules", "vpc")
+	os.MkdirAll(module, 0755)
+	os.WriteFile(filepath.Join(module, "main.tf"), []byte("variable \"foo\" {}\n"), 0644)
+	for _, env := range []string{"staging", "production"} {
+		dir := filepath.Join(root, "live", env, "us-east-1")
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "terragrunt.hcl"), []byte("terraform {\n  source = \"../../../modules/vpc\"\n}\n"), 0644)
+		pg.plannedStates = append(pg.plannedStates, &State{Path: dir})
+	}
+
+	result, err := pg.lintStates()
+	if err != nil {
+		t.Fatalf("lintStates: %v", err)
+	}
+	// Both states use the same module, linted once
+	if len(fake.commands) != 1 || fake.commands[0][0] != "tflint" {
+		t.Fatalf("commands = %v, want one tflint run", fake.commands)
+	}
+	if result.Dirs != 1 || len(result.Findings) != 1 {
+		t.Fatalf("result = %+v, want 1 finding in 1 directory", result)
+	}
+	if f := result.Findings[0]; len(f.States) != 2 || !strings.HasSuffix(f.File, "modules/vpc/main.tf") || f.Line != 3 {
+		t.Errorf("finding = %+v, want main.tf:3 shared by both states", f)
+	}
+}
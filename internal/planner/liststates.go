@@ -0,0 +1,213 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// listedState is a state as list-states prints it.
+type listedState struct {
+	Partition string `json:"partition,omitempty"`
+	Env       string `json:"env,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Path      string `json:"path"`
+	Workspace string `json:"workspace,omitempty"`
+}
+
+func newListStatesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list-states <module_name>",
+		Short: "List the states of a module, by environment and region",
+		Long: `Lists the concrete states of a module a run would plan, with the
+partition, environment and region each is reported under, after --targeted
+and --select like a run.
+
+Without --targeted, every state of the module is listed: the states its
+plan_all covers. States no partition matches are listed without one; runs
+skip them.
+
+Formats:
+  table   one state per line (default)
+  matrix  states per environment (rows) and region (columns)
+  json    an array of {partition, env, region, path, workspace}
+
+Examples:
+  terraform-pr-generator list-states s3_malware_protection
+  terraform-pr-generator list-states s3_malware_protection --format matrix
+  terraform-pr-generator list-states s3_malware_protection --targeted --select 'env=production' --format json`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeModules(1),
+		Run:               runListStates,
+		Annotations:       map[string]string{findsRepoRoot: "true"},
+	}
+
+	cmd.Flags().StringP("config", "c", "", "Path to a YAML config file (default: .tfprgen.yaml in the repo root)")
+	cmd.Flags().String("runner", "", "Built-in runner: kitman, terragrunt or terraform (default: from config, else kitman)")
+	cmd.Flags().BoolP("targeted", "t", false, "List only the states affected-modules.sh finds, like a targeted run")
+	cmd.Flags().String("select", "", "Only list states matching an expression, e.g. 'env=production && region=us-east-*'")
+	cmd.Flags().String("format", "table", "Output format: table, matrix or json")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"table", "matrix", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func runListStates(cmd *cobra.Command, args []string) {
+	targeted, _ := cmd.Flags().GetBool("targeted")
+	selectExpr, _ := cmd.Flags().GetString("select")
+	format, _ := cmd.Flags().GetString("format")
+	configPath, _ := cmd.Flags().GetString("config")
+	if format != "table" && format != "matrix" && format != "json" {
+		errorColor.Printf("❌ Error: invalid format %q (supported: table, matrix, json)\n", format)
+		os.Exit(1)
+	}
+
+	if configPath == "" {
+		configPath = FindConfigFile(".")
+	}
+	cfg, err := LoadConfig(configPath)
+	if err == nil {
+		if runner, _ := cmd.Flags().GetString("runner"); runner != "" {
+			err = cfg.SetRunner(runner)
+		}
+	}
+	var module moduleRef
+	if err == nil {
+		module, err = resolveModule(cfg.moduleRoots(), args[0])
+	}
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	pg := &PlanGenerator{ModuleName: module.Name, ModuleRoot: module.Root, Config: cfg, ctx: context.Background()}
+	if err := pg.validateModule(); err != nil && !cfg.Runner.Workspaces {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	states, err := pg.moduleStates(targeted)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if selectExpr != "" {
+		sel, err := parseSelector(selectExpr)
+		if err != nil {
+			errorColor.Printf("❌ Error: --select: %v\n", err)
+			os.Exit(1)
+		}
+		states = pg.selectStates(states, sel)
+	}
+
+	listed := make([]listedState, len(states))
+	envs, regions := make(map[string]bool), make(map[string]bool)
+	for i, state := range states {
+		fields := pg.selectorFieldsOf(state)
+		listed[i] = listedState{Partition: fields["partition"], Env: fields["env"], Region: fields["region"], Path: fields["path"], Workspace: state.Workspace}
+		envs[fields["env"]], regions[fields["region"]] = true, true
+	}
+	sort.SliceStable(listed, func(i, j int) bool {
+		a, b := listed[i], listed[j]
+		if a.Partition != b.Partition {
+			return a.Partition < b.Partition
+		}
+		if a.Env != b.Env {
+			return a.Env < b.Env
+		}
+		return a.Region < b.Region
+	})
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(listed, "", "  ")
+		if err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(append(data, '\n'))
+	case "matrix":
+		printStateMatrix(listed)
+	default:
+		var lines [][]string
+		for _, state := range listed {
+			line := []string{orDash(state.Partition), orDash(state.Env), orDash(state.Region), state.Path}
+			if cfg.Runner.Workspaces {
+				line = append(line, state.Workspace)
+			}
+			lines = append(lines, line)
+		}
+		header := []string{"PARTITION", "ENV", "REGION", "PATH"}
+		if cfg.Runner.Workspaces {
+			header = append(header, "WORKSPACE")
+		}
+		printTable(header, lines)
+	}
+	infoColor.Printf("📋 %d state(s) in %d environment(s) and %d region(s)\n", len(listed), len(envs), len(regions))
+}
+
+// moduleStates lists the states of the module a run would plan: the
+// workspaces of a workspace runner, the affected states of a targeted run,
+// or every state of the module.
+func (pg *PlanGenerator) moduleStates(targeted bool) ([]*State, error) {
+	switch {
+	case pg.Config.Runner.Workspaces:
+		return pg.discoverWorkspaceStates()
+	case targeted:
+		paths, err := pg.findAffectedPlans()
+		return statesFromPaths(paths), err
+	default:
+		paths, err := findModuleStates(pg.Config.Runner.WorkingDir, pg.ModuleName)
+		if err != nil {
+			return nil, fmt.Errorf("listing the module's states: %v", err)
+		}
+		return statesFromPaths(paths), nil
+	}
+}
+
+// printStateMatrix prints how many states each environment has in each
+// region.
+func printStateMatrix(states []listedState) {
+	counts := make(map[string]map[string]int)
+	var envs, regions []string
+	seenRegion := make(map[string]bool)
+	for _, state := range states {
+		env, region := orDash(state.Env), orDash(state.Region)
+		if counts[env] == nil {
+			counts[env] = make(map[string]int)
+			envs = append(envs, env)
+		}
+		counts[env][region]++
+		if !seenRegion[region] {
+			seenRegion[region] = true
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(envs)
+	sort.Strings(regions)
+
+	header := append([]string{"ENV"}, regions...)
+	var lines [][]string
+	for _, env := range envs {
+		line := []string{env}
+		for _, region := range regions {
+			cell := "·"
+			if n := counts[env][region]; n > 0 {
+				cell = strconv.Itoa(n)
+			}
+			line = append(line, cell)
+		}
+		lines = append(lines, line)
+	}
+	printTable(header, lines)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,104 @@
+package planner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// lockInfoRegex matches a field of the "Lock Info:" block terraform prints
+// when it can't acquire the state lock.
+var lockInfoRegex = regexp.MustCompile(`^\s*(ID|Path|Operation|Who|Created):\s*(.*?)\s*$`)
+
+// stateLock is who holds the state lock a plan failed on.
+type stateLock struct {
+	ID        string
+	Path      string
+	Operation string
+	Who       string
+	Created   string
+}
+
+// stateLockError is a plan failing on a state lock held by someone else,
+// saying who holds it rather than only the command's failure.
+type stateLockError struct {
+	Lock *stateLock
+	Err  error
+}
+
+func (e *stateLockError) Error() string {
+	var holder []string
+	if e.Lock.Operation != "" {
+		holder = append(holder, e.Lock.Operation)
+	}
+	if e.Lock.Created != "" {
+		holder = append(holder, "since "+e.Lock.Created)
+	}
+	if e.Lock.ID != "" {
+		holder = append(holder, "lock ID "+e.Lock.ID)
+	}
+	summary := "state locked by " + e.Lock.who()
+	if len(holder) > 0 {
+		summary += " (" + strings.Join(holder, ", ") + ")"
+	}
+	return fmt.Sprintf("%s: %v", summary, e.Err)
+}
+
+func (e *stateLockError) Unwrap() error {
+	return e.Err
+}
+
+func (l *stateLock) who() string {
+	if l.Who == "" {
+		return "someone else"
+	}
+	return l.Who
+}
+
+// parseStateLock extracts the lock holder from the stderr of a plan that
+// failed acquiring the state lock, nil for other failures.
+func parseStateLock(stderr []byte) *stateLock {
+	text := string(stderr)
+	if !strings.Contains(text, "Error acquiring the state lock") {
+		return nil
+	}
+	lock := &stateLock{}
+	inInfo := false
+	for _, line := range strings.Split(text, "\n") {
+		// Inside terraform's diagnostics box
+		line = strings.TrimLeft(line, "│ ")
+		if strings.TrimSpace(line) == "Lock Info:" {
+			inInfo = true
+			continue
+		}
+		if !inInfo {
+			continue
+		}
+		m := lockInfoRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		switch m[1] {
+		case "ID":
+			lock.ID = m[2]
+		case "Path":
+			lock.Path = m[2]
+		case "Operation":
+			lock.Operation = m[2]
+		case "Who":
+			lock.Who = m[2]
+		case "Created":
+			lock.Created = m[2]
+		}
+	}
+	return lock
+}
+
+// lockHint is what the report suggests for a plan failed on a state lock.
+func lockHint(lock *stateLock) string {
+	hint := fmt.Sprintf("The state is locked by %s.", lock.who())
+	if lock.ID != "" {
+		hint += fmt.Sprintf(" If that operation is no longer running, release the lock with `terraform force-unlock %s` from the state's directory.", lock.ID)
+	}
+	return hint + " `--lock-timeout` makes plans wait for a lock instead of failing on it."
+}
@@ -0,0 +1,181 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/fatih/color"
+	"github.com/spf13/pflag"
+)
+
+// logger writes console output as structured records for --log-format
+// text or json, nil in the default pretty format, where it's printed with
+// emoji and colors as is.
+var logger *slog.Logger
+
+// logLevel is the least severe console output shown (--log-level).
+var logLevel = new(slog.LevelVar)
+
+// logColor is a console color printing at a log level: colored with the
+// pretty format, a log record of the level otherwise.
+type logColor struct {
+	*color.Color
+	level slog.Level
+}
+
+func newLogColor(level slog.Level, attrs ...color.Attribute) logColor {
+	return logColor{Color: color.New(attrs...), level: level}
+}
+
+func (c logColor) Print(a ...interface{}) {
+	c.print(fmt.Sprint(a...))
+}
+
+func (c logColor) Printf(format string, a ...interface{}) {
+	c.print(fmt.Sprintf(format, a...))
+}
+
+func (c logColor) Println(a ...interface{}) {
+	c.print(fmt.Sprintln(a...))
+}
+
+func (c logColor) print(msg string) {
+	if debugLog != nil {
+		if msg := logMessage(msg); msg != "" {
+			debugLog.Log(context.Background(), c.level, msg)
+		}
+	}
+	if logger != nil {
+		if msg = logMessage(msg); msg != "" {
+			logger.Log(context.Background(), c.level, msg)
+		}
+		return
+	}
+	if c.level >= logLevel.Level() {
+		c.Color.Print(msg)
+	}
+}
+
+// logMessage drops the emoji, markers and blank lines around a console
+// message, which structured records don't need.
+func logMessage(msg string) string {
+	return strings.TrimLeftFunc(strings.TrimSpace(msg), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("([`'\"./~-", r)
+	})
+}
+
+// setLogging applies --log-format and --log-level. Plain console output is
+// logged at info level, so it's left out above it.
+func setLogging(format, level string) error {
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("--log-level must be debug, info, warn or error, not %q", level)
+	}
+	options := &slog.HandlerOptions{Level: logLevel}
+	switch format {
+	case "pretty":
+		if logLevel.Level() > slog.LevelInfo {
+			console = io.Discard
+		}
+		return nil
+	case "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, options))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, options))
+	default:
+		return fmt.Errorf("--log-format must be pretty, text or json, not %q", format)
+	}
+	// Records have no room for a redrawn status line or colors
+	consoleTTY = false
+	color.NoColor = true
+	console = &logWriter{logger: logger}
+	return nil
+}
+
+// quietLogging leaves only errors on the console (--quiet).
+func quietLogging() {
+	logLevel.Set(slog.LevelError)
+	if logger == nil {
+		console = io.Discard
+	}
+}
+
+// debugLogging tells whether --log-level debug asked for the details
+// --verbose prints.
+func debugLogging() bool {
+	return logLevel.Level() <= slog.LevelDebug
+}
+
+// logWriter logs each line written to the console as an info record.
+type logWriter struct {
+	logger  *slog.Logger
+	mu      sync.Mutex
+	pending []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		if msg := logMessage(string(w.pending[:i])); msg != "" {
+			w.logger.Info(msg)
+		}
+		w.pending = w.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+// verbosity is the -v flag: --verbose or -v for verbose output, -vv to
+// also trace every command run.
+type verbosity int
+
+func (v *verbosity) String() string {
+	return strconv.Itoa(int(*v))
+}
+
+func (v *verbosity) Set(value string) error {
+	switch value {
+	case "+1":
+		*v++
+	case "true":
+		if *v == 0 {
+			*v = 1
+		}
+	case "false":
+		*v = 0
+	default:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("want true, false or a level")
+		}
+		*v = verbosity(n)
+	}
+	return nil
+}
+
+func (v *verbosity) Type() string {
+	return "verbosity"
+}
+
+func addVerboseFlag(flags *pflag.FlagSet) {
+	flags.VarP(new(verbosity), "verbose", "v", "Enable verbose output; -vv also traces every command run with its directory, environment and duration")
+	flags.Lookup("verbose").NoOptDefVal = "+1"
+}
+
+// verboseLevel is the -v count: 0 for the usual output, 1 verbose, 2 and
+// up also tracing commands.
+func verboseLevel(flags *pflag.FlagSet) int {
+	return int(*flags.Lookup("verbose").Value.(*verbosity))
+}
@@ -0,0 +1,104 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const manifestFile = "manifest.json"
+
+// RunManifest records what a run planned and with which inputs, so a past
+// run can be inspected or reproduced later.
+type RunManifest struct {
+	Module     string    `json:"module"`
+	ModuleRoot string    `json:"module_root,omitempty"` // "." or empty for the repository root
+	StartedAt  time.Time `json:"started_at"`
+	Targeted   bool      `json:"targeted"`
+	ModeReason string    `json:"mode_reason,omitempty"` // why --mode auto chose Targeted
+	States     []*State  `json:"states,omitempty"`
+	VarFiles   []string  `json:"var_files,omitempty"`
+	Targets    []string  `json:"targets,omitempty"`
+	Select     string    `json:"select,omitempty"`
+	Destroy    bool      `json:"destroy,omitempty"`
+	ExtraArgs  []string  `json:"extra_args,omitempty"`
+	GitCommit  string    `json:"git_commit,omitempty"`
+	GitDirty   bool      `json:"git_dirty,omitempty"`
+	Runner     string    `json:"runner,omitempty"`
+	ConfigFile string    `json:"config_file,omitempty"`
+	Config     string    `json:"config,omitempty"`
+	Snapshot   *Snapshot `json:"snapshot,omitempty"`
+
+	// Checksums maps each output file (relative path) to its SHA-256. It is
+	// filled in once the run completes; see sealManifest.
+	Checksums map[string]string `json:"checksums,omitempty"`
+}
+
+// writeManifest records the run before any plans start, so even a failed
+// run leaves a trace of what it attempted.
+func (pg *PlanGenerator) writeManifest(targeted bool, states []*State) error {
+	manifest := &RunManifest{
+		Module:     pg.ModuleName,
+		ModuleRoot: pg.ModuleRoot,
+		StartedAt:  pg.now().UTC(),
+		Targeted:   targeted,
+		ModeReason: pg.modeReason,
+		States:     states,
+		VarFiles:   pg.VarFiles,
+		Targets:    pg.Targets,
+		Select:     pg.Select,
+		Destroy:    pg.Destroy,
+		ExtraArgs:  pg.ExtraArgs,
+		GitCommit:  gitHead(),
+		GitDirty:   gitDirty(),
+		Runner:     pg.Config.Runner.Name,
+		ConfigFile: pg.Config.Path,
+		Config:     pg.Config.Contents,
+	}
+	if pg.Snapshot {
+		if pg.Verbose {
+			fmt.Fprintln(console, "📸 Recording input snapshot...")
+		}
+		snapshot, err := takeSnapshot(pg.moduleDir(), statePaths(states))
+		if err != nil {
+			return err
+		}
+		manifest.Snapshot = snapshot
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(pg.OutputDir, manifestFile), append(data, '\n'), 0644)
+}
+
+// readManifest loads the manifest of a previous run directory.
+func readManifest(runDir string) (*RunManifest, error) {
+	data, err := os.ReadFile(filepath.Join(runDir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run manifest: %v", err)
+	}
+	var manifest RunManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filepath.Join(runDir, manifestFile), err)
+	}
+	return &manifest, nil
+}
+
+// config re-creates the configuration the run used: the recorded config
+// file plus any --runner override.
+func (m *RunManifest) config() (*Config, error) {
+	cfg, err := ParseConfig([]byte(m.Config), m.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if m.Runner != "" && m.Runner != cfg.Runner.Name {
+		if err := cfg.SetRunner(m.Runner); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,189 @@
+package planner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/backendken/terraform-pr-generator/pkg/render"
+)
+
+// generatePRMarkdown writes pr-ready.md. A non-empty mismatch (see
+// revisionMismatch) marks the report as needing regeneration.
+func (pg *PlanGenerator) generatePRMarkdown(results []*PartitionResult, mismatch string) error {
+	outputPath := filepath.Join(pg.OutputDir, "pr-ready.md")
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if pg.Destroy {
+		file.WriteString(fmt.Sprintf("**Terraform plan** — %s**DESTROY PLAN**\n\n", pg.renderer().Icon("🔥")))
+		file.WriteString("> " + pg.renderer().Icon("🔥") + "**DESTROY PLAN:** every plan below was run with `-destroy` and shows what removing this module tears down.\n\n")
+	} else {
+		file.WriteString("**Terraform plan**\n\n")
+	}
+	pg.writeJiraNote(file)
+
+	if mismatch != "" {
+		file.WriteString(fmt.Sprintf("> %s**Inconsistent report:** plans ran against different git revisions (%s). Regenerate this report before reviewing.\n\n", pg.renderer().Icon("⚠️"), mismatch))
+	}
+	if pg.modeReason != "" {
+		file.WriteString(fmt.Sprintf("> %sPlanning mode (auto): %s\n\n", pg.renderer().Icon("🧭"), pg.modeReason))
+	}
+	if len(pg.Targets) > 0 {
+		file.WriteString(fmt.Sprintf("> %sPlans are limited to `%s`; other changes are not shown.\n\n", pg.renderer().Icon("🎯"), strings.Join(pg.Targets, "`, `")))
+	}
+
+	pg.writeInterruptedNote(file)
+	pg.writeDriftNote(file)
+	pg.writeBaseNote(file)
+	pg.writeRemoteNote(file)
+	pg.writeMaskedNote(file)
+	pg.writeAnonymizedNote(file)
+
+	if pg.Select != "" {
+		file.WriteString(fmt.Sprintf("> %sStates are limited to `%s`; other environments and regions were not planned.\n\n", pg.renderer().Icon("🔍"), pg.Select))
+	}
+
+	pg.writePolicyResult(file)
+	pg.writeCostImpact(file)
+	pg.writeSecurityFindings(file)
+	pg.writeTagPolicy(file)
+	pg.writeLintFindings(file)
+	pg.writeVersionSkew(file)
+	pg.writeToolVersions(file)
+	pg.writeTimings(file)
+	pg.writeBlastRadius(file)
+	pg.writeDependencyGraph(file)
+	pg.writeArtifacts(file)
+	pg.writeReleaseNotes(file)
+
+	pg.writeFailures(file)
+
+	for _, result := range results {
+		pg.writePartitionMarkdown(result, file)
+	}
+
+	if pg.ApplyOrder {
+		pg.writeApplyOrder(results, file)
+	}
+	if pg.Approvals {
+		pg.writeApprovals(results, file)
+	}
+
+	if warnings := allWarnings(results); len(warnings) > 0 {
+		file.WriteString("## " + pg.renderer().Icon("⚠️") + "Parse warnings\n\n")
+		file.WriteString("The report may be missing or misattributing plans:\n\n")
+		for _, w := range warnings {
+			file.WriteString(fmt.Sprintf("- %s\n", w))
+		}
+		file.WriteString("\n")
+	}
+
+	return nil
+}
+
+func (pg *PlanGenerator) writePartitionMarkdown(result *PartitionResult, output io.StringWriter) {
+	// Environments of different partitions may share a name, so tools
+	// reading the report back tell them apart by the partition marker
+	if !pg.PlainReport && len(result.Environments) > 0 {
+		output.WriteString(fmt.Sprintf(partitionMarker+"\n\n", result.Partition.Name))
+	}
+	for _, group := range pg.environmentGroups(result) {
+		env := group[0]
+		names := make([]string, len(group))
+		for i, e := range group {
+			names[i] = e.Name
+		}
+		pg.renderer().EnvironmentHeading(output, strings.Join(names, ", "), pg.Config.Runner.Label, pg.moduleLabel())
+
+		for _, region := range env.Regions {
+			if planContent, exists := env.Plans[region]; exists && planContent != "" {
+				if env.Incomplete[region] && pg.interruptedRegion(result.Partition, env.Name, region) {
+					pg.renderer().OpenSection(output, 3, region+pg.renderer().DestroyTag()+pg.renderer().Tag("⛔", "not planned ("+pg.stopReason()+")"))
+					output.WriteString("> " + pg.renderer().Icon("⛔") + "The run stopped (" + pg.stopReason() + ") while this plan ran. The output below is partial.\n\n")
+				} else if env.Incomplete[region] {
+					pg.renderer().OpenSection(output, 3, region+pg.renderer().DestroyTag()+pg.renderer().Tag("⚠️", "incomplete"))
+					output.WriteString("> " + pg.renderer().Icon("⚠️") + "This plan did not reach a `Plan:` summary (it likely errored). The output below is partial.\n\n")
+				} else if attempts := pg.regionAttempts(result.Partition, env.Name, region); attempts > 1 {
+					pg.renderer().OpenSection(output, 3, region+pg.renderer().DestroyTag()+pg.renderer().Tag("🔁", fmt.Sprintf("%d attempts", attempts)))
+				} else if pg.reusedRegion(result.Partition, env.Name, region) {
+					pg.renderer().OpenSection(output, 3, region+pg.renderer().DestroyTag()+pg.renderer().Tag("♻️", "cached"))
+				} else {
+					pg.renderer().OpenSection(output, 3, region+pg.renderer().DestroyTag())
+				}
+				if section := pg.offloaded[sectionKey(result.Partition, env.Name, region)]; section != nil {
+					output.WriteString(pg.offloadedNote(section, planContent))
+				} else if section := pg.truncated[sectionKey(result.Partition, env.Name, region)]; section != nil {
+					output.WriteString(pg.truncatedNote(section))
+					pg.renderer().CodeBlock(output, section.Condensed)
+				} else {
+					pg.renderer().CodeBlock(output, collapseForEach(planContent, pg.CollapseForEach))
+				}
+				pg.renderer().CloseSection(output)
+			}
+		}
+	}
+}
+
+// environmentGroups groups a partition's environments for the report: with
+// MergeIdentical, each environment whose plans are the same as an earlier
+// one's in every region joins its group, shown once under both names.
+// Otherwise, and for incomplete plans, every environment is its own group.
+func (pg *PlanGenerator) environmentGroups(result *PartitionResult) [][]*Environment {
+	var groups [][]*Environment
+	for _, env := range result.Environments {
+		i := -1
+		if pg.MergeIdentical {
+			i = slices.IndexFunc(groups, func(group []*Environment) bool { return identicalPlans(group[0], env) })
+		}
+		if i < 0 {
+			groups = append(groups, []*Environment{env})
+		} else {
+			groups[i] = append(groups[i], env)
+		}
+	}
+	return groups
+}
+
+// identicalPlans reports whether a and b planned the same regions with the
+// same complete plans.
+func identicalPlans(a, b *Environment) bool {
+	if !slices.Equal(a.Regions, b.Regions) {
+		return false
+	}
+	for _, region := range a.Regions {
+		if a.Incomplete[region] || b.Incomplete[region] || a.Plans[region] != b.Plans[region] {
+			return false
+		}
+	}
+	return true
+}
+
+// renderer renders the report's markdown: plain for --plain-report, with
+// destroy markers for --destroy.
+func (pg *PlanGenerator) renderer() render.MarkdownRenderer {
+	return render.MarkdownRenderer{Plain: pg.PlainReport, Destroy: pg.Destroy}
+}
+
+// partialMarkdown renders the plans finished so far, for progress updates
+// while the run is still going.
+func (pg *PlanGenerator) partialMarkdown() string {
+	pg.flushMu.Lock()
+	defer pg.flushMu.Unlock()
+
+	var b strings.Builder
+	for _, p := range pg.Config.Partitions {
+		result, err := pg.parsePlansFile(p)
+		if err != nil {
+			continue
+		}
+		pg.writePartitionMarkdown(result, &b)
+	}
+	return b.String()
+}
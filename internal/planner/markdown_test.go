@@ -0,0 +1,26 @@
+package planner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeIdentical(t *testing.T) {
+	pg := newTestGenerator(t, nil)
+	pg.MergeIdentical = true
+	plan := "Plan: 1 to add, 0 to change, 0 to destroy."
+	results := testResults(pg.Config.Partitions[0],
+		testEnvironment("dev", map[string]string{"us-east-1": "No changes."}),
+		testEnvironment("production", map[string]string{"us-east-1": plan}),
+		testEnvironment("staging", map[string]string{"us-east-1": plan}),
+	)
+	var b strings.Builder
+	pg.writePartitionMarkdown(results[0], &b)
+	report := b.String()
+	if !strings.Contains(report, "[environment: production, staging]") || !strings.Contains(report, "[environment: dev]") {
+		t.Errorf("environments not merged:\n%s", report)
+	}
+	if n := strings.Count(report, plan); n != 1 {
+		t.Errorf("merged plan shown %d times", n)
+	}
+}
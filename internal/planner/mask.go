@@ -0,0 +1,195 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultMaskReplacement replaces the matches of masking rules without a
+// replacement.
+const defaultMaskReplacement = "<masked>"
+
+// MaskRule masks text matching Pattern, like internal hostnames, license
+// keys or IP ranges, in everything the plans print before it's written
+// anywhere.
+type MaskRule struct {
+	// Pattern is a regular expression, matched within a line.
+	Pattern string `yaml:"pattern"`
+	// Replacement replaces each match, with $1 or ${name} for its groups;
+	// "<masked>" by default.
+	Replacement string `yaml:"replacement"`
+
+	regex *regexp.Regexp
+}
+
+func validateMaskRules(rules []*MaskRule) error {
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("masking[%d]: pattern is required", i)
+		}
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("masking[%d]: invalid pattern: %v", i, err)
+		}
+		rule.regex = regex
+		if rule.Replacement == "" {
+			rule.Replacement = defaultMaskReplacement
+		}
+	}
+	return nil
+}
+
+// maskText applies rules to text, returning it masked and the number of
+// matches replaced.
+func maskText(rules []*MaskRule, text []byte) ([]byte, int) {
+	count := 0
+	for _, rule := range rules {
+		matches := len(rule.regex.FindAllIndex(text, -1))
+		if matches == 0 {
+			continue
+		}
+		count += matches
+		text = rule.regex.ReplaceAll(text, []byte(rule.Replacement))
+	}
+	return text, count
+}
+
+// maskBytes masks text with the masking rules, then pseudonymizes it with
+// --anonymize, returning the number of matches the rules replaced.
+func (pg *PlanGenerator) maskBytes(text []byte) ([]byte, int) {
+	text, count := maskText(pg.Config.Masking, text)
+	if pg.anonymizer != nil {
+		text = pg.anonymizer.anonymize(text)
+	}
+	return text, count
+}
+
+// masking tells whether plan output is masked or anonymized at all.
+func (pg *PlanGenerator) masking() bool {
+	return len(pg.Config.Masking) > 0 || pg.anonymizer != nil
+}
+
+// maskWriter masks what's written to it line by line on its way to w,
+// counting the matches replaced.
+type maskWriter struct {
+	w     io.Writer
+	mask  func([]byte) ([]byte, int)
+	count int
+	line  []byte
+}
+
+func (m *maskWriter) Write(p []byte) (int, error) {
+	m.line = append(m.line, p...)
+	end := bytes.LastIndexByte(m.line, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+	masked, n := m.mask(m.line[:end+1])
+	m.count += n
+	m.line = append(m.line[:0], m.line[end+1:]...)
+	if _, err := m.w.Write(masked); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes an unfinished last line.
+func (m *maskWriter) Flush() {
+	if len(m.line) == 0 {
+		return
+	}
+	masked, n := m.mask(m.line)
+	m.count += n
+	m.line = nil
+	m.w.Write(masked)
+}
+
+// maskCommand masks cmd's output with maskBytes if it opted in
+// with a maskCount. flush writes what's left once the command exited and
+// adds up the matches; stdout and stderr are written concurrently, so each
+// counts its own.
+func (pg *PlanGenerator) maskCommand(cmd *Command) (flush func()) {
+	if cmd.maskCount == nil || !pg.masking() {
+		return func() {}
+	}
+	var writers []*maskWriter
+	for _, w := range []*io.Writer{&cmd.Stdout, &cmd.Stderr} {
+		if *w != nil {
+			masked := &maskWriter{w: *w, mask: pg.maskBytes}
+			*w = masked
+			writers = append(writers, masked)
+		}
+	}
+	return func() {
+		for _, w := range writers {
+			w.Flush()
+			*cmd.maskCount += w.count
+		}
+	}
+}
+
+// maskPlan masks a plan's output that wasn't printed by a local command,
+// like a remote run's log, counting the matches under name.
+func (pg *PlanGenerator) maskPlan(name string, output []byte) []byte {
+	if !pg.masking() {
+		return output
+	}
+	masked, n := pg.maskBytes(output)
+	pg.recordMasked(name, n)
+	return masked
+}
+
+// recordMasked records how many matches the masking rules replaced in the
+// plan of a state, or of a partition in full runs. A retried plan counts
+// its last attempt.
+func (pg *PlanGenerator) recordMasked(name string, count int) {
+	if len(pg.Config.Masking) == 0 {
+		return
+	}
+	pg.flushMu.Lock()
+	defer pg.flushMu.Unlock()
+	if pg.masked == nil {
+		pg.masked = make(map[string]int)
+	}
+	pg.masked[name] = count
+}
+
+// maskedCounts are the plans the masking rules replaced values in, with
+// how many; nil if none.
+func (pg *PlanGenerator) maskedCounts() map[string]int {
+	var counts map[string]int
+	for name, count := range pg.masked {
+		if count > 0 {
+			if counts == nil {
+				counts = make(map[string]int)
+			}
+			counts[name] = count
+		}
+	}
+	return counts
+}
+
+// writeMaskedNote counts the values the masking rules replaced, per state.
+func (pg *PlanGenerator) writeMaskedNote(output *os.File) {
+	masked := pg.maskedCounts()
+	if len(masked) == 0 {
+		return
+	}
+	var names []string
+	total := 0
+	for name, count := range masked {
+		names = append(names, name)
+		total += count
+	}
+	sort.Strings(names)
+	var counts []string
+	for _, name := range names {
+		counts = append(counts, fmt.Sprintf("`%s` (%d)", name, masked[name]))
+	}
+	output.WriteString(fmt.Sprintf("> %sMasked %d value(s) with the configured masking rules: %s\n\n", pg.renderer().Icon("🕶️"), total, strings.Join(counts, ", ")))
+}
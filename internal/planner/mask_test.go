@@ -0,0 +1,37 @@
+package planner
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPlanStateMasked(t *testing.T) {
+	fake := &fakeExecutor{
+		stdout: "  + host = \"db1.corp.internal\"\n  + peer = \"db2.corp.internal\"\n  + license = \"LIC-1234-5678\"",
+	}
+	pg := newTestGenerator(t, fake)
+	pg.Config.Masking = []*MaskRule{
+		{Pattern: `[a-z0-9]+\.corp\.internal`, Replacement: "<host>"},
+		{Pattern: `LIC-[0-9-]+`},
+	}
+	if err := validateMaskRules(pg.Config.Masking); err != nil {
+		t.Fatalf("validateMaskRules: %v", err)
+	}
+	state := &State{Path: "terragrunt_vpc/organizations/staging/us-east-1"}
+
+	output, err := pg.planState(context.Background(), pg.Config.Partitions[0], state)
+	if err != nil {
+		t.Fatalf("planState: %v", err)
+	}
+	want := "  + host = \"<host>\"\n  + peer = \"<host>\"\n  + license = \"<masked>\""
+	if string(output) != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+	if got := pg.maskedCounts()[state.String()]; got != 3 {
+		t.Errorf("masked %d values, want 3", got)
+	}
+
+	if err := validateMaskRules([]*MaskRule{{Pattern: "("}}); err == nil {
+		t.Error("an invalid pattern was accepted")
+	}
+}
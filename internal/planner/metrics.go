@@ -0,0 +1,200 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pushgatewayEnv overrides metrics.pushgateway, e.g. for CI jobs only.
+const pushgatewayEnv = "TFPRGEN_PUSHGATEWAY"
+
+// MetricsConfig pushes the metrics of every finished run to a Prometheus
+// Pushgateway.
+type MetricsConfig struct {
+	// Pushgateway is the gateway's base URL, e.g.
+	// http://pushgateway:9091. Nothing is pushed without one.
+	Pushgateway string `yaml:"pushgateway"`
+	// Job is the job label the metrics are grouped under;
+	// terraform-pr-generator by default.
+	Job string `yaml:"job"`
+	// Labels are added to the grouping key, e.g. team: platform.
+	Labels map[string]string `yaml:"labels"`
+}
+
+func (c MetricsConfig) validate() error {
+	if c.Pushgateway == "" {
+		return nil
+	}
+	if u, err := url.Parse(c.Pushgateway); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("pushgateway %q isn't an http(s) URL", c.Pushgateway)
+	}
+	return nil
+}
+
+// metricsPusher pushes a run's metrics once it ends. A nil pusher pushes
+// nothing.
+type metricsPusher struct {
+	config MetricsConfig
+	http   *http.Client
+}
+
+// newMetricsPusher returns the pusher of a run, nil when no Pushgateway is
+// configured.
+func newMetricsPusher(cfg MetricsConfig) (*metricsPusher, error) {
+	if gateway := os.Getenv(pushgatewayEnv); gateway != "" {
+		cfg.Pushgateway = gateway
+	}
+	if cfg.Pushgateway == "" {
+		return nil, nil
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("metrics: %v", err)
+	}
+	if cfg.Job == "" {
+		cfg.Job = defaultServiceName
+	}
+	return &metricsPusher{config: cfg, http: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// groupURL is where the module's metrics are pushed: one group per job,
+// module and configured labels, replaced by every run.
+func (m *metricsPusher) groupURL(module string) string {
+	path := "/metrics/job/" + url.PathEscape(m.config.Job) + "/module/" + url.PathEscape(module)
+	var names []string
+	for name := range m.config.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path += "/" + url.PathEscape(name) + "/" + url.PathEscape(m.config.Labels[name])
+	}
+	return strings.TrimSuffix(m.config.Pushgateway, "/") + path
+}
+
+// metricsWriter collects gauges for the Prometheus text exposition
+// format, which wants every family's samples together.
+type metricsWriter struct {
+	families []string
+	help     map[string]string
+	samples  map[string][]string
+}
+
+// gauge adds a sample of a gauge, labels being name/value pairs.
+func (w *metricsWriter) gauge(name, help string, value float64, labels ...string) {
+	if w.help == nil {
+		w.help, w.samples = make(map[string]string), make(map[string][]string)
+	}
+	if _, ok := w.help[name]; !ok {
+		w.families = append(w.families, name)
+		w.help[name] = help
+	}
+	sample := name
+	if len(labels) > 0 {
+		var pairs []string
+		for i := 0; i+1 < len(labels); i += 2 {
+			value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], value))
+		}
+		sample += "{" + strings.Join(pairs, ",") + "}"
+	}
+	w.samples[name] = append(w.samples[name], fmt.Sprintf("%s %g", sample, value))
+}
+
+func (w *metricsWriter) bytes() []byte {
+	var b bytes.Buffer
+	for _, name := range w.families {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, w.help[name], name)
+		for _, sample := range w.samples[name] {
+			b.WriteString(sample + "\n")
+		}
+	}
+	return b.Bytes()
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// runMetrics renders the metrics of a finished run: its outcome and
+// duration, how many states were planned and failed, and the changes and
+// incomplete plans of every environment.
+func (pg *PlanGenerator) runMetrics(duration time.Duration, runErr error) []byte {
+	var w metricsWriter
+	status := runStatus(runErr)
+	w.gauge("tfprgen_run_duration_seconds", "How long the last run took.", duration.Seconds())
+	w.gauge("tfprgen_run_timestamp_seconds", "When the last run finished, in seconds since the epoch.", float64(time.Now().Unix()))
+	w.gauge("tfprgen_run_failed", "Whether the last run failed.", boolGauge(status == "failed"))
+	w.gauge("tfprgen_run_drift", "Whether the last run found drift (--expect-no-changes).", boolGauge(status == "drift"))
+	w.gauge("tfprgen_run_targeted", "Whether the last run planned only the affected states.", boolGauge(len(pg.plannedStates) > 0))
+
+	failed := 0
+	for _, t := range pg.timings {
+		if t.Status == stateFailed {
+			failed++
+		}
+	}
+	w.gauge("tfprgen_states_planned", "States the last run planned.", float64(len(pg.timings)))
+	w.gauge("tfprgen_states_failed", "States whose plan failed in the last run.", float64(failed))
+
+	for _, result := range pg.results {
+		for _, env := range result.Environments {
+			var counts PlanCounts
+			incomplete := 0
+			for _, region := range env.Regions {
+				if c, ok := ParsePlanCounts(env.Plans[region]); ok {
+					counts.Add += c.Add
+					counts.Change += c.Change
+					counts.Destroy += c.Destroy
+				}
+				if env.Incomplete[region] {
+					incomplete++
+				}
+			}
+			labels := []string{"partition", result.Partition.Name, "env", env.Name}
+			w.gauge("tfprgen_plan_changes", "Resource changes the last run planned, by environment and action.", float64(counts.Add), append(labels, "action", "add")...)
+			w.gauge("tfprgen_plan_changes", "", float64(counts.Change), append(labels, "action", "change")...)
+			w.gauge("tfprgen_plan_changes", "", float64(counts.Destroy), append(labels, "action", "destroy")...)
+			w.gauge("tfprgen_regions_incomplete", "Region plans of the last run that didn't reach a summary, by environment.", float64(incomplete), labels...)
+		}
+	}
+	return w.bytes()
+}
+
+// pushMetrics replaces the module's metrics on the Pushgateway with those
+// of the finished run. Failing to push is only a warning.
+func (pg *PlanGenerator) pushMetrics(started time.Time, runErr error) {
+	if pg.metrics == nil {
+		return
+	}
+	if err := pg.metrics.push(pg.ModuleName, pg.runMetrics(time.Since(started), runErr)); err != nil {
+		warningColor.Printf("⚠️  Couldn't push the run's metrics: %v\n", err)
+	}
+}
+
+func (m *metricsPusher) push(module string, metrics []byte) error {
+	req, err := http.NewRequest("PUT", m.groupURL(module), bytes.NewReader(metrics))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := m.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
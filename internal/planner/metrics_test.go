@@ -0,0 +1,36 @@
+package planner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunMetrics(t *testing.T) {
+	pg := &PlanGenerator{
+		timings: []*stateTiming{{State: "a", Status: stateFailed}, {State: "b", Status: stateSucceeded}},
+		results: testResults(&Partition{Name: "commercial"},
+			testEnvironment("production", map[string]string{"us-east-1": "Plan: 0 to add, 2 to change, 1 to destroy."}),
+			testEnvironment("staging", map[string]string{"us-east-1": "Plan: 1 to add, 0 to change, 0 to destroy.", "eu-west-1": "Error: boom"}, "eu-west-1"),
+		),
+	}
+	metrics := string(pg.runMetrics(90*time.Second, errors.New("plans failed")))
+	for _, want := range []string{
+		"tfprgen_run_duration_seconds 90\n",
+		"tfprgen_run_failed 1\n",
+		"tfprgen_states_failed 1\n",
+		`tfprgen_plan_changes{partition="commercial",env="production",action="change"} 2` + "\n",
+		`tfprgen_plan_changes{partition="commercial",env="staging",action="add"} 1` + "\n",
+		`tfprgen_regions_incomplete{partition="commercial",env="staging"} 1` + "\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics lack %q:\n%s", want, metrics)
+		}
+	}
+	// Each family's samples are grouped under one TYPE line
+	if strings.Count(metrics, "# TYPE tfprgen_plan_changes gauge") != 1 ||
+		strings.Index(metrics, "tfprgen_regions_incomplete{") < strings.LastIndex(metrics, "tfprgen_plan_changes{") {
+		t.Errorf("families are interleaved:\n%s", metrics)
+	}
+}
@@ -0,0 +1,359 @@
+package planner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+// modulePrefix names the module directories in each module root, e.g.
+// terragrunt_s3_malware_protection for s3_malware_protection.
+const modulePrefix = "terragrunt_"
+
+// descriptionWidth is the most of a module description list-modules shows.
+const descriptionWidth = 80
+
+// maxSuggestions is how many similar module names a mistyped one suggests.
+const maxSuggestions = 3
+
+func newListModulesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list-modules",
+		Short: "List the modules that can be planned",
+		Long: `Lists the modules of the repository, named after the terragrunt_<module>
+directories of its module roots (module_roots in the config), one per line on
+stdout: the names the generator takes as its module argument. A name found in
+more than one root is listed once per root, qualified with it, e.g.
+platform/vpc.
+
+With --describe, each module's description is shown next to it: the first
+paragraph of its README.md, else the leading comment of its terragrunt.hcl
+or main.tf.
+
+Examples:
+  terraform-pr-generator list-modules
+  terraform-pr-generator list-modules --describe`,
+		Args:        cobra.NoArgs,
+		Run:         runListModules,
+		Annotations: map[string]string{findsRepoRoot: "true"},
+	}
+
+	cmd.Flags().BoolP("describe", "d", false, "Show each module's description")
+	cmd.Flags().StringP("config", "c", "", "Path to a YAML config file (default: .tfprgen.yaml in the repo root)")
+	return cmd
+}
+
+func runListModules(cmd *cobra.Command, args []string) {
+	describe, _ := cmd.Flags().GetBool("describe")
+	roots := commandModuleRoots(cmd, ".")
+	modules, err := findModules(".", roots)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(modules) == 0 {
+		errorColor.Printf("❌ Error: no %s* module directories in %s.\nMake sure you're running this from the elon-modules root directory\n", modulePrefix, strings.Join(roots, ", "))
+		os.Exit(1)
+	}
+
+	names := moduleNames(modules)
+	if !describe {
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return
+	}
+	var lines [][]string
+	for i, module := range modules {
+		line := []string{names[i], moduleDescription(module.Dir())}
+		if len(roots) > 1 {
+			line = []string{names[i], module.Root, line[1]}
+		}
+		lines = append(lines, line)
+	}
+	if len(roots) > 1 {
+		printTable([]string{"MODULE", "ROOT", "DESCRIPTION"}, lines)
+	} else {
+		printTable([]string{"MODULE", "DESCRIPTION"}, lines)
+	}
+}
+
+// moduleRef is a module and the module root its directory is in.
+type moduleRef struct {
+	Root string // relative to the repository root, "." for the root itself
+	Name string
+}
+
+// Dir is the module's terragrunt_<module> directory.
+func (m moduleRef) Dir() string {
+	return filepath.Join(m.Root, modulePrefix+m.Name)
+}
+
+// String is the module's name, qualified with its root unless that's the
+// repository root, e.g. platform/vpc.
+func (m moduleRef) String() string {
+	if m.Root == "" || m.Root == "." {
+		return m.Name
+	}
+	return m.qualified()
+}
+
+// qualified is the module's name prefixed with its root, even the
+// repository root: ./vpc.
+func (m moduleRef) qualified() string {
+	root := m.Root
+	if root == "" {
+		root = "."
+	}
+	return filepath.ToSlash(root) + "/" + m.Name
+}
+
+// findModules lists the modules of each of roots below dir, by name and
+// then in the order of roots. Roots missing from the checkout are skipped.
+func findModules(dir string, roots []string) ([]moduleRef, error) {
+	var modules []moduleRef
+	for _, root := range roots {
+		names, err := listModules(filepath.Join(dir, root))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			modules = append(modules, moduleRef{Root: root, Name: name})
+		}
+	}
+	sort.SliceStable(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	return modules, nil
+}
+
+// moduleNames are the names modules are given as arguments by, indexed
+// like modules: their own, qualified with the root when several roots have
+// a module of that name.
+func moduleNames(modules []moduleRef) []string {
+	count := make(map[string]int)
+	for _, module := range modules {
+		count[module.Name]++
+	}
+	names := make([]string, len(modules))
+	for i, module := range modules {
+		names[i] = module.Name
+		if count[module.Name] > 1 {
+			names[i] = module.qualified()
+		}
+	}
+	return names
+}
+
+// resolveModule finds the module a module argument names: in the root it's
+// qualified with (platform/vpc), else in whichever of roots has a directory
+// for it. A module no root has resolves to the first, for the run to report
+// it missing.
+func resolveModule(roots []string, arg string) (moduleRef, error) {
+	if i := strings.LastIndex(arg, "/"); i >= 0 {
+		return moduleRef{Root: filepath.Clean(filepath.FromSlash(arg[:i])), Name: arg[i+1:]}, nil
+	}
+	var found []moduleRef
+	for _, root := range roots {
+		module := moduleRef{Root: root, Name: arg}
+		if info, err := os.Stat(module.Dir()); err == nil && info.IsDir() {
+			found = append(found, module)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return moduleRef{Root: roots[0], Name: arg}, nil
+	case 1:
+		return found[0], nil
+	}
+	var names []string
+	for _, module := range found {
+		names = append(names, module.qualified())
+	}
+	return moduleRef{}, fmt.Errorf("module %s is in several module roots; name one of %s", arg, strings.Join(names, ", "))
+}
+
+// commandModuleRoots are the module roots of the config a command run from
+// dir would use: --config, else the .tfprgen.yaml found from dir. Without a
+// readable config only dir itself is a root.
+func commandModuleRoots(cmd *cobra.Command, dir string) []string {
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = FindConfigFile(dir)
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		return []string{"."}
+	}
+	return cfg.moduleRoots()
+}
+
+// listModules lists the names of the module directories in root, sorted.
+func listModules(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var modules []string
+	for _, entry := range entries {
+		if name := entry.Name(); entry.IsDir() && strings.HasPrefix(name, modulePrefix) && len(name) > len(modulePrefix) {
+			modules = append(modules, strings.TrimPrefix(name, modulePrefix))
+		}
+	}
+	sort.Strings(modules)
+	return modules, nil
+}
+
+// moduleDescription is the first paragraph line of a module's README.md,
+// else the first line of the comment its terragrunt.hcl or main.tf starts
+// with, shortened to descriptionWidth; "" without either.
+func moduleDescription(dir string) string {
+	description := readmeDescription(filepath.Join(dir, "README.md"))
+	for _, name := range []string{"terragrunt.hcl", "main.tf"} {
+		if description != "" {
+			break
+		}
+		description = leadingComment(filepath.Join(dir, name))
+	}
+	if runes := []rune(description); len(runes) > descriptionWidth {
+		description = strings.TrimSpace(string(runes[:descriptionWidth-1])) + "…"
+	}
+	return description
+}
+
+// readmeDescription is the first line of a README's text, skipping
+// headings, badges and HTML.
+func readmeDescription(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[![") || strings.HasPrefix(line, "![") || strings.HasPrefix(line, "<") || strings.HasPrefix(line, "---") {
+			continue
+		}
+		return line
+	}
+	return ""
+}
+
+// leadingComment is the first line of the comment an HCL file starts with.
+func leadingComment(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		marker := ""
+		for _, m := range []string{"#", "//", "/*", "*"} {
+			if strings.HasPrefix(line, m) {
+				marker = m
+				break
+			}
+		}
+		if marker == "" {
+			return ""
+		}
+		// Blank comment lines, e.g. an opening /*, are skipped
+		if text := strings.Trim(strings.TrimPrefix(line, marker), "#/* \t"); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// suggestModules lists the modules of roots whose names are close to a
+// mistyped one, closest first: within a few edits of it, or containing it
+// or contained in it.
+func suggestModules(name string, roots []string) []string {
+	found, err := findModules(".", roots)
+	if err != nil {
+		return nil
+	}
+	modules := moduleNames(found)
+	type candidate struct {
+		module   string
+		distance int
+	}
+	var candidates []candidate
+	lower := strings.ToLower(name)
+	for _, module := range modules {
+		distance := editDistance(lower, strings.ToLower(module))
+		similar := distance <= 2 || distance <= len(name)/3
+		if !similar && len(name) >= 3 && (strings.Contains(strings.ToLower(module), lower) || strings.Contains(lower, strings.ToLower(module))) {
+			similar = true
+		}
+		if similar {
+			candidates = append(candidates, candidate{module, distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].module)
+	}
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// promptModule offers to plan the closest existing module instead of a
+// mistyped one, when run from a terminal. It returns the module to plan:
+// name itself unless the suggestion was accepted, leaving the error to the
+// run.
+func promptModule(name string, roots []string) string {
+	if !isatty.IsTerminal(os.Stdin.Fd()) || !consoleTTY {
+		return name
+	}
+	module, err := resolveModule(roots, name)
+	if err != nil {
+		return name
+	}
+	if _, err := os.Stat(module.Dir()); err == nil {
+		return name
+	}
+	suggestions := suggestModules(name, roots)
+	if len(suggestions) == 0 {
+		return name
+	}
+	if confirm(bufio.NewReader(os.Stdin), fmt.Sprintf("🤔 Module %s not found. Did you mean %s?", name, suggestions[0])) {
+		return suggestions[0]
+	}
+	return name
+}
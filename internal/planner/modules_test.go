@@ -0,0 +1,33 @@
+package planner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindModules(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"terragrunt_vpc", "platform/terragrunt_vpc", "platform/terragrunt_iam", "networking/README.md"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	modules, err := findModules(root, []string{".", "platform", "networking", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(moduleNames(modules), " "), "iam ./vpc platform/vpc"; got != want {
+		t.Errorf("moduleNames() = %q, want %q", got, want)
+	}
+	if dir := modules[2].Dir(); dir != filepath.Join("platform", "terragrunt_vpc") {
+		t.Errorf("Dir() = %q", dir)
+	}
+
+	module, err := resolveModule([]string{"."}, "platform/vpc")
+	if err != nil || module.Root != "platform" || module.Name != "vpc" || module.String() != "platform/vpc" {
+		t.Errorf("resolveModule(platform/vpc) = %+v, %v", module, err)
+	}
+	if module, _ := resolveModule([]string{"."}, "./vpc"); module.String() != "vpc" {
+		t.Errorf("resolveModule(./vpc) = %+v", module)
+	}
+}
@@ -0,0 +1,84 @@
+package planner
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// logTimestampRegex matches the timestamps CI runners and terragrunt
+	// put before log lines, e.g. "2025-06-04T14:30:22.1234567Z " or
+	// "14:30:22.123 ".
+	logTimestampRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}[T ])?\d{2}:\d{2}:\d{2}([.,]\d+)?(Z|[+-]\d{2}:?\d{2})? +`)
+	// alignmentRegex matches the padding terraform aligns "=" with, which
+	// shifts whenever a longer attribute appears next to it.
+	alignmentRegex = regexp.MustCompile(`^(\s*(?:[-+~]|-/\+|\+/-)?\s*(?:"[^"]*"|[\w.\-]+)) {2,}= `)
+	// mapOpenRegex matches the first line of a map attribute, e.g.
+	// "~ tags = {".
+	mapOpenRegex = regexp.MustCompile(`^\s*(?:[-+~]|-/\+|\+/-)?\s*(?:"[^"]*"|[\w.\-]+) += \{$`)
+	// mapEntryRegex matches a single-line map entry, capturing its key.
+	mapEntryRegex = regexp.MustCompile(`^\s*(?:[-+~]\s+)?("[^"]*"|[\w.\-]+) += .*[^{\[(]$`)
+)
+
+// stablePlan normalizes a plan body so plans of the same changes compare
+// equal across runs: log timestamps are stripped, the entries of
+// single-line maps sorted by key, alignment padding collapsed, trailing
+// whitespace dropped and runs of blank lines folded into one.
+func stablePlan(body string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		line = logTimestampRegex.ReplaceAllString(line, "")
+		line = strings.TrimRight(line, " \t\r")
+		lines[i] = alignmentRegex.ReplaceAllString(line, "$1 = ")
+	}
+	lines = sortMaps(lines)
+
+	var out []string
+	for _, line := range lines {
+		if line == "" && (len(out) == 0 || out[len(out)-1] == "") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.TrimRight(strings.Join(out, "\n"), "\n")
+}
+
+// sortMaps sorts the entries of maps whose entries all fit on one line by
+// key, keeping "# (N unchanged elements hidden)" notes after them. Maps
+// holding nested values are left alone.
+func sortMaps(lines []string) []string {
+	for i := 0; i < len(lines); i++ {
+		if !mapOpenRegex.MatchString(lines[i]) {
+			continue
+		}
+		var entries, notes []string
+		end := -1
+		for j := i + 1; j < len(lines); j++ {
+			trimmed := strings.TrimSpace(lines[j])
+			if trimmed == "}" || strings.HasPrefix(trimmed, "} ->") {
+				end = j
+				break
+			}
+			if strings.HasPrefix(trimmed, "#") {
+				notes = append(notes, lines[j])
+			} else if mapEntryRegex.MatchString(lines[j]) {
+				entries = append(entries, lines[j])
+			} else {
+				break
+			}
+		}
+		if end < 0 {
+			continue
+		}
+		sort.SliceStable(entries, func(a, b int) bool { return mapKey(entries[a]) < mapKey(entries[b]) })
+		copy(lines[i+1:], append(entries, notes...))
+		i = end
+	}
+	return lines
+}
+
+// mapKey is the key of a map entry, without quotes.
+func mapKey(line string) string {
+	return strings.Trim(mapEntryRegex.FindStringSubmatch(line)[1], `"`)
+}
@@ -0,0 +1,51 @@
+package planner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStablePlan(t *testing.T) {
+	a := strings.Join([]string{
+		"2025-06-04T14:30:22.1234567Z Terraform will perform the following actions:",
+		"",
+		"",
+		"  # aws_s3_bucket.this will be updated in-place",
+		"  ~ resource \"aws_s3_bucket\" \"this\" {",
+		"        id     = \"logs\"   ",
+		"      ~ tags   = {",
+		"          + \"Team\" = \"platform\"",
+		"            \"Env\"  = \"staging\"",
+		"            # (1 unchanged element hidden)",
+		"        }",
+		"    }",
+		"",
+		"Plan: 0 to add, 1 to change, 0 to destroy.",
+	}, "\n")
+	b := strings.Join([]string{
+		"Terraform will perform the following actions:",
+		"",
+		"  # aws_s3_bucket.this will be updated in-place",
+		"  ~ resource \"aws_s3_bucket\" \"this\" {",
+		"        id = \"logs\"",
+		"      ~ tags = {",
+		"            \"Env\" = \"staging\"",
+		"          + \"Team\" = \"platform\"",
+		"            # (1 unchanged element hidden)",
+		"        }",
+		"    }",
+		"",
+		"Plan: 0 to add, 1 to change, 0 to destroy.",
+	}, "\n")
+	if stablePlan(a) != stablePlan(b) {
+		t.Errorf("plans of the same changes differ:\n%s\n---\n%s", stablePlan(a), stablePlan(b))
+	}
+	if got := stablePlan(b); got != b {
+		t.Errorf("a stable plan changed:\n%s", got)
+	}
+
+	nested := "      ~ settings = {\n          ~ b = {\n              + x = 1\n            }\n          + a = 2\n        }"
+	if got := stablePlan(nested); got != nested {
+		t.Errorf("a map with nested values was reordered:\n%s", got)
+	}
+}
@@ -0,0 +1,179 @@
+package planner
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// GeneratorOptions are the options of a run, one per flag of the command,
+// named like the PlanGenerator fields they set, e.g. Approvals for
+// --approval-checklist. The config file supplies those Given doesn't report
+// as set, as it does for flags left off the command line.
+type GeneratorOptions struct {
+	Module     string // as given to the command, e.g. "vpc" or "platform/vpc"
+	ConfigFile string // "" for the .tfprgen.yaml found from the current directory
+	Runner     string
+	Verbosity  int // times --verbose was given
+
+	Targeted           bool
+	Mode               string
+	OutputDir          string
+	Parallelism        string
+	Formats            []string
+	Snapshot           bool
+	CollapseForEach    int
+	MergeIdentical     bool
+	ApplyOrder         bool
+	Approvals          bool
+	Graph              bool
+	GraphDot           string
+	VarFiles           []string
+	Targets            []string
+	Select             string
+	Base               string
+	WarningsAsErrors   bool
+	Destroy            bool
+	SavePlans          bool
+	PolicyDir          string
+	SecurityScanner    string
+	Stdout             bool
+	Quiet              bool
+	Copy               bool
+	Open               bool
+	DryRun             bool
+	EmitScript         string
+	GitHubComment      bool
+	PRDescription      bool
+	PRNumber           int
+	GitHubStatus       bool
+	Email              bool
+	Jira               string
+	CommitArtifacts    bool
+	ReleaseNotes       bool
+	Upload             string
+	UploadExpires      time.Duration
+	MaxSectionBytes    int
+	MaxOutputBytes     int
+	PlainReport        bool
+	Deterministic      bool
+	Normalize          bool
+	Anonymize          bool
+	Record             string
+	Replay             string
+	Archive            bool
+	IncludeConsumers   bool
+	NoHistory          bool
+	NoCredentialsCheck bool
+	NoCost             bool
+	Lint               bool
+	Init               bool
+	Precheck           bool
+	AutoInit           bool
+	LogFile            bool
+	ExpectNoChanges    bool
+	PlanTimeout        time.Duration
+	LockTimeout        time.Duration
+	Retries            int
+	Incremental        bool
+	KeepGoing          bool
+	Force              bool
+	RemovePartial      bool
+	Timeout            time.Duration
+	Slowest            int
+	TUI                bool
+	Hook               bool
+
+	// Given tells whether a flag, e.g. "parallelism", was set, overriding
+	// the config; nil sets none.
+	Given func(flag string) bool
+}
+
+// given tells whether flag was set.
+func (opts *GeneratorOptions) given(flag string) bool {
+	return opts.Given != nil && opts.Given(flag)
+}
+
+// flagOptions reads the options from the command's flags.
+func flagOptions(flags *pflag.FlagSet) GeneratorOptions {
+	opts := GeneratorOptions{Verbosity: verboseLevel(flags), Given: flags.Changed}
+	opts.ConfigFile, _ = flags.GetString("config")
+	opts.Runner, _ = flags.GetString("runner")
+	opts.Targeted, _ = flags.GetBool("targeted")
+	opts.Mode, _ = flags.GetString("mode")
+	opts.OutputDir, _ = flags.GetString("output")
+	opts.Parallelism, _ = flags.GetString("parallelism")
+	opts.Formats, _ = flags.GetStringSlice("format")
+	opts.Snapshot, _ = flags.GetBool("snapshot")
+	opts.CollapseForEach, _ = flags.GetInt("collapse-for-each")
+	opts.MergeIdentical, _ = flags.GetBool("merge-identical")
+	opts.ApplyOrder, _ = flags.GetBool("apply-order")
+	opts.Approvals, _ = flags.GetBool("approval-checklist")
+	opts.Graph, _ = flags.GetBool("graph")
+	opts.GraphDot, _ = flags.GetString("graph-dot")
+	opts.VarFiles, _ = flags.GetStringArray("var-file")
+	opts.Targets, _ = flags.GetStringArray("target")
+	opts.Select, _ = flags.GetString("select")
+	opts.Base, _ = flags.GetString("base")
+	opts.WarningsAsErrors, _ = flags.GetBool("warnings-as-errors")
+	opts.Destroy, _ = flags.GetBool("destroy")
+	opts.SavePlans, _ = flags.GetBool("save-plans")
+	opts.PolicyDir, _ = flags.GetString("policy-dir")
+	opts.SecurityScanner, _ = flags.GetString("security-scanner")
+	opts.Stdout, _ = flags.GetBool("stdout")
+	opts.Quiet, _ = flags.GetBool("quiet")
+	opts.Copy, _ = flags.GetBool("copy")
+	opts.Open, _ = flags.GetBool("open")
+	opts.DryRun, _ = flags.GetBool("dry-run")
+	opts.EmitScript, _ = flags.GetString("emit-script")
+	opts.GitHubComment, _ = flags.GetBool("github-comment")
+	opts.PRDescription, _ = flags.GetBool("update-pr-description")
+	opts.PRNumber, _ = flags.GetInt("pr-number")
+	opts.GitHubStatus, _ = flags.GetBool("github-status")
+	opts.Email, _ = flags.GetBool("email")
+	opts.Jira, _ = flags.GetString("jira")
+	opts.CommitArtifacts, _ = flags.GetBool("commit-artifacts")
+	opts.ReleaseNotes, _ = flags.GetBool("release-notes")
+	opts.Upload, _ = flags.GetString("upload")
+	opts.UploadExpires, _ = flags.GetDuration("upload-expires")
+	opts.MaxSectionBytes, _ = flags.GetInt("max-section-bytes")
+	opts.MaxOutputBytes, _ = flags.GetInt("max-output-bytes")
+	opts.PlainReport, _ = flags.GetBool("plain-report")
+	opts.Deterministic, _ = flags.GetBool("deterministic")
+	opts.Normalize, _ = flags.GetBool("normalize")
+	opts.Anonymize, _ = flags.GetBool("anonymize")
+	opts.Record, _ = flags.GetString("record")
+	opts.Replay, _ = flags.GetString("replay")
+	opts.Archive, _ = flags.GetBool("archive")
+	opts.IncludeConsumers, _ = flags.GetBool("include-consumers")
+	opts.NoHistory, _ = flags.GetBool("no-history")
+	opts.NoCredentialsCheck, _ = flags.GetBool("no-credentials-check")
+	opts.NoCost, _ = flags.GetBool("no-cost")
+	opts.Lint, _ = flags.GetBool("lint")
+	opts.Init, _ = flags.GetBool("init")
+	opts.Precheck, _ = flags.GetBool("precheck")
+	opts.AutoInit, _ = flags.GetBool("auto-init")
+	opts.LogFile, _ = flags.GetBool("log-file")
+	opts.ExpectNoChanges, _ = flags.GetBool("expect-no-changes")
+	opts.PlanTimeout, _ = flags.GetDuration("plan-timeout")
+	opts.LockTimeout, _ = flags.GetDuration("lock-timeout")
+	opts.Retries, _ = flags.GetInt("retries")
+	opts.Incremental, _ = flags.GetBool("incremental")
+	opts.KeepGoing, _ = flags.GetBool("keep-going")
+	opts.Force, _ = flags.GetBool("force")
+	opts.RemovePartial, _ = flags.GetBool("remove-partial")
+	opts.Timeout, _ = flags.GetDuration("timeout")
+	opts.Slowest, _ = flags.GetInt("slowest")
+	opts.TUI, _ = flags.GetBool("tui")
+	opts.Hook, _ = flags.GetBool("hook")
+	return opts
+}
+
+// DefaultGeneratorOptions are the options of the command run without flags,
+// e.g. UploadExpires at --upload-expires's default.
+func DefaultGeneratorOptions() GeneratorOptions {
+	opts := flagOptions(planFlags(&cobra.Command{}))
+	opts.Given = nil
+	return opts
+}
@@ -0,0 +1,91 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// offloadedSection is a plan too large to embed, moved to a link.
+type offloadedSection struct {
+	URL   string
+	Bytes int
+}
+
+// sectionKey identifies one region's plan in the report.
+func sectionKey(p *Partition, env, region string) string {
+	return p.Name + "/" + env + "/" + region
+}
+
+// offloadLargeSections moves region plans larger than MaxSectionBytes out
+// of the report: into the --upload destination when there is one,
+// otherwise into secret gists when GIST_TOKEN is set. Sections that can't
+// be moved stay embedded, so nothing is ever dropped.
+func (pg *PlanGenerator) offloadLargeSections(results []*PartitionResult) {
+	if pg.MaxSectionBytes <= 0 {
+		return
+	}
+	var gists *githubClient
+	if token := os.Getenv("GIST_TOKEN"); token != "" && pg.upload == nil {
+		gists = newGitHubAPI()
+		gists.token = token
+	}
+
+	for _, result := range results {
+		for _, env := range result.Environments {
+			for _, region := range env.Regions {
+				content := collapseForEach(env.Plans[region], pg.CollapseForEach)
+				if len(content) <= pg.MaxSectionBytes {
+					continue
+				}
+				name := fmt.Sprintf("%s-%s-%s-%s.txt", pg.ModuleName, result.Partition.Name, env.Name, region)
+				var url string
+				var err error
+				switch {
+				case pg.upload != nil:
+					url, err = pg.uploadSection(name, content)
+				case gists != nil:
+					url, err = gists.createGist(fmt.Sprintf("terraform plan: %s %s %s", pg.ModuleName, env.Name, region), name, content)
+				default:
+					warningColor.Printf("⚠️  %s %s plan is %d KB; set --upload or GIST_TOKEN to link it instead of embedding it\n", env.Name, region, len(content)/1024)
+					continue
+				}
+				if err != nil {
+					warningColor.Printf("⚠️  Couldn't move the %s %s plan out of the report, embedding it: %v\n", env.Name, region, err)
+					continue
+				}
+				if pg.offloaded == nil {
+					pg.offloaded = make(map[string]*offloadedSection)
+				}
+				pg.offloaded[sectionKey(result.Partition, env.Name, region)] = &offloadedSection{URL: url, Bytes: len(content)}
+				if pg.Verbose {
+					fmt.Fprintf(console, "📎 Linked %s %s plan (%d KB): %s\n", env.Name, region, len(content)/1024, url)
+				}
+			}
+		}
+	}
+}
+
+// uploadSection writes a section below sections/ in the output directory,
+// which --upload copies along with everything else, and signs its link.
+func (pg *PlanGenerator) uploadSection(name, content string) (string, error) {
+	rel := filepath.ToSlash(filepath.Join("sections", name))
+	if err := os.MkdirAll(filepath.Join(pg.OutputDir, "sections"), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(pg.OutputDir, rel), []byte(content), 0644); err != nil {
+		return "", err
+	}
+	return pg.upload.presign(pg.OutputDir, rel, pg.UploadExpires)
+}
+
+// offloadedNote replaces the body of a linked section, keeping the plan's
+// summary line so reviewers still see the scale of the change.
+func (pg *PlanGenerator) offloadedNote(section *offloadedSection, content string) string {
+	note := fmt.Sprintf("> %sThis plan is too large to embed (%d KB): [view the full plan](%s)", pg.renderer().Icon("📎"), section.Bytes/1024, section.URL)
+	if summary := planCountsRegex.FindString(content); summary != "" {
+		note += "\n>\n> `" + strings.TrimSpace(summary) + "`"
+	}
+	return note + "\n\n"
+}
@@ -0,0 +1,275 @@
+package planner
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// stateHeader precedes each state's output in a plans file so the parser
+// can attribute it even when the runner doesn't print the state path.
+const stateHeader = "### terraform-pr-generator state:"
+
+var (
+	stateEnvRegex    = regexp.MustCompile(` env=(\S+)`)
+	stateRegionRegex = regexp.MustCompile(` region=(\S+)`)
+)
+
+var modulePrefixRegex = regexp.MustCompile(`^\[([^\]]+)\] ?(.*)$`)
+
+// demultiplexModulePrefix regroups interleaved "[state path] line" output
+// (terragrunt --terragrunt-include-module-prefix) into one contiguous block
+// per state, each preceded by a state header.
+func demultiplexModulePrefix(content string) string {
+	var order []string
+	streams := make(map[string][]string)
+	last := ""
+	for _, line := range strings.Split(content, "\n") {
+		prefix, text := last, line
+		if m := modulePrefixRegex.FindStringSubmatch(line); m != nil {
+			prefix, text = m[1], m[2]
+		}
+		if _, seen := streams[prefix]; !seen {
+			order = append(order, prefix)
+		}
+		streams[prefix] = append(streams[prefix], text)
+		last = prefix
+	}
+
+	var out strings.Builder
+	for _, prefix := range order {
+		if prefix != "" {
+			fmt.Fprintf(&out, "%s %s/\n", stateHeader, strings.TrimSuffix(prefix, "/"))
+		}
+		out.WriteString(strings.Join(streams[prefix], "\n"))
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// PlanParser parses the plans of a partition out of the output of its
+// runner.
+type PlanParser struct {
+	// Partition tells the environment and region of each plan from the
+	// state paths in the output. It has to come from a Config LoadConfig or
+	// ParseConfig returned, which compile its patterns.
+	Partition *Partition
+	// ModulePrefix is set for output whose lines carry a "[state path]"
+	// prefix (terragrunt --terragrunt-include-module-prefix).
+	ModulePrefix bool
+	// EnvironmentOrder lists globs of environment names sorted first, in
+	// that order, e.g. promotion order; the others follow by name.
+	EnvironmentOrder []string
+	// Normalize makes plan bodies stable across runs (see stablePlan).
+	Normalize bool
+}
+
+// Parse returns the environments planned in a runner's output, sorted by
+// EnvironmentOrder, then name, with their regions sorted, and warnings
+// about the plans it had to guess at, dropped or found incomplete.
+func (pp *PlanParser) Parse(content string) ([]*Environment, []string) {
+	if pp.ModulePrefix {
+		content = demultiplexModulePrefix(content)
+	}
+	environments, warnings := parsePlans(content, pp.Partition)
+
+	var envNames []string
+	for name := range environments {
+		envNames = append(envNames, name)
+	}
+	sort.Slice(envNames, func(i, j int) bool { return environmentLess(pp.EnvironmentOrder, envNames[i], envNames[j]) })
+
+	var sorted []*Environment
+	for _, envName := range envNames {
+		env := environments[envName]
+		sort.Strings(env.Regions)
+		for _, region := range env.Regions {
+			if pp.Normalize {
+				env.Plans[region] = stablePlan(env.Plans[region])
+			}
+			if env.Incomplete[region] {
+				warnings = append(warnings, fmt.Sprintf("incomplete plan for %s/%s (no Plan: summary found)", env.Name, region))
+			}
+		}
+		sorted = append(sorted, env)
+	}
+	return sorted, warnings
+}
+
+// environmentLess sorts environments matching an earlier glob of order
+// first, then by name.
+func environmentLess(order []string, a, b string) bool {
+	if rankA, rankB := environmentRank(order, a), environmentRank(order, b); rankA != rankB {
+		return rankA < rankB
+	}
+	return a < b
+}
+
+// environmentRank is the index of the first glob of order matching env,
+// len(order) if none does.
+func environmentRank(order []string, env string) int {
+	for i, pattern := range order {
+		if ok, _ := path.Match(pattern, env); ok {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// errorBlockEnd closes the boxed diagnostics terraform prints for errors.
+const errorBlockEnd = "╵"
+
+var errorLineRegex = regexp.MustCompile(`^\s*(│\s*)?Error:`)
+
+// parsePlans extracts per-environment, per-region plan bodies from raw
+// runner output. A body starts at "Terraform will perform the following
+// actions:" and ends at the "Plan:" summary; bodies that never reach the
+// summary are kept and flagged as incomplete rather than dropped. Anything
+// the parser had to guess at or throw away is returned as a warning.
+func parsePlans(content string, p *Partition) (map[string]*Environment, []string) {
+	environments := make(map[string]*Environment)
+	lines := strings.Split(content, "\n")
+	var warnings []string
+
+	var currentEnv, currentRegion string
+	// The environment and region the open plan body started under.
+	var sectionEnv, sectionRegion string
+	var planLines []string
+	var inPlanSection, sawError bool
+	// Set while a state header pinned the environment and region.
+	var pinned bool
+
+	record := func(incomplete bool) {
+		switch {
+		case sectionEnv == "":
+			warnings = append(warnings, fmt.Sprintf("dropped a %d-line plan: no environment matched", len(planLines)))
+		case sectionRegion == "":
+			warnings = append(warnings, fmt.Sprintf("dropped a %d-line plan for %s: no region matched", len(planLines), sectionEnv))
+		}
+		if sectionEnv != "" && sectionRegion != "" {
+			if environments[sectionEnv] == nil {
+				environments[sectionEnv] = &Environment{
+					Name:       sectionEnv,
+					Regions:    []string{},
+					Plans:      make(map[string]string),
+					Incomplete: make(map[string]bool),
+				}
+			}
+			env := environments[sectionEnv]
+
+			// A complete plan always wins over a partial one for the same region.
+			if _, exists := env.Plans[sectionRegion]; exists && incomplete && !env.Incomplete[sectionRegion] {
+				warnings = append(warnings, fmt.Sprintf("dropped a partial plan for %s/%s in favour of a complete one", sectionEnv, sectionRegion))
+				planLines = []string{}
+				inPlanSection, sawError = false, false
+				return
+			}
+			if _, exists := env.Plans[sectionRegion]; exists && !incomplete && !env.Incomplete[sectionRegion] {
+				warnings = append(warnings, fmt.Sprintf("%s/%s was planned more than once; keeping the last plan", sectionEnv, sectionRegion))
+			}
+
+			if !contains(env.Regions, sectionRegion) {
+				env.Regions = append(env.Regions, sectionRegion)
+			}
+			env.Plans[sectionRegion] = strings.TrimRight(strings.Join(planLines, "\n"), "\n")
+			env.Incomplete[sectionRegion] = incomplete
+		}
+		planLines = []string{}
+		inPlanSection, sawError = false, false
+	}
+
+	for _, line := range lines {
+		// State headers may carry the environment and region explicitly
+		if strings.HasPrefix(line, stateHeader) {
+			// A new state starts; a body still open belongs to the last one
+			if inPlanSection {
+				record(true)
+			}
+			pinned = false
+			if m := stateEnvRegex.FindStringSubmatch(line); m != nil {
+				currentEnv, pinned = m[1], true
+			}
+			if m := stateRegionRegex.FindStringSubmatch(line); m != nil {
+				currentRegion, pinned = m[1], true
+			}
+			if pinned {
+				continue
+			}
+			if _, ok := p.MatchEnv(line); !ok {
+				warnings = append(warnings, fmt.Sprintf("state %q matched no environment", strings.TrimSpace(strings.TrimPrefix(line, stateHeader))))
+			} else if _, ok := p.MatchRegion(line); !ok {
+				warnings = append(warnings, fmt.Sprintf("state %q has no recognisable region", strings.TrimSpace(strings.TrimPrefix(line, stateHeader))))
+			}
+		}
+
+		// Check for environment/region markers in file paths. Plan bodies
+		// name environments and regions of their own, so an open body only
+		// ends at a line naming both of another state, as incomplete: it
+		// never reached its summary.
+		if !pinned {
+			env, envOK := p.MatchEnv(line)
+			region, regionOK := p.MatchRegion(line)
+			if inPlanSection {
+				if envOK && regionOK && (env != sectionEnv || region != sectionRegion) {
+					record(true)
+				} else {
+					envOK, regionOK = false, false
+				}
+			}
+			if envOK {
+				currentEnv = env
+			}
+			if regionOK {
+				currentRegion = region
+			}
+		}
+
+		// Start collecting plan content when we see "Terraform will perform"
+		if strings.Contains(line, "Terraform will perform the following actions:") {
+			if inPlanSection {
+				record(true)
+			}
+			inPlanSection = true
+			sectionEnv, sectionRegion = currentEnv, currentRegion
+			planLines = []string{line}
+			continue
+		}
+
+		// If we're in a plan section, collect lines
+		if inPlanSection {
+			planLines = append(planLines, line)
+
+			if errorLineRegex.MatchString(line) {
+				sawError = true
+			}
+
+			// End plan section when we see "Plan: X to add, Y to change, Z to destroy"
+			if isPlanSummary(line) {
+				record(false)
+			} else if sawError && strings.TrimSpace(line) == errorBlockEnd {
+				record(true)
+			}
+		}
+	}
+
+	if inPlanSection {
+		record(true)
+	}
+
+	return environments, warnings
+}
+
+func isPlanSummary(line string) bool {
+	return strings.Contains(line, "Plan:") && (strings.Contains(line, "to add") || strings.Contains(line, "to change") || strings.Contains(line, "to destroy"))
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
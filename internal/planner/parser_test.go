@@ -0,0 +1,188 @@
+package planner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanParserParse(t *testing.T) {
+	output := stagingPlan + `Running in /repo/terragrunt_vpc/organizations/production/us-west-2/
+Terraform will perform the following actions:
+
+  # aws_vpc.this will be destroyed
+  - resource "aws_vpc" "this" {}
+
+Plan: 0 to add, 0 to change, 1 to destroy.
+Running in /repo/terragrunt_vpc/organizations/production/eu-west-1/
+No changes. Your infrastructure matches the configuration.
+`
+	parser := &PlanParser{Partition: commercialPartition(t)}
+	envs, warnings := parser.Parse(output)
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if len(envs) != 2 || envs[0].Name != "production" || envs[1].Name != "staging" {
+		t.Fatalf("environments = %v, want production and staging", envNames(envs))
+	}
+	production := envs[0]
+	if len(production.Regions) != 1 || production.Regions[0] != "us-west-2" {
+		t.Errorf("production regions = %v, want [us-west-2]", production.Regions)
+	}
+	plan := production.Plans["us-west-2"]
+	if !strings.HasPrefix(plan, "Terraform will perform the following actions:") || !strings.HasSuffix(plan, "1 to destroy.") {
+		t.Errorf("production plan = %q", plan)
+	}
+	if production.Incomplete["us-west-2"] {
+		t.Error("production plan marked incomplete")
+	}
+}
+
+func TestPlanParserIncompletePlan(t *testing.T) {
+	output := `Running in /repo/terragrunt_vpc/organizations/staging/us-east-1/
+Terraform will perform the following actions:
+
+  # aws_vpc.this will be created
+  + resource "aws_vpc" "this" {}
+╷
+│ Error: creating VPC: UnauthorizedOperation
+╵
+`
+	parser := &PlanParser{Partition: commercialPartition(t)}
+	envs, warnings := parser.Parse(output)
+	if len(envs) != 1 || !envs[0].Incomplete["us-east-1"] {
+		t.Fatalf("want an incomplete staging/us-east-1 plan, got %v", envNames(envs))
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "incomplete plan for staging/us-east-1") {
+		t.Errorf("warnings = %v", warnings)
+	}
+}
+
+func TestPlanParserCompletePlanWins(t *testing.T) {
+	partial := `Running in /repo/terragrunt_vpc/organizations/staging/us-east-1/
+Terraform will perform the following actions:
+  + resource "aws_vpc" "this" {}
+`
+	parser := &PlanParser{Partition: commercialPartition(t)}
+	envs, warnings := parser.Parse(stagingPlan + partial)
+	if len(envs) != 1 || envs[0].Incomplete["us-east-1"] {
+		t.Fatalf("the complete plan should be kept")
+	}
+	if !strings.HasSuffix(envs[0].Plans["us-east-1"], "0 to destroy.") {
+		t.Errorf("plan = %q", envs[0].Plans["us-east-1"])
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "in favour of a complete one") {
+		t.Errorf("warnings = %v", warnings)
+	}
+}
+
+func TestPlanParserIncompletePlanKeepsItsState(t *testing.T) {
+	output := `Running in /repo/terragrunt_vpc/organizations/staging/us-east-1/
+Terraform will perform the following actions:
+  + resource "aws_vpc" "this" {
+      + arn = "arn:aws:ec2:eu-west-1:123456789012:vpc/organizations/sandbox/"
+    }
+Running in /repo/terragrunt_vpc/organizations/production/us-west-2/
+Terraform will perform the following actions:
+  - resource "aws_vpc" "this" {}
+Plan: 0 to add, 0 to change, 1 to destroy.
+`
+	parser := &PlanParser{Partition: commercialPartition(t)}
+	envs, _ := parser.Parse(output)
+	if len(envs) != 2 || envs[0].Name != "production" || envs[1].Name != "staging" {
+		t.Fatalf("environments = %v, want production and staging", envNames(envs))
+	}
+	staging, production := envs[1], envs[0]
+	if len(staging.Regions) != 1 || !staging.Incomplete["us-east-1"] || !strings.Contains(staging.Plans["us-east-1"], "vpc/organizations/sandbox/") {
+		t.Errorf("staging = %v %q, want an incomplete us-east-1 plan", staging.Regions, staging.Plans["us-east-1"])
+	}
+	if strings.Contains(staging.Plans["us-east-1"], "production") {
+		t.Errorf("staging plan runs into the next state: %q", staging.Plans["us-east-1"])
+	}
+	if len(production.Regions) != 1 || production.Incomplete["us-west-2"] || !strings.HasSuffix(production.Plans["us-west-2"], "1 to destroy.") {
+		t.Errorf("production = %v %q, want a complete us-west-2 plan", production.Regions, production.Plans["us-west-2"])
+	}
+}
+
+func TestPlanParserUnmatchedEnvironment(t *testing.T) {
+	output := `Terraform will perform the following actions:
+  + resource "aws_vpc" "this" {}
+Plan: 1 to add, 0 to change, 0 to destroy.
+`
+	parser := &PlanParser{Partition: commercialPartition(t)}
+	envs, warnings := parser.Parse(output)
+	if len(envs) != 0 {
+		t.Errorf("environments = %v, want none", envNames(envs))
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "no environment matched") {
+		t.Errorf("warnings = %v", warnings)
+	}
+}
+
+func TestPlanParserStateHeader(t *testing.T) {
+	output := stateHeader + " terragrunt_vpc/live env=sandbox region=ap-south-1\n" + `Terraform will perform the following actions:
+  + resource "aws_vpc" "this" {}
+Plan: 1 to add, 0 to change, 0 to destroy.
+`
+	parser := &PlanParser{Partition: commercialPartition(t)}
+	envs, warnings := parser.Parse(output)
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if len(envs) != 1 || envs[0].Name != "sandbox" || envs[0].Plans["ap-south-1"] == "" {
+		t.Errorf("want a sandbox/ap-south-1 plan, got %v", envNames(envs))
+	}
+}
+
+func TestPlanParserModulePrefix(t *testing.T) {
+	a := "[terragrunt_vpc/organizations/staging/us-east-1] "
+	b := "[terragrunt_vpc/organizations/production/us-east-1] "
+	output := strings.Join([]string{
+		a + "Terraform will perform the following actions:",
+		b + "Terraform will perform the following actions:",
+		a + `  + resource "aws_vpc" "this" {}`,
+		b + `  - resource "aws_vpc" "this" {}`,
+		a + "Plan: 1 to add, 0 to change, 0 to destroy.",
+		b + "Plan: 0 to add, 0 to change, 1 to destroy.",
+	}, "\n")
+	parser := &PlanParser{Partition: commercialPartition(t), ModulePrefix: true}
+	envs, warnings := parser.Parse(output)
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if len(envs) != 2 {
+		t.Fatalf("environments = %v, want production and staging", envNames(envs))
+	}
+	if plan := envs[1].Plans["us-east-1"]; !strings.Contains(plan, "+ resource") || strings.Contains(plan, "- resource") {
+		t.Errorf("staging plan mixes in other states: %q", plan)
+	}
+}
+
+func TestParsePlanCounts(t *testing.T) {
+	counts, ok := ParsePlanCounts("...\nPlan: 3 to add, 1 to change, 2 to destroy.\n")
+	if !ok || counts != (PlanCounts{Add: 3, Change: 1, Destroy: 2}) {
+		t.Errorf("ParsePlanCounts = %+v, %v", counts, ok)
+	}
+	if _, ok := ParsePlanCounts("No changes."); ok {
+		t.Error("ParsePlanCounts found counts in a plan without a summary")
+	}
+}
+
+func TestPlanParserEnvironmentOrder(t *testing.T) {
+	var output strings.Builder
+	for _, env := range []string{"sandbox", "production", "govcloud-staging", "staging", "dev"} {
+		output.WriteString(strings.ReplaceAll(stagingPlan, "/staging/", "/"+env+"/"))
+	}
+	parser := &PlanParser{Partition: commercialPartition(t), EnvironmentOrder: []string{"dev", "staging", "prod*", "govcloud-*"}}
+	envs, _ := parser.Parse(output.String())
+	if got := strings.Join(envNames(envs), " "); got != "dev staging production govcloud-staging sandbox" {
+		t.Errorf("environments = %s, want promotion order, then the rest by name", got)
+	}
+}
+
+func envNames(envs []*Environment) []string {
+	var names []string
+	for _, env := range envs {
+		names = append(names, env.Name)
+	}
+	return names
+}
@@ -0,0 +1,2044 @@
+// Package planner implements the terraform-pr-generator command: it runs
+// terraform plans for a module across partitions, environments and regions
+// and turns their output into PR-ready reports.
+//
+// NewRootCmd is the command and PlanGenerator drives a run of it. Other
+// tools use pkg/planner, the public API over this package.
+package planner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// PlanGenerator plans a module, one plans file per partition, and writes
+// the reports of the run to OutputDir. NewGenerator sets one up from a run's
+// options and the config, newPlanGenerator from the command line flags.
+type PlanGenerator struct {
+	ModuleName string
+	ModuleRoot string // module root holding terragrunt_<ModuleName>, "." for the repository root
+	OutputDir  string
+	Verbose    bool
+	Targeted   bool
+	AutoMode   bool // pick Targeted from the branch's changes (--mode auto)
+	Formats    []string
+	Snapshot   bool     // record input versions per state in the manifest
+	States     []*State // explicit states to plan, skipping discovery
+	VarFiles   []string // absolute tfvars paths passed as -var-file
+	Targets    []string // resource addresses passed as -target
+	Select     string   // --select expression narrowing the planned states
+	Base       string   // leave out region plans unchanged from the merge-base with this ref
+	Destroy    bool     // plan with -destroy and label the report as such
+	SavePlans  bool     // write each targeted state's plan with -out
+	PolicyDir  string   // Rego policies conftest evaluates against each targeted state's plan JSON
+	Stdout     bool     // print the rendered markdown to stdout instead of the usual summary
+	Quiet      bool     // print only errors, and the report's path to stdout
+	Copy       bool     // place pr-ready.md onto the clipboard once written
+	Open       bool     // preview pr-ready.md as HTML in the default browser
+	DryRun     bool     // print the plan commands to stdout instead of running them
+	EmitScript string   // write the plan commands to this shell script instead of running them
+	ExtraArgs  []string // forwarded to every plan command
+	Config     *Config
+
+	// Executor runs the run's commands; nil runs them as local processes.
+	Executor Executor
+
+	// CollapseForEach merges at least this many identical for_each
+	// instances into one markdown entry (0 disables).
+	CollapseForEach int
+	// MergeIdentical shows environments with the same plans in every
+	// region once, under one heading listing them all.
+	MergeIdentical bool
+	// ApplyOrder appends a suggested apply order checklist.
+	ApplyOrder bool
+	// Approvals appends a checkbox per environment for reviewers
+	// to approve its plan.
+	Approvals bool
+	// Graph draws the targeted states and their dependencies as a Mermaid
+	// graph.
+	Graph bool
+	// GraphDot is where the targeted states' dependency graph is written
+	// in Graphviz DOT.
+	GraphDot string
+	// WarningsAsErrors fails the run once the reports are written if
+	// parsing produced any warnings.
+	WarningsAsErrors bool
+	// GitHubComment keeps a pull request comment updated with partial
+	// results while plans run, then the final report. PRNumber overrides
+	// the pull request detected from GITHUB_REF.
+	GitHubComment bool
+	PRNumber      int
+	// PRDescription puts the final report between marker comments in the
+	// pull request's description instead.
+	PRDescription bool
+	// GitHubStatus sets a commit status per partition: pending while plans
+	// run, then whether they're clean.
+	GitHubStatus bool
+	// Email sends the report to email.to once the run completes.
+	Email bool
+	// Jira is the key of the Jira issue the change belongs to, linked from
+	// the report; found in the branch name when empty and jira.url is set.
+	Jira string
+	// CommitArtifacts commits the report to the branch and pushes it.
+	CommitArtifacts bool
+	// ReleaseNotes embeds the release notes of module versions bumped on
+	// the branch.
+	ReleaseNotes bool
+	// Upload is an s3:// or gs:// location the output directory is copied
+	// to, with links valid for UploadExpires embedded in the report.
+	Upload        string
+	UploadExpires time.Duration
+	// Archive packs the output directory into a .tar.gz next to it.
+	Archive bool
+	// PlainReport renders the report without emoji, HTML or
+	// color-dependent cues, for screen readers and HTML-stripping tools.
+	PlainReport bool
+	// Deterministic fixes the run's timestamps and leaves durations out of
+	// the output, so runs of the same plans compare byte for byte.
+	Deterministic bool
+	// Normalize strips timestamps, sorts maps and collapses whitespace in
+	// plan bodies, so runs of the same changes show the same plans.
+	Normalize bool
+	// Anonymize replaces account IDs, bucket and role names and hostnames
+	// in the plans with pseudonyms, the same for each value throughout the
+	// run, so they can be shared outside the organization.
+	Anonymize bool
+	// MaxSectionBytes is the largest region plan embedded in the report;
+	// larger ones are linked instead where possible. 0 embeds everything.
+	MaxSectionBytes int
+	// MaxOutputBytes is the size budget of pr-ready.md: the largest plans
+	// are cut down to their resource headers until it fits. 0 is no limit.
+	MaxOutputBytes int
+	// IncludeConsumers adds unplanned states reading shared files changed
+	// on the branch to targeted runs.
+	IncludeConsumers bool
+	// History records the run in the local history database.
+	History bool
+	// CheckCredentials checks the AWS credentials of the planned
+	// partitions before any plan starts, failing fast on expired ones.
+	CheckCredentials bool
+	// Cost estimates the monthly cost change of the saved plans with
+	// Infracost, when it's installed.
+	Cost bool
+	// Lint runs tflint on the configuration of the planned states.
+	Lint bool
+	// ExpectNoChanges fails the run with a drift report if any plan
+	// changes something (scheduled drift detection).
+	ExpectNoChanges bool
+	// Init initializes every targeted state up front, downloading each
+	// provider version once, before the plans run.
+	Init bool
+	// AutoInit initializes a targeted state whose plan failed for lack of
+	// init, then plans it again.
+	AutoInit bool
+	// Precheck runs terraform fmt -check, terraform validate and
+	// terragrunt hclfmt before planning, failing fast on their errors.
+	Precheck bool
+	// TUI monitors the run in an interactive terminal UI.
+	TUI bool
+	// Retries is how many times a failed targeted plan is run again, with
+	// exponential backoff.
+	Retries int
+	// KeepGoing lets the run go on past failed plans, listing them in the
+	// report's "Failed states" section, and fails it once it's out.
+	KeepGoing bool
+	// Force takes over the checkout's run lock from a run still in
+	// progress.
+	Force bool
+	// RemovePartial removes the output directory of a failed run rather
+	// than leaving it marked INCOMPLETE.
+	RemovePartial bool
+	// PlanTimeout kills and fails a targeted plan running longer, e.g.
+	// one stuck on a state lock. 0 is no limit.
+	PlanTimeout time.Duration
+	// LockTimeout makes plans wait this long for a held state lock
+	// (-lock-timeout) before failing on it.
+	LockTimeout time.Duration
+	// Timeout interrupts the run once it has taken this long, so the
+	// report covers the plans finished by then. 0 is no limit.
+	Timeout time.Duration
+	// Slowest is how many of the slowest plans the console summary names.
+	Slowest int
+	// LogFile writes a debug log of the run, its commands included, to
+	// debug.log in the output directory.
+	LogFile bool
+	// Incremental reuses the cached plan of a targeted state whose inputs
+	// haven't changed since it last planned.
+	Incremental bool
+
+	pool *workerPool
+	// progress reports on the states being planned.
+	progress *progress
+	// ctx is cancelled to stop the run's commands; cancelRun does so.
+	ctx       context.Context
+	cancelRun context.CancelFunc
+	// cancelledStates were cancelled one by one from the TUI and are left
+	// out of the report; interruptedStates were running or waiting when
+	// the run was interrupted. Both are guarded by flushMu.
+	cancelledStates   []string
+	interruptedStates []string
+	// failures are the plans that failed under KeepGoing, guarded by
+	// flushMu.
+	failures []*stateFailure
+	// attempts are the attempts of targeted states that needed more than
+	// one, guarded by flushMu.
+	attempts map[string]int
+	// reusedStates are the targeted states whose cached plan was reused
+	// (Incremental), guarded by flushMu.
+	reusedStates []string
+	// autoInits are the state directories initialized by AutoInit, guarded
+	// by flushMu.
+	autoInits map[string]*stateInit
+	// assumedRoles are the session credentials of the aws_credentials
+	// roles the run's plans use, assumed before any plan starts.
+	assumedRoles map[*AWSCredentials][]string
+	// stopped is why the run was interrupted before its plans were done,
+	// nil when it wasn't.
+	stopped error
+	// partitionPools are the pools of partitions with their own parallel
+	// setting, created on first use.
+	partitionPools map[string]*workerPool
+	poolsMu        sync.Mutex
+	// plannedStates are the targeted states of the current run.
+	plannedStates []*State
+	// results are the parsed plans, once collected.
+	results []*PartitionResult
+	// drift lists the region plans with changes (--expect-no-changes).
+	drift []*driftedRegion
+	// baseUnchanged lists the region plans left out as the same on the
+	// merge-base (--base), as partition/env/region.
+	baseUnchanged []string
+	// revisions are the commits each partition was planned against,
+	// indexed like Config.Partitions (nil for skipped partitions).
+	revisions []*groupRevision
+	// moduleBumps are the module versions changed on the branch, with
+	// their release notes (--release-notes).
+	moduleBumps []*moduleBump
+	// upload is the parsed Upload destination and artifacts the presigned
+	// links to its files.
+	upload    Storage
+	artifacts []*artifactLink
+	// selector is the parsed Select expression.
+	selector selector
+	// offloaded are the region plans linked instead of embedded, by
+	// sectionKey.
+	offloaded map[string]*offloadedSection
+	// truncated are the region plans cut down to fit MaxOutputBytes, by
+	// sectionKey.
+	truncated map[string]*truncatedSection
+	// skew lists module and provider versions that differ between the
+	// module's states.
+	skew []*versionSkew
+	// toolVersions are the terraform and terragrunt versions of the
+	// planned states.
+	toolVersions []*stateTools
+	// tracer exports the run's spans when tracing is configured; runSpan
+	// is the span of the whole run.
+	tracer  *tracer
+	runSpan *span
+	// metrics pushes the run's metrics to a Pushgateway, when configured.
+	metrics *metricsPusher
+	// timings are how long each state's plan took, slowest first.
+	timings []*stateTiming
+	// lint is what tflint found in the planned states' configuration.
+	lint *lintResult
+	// tagPolicy is what checking Config.TagPolicy found.
+	tagPolicy *tagPolicyResult
+	// security is what the Config.Security scanner found.
+	security *securityResult
+	// policy is what the policies of PolicyDir found in the plans.
+	policy *policyResult
+	// cost is the monthly cost change Infracost estimates for the saved
+	// plans, nil without an estimate.
+	cost *costReport
+	// blastRadius lists shared files changed on the branch and the states
+	// reading them that discovery didn't plan; consumersIncluded records
+	// that they were planned after all (--include-consumers).
+	blastRadius       []*sharedChange
+	consumersIncluded bool
+	// modeReason explains the planning mode --mode auto picked.
+	modeReason string
+	// hookCtx is the context passed to hooks, set once the run is planned.
+	hookCtx *hookContext
+	// comment is the pull request comment being streamed to, if any.
+	comment *commentStream
+	// github is the client the pr subcommand authenticated; other runs
+	// take it from the environment.
+	github *githubClient
+	// description is the pull request description being updated, if any.
+	description *prDescription
+	// statuses are the partitions' commit statuses being set, if any.
+	statuses *commitStatuses
+	// jira comments the plan summary on the Jira issue, if JIRA_API_TOKEN
+	// is set.
+	jira *jiraClient
+	// remoteRuns are the runs that planned states on remote platforms, and
+	// tfc, spacelift and env0 the platforms' clients, created on first use
+	// under remoteMu.
+	remoteRuns []*remoteRun
+	tfc        *tfcClient
+	spacelift  *spaceliftClient
+	env0       *env0Client
+	remoteMu   sync.Mutex
+	// masked counts the values the masking rules replaced per state, or
+	// per partition in full runs, guarded by flushMu.
+	masked map[string]int
+	// anonymizer pseudonymizes the plans with Anonymize.
+	anonymizer *anonymizer
+	// flushMu guards plans files while they are written incrementally, and
+	// the states left out of them.
+	flushMu sync.Mutex
+	// pluginCache is the TF_PLUGIN_CACHE_DIR set for runner commands by
+	// --init, empty when the user's own applies.
+	pluginCache string
+	// createdOutputDir is set when the run created OutputDir, and
+	// outputMarked while it holds the incompleteFile marker.
+	createdOutputDir bool
+	outputMarked     bool
+	// scratch marks OutputDir as living in a temporary directory for
+	// --stdout runs, removed once the report is printed.
+	scratch bool
+	// rerun marks the scratch run of rerun-failed, whose plans are merged
+	// into the original run, so its own output paths aren't printed.
+	rerun bool
+	// resuming marks a --resume run, which reuses the checkpoints of the
+	// interrupted run it continues.
+	resuming bool
+}
+
+// errRunCancelled stops a command of an interrupted run.
+var errRunCancelled = errors.New("run cancelled")
+
+// Environment holds the plans of one environment, by region.
+type Environment struct {
+	Name    string
+	Regions []string
+	Plans   map[string]string // region -> plan content
+
+	// Incomplete marks regions whose plan body never reached a "Plan:"
+	// summary, usually because terraform errored mid-plan.
+	Incomplete map[string]bool
+}
+
+// Color definitions for better UX. Like all progress output they write to
+// stderr, keeping stdout for data such as the --stdout report, and log at
+// their level with --log-format text or json.
+var (
+	successColor = newLogColor(slog.LevelInfo, color.FgGreen, color.Bold)
+	errorColor   = newLogColor(slog.LevelError, color.FgRed, color.Bold)
+	warningColor = newLogColor(slog.LevelWarn, color.FgYellow, color.Bold)
+	infoColor    = newLogColor(slog.LevelInfo, color.FgCyan, color.Bold)
+	boldColor    = newLogColor(slog.LevelInfo, color.Bold)
+)
+
+// Execute runs the terraform-pr-generator command on the process's
+// arguments, exiting on errors.
+func Execute() {
+	if err := NewRootCmd().Execute(); err != nil {
+		errorColor.Fprintf(console, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// NewRootCmd builds the terraform-pr-generator command and its subcommands.
+func NewRootCmd() *cobra.Command {
+	var rootCmd = &cobra.Command{
+		Use:   "terraform-pr-generator [module_name] [-- plan args...]",
+		Short: "Generate terraform plans for PR workflow",
+		Long: `A CLI tool to automate terraform plan generation for PR workflow.
+Generates plans for all environments and regions, formatted for GitHub PRs.
+
+Examples:
+  terraform-pr-generator s3_malware_protection
+  terraform-pr-generator s3_malware_protection --verbose --targeted
+  terraform-pr-generator s3_malware_protection --mode auto
+  terraform-pr-generator s3_malware_protection --output my-custom-dir
+  terraform-pr-generator s3_malware_protection --var-file new-vars.tfvars
+  terraform-pr-generator s3_malware_protection --target aws_s3_bucket.this
+  terraform-pr-generator s3_malware_protection --destroy
+  terraform-pr-generator s3_malware_protection --targeted --save-plans
+  terraform-pr-generator s3_malware_protection --stdout | gh pr comment -F -
+  terraform-pr-generator s3_malware_protection -- -lock-timeout=5m -refresh=false`,
+		Args:              moduleArgs,
+		ValidArgsFunction: completeModules(1),
+		Run:               runPlanGenerator,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			applyConsoleFlags(cmd)
+			if err := changeDir(cmd); err != nil {
+				errorColor.Printf("❌ Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+		Annotations: map[string]string{findsRepoRoot: "true"},
+		Version:     CurrentBuild().Version,
+	}
+
+	rootCmd.PersistentFlags().String("log-format", "pretty", "Console output format: pretty (emoji and colors), text or json (structured logs for CI)")
+	rootCmd.PersistentFlags().String("log-level", "info", "Least severe console output shown: debug (adds the --verbose details), info, warn or error")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Print no colors (also for NO_COLOR, TERM=dumb, CI jobs and when stderr isn't a terminal)")
+	rootCmd.PersistentFlags().StringP("chdir", "C", "", "Run from this directory, e.g. the repository root, instead of the current one")
+	rootCmd.PersistentFlags().Bool("ascii", false, "Print ASCII markers instead of emoji and no colors (detected for non-UTF-8 locales and legacy Windows consoles)")
+	rootCmd.RegisterFlagCompletionFunc("log-format", cobra.FixedCompletions([]string{"pretty", "text", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("log-level", cobra.FixedCompletions([]string{"debug", "info", "warn", "error"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("chdir", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	})
+	addPlanFlags(rootCmd)
+
+	rootCmd.AddCommand(newReproduceCmd())
+	rootCmd.AddCommand(newRerunFailedCmd())
+	rootCmd.AddCommand(newDoctorCmd())
+	rootCmd.AddCommand(newListModulesCmd())
+	rootCmd.AddCommand(newListStatesCmd())
+	rootCmd.AddCommand(newExtractCmd())
+	rootCmd.AddCommand(newAnalyticsCmd())
+	rootCmd.AddCommand(newApplyCmd())
+	rootCmd.AddCommand(newCleanCmd())
+	rootCmd.AddCommand(newHistoryCmd())
+	rootCmd.AddCommand(newStatsCmd())
+	rootCmd.AddCommand(newCompareCmd())
+	rootCmd.AddCommand(newFormatCmd())
+	rootCmd.AddCommand(newGrepCmd())
+	rootCmd.AddCommand(newAuditCmd())
+	rootCmd.AddCommand(newPRCmd())
+	rootCmd.AddCommand(newBatchCmd())
+	rootCmd.AddCommand(newActionCmd())
+	rootCmd.AddCommand(newDriftCmd())
+	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newCompletionCmd())
+	rootCmd.AddCommand(newVersionCmd())
+	rootCmd.AddCommand(newSelfUpdateCmd())
+	rootCmd.AddCommand(newHookCmd())
+	return rootCmd
+}
+
+// addPlanFlags registers the flags configuring a plan run, shared by the
+// root command and action mode.
+func addPlanFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	addVerboseFlag(flags)
+	flags.Bool("dry-run", false, "Print the plan commands the run would start, after targeting and --select, without running them")
+	flags.String("emit-script", "", "Write the plan commands the run would start to a shell script, e.g. run-plans.sh, without running them")
+	flags.BoolP("quiet", "q", false, "Print only errors, and the path of pr-ready.md to stdout (scripts)")
+	flags.BoolP("targeted", "t", false, "Use targeted planning (affected-modules.sh)")
+	flags.String("mode", "", "Planning mode: full, targeted, or auto to decide from the git diff (default: --targeted)")
+	flags.StringP("output", "o", "", "Custom output directory (default: pr-plans-TIMESTAMP)")
+	flags.StringP("config", "c", "", "Path to a YAML config file (default: .tfprgen.yaml in the repo root)")
+	flags.StringSlice("format", nil, "Additional report formats to write alongside pr-ready.md: junit, json, csv or a configured formatter")
+	addParallelismFlag(flags)
+	flags.String("runner", "", "Built-in runner to plan with: kitman, terragrunt or terraform (default: from config, else kitman)")
+	flags.Bool("hook", false, "Pre-push hook mode: targeted, only pre_push.select states (env=staging), quiet; without a module, plans those the pushed commits change")
+	flags.Bool("watch", false, "Plan the affected states, then plan them again whenever .tf/.hcl files of the module change (implies --targeted)")
+	flags.Bool("tui", false, "Monitor the plans in an interactive terminal UI with per-state logs and cancellation")
+	flags.Int("collapse-for-each", 0, "Merge at least N identical for_each instances into one markdown entry (0 disables)")
+	flags.Bool("merge-identical", false, "Merge environments with identical plans in every region into one markdown section")
+	flags.Bool("apply-order", false, "Append a suggested apply order (non-prod first, dependencies respected) to the report")
+	flags.Bool("approval-checklist", false, "Append a checklist with a box per environment, and its destroy count, for reviewers to approve each plan")
+	flags.Bool("graph", false, "Draw a Mermaid graph of the module, the targeted states and their terragrunt dependencies in the report")
+	flags.String("graph-dot", "", "Write the dependency graph of the targeted states to this Graphviz DOT file, e.g. out.dot, like terragrunt graph-dependencies")
+	flags.Bool("snapshot", false, "Record module sources, provider locks and terragrunt config hashes per state in manifest.json")
+	flags.StringArray("var-file", nil, "tfvars file passed as -var-file to every plan (repeatable)")
+	flags.Bool("destroy", false, "Plan with -destroy to show what removing the module tears down everywhere")
+	flags.Bool("stdout", false, "Print only the rendered markdown to stdout; without --output nothing is kept on disk")
+	flags.Bool("copy", false, "Copy pr-ready.md to the clipboard (pbcopy, wl-copy, xclip, xsel or PowerShell)")
+	flags.Bool("open", false, "Render pr-ready.md to pr-ready.html and open it in the default browser to check it before posting")
+	flags.Bool("save-plans", false, "Save each targeted state's binary plan (-out) under tfplans/ in the output directory")
+	flags.String("security-scanner", "", "Scan each targeted state with checkov or tfsec and add the findings to the report (overrides security.scanner)")
+	flags.String("policy-dir", "", "Evaluate the Rego policies in this directory against each targeted state's plan JSON with conftest; violations fail the run")
+	flags.Bool("github-comment", false, "Stream progress and the final report into a pull request comment (needs GITHUB_TOKEN and GITHUB_REPOSITORY)")
+	flags.Bool("update-pr-description", false, "Put the report between <!-- tfprgen:start --> and <!-- tfprgen:end --> in the pull request description (needs GITHUB_TOKEN and GITHUB_REPOSITORY)")
+	flags.Int("pr-number", 0, "Pull request to comment on or describe (default: from GITHUB_REF)")
+	flags.Bool("commit-artifacts", false, "Commit pr-ready.md to .pr-plans/<module> on the branch and push it (see commit_artifacts in the config)")
+	flags.Bool("github-status", false, "Set a commit status per partition that fails on failed states or too many destroys (needs GITHUB_TOKEN and GITHUB_REPOSITORY)")
+	flags.Bool("email", false, "Email the report to email.to through the email.smtp server once the run completes (SMTP_USERNAME and SMTP_PASSWORD authenticate)")
+	flags.String("jira", "", "Jira issue the change belongs to, e.g. OPS-123, linked from the report and commented with the plan summary (default: a key in the branch name; needs jira.url or JIRA_URL)")
+	flags.Bool("warnings-as-errors", false, "Exit non-zero if parsing the plan output produced any warnings")
+	flags.Bool("release-notes", false, "Embed the GitHub release notes of module versions bumped on the branch in the report")
+	flags.String("upload", "", "Copy the output directory to s3://, gs://, az://account/container or artifactory://host/repo storage and link the files from the report")
+	flags.Duration("upload-expires", maxUploadExpiry, "How long the report's --upload links stay valid (at most 168h)")
+	flags.Bool("archive", false, "Also pack the output directory into <output>.tar.gz, e.g. to attach to a ticket")
+	flags.Bool("plain-report", false, "Write the report without emoji, HTML <details> or syntax highlighting (screen readers, ticketing systems)")
+	flags.String("record", "", "Record every command the run starts, with its output, into this fixtures directory")
+	flags.String("replay", "", "Answer the run's commands from a --record fixtures directory instead of running them")
+	flags.Bool("deterministic", false, "Fix timestamps (SOURCE_DATE_EPOCH, else 1970-01-01) and leave durations out, so runs compare byte for byte")
+	flags.Bool("normalize", false, "Strip timestamps, sort attribute maps and collapse whitespace in plan bodies, so consecutive runs show only real changes")
+	flags.Bool("anonymize", false, "Replace account IDs, bucket and role names and hostnames in the plans with stable pseudonyms, for sharing them outside the organization")
+	flags.Int("max-section-bytes", 30000, "Link region plans larger than this via --upload or a gist (GIST_TOKEN) instead of embedding them (0 embeds all)")
+	flags.Int("max-output-bytes", 0, "Size budget of pr-ready.md: truncate the largest plans to their resource headers and link them in full until it fits (0 for no limit)")
+	flags.Bool("expect-no-changes", false, "Exit with status 2 and a drift report if any plan shows changes (drift detection)")
+	flags.Bool("init", false, "Initialize all targeted states up front with a shared provider cache before planning")
+	flags.Bool("precheck", false, "Run terraform fmt -check, terraform validate and terragrunt hclfmt on the module first, failing before any plan runs")
+	flags.Bool("auto-init", false, "Initialize a targeted state whose plan failed asking for terraform init, then plan it again")
+	flags.Bool("keep-going", false, "Keep planning when a plan fails, listing the failures in the report")
+	flags.Bool("remove-partial", false, "Remove the output directory of a failed or interrupted run instead of marking it INCOMPLETE")
+	flags.String("resume", "", "Continue an interrupted run in its output directory, reusing the plans it finished")
+	flags.Bool("force", false, "Run even though another run holds the checkout's lock, taking it over")
+	flags.Bool("incremental", false, "Reuse the cached plan of targeted states whose module and terragrunt inputs haven't changed since they last planned")
+	flags.Int("retries", 0, "Run a failed targeted plan again up to N times, with exponential backoff")
+	flags.Duration("plan-timeout", 0, "Kill and fail a targeted plan still running after this long, e.g. 10m (default: no limit)")
+	flags.Duration("lock-timeout", 0, "Wait this long for a held state lock before failing a plan on it (-lock-timeout), e.g. 5m")
+	flags.Duration("timeout", 0, "Stop the plans still running after this long for the whole run, e.g. 45m, and report those finished (default: no limit)")
+	flags.Int("slowest", 5, "Name the N slowest plans in the console summary (0: none)")
+	flags.Bool("log-file", false, "Write a debug log with every command run, its duration and stderr to debug.log in the output directory")
+	flags.Bool("no-history", false, "Don't record the run in ~/.tfprgen/history.db")
+	flags.Bool("no-credentials-check", false, "Don't check the AWS credentials of each partition before the plans start")
+	flags.Bool("lint", false, "Run tflint on the configuration of every planned state and add the findings to the report")
+	flags.Bool("no-cost", false, "Don't estimate the monthly cost change of --save-plans plans with infracost")
+	flags.Bool("include-consumers", false, "Also plan states of any module that read shared files changed on the branch (targeted runs)")
+	flags.String("select", "", "Only plan states matching an expression, e.g. 'env=production && region=us-east-*'")
+	flags.String("base", "", "Also plan the merge-base with this ref, e.g. main, and leave out region plans that are the same there, like pre-existing drift")
+	flags.StringArray("target", nil, "Resource address passed as -target to every plan (repeatable)")
+	registerPlanFlagCompletions(cmd)
+}
+
+// moduleArgs accepts exactly one module name, optionally followed by
+// "--" and arguments to forward to the plan commands.
+func moduleArgs(cmd *cobra.Command, args []string) error {
+	positional := args
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		positional = args[:dash]
+	}
+	if hook, _ := cmd.Flags().GetBool("hook"); hook && len(positional) == 0 {
+		// The pre-push hook finds the modules itself
+		return nil
+	}
+	if resume, _ := cmd.Flags().GetString("resume"); resume != "" {
+		// The run's manifest names the module
+		return cobra.MaximumNArgs(1)(cmd, positional)
+	}
+	return cobra.ExactArgs(1)(cmd, positional)
+}
+
+func runPlanGenerator(cmd *cobra.Command, args []string) {
+	if resume, _ := cmd.Flags().GetString("resume"); resume != "" {
+		resumeRun(cmd, resume, args)
+		return
+	}
+	if hook, _ := cmd.Flags().GetBool("hook"); hook && (len(args) == 0 || cmd.ArgsLenAtDash() == 0) {
+		runPrePush(cmd, args)
+		return
+	}
+	if destroy, _ := cmd.Flags().GetBool("destroy"); !destroy {
+		args[0] = promptModule(args[0], commandModuleRoots(cmd, "."))
+	}
+	var extraArgs []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		extraArgs = args[dash:]
+	}
+	if watch, _ := cmd.Flags().GetBool("watch"); watch {
+		watchModule(cmd, args[0], extraArgs)
+		return
+	}
+	pg, err := newPlanGenerator(cmd, args[0], "")
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	pg.ExtraArgs = append(pg.ExtraArgs, extraArgs...)
+
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := pg.RunContext(ctx); err != nil {
+		exitOnRunError(err)
+	}
+}
+
+// exitOnRunError reports a failed run and exits, with driftExitCode for
+// drift found by --expect-no-changes.
+func exitOnRunError(err error) {
+	errorColor.Printf("❌ Error: %v\n", err)
+	if _, ok := err.(*errDrift); ok {
+		os.Exit(driftExitCode)
+	}
+	os.Exit(1)
+}
+
+// planFlags are cmd's flags, with the plan flags it doesn't define, such as
+// those drift and serve leave out, at their defaults.
+func planFlags(cmd *cobra.Command) *pflag.FlagSet {
+	defaults := &cobra.Command{}
+	addPlanFlags(defaults)
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	flags.AddFlagSet(cmd.Flags())
+	defaults.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flags.Lookup(flag.Name) == nil {
+			flags.AddFlag(flag)
+		}
+	})
+	return flags
+}
+
+// newPlanGenerator builds a generator from the command's flags layered over
+// the config file. configPath overrides the --config flag when non-empty.
+func newPlanGenerator(cmd *cobra.Command, moduleName, configPath string) (*PlanGenerator, error) {
+	opts := flagOptions(planFlags(cmd))
+	opts.Module = moduleName
+	if configPath != "" {
+		opts.ConfigFile = configPath
+	}
+	return NewGenerator(opts)
+}
+
+// NewGenerator builds a generator from opts layered over the config file,
+// like the command does with its flags.
+func NewGenerator(opts GeneratorOptions) (*PlanGenerator, error) {
+	verbose := opts.Verbosity > 0
+	configPath := opts.ConfigFile
+	if configPath == "" {
+		configPath = FindConfigFile(".")
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Runner != "" {
+		if err := cfg.SetRunner(opts.Runner); err != nil {
+			return nil, err
+		}
+	}
+	module, err := resolveModule(cfg.moduleRoots(), opts.Module)
+	if err != nil {
+		return nil, err
+	}
+	moduleName := module.Name
+
+	if opts.Hook {
+		// The pre-push hook has to be fast and stay out of the way
+		opts.Targeted = true
+		opts.Quiet = true
+		if opts.Select == "" {
+			opts.Select = cfg.PrePush.Select
+		}
+	}
+
+	// Options given, e.g. on the command line, win over the config file.
+	if !opts.given("verbose") {
+		verbose = (cfg.Verbose || debugLogging()) && !opts.Quiet
+	}
+	if !opts.given("targeted") && !opts.Hook {
+		opts.Targeted = cfg.Targeted
+	}
+	if opts.Mode == "" && !opts.given("targeted") && !opts.Hook {
+		opts.Mode = cfg.Mode
+	}
+	if opts.Mode != "" {
+		if err := parseMode(opts.Mode); err != nil {
+			return nil, err
+		}
+		opts.Targeted = opts.Mode == modeTargeted
+	}
+	if !opts.given("parallelism") {
+		opts.Parallelism = cfg.Parallel
+	}
+	if !opts.given("collapse-for-each") {
+		opts.CollapseForEach = cfg.CollapseForEach
+	}
+	if !opts.given("merge-identical") {
+		opts.MergeIdentical = cfg.MergeIdentical
+	}
+	if !opts.given("apply-order") {
+		opts.ApplyOrder = cfg.ApplyOrder
+	}
+	if !opts.given("approval-checklist") {
+		opts.Approvals = cfg.ApprovalChecklist
+	}
+	if !opts.given("normalize") {
+		opts.Normalize = cfg.Normalize
+	}
+	if !opts.given("graph") {
+		opts.Graph = cfg.Graph
+	}
+	if !opts.given("warnings-as-errors") {
+		opts.WarningsAsErrors = cfg.WarningsAsErrors
+	}
+	if !opts.given("github-comment") {
+		opts.GitHubComment = cfg.GitHubComment
+	}
+	if !opts.given("update-pr-description") {
+		opts.PRDescription = cfg.UpdatePRDescription
+	}
+	if !opts.given("github-status") {
+		opts.GitHubStatus = cfg.CommitStatus.Enabled
+	}
+	if !opts.given("commit-artifacts") {
+		opts.CommitArtifacts = cfg.CommitArtifacts.Enabled
+	}
+	if !opts.given("release-notes") {
+		opts.ReleaseNotes = cfg.ReleaseNotes
+	}
+	if !opts.given("email") {
+		opts.Email = cfg.Email.Enabled
+	}
+	if !opts.given("upload") {
+		opts.Upload = cfg.Upload
+	}
+	if !opts.given("policy-dir") {
+		opts.PolicyDir = cfg.PolicyDir
+	}
+	if !opts.given("max-section-bytes") {
+		opts.MaxSectionBytes = cfg.MaxSectionBytes
+	}
+	if !opts.given("max-output-bytes") {
+		opts.MaxOutputBytes = cfg.MaxOutputBytes
+	}
+	if !opts.given("plain-report") {
+		opts.PlainReport = cfg.PlainReport
+	}
+	if !opts.given("archive") {
+		opts.Archive = cfg.Archive
+	}
+	if !opts.given("include-consumers") {
+		opts.IncludeConsumers = cfg.IncludeConsumers
+	}
+	if !opts.given("init") {
+		opts.Init = cfg.Init
+	}
+	if !opts.given("precheck") {
+		opts.Precheck = cfg.Precheck
+	}
+	if !opts.given("auto-init") {
+		opts.AutoInit = cfg.AutoInit
+	}
+	if !opts.given("log-file") {
+		opts.LogFile = cfg.LogFile
+	}
+	if !opts.given("retries") {
+		opts.Retries = cfg.Retries
+	}
+	if !opts.given("remove-partial") {
+		opts.RemovePartial = cfg.RemovePartial
+	}
+	if !opts.given("keep-going") {
+		opts.KeepGoing = cfg.KeepGoing
+	}
+	if !opts.given("incremental") {
+		opts.Incremental = cfg.Incremental
+	}
+	history := cfg.History
+	if opts.given("no-history") {
+		history = !opts.NoHistory
+	}
+	checkCredentials := cfg.CheckCredentials
+	if opts.given("no-credentials-check") {
+		checkCredentials = !opts.NoCredentialsCheck
+	}
+	if !opts.given("lint") {
+		opts.Lint = cfg.Lint
+	}
+	cost := cfg.Cost
+	if opts.given("no-cost") {
+		cost = !opts.NoCost
+	}
+
+	workers, autoParallel, err := parseParallel(opts.Parallelism)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Retries < 0 {
+		return nil, fmt.Errorf("--retries can't be negative")
+	}
+	if opts.DryRun && opts.Stdout {
+		return nil, fmt.Errorf("--dry-run prints the plan commands to stdout; it can't be combined with --stdout")
+	}
+	if opts.DryRun || opts.EmitScript != "" {
+		// Nothing is planned, so there's nothing to record
+		history = false
+	}
+	if opts.Record != "" && opts.Replay != "" {
+		return nil, fmt.Errorf("--record and --replay can't be combined")
+	}
+	if opts.Replay != "" {
+		// Replayed plans are old ones
+		history = false
+	}
+	if opts.Quiet && verbose {
+		return nil, fmt.Errorf("--quiet and --verbose can't be combined")
+	}
+	if opts.Quiet && opts.TUI {
+		return nil, fmt.Errorf("--quiet and --tui can't be combined")
+	}
+	if opts.Quiet {
+		quietLogging()
+	}
+	traceCommands = opts.Verbosity > 1
+	if opts.PlanTimeout < 0 {
+		return nil, fmt.Errorf("--plan-timeout can't be negative")
+	}
+	if opts.LockTimeout < 0 {
+		return nil, fmt.Errorf("--lock-timeout can't be negative")
+	}
+	if opts.Timeout < 0 {
+		return nil, fmt.Errorf("--timeout can't be negative")
+	}
+	if opts.Jira != "" && !jiraKeyRegex.MatchString(strings.ToUpper(opts.Jira)) {
+		return nil, fmt.Errorf("--jira: %q isn't a Jira issue key, like OPS-123", opts.Jira)
+	}
+	if opts.Email {
+		if err := cfg.Email.ready(); err != nil {
+			return nil, fmt.Errorf("--email: %v", err)
+		}
+	}
+	if opts.Jira != "" && cfg.Jira.site() == "" {
+		return nil, fmt.Errorf("--jira: the Jira site is unknown: set jira.url or JIRA_URL")
+	}
+	if err := validateFormats(opts.Formats, cfg); err != nil {
+		return nil, err
+	}
+	opts.VarFiles, err = resolveVarFiles(opts.VarFiles)
+	if err != nil {
+		return nil, err
+	}
+	var sel selector
+	if opts.Select != "" {
+		if sel, err = parseSelector(opts.Select); err != nil {
+			return nil, err
+		}
+	}
+	var store Storage
+	if opts.Upload != "" {
+		if store, err = parseUploadURL(opts.Upload); err != nil {
+			return nil, err
+		}
+		if opts.UploadExpires <= 0 || opts.UploadExpires > maxUploadExpiry {
+			return nil, fmt.Errorf("--upload-expires must be between 1s and %s", maxUploadExpiry)
+		}
+	}
+
+	trace, err := newTracer(cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := newMetricsPusher(cfg.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	// With --stdout and no --output the report (and any --upload) is the
+	// only product, so plan in a scratch directory rather than leaving one
+	// behind.
+	scratch := opts.OutputDir == "" && opts.Stdout
+	if opts.TUI {
+		if _, err := newTUI(); err != nil {
+			return nil, err
+		}
+	}
+	if scratch && (opts.SavePlans || len(opts.Formats) > 0) && store == nil && !opts.Archive {
+		return nil, fmt.Errorf("--save-plans and --format write files meant to be kept; pass --output, --upload or --archive along with --stdout")
+	}
+	if opts.PolicyDir != "" {
+		if opts.PolicyDir, err = resolvePolicyDir(opts.PolicyDir); err != nil {
+			return nil, err
+		}
+	}
+	if opts.given("security-scanner") {
+		cfg.Security.Scanner = opts.SecurityScanner
+		if err := cfg.Security.validate(); err != nil {
+			return nil, fmt.Errorf("--security-scanner: %v", err)
+		}
+	}
+	// Policies, plan scans and the tag policy read the JSON of the saved
+	// plans
+	if opts.PolicyDir != "" || (cfg.Security.Scanner != "" && cfg.Security.target() == "plan") || cfg.TagPolicy.enabled() {
+		if cfg.Runner.Show == "" {
+			return nil, fmt.Errorf("checking plans (--policy-dir, security scans of plans, tag_policy) needs runner.show, which isn't set for the %s runner", cfg.Runner.Name)
+		}
+		opts.SavePlans = true
+	}
+	if opts.OutputDir == "" {
+		now := time.Now()
+		if opts.Deterministic {
+			now = fixedTime()
+		}
+		opts.OutputDir, err = cfg.OutputDirName(moduleName, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if scratch {
+		tmp, err := os.MkdirTemp("", "tfprgen-")
+		if err != nil {
+			return nil, err
+		}
+		opts.OutputDir = filepath.Join(tmp, filepath.Base(opts.OutputDir))
+	}
+
+	pg := &PlanGenerator{
+		ModuleName: moduleName,
+		ModuleRoot: module.Root,
+		OutputDir:  opts.OutputDir,
+		Verbose:    verbose,
+		Targeted:   opts.Targeted,
+		AutoMode:   opts.Mode == modeAuto,
+		Formats:    opts.Formats,
+		Snapshot:   opts.Snapshot,
+		VarFiles:   opts.VarFiles,
+		Targets:    opts.Targets,
+		Select:     opts.Select,
+		Base:       opts.Base,
+		Destroy:    opts.Destroy,
+		SavePlans:  opts.SavePlans,
+		PolicyDir:  opts.PolicyDir,
+		Stdout:     opts.Stdout,
+		Quiet:      opts.Quiet,
+		Copy:       opts.Copy,
+		Open:       opts.Open,
+		DryRun:     opts.DryRun,
+		EmitScript: opts.EmitScript,
+		Config:     cfg,
+
+		CollapseForEach:  opts.CollapseForEach,
+		MergeIdentical:   opts.MergeIdentical,
+		ApplyOrder:       opts.ApplyOrder,
+		Approvals:        opts.Approvals,
+		Graph:            opts.Graph,
+		GraphDot:         opts.GraphDot,
+		WarningsAsErrors: opts.WarningsAsErrors,
+		GitHubComment:    opts.GitHubComment,
+		PRNumber:         opts.PRNumber,
+		PRDescription:    opts.PRDescription,
+		GitHubStatus:     opts.GitHubStatus,
+		Email:            opts.Email,
+		Jira:             strings.ToUpper(opts.Jira),
+		CommitArtifacts:  opts.CommitArtifacts,
+		ReleaseNotes:     opts.ReleaseNotes,
+		Upload:           opts.Upload,
+		UploadExpires:    opts.UploadExpires,
+		MaxSectionBytes:  opts.MaxSectionBytes,
+		MaxOutputBytes:   opts.MaxOutputBytes,
+		PlainReport:      opts.PlainReport,
+		Deterministic:    opts.Deterministic,
+		Normalize:        opts.Normalize,
+		Anonymize:        opts.Anonymize,
+		Archive:          opts.Archive,
+		IncludeConsumers: opts.IncludeConsumers,
+		History:          history,
+		CheckCredentials: checkCredentials,
+		Cost:             cost,
+		Lint:             opts.Lint,
+		Init:             opts.Init,
+		AutoInit:         opts.AutoInit,
+		Precheck:         opts.Precheck,
+		LogFile:          opts.LogFile,
+		ExpectNoChanges:  opts.ExpectNoChanges,
+		PlanTimeout:      opts.PlanTimeout,
+		LockTimeout:      opts.LockTimeout,
+		Retries:          opts.Retries,
+		KeepGoing:        opts.KeepGoing,
+		Force:            opts.Force,
+		RemovePartial:    opts.RemovePartial,
+		Incremental:      opts.Incremental,
+		Timeout:          opts.Timeout,
+		Slowest:          opts.Slowest,
+		TUI:              opts.TUI,
+		upload:           store,
+		selector:         sel,
+		tracer:           trace,
+		metrics:          metrics,
+		pool:             newWorkerPool(workers, autoParallel, verbose),
+		scratch:          scratch,
+	}
+	switch {
+	case opts.Record != "":
+		if pg.Executor, err = newRecordingExecutor(localExecutor{}, opts.Record, opts.OutputDir); err != nil {
+			return nil, err
+		}
+	case opts.Replay != "":
+		if pg.Executor, err = newReplayExecutor(opts.Replay, opts.OutputDir); err != nil {
+			return nil, err
+		}
+	}
+	return pg, nil
+}
+
+// resolveVarFiles makes tfvars paths absolute, since plans run from each
+// state's directory rather than the current one.
+func resolveVarFiles(paths []string) ([]string, error) {
+	var resolved []string
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("var file %s: %v", path, err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, fmt.Errorf("var file %s: %v", path, err)
+		}
+		resolved = append(resolved, abs)
+	}
+	return resolved, nil
+}
+
+// planArgs are the arguments appended to every plan command.
+func (pg *PlanGenerator) planArgs() []string {
+	var args []string
+	if pg.Destroy {
+		args = append(args, "-destroy")
+	}
+	for _, path := range pg.VarFiles {
+		args = append(args, "-var-file="+path)
+	}
+	for _, target := range pg.Targets {
+		args = append(args, "-target="+target)
+	}
+	if pg.LockTimeout > 0 {
+		args = append(args, "-lock-timeout="+pg.LockTimeout.String())
+	}
+	return append(args, pg.ExtraArgs...)
+}
+
+// Results are the plans of the run parsed per partition, nil until it
+// planned.
+func (pg *PlanGenerator) Results() []*PartitionResult {
+	return pg.results
+}
+
+// Run discovers the states to plan, runs the plans and writes every report.
+func (pg *PlanGenerator) Run() error {
+	return pg.RunContext(context.Background())
+}
+
+// RunContext is Run, interrupted when ctx is cancelled: the plans still
+// running are stopped and the report covers those finished.
+func (pg *PlanGenerator) RunContext(ctx context.Context) (runErr error) {
+	if pg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pg.Timeout)
+		defer cancel()
+	}
+	pg.ctx, pg.cancelRun = context.WithCancel(ctx)
+	defer pg.cancelRun()
+	if pg.Anonymize && pg.anonymizer == nil {
+		pg.anonymizer = newAnonymizer()
+	}
+	pg.runSpan = pg.tracer.start(nil, "run "+pg.ModuleName).set("tfprgen.module", pg.ModuleName).set("tfprgen.output_dir", pg.OutputDir)
+	defer func() {
+		pg.runSpan.set("tfprgen.targeted", len(pg.plannedStates) > 0).set("tfprgen.states", len(pg.timings)).finish(runErr)
+		if err := pg.tracer.export(); err != nil {
+			warningColor.Printf("⚠️  Couldn't export the run's trace: %v\n", err)
+		}
+	}()
+	defer func() {
+		if runErr != nil {
+			pg.comment.fail(runErr)
+			pg.description.fail(runErr)
+		}
+	}()
+
+	if pg.scratch {
+		defer func() {
+			if runErr != nil {
+				warningColor.Printf("⚠️  Run files kept in %s for debugging\n", pg.OutputDir)
+				return
+			}
+			os.RemoveAll(filepath.Dir(pg.OutputDir))
+		}()
+	}
+
+	// Runs after the hooks and the audit, which can still fail the run
+	defer func() { pg.finishOutputDir(runErr) }()
+
+	// Registered last so the run is recorded before scratch files go
+	started := time.Now()
+	if pg.History {
+		defer func() { pg.recordHistory(started, pg.results, runErr) }()
+	}
+	defer func() { pg.pushMetrics(started, runErr) }()
+	defer func() { pg.finishStatuses(runErr) }()
+	defer func() {
+		if err := pg.recordAudit(runErr); err != nil && runErr == nil {
+			runErr = err
+		}
+	}()
+	defer func() { runErr = pg.runPostRunHooks(runErr) }()
+
+	if !pg.Stdout {
+		infoColor.Printf("🚀 Generating terraform plans for module: %s\n", pg.moduleLabel())
+	}
+	if pg.Config.Path != "" && pg.Verbose {
+		fmt.Fprintf(console, "⚙️  Using config: %s\n", pg.Config.Path)
+	}
+	if !pg.Stdout && !pg.rerun && !pg.commandsOnly() {
+		fmt.Fprintf(console, "📝 Plans will be saved to: %s/\n\n", pg.OutputDir)
+	}
+
+	if pg.Destroy {
+		warningColor.Println("🔥 Destroy mode: plans show what would be torn down")
+	}
+
+	// Concurrent runs in a checkout share its terragrunt caches
+	if !pg.commandsOnly() && !pg.replaying() {
+		release, err := pg.lockCheckout()
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	// Validate module exists (workspace mode discovers it instead). A PR
+	// removing the module may already have deleted it, so destroy plans
+	// only warn.
+	if !pg.Config.Runner.Workspaces && !pg.replaying() {
+		if err := pg.validateModule(); err != nil {
+			if !pg.Destroy {
+				return err
+			}
+			warningColor.Printf("⚠️  %v\n", err)
+		}
+	}
+
+	if !pg.commandsOnly() {
+		// Create output directory
+		if _, err := os.Stat(pg.OutputDir); os.IsNotExist(err) {
+			pg.createdOutputDir = true
+		}
+		if err := os.MkdirAll(pg.OutputDir, 0755); err != nil {
+			return fmt.Errorf("creating output directory: %v", err)
+		}
+		if !pg.rerun {
+			pg.markIncomplete("is still running, or was killed")
+		}
+		if pg.LogFile {
+			closeLog, err := pg.openDebugLog()
+			if err != nil {
+				return err
+			}
+			defer func() { closeLog(runErr) }()
+		}
+	}
+
+	targeted := pg.Targeted
+	affectedPlans := pg.States
+	var err error
+
+	var autoTargeted bool
+	var autoReason string
+	if pg.AutoMode && !pg.Config.Runner.Workspaces && len(affectedPlans) == 0 {
+		autoTargeted, autoReason = pg.chooseMode()
+		targeted = autoTargeted
+		if targeted {
+			infoColor.Printf("🧭 Auto mode: targeted planning (%s)\n", autoReason)
+		} else {
+			infoColor.Printf("🧭 Auto mode: full planning (%s)\n", autoReason)
+		}
+	}
+
+	if pg.Config.Runner.Workspaces && len(affectedPlans) == 0 {
+		infoColor.Println("🔎 Discovering terraform workspaces...")
+		affectedPlans, err = pg.discoverWorkspaceStates()
+		if err != nil {
+			return err
+		}
+		if len(affectedPlans) == 0 {
+			return fmt.Errorf("no terraform workspaces found for module %s", pg.ModuleName)
+		}
+		successColor.Printf("📋 Found %d workspace states\n", len(affectedPlans))
+		targeted = true
+	} else if targeted && len(affectedPlans) == 0 {
+		infoColor.Println("🎯 Finding affected states using affected-modules.sh...")
+		var paths []string
+		paths, err = pg.findAffectedPlans()
+		affectedPlans = statesFromPaths(paths)
+		if err != nil || len(affectedPlans) == 0 {
+			if pg.Verbose {
+				warningColor.Printf("⚠️  Targeted planning failed or found no plans: %v\n", err)
+				fmt.Fprintln(console, "Falling back to plan_all method...")
+			}
+			targeted = false
+		} else {
+			successColor.Printf("📋 Found %d affected terraform states\n", len(affectedPlans))
+			if pg.Verbose {
+				for i, plan := range affectedPlans {
+					if i < 5 {
+						fmt.Fprintf(console, "  - %s\n", plan)
+					}
+				}
+				if len(affectedPlans) > 5 {
+					fmt.Fprintf(console, "  ... and %d more\n", len(affectedPlans)-5)
+				}
+			}
+			fmt.Fprintln(console)
+		}
+	}
+	if !targeted {
+		affectedPlans = nil
+	}
+	if autoReason != "" {
+		switch {
+		case targeted:
+			pg.modeReason = "targeted — " + autoReason
+		case autoTargeted:
+			pg.modeReason = "full — " + autoReason + ", but no affected states were found"
+		default:
+			pg.modeReason = "full — " + autoReason
+		}
+	}
+
+	if pg.selector != nil && len(pg.States) == 0 {
+		if !targeted {
+			// plan_all can't be narrowed down, so plan the module's
+			// matching states one by one instead.
+			paths, err := findModuleStates(pg.Config.Runner.WorkingDir, pg.ModuleName)
+			if err != nil {
+				return fmt.Errorf("listing states for --select: %v", err)
+			}
+			affectedPlans = statesFromPaths(paths)
+			targeted = true
+		}
+		before := len(affectedPlans)
+		affectedPlans = pg.selectStates(affectedPlans, pg.selector)
+		if len(affectedPlans) == 0 {
+			return fmt.Errorf("--select %q matches none of the %d state(s)", pg.Select, before)
+		}
+		successColor.Printf("🔍 --select kept %d of %d state(s)\n", len(affectedPlans), before)
+		if pg.Verbose {
+			for _, state := range affectedPlans {
+				fmt.Fprintf(console, "  - %s\n", state)
+			}
+		}
+	}
+
+	if len(pg.States) == 0 && !pg.Config.Runner.Workspaces {
+		affectedPlans = pg.checkBlastRadius(targeted, affectedPlans)
+	}
+
+	for _, state := range affectedPlans {
+		state.PlanFile = ""
+		if pg.SavePlans {
+			state.PlanFile = state.planFileName()
+		}
+	}
+	if pg.GraphDot != "" {
+		if err := pg.writeGraphDot(targeted, affectedPlans); err != nil {
+			return err
+		}
+	}
+	if pg.commandsOnly() {
+		return pg.listCommands(targeted, affectedPlans)
+	}
+	if pg.Precheck {
+		if err := pg.runPrechecks(targeted, affectedPlans); err != nil {
+			return err
+		}
+	}
+	if !pg.replaying() {
+		// Replayed plans don't reach AWS
+		if err := pg.assumeRoles(targeted, affectedPlans); err != nil {
+			return err
+		}
+		if pg.CheckCredentials {
+			if err := pg.checkCredentials(targeted, affectedPlans); err != nil {
+				return err
+			}
+		}
+	}
+	if pg.SavePlans {
+		if targeted {
+			if err := os.MkdirAll(filepath.Join(pg.OutputDir, "tfplans"), 0755); err != nil {
+				return fmt.Errorf("creating plan directory: %v", err)
+			}
+		} else if pg.PolicyDir != "" || pg.Config.Security.Scanner != "" || pg.Config.TagPolicy.enabled() {
+			warningColor.Println("⚠️  --policy-dir, security scans and tag_policy only apply to targeted runs; the plans won't be checked")
+		} else {
+			warningColor.Println("⚠️  --save-plans only applies to targeted runs; no plan files will be saved")
+		}
+	}
+
+	if err := pg.writeManifest(targeted, affectedPlans); err != nil {
+		return fmt.Errorf("writing run manifest: %v", err)
+	}
+
+	pg.hookCtx = pg.newHookContext(targeted, affectedPlans)
+	if err := pg.runHooks("pre_run", *pg.hookCtx); err != nil {
+		return err
+	}
+
+	if pg.GitHubComment {
+		if err := pg.startComment(); err != nil {
+			return err
+		}
+	}
+	if pg.PRDescription {
+		if err := pg.startDescription(); err != nil {
+			return err
+		}
+	}
+	if pg.GitHubStatus {
+		if err := pg.startStatuses(); err != nil {
+			return err
+		}
+	}
+	pg.startJira()
+	if pg.CommitArtifacts {
+		// Fail before planning rather than after
+		if _, err := currentBranch(); err != nil {
+			return fmt.Errorf("--commit-artifacts: %v", err)
+		}
+	}
+
+	if pg.Init && !targeted {
+		warningColor.Println("⚠️  --init only applies to targeted runs; plan_all initializes states itself")
+	}
+	if pg.AutoInit && !targeted {
+		warningColor.Println("⚠️  --auto-init only applies to targeted runs; plan_all initializes states itself")
+	} else if pg.AutoInit && pg.Config.Runner.Init == "" {
+		return fmt.Errorf("--auto-init: runner.init isn't set for the %s runner", pg.Config.Runner.Name)
+	}
+	if pg.Retries > 0 && !targeted {
+		warningColor.Println("⚠️  --retries only applies to targeted runs; plan_all plans a partition's states in one command")
+	}
+	if pg.Incremental && !targeted {
+		warningColor.Println("⚠️  --incremental only applies to targeted runs; plan_all plans a partition's states in one command")
+	}
+	if pg.PlanTimeout > 0 && !targeted {
+		warningColor.Println("⚠️  --plan-timeout only applies to targeted runs; plan_all plans a partition's states in one command")
+	}
+	if pg.Graph && !targeted {
+		warningColor.Println("⚠️  --graph only applies to targeted runs; plan_all doesn't list the states it plans")
+	}
+	if hooks := pg.Config.Hooks; (len(hooks.PreState) > 0 || len(hooks.PostState) > 0) && !targeted {
+		warningColor.Println("⚠️  pre_state and post_state hooks only apply to targeted runs; plan_all plans a partition's states in one command")
+	}
+	if targeted {
+		pg.plannedStates = affectedPlans
+		if pg.Init {
+			if err := pg.initStates(affectedPlans); err != nil {
+				if pg.ctx.Err() != nil {
+					return fmt.Errorf("interrupted while initializing, before any plans ran")
+				}
+				return err
+			}
+		}
+		infoColor.Println("⚡ Running targeted plans for affected states...")
+		pg.startProgress(len(affectedPlans))
+		err = pg.runTargetedPlans(affectedPlans)
+	} else {
+		for _, p := range pg.Config.Partitions {
+			infoColor.Printf("%s Running plans for %s accounts...\n", p.Icon, p.Label)
+		}
+		pg.startProgress(pg.expectedStates())
+		err = pg.runPlanAll()
+	}
+	pg.progress.stop()
+	pg.stopped = pg.ctx.Err()
+	pg.timings = pg.progress.timings()
+	pg.printSlowest()
+	if len(pg.reusedStates) > 0 {
+		infoColor.Printf("♻️  Reused the cached plans of %d unchanged state(s) (--incremental)\n", len(pg.reusedStates))
+	}
+	if len(pg.cancelledStates) > 0 {
+		sort.Strings(pg.cancelledStates)
+		warningColor.Printf("⚠️  Cancelled, so left out of the report: %s\n", strings.Join(pg.cancelledStates, ", "))
+	}
+	if len(pg.failures) > 0 {
+		errorColor.Printf("❌ %d plan(s) failed; the report lists them under Failed states\n", len(pg.failures))
+	}
+	if len(pg.interruptedStates) > 0 {
+		sort.Strings(pg.interruptedStates)
+		warningColor.Printf("⛔ Not planned (%s): %s\n", pg.stopReason(), strings.Join(pg.interruptedStates, ", "))
+		if !pg.scratch && !pg.rerun {
+			fmt.Fprintf(console, "⏯️  Plan the rest with: terraform-pr-generator --resume %s\n", pg.OutputDir)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("generating plans: %v", err)
+	}
+
+	mismatch := revisionMismatch(pg.revisions)
+	if mismatch != "" {
+		warningColor.Printf("⚠️  Plans ran against different git revisions: %s\n", mismatch)
+	}
+
+	results, err := pg.collectResults()
+	if err != nil {
+		return fmt.Errorf("parsing plans: %v", err)
+	}
+	if pg.Base != "" && pg.ctx.Err() == nil {
+		pg.compareWithBase(results)
+	}
+	pg.results = results
+	if pg.ExpectNoChanges {
+		pg.drift = findDrift(results)
+	}
+
+	if pg.skew, err = pg.findVersionSkew(); err != nil {
+		warningColor.Printf("⚠️  Can't check for version skew: %v\n", err)
+	}
+	pg.toolVersions = pg.findToolVersions()
+	pg.skew = append(pg.skew, toolSkew(pg.toolVersions)...)
+	if pg.PolicyDir != "" && len(pg.plannedStates) > 0 && pg.ctx.Err() == nil {
+		infoColor.Println("🛡️  Evaluating policies with conftest...")
+		if pg.policy, err = pg.evaluatePolicies(); err != nil {
+			return fmt.Errorf("evaluating policies: %v", err)
+		}
+		pg.printPolicyResult()
+	}
+	if pg.Config.Security.Scanner != "" && len(pg.plannedStates) > 0 && pg.ctx.Err() == nil {
+		infoColor.Printf("🔒 Scanning with %s...\n", pg.Config.Security.Scanner)
+		if pg.security, err = pg.scanSecurity(); err != nil {
+			warningColor.Printf("⚠️  Can't run the security scan: %v\n", err)
+		} else if n := len(pg.security.Findings); n > 0 {
+			warningColor.Printf("🔒 %s found %d issue(s): %s\n", pg.security.Scanner, n, severityCounts(pg.security.Findings))
+		}
+	}
+	if pg.Config.TagPolicy.enabled() && len(pg.plannedStates) > 0 && pg.ctx.Err() == nil {
+		if pg.tagPolicy, err = pg.checkTagPolicy(); err != nil {
+			warningColor.Printf("⚠️  Can't check the tag policy: %v\n", err)
+		} else if n := len(pg.tagPolicy.Violations); n > 0 {
+			warningColor.Printf("🏷️  %d new resource(s) don't meet the tag policy\n", n)
+		}
+	}
+	if pg.Lint && pg.ctx.Err() == nil {
+		if !pg.tflintInstalled() {
+			warningColor.Println("⚠️  --lint: tflint isn't installed; skipping lint")
+		} else if pg.lint, err = pg.lintStates(); err != nil {
+			warningColor.Printf("⚠️  Can't lint: %v\n", err)
+		} else if n := len(pg.lint.Findings); n > 0 {
+			warningColor.Printf("🧹 tflint found %d issue(s): %s\n", n, lintCounts(pg.lint.Findings))
+		}
+	}
+	if pg.estimatesCosts() {
+		infoColor.Println("💰 Estimating cost changes with infracost...")
+		if pg.cost, err = pg.estimateCosts(); err != nil {
+			warningColor.Printf("⚠️  Can't estimate cost changes: %v\n", err)
+		} else if pg.cost != nil {
+			infoColor.Printf("💰 Monthly cost change: %s\n", formatCostChange(pg.cost.Past, pg.cost.Diff, pg.cost.Currency))
+		}
+	}
+	for _, skew := range pg.skew {
+		warningColor.Printf("⚖️  Version skew: %s %s is pinned to %d different versions\n", skew.Kind, skew.Name, len(skew.Versions))
+	}
+	if pg.ReleaseNotes {
+		pg.moduleBumps = pg.fetchReleaseNotes()
+	}
+	if pg.upload != nil {
+		infoColor.Println("🔗 Signing artifact links...")
+		pg.artifacts, err = pg.presignArtifacts()
+		if err != nil {
+			return fmt.Errorf("uploading artifacts: %v", err)
+		}
+	}
+	pg.offloadLargeSections(results)
+
+	// Generate formatted PR markdown
+	if err := pg.generatePRMarkdown(results, mismatch); err != nil {
+		return fmt.Errorf("generating PR markdown: %v", err)
+	}
+	if err := pg.fitOutputBudget(results, mismatch); err != nil {
+		return fmt.Errorf("generating PR markdown: %v", err)
+	}
+
+	reports := map[string]string{"markdown": filepath.Join(pg.OutputDir, "pr-ready.md")}
+	for _, format := range pg.Formats {
+		path, err := pg.writeFormat(format, results)
+		if err != nil {
+			return fmt.Errorf("generating %s report: %v", format, err)
+		}
+		if path != "" {
+			boldColor.Printf("📄 %s report: %s\n", format, path)
+			reports[format] = path
+		}
+	}
+	if pg.interrupted() {
+		// Nothing past here is meant for a partial report
+		if pg.stopReason() == "timeout" {
+			return fmt.Errorf("run timed out after %s: %s covers only the plans that finished", pg.Timeout, reports["markdown"])
+		}
+		return fmt.Errorf("run interrupted: %s covers only the plans that finished", reports["markdown"])
+	}
+
+	if pg.Open {
+		path, err := pg.writePreview(reports["markdown"])
+		if err != nil {
+			return fmt.Errorf("generating HTML preview: %v", err)
+		}
+		reports["html"] = path
+	}
+
+	renderCtx := *pg.hookCtx
+	renderCtx.Reports = reports
+	if err := pg.runHooks("post_render", renderCtx); err != nil {
+		return err
+	}
+
+	if mismatch != "" {
+		return fmt.Errorf("report in %s is inconsistent (%s); regenerate it once the checkout is stable", pg.OutputDir, mismatch)
+	}
+	if warnings := allWarnings(results); pg.WarningsAsErrors && len(warnings) > 0 {
+		return fmt.Errorf("parsing produced %d warning(s) and --warnings-as-errors is set", len(warnings))
+	}
+
+	// Seal only runs that passed their checks, so apply refuses the others
+	if len(pg.failures) == 0 && pg.policyError() == nil {
+		pg.markComplete()
+		if err := pg.sealManifest(); err != nil {
+			return fmt.Errorf("sealing manifest: %v", err)
+		}
+	}
+	if pg.Archive {
+		path := pg.archivePath()
+		if err := pg.writeArchive(path); err != nil {
+			return fmt.Errorf("writing archive: %v", err)
+		}
+		boldColor.Printf("🗜️  Archive: %s\n", path)
+	}
+	if pg.upload != nil {
+		infoColor.Printf("☁️  Uploading run to %s\n", pg.upload.location(pg.OutputDir, ""))
+		if err := pg.upload.upload(pg.OutputDir); err != nil {
+			return fmt.Errorf("uploading artifacts: %v", err)
+		}
+	}
+
+	if err := pg.runHooks("pre_publish", renderCtx); err != nil {
+		return err
+	}
+	if pg.CommitArtifacts {
+		if err := pg.commitArtifacts(results, reports); err != nil {
+			return fmt.Errorf("--commit-artifacts: %v", err)
+		}
+	}
+	pg.postJiraComment(results)
+	if pg.Email {
+		if err := pg.emailReport(results, reports["markdown"]); err != nil {
+			return fmt.Errorf("--email: %v", err)
+		}
+	}
+
+	if pg.Open {
+		// A preview that doesn't open is still on disk, so this doesn't fail
+		// the run
+		if err := openBrowser(reports["html"]); err != nil {
+			warningColor.Printf("⚠️  Can't open a browser (%v); the preview is %s\n", err, reports["html"])
+		} else {
+			infoColor.Printf("🌐 Opened the preview in your browser: %s\n", reports["html"])
+		}
+	}
+	if pg.comment != nil || pg.description != nil || pg.Stdout || pg.Copy {
+		report, err := os.ReadFile(reports["markdown"])
+		if err != nil {
+			return err
+		}
+		if pg.comment != nil {
+			pg.comment.finish(string(report))
+			successColor.Printf("💬 Updated PR #%d comment\n", pg.comment.pr)
+		}
+		pg.description.finish(string(report))
+		if pg.Copy {
+			// The report is out either way, so this doesn't fail the run
+			if err := copyToClipboard(report); err != nil {
+				warningColor.Printf("⚠️  Can't copy the report to the clipboard: %v\n", err)
+			} else {
+				successColor.Println("📋 Copied pr-ready.md to the clipboard")
+			}
+		}
+		if pg.Stdout {
+			os.Stdout.Write(report)
+			return pg.outcome()
+		}
+	}
+
+	if pg.rerun {
+		return pg.outcome()
+	}
+	if pg.Quiet {
+		fmt.Println(filepath.Join(pg.OutputDir, "pr-ready.md"))
+		return pg.outcome()
+	}
+	successColor.Println("✅ Plan generation complete!")
+	boldColor.Printf("📄 PR-ready markdown: %s/pr-ready.md\n\n", pg.OutputDir)
+
+	fmt.Fprintln(console, "🚀 Quick commands:")
+	if hint := clipboardHint(filepath.Join(pg.OutputDir, "pr-ready.md")); hint != "" && !pg.Copy {
+		fmt.Fprintf(console, "  # Copy PR markdown to clipboard (or pass --copy):\n")
+		newLogColor(slog.LevelInfo, color.FgGreen).Printf("  %s\n\n", hint)
+	}
+	fmt.Fprintf(console, "  # View plans:\n")
+	for _, p := range pg.Config.Partitions {
+		newLogColor(slog.LevelInfo, color.FgCyan).Printf("  less %s/%s\n", pg.OutputDir, p.OutputFile)
+	}
+
+	return pg.outcome()
+}
+
+// outcome fails a run whose reports are out if plans failed under
+// --keep-going, violate --policy-dir policies, or on drift with
+// --expect-no-changes.
+func (pg *PlanGenerator) outcome() error {
+	if len(pg.failures) > 0 {
+		return pg.failedError()
+	}
+	if err := pg.policyError(); err != nil {
+		return err
+	}
+	return pg.checkDrift()
+}
+
+// githubClient is the run's GitHub client: the pr subcommand's, or one
+// from the environment GitHub Actions provides.
+func (pg *PlanGenerator) githubClient() (*githubClient, error) {
+	if pg.github != nil {
+		return pg.github, nil
+	}
+	return newGitHubClient()
+}
+
+// startComment posts the "plans in progress" pull request comment.
+func (pg *PlanGenerator) startComment() error {
+	client, err := pg.githubClient()
+	if err != nil {
+		return fmt.Errorf("--github-comment: %v", err)
+	}
+	pr, err := pullRequestNumber(pg.PRNumber)
+	if err != nil {
+		return fmt.Errorf("--github-comment: %v", err)
+	}
+	pg.comment, err = startCommentStream(client, pr, pg.ModuleName)
+	if err != nil {
+		return err
+	}
+	if pg.Verbose {
+		fmt.Fprintf(console, "💬 Streaming progress to PR #%d\n", pr)
+	}
+	return nil
+}
+
+// moduleDir is the module's terragrunt_<module> directory.
+func (pg *PlanGenerator) moduleDir() string {
+	return moduleRef{Root: pg.ModuleRoot, Name: pg.ModuleName}.Dir()
+}
+
+// moduleLabel names the module in the console and the report, with its
+// module root unless that's the repository root.
+func (pg *PlanGenerator) moduleLabel() string {
+	return moduleRef{Root: pg.ModuleRoot, Name: pg.ModuleName}.String()
+}
+
+func (pg *PlanGenerator) validateModule() error {
+	moduleDir := pg.moduleDir()
+	if _, err := os.Stat(moduleDir); os.IsNotExist(err) {
+		roots := pg.Config.moduleRoots()
+		where := "current directory"
+		if len(roots) > 1 {
+			where = "module_roots (" + strings.Join(roots, ", ") + ")"
+		}
+		if suggestions := suggestModules(pg.ModuleName, roots); len(suggestions) > 0 {
+			return fmt.Errorf("module %s not found in %s.\nDid you mean: %s?", moduleDir, where, strings.Join(suggestions, ", "))
+		}
+		return fmt.Errorf("module %s not found in %s.\nMake sure you're running this from the elon-modules root directory, or pass --chdir", moduleDir, where)
+	}
+	return nil
+}
+
+func (pg *PlanGenerator) findAffectedPlans() ([]string, error) {
+	if _, err := os.Stat("./affected-modules.sh"); os.IsNotExist(err) && !pg.replaying() {
+		return nil, fmt.Errorf("affected-modules.sh not found in current directory")
+	}
+
+	// The module's directory tells scripts of monorepos which root it's in
+	var output bytes.Buffer
+	env := append(os.Environ(), "TFPRGEN_MODULE_DIR="+filepath.ToSlash(pg.moduleDir()))
+	err := pg.execute(pg.ctx, &Command{Args: []string{"./affected-modules.sh", pg.ModuleName, "."}, Env: env, Stdout: &output})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run affected-modules.sh: %v", err)
+	}
+
+	var plans []string
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.Contains(line, "kitman tg plan") {
+			// Extract the path
+			parts := strings.Fields(line)
+			for i, part := range parts {
+				if part == "-w" && i+1 < len(parts) {
+					planPath := strings.Replace(parts[i+1], "/terragrunt.hcl", "", 1)
+					plans = append(plans, planPath)
+					break
+				}
+			}
+		}
+	}
+
+	return plans, nil
+}
+
+func (pg *PlanGenerator) runPlanAll() error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(pg.Config.Partitions))
+	pg.revisions = make([]*groupRevision, len(pg.Config.Partitions))
+	pg.comment.expect(len(pg.Config.Partitions), "partitions")
+
+	for i, p := range pg.Config.Partitions {
+		wg.Add(1)
+		go func(i int, p *Partition) {
+			defer wg.Done()
+			span := pg.tracer.start(pg.runSpan, "partition "+p.Name).set("tfprgen.partition", p.Name)
+			defer func() { span.finish(errs[i]) }()
+			if pg.resumedPartition(p) {
+				pg.comment.progress(1, pg.partialMarkdown)
+				return
+			}
+			if pg.Verbose {
+				fmt.Fprintf(console, "  → Running %s account plans...\n", p.Label)
+			}
+			argv, err := pg.Config.Runner.PlanAllCommand(p, pg.ModuleName, pg.planArgs())
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			rev := &groupRevision{Partition: p.Name, Start: gitHead()}
+			errs[i] = pg.runCommand(p, span, argv[0], argv[1:], filepath.Join(pg.OutputDir, p.OutputFile))
+			rev.End = gitHead()
+			pg.revisions[i] = rev
+			if errs[i] == errRunCancelled {
+				// The report covers what it planned
+				errs[i] = nil
+				return
+			}
+			if errs[i] != nil && pg.KeepGoing {
+				pg.recordFailure(p, p.Name+" plan_all", errs[i])
+				errs[i] = nil
+				return
+			}
+			if errs[i] == nil {
+				pg.comment.progress(1, pg.partialMarkdown)
+				errs[i] = pg.runPostGroupHooks(p)
+			}
+			if errs[i] == nil {
+				pg.checkpointPartition(p)
+			}
+		}(i, p)
+	}
+
+	wg.Wait()
+
+	for i, p := range pg.Config.Partitions {
+		if errs[i] != nil {
+			return fmt.Errorf("%s plans failed: %v", p.Name, errs[i])
+		}
+	}
+
+	return nil
+}
+
+func (pg *PlanGenerator) runTargetedPlans(affectedPlans []*State) error {
+	groups := make(map[*Partition][]*State)
+	for _, plan := range affectedPlans {
+		p := pg.Config.PartitionFor(plan.String())
+		if p == nil {
+			if pg.Verbose {
+				warningColor.Printf("⚠️  No partition matches %s, skipping\n", plan)
+			}
+			continue
+		}
+		groups[p] = append(groups[p], plan)
+	}
+	for _, plans := range groups {
+		pg.comment.expect(len(plans), "states")
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(pg.Config.Partitions))
+	pg.revisions = make([]*groupRevision, len(pg.Config.Partitions))
+
+	for i, p := range pg.Config.Partitions {
+		plans := groups[p]
+		if len(plans) == 0 {
+			// Create empty file
+			os.WriteFile(filepath.Join(pg.OutputDir, p.OutputFile), []byte(p.EmptyPlaceholder()), 0644)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, p *Partition, plans []*State) {
+			defer wg.Done()
+			if pg.Verbose {
+				fmt.Fprintf(console, "  → Running %d %s plans...\n", len(plans), p.Label)
+			}
+			span := pg.tracer.start(pg.runSpan, "partition "+p.Name).set("tfprgen.partition", p.Name).set("tfprgen.states", len(plans))
+			defer func() { span.finish(errs[i]) }()
+			rev := &groupRevision{Partition: p.Name, Start: gitHead()}
+			errs[i] = pg.runTargetedPlanGroup(p, span, plans)
+			rev.End = gitHead()
+			pg.revisions[i] = rev
+			if errs[i] == nil {
+				errs[i] = pg.runPostGroupHooks(p)
+			}
+		}(i, p, plans)
+	}
+
+	wg.Wait()
+
+	for i, p := range pg.Config.Partitions {
+		if errs[i] != nil {
+			return fmt.Errorf("%s plans failed: %v", p.Name, errs[i])
+		}
+	}
+
+	return nil
+}
+
+// runTargetedPlanGroup plans a partition's states, tracing each under
+// parent.
+func (pg *PlanGenerator) runTargetedPlanGroup(p *Partition, parent *span, plans []*State) error {
+	outputPath := filepath.Join(pg.OutputDir, p.OutputFile)
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Plans run concurrently (bounded by the worker pool) but are written
+	// in input order so the output file is deterministic. Each finished
+	// run of consecutive plans is flushed right away so progress updates
+	// can render it.
+	outputs := make([][]byte, len(plans))
+	errs := make([]error, len(plans))
+	finished := make([]bool, len(plans))
+	// skipped plans were cancelled or interrupted and have no output
+	skipped := make([]bool, len(plans))
+	next := 0
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	flush := func() int {
+		pg.flushMu.Lock()
+		defer pg.flushMu.Unlock()
+		flushed := 0
+		for next < len(plans) && finished[next] && errs[next] == nil {
+			if !skipped[next] {
+				fmt.Fprintln(file, plans[next].Header())
+				file.Write(outputs[next])
+				file.WriteString("\n")
+				flushed++
+			}
+			next++
+		}
+		return flushed
+	}
+
+	for _, state := range plans {
+		pg.progress.queue(state.String())
+	}
+	for i, state := range plans {
+		wg.Add(1)
+		go func(i int, state *State) {
+			defer wg.Done()
+			pool := pg.poolFor(p)
+			pool.acquire()
+			start := time.Now()
+			defer func() { pool.release(time.Since(start)) }()
+
+			name := state.String()
+			span := pg.tracer.start(parent, "plan "+name).set("tfprgen.state", name).set("tfprgen.location", pg.stateLocation(state.Path))
+			ctx, cancel := pg.stateContext()
+			defer cancel()
+			var output []byte
+			err := pg.ctx.Err()
+			status := stateCancelled
+			if err == nil {
+				if pg.Verbose {
+					fmt.Fprintf(console, "    Planning: %s\n", state)
+				}
+				pg.progress.begin(name, cancel)
+				output, err = pg.planCheckpointed(ctx, p, state)
+				status = stateSucceeded
+			}
+			planErr := err
+			skip := false
+			switch {
+			case pg.ctx.Err() != nil:
+				// Interrupted: the report covers the plans finished
+				output, err, status, skip = nil, nil, stateCancelled, true
+				pg.flushMu.Lock()
+				pg.interruptedStates = append(pg.interruptedStates, name)
+				pg.flushMu.Unlock()
+			case ctx.Err() == context.DeadlineExceeded:
+				output, err, status = nil, fmt.Errorf("timed out after %s (--plan-timeout): %v", pg.PlanTimeout, err), stateFailed
+			case ctx.Err() != nil:
+				// Cancelled on its own from the TUI: the run goes on
+				// without it
+				output, err, status, skip = nil, nil, stateCancelled, true
+				pg.flushMu.Lock()
+				pg.cancelledStates = append(pg.cancelledStates, name)
+				pg.flushMu.Unlock()
+			case err != nil:
+				status = stateFailed
+				if pg.KeepGoing {
+					pg.recordFailure(p, name, err)
+					output, err, skip = nil, nil, true
+				}
+			}
+			pg.progress.end(name, status)
+			if status != stateFailed {
+				planErr = nil
+			}
+			span.set("tfprgen.status", status).finish(planErr)
+
+			mu.Lock()
+			outputs[i], errs[i], finished[i], skipped[i] = output, err, true, skip
+			flushed := flush()
+			mu.Unlock()
+			if flushed > 0 {
+				pg.comment.progress(flushed, pg.partialMarkdown)
+			}
+		}(i, state)
+	}
+
+	wg.Wait()
+
+	for i, state := range plans {
+		if errs[i] != nil {
+			return fmt.Errorf("failed to run plan for %s: %v", state, errs[i])
+		}
+	}
+
+	return nil
+}
+
+// stateContext is the context of one targeted plan, cancelled with the run,
+// from the TUI or after --plan-timeout.
+func (pg *PlanGenerator) stateContext() (context.Context, context.CancelFunc) {
+	if pg.PlanTimeout > 0 {
+		return context.WithTimeout(pg.ctx, pg.PlanTimeout)
+	}
+	return context.WithCancel(pg.ctx)
+}
+
+// stateCommand is the command line planning one targeted state.
+func (pg *PlanGenerator) stateCommand(p *Partition, state *State) ([]string, error) {
+	args := pg.planArgs()
+	if state.PlanFile != "" {
+		// Absolute, since the plan runs from the state's directory
+		planFile, _ := filepath.Abs(filepath.Join(pg.OutputDir, state.PlanFile))
+		args = append(args, "-out="+planFile)
+	}
+	return pg.Config.Runner.PlanCommand(p, pg.ModuleName, state.Path, args)
+}
+
+// planState runs one targeted plan and returns its output. States of
+// Terraform Cloud workspaces, Spacelift stacks and env0 environments are
+// planned remotely.
+func (pg *PlanGenerator) planState(ctx context.Context, p *Partition, state *State) ([]byte, error) {
+	if output, remote, err := pg.planOnPlatform(ctx, state); remote {
+		return pg.maskPlan(state.String(), output), err
+	}
+	argv, err := pg.stateCommand(p, state)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	var masked int
+	err = pg.execute(ctx, &Command{
+		Args:      argv,
+		Env:       pg.commandEnv(state),
+		Stdout:    io.MultiWriter(&stdout, pg.progress.logWriter(state.String())),
+		Stderr:    io.MultiWriter(&stderr, pg.progress.logWriter(state.String())),
+		maskCount: &masked,
+	})
+	pg.recordMasked(state.String(), masked)
+	if err != nil {
+		return nil, pg.commandError(err, state.String(), stderr.Bytes())
+	}
+	if state.PlanFile != "" && pg.Config.Runner.Show != "" {
+		if err := pg.showPlan(ctx, p, state); err != nil {
+			warningColor.Printf("⚠️  Can't save %s as JSON: %v\n", state.PlanFile, err)
+		}
+	}
+	return stdout.Bytes(), nil
+}
+
+// showPlan writes the JSON rendering of a state's saved plan next to it,
+// for Infracost and other tools reading plans.
+func (pg *PlanGenerator) showPlan(ctx context.Context, p *Partition, state *State) error {
+	planFile, _ := filepath.Abs(filepath.Join(pg.OutputDir, state.PlanFile))
+	argv, err := pg.Config.Runner.ShowCommand(p, pg.ModuleName, state.Path, planFile)
+	if err != nil {
+		return err
+	}
+	var stdout, stderr bytes.Buffer
+	if err := pg.execute(ctx, &Command{Args: argv, Env: pg.commandEnv(state), Stdout: &stdout, Stderr: &stderr}); err != nil {
+		return pg.commandError(err, state.String()+"-show", stderr.Bytes())
+	}
+	// Counted with the state's plan already
+	plan, _ := pg.maskBytes(stdout.Bytes())
+	return os.WriteFile(filepath.Join(pg.OutputDir, state.planJSONName()), plan, 0644)
+}
+
+// runCommand streams a partition's plan output into outputFile, echoing it
+// to the console behind the partition's label in verbose mode, and traces
+// the states it plans under parent. The output
+// goes to outputFile.partial until the command succeeds, so partial
+// reports don't parse a half-written file and a failed run keeps what was
+// planned.
+func (pg *PlanGenerator) runCommand(p *Partition, parent *span, command string, args []string, outputFile string) error {
+	partial := outputFile + ".partial"
+	file, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	var masked int
+	scanner := newProgressScanner(pg.progress, p)
+	defer func() {
+		scanner.Close()
+		pg.traceScannedStates(parent, scanner)
+	}()
+	cmd := &Command{
+		Args:      append([]string{command}, args...),
+		Env:       pg.partitionEnv(p),
+		Stdout:    io.MultiWriter(file, scanner),
+		Stderr:    &stderr,
+		maskCount: &masked,
+	}
+	if pg.Verbose {
+		echo := &prefixWriter{w: console, prefix: fmt.Sprintf("    [%s] ", p.Label)}
+		defer echo.Flush()
+		cmd.Stdout = io.MultiWriter(file, scanner, echo)
+	}
+	err = pg.execute(pg.ctx, cmd)
+	pg.recordMasked(p.Name, masked)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if pg.ctx.Err() != nil {
+		// Keep the plans finished for the report, marking the states cut
+		// short
+		scanner.flush()
+		interrupted := scanner.interrupt()
+		pg.flushMu.Lock()
+		defer pg.flushMu.Unlock()
+		pg.interruptedStates = append(pg.interruptedStates, interrupted...)
+		if err := os.Rename(partial, outputFile); err != nil {
+			return err
+		}
+		return errRunCancelled
+	}
+	if err != nil {
+		if pg.KeepGoing {
+			// The report shows the states planned before the failure
+			if renameErr := pg.keepPartial(outputFile); renameErr == nil {
+				partial = outputFile
+			}
+		}
+		return pg.commandError(fmt.Errorf("command failed: %s %v - %v (output so far: %s)", command, args, err, partial), p.Name, stderr.Bytes())
+	}
+
+	pg.flushMu.Lock()
+	defer pg.flushMu.Unlock()
+	return os.Rename(partial, outputFile)
+}
@@ -0,0 +1,190 @@
+package planner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// policyResult is what conftest found evaluating the policies of
+// --policy-dir against the targeted states' plan JSON. Violations
+// (deny/violation rules) block the change; warnings (warn rules) don't.
+type policyResult struct {
+	Dir        string           `json:"dir"`
+	States     int              `json:"states"`
+	Violations []*policyFinding `json:"violations"`
+	Warnings   []*policyFinding `json:"warnings"`
+}
+
+// policyFinding is one failed rule for one state.
+type policyFinding struct {
+	State     string `json:"state"`
+	Location  string `json:"location"` // env/region, or the state's path
+	Namespace string `json:"namespace"`
+	Message   string `json:"message"`
+}
+
+// conftestResult is one file's entry in `conftest test --output json`.
+type conftestResult struct {
+	Filename  string            `json:"filename"`
+	Namespace string            `json:"namespace"`
+	Warnings  []conftestMessage `json:"warnings"`
+	Failures  []conftestMessage `json:"failures"`
+}
+
+type conftestMessage struct {
+	Msg string `json:"msg"`
+}
+
+// resolvePolicyDir makes --policy-dir absolute, checking that it's a
+// directory.
+func resolvePolicyDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("--policy-dir: %v", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("--policy-dir: %s is not a directory", dir)
+	}
+	return abs, nil
+}
+
+// evaluatePolicies runs conftest once over the plan JSON of every planned
+// state. conftest exits non-zero when a policy fails, so its output is
+// read either way; a run whose policies can't be evaluated fails rather
+// than passing unchecked.
+func (pg *PlanGenerator) evaluatePolicies() (*policyResult, error) {
+	result := &policyResult{Dir: pg.PolicyDir}
+	byFile := make(map[string]*State)
+	args := []string{"conftest", "test", "--policy", pg.PolicyDir, "--all-namespaces", "--output", "json", "--no-color"}
+	for _, state := range pg.plannedStates {
+		path, _ := filepath.Abs(filepath.Join(pg.OutputDir, state.planJSONName()))
+		if _, err := os.Stat(path); err != nil {
+			// Failed plans save nothing; they're listed as failures
+			continue
+		}
+		byFile[path] = state
+		args = append(args, path)
+	}
+	result.States = len(byFile)
+	if result.States == 0 {
+		return result, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := pg.execute(pg.ctx, &Command{Args: args, Stdout: &stdout, Stderr: &stderr})
+	var entries []conftestResult
+	if jsonErr := json.Unmarshal(stdout.Bytes(), &entries); jsonErr != nil {
+		if err != nil {
+			return nil, pg.commandError(err, "conftest", stderr.Bytes())
+		}
+		return nil, fmt.Errorf("reading conftest output: %v", jsonErr)
+	}
+
+	for _, entry := range entries {
+		state := byFile[entry.Filename]
+		if state == nil {
+			continue
+		}
+		finding := func(msg conftestMessage) *policyFinding {
+			location := pg.stateLocation(state.Path)
+			if state.Workspace != "" {
+				location = state.String()
+			}
+			return &policyFinding{State: state.String(), Location: location, Namespace: entry.Namespace, Message: msg.Msg}
+		}
+		for _, failure := range entry.Failures {
+			result.Violations = append(result.Violations, finding(failure))
+		}
+		for _, warning := range entry.Warnings {
+			result.Warnings = append(result.Warnings, finding(warning))
+		}
+	}
+	for _, findings := range [][]*policyFinding{result.Violations, result.Warnings} {
+		sort.SliceStable(findings, func(i, j int) bool { return findings[i].Location < findings[j].Location })
+	}
+	return result, nil
+}
+
+// printPolicyResult reports the policy evaluation on the console.
+func (pg *PlanGenerator) printPolicyResult() {
+	r := pg.policy
+	if r.States == 0 {
+		return
+	}
+	if len(r.Violations) == 0 {
+		successColor.Printf("✅ Plans of %d state(s) pass the policies in %s\n", r.States, r.Dir)
+	} else {
+		errorColor.Printf("🚫 %d policy violation(s):\n", len(r.Violations))
+		for _, v := range r.Violations {
+			fmt.Fprintf(console, "  %s: %s\n", v.Location, v.Message)
+		}
+	}
+	for _, w := range r.Warnings {
+		warningColor.Printf("⚠️  Policy warning for %s: %s\n", w.Location, w.Message)
+	}
+}
+
+// policyError fails a run whose plans violate policies.
+func (pg *PlanGenerator) policyError() error {
+	if pg.policy == nil || len(pg.policy.Violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d policy violation(s) in the plans (policies: %s)", len(pg.policy.Violations), pg.policy.Dir)
+}
+
+// writePolicyResult renders the policy violations, which block the change,
+// and the policy warnings.
+func (pg *PlanGenerator) writePolicyResult(output *os.File) {
+	r := pg.policy
+	if r == nil || r.States == 0 {
+		return
+	}
+	dir := pg.policyDirLabel()
+	if len(r.Violations) == 0 && len(r.Warnings) == 0 {
+		output.WriteString(fmt.Sprintf("> %sPlans pass the policies in `%s`.\n\n", pg.renderer().Icon("✅"), dir))
+		return
+	}
+	if len(r.Violations) > 0 {
+		output.WriteString("## " + pg.renderer().Icon("🚫") + "Policy violations\n\n")
+		output.WriteString(fmt.Sprintf("**Blocking:** the plans violate the policies in `%s`. Fix these before merging:\n\n", dir))
+		writePolicyFindings(output, r.Violations)
+	}
+	if len(r.Warnings) > 0 {
+		output.WriteString("## " + pg.renderer().Icon("📋") + "Policy warnings\n\n")
+		writePolicyFindings(output, r.Warnings)
+	}
+}
+
+func writePolicyFindings(output *os.File, findings []*policyFinding) {
+	for _, f := range findings {
+		output.WriteString(fmt.Sprintf("- **%s**: %s", f.Location, f.Message))
+		if f.Namespace != "" && f.Namespace != "main" {
+			output.WriteString(fmt.Sprintf(" (`%s`)", f.Namespace))
+		}
+		output.WriteString("\n")
+	}
+	output.WriteString("\n")
+}
+
+// policyDirLabel names the policy directory relative to the working
+// directory where it's below it, so reports don't show local paths.
+func (pg *PlanGenerator) policyDirLabel() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return pg.policy.Dir
+	}
+	rel, err := filepath.Rel(cwd, pg.policy.Dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return pg.policy.Dir
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,48 @@
+package planner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvaluatePolicies(t *testing.T) {
+	fake := &fakeExecutor{err: errors.New("exit status 1")}
+	pg := newTestGenerator(t, fake)
+	pg.PolicyDir = "/policies"
+	pg.plannedStates = []*State{
+		{Path: "terragrunt_vpc/organizations/staging/us-east-1"},
+		{Path: "terragrunt_vpc/organizations/production/us-east-1"},
+	}
+	var files []string
+	for _, state := range pg.plannedStates {
+		state.PlanFile = state.planFileName()
+		path, _ := filepath.Abs(filepath.Join(pg.OutputDir, state.planJSONName()))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("{}"), 0644)
+		files = append(files, path)
+	}
+	// conftest exits 1 when a policy fails, with its findings on stdout
+	fake.stdout = `[{"filename":"` + files[0] + `","namespace":"main","successes":2},
+		{"filename":"` + files[1] + `","namespace":"s3","warnings":[{"msg":"missing tags"}],"failures":[{"msg":"versioning disabled"}]}]`
+
+	result, err := pg.evaluatePolicies()
+	if err != nil {
+		t.Fatalf("evaluatePolicies: %v", err)
+	}
+	if len(fake.commands) != 1 || len(fake.commands[0]) != 10 {
+		t.Fatalf("commands = %v, want one conftest run over both plans", fake.commands)
+	}
+	if result.States != 2 || len(result.Violations) != 1 || len(result.Warnings) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	if v := result.Violations[0]; v.State != pg.plannedStates[1].Path || v.Namespace != "s3" || v.Message != "versioning disabled" {
+		t.Errorf("violation = %+v", v)
+	}
+	pg.policy = result
+	if err := pg.outcome(); err == nil || !strings.Contains(err.Error(), "1 policy violation") {
+		t.Errorf("outcome = %v, want the violation to fail the run", err)
+	}
+}
@@ -0,0 +1,177 @@
+package planner
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// planMemoryBytes is a rough estimate of what one terraform plan (terragrunt,
+// terraform and its provider plugins) needs in memory.
+const planMemoryBytes = 512 << 20
+
+// workerPool bounds how many plans run at once. In auto mode the limit is
+// re-tuned after every plan from CPU load, available memory and the observed
+// plan durations.
+type workerPool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	limit   int
+	max     int
+	active  int
+	auto    bool
+	verbose bool
+
+	baseline  time.Duration // average duration of the first few plans
+	durations []time.Duration
+
+	// ceiling is the limit before AWS rate limits first cut it, which it
+	// grows back to; 0 until then.
+	ceiling          int
+	throttledAt      time.Time
+	unthrottledPlans int
+}
+
+// addParallelismFlag registers -j/--parallelism. --parallel, its old name,
+// keeps working.
+func addParallelismFlag(flags *pflag.FlagSet) {
+	flags.StringP("parallelism", "j", "1", "Number of targeted plans to run at once, or \"auto\" to tune from system load")
+	flags.SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "parallel" {
+			name = "parallelism"
+		}
+		return pflag.NormalizedName(name)
+	})
+}
+
+// parseParallel parses the --parallelism flag value: a positive number or
+// "auto".
+func parseParallel(value string) (n int, auto bool, err error) {
+	if value == "auto" {
+		return 0, true, nil
+	}
+	n, err = strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, false, fmt.Errorf("invalid parallelism %q: must be a positive number or \"auto\"", value)
+	}
+	return n, false, nil
+}
+
+func newWorkerPool(n int, auto, verbose bool) *workerPool {
+	wp := &workerPool{limit: n, max: n, auto: auto, verbose: verbose}
+	wp.cond = sync.NewCond(&wp.mu)
+	if auto {
+		wp.max = 2 * runtime.NumCPU()
+		if avail, ok := availableMemory(); ok {
+			if byMem := int(avail / planMemoryBytes); byMem < wp.max {
+				wp.max = byMem
+			}
+		}
+		if wp.max < 1 {
+			wp.max = 1
+		}
+		wp.limit = runtime.NumCPU()
+		if wp.limit > wp.max {
+			wp.limit = wp.max
+		}
+		if verbose {
+			fmt.Fprintf(console, "  ⚙️  Auto parallelism: starting with %d workers (max %d)\n", wp.limit, wp.max)
+		}
+	}
+	return wp
+}
+
+// poolFor returns the pool bounding p's plans: its own when the partition
+// sets parallel, otherwise the one shared by every partition.
+func (pg *PlanGenerator) poolFor(p *Partition) *workerPool {
+	if p == nil || p.Parallel == "" {
+		return pg.pool
+	}
+	pg.poolsMu.Lock()
+	defer pg.poolsMu.Unlock()
+	if wp, ok := pg.partitionPools[p.Name]; ok {
+		return wp
+	}
+	if pg.partitionPools == nil {
+		pg.partitionPools = make(map[string]*workerPool)
+	}
+	n, auto, _ := parseParallel(p.Parallel) // validated with the config
+	wp := newWorkerPool(n, auto, pg.Verbose)
+	pg.partitionPools[p.Name] = wp
+	return wp
+}
+
+// acquire blocks until a worker slot is free.
+func (wp *workerPool) acquire() {
+	wp.mu.Lock()
+	for wp.active >= wp.limit {
+		wp.cond.Wait()
+	}
+	wp.active++
+	wp.mu.Unlock()
+}
+
+// release frees a worker slot and, in auto mode, re-tunes the limit using
+// the duration of the plan that just finished.
+func (wp *workerPool) release(took time.Duration) {
+	wp.mu.Lock()
+	wp.active--
+	if wp.auto {
+		wp.retune(took)
+	}
+	wp.mu.Unlock()
+	wp.cond.Broadcast()
+}
+
+// releaseUntimed frees a worker slot without counting the work towards the
+// plan durations auto mode tunes from, e.g. for init runs.
+func (wp *workerPool) releaseUntimed() {
+	wp.mu.Lock()
+	wp.active--
+	wp.mu.Unlock()
+	wp.cond.Broadcast()
+}
+
+const baselineSamples = 3
+
+func (wp *workerPool) retune(took time.Duration) {
+	wp.durations = append(wp.durations, took)
+	if len(wp.durations) == baselineSamples {
+		var total time.Duration
+		for _, d := range wp.durations {
+			total += d
+		}
+		wp.baseline = total / baselineSamples
+	}
+
+	// Plans getting much slower than the first few means we're contending
+	// for CPU, memory or API rate limits.
+	slowdown := wp.baseline > 0 && took > wp.baseline*3/2
+
+	load, haveLoad := loadPerCPU()
+	memoryTight := false
+	if avail, ok := availableMemory(); ok {
+		memoryTight = avail < planMemoryBytes
+	}
+
+	previous := wp.limit
+	switch {
+	case memoryTight || slowdown || (haveLoad && load > 1.0):
+		if wp.limit > 1 {
+			wp.limit--
+		}
+	case haveLoad && load < 0.7:
+		// A throttled pool only grows back through unthrottled
+		if wp.limit < wp.max && (wp.ceiling == 0 || wp.limit >= wp.ceiling) {
+			wp.limit++
+		}
+	}
+
+	if wp.verbose && wp.limit != previous {
+		fmt.Fprintf(console, "  ⚙️  Auto parallelism: %d → %d workers\n", previous, wp.limit)
+	}
+}
@@ -0,0 +1,251 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultPRTitle = "{{.Module}}: {{.Subject}}"
+	defaultPRBody  = `{{range .Commits}}- {{.}}
+{{end}}
+` + descriptionStart + `
+` + descriptionEnd + `
+`
+)
+
+// remoteRepoRegex takes owner/name from a GitHub remote URL:
+// git@github.com:owner/name.git, https://github.com/owner/name or
+// ssh://git@github.com/owner/name.git.
+var remoteRepoRegex = regexp.MustCompile(`[:/]([^/:]+/[^/]+?)(?:\.git)?/?$`)
+
+// PRConfig configures the pull requests the pr subcommand opens.
+type PRConfig struct {
+	// Base is the branch they merge into; the repository's default branch
+	// if empty.
+	Base string `yaml:"base"`
+	// Remote is the git remote the branch is pushed to; origin if empty.
+	Remote string `yaml:"remote"`
+	// Title and Body are text/templates with {{.Module}}, {{.Branch}},
+	// {{.Base}}, {{.Subject}} (the last commit's) and {{.Commits}} (the
+	// branch's commit subjects). The report goes between the tfprgen
+	// markers in Body, or below it.
+	Title string `yaml:"title"`
+	Body  string `yaml:"body"`
+	// Draft opens the pull requests as drafts.
+	Draft bool `yaml:"draft"`
+}
+
+func (c PRConfig) validate() error {
+	for name, text := range map[string]string{"title": c.Title, "body": c.Body} {
+		if _, err := template.New(name).Parse(text); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// prTemplateData is what the title and body templates are rendered with.
+type prTemplateData struct {
+	Module  string
+	Branch  string
+	Base    string
+	Subject string
+	Commits []string
+}
+
+func newPRCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pr <module_name> [-- plan args...]",
+		Short: "Push the branch, open its pull request and attach the plans",
+		Long: `Pushes the current branch, opens a GitHub pull request for it (or reuses
+the one already open) and generates the module's plans into its description,
+between the <!-- tfprgen:start --> and <!-- tfprgen:end --> markers.
+
+The title and body come from the pr section of the config, rendered with the
+module, the branch and its commits. GITHUB_TOKEN (or else the gh CLI's
+token) authenticates; the repository comes from GITHUB_REPOSITORY or the
+remote's URL. Every flag of the main command applies to the plans; --base
+also picks the branch the pull request merges into.
+
+Examples:
+  terraform-pr-generator pr s3_malware_protection --targeted
+  terraform-pr-generator pr s3_malware_protection --draft --base release/2.x
+  terraform-pr-generator pr s3_malware_protection --title "Rotate the KMS key"`,
+		Args:              moduleArgs,
+		ValidArgsFunction: completeModules(1),
+		Run:               runPR,
+		Annotations:       map[string]string{findsRepoRoot: "true"},
+	}
+	addPlanFlags(cmd)
+	cmd.Flags().String("title", "", "Pull request title, instead of the pr.title template")
+	cmd.Flags().Bool("draft", false, "Open the pull request as a draft")
+	return cmd
+}
+
+func runPR(cmd *cobra.Command, args []string) {
+	pg, err := newPlanGenerator(cmd, args[0], "")
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		pg.ExtraArgs = append(pg.ExtraArgs, args[dash:]...)
+	}
+	pr, err := pg.openPullRequest(cmd)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	runErr := pg.RunContext(ctx)
+	fmt.Fprintf(console, "🔗 %s\n", pr.URL)
+	if runErr != nil {
+		exitOnRunError(runErr)
+	}
+}
+
+// openPullRequest pushes the branch and opens its pull request, or finds
+// the one already open, then points the run's report at its description.
+func (pg *PlanGenerator) openPullRequest(cmd *cobra.Command) (*githubPull, error) {
+	cfg := pg.Config.PR
+	if base, _ := cmd.Flags().GetString("base"); base != "" {
+		cfg.Base = base
+	}
+	if title, _ := cmd.Flags().GetString("title"); title != "" {
+		cfg.Title = title
+	}
+	if draft, _ := cmd.Flags().GetBool("draft"); draft {
+		cfg.Draft = true
+	}
+	if cfg.Remote == "" {
+		cfg.Remote = "origin"
+	}
+
+	out, err := commandOutput(exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD"))
+	if err != nil {
+		return nil, fmt.Errorf("finding the current branch: %v", err)
+	}
+	branch := strings.TrimSpace(string(out))
+	if branch == "HEAD" {
+		return nil, fmt.Errorf("HEAD is detached; check out the branch to open a pull request for")
+	}
+	// Untracked files, e.g. the output of earlier runs, don't count
+	if out, err := commandOutput(exec.Command("git", "status", "--porcelain", "--untracked-files=no")); err == nil && len(bytes.TrimSpace(out)) > 0 {
+		return nil, fmt.Errorf("the working tree has uncommitted changes, which would be planned but not pushed; commit or stash them first")
+	}
+	client, err := repoClient(os.Getenv("GITHUB_REPOSITORY"), cfg.Remote)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Base == "" {
+		if cfg.Base, err = client.defaultBranch(); err != nil {
+			return nil, fmt.Errorf("finding the default branch: %v", err)
+		}
+	}
+	if branch == cfg.Base {
+		return nil, fmt.Errorf("%s is the base branch; create a branch for the change first", branch)
+	}
+
+	infoColor.Printf("⬆️  Pushing %s to %s\n", branch, cfg.Remote)
+	if err := runGit("push", "--set-upstream", cfg.Remote, branch); err != nil {
+		return nil, err
+	}
+
+	pr, err := client.openPullForBranch(branch)
+	if err != nil {
+		return nil, err
+	}
+	if pr != nil {
+		successColor.Printf("🔀 Using the open PR #%d: %s\n", pr.Number, pr.URL)
+	} else {
+		data := pg.branchTemplateData(branch, cfg.Remote+"/"+cfg.Base)
+		data.Base = cfg.Base
+		title, err := renderPRTemplate("title", cfg.Title, defaultPRTitle, data)
+		if err != nil {
+			return nil, err
+		}
+		body, err := renderPRTemplate("body", cfg.Body, defaultPRBody, data)
+		if err != nil {
+			return nil, err
+		}
+		if pr, err = client.createPull(title, branch, cfg.Base, body, cfg.Draft); err != nil {
+			return nil, fmt.Errorf("creating the pull request: %v", err)
+		}
+		successColor.Printf("🔀 Opened PR #%d: %s\n", pr.Number, pr.URL)
+	}
+
+	pg.github = client
+	pg.PRNumber = pr.Number
+	pg.PRDescription = true
+	return pr, nil
+}
+
+// repoClient is a GitHub client for repo (owner/name), else the repository
+// remote points to, authenticated by GITHUB_TOKEN or the gh CLI.
+func repoClient(repo, remote string) (*githubClient, error) {
+	client := newGitHubAPI()
+	client.repo = repo
+	if client.repo == "" {
+		out, err := commandOutput(exec.Command("git", "remote", "get-url", remote))
+		if err != nil {
+			return nil, fmt.Errorf("git remote get-url %s failed: %v", remote, err)
+		}
+		m := remoteRepoRegex.FindStringSubmatch(strings.TrimSpace(string(out)))
+		if m == nil {
+			return nil, fmt.Errorf("can't tell the GitHub repository from %s's URL %s; set GITHUB_REPOSITORY", remote, strings.TrimSpace(string(out)))
+		}
+		client.repo = m[1]
+	}
+	if client.token == "" {
+		if out, err := commandOutput(exec.Command("gh", "auth", "token")); err == nil {
+			client.token = strings.TrimSpace(string(out))
+		}
+	}
+	if client.token == "" {
+		return nil, fmt.Errorf("GITHUB_TOKEN is not set and gh isn't logged in")
+	}
+	return client, nil
+}
+
+// branchTemplateData describes the branch: its last commit's subject and
+// the subjects of its commits since it forked from base, oldest first.
+func (pg *PlanGenerator) branchTemplateData(branch, base string) *prTemplateData {
+	data := &prTemplateData{Module: pg.ModuleName, Branch: branch}
+	if out, err := commandOutput(exec.Command("git", "log", "-1", "--format=%s")); err == nil {
+		data.Subject = strings.TrimSpace(string(out))
+	}
+	if fork, err := forkPoint(base); err == nil {
+		if out, err := commandOutput(exec.Command("git", "log", "--reverse", "--format=%s", fork+"..HEAD")); err == nil {
+			data.Commits = strings.Split(strings.TrimSpace(string(out)), "\n")
+		}
+	}
+	if len(data.Commits) == 0 && data.Subject != "" {
+		data.Commits = []string{data.Subject}
+	}
+	return data
+}
+
+func renderPRTemplate(name, text, fallback string, data *prTemplateData) (string, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("pr.%s: %v", name, err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("pr.%s: %v", name, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
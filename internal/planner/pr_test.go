@@ -0,0 +1,21 @@
+package planner
+
+import "testing"
+
+func TestPRTemplates(t *testing.T) {
+	for _, remote := range []string{"git@github.com:acme/infra.git", "https://github.com/acme/infra", "ssh://git@github.com/acme/infra.git"} {
+		if m := remoteRepoRegex.FindStringSubmatch(remote); m == nil || m[1] != "acme/infra" {
+			t.Errorf("%s: got %v, want acme/infra", remote, m)
+		}
+	}
+
+	data := &prTemplateData{Module: "s3mod", Subject: "Add a bucket", Commits: []string{"Add a module", "Add a bucket"}}
+	title, err := renderPRTemplate("title", "", defaultPRTitle, data)
+	if err != nil || title != "s3mod: Add a bucket" {
+		t.Errorf("title: got %q, %v", title, err)
+	}
+	body, err := renderPRTemplate("body", "", defaultPRBody, data)
+	if want := "- Add a module\n- Add a bucket\n\n" + descriptionStart + "\n" + descriptionEnd; err != nil || body != want {
+		t.Errorf("body: got %q, %v, want %q", body, err, want)
+	}
+}
@@ -0,0 +1,124 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// precheck is one fast check --precheck runs before planning.
+type precheck struct {
+	name string // errors/precheck-<name>.log
+	dir  string
+	args [][]string // run in order, stopping at the first failure
+	env  []string
+}
+
+// runPrechecks runs terraform fmt -check and terraform validate on the
+// module (the planned state directories for workspace runners) and
+// terragrunt hclfmt on the targeted states, so formatting and syntax
+// errors fail the run in seconds instead of after every plan. Every check
+// runs, so one run shows all of their diagnostics.
+func (pg *PlanGenerator) runPrechecks(targeted bool, states []*State) error {
+	checks, cleanup, err := pg.prechecks(targeted, states)
+	defer cleanup()
+	if err != nil {
+		return fmt.Errorf("--precheck: %v", err)
+	}
+	if len(checks) == 0 {
+		warningColor.Println("⚠️  --precheck found nothing to check")
+		return nil
+	}
+
+	infoColor.Printf("🔎 Running %d precheck(s) before planning...\n", len(checks))
+	var failed []string
+	for _, check := range checks {
+		if pg.ctx.Err() != nil {
+			return pg.ctx.Err()
+		}
+		for _, argv := range check.args {
+			var output bytes.Buffer
+			err := pg.execute(pg.ctx, &Command{Args: argv, Dir: check.dir, Env: check.env, Stdout: &output, Stderr: &output})
+			if err != nil {
+				err = pg.commandError(err, "precheck-"+check.name, output.Bytes())
+				errorColor.Printf("❌ %s failed in %s: %v\n", strings.Join(argv[:2], " "), check.dir, err)
+				failed = append(failed, fmt.Sprintf("%s (%s)", strings.Join(argv[:2], " "), check.dir))
+				break
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("--precheck failed, no plans ran: %s", strings.Join(failed, ", "))
+	}
+	successColor.Println("✅ Prechecks passed")
+	return nil
+}
+
+// prechecks lists the checks to run. cleanup removes what validating
+// leaves behind: its data directory, and a lock file init created.
+func (pg *PlanGenerator) prechecks(targeted bool, states []*State) (checks []*precheck, cleanup func(), err error) {
+	var dataDirs, createdLocks []string
+	cleanup = func() {
+		for _, path := range append(dataDirs, createdLocks...) {
+			os.RemoveAll(path)
+		}
+	}
+
+	var tfDirs []string
+	if pg.Config.Runner.Workspaces {
+		tfDirs = stateDirs(states)
+	} else if _, err := os.Stat(pg.moduleDir()); err == nil {
+		tfDirs = []string{pg.moduleDir()}
+	}
+	terraform := pg.terraformBinary()
+	for _, dir := range tfDirs {
+		id := strings.ReplaceAll(filepath.ToSlash(filepath.Clean(dir)), "/", "_")
+		checks = append(checks, &precheck{name: "fmt-" + id, dir: dir, args: [][]string{{terraform, "fmt", "-check", "-recursive", "-diff"}}})
+
+		// Validating needs init; a separate data directory without a
+		// backend leaves the state's own .terraform alone
+		dataDir, err := os.MkdirTemp("", "tfprgen-precheck-")
+		if err != nil {
+			return nil, cleanup, err
+		}
+		dataDirs = append(dataDirs, dataDir)
+		lock := filepath.Join(dir, ".terraform.lock.hcl")
+		if _, err := os.Stat(lock); os.IsNotExist(err) {
+			createdLocks = append(createdLocks, lock)
+		}
+		checks = append(checks, &precheck{
+			name: "validate-" + id,
+			dir:  dir,
+			args: [][]string{
+				{terraform, "init", "-backend=false", "-input=false", "-no-color"},
+				{terraform, "validate", "-no-color"},
+			},
+			env: append(inheritedEnv(false), "TF_DATA_DIR="+dataDir),
+		})
+	}
+
+	if !pg.Config.Runner.Workspaces && targeted {
+		for _, dir := range stateDirs(states) {
+			id := strings.ReplaceAll(filepath.ToSlash(filepath.Clean(dir)), "/", "_")
+			checks = append(checks, &precheck{name: "hclfmt-" + id, dir: dir, args: [][]string{{"terragrunt", "hclfmt", "--terragrunt-check"}}})
+		}
+	}
+	return checks, cleanup, nil
+}
+
+// stateDirs lists the directories of states once, sorted.
+func stateDirs(states []*State) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, state := range states {
+		if !seen[state.Path] {
+			seen[state.Path] = true
+			dirs = append(dirs, state.Path)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -0,0 +1,238 @@
+package planner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+// prePushMarker identifies the pre-push hook `hook install` writes, so it
+// only ever replaces or removes its own.
+const prePushMarker = "# Installed by terraform-pr-generator hook install"
+
+// zeroSHA is the object name git passes a pre-push hook for a ref that
+// doesn't exist on one side.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// PrePushConfig configures --hook, the mode the pre-push hook runs in.
+type PrePushConfig struct {
+	// Select narrows the states a pushed module is planned in, a --select
+	// expression; env=staging by default.
+	Select string `yaml:"select"`
+}
+
+func newHookCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hook",
+		Short: "Install a git pre-push hook planning the modules a push changes",
+	}
+	install := &cobra.Command{
+		Use:   "install",
+		Short: "Install the pre-push hook in the current repository",
+		Long: `Writes a git pre-push hook that runs the generator in --hook mode: the
+modules changed by the commits being pushed are planned in their staging
+states (pre_push.select in the config), quietly, and the push is refused if
+a plan fails. git push --no-verify skips it.
+
+The hook goes where git looks for hooks, honouring core.hooksPath. An
+existing pre-push hook that isn't the generator's is left alone unless
+--force is given.
+
+Examples:
+  terraform-pr-generator hook install
+  terraform-pr-generator hook install --force`,
+		Args: cobra.NoArgs,
+		Run:  runHookInstall,
+	}
+	install.Flags().Bool("force", false, "Replace an existing pre-push hook")
+	uninstall := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the pre-push hook hook install wrote",
+		Args:  cobra.NoArgs,
+		Run:   runHookUninstall,
+	}
+	cmd.AddCommand(install, uninstall)
+	return cmd
+}
+
+// prePushPath is where git runs the repository's pre-push hook from.
+func prePushPath() (string, error) {
+	out, err := commandOutput(exec.Command("git", "rev-parse", "--git-path", "hooks/pre-push"))
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %v", err)
+	}
+	return filepath.Abs(strings.TrimSpace(string(out)))
+}
+
+func runHookInstall(cmd *cobra.Command, args []string) {
+	force, _ := cmd.Flags().GetBool("force")
+	path, err := prePushPath()
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if data, err := os.ReadFile(path); err == nil && !strings.Contains(string(data), prePushMarker) && !force {
+		errorColor.Printf("❌ Error: %s already exists; pass --force to replace it\n", path)
+		os.Exit(1)
+	}
+	// The absolute path keeps the hook working for git clients that don't
+	// share the shell's PATH
+	binary, err := os.Executable()
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+%s: plans the modules this
+# push changes in their staging states and refuses the push if a plan fails.
+# Skip it with git push --no-verify.
+exec %s --hook
+`, prePushMarker, shellQuote(binary))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	successColor.Printf("✅ Installed the pre-push hook in %s\n", path)
+}
+
+func runHookUninstall(cmd *cobra.Command, args []string) {
+	path, err := prePushPath()
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Fprintln(console, "No pre-push hook is installed")
+		return
+	}
+	if err != nil || !strings.Contains(string(data), prePushMarker) {
+		errorColor.Printf("❌ Error: %s isn't the generator's hook; leaving it alone\n", path)
+		os.Exit(1)
+	}
+	if err := os.Remove(path); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	successColor.Printf("✅ Removed the pre-push hook from %s\n", path)
+}
+
+// runPrePush is --hook without a module, as the pre-push hook runs it: the
+// modules changed by the pushed commits (read from the refs git passes on
+// stdin, else the branch's changes) are planned in turn, and any failure
+// refuses the push.
+func runPrePush(cmd *cobra.Command, extraArgs []string) {
+	files, err := pushedFiles(cmd)
+	if err != nil {
+		errorColor.Printf("❌ Error: finding the pushed changes: %v\n", err)
+		os.Exit(1)
+	}
+	modules := changedModules(files, commandModuleRoots(cmd, "."))
+	if len(modules) == 0 {
+		return
+	}
+	fmt.Fprintf(console, "🪝 Planning %s before the push (git push --no-verify skips this)...\n", strings.Join(modules, ", "))
+
+	ctx, stop := interruptContext()
+	defer stop()
+	var failed []string
+	for _, module := range modules {
+		pg, err := newPlanGenerator(cmd, module, "")
+		if err != nil {
+			errorColor.Printf("❌ %s: %v\n", module, err)
+			failed = append(failed, module)
+			continue
+		}
+		pg.ExtraArgs = append(pg.ExtraArgs, extraArgs...)
+		if err := pg.RunContext(ctx); err != nil {
+			errorColor.Printf("❌ %s: %v\n", module, err)
+			failed = append(failed, module)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(failed) > 0 || ctx.Err() != nil {
+		errorColor.Printf("❌ Push refused: %s didn't plan cleanly\n", strings.Join(failed, ", "))
+		os.Exit(1)
+	}
+}
+
+// pushedFiles lists the files the commits being pushed change. git gives a
+// pre-push hook one "<local ref> <local sha> <remote ref> <remote sha>"
+// line per ref; a new branch is compared with auto_mode.base. Run from a
+// terminal, it's the branch's changes since it forked from base.
+func pushedFiles(cmd *cobra.Command) ([]string, error) {
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = FindConfigFile(".")
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	base := cfg.AutoMode.Base
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		return changedFiles(base)
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 || fields[1] == zeroSHA {
+			// Deleting a ref pushes no changes
+			continue
+		}
+		from := fields[3]
+		if from == zeroSHA {
+			out, err := commandOutput(exec.Command("git", "merge-base", base, fields[1]))
+			if err != nil {
+				return nil, fmt.Errorf("git merge-base %s %s failed: %v", base, fields[1], err)
+			}
+			from = strings.TrimSpace(string(out))
+		}
+		out, err := commandOutput(exec.Command("git", "diff", "--name-only", from, fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("git diff failed: %v", err)
+		}
+		files = append(files, strings.Fields(string(out))...)
+	}
+	return files, scanner.Err()
+}
+
+// changedModules lists the modules whose terragrunt_<module> directory, in
+// any of roots, holds one of files and still exists. Modules outside the
+// repository root are named with their root, e.g. platform/vpc.
+func changedModules(files []string, roots []string) []string {
+	var modules []string
+	for _, file := range files {
+		for _, root := range roots {
+			rel, ok := file, true
+			if root != "." {
+				rel, ok = strings.CutPrefix(file, filepath.ToSlash(root)+"/")
+			}
+			dir, _, found := strings.Cut(rel, "/")
+			if !ok || !found || !strings.HasPrefix(dir, modulePrefix) || len(dir) == len(modulePrefix) {
+				continue
+			}
+			module := moduleRef{Root: root, Name: strings.TrimPrefix(dir, modulePrefix)}
+			if info, err := os.Stat(module.Dir()); err == nil && info.IsDir() && !contains(modules, module.String()) {
+				modules = append(modules, module.String())
+			}
+		}
+	}
+	sort.Strings(modules)
+	return modules
+}
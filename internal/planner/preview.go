@@ -0,0 +1,59 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/backendken/terraform-pr-generator/pkg/render"
+)
+
+// previewFile is the HTML rendering of pr-ready.md written for --open.
+const previewFile = "pr-ready.html"
+
+// writePreview renders the markdown report as HTML and returns its path.
+// Runs without an output directory to keep (--stdout) write it to a
+// temporary file instead, so the browser still finds it once they're done.
+func (pg *PlanGenerator) writePreview(markdownPath string) (string, error) {
+	markdown, err := os.ReadFile(markdownPath)
+	if err != nil {
+		return "", err
+	}
+	page := []byte(render.HTML("Terraform plan: "+pg.ModuleName, string(markdown)))
+	if pg.scratch {
+		file, err := os.CreateTemp("", "pr-ready-*.html")
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		_, err = file.Write(page)
+		return file.Name(), err
+	}
+	path := filepath.Join(pg.OutputDir, previewFile)
+	return path, os.WriteFile(path, page, 0644)
+}
+
+// openBrowser opens a local file in the default browser without waiting
+// for it.
+func openBrowser(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", abs)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", abs)
+	default:
+		cmd = exec.Command("xdg-open", abs)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s: %v", cmd.Args[0], err)
+	}
+	go cmd.Wait()
+	return nil
+}
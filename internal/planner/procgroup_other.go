@@ -0,0 +1,14 @@
+//go:build !unix
+
+package planner
+
+import "os/exec"
+
+// setProcessGroup is only implemented on Unix; elsewhere a cancelled
+// command's process is killed, leaving its children to exit on their own.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// processAlive can't tell elsewhere, so every process is taken to run.
+func processAlive(pid int) bool {
+	return true
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package planner
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup starts cmd in a new process group and makes cancelling
+// it interrupt the group, as Ctrl-C would, then kill it after
+// interruptGrace.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		group := -cmd.Process.Pid
+		if err := syscall.Kill(group, syscall.SIGINT); err != nil {
+			return err
+		}
+		time.AfterFunc(interruptGrace, func() { syscall.Kill(group, syscall.SIGKILL) })
+		return nil
+	}
+	// Don't wait on pipes a lingering grandchild holds open
+	cmd.WaitDelay = interruptGrace + time.Second
+}
+
+// processAlive reports whether a process with this PID runs.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
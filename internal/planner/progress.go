@@ -0,0 +1,472 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// progressRedraw is how often a terminal's status line is redrawn.
+	progressRedraw = time.Second
+	// progressLogInterval is how often a status line is printed elsewhere,
+	// e.g. to CI logs.
+	progressLogInterval = 30 * time.Second
+	// progressMaxRunning is how many running states the status line names.
+	progressMaxRunning = 3
+	// progressLogLines is how much of each state's output --tui keeps.
+	progressLogLines = 2000
+)
+
+// State statuses, as progress tracks them.
+const (
+	stateQueued    = "queued"
+	stateRunning   = "running"
+	stateSucceeded = "succeeded"
+	stateFailed    = "failed"
+	stateCancelled = "cancelled"
+)
+
+// stateProgress is how far one state is.
+type stateProgress struct {
+	Name   string
+	Status string
+	Began  time.Time
+	Took   time.Duration
+	// Log is the state's latest output, kept for --tui.
+	Log    []string
+	cancel func()
+}
+
+// progress tracks the states of a run as they're planned and reports how
+// far along it is: a status line redrawn in place on a terminal (unless
+// verbose output scrolls by anyway), otherwise a line every 30 seconds, or
+// the --tui screen. A nil progress does nothing.
+type progress struct {
+	mu sync.Mutex
+	// total is the number of states expected, 0 when unknown.
+	total   int
+	states  []*stateProgress
+	byName  map[string]*stateProgress
+	started time.Time
+	module  string
+	live    bool
+	// tui shows the interactive screen instead of the status line, and
+	// keeps every state's output for it. cancelRun cancels the whole run.
+	tui       *tuiProgram
+	cancelRun func()
+	// timeless leaves the elapsed time and estimate out (--deterministic).
+	timeless bool
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newProgress(total int, module string, verbose bool) *progress {
+	return &progress{
+		total:   total,
+		byName:  make(map[string]*stateProgress),
+		started: time.Now(),
+		module:  module,
+		live:    consoleTTY && !verbose,
+		quit:    make(chan struct{}),
+	}
+}
+
+// startProgress starts reporting on the total states about to be planned.
+func (pg *PlanGenerator) startProgress(total int) {
+	pg.progress = newProgress(total, pg.ModuleName, pg.Verbose)
+	pg.progress.cancelRun = pg.cancelRun
+	pg.progress.timeless = pg.Deterministic
+	if pg.TUI {
+		// Checked when the flags were read
+		pg.progress.tui, _ = newTUI()
+	}
+	pg.progress.start()
+}
+
+// start reports progress until stop is called.
+func (p *progress) start() {
+	if p == nil {
+		return
+	}
+	if p.tui != nil {
+		p.tui.start(p)
+		return
+	}
+	interval := progressLogInterval
+	if p.live {
+		interval = progressRedraw
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.mu.Lock()
+				line, running := p.status(), p.count(stateRunning)
+				p.mu.Unlock()
+				if p.live {
+					fmt.Fprintf(console, "\r\033[K%s", line)
+				} else if running > 0 {
+					fmt.Fprintln(console, line)
+				}
+			case <-p.quit:
+				if p.live {
+					fmt.Fprint(console, "\r\033[K")
+				}
+				return
+			}
+		}
+	}()
+}
+
+// stop ends reporting, clearing the status line or closing the TUI.
+func (p *progress) stop() {
+	if p == nil {
+		return
+	}
+	if p.tui != nil {
+		p.tui.stop()
+		return
+	}
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// state returns the named state, adding it with status if it's new.
+func (p *progress) state(name, status string) *stateProgress {
+	st, ok := p.byName[name]
+	if !ok {
+		st = &stateProgress{Name: name, Status: status}
+		p.byName[name] = st
+		p.states = append(p.states, st)
+	}
+	return st
+}
+
+// queue lists a state before it runs.
+func (p *progress) queue(name string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state(name, stateQueued)
+}
+
+// begin marks a state as running. cancel, if not nil, cancels it from the
+// TUI.
+func (p *progress) begin(name string, cancel func()) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.state(name, stateQueued)
+	if st.Status == stateQueued {
+		st.Status, st.Began, st.cancel = stateRunning, time.Now(), cancel
+	}
+}
+
+// end marks a running state as finished with status.
+func (p *progress) end(name, status string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st, ok := p.byName[name]
+	if !ok || (st.Status != stateRunning && st.Status != stateQueued) {
+		return
+	}
+	if st.Status == stateRunning {
+		st.Took = time.Since(st.Began)
+	}
+	st.Status, st.cancel = status, nil
+}
+
+// cancel cancels a running state, telling whether it could be.
+func (p *progress) cancel(name string) bool {
+	p.mu.Lock()
+	st, ok := p.byName[name]
+	var cancel func()
+	if ok && st.Status == stateRunning {
+		cancel = st.cancel
+	}
+	p.mu.Unlock()
+	if cancel == nil {
+		return false
+	}
+	cancel()
+	return true
+}
+
+// log keeps a line of a state's output for the TUI.
+func (p *progress) log(name, line string) {
+	if p == nil || p.tui == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.state(name, stateQueued)
+	st.Log = append(st.Log, line)
+	if len(st.Log) > progressLogLines {
+		st.Log = st.Log[len(st.Log)-progressLogLines:]
+	}
+}
+
+// logWriter is an io.Writer logging a state's output line by line.
+func (p *progress) logWriter(name string) io.Writer {
+	if p == nil || p.tui == nil {
+		return io.Discard
+	}
+	return &lineWriter{line: func(line string) { p.log(name, line) }}
+}
+
+// snapshot copies the states for rendering.
+func (p *progress) snapshot() []stateProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	states := make([]stateProgress, len(p.states))
+	for i, st := range p.states {
+		states[i] = *st
+		states[i].Log = append([]string(nil), st.Log...)
+	}
+	return states
+}
+
+func (p *progress) count(status string) int {
+	n := 0
+	for _, st := range p.states {
+		if st.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// status renders the status line, e.g. "⏳ 12/25 states planned, 1 failed,
+// 3 running (staging/us-east-1, ...) — 4m10s elapsed, about 3m left".
+func (p *progress) status() string {
+	var running []string
+	var spent time.Duration
+	finished, done, failed := 0, p.count(stateSucceeded), p.count(stateFailed)
+	for _, st := range p.states {
+		switch st.Status {
+		case stateRunning:
+			running = append(running, p.shortName(st.Name))
+		case stateSucceeded, stateFailed:
+			finished++
+			spent += st.Took
+		}
+	}
+	total := p.total
+	if total < len(p.states) {
+		// The full run estimate turned out short
+		total = len(p.states)
+	}
+	total -= p.count(stateCancelled)
+
+	var b strings.Builder
+	if total > 0 {
+		fmt.Fprintf(&b, "⏳ %d/%d states planned", done, total)
+	} else {
+		fmt.Fprintf(&b, "⏳ %d states planned", done)
+	}
+	if failed > 0 {
+		fmt.Fprintf(&b, ", %d failed", failed)
+	}
+	if len(running) > 0 {
+		sort.Strings(running)
+		names := running
+		if len(names) > progressMaxRunning {
+			names = append(names[:progressMaxRunning:progressMaxRunning], fmt.Sprintf("+%d more", len(names)-progressMaxRunning))
+		}
+		fmt.Fprintf(&b, ", %d running (%s)", len(running), strings.Join(names, ", "))
+	}
+	if p.timeless {
+		return b.String()
+	}
+	elapsed := time.Since(p.started)
+	fmt.Fprintf(&b, " — %s elapsed", elapsed.Round(time.Second))
+	if remaining := total - finished; total > 0 && finished > 0 && remaining > 0 {
+		// Finished states' average duration, spread over the states
+		// running at once
+		workers := len(running)
+		if workers == 0 {
+			workers = 1
+		}
+		eta := spent / time.Duration(finished) * time.Duration(remaining) / time.Duration(workers)
+		fmt.Fprintf(&b, ", about %s left", eta.Round(time.Second))
+	}
+	return b.String()
+}
+
+// shortName shortens a state path to the segments before the module, e.g.
+// "staging/us-east-1".
+func (p *progress) shortName(state string) string {
+	path, workspace, _ := strings.Cut(state, "@")
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), "/"+p.module)
+	segments := strings.Split(path, "/")
+	if len(segments) > 2 {
+		segments = segments[len(segments)-2:]
+	}
+	name := strings.Join(segments, "/")
+	if workspace != "" {
+		name += "@" + workspace
+	}
+	return name
+}
+
+// progressScanner follows a partition's plan_all output to tell progress
+// which states run and finish. States are told apart by their
+// "[state path]" line prefix (terragrunt's interleaved output) or else by
+// the environment and region their lines mention between plans.
+type progressScanner struct {
+	lineWriter
+	progress  *progress
+	partition *Partition
+	// current is the unprefixed state being planned, inBody is set inside
+	// its plan, where resource attributes may mention other regions.
+	current string
+	inBody  bool
+	// open are the states begun and not finished yet, with whether their
+	// output showed an error; closed those finished, whose later mentions
+	// don't start them again.
+	open   map[string]bool
+	closed map[string]bool
+}
+
+func newProgressScanner(p *progress, partition *Partition) *progressScanner {
+	s := &progressScanner{progress: p, partition: partition, open: make(map[string]bool), closed: make(map[string]bool)}
+	s.lineWriter.line = s.scan
+	return s
+}
+
+func (s *progressScanner) scan(line string) {
+	state := s.current
+	if m := modulePrefixRegex.FindStringSubmatch(line); m != nil {
+		state, line = m[1], m[2]
+		s.begin(state)
+	} else if !s.inBody {
+		env, envOK := s.partition.MatchEnv(line)
+		region, regionOK := s.partition.MatchRegion(line)
+		if next := env + "/" + region; envOK && regionOK && next != s.current && !s.closed[next] {
+			s.finish(s.current)
+			s.current, state = next, next
+			s.begin(state)
+		}
+	}
+	if state == "" {
+		return
+	}
+	s.progress.log(state, line)
+
+	switch {
+	case strings.Contains(line, "Terraform will perform the following actions"):
+		s.inBody = state == s.current
+	case errorLineRegex.MatchString(line):
+		if _, ok := s.open[state]; ok {
+			s.open[state] = true
+		}
+	case strings.HasPrefix(strings.TrimSpace(line), "Plan: "), strings.Contains(line, "No changes."):
+		s.finish(state)
+	}
+}
+
+func (s *progressScanner) begin(state string) {
+	if _, ok := s.open[state]; !ok && !s.closed[state] {
+		s.open[state] = false
+		s.progress.begin(state, nil)
+	}
+}
+
+// finish ends a state, if it's running.
+func (s *progressScanner) finish(state string) {
+	if state == s.current {
+		s.current, s.inBody = "", false
+	}
+	errored, ok := s.open[state]
+	if !ok {
+		return
+	}
+	delete(s.open, state)
+	s.closed[state] = true
+	status := stateSucceeded
+	if errored {
+		status = stateFailed
+	}
+	s.progress.end(state, status)
+}
+
+// interrupt ends the states still open as cancelled, returning them.
+func (s *progressScanner) interrupt() []string {
+	var states []string
+	for state := range s.open {
+		delete(s.open, state)
+		s.closed[state] = true
+		s.progress.end(state, stateCancelled)
+		states = append(states, state)
+	}
+	return states
+}
+
+// Close ends the states still open when the output ends.
+func (s *progressScanner) Close() {
+	s.flush()
+	for state := range s.open {
+		s.finish(state)
+	}
+}
+
+// expectedStates estimates how many states a full run plans: the module's
+// state directories that belong to a partition. It's 0, unknown, when they
+// can't be listed.
+func (pg *PlanGenerator) expectedStates() int {
+	paths, err := findModuleStates(pg.Config.Runner.WorkingDir, pg.ModuleName)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, path := range paths {
+		if pg.Config.PartitionFor(path) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// lineWriter calls line for every line written to it.
+type lineWriter struct {
+	line    func(string)
+	pending []byte
+}
+
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.pending = append(w.pending, data...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(string(w.pending[:i]))
+		w.pending = w.pending[i+1:]
+	}
+	return len(data), nil
+}
+
+// flush passes on a last unterminated line.
+func (w *lineWriter) flush() {
+	if len(w.pending) > 0 {
+		w.line(string(w.pending))
+		w.pending = nil
+	}
+}
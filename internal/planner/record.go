@@ -0,0 +1,214 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fixturesIndex lists the commands of a fixtures directory, next to a
+// <n>.stdout and <n>.stderr file per command.
+const fixturesIndex = "commands.json"
+
+// recordedCommand is a command of a recorded run. Paths below the
+// repository root and the output directory are recorded relative to them
+// ({root} and {output}), so fixtures replay from other checkouts and into
+// other output directories. The environment isn't recorded: it may hold
+// credentials.
+type recordedCommand struct {
+	Args     []string `json:"args"`
+	Dir      string   `json:"dir,omitempty"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr"`
+	ExitCode int      `json:"exit_code,omitempty"`
+	// Error is why a command couldn't run at all, e.g. a missing binary.
+	Error string `json:"error,omitempty"`
+}
+
+// pathPlaceholders rewrites the absolute paths of a run's command lines to
+// the placeholders fixtures record them with.
+type pathPlaceholders struct {
+	root, output string
+}
+
+func newPathPlaceholders(outputDir string) pathPlaceholders {
+	root, _ := os.Getwd()
+	output, _ := filepath.Abs(outputDir)
+	return pathPlaceholders{root: root, output: output}
+}
+
+func (p pathPlaceholders) generalize(args []string) []string {
+	generalized := make([]string, len(args))
+	for i, arg := range args {
+		// The output directory may be below the root
+		if p.output != "" {
+			arg = strings.ReplaceAll(arg, p.output, "{output}")
+		}
+		if p.root != "" {
+			arg = strings.ReplaceAll(arg, p.root, "{root}")
+		}
+		generalized[i] = arg
+	}
+	return generalized
+}
+
+// recordingExecutor runs commands with another executor and records them,
+// with their output, into a fixtures directory (--record).
+type recordingExecutor struct {
+	Executor
+	dir   string
+	paths pathPlaceholders
+
+	mu       sync.Mutex
+	commands []*recordedCommand
+}
+
+func newRecordingExecutor(executor Executor, dir, outputDir string) (*recordingExecutor, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("--record: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, fixturesIndex)); err == nil {
+		return nil, fmt.Errorf("--record: %s already holds a recording", dir)
+	}
+	return &recordingExecutor{Executor: executor, dir: dir, paths: newPathPlaceholders(outputDir)}, nil
+}
+
+func (r *recordingExecutor) Run(ctx context.Context, cmd *Command) error {
+	var stdout, stderr bytes.Buffer
+	recorded := *cmd
+	recorded.Stdout = teeWriter(cmd.Stdout, &stdout)
+	recorded.Stderr = teeWriter(cmd.Stderr, &stderr)
+	err := r.Executor.Run(ctx, &recorded)
+	if ctx.Err() != nil {
+		// Cut short, so not worth replaying
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(r.commands) + 1
+	entry := &recordedCommand{
+		Args:   r.paths.generalize(cmd.Args),
+		Stdout: fmt.Sprintf("%03d.stdout", n),
+		Stderr: fmt.Sprintf("%03d.stderr", n),
+	}
+	if cmd.Dir != "" {
+		entry.Dir = r.paths.generalize([]string{cmd.Dir})[0]
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		entry.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		entry.Error = err.Error()
+	}
+	if writeErr := os.WriteFile(filepath.Join(r.dir, entry.Stdout), stdout.Bytes(), 0644); writeErr != nil {
+		warningColor.Printf("⚠️  --record: %v\n", writeErr)
+	}
+	if writeErr := os.WriteFile(filepath.Join(r.dir, entry.Stderr), stderr.Bytes(), 0644); writeErr != nil {
+		warningColor.Printf("⚠️  --record: %v\n", writeErr)
+	}
+	r.commands = append(r.commands, entry)
+	// Rewritten after every command, so an interrupted run keeps what it
+	// recorded
+	data, _ := json.MarshalIndent(r.commands, "", "  ")
+	if writeErr := os.WriteFile(filepath.Join(r.dir, fixturesIndex), append(data, '\n'), 0644); writeErr != nil {
+		warningColor.Printf("⚠️  --record: %v\n", writeErr)
+	}
+	return err
+}
+
+func teeWriter(w io.Writer, buf *bytes.Buffer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(w, buf)
+}
+
+// replayExecutor answers commands with the output recorded for the same
+// command line in a fixtures directory (--replay), without running
+// anything. A command line recorded more than once is answered in the
+// order it was recorded.
+type replayExecutor struct {
+	dir   string
+	paths pathPlaceholders
+
+	mu       sync.Mutex
+	recorded map[string][]*recordedCommand
+}
+
+func newReplayExecutor(dir, outputDir string) (*replayExecutor, error) {
+	data, err := os.ReadFile(filepath.Join(dir, fixturesIndex))
+	if err != nil {
+		return nil, fmt.Errorf("--replay: %v", err)
+	}
+	var commands []*recordedCommand
+	if err := json.Unmarshal(data, &commands); err != nil {
+		return nil, fmt.Errorf("--replay: invalid %s: %v", filepath.Join(dir, fixturesIndex), err)
+	}
+	r := &replayExecutor{dir: dir, paths: newPathPlaceholders(outputDir), recorded: make(map[string][]*recordedCommand)}
+	for _, command := range commands {
+		key := replayKey(command.Args)
+		r.recorded[key] = append(r.recorded[key], command)
+	}
+	return r, nil
+}
+
+func replayKey(args []string) string {
+	return strings.Join(shellQuoteAll(args), " ")
+}
+
+func (r *replayExecutor) Run(ctx context.Context, cmd *Command) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key := replayKey(r.paths.generalize(cmd.Args))
+	r.mu.Lock()
+	queue := r.recorded[key]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return fmt.Errorf("no recorded output for `%s` in %s", key, r.dir)
+	}
+	command := queue[0]
+	if len(queue) > 1 {
+		// The last recording answers any further runs, e.g. retries
+		r.recorded[key] = queue[1:]
+	}
+	r.mu.Unlock()
+
+	for _, stream := range []struct {
+		file string
+		w    io.Writer
+	}{{command.Stdout, cmd.Stdout}, {command.Stderr, cmd.Stderr}} {
+		if stream.w == nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(r.dir, stream.file))
+		if err != nil {
+			return fmt.Errorf("--replay: %v", err)
+		}
+		stream.w.Write(data)
+	}
+	switch {
+	case command.Error != "":
+		return errors.New(command.Error)
+	case command.ExitCode != 0:
+		return fmt.Errorf("exit status %d", command.ExitCode)
+	}
+	return nil
+}
+
+// replaying tells whether the run's commands are answered from fixtures
+// (--replay), so there's no module, script or AWS account to check.
+func (pg *PlanGenerator) replaying() bool {
+	_, ok := pg.Executor.(*replayExecutor)
+	return ok
+}
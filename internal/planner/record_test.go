@@ -0,0 +1,65 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "run")
+	recorder, err := newRecordingExecutor(&fakeExecutor{stdout: stagingPlan, stderr: "warning\n"}, dir, outputDir)
+	if err != nil {
+		t.Fatalf("newRecordingExecutor: %v", err)
+	}
+	planFile, _ := filepath.Abs(filepath.Join(outputDir, "tfplans", "staging.tfplan"))
+	args := []string{"kitman", "tg", "plan", "-out=" + planFile}
+	var stdout bytes.Buffer
+	if err := recorder.Run(context.Background(), &Command{Args: args, Stdout: &stdout}); err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	if stdout.String() != stagingPlan {
+		t.Errorf("recording passed on %q, want the command's stdout", stdout.String())
+	}
+	if got := recorder.commands[0].Args[3]; got != "-out={output}/tfplans/staging.tfplan" {
+		t.Errorf("recorded %q, want the output directory as a placeholder", got)
+	}
+
+	// Replayed into another output directory
+	otherOutput := filepath.Join(t.TempDir(), "replay")
+	player, err := newReplayExecutor(dir, otherOutput)
+	if err != nil {
+		t.Fatalf("newReplayExecutor: %v", err)
+	}
+	otherPlanFile, _ := filepath.Abs(filepath.Join(otherOutput, "tfplans", "staging.tfplan"))
+	var replayed, replayedErr bytes.Buffer
+	cmd := &Command{Args: []string{"kitman", "tg", "plan", "-out=" + otherPlanFile}, Stdout: &replayed, Stderr: &replayedErr}
+	if err := player.Run(context.Background(), cmd); err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+	if replayed.String() != stagingPlan || replayedErr.String() != "warning\n" {
+		t.Errorf("replayed %q and %q, want the recorded output", replayed.String(), replayedErr.String())
+	}
+
+	err = player.Run(context.Background(), &Command{Args: []string{"kitman", "tg", "plan_all"}, Stdout: &replayed})
+	if err == nil || !strings.Contains(err.Error(), "no recorded output") {
+		t.Errorf("replaying an unrecorded command: %v", err)
+	}
+}
+
+func TestRecordRefusesExistingRecording(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := newRecordingExecutor(&fakeExecutor{}, dir, "out")
+	if err != nil {
+		t.Fatalf("newRecordingExecutor: %v", err)
+	}
+	if err := recorder.Run(context.Background(), &Command{Args: []string{"true"}}); err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	if _, err := newRecordingExecutor(&fakeExecutor{}, dir, "out"); err == nil {
+		t.Error("recorded over an existing recording")
+	}
+}
@@ -0,0 +1,211 @@
+package planner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxReleaseNotes caps each release body in the report; the release link
+// has the rest.
+const maxReleaseNotes = 4000
+
+var (
+	githubSourceRegex = regexp.MustCompile(`github\.com[/:]([\w.-]+)/([\w.-]+?)(?:\.git)?(?:[/?]|$)`)
+	sourceRefRegex    = regexp.MustCompile(`[?&]ref=([^&]+)`)
+)
+
+// moduleBump is a GitHub-hosted module source whose ?ref= changed on the
+// branch, with the releases published in between.
+type moduleBump struct {
+	Repo     string // owner/name
+	From, To string
+	Files    []string
+	Releases []*githubRelease // after From up to and including To, newest first
+	Err      error            // why Releases couldn't be fetched
+}
+
+// fetchReleaseNotes finds module version bumps on the branch and fetches
+// their release notes. Failures only warn: release notes are context for
+// reviewers, not something a plan depends on.
+func (pg *PlanGenerator) fetchReleaseNotes() []*moduleBump {
+	base := pg.baseRef()
+	bumps, err := moduleBumps(base)
+	if err != nil {
+		warningColor.Printf("⚠️  Can't look for module version bumps: %v\n", err)
+		return nil
+	}
+	if len(bumps) == 0 {
+		if pg.Verbose {
+			fmt.Fprintf(console, "📦 No module version bumps since %s\n", base)
+		}
+		return nil
+	}
+
+	infoColor.Printf("📦 Fetching release notes for %d module version bump(s)...\n", len(bumps))
+	client := newGitHubAPI()
+	for _, bump := range bumps {
+		releases, err := client.releases(bump.Repo)
+		if err != nil {
+			bump.Err = err
+		} else {
+			bump.Releases, bump.Err = releasesBetween(releases, bump.From, bump.To)
+		}
+		if bump.Err != nil {
+			warningColor.Printf("⚠️  No release notes for %s %s → %s: %v\n", bump.Repo, bump.From, bump.To, bump.Err)
+		}
+	}
+	return bumps
+}
+
+// moduleBumps diffs the branch's terraform and terragrunt files against
+// base and pairs each removed GitHub module source with an added one of
+// the same repository at a different ref.
+func moduleBumps(base string) ([]*moduleBump, error) {
+	fork, err := forkPoint(base)
+	if err != nil {
+		return nil, err
+	}
+	out, err := commandOutput(exec.Command("git", "diff", "-U0", fork, "--", "*.hcl", "*.tf"))
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %v", err)
+	}
+
+	type source struct{ repo, ref string }
+	var file string
+	removed := make(map[string][]source)
+	added := make(map[string][]source)
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "+++ ") {
+			file = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			continue
+		}
+		if strings.HasPrefix(line, "--- ") || (!strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "+")) {
+			continue
+		}
+		m := sourceRegex.FindStringSubmatch(line[1:])
+		if m == nil {
+			continue
+		}
+		repo := githubSourceRegex.FindStringSubmatch(m[1])
+		ref := sourceRefRegex.FindStringSubmatch(m[1])
+		if repo == nil || ref == nil {
+			continue
+		}
+		s := source{repo: repo[1] + "/" + repo[2], ref: ref[1]}
+		if line[0] == '-' {
+			removed[file] = append(removed[file], s)
+		} else {
+			added[file] = append(added[file], s)
+		}
+	}
+
+	byKey := make(map[string]*moduleBump)
+	var bumps []*moduleBump
+	for file, sources := range added {
+		for _, to := range sources {
+			for _, from := range removed[file] {
+				if from.repo != to.repo || from.ref == to.ref {
+					continue
+				}
+				key := to.repo + " " + from.ref + " " + to.ref
+				bump := byKey[key]
+				if bump == nil {
+					bump = &moduleBump{Repo: to.repo, From: from.ref, To: to.ref}
+					byKey[key] = bump
+					bumps = append(bumps, bump)
+				}
+				if !contains(bump.Files, file) {
+					bump.Files = append(bump.Files, file)
+				}
+				break
+			}
+		}
+	}
+	sort.Slice(bumps, func(i, j int) bool {
+		if bumps[i].Repo != bumps[j].Repo {
+			return bumps[i].Repo < bumps[j].Repo
+		}
+		return bumps[i].To < bumps[j].To
+	})
+	for _, bump := range bumps {
+		sort.Strings(bump.Files)
+	}
+	return bumps, nil
+}
+
+// releasesBetween picks the releases after from up to and including to out
+// of a newest-first release list. Downgrades list the releases being
+// rolled back instead.
+func releasesBetween(releases []*githubRelease, from, to string) ([]*githubRelease, error) {
+	index := func(tag string) int {
+		for i, r := range releases {
+			if r.Tag == tag {
+				return i
+			}
+		}
+		return -1
+	}
+	newer, older := index(to), index(from)
+	if newer < 0 {
+		return nil, fmt.Errorf("no release tagged %s", to)
+	}
+	if older < 0 {
+		return nil, fmt.Errorf("no release tagged %s among the latest %d", from, len(releases))
+	}
+	if newer > older {
+		newer, older = older, newer
+	}
+	return releases[newer:older], nil
+}
+
+// writeReleaseNotes renders one collapsible section per module bump.
+func (pg *PlanGenerator) writeReleaseNotes(output *os.File) {
+	if len(pg.moduleBumps) == 0 {
+		return
+	}
+	server := os.Getenv("GITHUB_SERVER_URL")
+	if server == "" {
+		server = "https://github.com"
+	}
+
+	output.WriteString("## " + pg.renderer().Icon("📦") + "Module version changes\n\n")
+	for _, bump := range pg.moduleBumps {
+		summary := fmt.Sprintf("%s %s to %s", bump.Repo, bump.From, bump.To)
+		if bump.Err == nil {
+			summary += fmt.Sprintf(" (%d release(s))", len(bump.Releases))
+		}
+		pg.renderer().OpenSection(output, 3, summary)
+		output.WriteString(fmt.Sprintf("Changed in `%s` — [compare %s...%s](%s/%s/compare/%s...%s)\n\n",
+			strings.Join(bump.Files, "`, `"), bump.From, bump.To, strings.TrimSuffix(server, "/"), bump.Repo, bump.From, bump.To))
+		if bump.Err != nil {
+			output.WriteString(fmt.Sprintf("> %sRelease notes unavailable: %v\n\n", pg.renderer().Icon("⚠️"), bump.Err))
+		}
+		for _, release := range bump.Releases {
+			title := release.Tag
+			if release.Name != "" && release.Name != release.Tag {
+				title += " — " + release.Name
+			}
+			output.WriteString(fmt.Sprintf("#### [%s](%s)\n\n", title, release.URL))
+			body := strings.TrimSpace(strings.ReplaceAll(release.Body, "\r\n", "\n"))
+			if len(body) > maxReleaseNotes {
+				cut := strings.LastIndex(body[:maxReleaseNotes], "\n")
+				if cut < 0 {
+					cut = maxReleaseNotes
+				}
+				body = strings.TrimSpace(body[:cut]) + "\n\n_(truncated, see the release for the rest)_"
+			}
+			if body == "" {
+				body = "_No release notes._"
+			}
+			output.WriteString(body + "\n\n")
+		}
+		pg.renderer().CloseSection(output)
+	}
+}
@@ -0,0 +1,145 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// remotePollInterval is how often the status of a remote run is checked.
+var remotePollInterval = 5 * time.Second
+
+// RemoteStack maps the states matching a glob to the Terraform Cloud
+// workspace, Spacelift stack or env0 environment that plans them.
+type RemoteStack struct {
+	// States is a glob (with ** for any number of directories) of state
+	// paths.
+	States string `yaml:"states"`
+	// Name is a text/template of the workspace's, stack's or
+	// environment's name with {{.Path}}, {{.Env}}, {{.Region}},
+	// {{.Workspace}}, {{.Partition}} and {{.Module}}.
+	Name string `yaml:"name"`
+
+	tmpl *template.Template
+}
+
+func validateRemoteStacks(field string, stacks []*RemoteStack) error {
+	for i, stack := range stacks {
+		if stack.States == "" || stack.Name == "" {
+			return fmt.Errorf("%s[%d]: states and name are required", field, i)
+		}
+		tmpl, err := template.New("name").Option("missingkey=error").Parse(stack.Name)
+		if err != nil {
+			return fmt.Errorf("%s[%d]: name: %v", field, i, err)
+		}
+		stack.tmpl = tmpl
+	}
+	return nil
+}
+
+// remoteRun is a run planning a state on a remote platform, linked from
+// the report.
+type remoteRun struct {
+	State    string
+	Platform string
+	URL      string
+}
+
+// remoteStack is the name of the first of stacks planning state, "" if
+// none does.
+func (pg *PlanGenerator) remoteStack(stacks []*RemoteStack, state *State) (string, error) {
+	fields := pg.selectorFieldsOf(state)
+	for _, stack := range stacks {
+		if !matchGlob(stack.States, fields["path"]) {
+			continue
+		}
+		var b bytes.Buffer
+		err := stack.tmpl.Execute(&b, map[string]string{
+			"Path":      fields["path"],
+			"Env":       fields["env"],
+			"Region":    fields["region"],
+			"Workspace": fields["workspace"],
+			"Partition": fields["partition"],
+			"Module":    pg.ModuleName,
+		})
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", stack.States, err)
+		}
+		return strings.TrimSpace(b.String()), nil
+	}
+	return "", nil
+}
+
+// planOnPlatform plans state on the platform managing it, if any: the
+// first of Terraform Cloud, Spacelift and env0 with a stack matching it.
+// remote is false for states planned locally.
+func (pg *PlanGenerator) planOnPlatform(ctx context.Context, state *State) (output []byte, remote bool, err error) {
+	platforms := []struct {
+		section string
+		stacks  []*RemoteStack
+		plan    func(context.Context, *State, string) ([]byte, error)
+	}{
+		{"terraform_cloud", pg.Config.TerraformCloud.Workspaces, pg.planTFC},
+		{"spacelift", pg.Config.Spacelift.Stacks, pg.planSpacelift},
+		{"env0", pg.Config.Env0.Environments, pg.planEnv0},
+	}
+	for _, platform := range platforms {
+		name, err := pg.remoteStack(platform.stacks, state)
+		if err != nil {
+			return nil, true, fmt.Errorf("%s: %v", platform.section, err)
+		}
+		if name != "" {
+			output, err := platform.plan(ctx, state, name)
+			return output, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// recordRemoteRun links a state's remote run from the report and its
+// console log.
+func (pg *PlanGenerator) recordRemoteRun(state *State, platform, url string) {
+	pg.progress.log(state.String(), "--- planning remotely: "+url+" ---")
+	if pg.Verbose {
+		fmt.Fprintf(console, "    ☁️  %s: %s\n", state, url)
+	}
+	pg.flushMu.Lock()
+	pg.remoteRuns = append(pg.remoteRuns, &remoteRun{State: state.String(), Platform: platform, URL: url})
+	pg.flushMu.Unlock()
+}
+
+// waitRemote calls check every remotePollInterval until it's done, fails
+// or ctx ends.
+func waitRemote(ctx context.Context, check func() (done bool, err error)) error {
+	for {
+		done, err := check()
+		if done || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(remotePollInterval):
+		}
+	}
+}
+
+// writeRemoteNote links the remote runs that planned states.
+func (pg *PlanGenerator) writeRemoteNote(output *os.File) {
+	if len(pg.remoteRuns) == 0 {
+		return
+	}
+	runs := slices.Clone(pg.remoteRuns)
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].State < runs[j].State })
+	var links []string
+	for _, run := range runs {
+		links = append(links, fmt.Sprintf("[`%s`](%s) (%s)", run.State, run.URL, run.Platform))
+	}
+	output.WriteString(fmt.Sprintf("> %sPlanned remotely: %s\n\n", pg.renderer().Icon("☁️"), strings.Join(links, ", ")))
+}
@@ -0,0 +1,88 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// findsRepoRoot annotates the commands that run from the repository root,
+// moving up to it when started further down.
+const findsRepoRoot = "finds-repo-root"
+
+// changeDir moves to --chdir, else, for the commands that run from the
+// repository root, up to the root when started below it, e.g. from inside a
+// module directory. Relative paths given with --chdir are taken from the new
+// directory, like git -C.
+func changeDir(cmd *cobra.Command) error {
+	if dir, _ := cmd.Flags().GetString("chdir"); dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			return fmt.Errorf("--chdir: %v", err)
+		}
+		return nil
+	}
+	if cmd.Annotations[findsRepoRoot] == "" {
+		return nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	root := findRepoRoot(cwd)
+	if root == "" || root == cwd {
+		return nil
+	}
+
+	// A relative --config was meant from where the command was started
+	if flag := cmd.Flags().Lookup("config"); flag != nil && flag.Value.String() != "" && !filepath.IsAbs(flag.Value.String()) {
+		flag.Value.Set(filepath.Join(cwd, flag.Value.String()))
+	}
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	infoColor.Printf("📂 Running from the repository root %s\n", root)
+	return nil
+}
+
+// findRepoRoot walks up from dir to the first directory holding modules or
+// affected-modules.sh, not past the root of the git checkout; "" when
+// there's none. A config with module_roots marks the root itself, so a
+// module root isn't taken for it.
+func findRepoRoot(dir string) string {
+	if path := FindConfigFile(dir); path != "" {
+		if cfg, err := LoadConfig(path); err == nil && len(cfg.ModuleRoots) > 0 {
+			return filepath.Dir(path)
+		}
+	}
+	for {
+		if isRepoRoot(dir) {
+			return dir
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// isRepoRoot reports whether dir holds modules, in itself or the
+// module_roots of a config there, or affected-modules.sh.
+func isRepoRoot(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "affected-modules.sh")); err == nil {
+		return true
+	}
+	roots := []string{"."}
+	if path := FindConfigFile(dir); path != "" && filepath.Dir(path) == dir {
+		if cfg, err := LoadConfig(path); err == nil {
+			roots = cfg.moduleRoots()
+		}
+	}
+	modules, err := findModules(dir, roots)
+	return err == nil && len(modules) > 0
+}
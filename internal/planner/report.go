@@ -0,0 +1,127 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PartitionResult holds the parsed plans of one partition.
+type PartitionResult struct {
+	Partition    *Partition
+	Environments []*Environment // sorted by name
+	Warnings     []string       // non-fatal parse anomalies
+}
+
+// allWarnings lists the parse warnings of every partition, prefixed with
+// the partition name.
+func allWarnings(results []*PartitionResult) []string {
+	var warnings []string
+	for _, result := range results {
+		for _, w := range result.Warnings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", result.Partition.Name, w))
+		}
+	}
+	return warnings
+}
+
+var planCountsRegex = regexp.MustCompile(`Plan: (\d+) to add, (\d+) to change, (\d+) to destroy`)
+
+// PlanCounts is the parsed "Plan: X to add, Y to change, Z to destroy" line.
+type PlanCounts struct {
+	Add, Change, Destroy int
+}
+
+// ParsePlanCounts finds the Plan: summary in a plan body.
+func ParsePlanCounts(body string) (PlanCounts, bool) {
+	m := planCountsRegex.FindStringSubmatch(body)
+	if m == nil {
+		return PlanCounts{}, false
+	}
+	add, _ := strconv.Atoi(m[1])
+	change, _ := strconv.Atoi(m[2])
+	destroy, _ := strconv.Atoi(m[3])
+	return PlanCounts{Add: add, Change: change, Destroy: destroy}, true
+}
+
+// collectResults parses every partition's plans file and prints the parse
+// warnings.
+func (pg *PlanGenerator) collectResults() ([]*PartitionResult, error) {
+	var results []*PartitionResult
+	for _, p := range pg.Config.Partitions {
+		result, err := pg.parsePlansFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("error processing %s plans: %v", p.Name, err)
+		}
+		for _, w := range result.Warnings {
+			warningColor.Printf("⚠️  %s: %s\n", p.Name, w)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (pg *PlanGenerator) parsePlansFile(p *Partition) (*PartitionResult, error) {
+	result := &PartitionResult{Partition: p}
+
+	filePath := filepath.Join(pg.OutputDir, p.OutputFile)
+	content, err := os.ReadFile(filePath)
+	if err != nil || len(content) == 0 {
+		return result, nil // Skip if file doesn't exist or is empty
+	}
+
+	contentStr := string(content)
+	if contentStr == p.EmptyPlaceholder() {
+		return result, nil // Skip empty placeholder files
+	}
+
+	parser := &PlanParser{Partition: p, ModulePrefix: pg.Config.Runner.ModulePrefix, EnvironmentOrder: pg.Config.EnvironmentOrder, Normalize: pg.Normalize}
+	result.Environments, result.Warnings = parser.Parse(contentStr)
+	return result, nil
+}
+
+// validateFormats checks the --format values before any plans run.
+func validateFormats(formats []string, cfg *Config) error {
+	for _, format := range formats {
+		switch {
+		case format == "markdown" || format == "junit" || format == "json" || format == "csv":
+		case cfg.formatter(format) != nil:
+		default:
+			supported := []string{"markdown", "junit", "json", "csv"}
+			for _, f := range cfg.Formatters {
+				supported = append(supported, f.Name)
+			}
+			return fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(supported, ", "))
+		}
+	}
+	return nil
+}
+
+// formatFiles are the files additional report formats are written to in
+// the output directory.
+var formatFiles = map[string]string{
+	"junit": "junit.xml",
+	"json":  "report.json",
+	"csv":   "changes.csv",
+}
+
+// writeFormat renders an additional report format and returns its path.
+// Markdown is always written, so it's a no-op here.
+func (pg *PlanGenerator) writeFormat(format string, results []*PartitionResult) (string, error) {
+	if f := pg.Config.formatter(format); f != nil {
+		return pg.runFormatter(f, results)
+	}
+	path := filepath.Join(pg.OutputDir, formatFiles[format])
+	switch format {
+	case "junit":
+		return path, pg.writeJUnit(path, results)
+	case "json":
+		return path, pg.writeJSON(path, results)
+	case "csv":
+		return path, pg.writeCSV(path, results)
+	}
+	return "", nil
+}
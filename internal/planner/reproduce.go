@@ -0,0 +1,117 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newReproduceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reproduce <run_dir>",
+		Short: "Re-run a past run against the same module, states and config",
+		Long: `Re-runs the plans of a previous run using the module, mode, states and
+config recorded in its manifest.json. Before planning, the current git
+commit and (if the run used --snapshot) module sources, provider locks and
+terragrunt configs are compared against what was recorded, so
+discrepancies between the two runs can be traced back to their inputs.`,
+		Args: cobra.ExactArgs(1),
+		Run:  runReproduce,
+	}
+
+	addVerboseFlag(cmd.Flags())
+	cmd.Flags().StringP("output", "o", "", "Output directory (default: <run_dir>-reproduce-TIMESTAMP)")
+	addParallelismFlag(cmd.Flags())
+	return cmd
+}
+
+func runReproduce(cmd *cobra.Command, args []string) {
+	runDir := args[0]
+	manifest, err := readManifest(runDir)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	infoColor.Printf("🔁 Reproducing %s (module %s, started %s)\n", runDir, manifest.Module, manifest.StartedAt.Local().Format(time.RFC1123))
+
+	if head := gitHead(); manifest.GitCommit != "" && head != manifest.GitCommit {
+		warningColor.Printf("⚠️  Checkout is at %s but the run used %s\n", shortSHA(head), shortSHA(manifest.GitCommit))
+	}
+	if manifest.GitDirty {
+		warningColor.Println("⚠️  The original run had uncommitted changes; they can't be reproduced exactly")
+	}
+
+	if manifest.Snapshot != nil {
+		current, err := takeSnapshot(moduleRef{Root: manifest.ModuleRoot, Name: manifest.Module}.Dir(), statePaths(manifest.States))
+		if err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		if diffs := diffSnapshots(manifest.Snapshot, current); len(diffs) > 0 {
+			warningColor.Printf("⚠️  %d input(s) differ from the original run:\n", len(diffs))
+			for _, diff := range diffs {
+				fmt.Fprintf(console, "  - %s\n", diff)
+			}
+		} else {
+			successColor.Println("✅ Inputs match the recorded snapshot")
+		}
+	}
+	fmt.Fprintln(console)
+
+	// Re-use the recorded config rather than whatever is on disk now.
+	cfg, err := manifest.config()
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	verbosity := verboseLevel(cmd.Flags())
+	verbose := verbosity > 0
+	traceCommands = verbosity > 1
+	outputDir, _ := cmd.Flags().GetString("output")
+	parallel, _ := cmd.Flags().GetString("parallelism")
+	if outputDir == "" {
+		outputDir = fmt.Sprintf("%s-reproduce-%s", runDir, time.Now().Format("20060102-150405"))
+	}
+	workers, autoParallel, err := parseParallel(parallel)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	pg := &PlanGenerator{
+		ModuleName: manifest.Module,
+		ModuleRoot: manifest.ModuleRoot,
+		OutputDir:  outputDir,
+		Verbose:    verbose,
+		Targeted:   manifest.Targeted,
+		States:     manifest.States,
+		VarFiles:   manifest.VarFiles,
+		Targets:    manifest.Targets,
+		Select:     manifest.Select,
+		Destroy:    manifest.Destroy,
+		ExtraArgs:  manifest.ExtraArgs,
+		Snapshot:   manifest.Snapshot != nil,
+		Config:     cfg,
+		pool:       newWorkerPool(workers, autoParallel, verbose),
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := pg.RunContext(ctx); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	if sha == "" {
+		return "(unknown)"
+	}
+	return sha
+}
@@ -0,0 +1,331 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newRerunFailedCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rerun-failed --from <run_dir>",
+		Short: "Re-plan only the states a past run failed, merging them into it",
+		Long: `Re-plans the states of a previous run that failed or left an incomplete
+plan, with the module, config and plan arguments recorded in its
+manifest.json, and merges the fresh plans into the run directory:
+its plans files, pr-ready.md and any report.json or junit.xml.
+
+Targeted runs re-plan the states missing from their plans files. Full runs
+re-plan the states of a partition whose plan_all failed that have no
+complete plan, and the states of the other partitions with an incomplete
+one. Plans that fail again are listed in the report's Failed states
+section.`,
+		Args: cobra.NoArgs,
+		Run:  runRerunFailed,
+	}
+
+	cmd.Flags().String("from", "", "Run directory to re-plan the failed states of")
+	addVerboseFlag(cmd.Flags())
+	cmd.Flags().Int("retries", 0, "Run a failed plan again up to N times, with exponential backoff")
+	addParallelismFlag(cmd.Flags())
+	cmd.MarkFlagRequired("from")
+	return cmd
+}
+
+func runRerunFailed(cmd *cobra.Command, args []string) {
+	runDir, _ := cmd.Flags().GetString("from")
+	manifest, err := readManifest(runDir)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	cfg, err := manifest.config()
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	run := &PlanGenerator{
+		ModuleName:      manifest.Module,
+		ModuleRoot:      manifest.ModuleRoot,
+		OutputDir:       runDir,
+		Destroy:         manifest.Destroy,
+		Targets:         manifest.Targets,
+		Select:          manifest.Select,
+		Config:          cfg,
+		CollapseForEach: cfg.CollapseForEach,
+		ApplyOrder:      cfg.ApplyOrder,
+		PlainReport:     cfg.PlainReport,
+	}
+	states, err := run.failedStates(manifest)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(states) == 0 {
+		successColor.Printf("✅ Every state of %s has a complete plan; nothing to re-plan\n", runDir)
+		return
+	}
+	infoColor.Printf("🔁 Re-planning %d failed state(s) of %s\n", len(states), runDir)
+	for _, state := range states {
+		fmt.Fprintf(console, "  - %s\n", state)
+	}
+	fmt.Fprintln(console)
+
+	verbosity := verboseLevel(cmd.Flags())
+	verbose := verbosity > 0
+	traceCommands = verbosity > 1
+	retries, _ := cmd.Flags().GetInt("retries")
+	parallel, _ := cmd.Flags().GetString("parallelism")
+	workers, autoParallel, err := parseParallel(parallel)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	scratch, err := os.MkdirTemp("", "tfprgen-rerun-")
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(scratch)
+
+	savePlans := false
+	for _, state := range states {
+		savePlans = savePlans || state.PlanFile != ""
+	}
+	pg := &PlanGenerator{
+		ModuleName: manifest.Module,
+		ModuleRoot: manifest.ModuleRoot,
+		OutputDir:  scratch,
+		Verbose:    verbose,
+		Targeted:   true,
+		States:     states,
+		VarFiles:   manifest.VarFiles,
+		Targets:    manifest.Targets,
+		Destroy:    manifest.Destroy,
+		ExtraArgs:  manifest.ExtraArgs,
+		SavePlans:  savePlans,
+		Config:     cfg,
+		Retries:    retries,
+		KeepGoing:  true,
+		rerun:      true,
+		pool:       newWorkerPool(workers, autoParallel, verbose),
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	runErr := pg.RunContext(ctx)
+	if runErr != nil && len(pg.failures) == 0 && !pg.interrupted() {
+		errorColor.Printf("❌ Error: %v\n", runErr)
+		os.Exit(1)
+	}
+
+	if err := run.merge(pg); err != nil {
+		errorColor.Printf("❌ Error: merging into %s: %v\n", runDir, err)
+		os.Exit(1)
+	}
+	successColor.Printf("🔀 Merged %d re-planned state(s) into %s\n", len(states)-len(pg.failures)-len(pg.interruptedStates), runDir)
+	boldColor.Printf("📄 PR-ready markdown: %s\n", filepath.Join(runDir, "pr-ready.md"))
+	if runErr != nil {
+		errorColor.Printf("❌ Error: %v\n", runErr)
+		os.Exit(1)
+	}
+}
+
+// failedStates lists the states of a past run to plan again: those without
+// a complete plan in targeted runs; in full runs those of partitions whose
+// plan_all failed without a complete plan, and the others' incomplete ones.
+func (pg *PlanGenerator) failedStates(manifest *RunManifest) ([]*State, error) {
+	results, err := pg.collectResults()
+	if err != nil {
+		return nil, err
+	}
+	incomplete := func(p *Partition, state *State) (planned, complete bool) {
+		env, region, ok := stateRegion(p, state)
+		if !ok {
+			return false, false
+		}
+		for _, result := range results {
+			if result.Partition != p {
+				continue
+			}
+			for _, e := range result.Environments {
+				if _, exists := e.Plans[region]; e.Name == env && exists {
+					return true, !e.Incomplete[region]
+				}
+			}
+		}
+		return false, false
+	}
+
+	var states []*State
+	if manifest.Targeted {
+		plans := make(map[*Partition]string)
+		for _, state := range manifest.States {
+			p := pg.Config.PartitionFor(state.String())
+			if p == nil {
+				continue
+			}
+			if _, ok := plans[p]; !ok {
+				data, _ := os.ReadFile(filepath.Join(pg.OutputDir, p.OutputFile))
+				plans[p] = string(data)
+			}
+			// Only successful plans are written, behind their header
+			written := strings.Contains(plans[p], state.Header()+"\n")
+			if planned, complete := incomplete(p, state); !written || (planned && !complete) {
+				states = append(states, state)
+			}
+		}
+		return states, nil
+	}
+
+	paths, err := findModuleStates(pg.Config.Runner.WorkingDir, pg.ModuleName)
+	if err != nil {
+		return nil, fmt.Errorf("listing the module's states: %v", err)
+	}
+	for _, state := range statesFromPaths(paths) {
+		p := pg.Config.PartitionFor(state.String())
+		if p == nil {
+			continue
+		}
+		_, logErr := os.Stat(pg.errorLogPath(p.Name))
+		failed := logErr == nil
+		if planned, complete := incomplete(p, state); !complete && (failed || planned) {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+// stateRegion is the environment and region a state's plan is reported
+// under.
+func stateRegion(p *Partition, state *State) (env, region string, ok bool) {
+	env, region = state.Env, state.Region
+	envOK, regionOK := env != "", region != ""
+	if !envOK {
+		env, envOK = p.MatchEnv(state.String())
+	}
+	if !regionOK {
+		region, regionOK = p.MatchRegion(state.String())
+	}
+	return env, region, envOK && regionOK
+}
+
+// merge appends the plans of rerun, a targeted run of some of this run's
+// states, to this run's plans files, where they win over the incomplete
+// plans they replace, and renders the reports again.
+func (pg *PlanGenerator) merge(rerun *PlanGenerator) error {
+	for _, p := range pg.Config.Partitions {
+		fresh, err := os.ReadFile(filepath.Join(rerun.OutputDir, p.OutputFile))
+		if err != nil || len(fresh) == 0 || string(fresh) == p.EmptyPlaceholder() {
+			continue
+		}
+		path := filepath.Join(pg.OutputDir, p.OutputFile)
+		if existing, err := os.ReadFile(path); err == nil && string(existing) == p.EmptyPlaceholder() {
+			os.Remove(path)
+		}
+		if err := appendFile(path, fresh); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []string{"tfplans", errorLogDir} {
+		if err := copyDir(filepath.Join(rerun.OutputDir, dir), filepath.Join(pg.OutputDir, dir)); err != nil {
+			return err
+		}
+	}
+
+	pg.failures, pg.attempts = rerun.failures, rerun.attempts
+	for _, f := range pg.failures {
+		// Their logs were copied along
+		var lockErr *stateLockError
+		if errors.As(f.Err, &lockErr) {
+			f.Err = &stateLockError{Lock: lockErr.Lock, Err: errors.New(strings.ReplaceAll(lockErr.Err.Error(), rerun.OutputDir, pg.OutputDir))}
+		} else {
+			f.Err = errors.New(strings.ReplaceAll(f.Err.Error(), rerun.OutputDir, pg.OutputDir))
+		}
+	}
+	results, err := pg.collectResults()
+	if err != nil {
+		return err
+	}
+	if err := pg.generatePRMarkdown(results, ""); err != nil {
+		return err
+	}
+	if err := pg.fitOutputBudget(results, ""); err != nil {
+		return err
+	}
+	formats := make(map[string]string)
+	for format, name := range formatFiles {
+		formats[format] = name
+	}
+	for _, f := range pg.Config.Formatters {
+		if f.Output != "" {
+			formats[f.Name] = f.Output
+		}
+	}
+	for format, name := range formats {
+		if _, err := os.Stat(filepath.Join(pg.OutputDir, name)); err != nil {
+			continue
+		}
+		if _, err := pg.writeFormat(format, results); err != nil {
+			return err
+		}
+	}
+	if len(pg.failures) == 0 && !rerun.interrupted() {
+		// Complete now, so apply accepts it
+		pg.markComplete()
+		return pg.sealManifest()
+	}
+	return nil
+}
+
+func appendFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// copyDir copies the files of src, if it exists, into dst.
+func copyDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		in, err := os.Open(filepath.Join(src, entry.Name()))
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(filepath.Join(dst, entry.Name()))
+		if err != nil {
+			in.Close()
+			return err
+		}
+		_, err = io.Copy(out, in)
+		in.Close()
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// retryBackoff is the wait before a failed plan's first retry; it
+	// doubles for each retry after, up to retryMaxBackoff.
+	retryBackoff    = 5 * time.Second
+	retryMaxBackoff = time.Minute
+)
+
+// planWithRetries runs a targeted plan, retrying it up to --retries times
+// when it fails, e.g. on a flaky network. Plans failing on AWS rate limits
+// are retried up to throttleRetries more times, with fewer plans running at
+// once. States needing more than one attempt are recorded for the report.
+func (pg *PlanGenerator) planWithRetries(ctx context.Context, p *Partition, state *State) ([]byte, error) {
+	backoff := retryBackoff
+	throttled := 0
+	for attempt := 1; ; attempt++ {
+		output, err := pg.planInitialized(ctx, p, state)
+		var throttleErr *throttledError
+		if errors.As(err, &throttleErr) && throttled < throttleRetries && ctx.Err() == nil {
+			throttled++
+			if pg.waitThrottled(ctx, p, state, throttled) {
+				continue
+			}
+		} else if err == nil {
+			pg.poolFor(p).unthrottled()
+		}
+		if err == nil || attempt-throttled > pg.Retries || ctx.Err() != nil {
+			if attempt > 1 {
+				pg.flushMu.Lock()
+				if pg.attempts == nil {
+					pg.attempts = make(map[string]int)
+				}
+				pg.attempts[state.String()] = attempt
+				pg.flushMu.Unlock()
+				if err != nil {
+					err = fmt.Errorf("failed %d attempts, the last with: %w", attempt, err)
+				}
+			}
+			return output, err
+		}
+
+		warningColor.Printf("🔁 Plan failed for %s, retrying in %s (attempt %d of %d)\n", state, backoff, attempt+1, pg.Retries+1)
+		pg.progress.log(state.String(), fmt.Sprintf("--- attempt %d failed, retrying in %s ---", attempt, backoff))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		if backoff *= 2; backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+	}
+}
+
+// regionAttempts is the most attempts a state of the region plan needed,
+// 1 when none was retried.
+func (pg *PlanGenerator) regionAttempts(p *Partition, env, region string) int {
+	most := 1
+	for name, attempts := range pg.attempts {
+		if attempts > most && stateInRegion(p, name, env, region) {
+			most = attempts
+		}
+	}
+	return most
+}
+
+// stateInRegion tells whether a state's name, its path or env/region,
+// belongs to the partition's region plan.
+func stateInRegion(p *Partition, name, env, region string) bool {
+	stateEnv, envOK := p.MatchEnv(name)
+	stateRegion, regionOK := p.MatchRegion(name)
+	return envOK && regionOK && stateEnv == env && stateRegion == region
+}
@@ -0,0 +1,120 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runLockName is the lock file runs in a checkout take, in its git
+// directory so worktrees have their own and it never shows as a change.
+const runLockName = "tfprgen.lock"
+
+// runLock is who holds the lock of a checkout.
+type runLock struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	User      string    `json:"user,omitempty"`
+	Module    string    `json:"module"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+func (l *runLock) String() string {
+	holder := fmt.Sprintf("PID %d on %s", l.PID, l.Host)
+	if l.User != "" {
+		holder += " by " + l.User
+	}
+	return fmt.Sprintf("%s, module %s, started at %s", holder, l.Module, l.StartedAt.Local().Format("2006-01-02 15:04:05"))
+}
+
+// stale reports whether the holder is gone: a process of this host that
+// no longer runs.
+func (l *runLock) stale(host string) bool {
+	return l.Host == host && !processAlive(l.PID)
+}
+
+// runLockPath is the lock file of the current checkout: in its git
+// directory, else .tfprgen.lock outside git.
+func runLockPath() string {
+	out, err := commandOutput(exec.Command("git", "rev-parse", "--absolute-git-dir"))
+	if err != nil {
+		return "." + runLockName
+	}
+	return filepath.Join(strings.TrimSpace(string(out)), runLockName)
+}
+
+// lockCheckout keeps other runs out of the checkout while this one plans,
+// since they would trample each other's terragrunt caches and state locks.
+// A lock whose process is gone is taken over; one still held fails the
+// run, unless Force takes it over anyway. The returned func releases it.
+func (pg *PlanGenerator) lockCheckout() (func(), error) {
+	path := runLockPath()
+	host, _ := os.Hostname()
+	lock := &runLock{PID: os.Getpid(), Host: host, Module: pg.moduleLabel(), StartedAt: time.Now()}
+	if u, err := user.Current(); err == nil {
+		lock.User = u.Username
+	} else {
+		lock.User = os.Getenv("USER")
+	}
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = file.Write(data)
+			if cerr := file.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("locking the checkout: %v", err)
+			}
+			return func() { releaseRunLock(path, lock.PID) }, nil
+		}
+		if !os.IsExist(err) || attempt > 0 {
+			return nil, fmt.Errorf("locking the checkout: %v", err)
+		}
+
+		holder, err := readRunLock(path)
+		switch {
+		case err != nil:
+			warningColor.Printf("⚠️  Replacing the unreadable run lock %s: %v\n", path, err)
+		case holder.stale(host):
+			warningColor.Printf("⚠️  Taking over the lock of a run that's gone (%s)\n", holder)
+		case pg.Force:
+			warningColor.Printf("⚠️  --force: taking over the lock of the run in progress (%s)\n", holder)
+		default:
+			return nil, fmt.Errorf("another run is in progress in this checkout (%s); wait for it to finish, or pass --force if it's gone (lock file: %s)", holder, path)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("locking the checkout: %v", err)
+		}
+	}
+}
+
+func readRunLock(path string) (*runLock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lock runLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// releaseRunLock removes the lock file unless another run took it over.
+func releaseRunLock(path string, pid int) {
+	if holder, err := readRunLock(path); err == nil && holder.PID == pid {
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,46 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestLockCheckout(t *testing.T) {
+	cwd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(cwd)
+	pg := newTestGenerator(t, nil)
+
+	release, err := pg.lockCheckout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pg.lockCheckout(); err == nil || !strings.Contains(err.Error(), fmt.Sprintf("another run is in progress in this checkout (PID %d", os.Getpid())) {
+		t.Errorf("err = %v, want the run in progress", err)
+	}
+	pg.Force = true
+	if _, err := pg.lockCheckout(); err != nil {
+		t.Errorf("--force: %v", err)
+	}
+	release()
+	if _, err := os.Stat(runLockPath()); !os.IsNotExist(err) {
+		t.Errorf("lock not released: %v", err)
+	}
+
+	// A lock left by a process that's gone is taken over
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Skip(err)
+	}
+	host, _ := os.Hostname()
+	data, _ := json.Marshal(&runLock{PID: exited.Process.Pid, Host: host, Module: "vpc"})
+	os.WriteFile(runLockPath(), data, 0644)
+	pg.Force = false
+	if _, err := pg.lockCheckout(); err != nil && !processAlive(exited.Process.Pid) {
+		t.Errorf("stale lock: %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RunnerConfig defines how plans are executed. PlanAll, Plan, Init and
+// Apply are text/template command lines rendered with commandData.
+type RunnerConfig struct {
+	// Name selects a built-in preset that the other fields then override.
+	Name    string `yaml:"name"`
+	Binary  string `yaml:"binary"`
+	PlanAll string `yaml:"plan_all"`
+	Plan    string `yaml:"plan"`
+	// Init initializes one state ahead of planning (--init).
+	Init string `yaml:"init"`
+	// Apply applies one state's saved plan (the apply subcommand).
+	Apply string `yaml:"apply"`
+	// Show prints one state's saved plan as JSON (--save-plans).
+	Show string `yaml:"show"`
+	// Label names the command in the markdown headings.
+	Label string `yaml:"label"`
+	// WorkingDir is the root plan_all runs from (terragrunt-style runners).
+	WorkingDir string `yaml:"working_dir"`
+	// ModulePrefix means plan_all output lines are prefixed with
+	// "[state path] " and interleaved, so they're demultiplexed before
+	// parsing.
+	ModulePrefix bool `yaml:"module_prefix"`
+	// Workspaces switches to plain terraform workspace mode: root modules
+	// below WorkingDir are discovered and planned once per workspace, with
+	// TF_WORKSPACE set.
+	Workspaces bool `yaml:"workspaces"`
+	// WorkspacePattern maps workspace names to (?P<env>...) and
+	// (?P<region>...); unmatched workspaces use their name as environment.
+	WorkspacePattern string `yaml:"workspace_pattern"`
+
+	planAllTmpl    *template.Template
+	planTmpl       *template.Template
+	initTmpl       *template.Template
+	applyTmpl      *template.Template
+	showTmpl       *template.Template
+	workspaceRegex *regexp.Regexp
+}
+
+// commandData is what runner templates can reference.
+type commandData struct {
+	Runner        string
+	Module        string
+	Path          string // state directory, for Plan, Init and Apply only
+	PlanFile      string // absolute saved plan path, for Apply and Show only
+	Partition     string
+	Organizations string   // pipe-separated
+	Regions       string   // pipe-separated
+	Args          []string // runner_args followed by arguments given after --
+	WorkingDir    string
+	IncludeDirs   []string // globs selecting the partition's states
+	ExcludeDirs   []string // the partition's exclude_dirs
+}
+
+// runnerPresets are the built-in runners selectable with --runner or
+// runner.name in the config.
+var runnerPresets = map[string]func() RunnerConfig{
+	"kitman":     DefaultRunner,
+	"terragrunt": TerragruntRunner,
+	"terraform":  TerraformRunner,
+}
+
+// DefaultRunner runs plans through the internal kitman wrapper.
+func DefaultRunner() RunnerConfig {
+	return RunnerConfig{
+		Name:    "kitman",
+		Binary:  "kitman",
+		PlanAll: `{{.Runner}} tg plan_all -m {{.Module}}{{with .Organizations}} --organizations {{quote .}}{{end}}{{with .Regions}} --regions {{quote .}}{{end}} --local --pr {{args .Args}}`,
+		Plan:    `{{.Runner}} tg plan --wd {{quote .Path}} --local --pr {{args .Args}}`,
+		Init:    `{{.Runner}} tg init --wd {{quote .Path}}`,
+		Apply:   `{{.Runner}} tg apply --wd {{quote .Path}} {{quote .PlanFile}}`,
+		Show:    `{{.Runner}} tg show --wd {{quote .Path}} -json {{quote .PlanFile}}`,
+		Label:   "kitman tg plan_all",
+	}
+}
+
+// TerragruntRunner runs terragrunt directly, for setups without kitman.
+func TerragruntRunner() RunnerConfig {
+	return RunnerConfig{
+		Name:   "terragrunt",
+		Binary: "terragrunt",
+		PlanAll: `{{.Runner}} run-all plan --terragrunt-non-interactive --terragrunt-include-module-prefix` +
+			` --terragrunt-working-dir {{quote .WorkingDir}} --terragrunt-strict-include` +
+			`{{range .IncludeDirs}} --terragrunt-include-dir {{quote .}}{{end}}` +
+			`{{range .ExcludeDirs}} --terragrunt-exclude-dir {{quote .}}{{end}} {{args .Args}}`,
+		Plan:         `{{.Runner}} plan --terragrunt-non-interactive --terragrunt-working-dir {{quote .Path}} {{args .Args}}`,
+		Init:         `{{.Runner}} init -input=false --terragrunt-non-interactive --terragrunt-working-dir {{quote .Path}}`,
+		Apply:        `{{.Runner}} apply --terragrunt-non-interactive --terragrunt-working-dir {{quote .Path}} {{quote .PlanFile}}`,
+		Show:         `{{.Runner}} show -json --terragrunt-non-interactive --terragrunt-working-dir {{quote .Path}} {{quote .PlanFile}}`,
+		Label:        "terragrunt run-all plan",
+		WorkingDir:   ".",
+		ModulePrefix: true,
+	}
+}
+
+// TerraformRunner plans plain terraform root modules once per workspace.
+func TerraformRunner() RunnerConfig {
+	return RunnerConfig{
+		Name:             "terraform",
+		Binary:           "terraform",
+		Plan:             `{{.Runner}} -chdir={{quote .Path}} plan -input=false {{args .Args}}`,
+		Init:             `{{.Runner}} -chdir={{quote .Path}} init -input=false`,
+		Apply:            `{{.Runner}} -chdir={{quote .Path}} apply -input=false {{quote .PlanFile}}`,
+		Show:             `{{.Runner}} -chdir={{quote .Path}} show -json {{quote .PlanFile}}`,
+		Label:            "terraform plan",
+		WorkingDir:       ".",
+		Workspaces:       true,
+		WorkspacePattern: `^(?P<env>.+?)[-_](?P<region>[a-z]{2}(?:-gov)?-[a-z]+-[0-9])$`,
+	}
+}
+
+// RunnerPreset returns the built-in runner called name.
+func RunnerPreset(name string) (RunnerConfig, error) {
+	preset, ok := runnerPresets[name]
+	if !ok {
+		var names []string
+		for n := range runnerPresets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return RunnerConfig{}, fmt.Errorf("unknown runner %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	return preset(), nil
+}
+
+// UnmarshalYAML starts from the preset named by "name" (if any) so a config
+// only needs to list the fields it changes.
+func (r *RunnerConfig) UnmarshalYAML(node *yaml.Node) error {
+	var named struct {
+		Name string `yaml:"name"`
+	}
+	if err := node.Decode(&named); err != nil {
+		return err
+	}
+	if named.Name != "" {
+		preset, err := RunnerPreset(named.Name)
+		if err != nil {
+			return err
+		}
+		*r = preset
+	}
+	type plain RunnerConfig
+	return node.Decode((*plain)(r))
+}
+
+var runnerFuncs = template.FuncMap{
+	"quote": shellQuote,
+	"args": func(args []string) string {
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = shellQuote(arg)
+		}
+		return strings.Join(quoted, " ")
+	},
+}
+
+func (r *RunnerConfig) compile() error {
+	if r.Binary == "" {
+		return fmt.Errorf("runner.binary must be set")
+	}
+	var err error
+	if r.planAllTmpl, err = template.New("plan_all").Funcs(runnerFuncs).Parse(r.PlanAll); err != nil {
+		return fmt.Errorf("invalid runner.plan_all template: %v", err)
+	}
+	if r.planTmpl, err = template.New("plan").Funcs(runnerFuncs).Parse(r.Plan); err != nil {
+		return fmt.Errorf("invalid runner.plan template: %v", err)
+	}
+	if r.initTmpl, err = template.New("init").Funcs(runnerFuncs).Parse(r.Init); err != nil {
+		return fmt.Errorf("invalid runner.init template: %v", err)
+	}
+	if r.applyTmpl, err = template.New("apply").Funcs(runnerFuncs).Parse(r.Apply); err != nil {
+		return fmt.Errorf("invalid runner.apply template: %v", err)
+	}
+	if r.showTmpl, err = template.New("show").Funcs(runnerFuncs).Parse(r.Show); err != nil {
+		return fmt.Errorf("invalid runner.show template: %v", err)
+	}
+	if r.Label == "" {
+		r.Label = r.Binary
+	}
+	if r.WorkingDir == "" {
+		r.WorkingDir = "."
+	}
+	if r.WorkspacePattern != "" {
+		if r.workspaceRegex, err = regexp.Compile(r.WorkspacePattern); err != nil {
+			return fmt.Errorf("invalid runner.workspace_pattern: %v", err)
+		}
+	}
+	return nil
+}
+
+// PlanAllCommand renders the argv that plans every state of a partition.
+// extraArgs are forwarded to the plan after the partition's runner_args.
+func (r *RunnerConfig) PlanAllCommand(p *Partition, moduleName string, extraArgs []string) ([]string, error) {
+	return r.render(r.planAllTmpl, p, moduleName, "", "", extraArgs)
+}
+
+// PlanCommand renders the argv that plans a single targeted state.
+func (r *RunnerConfig) PlanCommand(p *Partition, moduleName, planDir string, extraArgs []string) ([]string, error) {
+	return r.render(r.planTmpl, p, moduleName, planDir, "", extraArgs)
+}
+
+// InitCommand renders the argv that initializes a state before planning.
+func (r *RunnerConfig) InitCommand(p *Partition, moduleName, planDir string) ([]string, error) {
+	return r.render(r.initTmpl, p, moduleName, planDir, "", nil)
+}
+
+// ApplyCommand renders the argv that applies a state's saved plan file.
+func (r *RunnerConfig) ApplyCommand(p *Partition, moduleName, planDir, planFile string) ([]string, error) {
+	return r.render(r.applyTmpl, p, moduleName, planDir, planFile, nil)
+}
+
+// ShowCommand renders the argv that prints a state's saved plan file as
+// JSON.
+func (r *RunnerConfig) ShowCommand(p *Partition, moduleName, planDir, planFile string) ([]string, error) {
+	return r.render(r.showTmpl, p, moduleName, planDir, planFile, nil)
+}
+
+func (r *RunnerConfig) render(tmpl *template.Template, p *Partition, moduleName, planDir, planFile string, extraArgs []string) ([]string, error) {
+	args := append(append([]string{}, p.RunnerArgs...), extraArgs...)
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, commandData{
+		Runner:        r.Binary,
+		Module:        moduleName,
+		Path:          planDir,
+		PlanFile:      planFile,
+		Partition:     p.Name,
+		Organizations: strings.Join(p.Organizations, "|"),
+		Regions:       strings.Join(p.Regions, "|"),
+		Args:          args,
+		WorkingDir:    r.WorkingDir,
+		IncludeDirs:   includeDirs(p, moduleName),
+		ExcludeDirs:   p.ExcludeDirs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s command: %v", tmpl.Name(), err)
+	}
+	argv, err := splitCommandLine(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s command %q: %v", tmpl.Name(), buf.String(), err)
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("%s command is empty", tmpl.Name())
+	}
+	return argv, nil
+}
+
+// includeDirs builds a glob per organization/region combination that
+// matches the module's state directories below them.
+func includeDirs(p *Partition, moduleName string) []string {
+	orgs := p.Organizations
+	if len(orgs) == 0 {
+		orgs = []string{""}
+	}
+	regions := p.Regions
+	if len(regions) == 0 {
+		regions = []string{""}
+	}
+
+	var dirs []string
+	for _, org := range orgs {
+		for _, region := range regions {
+			parts := []string{"**"}
+			if org != "" {
+				parts = append(parts, org)
+			}
+			if region != "" {
+				parts = append(parts, region)
+			}
+			parts = append(parts, "**", moduleName)
+			dirs = append(dirs, path.Join(parts...))
+		}
+	}
+	return dirs
+}
+
+// shellQuote single-quotes s if it contains anything splitCommandLine would
+// otherwise interpret.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\|&;<>()$`*?[]#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// splitCommandLine splits a rendered command line into argv using POSIX
+// shell quoting rules (no expansion or operators).
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\$`+"`", runes[i+1]) {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == '\\':
+			if i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+				inArg = true
+			}
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,315 @@
+package planner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SecurityConfig runs a security scanner over every targeted state, for
+// the security findings section of the report.
+type SecurityConfig struct {
+	// Scanner is checkov or tfsec; empty disables scanning.
+	Scanner string `yaml:"scanner"`
+	// Target is what gets scanned: "plan", the state's plan JSON (checkov
+	// only), or "source", the state's directory. Defaults to plan where
+	// the scanner supports it.
+	Target string `yaml:"target"`
+	// Args are added to the scanner's command line, e.g. to skip checks.
+	Args []string `yaml:"args"`
+}
+
+// securityScanner knows how to run one scanner and read its JSON output.
+type securityScanner struct {
+	// targets are the targets the scanner supports, the default first.
+	targets []string
+	command func(target, path string) []string
+	parse   func(output []byte) ([]*securityFinding, error)
+}
+
+var securityScanners = map[string]securityScanner{
+	"checkov": {
+		targets: []string{"plan", "source"},
+		command: func(target, path string) []string {
+			if target == "plan" {
+				return []string{"checkov", "-f", path, "--framework", "terraform_plan", "-o", "json", "--quiet", "--soft-fail"}
+			}
+			return []string{"checkov", "-d", path, "--framework", "terraform", "-o", "json", "--quiet", "--soft-fail"}
+		},
+		parse: parseCheckov,
+	},
+	"tfsec": {
+		targets: []string{"source"},
+		command: func(target, path string) []string {
+			return []string{"tfsec", path, "--format", "json", "--no-color", "--soft-fail"}
+		},
+		parse: parseTfsec,
+	},
+}
+
+// severities orders finding severities, most severe first, with their
+// icons.
+var severities = []struct{ name, icon string }{
+	{"CRITICAL", "🔴"},
+	{"HIGH", "🟠"},
+	{"MEDIUM", "🟡"},
+	{"LOW", "🔵"},
+	{"INFO", "⚪"},
+	{"UNKNOWN", "⚪"},
+}
+
+// securityResult is what the scanner found in the targeted states.
+type securityResult struct {
+	Scanner  string             `json:"scanner"`
+	Target   string             `json:"target"`
+	States   int                `json:"states"`
+	Findings []*securityFinding `json:"findings"`
+}
+
+// securityFinding is one failed check for one resource.
+type securityFinding struct {
+	State     string `json:"state"`
+	Location  string `json:"location"` // env/region, or the state's path
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Severity  string `json:"severity"`
+	Resource  string `json:"resource,omitempty"`
+	Guideline string `json:"guideline,omitempty"`
+}
+
+func (s *SecurityConfig) validate() error {
+	if s.Scanner == "" {
+		return nil
+	}
+	scanner, ok := securityScanners[s.Scanner]
+	if !ok {
+		return fmt.Errorf("unknown scanner %q (supported: checkov, tfsec)", s.Scanner)
+	}
+	if s.Target != "" && !contains(scanner.targets, s.Target) {
+		return fmt.Errorf("%s can't scan %q (supported: %s)", s.Scanner, s.Target, strings.Join(scanner.targets, ", "))
+	}
+	return nil
+}
+
+// target is what the scanner scans, the scanner's default when unset.
+func (s *SecurityConfig) target() string {
+	if s.Target == "" {
+		return securityScanners[s.Scanner].targets[0]
+	}
+	return s.Target
+}
+
+// scanSecurity runs the configured scanner over every planned state, its
+// plan JSON or its directory. A state that can't be scanned fails the
+// scan, since its findings would silently be missing.
+func (pg *PlanGenerator) scanSecurity() (*securityResult, error) {
+	cfg := pg.Config.Security
+	scanner := securityScanners[cfg.Scanner]
+	result := &securityResult{Scanner: cfg.Scanner, Target: cfg.target(), Findings: []*securityFinding{}}
+	for _, state := range pg.plannedStates {
+		path := state.Path
+		if result.Target == "plan" {
+			path = filepath.Join(pg.OutputDir, state.planJSONName())
+			if _, err := os.Stat(path); err != nil {
+				// Failed plans save nothing; they're listed as failures
+				continue
+			}
+		}
+		path, _ = filepath.Abs(path)
+
+		var stdout, stderr bytes.Buffer
+		err := pg.execute(pg.ctx, &Command{
+			Args:   append(scanner.command(result.Target, path), cfg.Args...),
+			Stdout: &stdout,
+			Stderr: &stderr,
+		})
+		findings, parseErr := scanner.parse(stdout.Bytes())
+		if parseErr != nil {
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", state, pg.commandError(err, state.String()+"-"+cfg.Scanner, stderr.Bytes()))
+			}
+			return nil, fmt.Errorf("reading %s output for %s: %v", cfg.Scanner, state, parseErr)
+		}
+		location := pg.stateLocation(state.Path)
+		if state.Workspace != "" {
+			location = state.String()
+		}
+		for _, finding := range findings {
+			finding.State, finding.Location = state.String(), location
+		}
+		result.Findings = append(result.Findings, findings...)
+		result.States++
+	}
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		a, b := result.Findings[i], result.Findings[j]
+		if a.Severity != b.Severity {
+			return severityRank(a.Severity) < severityRank(b.Severity)
+		}
+		return a.Location < b.Location
+	})
+	return result, nil
+}
+
+// checkovReport is one framework's report in `checkov -o json` output,
+// which is a list of them when several frameworks ran.
+type checkovReport struct {
+	Results struct {
+		FailedChecks []struct {
+			CheckID   string  `json:"check_id"`
+			CheckName string  `json:"check_name"`
+			Resource  string  `json:"resource"`
+			Severity  *string `json:"severity"`
+			Guideline string  `json:"guideline"`
+		} `json:"failed_checks"`
+	} `json:"results"`
+}
+
+func parseCheckov(output []byte) ([]*securityFinding, error) {
+	var reports []checkovReport
+	if err := json.Unmarshal(output, &reports); err != nil {
+		var report checkovReport
+		if err := json.Unmarshal(output, &report); err != nil {
+			return nil, err
+		}
+		reports = []checkovReport{report}
+	}
+	var findings []*securityFinding
+	for _, report := range reports {
+		for _, check := range report.Results.FailedChecks {
+			severity := ""
+			if check.Severity != nil {
+				severity = *check.Severity
+			}
+			findings = append(findings, &securityFinding{
+				ID:        check.CheckID,
+				Title:     check.CheckName,
+				Severity:  normalizeSeverity(severity),
+				Resource:  check.Resource,
+				Guideline: check.Guideline,
+			})
+		}
+	}
+	return findings, nil
+}
+
+func parseTfsec(output []byte) ([]*securityFinding, error) {
+	var report struct {
+		Results []struct {
+			RuleID          string   `json:"rule_id"`
+			LongID          string   `json:"long_id"`
+			RuleDescription string   `json:"rule_description"`
+			Description     string   `json:"description"`
+			Severity        string   `json:"severity"`
+			Resource        string   `json:"resource"`
+			Links           []string `json:"links"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, err
+	}
+	var findings []*securityFinding
+	for _, r := range report.Results {
+		finding := &securityFinding{
+			ID:       r.LongID,
+			Title:    r.RuleDescription,
+			Severity: normalizeSeverity(r.Severity),
+			Resource: r.Resource,
+		}
+		if finding.ID == "" {
+			finding.ID = r.RuleID
+		}
+		if finding.Title == "" {
+			finding.Title = r.Description
+		}
+		if len(r.Links) > 0 {
+			finding.Guideline = r.Links[0]
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// normalizeSeverity maps a scanner's severity onto severities; checkov
+// only rates checks with a platform API key.
+func normalizeSeverity(severity string) string {
+	severity = strings.ToUpper(strings.TrimSpace(severity))
+	if severityRank(severity) == len(severities) {
+		return "UNKNOWN"
+	}
+	return severity
+}
+
+func severityRank(severity string) int {
+	for i, s := range severities {
+		if s.name == severity {
+			return i
+		}
+	}
+	return len(severities)
+}
+
+// severityCounts summarizes findings as "1 critical, 2 high".
+func severityCounts(findings []*securityFinding) string {
+	var parts []string
+	for _, s := range severities {
+		n := 0
+		for _, f := range findings {
+			if f.Severity == s.name {
+				n++
+			}
+		}
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, strings.ToLower(s.name)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeSecurityFindings renders the security findings section, one
+// collapsed list per severity.
+func (pg *PlanGenerator) writeSecurityFindings(output *os.File) {
+	r := pg.security
+	if r == nil || r.States == 0 {
+		return
+	}
+	scanned := "plans"
+	if r.Target == "source" {
+		scanned = "configuration"
+	}
+	if len(r.Findings) == 0 {
+		output.WriteString(fmt.Sprintf("> %s%s found no security issues in the %s of %d state(s).\n\n", pg.renderer().Icon("🔒"), r.Scanner, scanned, r.States))
+		return
+	}
+	output.WriteString("## " + pg.renderer().Icon("🔒") + "Security findings\n\n")
+	output.WriteString(fmt.Sprintf("%s found %d issue(s) in the %s of %d state(s): %s.\n\n", r.Scanner, len(r.Findings), scanned, r.States, severityCounts(r.Findings)))
+	for _, s := range severities {
+		var findings []*securityFinding
+		for _, f := range r.Findings {
+			if f.Severity == s.name {
+				findings = append(findings, f)
+			}
+		}
+		if len(findings) == 0 {
+			continue
+		}
+		label := strings.ToUpper(s.name[:1]) + strings.ToLower(s.name[1:])
+		pg.renderer().OpenSection(output, 3, fmt.Sprintf("%s%s (%d)", pg.renderer().Icon(s.icon), label, len(findings)))
+		for _, f := range findings {
+			line := fmt.Sprintf("- `%s` %s", f.ID, f.Title)
+			if f.Resource != "" {
+				line += fmt.Sprintf(": `%s`", f.Resource)
+			}
+			line += " in " + f.Location
+			if f.Guideline != "" {
+				line += fmt.Sprintf(" ([guide](%s))", f.Guideline)
+			}
+			output.WriteString(line + "\n")
+		}
+		output.WriteString("\n")
+		pg.renderer().CloseSection(output)
+	}
+}
@@ -0,0 +1,32 @@
+package planner
+
+import "testing"
+
+func TestParseSecurityScanners(t *testing.T) {
+	checkov := `[{"check_type":"terraform_plan","results":{"failed_checks":[
+		{"check_id":"CKV_AWS_18","check_name":"Ensure access logging","resource":"aws_s3_bucket.this","severity":null},
+		{"check_id":"CKV_AWS_145","check_name":"Ensure KMS encryption","resource":"aws_s3_bucket.this","severity":"high"}]}}]`
+	findings, err := parseCheckov([]byte(checkov))
+	if err != nil {
+		t.Fatalf("parseCheckov: %v", err)
+	}
+	if len(findings) != 2 || findings[0].Severity != "UNKNOWN" || findings[1].Severity != "HIGH" || findings[1].ID != "CKV_AWS_145" {
+		t.Errorf("checkov findings = %+v %+v", findings[0], findings[1])
+	}
+	// Nothing to scan gives a bare summary instead of a report
+	if findings, err := parseCheckov([]byte(`{"passed":0,"failed":0}`)); err != nil || len(findings) != 0 {
+		t.Errorf("empty checkov run = %v, %v", findings, err)
+	}
+
+	tfsec := `{"results":[{"rule_id":"AVD-AWS-0089","long_id":"aws-s3-enable-bucket-logging","rule_description":"S3 Bucket does not have logging enabled.","severity":"MEDIUM","resource":"aws_s3_bucket.this","links":["https://example.com/check"]}]}`
+	findings, err = parseTfsec([]byte(tfsec))
+	if err != nil {
+		t.Fatalf("parseTfsec: %v", err)
+	}
+	if len(findings) != 1 || findings[0].ID != "aws-s3-enable-bucket-logging" || findings[0].Guideline != "https://example.com/check" {
+		t.Errorf("tfsec findings = %+v", findings)
+	}
+	if got := severityCounts(append(findings, &securityFinding{Severity: "CRITICAL"})); got != "1 critical, 1 medium" {
+		t.Errorf("severityCounts = %q", got)
+	}
+}
@@ -0,0 +1,217 @@
+package planner
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// selectorFields are the state attributes a --select expression can test.
+var selectorFields = []string{"env", "region", "partition", "path", "workspace"}
+
+// selector is a parsed --select expression such as
+// `env=production && region=us-east-*`.
+type selector interface {
+	match(fields map[string]string) bool
+}
+
+type selectAnd struct{ left, right selector }
+type selectOr struct{ left, right selector }
+type selectNot struct{ inner selector }
+
+// selectCompare matches one field against a path.Match glob.
+type selectCompare struct {
+	field, pattern string
+	negate         bool
+}
+
+func (s selectAnd) match(f map[string]string) bool { return s.left.match(f) && s.right.match(f) }
+func (s selectOr) match(f map[string]string) bool  { return s.left.match(f) || s.right.match(f) }
+func (s selectNot) match(f map[string]string) bool { return !s.inner.match(f) }
+
+func (s selectCompare) match(f map[string]string) bool {
+	ok, _ := path.Match(s.pattern, f[s.field])
+	return ok != s.negate
+}
+
+// parseSelector parses a selector expression:
+//
+//	expr    = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" expr ")" | field ("=" | "!=") value
+//
+// Values are globs, bare or in single or double quotes.
+func parseSelector(expr string) (selector, error) {
+	p := &selectorParser{input: expr}
+	sel, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid --select %q: %v", expr, err)
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return nil, fmt.Errorf("invalid --select %q: unexpected %q at offset %d", expr, p.input[p.pos:], p.pos)
+	}
+	return sel, nil
+}
+
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+func (p *selectorParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// consume skips token if it comes next.
+func (p *selectorParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *selectorParser) parseOr() (selector, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = selectOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *selectorParser) parseAnd() (selector, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = selectAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *selectorParser) parseUnary() (selector, error) {
+	if p.consume("!") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return selectNot{inner}, nil
+	}
+	if p.consume("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, fmt.Errorf("missing ) at offset %d", p.pos)
+		}
+		return inner, nil
+	}
+
+	field := p.word()
+	if field == "" {
+		return nil, fmt.Errorf("expected a field at offset %d", p.pos)
+	}
+	if !contains(selectorFields, field) {
+		return nil, fmt.Errorf("unknown field %q (supported: %s)", field, strings.Join(selectorFields, ", "))
+	}
+	cmp := selectCompare{field: field}
+	switch {
+	case p.consume("!="):
+		cmp.negate = true
+	case p.consume("="):
+	default:
+		return nil, fmt.Errorf("expected = or != after %s", field)
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := path.Match(value, ""); err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %v", value, err)
+	}
+	cmp.pattern = value
+	return cmp, nil
+}
+
+// word reads a bare field name or value.
+func (p *selectorParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && !strings.ContainsRune(" \t\n()&|!='\"", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *selectorParser) value() (string, error) {
+	p.skipSpace()
+	if p.pos < len(p.input) && (p.input[p.pos] == '\'' || p.input[p.pos] == '"') {
+		quote := p.input[p.pos]
+		end := strings.IndexByte(p.input[p.pos+1:], quote)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote at offset %d", p.pos)
+		}
+		value := p.input[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return value, nil
+	}
+	value := p.word()
+	if value == "" {
+		return "", fmt.Errorf("expected a value at offset %d", p.pos)
+	}
+	return value, nil
+}
+
+// selectorFieldsOf describes a state for selector matching. Env and region
+// come from the state itself or, as in the report, its partition's
+// patterns applied to the path.
+func (pg *PlanGenerator) selectorFieldsOf(state *State) map[string]string {
+	fields := map[string]string{
+		"path":      filepath.ToSlash(state.Path),
+		"workspace": state.Workspace,
+		"env":       state.Env,
+		"region":    state.Region,
+	}
+	p := pg.Config.PartitionFor(state.String())
+	if p == nil {
+		return fields
+	}
+	fields["partition"] = p.Name
+	dir := filepath.ToSlash(state.Path) + "/"
+	if fields["env"] == "" {
+		fields["env"], _ = p.MatchEnv(dir)
+	}
+	if fields["region"] == "" {
+		fields["region"], _ = p.MatchRegion(dir)
+	}
+	return fields
+}
+
+// selectStates keeps the states matching sel.
+func (pg *PlanGenerator) selectStates(states []*State, sel selector) []*State {
+	var selected []*State
+	for _, state := range states {
+		if sel.match(pg.selectorFieldsOf(state)) {
+			selected = append(selected, state)
+		}
+	}
+	return selected
+}
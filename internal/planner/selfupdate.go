@@ -0,0 +1,260 @@
+package planner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// updateRepo is the repository whose releases self-update installs.
+const updateRepo = "backendken/terraform-pr-generator"
+
+// Release assets self-update reads next to the binaries: sha256sum output
+// for all of them, and its ed25519 signature (base64).
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = checksumsAsset + ".sig"
+)
+
+// UpdatePublicKey is the base64 ed25519 public key release checksums are
+// signed with, set with -ldflags like Version. Builds with a key refuse
+// releases without a valid signature; builds without one can only check
+// the checksums.
+var UpdatePublicKey string
+
+var (
+	semverRegex = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+	// devVersionRegex matches the versions of builds between releases: Go
+	// pseudo-versions, git describe past a tag and uncommitted changes.
+	devVersionRegex = regexp.MustCompile(`\d{14}-[0-9a-f]{12}|-\d+-g[0-9a-f]+|dirty`)
+)
+
+func newSelfUpdateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Update the generator to its latest release",
+		Long: `Replaces the running binary with the latest GitHub release of the
+generator, or the one --version names. The download is checked against the
+release's checksums.txt, whose signature is verified first when the build
+has a release key, and the new binary has to run before it's swapped in.
+
+Private repositories and mirrors need GITHUB_TOKEN; GITHUB_API_URL points
+at GitHub Enterprise.
+
+Examples:
+  terraform-pr-generator self-update --check
+  terraform-pr-generator self-update
+  terraform-pr-generator self-update --version v1.3.2`,
+		Args: cobra.NoArgs,
+		Run:  runSelfUpdate,
+	}
+	cmd.Flags().Bool("check", false, "Only report whether a newer release exists (exit status 1 if so)")
+	cmd.Flags().String("version", "latest", "Release tag to install, e.g. v1.3.2, also to downgrade")
+	cmd.Flags().String("repo", updateRepo, "owner/name of the repository publishing the releases, e.g. an internal mirror")
+	cmd.Flags().Bool("force", false, "Install even over a development build or the same version")
+	return cmd
+}
+
+func runSelfUpdate(cmd *cobra.Command, args []string) {
+	check, _ := cmd.Flags().GetBool("check")
+	tag, _ := cmd.Flags().GetString("version")
+	repo, _ := cmd.Flags().GetString("repo")
+	force, _ := cmd.Flags().GetBool("force")
+
+	current := CurrentBuild().Version
+	release, err := newGitHubAPI().release(repo, tag)
+	if err != nil {
+		errorColor.Printf("❌ Error: finding the %s release of %s: %v\n", tag, repo, err)
+		os.Exit(1)
+	}
+
+	order, comparable := compareVersions(release.Tag, current)
+	switch {
+	case check && comparable && order <= 0:
+		successColor.Printf("✅ %s is up to date (latest release: %s)\n", current, release.Tag)
+		return
+	case check && !comparable:
+		infoColor.Printf("ℹ️  Running development build %s; the latest release is %s\n", current, release.Tag)
+		return
+	case check:
+		warningColor.Printf("⬆️  %s is available (running %s): %s\n", release.Tag, current, release.URL)
+		os.Exit(1)
+	case !force && !comparable:
+		errorColor.Printf("❌ Error: %s is a development build; pass --force to replace it with %s\n", current, release.Tag)
+		os.Exit(1)
+	case !force && order == 0:
+		successColor.Printf("✅ Already running %s\n", current)
+		return
+	case !force && order < 0 && tag == "latest":
+		successColor.Printf("✅ %s is newer than the latest release, %s\n", current, release.Tag)
+		return
+	}
+
+	infoColor.Printf("⬇️  Updating %s → %s\n", current, release.Tag)
+	path, err := selfUpdate(release)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	successColor.Printf("✅ Updated %s to %s\n", path, release.Tag)
+}
+
+// releaseBinary is the name of the release asset holding the binary for
+// this platform, as make build-all names them.
+func releaseBinary() string {
+	name := fmt.Sprintf("terraform-pr-generator-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// selfUpdate downloads and verifies release's binary for this platform and
+// swaps it in for the running one, returning the binary's path.
+func selfUpdate(release *githubRelease) (string, error) {
+	api := newGitHubAPI()
+	binary, checksums := release.asset(releaseBinary()), release.asset(checksumsAsset)
+	switch {
+	case binary == nil:
+		return "", fmt.Errorf("release %s has no %s binary for %s/%s", release.Tag, releaseBinary(), runtime.GOOS, runtime.GOARCH)
+	case checksums == nil:
+		return "", fmt.Errorf("release %s has no %s to verify the download with", release.Tag, checksumsAsset)
+	}
+	sums, err := api.downloadAsset(checksums)
+	if err != nil {
+		return "", err
+	}
+	if UpdatePublicKey != "" {
+		signature := release.asset(signatureAsset)
+		if signature == nil {
+			return "", fmt.Errorf("release %s isn't signed (no %s)", release.Tag, signatureAsset)
+		}
+		sig, err := api.downloadAsset(signature)
+		if err != nil {
+			return "", err
+		}
+		if err := verifyChecksumsSignature(sums, sig, UpdatePublicKey); err != nil {
+			return "", fmt.Errorf("release %s: %v", release.Tag, err)
+		}
+	}
+	want, err := lookupChecksum(sums, binary.Name)
+	if err != nil {
+		return "", fmt.Errorf("release %s: %v", release.Tag, err)
+	}
+
+	data, err := api.downloadAsset(binary)
+	if err != nil {
+		return "", err
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != want {
+		return "", fmt.Errorf("%s doesn't match its checksum in %s; not installing it", binary.Name, checksumsAsset)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", err
+	}
+	return exe, replaceExecutable(exe, data)
+}
+
+// replaceExecutable swaps data in for the binary at exe. The new binary is
+// written next to it and has to run before it replaces the old one, which
+// Windows keeps as <exe>.old since a running binary can't be removed there.
+func replaceExecutable(exe string, data []byte) error {
+	next := exe + ".new"
+	if err := os.WriteFile(next, data, 0755); err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("can't write to %s: rerun with permission to, e.g. sudo, or reinstall somewhere you own", filepath.Dir(exe))
+		}
+		return err
+	}
+	if out, err := exec.Command(next, "version").CombinedOutput(); err != nil {
+		os.Remove(next)
+		return fmt.Errorf("the downloaded binary doesn't run here (%v): %s", err, strings.TrimSpace(string(out)))
+	}
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			os.Remove(next)
+			return err
+		}
+	}
+	if err := os.Rename(next, exe); err != nil {
+		os.Remove(next)
+		return err
+	}
+	return nil
+}
+
+// lookupChecksum finds a file's SHA-256 in sha256sum output.
+func lookupChecksum(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// "*name" is sha256sum's binary mode
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", checksumsAsset, name)
+}
+
+// verifyChecksumsSignature checks the base64 ed25519 signature of a
+// release's checksums against the base64 public key.
+func verifyChecksumsSignature(sums, signature []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("this build's release key is invalid")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(key), sums, sig) {
+		return fmt.Errorf("%s signature does not match", checksumsAsset)
+	}
+	return nil
+}
+
+// compareVersions orders two semantic versions like strings.Compare, with
+// prereleases before their release. comparable is false when either isn't
+// one, e.g. a development build.
+func compareVersions(a, b string) (order int, comparable bool) {
+	ma, mb := semverRegex.FindStringSubmatch(a), semverRegex.FindStringSubmatch(b)
+	if ma == nil || mb == nil || devVersionRegex.MatchString(a) || devVersionRegex.MatchString(b) {
+		return 0, false
+	}
+	for i := 1; i <= 3; i++ {
+		x, _ := strconv.Atoi(ma[i])
+		y, _ := strconv.Atoi(mb[i])
+		if x != y {
+			if x < y {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	switch preA, preB := ma[4], mb[4]; {
+	case preA == preB:
+		return 0, true
+	case preA == "":
+		return 1, true
+	case preB == "":
+		return -1, true
+	}
+	return strings.Compare(ma[4], mb[4]), true
+}
@@ -0,0 +1,55 @@
+package planner
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b       string
+		order      int
+		comparable bool
+	}{
+		{"v1.4.0", "v1.3.9", 1, true},
+		{"v1.4.0", "1.4.0", 0, true},
+		{"v1.4.0-rc.1", "v1.4.0", -1, true},
+		{"v1.10.0", "v1.9.0", 1, true},
+		{"v1.4.0", "dev", 0, false},
+		{"v1.4.0", "v1.3.0-2-gabc1234", 0, false},
+		{"v1.4.0", "v0.0.0-20250604143022-3f9c2a1d0b7e+dirty", 0, false},
+	}
+	for _, tt := range tests {
+		order, comparable := compareVersions(tt.a, tt.b)
+		if order != tt.order || comparable != tt.comparable {
+			t.Errorf("compareVersions(%q, %q) = %d, %v, want %d, %v", tt.a, tt.b, order, comparable, tt.order, tt.comparable)
+		}
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	sums := []byte("3a7bd3e2360a3d29eea436fcfb7e44c735d117c42d1c1835420b6b9942dd4f1b  terraform-pr-generator-linux-amd64\n" +
+		"1b4f0e9851971998e732078544c96b36c3d01cedf7caa332359d6f1d83567014 *terraform-pr-generator-windows-amd64.exe\n")
+	if sum, err := lookupChecksum(sums, "terraform-pr-generator-windows-amd64.exe"); err != nil || sum[:8] != "1b4f0e98" {
+		t.Errorf("lookupChecksum = %q, %v", sum, err)
+	}
+	if _, err := lookupChecksum(sums, "terraform-pr-generator-darwin-arm64"); err == nil {
+		t.Error("lookupChecksum found a missing binary")
+	}
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(public)
+	signature := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(private, sums)) + "\n")
+	if err := verifyChecksumsSignature(sums, signature, key); err != nil {
+		t.Errorf("verifyChecksumsSignature: %v", err)
+	}
+	tampered := append([]byte("0"), sums[1:]...)
+	if err := verifyChecksumsSignature(tampered, signature, key); err == nil {
+		t.Error("verified the signature of tampered checksums")
+	}
+}
@@ -0,0 +1,745 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// serveTokenEnv is the bearer token API requests must carry, if set.
+const serveTokenEnv = "TFPRGEN_SERVE_TOKEN"
+
+// maxQueuedRuns bounds the runs waiting for the planner.
+const maxQueuedRuns = 50
+
+var moduleNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func newServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a REST API for starting runs and fetching their reports",
+		Long: `Serves an HTTP API so tools such as a ChatOps bot can generate plans without
+a shell on a workstation. Run it from the repo root; runs are queued and
+planned one at a time, or up to --max-concurrent-runs at once, and recorded
+in the run history like any other. Runs of the same module always wait for
+each other.
+
+  POST /runs                    start a run: {"module": "s3_malware_protection",
+                                "mode": "auto", "select": "env=staging",
+                                "targets": [...], "destroy": false}
+  GET  /runs                    list runs, newest first
+  GET  /runs/{id}               a run's status, queue position and change totals
+  GET  /runs/{id}/report        the rendered markdown
+  GET  /runs/{id}/report.json   the JSON report
+  GET  /healthz                 liveness
+  POST /webhooks/github         pull_request webhooks, with TFPRGEN_WEBHOOK_SECRET
+
+Requests must carry "Authorization: Bearer $TFPRGEN_SERVE_TOKEN" when that
+variable is set; without it, --listen must be a localhost address. Runs
+are kept in memory; earlier ones are in the history.
+
+With TFPRGEN_WEBHOOK_SECRET set (and GITHUB_TOKEN and GITHUB_REPOSITORY),
+pull requests opened or pushed to are planned for every module their diff
+touches, from a worktree of the head commit, and the report is posted as a
+comment on the pull request. The runs use the server's .tfprgen.yaml, never
+the pull request's. Pull requests from forks are ignored, except those of
+the repositories TFPRGEN_WEBHOOK_TRUSTED_FORKS lists, comma-separated.
+
+Pull request runs, and with --max-concurrent-runs above 1 every run, plan
+in a child process and a worktree of their own: of the pull request's head
+commit, or of the checkout's HEAD for API runs, so uncommitted changes
+aren't planned.
+
+Examples:
+  terraform-pr-generator serve
+  TFPRGEN_SERVE_TOKEN=... terraform-pr-generator serve --listen :8080
+  terraform-pr-generator serve --max-concurrent-runs 3`,
+		Args:        cobra.NoArgs,
+		Run:         runServe,
+		Annotations: map[string]string{findsRepoRoot: "true"},
+	}
+	flags := cmd.Flags()
+	flags.String("listen", "127.0.0.1:8080", "Address to listen on")
+	flags.StringP("config", "c", "", "Path to a YAML config file (default: .tfprgen.yaml in the repo root)")
+	addVerboseFlag(flags)
+	flags.String("runner", "", "Built-in runner to plan with: kitman, terragrunt or terraform (default: from config, else kitman)")
+	flags.Int("max-concurrent-runs", 1, "Runs to plan at once, each in a worktree of its own when above 1; runs of a module still plan one at a time")
+	addParallelismFlag(flags)
+	return cmd
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	listen, _ := cmd.Flags().GetString("listen")
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = FindConfigFile(".")
+	}
+	// Fail on a broken config now rather than on the first request
+	if _, err := LoadConfig(configPath); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	maxConcurrent, _ := cmd.Flags().GetInt("max-concurrent-runs")
+	if maxConcurrent < 1 {
+		errorColor.Println("❌ Error: --max-concurrent-runs must be at least 1")
+		os.Exit(1)
+	}
+
+	root, err := os.Getwd()
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	s := newPlanServer(cmd, root, configPath, os.Getenv(serveTokenEnv))
+	// Without a token anyone who can reach the address could start runs
+	if s.token == "" && !loopbackAddress(listen) {
+		errorColor.Printf("❌ Error: %s must be set to listen on %s; without it the API only listens on localhost\n", serveTokenEnv, listen)
+		os.Exit(1)
+	}
+	s.maxConcurrent = maxConcurrent
+	if s.webhookSecret = os.Getenv(webhookSecretEnv); s.webhookSecret != "" {
+		if _, err := newGitHubClient(); err != nil {
+			errorColor.Printf("❌ Error: %s needs %v\n", webhookSecretEnv, err)
+			os.Exit(1)
+		}
+		for _, fork := range strings.Split(os.Getenv(trustedForksEnv), ",") {
+			if fork = strings.TrimSpace(fork); fork != "" {
+				s.trustedForks = append(s.trustedForks, fork)
+			}
+		}
+	}
+	server := &http.Server{Addr: listen, Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	// A signal stops accepting requests and lets the runs in progress
+	// finish
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		infoColor.Println("⏹️  Shutting down after the runs in progress...")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	go s.work()
+	infoColor.Printf("🌐 Serving the API on http://%s\n", listen)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	s.drain()
+}
+
+// loopbackAddress reports whether listen only accepts connections from the
+// machine itself.
+func loopbackAddress(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// runRequest is the body of POST /runs.
+type runRequest struct {
+	Module  string   `json:"module"`
+	Mode    string   `json:"mode,omitempty"`
+	Select  string   `json:"select,omitempty"`
+	Targets []string `json:"targets,omitempty"`
+	Destroy bool     `json:"destroy,omitempty"`
+}
+
+// serverRun is a run started through the API.
+type serverRun struct {
+	ID int64 `json:"id"`
+	runRequest
+	// PullRequest is set for runs triggered by a webhook.
+	PullRequest *pullRequestRef `json:"pull_request,omitempty"`
+	Status      string          `json:"status"` // queued, running, succeeded, failed or superseded
+	Error       string          `json:"error,omitempty"`
+	QueuedAt    time.Time       `json:"queued_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	OutputDir   string          `json:"output_dir,omitempty"`
+	// Totals are set once the plans are parsed.
+	Totals *runTotals `json:"totals,omitempty"`
+	// QueuePosition is the run's place among the queued runs, from 1.
+	QueuePosition int `json:"queue_position,omitempty"`
+}
+
+// runTotals are the resources a run's plans change.
+type runTotals struct {
+	Adds       int  `json:"adds"`
+	Changes    int  `json:"changes"`
+	Destroys   int  `json:"destroys"`
+	Incomplete bool `json:"incomplete"`
+}
+
+// planServer queues runs requested through the API and plans them one at a
+// time in the checkout, since concurrent runs would share it and the runner
+// caches, or up to maxConcurrent at once in worktrees of their own. Runs of
+// a module always plan one at a time, so they don't pile up on its state
+// locks.
+type planServer struct {
+	cmd        *cobra.Command
+	root       string // the checkout runs plan in
+	configPath string
+	token      string
+	// webhookSecret enables /webhooks/github.
+	webhookSecret string
+	// trustedForks are the forks whose pull requests the webhook plans.
+	trustedForks  []string
+	maxConcurrent int
+	// gitMu keeps runs from fetching and adding worktrees at once.
+	gitMu sync.Mutex
+
+	mu   sync.Mutex
+	runs []*serverRun
+	// queued are the runs waiting to start, oldest first, and running the
+	// modules being planned. changed is signalled when either changes.
+	queued  []*serverRun
+	running map[string]bool
+	changed *sync.Cond
+	active  sync.WaitGroup
+	nextID  int64
+	closed  bool
+}
+
+func newPlanServer(cmd *cobra.Command, root, configPath, token string) *planServer {
+	s := &planServer{
+		cmd:           cmd,
+		root:          root,
+		configPath:    configPath,
+		token:         token,
+		maxConcurrent: 1,
+		running:       make(map[string]bool),
+		nextID:        1,
+	}
+	s.changed = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *planServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/runs", s.authorized(s.handleRuns))
+	mux.HandleFunc("/runs/", s.authorized(s.handleRun))
+	mux.HandleFunc("/ui/", s.authorized(s.handleDashboard))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "/ui/", http.StatusFound)
+	})
+	if s.webhookSecret != "" {
+		// Authenticated by the payload signature instead
+		mux.HandleFunc("/webhooks/github", s.handleGitHubWebhook)
+	}
+	return mux
+}
+
+// authorized rejects requests without the bearer token, when one is set.
+// Browsers may send it as the password of basic auth instead, with any user
+// name.
+func (s *planServer) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if _, password, ok := r.BasicAuth(); ok {
+			given = password
+		}
+		if s.token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="terraform-pr-generator"`)
+			httpError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleRuns lists runs (GET) or starts one (POST).
+func (s *planServer) handleRuns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		runs := make([]*serverRun, 0, len(s.runs))
+		for i := len(s.runs) - 1; i >= 0; i-- {
+			runs = append(runs, s.snapshot(s.runs[i]))
+		}
+		s.mu.Unlock()
+		writeJSONResponse(w, http.StatusOK, runs)
+	case http.MethodPost:
+		var req runRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		if err := req.validate(); err != nil {
+			httpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		run, err := s.enqueue(req, nil)
+		if err != nil {
+			httpError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/runs/%d", run.ID))
+		writeJSONResponse(w, http.StatusAccepted, run)
+	default:
+		httpError(w, http.StatusMethodNotAllowed, "use GET or POST")
+	}
+}
+
+// handleRun serves /runs/{id} and its reports.
+func (s *planServer) handleRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	idPart, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/runs/"), "/")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		httpError(w, http.StatusNotFound, "no such run")
+		return
+	}
+	run := s.find(id)
+	if run == nil {
+		httpError(w, http.StatusNotFound, "no such run")
+		return
+	}
+
+	var file, contentType string
+	switch resource {
+	case "":
+		writeJSONResponse(w, http.StatusOK, run)
+		return
+	case "report":
+		file, contentType = "pr-ready.md", "text/markdown; charset=utf-8"
+	case "report.json":
+		file, contentType = formatFiles["json"], "application/json"
+	default:
+		httpError(w, http.StatusNotFound, "no such resource")
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(run.OutputDir, file))
+	if run.OutputDir == "" || err != nil {
+		httpError(w, http.StatusConflict, fmt.Sprintf("run %d has no report (status: %s)", id, run.Status))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(data)
+}
+
+func (req *runRequest) validate() error {
+	if !moduleNameRegex.MatchString(req.Module) {
+		return fmt.Errorf("invalid module %q", req.Module)
+	}
+	if req.Mode != "" {
+		if err := parseMode(req.Mode); err != nil {
+			return err
+		}
+	}
+	if req.Select != "" {
+		if _, err := parseSelector(req.Select); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *planServer) enqueue(req runRequest, pr *pullRequestRef) (*serverRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("shutting down")
+	}
+	if len(s.queued) >= maxQueuedRuns {
+		return nil, fmt.Errorf("%d runs are already queued", maxQueuedRuns)
+	}
+	run := &serverRun{ID: s.nextID, runRequest: req, PullRequest: pr, Status: "queued", QueuedAt: time.Now()}
+	s.nextID++
+	s.runs = append(s.runs, run)
+	s.queued = append(s.queued, run)
+	s.changed.Broadcast()
+	return s.snapshot(run), nil
+}
+
+// find returns a copy of a run, safe to read while it progresses.
+func (s *planServer) find(id int64) *serverRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		if run.ID == id {
+			return s.snapshot(run)
+		}
+	}
+	return nil
+}
+
+// snapshot copies a run, with its queue position; s.mu must be held.
+func (s *planServer) snapshot(run *serverRun) *serverRun {
+	c := *run
+	c.QueuePosition = slices.Index(s.queued, run) + 1
+	return &c
+}
+
+// update changes a run under the lock.
+func (s *planServer) update(run *serverRun, change func(run *serverRun)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(run)
+}
+
+// work starts the queued runs, oldest first, as slots free up, until
+// drain. A run waits while its module is being planned, letting later runs
+// of other modules go first.
+func (s *planServer) work() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		next := s.next()
+		for !s.closed && next < 0 {
+			s.changed.Wait()
+			next = s.next()
+		}
+		if s.closed {
+			return
+		}
+		run := s.start(next)
+		s.active.Add(1)
+		go func() {
+			defer s.active.Done()
+			s.execute(run)
+			s.finished(run)
+		}()
+	}
+}
+
+// start takes the queued run at index i off the queue and marks its module
+// as being planned; s.mu must be held.
+func (s *planServer) start(i int) *serverRun {
+	run := s.queued[i]
+	s.queued = slices.Delete(s.queued, i, i+1)
+	s.running[run.Module] = true
+	return run
+}
+
+// finished frees the slot and the module of a run done planning.
+func (s *planServer) finished(run *serverRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, run.Module)
+	s.changed.Broadcast()
+}
+
+// next is the index of the first queued run that can start, -1 if none;
+// s.mu must be held.
+func (s *planServer) next() int {
+	if len(s.running) >= s.maxConcurrent {
+		return -1
+	}
+	return slices.IndexFunc(s.queued, func(run *serverRun) bool { return !s.running[run.Module] })
+}
+
+// drain stops taking runs, drops the queued ones and waits for those in
+// progress.
+func (s *planServer) drain() {
+	s.mu.Lock()
+	s.closed = true
+	for _, run := range s.queued {
+		run.Status = "failed"
+		run.Error = "server shut down before the run started"
+	}
+	s.queued = nil
+	s.changed.Broadcast()
+	s.mu.Unlock()
+	s.active.Wait()
+}
+
+func (s *planServer) execute(run *serverRun) {
+	if s.superseded(run) {
+		s.update(run, func(run *serverRun) { run.Status = "superseded" })
+		return
+	}
+	started := time.Now()
+	s.update(run, func(run *serverRun) {
+		run.Status = "running"
+		run.StartedAt = &started
+	})
+
+	plan := s.planInProcess
+	if s.maxConcurrent > 1 || run.PullRequest != nil {
+		plan = s.planChild
+	}
+	totals, err := plan(run)
+
+	finished := time.Now()
+	s.update(run, func(run *serverRun) {
+		run.FinishedAt = &finished
+		run.Status = "succeeded"
+		if err != nil {
+			run.Status = "failed"
+			run.Error = err.Error()
+		}
+		run.Totals = totals
+	})
+}
+
+// planInProcess plans an API run in the server's process and checkout.
+// Pull request runs plan in a worktree, so always in a child process: the
+// working directory is shared by every request the server handles.
+func (s *planServer) planInProcess(run *serverRun) (*runTotals, error) {
+	var pg *PlanGenerator
+	err := func() error {
+		var err error
+		// The config is reloaded so changes to the checkout apply
+		if pg, err = newPlanGenerator(s.cmd, run.Module, s.configPath); err != nil {
+			return err
+		}
+		if pg.OutputDir, err = s.outputDir(run, pg.Config, pg.OutputDir); err != nil {
+			return err
+		}
+		if run.Mode != "" {
+			pg.Targeted = run.Mode == modeTargeted
+			pg.AutoMode = run.Mode == modeAuto
+		}
+		if run.Select != "" {
+			pg.Select = run.Select
+			pg.selector, _ = parseSelector(run.Select) // checked by validate
+		}
+		pg.Targets = run.Targets
+		pg.Destroy = run.Destroy
+		if !contains(pg.Formats, "json") {
+			pg.Formats = append(pg.Formats, "json")
+		}
+		return pg.Run()
+	}()
+	if pg == nil || pg.results == nil {
+		return nil, err
+	}
+	total, incomplete := planTotals(pg.results)
+	return &runTotals{Adds: total.Add, Changes: total.Change, Destroys: total.Destroy, Incomplete: incomplete}, err
+}
+
+// planChild plans run in a child process and a worktree of its own, so it
+// shares neither the checkout nor the working directory with the runs
+// planning alongside it. Its output goes to the console prefixed with the
+// run's ID, and its totals are read from its JSON report.
+func (s *planServer) planChild(run *serverRun) (*runTotals, error) {
+	worktree, cleanup, err := s.addWorktree(run.PullRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	configPath, removeConfig, err := s.childConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	defer removeConfig()
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	outputDir, err := cfg.OutputDirName(run.Module, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if outputDir, err = s.outputDir(run, cfg, outputDir); err != nil {
+		return nil, err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{run.Module, "--output", outputDir, "--format", "json", "--config", configPath}
+	if run.Mode != "" {
+		args = append(args, "--mode", run.Mode)
+	}
+	if run.Select != "" {
+		args = append(args, "--select", run.Select)
+	}
+	for _, target := range run.Targets {
+		args = append(args, "--target", target)
+	}
+	if run.Destroy {
+		args = append(args, "--destroy")
+	}
+	if pr := run.PullRequest; pr != nil {
+		args = append(args, "--github-comment", "--pr-number", strconv.Itoa(pr.Number))
+	}
+	for _, flag := range []string{"runner", "parallelism"} {
+		if s.cmd.Flags().Changed(flag) {
+			value, _ := s.cmd.Flags().GetString(flag)
+			args = append(args, "--"+flag, value)
+		}
+	}
+	if level := verboseLevel(s.cmd.Flags()); level > 0 {
+		args = append(args, fmt.Sprintf("--verbose=%d", level))
+	}
+
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = worktree
+	cmd.Env = os.Environ()
+	if pr := run.PullRequest; pr != nil {
+		cmd.Env = append(cmd.Env, "GITHUB_BASE_REF="+pr.Base)
+	}
+	out := &prefixWriter{w: console, prefix: fmt.Sprintf("[run %d] ", run.ID)}
+	errs := &lastErrorWriter{w: out}
+	cmd.Stdout, cmd.Stderr = errs, errs
+	err = cmd.Run()
+	out.Flush()
+	if err != nil && errs.last != "" {
+		err = errors.New(errs.last)
+	}
+	return readRunTotals(outputDir), err
+}
+
+// childConfigPath is the config a child run plans with: the server's, never
+// one in the worktree, where a pull request could change the runner's
+// commands. Without a config in the checkout the child gets an empty file,
+// i.e. the defaults, rather than looking in the worktree; cleanup removes
+// it.
+func (s *planServer) childConfigPath() (path string, cleanup func(), err error) {
+	if s.configPath != "" {
+		if filepath.IsAbs(s.configPath) {
+			return s.configPath, func() {}, nil
+		}
+		return filepath.Join(s.root, s.configPath), func() {}, nil
+	}
+	file, err := os.CreateTemp("", "tfprgen-defaults-*.yaml")
+	if err != nil {
+		return "", nil, err
+	}
+	file.Close()
+	return file.Name(), func() { os.Remove(file.Name()) }, nil
+}
+
+// outputDir claims the output directory of a run, dir unless it exists or
+// another run in progress writes to it: back-to-back runs would share a
+// directory timestamped to the second. A template without a timestamp
+// reuses an existing directory regardless, but never one in use. Pull
+// request runs plan in a temporary worktree, so the directory is anchored
+// to the server's checkout.
+func (s *planServer) outputDir(run *serverRun, cfg *Config, dir string) (string, error) {
+	for i := 0; ; i++ {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(s.root, dir)
+		}
+		_, err := os.Stat(dir)
+		if (err != nil || i >= 2) && s.claimOutputDir(run, dir) {
+			return dir, nil
+		}
+		if i == 4 {
+			return "", fmt.Errorf("%s is the output directory of a run in progress", dir)
+		}
+		time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+		if dir, err = cfg.OutputDirName(run.Module, time.Now()); err != nil {
+			return "", err
+		}
+	}
+}
+
+// claimOutputDir sets run's output directory to dir unless a run in
+// progress has it.
+func (s *planServer) claimOutputDir(run *serverRun, dir string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.runs {
+		if other != run && other.Status == "running" && other.OutputDir == dir {
+			return false
+		}
+	}
+	run.OutputDir = dir
+	return true
+}
+
+// readRunTotals adds up the change counts of a child run's JSON report;
+// nil if it wrote none.
+func readRunTotals(outputDir string) *runTotals {
+	data, err := os.ReadFile(filepath.Join(outputDir, formatFiles["json"]))
+	if err != nil {
+		return nil
+	}
+	var report jsonReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil
+	}
+	totals := &runTotals{}
+	for _, partition := range report.Partitions {
+		for _, env := range partition.Environments {
+			for _, region := range env.Regions {
+				if region.Counts != nil {
+					totals.Adds += region.Counts.Add
+					totals.Changes += region.Counts.Change
+					totals.Destroys += region.Counts.Destroy
+				}
+				totals.Incomplete = totals.Incomplete || region.Incomplete
+			}
+		}
+	}
+	return totals
+}
+
+// lastErrorWriter passes output through to w, keeping the last error a
+// child run printed.
+type lastErrorWriter struct {
+	w    io.Writer
+	line []byte
+	last string
+}
+
+func (l *lastErrorWriter) Write(p []byte) (int, error) {
+	l.line = append(l.line, p...)
+	for {
+		end := bytes.IndexByte(l.line, '\n')
+		if end < 0 {
+			break
+		}
+		if message, ok := strings.CutPrefix(string(l.line[:end]), "❌ Error: "); ok {
+			l.last = strings.TrimSpace(message)
+		}
+		l.line = l.line[end+1:]
+	}
+	return l.w.Write(p)
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func httpError(w http.ResponseWriter, status int, message string) {
+	writeJSONResponse(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,359 @@
+package planner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// serveRequest sends a request to the server's API and returns the
+// response.
+func serveRequest(t *testing.T, s *planServer, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, r)
+	return w
+}
+
+func TestServerAuthorization(t *testing.T) {
+	basic := httptest.NewRequest("GET", "/", nil)
+	basic.SetBasicAuth("anyone", "secret")
+
+	for _, tc := range []struct {
+		name   string
+		token  string
+		target string
+		header http.Header
+		want   int
+	}{
+		{"no token set", "", "/runs", nil, http.StatusOK},
+		{"missing", "secret", "/runs", nil, http.StatusUnauthorized},
+		{"wrong bearer", "secret", "/runs", http.Header{"Authorization": {"Bearer guess"}}, http.StatusUnauthorized},
+		{"bearer", "secret", "/runs", http.Header{"Authorization": {"Bearer secret"}}, http.StatusOK},
+		{"token without Bearer", "secret", "/runs", http.Header{"Authorization": {"secret"}}, http.StatusOK},
+		{"basic auth password", "secret", "/runs", basic.Header, http.StatusOK},
+		{"run", "secret", "/runs/1", nil, http.StatusUnauthorized},
+		{"dashboard", "secret", "/ui/", nil, http.StatusUnauthorized},
+		{"health check", "secret", "/healthz", nil, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newPlanServer(nil, t.TempDir(), "", tc.token)
+			w := serveRequest(t, s, "GET", tc.target, "", tc.header)
+			if w.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+			if challenge := w.Header().Get("WWW-Authenticate"); (w.Code == http.StatusUnauthorized) != (challenge != "") {
+				t.Errorf("WWW-Authenticate = %q with status %d", challenge, w.Code)
+			}
+		})
+	}
+}
+
+func TestServerQueuesRuns(t *testing.T) {
+	s := newPlanServer(nil, t.TempDir(), "", "")
+
+	for _, tc := range []struct {
+		body     string
+		want     int
+		location string
+	}{
+		{`{"module": "vpc", "mode": "auto"}`, http.StatusAccepted, "/runs/1"},
+		{`{"module": "s3", "select": "env=staging", "targets": ["aws_s3_bucket.logs"]}`, http.StatusAccepted, "/runs/2"},
+		{`{"module": "../vpc"}`, http.StatusBadRequest, ""},
+		{`{"module": "vpc", "mode": "everything"}`, http.StatusBadRequest, ""},
+		{`{"module": "vpc", "select": "planet=mars"}`, http.StatusBadRequest, ""},
+		{`{"module": `, http.StatusBadRequest, ""},
+	} {
+		w := serveRequest(t, s, "POST", "/runs", tc.body, nil)
+		if w.Code != tc.want {
+			t.Errorf("POST %s: status = %d, want %d: %s", tc.body, w.Code, tc.want, w.Body)
+		}
+		if location := w.Header().Get("Location"); location != tc.location {
+			t.Errorf("POST %s: Location = %q, want %q", tc.body, location, tc.location)
+		}
+	}
+
+	w := serveRequest(t, s, "GET", "/runs", "", nil)
+	var runs []*serverRun
+	if err := json.Unmarshal(w.Body.Bytes(), &runs); err != nil {
+		t.Fatalf("GET /runs: %v: %s", err, w.Body)
+	}
+	if len(runs) != 2 || runs[0].ID != 2 || runs[1].ID != 1 {
+		t.Fatalf("GET /runs = %+v, want runs 2 and 1", runs)
+	}
+	if runs[0].Module != "s3" || runs[0].Select != "env=staging" || len(runs[0].Targets) != 1 {
+		t.Errorf("run 2 = %+v, want the request's module, selector and target", runs[0])
+	}
+
+	w = serveRequest(t, s, "GET", "/runs/2", "", nil)
+	var run serverRun
+	if err := json.Unmarshal(w.Body.Bytes(), &run); err != nil {
+		t.Fatalf("GET /runs/2: %v: %s", err, w.Body)
+	}
+	if run.Status != "queued" || run.QueuePosition != 2 {
+		t.Errorf("run 2 is %s at position %d, want queued at 2", run.Status, run.QueuePosition)
+	}
+
+	for _, tc := range []struct {
+		method, target string
+		want           int
+	}{
+		{"GET", "/runs/3", http.StatusNotFound},
+		{"GET", "/runs/latest", http.StatusNotFound},
+		{"GET", "/runs/1/plan", http.StatusNotFound},
+		{"DELETE", "/runs/1", http.StatusMethodNotAllowed},
+		{"PUT", "/runs", http.StatusMethodNotAllowed},
+	} {
+		if w := serveRequest(t, s, tc.method, tc.target, "", nil); w.Code != tc.want {
+			t.Errorf("%s %s: status = %d, want %d", tc.method, tc.target, w.Code, tc.want)
+		}
+	}
+
+	s.drain()
+	if w := serveRequest(t, s, "POST", "/runs", `{"module": "vpc"}`, nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST after drain: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if run := s.find(1); run.Status != "failed" {
+		t.Errorf("queued run is %s after drain, want failed", run.Status)
+	}
+}
+
+func TestServerReports(t *testing.T) {
+	s := newPlanServer(nil, t.TempDir(), "", "")
+	if _, err := s.enqueue(runRequest{Module: "vpc"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serveRequest(t, s, "GET", "/runs/1/report", "", nil); w.Code != http.StatusConflict {
+		t.Errorf("report before planning: status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	dir := filepath.Join(s.root, "vpc-plans")
+	os.Mkdir(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "pr-ready.md"), []byte("# vpc\n"), 0644)
+	if !s.claimOutputDir(s.runs[0], dir) {
+		t.Fatal("claimOutputDir refused an unused directory")
+	}
+
+	for _, tc := range []struct {
+		target, contentType, body string
+		want                      int
+	}{
+		{"/runs/1/report", "text/markdown; charset=utf-8", "# vpc\n", http.StatusOK},
+		{"/runs/1/report.json", "", "", http.StatusConflict},
+	} {
+		w := serveRequest(t, s, "GET", tc.target, "", nil)
+		if w.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.target, w.Code, tc.want)
+			continue
+		}
+		if tc.want != http.StatusOK {
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); got != tc.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", tc.target, got, tc.contentType)
+		}
+		if w.Body.String() != tc.body {
+			t.Errorf("%s: body = %q, want %q", tc.target, w.Body, tc.body)
+		}
+	}
+
+	os.WriteFile(filepath.Join(dir, formatFiles["json"]), []byte(`{"partitions": []}`), 0644)
+	w := serveRequest(t, s, "GET", "/runs/1/report.json", "", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("report.json: status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestClaimOutputDir(t *testing.T) {
+	s := newPlanServer(nil, t.TempDir(), "", "")
+	s.enqueue(runRequest{Module: "vpc"}, nil)
+	s.enqueue(runRequest{Module: "vpc"}, nil)
+	first, second := s.runs[0], s.runs[1]
+	dir := filepath.Join(s.root, "vpc-plans")
+
+	if !s.claimOutputDir(first, dir) {
+		t.Fatal("the first run can't claim an unused directory")
+	}
+	first.Status = "running"
+	if s.claimOutputDir(second, dir) {
+		t.Error("a second run claimed the directory of a run in progress")
+	}
+	if second.OutputDir != "" {
+		t.Errorf("refused claim set OutputDir to %q", second.OutputDir)
+	}
+	if !s.claimOutputDir(first, dir) {
+		t.Error("a run can't claim its own directory again")
+	}
+	first.Status = "succeeded"
+	if !s.claimOutputDir(second, dir) {
+		t.Error("a finished run still holds its directory")
+	}
+
+	// A directory that doesn't exist yet is taken as is, anchored to the
+	// server's checkout
+	third, _ := s.enqueue(runRequest{Module: "s3"}, nil)
+	got, err := s.outputDir(s.runs[len(s.runs)-1], nil, "s3-plans")
+	if err != nil {
+		t.Fatalf("outputDir: %v", err)
+	}
+	if want := filepath.Join(s.root, "s3-plans"); got != want || s.find(third.ID).OutputDir != want {
+		t.Errorf("outputDir = %q, want %q", got, want)
+	}
+}
+
+func TestLoopbackAddress(t *testing.T) {
+	for listen, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"127.1.2.3:8080": true,
+		"localhost:8080": true,
+		"[::1]:8080":     true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"[::]:8080":      false,
+		"10.0.0.5:8080":  false,
+		"planner:8080":   false,
+		"127.0.0.1":      false,
+	} {
+		if got := loopbackAddress(listen); got != want {
+			t.Errorf("loopbackAddress(%q) = %v, want %v", listen, got, want)
+		}
+	}
+}
+
+func TestServerQueue(t *testing.T) {
+	// A step finishes a run, if any, then starts every run next allows
+	type step struct {
+		finish  int64   // the ID of the run finishing
+		started []int64 // the runs then started, in order
+		queued  []int64 // the runs left waiting, by queue position
+	}
+	for _, tc := range []struct {
+		name    string
+		limit   int
+		modules []string // the modules of runs 1, 2, ...
+		steps   []step
+	}{
+		{
+			name:    "one at a time",
+			limit:   1,
+			modules: []string{"vpc", "s3", "dns"},
+			steps: []step{
+				{started: []int64{1}, queued: []int64{2, 3}},
+				{finish: 1, started: []int64{2}, queued: []int64{3}},
+				{finish: 2, started: []int64{3}},
+				{finish: 3},
+			},
+		},
+		{
+			name:    "up to the limit",
+			limit:   2,
+			modules: []string{"vpc", "s3", "dns", "iam"},
+			steps: []step{
+				{started: []int64{1, 2}, queued: []int64{3, 4}},
+				{finish: 2, started: []int64{3}, queued: []int64{4}},
+				{finish: 1, started: []int64{4}},
+			},
+		},
+		{
+			name:    "a module at a time",
+			limit:   3,
+			modules: []string{"vpc", "vpc", "s3", "vpc", "s3", "dns"},
+			steps: []step{
+				{started: []int64{1, 3, 6}, queued: []int64{2, 4, 5}},
+				{finish: 3, started: []int64{5}, queued: []int64{2, 4}},
+				{finish: 6, queued: []int64{2, 4}},
+				{finish: 1, started: []int64{2}, queued: []int64{4}},
+				{finish: 2, started: []int64{4}},
+			},
+		},
+		{
+			name:    "later modules go first",
+			limit:   2,
+			modules: []string{"vpc", "vpc", "s3"},
+			steps: []step{
+				{started: []int64{1, 3}, queued: []int64{2}},
+				{finish: 3, queued: []int64{2}},
+				{finish: 1, started: []int64{2}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newPlanServer(nil, t.TempDir(), "", "")
+			s.maxConcurrent = tc.limit
+			for _, module := range tc.modules {
+				if _, err := s.enqueue(runRequest{Module: module}, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			active := make(map[int64]*serverRun)
+			for i, step := range tc.steps {
+				if step.finish != 0 {
+					run := active[step.finish]
+					if run == nil {
+						t.Fatalf("step %d: run %d isn't running", i, step.finish)
+					}
+					delete(active, step.finish)
+					s.finished(run)
+				}
+
+				var started []int64
+				s.mu.Lock()
+				for next := s.next(); next >= 0; next = s.next() {
+					run := s.start(next)
+					for _, other := range active {
+						if other.Module == run.Module {
+							t.Errorf("step %d: runs %d and %d of %s run together", i, other.ID, run.ID, run.Module)
+						}
+					}
+					active[run.ID] = run
+					started = append(started, run.ID)
+				}
+				s.mu.Unlock()
+				if len(active) > tc.limit {
+					t.Errorf("step %d: %d runs at once, over the limit of %d", i, len(active), tc.limit)
+				}
+				if !slices.Equal(started, step.started) {
+					t.Errorf("step %d: started runs %v, want %v", i, started, step.started)
+				}
+
+				var queued []int64
+				for _, run := range s.runs {
+					if position := s.find(run.ID).QueuePosition; position > 0 {
+						if position != len(queued)+1 {
+							t.Errorf("step %d: run %d is at position %d, want %d", i, run.ID, position, len(queued)+1)
+						}
+						queued = append(queued, run.ID)
+					}
+				}
+				if !slices.Equal(queued, step.queued) {
+					t.Errorf("step %d: queued runs %v, want %v", i, queued, step.queued)
+				}
+			}
+		})
+	}
+}
+
+func TestServerPlansWithPlanFlagDefaults(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".tfprgen.yaml")
+	os.WriteFile(configPath, []byte("upload: s3://plans-bucket/reports\n"), 0644)
+
+	// API runs planned in process build their generator from serve's flags,
+	// which don't include --upload-expires
+	pg, err := newPlanGenerator(newServeCmd(), "vpc", configPath)
+	if err != nil {
+		t.Fatalf("newPlanGenerator: %v", err)
+	}
+	if pg.UploadExpires != maxUploadExpiry || pg.MaxSectionBytes != 30000 {
+		t.Errorf("upload expiry %v and max section bytes %d, want the plan flags' defaults", pg.UploadExpires, pg.MaxSectionBytes)
+	}
+}
@@ -0,0 +1,160 @@
+package planner
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// versionSkew is a module source or provider pinned to different versions
+// across the module's states.
+type versionSkew struct {
+	Kind     string              `json:"kind"`     // "module" or "provider"
+	Name     string              `json:"name"`     // source without ?ref=, or provider address
+	Versions map[string][]string `json:"versions"` // version -> "env/region" of the states using it
+}
+
+// findVersionSkew compares the module sources and locked provider versions
+// of every terragrunt state of the module as they are in the checkout, i.e.
+// after this PR. Targeted or not, all states are compared: pinning prod to
+// an older version than staging is skew even if only staging changed.
+func (pg *PlanGenerator) findVersionSkew() ([]*versionSkew, error) {
+	dirs, err := findModuleStates(pg.Config.Runner.WorkingDir, pg.ModuleName)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*versionSkew)
+	record := func(kind, name, version, where string) {
+		key := kind + " " + name
+		skew := byName[key]
+		if skew == nil {
+			skew = &versionSkew{Kind: kind, Name: name, Versions: make(map[string][]string)}
+			byName[key] = skew
+		}
+		if !contains(skew.Versions[version], where) {
+			skew.Versions[version] = append(skew.Versions[version], where)
+		}
+	}
+	for _, dir := range dirs {
+		where := pg.stateLocation(dir)
+		state := snapshotState(dir)
+		for _, source := range state.Sources {
+			if m := sourceRefRegex.FindStringSubmatch(source); m != nil {
+				record("module", sourceRefRegex.ReplaceAllString(source, ""), m[1], where)
+			}
+		}
+		for _, provider := range state.Providers {
+			record("provider", provider.Source, provider.Version, where)
+		}
+	}
+
+	var skews []*versionSkew
+	for _, skew := range byName {
+		if len(skew.Versions) < 2 {
+			continue
+		}
+		for _, locations := range skew.Versions {
+			sort.Strings(locations)
+		}
+		skews = append(skews, skew)
+	}
+	sort.Slice(skews, func(i, j int) bool {
+		if skews[i].Kind != skews[j].Kind {
+			return skews[i].Kind == "module"
+		}
+		return skews[i].Name < skews[j].Name
+	})
+	return skews, nil
+}
+
+// findModuleStates returns the directories below root holding a
+// terragrunt.hcl with a path segment equal to moduleName, or every such
+// directory when moduleName is empty. Parent configs included by the
+// states below them aren't states themselves and are left out.
+func findModuleStates(root, moduleName string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); path != root && (strings.HasPrefix(name, ".") || snapshotSkippedPaths[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "terragrunt.hcl" && (moduleName == "" || containsSegment(filepath.Dir(path), moduleName)) {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var states []string
+	for _, dir := range dirs {
+		parent := false
+		for _, other := range dirs {
+			if other != dir && (dir == "." || strings.HasPrefix(other, dir+string(filepath.Separator))) {
+				parent = true
+				break
+			}
+		}
+		if !parent {
+			states = append(states, dir)
+		}
+	}
+	return states, nil
+}
+
+// stateLocation names a state directory by environment and region where
+// its partition's patterns can tell, by path otherwise.
+func (pg *PlanGenerator) stateLocation(dir string) string {
+	path := filepath.ToSlash(dir) + "/"
+	if p := pg.Config.PartitionFor(path); p != nil {
+		env, envOK := p.MatchEnv(path)
+		region, regionOK := p.MatchRegion(path)
+		if envOK && regionOK {
+			return env + "/" + region
+		}
+	}
+	return filepath.ToSlash(dir)
+}
+
+// skewVersions orders a skew's versions by how many states use them, so
+// the odd ones out come last.
+func skewVersions(skew *versionSkew) []string {
+	var versions []string
+	for version := range skew.Versions {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		a, b := skew.Versions[versions[i]], skew.Versions[versions[j]]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return versions[i] > versions[j]
+	})
+	return versions
+}
+
+// writeVersionSkew renders the version skew section.
+func (pg *PlanGenerator) writeVersionSkew(output *os.File) {
+	if len(pg.skew) == 0 {
+		return
+	}
+	output.WriteString("## " + pg.renderer().Icon("⚖️") + "Version skew\n\n")
+	output.WriteString("After this PR, environments of this module are pinned to different versions:\n\n")
+	for _, skew := range pg.skew {
+		output.WriteString(fmt.Sprintf("- %s `%s`\n", skew.Kind, skew.Name))
+		for _, version := range skewVersions(skew) {
+			output.WriteString(fmt.Sprintf("  - `%s`: %s\n", version, strings.Join(skew.Versions[version], ", ")))
+		}
+	}
+	output.WriteString("\n")
+}
@@ -0,0 +1,171 @@
+package planner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Snapshot pins the inputs a run planned against.
+type Snapshot struct {
+	ModuleHash string          `json:"module_sha256,omitempty"` // tree hash of terragrunt_<module>
+	States     []StateSnapshot `json:"states,omitempty"`
+}
+
+// StateSnapshot pins the inputs of a single state.
+type StateSnapshot struct {
+	Path           string         `json:"path"`
+	TerragruntHash string         `json:"terragrunt_hcl_sha256,omitempty"`
+	Sources        []string       `json:"sources,omitempty"`
+	Providers      []ProviderLock `json:"providers,omitempty"`
+}
+
+// ProviderLock is one provider entry of .terraform.lock.hcl.
+type ProviderLock struct {
+	Source  string   `json:"source"`
+	Version string   `json:"version"`
+	Hashes  []string `json:"hashes,omitempty"`
+}
+
+var (
+	sourceRegex          = regexp.MustCompile(`^\s*source\s*=\s*"([^"]+)"`)
+	lockProviderRegex    = regexp.MustCompile(`^\s*provider\s+"([^"]+)"\s*\{`)
+	lockVersionRegex     = regexp.MustCompile(`^\s*version\s*=\s*"([^"]+)"`)
+	lockHashRegex        = regexp.MustCompile(`"((?:h1|zh):[^"]+)"`)
+	snapshotSkippedPaths = map[string]bool{".terragrunt-cache": true, ".terraform": true}
+)
+
+func takeSnapshot(moduleDir string, states []string) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+
+	// Workspace-mode repos have no terragrunt_<module> directory.
+	if _, err := os.Stat(moduleDir); err == nil {
+		moduleHash, err := hashTree(moduleDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash module: %v", err)
+		}
+		snapshot.ModuleHash = moduleHash
+	}
+
+	for _, state := range states {
+		snapshot.States = append(snapshot.States, snapshotState(state))
+	}
+	return snapshot, nil
+}
+
+// snapshotState records what it can find; missing files just leave the
+// corresponding fields empty.
+func snapshotState(dir string) StateSnapshot {
+	state := StateSnapshot{Path: dir}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "terragrunt.hcl")); err == nil {
+		sum := sha256.Sum256(data)
+		state.TerragruntHash = hex.EncodeToString(sum[:])
+		for _, line := range strings.Split(string(data), "\n") {
+			if m := sourceRegex.FindStringSubmatch(line); m != nil {
+				state.Sources = append(state.Sources, m[1])
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, ".terraform.lock.hcl")); err == nil {
+		state.Providers = parseLockFile(string(data))
+	}
+
+	return state
+}
+
+func parseLockFile(content string) []ProviderLock {
+	var providers []ProviderLock
+	var current *ProviderLock
+	for _, line := range strings.Split(content, "\n") {
+		if m := lockProviderRegex.FindStringSubmatch(line); m != nil {
+			providers = append(providers, ProviderLock{Source: m[1]})
+			current = &providers[len(providers)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if m := lockVersionRegex.FindStringSubmatch(line); m != nil {
+			current.Version = m[1]
+		}
+		for _, m := range lockHashRegex.FindAllStringSubmatch(line, -1) {
+			current.Hashes = append(current.Hashes, m[1])
+		}
+	}
+	return providers
+}
+
+// hashTree hashes every file below root by relative path and content,
+// skipping terragrunt/terraform caches.
+func hashTree(root string) (string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && snapshotSkippedPaths[d.Name()] {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		rel, _ := filepath.Rel(root, path)
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(h, "%s %s\n", filepath.ToSlash(rel), hex.EncodeToString(sum[:]))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// diffSnapshots describes how the current inputs differ from a recorded
+// snapshot, one line per difference.
+func diffSnapshots(recorded, current *Snapshot) []string {
+	var diffs []string
+	if recorded.ModuleHash != current.ModuleHash {
+		diffs = append(diffs, "module sources changed")
+	}
+
+	now := make(map[string]StateSnapshot)
+	for _, state := range current.States {
+		now[state.Path] = state
+	}
+	for _, was := range recorded.States {
+		is := now[was.Path]
+		if was.TerragruntHash != is.TerragruntHash {
+			diffs = append(diffs, fmt.Sprintf("%s: terragrunt.hcl changed", was.Path))
+		}
+		if strings.Join(was.Sources, ",") != strings.Join(is.Sources, ",") {
+			diffs = append(diffs, fmt.Sprintf("%s: module source %v → %v", was.Path, was.Sources, is.Sources))
+		}
+		versions := make(map[string]string)
+		for _, p := range is.Providers {
+			versions[p.Source] = p.Version
+		}
+		for _, p := range was.Providers {
+			if versions[p.Source] != p.Version {
+				diffs = append(diffs, fmt.Sprintf("%s: provider %s %s → %s", was.Path, p.Source, p.Version, versions[p.Source]))
+			}
+		}
+	}
+	return diffs
+}
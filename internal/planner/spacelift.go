@@ -0,0 +1,224 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SpaceliftConfig plans the targeted states of Spacelift stacks in proposed
+// runs on the commit being planned, which has to be pushed.
+type SpaceliftConfig struct {
+	// Account is the Spacelift account, served at
+	// <account>.app.spacelift.io, or its URL; SPACELIFT_API_KEY_ENDPOINT
+	// by default.
+	Account string `yaml:"account"`
+	// Stacks map states to stack IDs; the first matching a state plans it.
+	Stacks []*RemoteStack `yaml:"stacks"`
+}
+
+func (c SpaceliftConfig) validate() error {
+	return validateRemoteStacks("stacks", c.Stacks)
+}
+
+// spaceliftDone are the states a proposed run ends in.
+var spaceliftDone = []string{"FINISHED", "FAILED", "STOPPED", "CANCELED", "DISCARDED", "SKIPPED"}
+
+// spaceliftClient is the slice of Spacelift's GraphQL API proposed runs
+// need.
+type spaceliftClient struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+// spaceliftClient is the run's Spacelift client, created on first use.
+func (pg *PlanGenerator) spaceliftClient() (*spaceliftClient, error) {
+	pg.remoteMu.Lock()
+	defer pg.remoteMu.Unlock()
+	if pg.spacelift == nil {
+		client, err := newSpaceliftClient(pg.Config.Spacelift)
+		if err != nil {
+			return nil, err
+		}
+		pg.spacelift = client
+	}
+	return pg.spacelift, nil
+}
+
+// newSpaceliftClient authenticates like spacectl: with SPACELIFT_API_TOKEN,
+// else the API key in SPACELIFT_API_KEY_ID and SPACELIFT_API_KEY_SECRET.
+func newSpaceliftClient(cfg SpaceliftConfig) (*spaceliftClient, error) {
+	account := cfg.Account
+	if account == "" {
+		account = os.Getenv("SPACELIFT_API_KEY_ENDPOINT")
+	}
+	if account == "" {
+		return nil, fmt.Errorf("the account is unknown: set spacelift.account or SPACELIFT_API_KEY_ENDPOINT")
+	}
+	if !strings.Contains(account, "://") {
+		account = "https://" + account + ".app.spacelift.io"
+	}
+	client := &spaceliftClient{
+		url:   strings.TrimSuffix(account, "/"),
+		token: os.Getenv("SPACELIFT_API_TOKEN"),
+		http:  &http.Client{Timeout: 60 * time.Second},
+	}
+	if client.token != "" {
+		return client, nil
+	}
+	id, secret := os.Getenv("SPACELIFT_API_KEY_ID"), os.Getenv("SPACELIFT_API_KEY_SECRET")
+	if id == "" || secret == "" {
+		return nil, fmt.Errorf("set SPACELIFT_API_TOKEN, or SPACELIFT_API_KEY_ID and SPACELIFT_API_KEY_SECRET")
+	}
+	var user struct {
+		APIKeyUser struct {
+			JWT string `json:"jwt"`
+		} `json:"apiKeyUser"`
+	}
+	if err := client.query(`mutation($id: ID!, $secret: String!) { apiKeyUser(id: $id, secret: $secret) { jwt } }`,
+		map[string]any{"id": id, "secret": secret}, &user); err != nil {
+		return nil, fmt.Errorf("exchanging the API key: %v", err)
+	}
+	client.token = user.APIKeyUser.JWT
+	return client, nil
+}
+
+// query runs a GraphQL query or mutation, decoding its data into result.
+func (c *spaceliftClient) query(query string, variables map[string]any, result any) error {
+	payload, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.url+"/graphql", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST /graphql: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("POST /graphql: unexpected response: %.200s", data)
+	}
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("%s", response.Errors[0].Message)
+	}
+	return json.Unmarshal(response.Data, result)
+}
+
+// planSpacelift plans state in a proposed run of stack on the current
+// commit and returns the run's planning logs as the state's output. Runs
+// are stopped with the state.
+func (pg *PlanGenerator) planSpacelift(ctx context.Context, state *State, stack string) ([]byte, error) {
+	client, err := pg.spaceliftClient()
+	if err != nil {
+		return nil, err
+	}
+	commit := gitHead()
+	if commit == "" {
+		return nil, fmt.Errorf("stack %s: the commit to plan is unknown outside a git repository", stack)
+	}
+	var trigger struct {
+		RunTrigger struct {
+			ID string `json:"id"`
+		} `json:"runTrigger"`
+	}
+	if err := client.query(`mutation($stack: ID!, $sha: String) { runTrigger(stack: $stack, commitSha: $sha, runType: PROPOSED) { id } }`,
+		map[string]any{"stack": stack, "sha": commit}, &trigger); err != nil {
+		return nil, fmt.Errorf("stack %s: triggering a proposed run: %v", stack, err)
+	}
+	run := trigger.RunTrigger.ID
+	runURL := fmt.Sprintf("%s/stack/%s/run/%s", client.url, stack, run)
+	pg.recordRemoteRun(state, "Spacelift", runURL)
+
+	vars := map[string]any{"stack": stack, "run": run}
+	var status string
+	err = waitRemote(ctx, func() (bool, error) {
+		var result struct {
+			Stack *struct {
+				Run *struct {
+					State string `json:"state"`
+				} `json:"run"`
+			} `json:"stack"`
+		}
+		if err := client.query(`query($stack: ID!, $run: ID!) { stack(id: $stack) { run(id: $run) { state } } }`, vars, &result); err != nil {
+			return false, err
+		}
+		if result.Stack == nil || result.Stack.Run == nil {
+			return false, fmt.Errorf("the run is gone")
+		}
+		status = result.Stack.Run.State
+		return slices.Contains(spaceliftDone, status), nil
+	})
+	if ctx.Err() != nil {
+		client.query(`mutation($stack: ID!, $run: ID!) { runStop(stack: $stack, run: $run) { id } }`, vars, &struct{}{})
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run %s: %v", runURL, err)
+	}
+	log, err := client.planningLogs(stack, run)
+	if err != nil {
+		return nil, fmt.Errorf("run %s: reading the planning logs: %v", runURL, err)
+	}
+	output := remotePlanOutput(log)
+	if status != "FINISHED" {
+		return nil, pg.commandError(fmt.Errorf("run %s: %s", runURL, strings.ToLower(status)), state.String(), output)
+	}
+	return output, nil
+}
+
+// planningLogs reads the logs of a run's planning phase, page by page.
+func (c *spaceliftClient) planningLogs(stack, run string) ([]byte, error) {
+	var b bytes.Buffer
+	var token *string
+	for {
+		var result struct {
+			RunLogs struct {
+				Finished bool `json:"finished"`
+				Messages []struct {
+					Message string `json:"message"`
+				} `json:"messages"`
+				NextToken *string `json:"nextToken"`
+			} `json:"runLogs"`
+		}
+		err := c.query(`query($stack: ID!, $run: ID!, $token: String) { runLogs(stack: $stack, run: $run, state: PLANNING, token: $token) { finished messages { message } nextToken } }`,
+			map[string]any{"stack": stack, "run": run, "token": token}, &result)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range result.RunLogs.Messages {
+			b.WriteString(strings.TrimSuffix(m.Message, "\n") + "\n")
+		}
+		if result.RunLogs.Finished || result.RunLogs.NextToken == nil {
+			return b.Bytes(), nil
+		}
+		token = result.RunLogs.NextToken
+	}
+}
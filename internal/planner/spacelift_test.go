@@ -0,0 +1,53 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPlanStateSpacelift(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		queries = append(queries, request.Query)
+		switch {
+		case strings.Contains(request.Query, "runTrigger"):
+			io.WriteString(w, `{"data":{"runTrigger":{"id":"01RUN"}}}`)
+		case strings.Contains(request.Query, "runLogs"):
+			io.WriteString(w, `{"data":{"runLogs":{"finished":true,"messages":[{"message":"\u001b[1m  # aws_eks_cluster.this\u001b[0m will be updated in-place"},{"message":"Plan: 0 to add, 1 to change, 0 to destroy."}]}}}`)
+		default:
+			io.WriteString(w, `{"data":{"stack":{"run":{"state":"FINISHED"}}}}`)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("SPACELIFT_API_TOKEN", "jwt")
+
+	pg := newTestGenerator(t, &fakeExecutor{err: errors.New("planned locally")})
+	pg.Config.Spacelift = SpaceliftConfig{Account: server.URL, Stacks: []*RemoteStack{{States: "terragrunt_eks/**", Name: "eks-{{.Env}}"}}}
+	if err := pg.Config.Spacelift.validate(); err != nil {
+		t.Fatal(err)
+	}
+	state := &State{Path: "terragrunt_eks/organizations/staging/us-east-1"}
+	output, err := pg.planState(context.Background(), pg.Config.Partitions[0], state)
+	if err != nil {
+		t.Fatalf("planState: %v", err)
+	}
+	if want := "  # aws_eks_cluster.this will be updated in-place\nPlan: 0 to add, 1 to change, 0 to destroy.\n"; string(output) != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+	if !strings.Contains(queries[0], "runType: PROPOSED") {
+		t.Errorf("first query = %q, want a proposed run trigger", queries[0])
+	}
+	if len(pg.remoteRuns) != 1 || pg.remoteRuns[0].URL != server.URL+"/stack/eks-staging/run/01RUN" {
+		t.Errorf("remote runs = %+v", pg.remoteRuns)
+	}
+}
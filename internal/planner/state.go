@@ -0,0 +1,76 @@
+package planner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// State is a single terraform state planned in targeted mode.
+type State struct {
+	Path      string `json:"path"`
+	Workspace string `json:"workspace,omitempty"`
+	// Env and Region override what the parser would extract from the
+	// output, for layouts where the path doesn't encode them.
+	Env    string `json:"env,omitempty"`
+	Region string `json:"region,omitempty"`
+	// PlanFile is the saved binary plan (--save-plans), relative to the
+	// run's output directory.
+	PlanFile string `json:"plan_file,omitempty"`
+}
+
+// String identifies the state in messages and partition matching.
+func (s *State) String() string {
+	if s.Workspace != "" {
+		return s.Path + "@" + s.Workspace
+	}
+	return s.Path
+}
+
+// Header is the line written before the state's output in a plans file.
+func (s *State) Header() string {
+	header := fmt.Sprintf("%s %s/", stateHeader, strings.TrimSuffix(s.Path, "/"))
+	if s.Workspace != "" {
+		header += " workspace=" + s.Workspace
+	}
+	if s.Env != "" {
+		header += " env=" + s.Env
+	}
+	if s.Region != "" {
+		header += " region=" + s.Region
+	}
+	return header
+}
+
+// planFileName is where --save-plans writes the state's binary plan.
+func (s *State) planFileName() string {
+	name := strings.ReplaceAll(strings.Trim(s.Path, "/"), "/", "__")
+	if s.Workspace != "" {
+		name += "@" + s.Workspace
+	}
+	return filepath.Join("tfplans", name+".tfplan")
+}
+
+// planJSONName is where --save-plans writes the JSON rendering of the
+// state's binary plan.
+func (s *State) planJSONName() string {
+	return strings.TrimSuffix(s.PlanFile, ".tfplan") + ".json"
+}
+
+// statesFromPaths wraps plain state directories.
+func statesFromPaths(paths []string) []*State {
+	states := make([]*State, len(paths))
+	for i, path := range paths {
+		states[i] = &State{Path: path}
+	}
+	return states
+}
+
+// statePaths returns the directories of states, for snapshots.
+func statePaths(states []*State) []string {
+	paths := make([]string, len(states))
+	for i, state := range states {
+		paths[i] = state.Path
+	}
+	return paths
+}
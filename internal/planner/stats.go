@@ -0,0 +1,279 @@
+package planner
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// historyStats aggregates the recorded runs of a time window, for capacity
+// planning.
+type historyStats struct {
+	Since        time.Time      `json:"since"`
+	Until        time.Time      `json:"until"`
+	Runs         int            `json:"runs"`
+	Weekly       []*weeklyRuns  `json:"weekly"`
+	Modules      []*moduleStats `json:"modules"`
+	Environments []*envStats    `json:"environments"`
+}
+
+// weeklyRuns counts one module's runs in one week.
+type weeklyRuns struct {
+	Week   string `json:"week"` // the Monday starting it, YYYY-MM-DD
+	Module string `json:"module"`
+	Runs   int    `json:"runs"`
+	Failed int    `json:"failed"`
+}
+
+// moduleStats sums up one module's runs. Drift is counted against full
+// runs, the only ones drift checks make.
+type moduleStats struct {
+	Module      string        `json:"module"`
+	Runs        int           `json:"runs"`
+	Failed      int           `json:"failed"`
+	FullRuns    int           `json:"full_runs"`
+	Drift       int           `json:"drift"`
+	AvgDuration time.Duration `json:"avg_duration_ns"`
+	MaxDuration time.Duration `json:"max_duration_ns"`
+	LastRun     time.Time     `json:"last_run"`
+}
+
+// envStats counts how often one environment's plans changed something.
+type envStats struct {
+	Partition string `json:"partition"`
+	Env       string `json:"env"`
+	Runs      int    `json:"runs"`         // runs changing it
+	Plans     int    `json:"region_plans"` // region plans changing it
+}
+
+func newStatsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats [module_name]",
+		Short: "Aggregate the run history: runs per week, durations, changed environments, drift",
+		Long: `Aggregates the runs recorded in the history database (see history) over
+the last --weeks weeks, or --since/--until:
+
+  - runs per module per week, and how many failed
+  - per module: runs, failures, average and longest run, and drift found
+    by full runs (drift checks)
+  - the environments whose plans change most often
+
+Examples:
+  terraform-pr-generator stats
+  terraform-pr-generator stats s3_malware_protection --weeks 4
+  terraform-pr-generator stats --since 2025-01-01 --until 2025-03-31 --format json`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeModules(1),
+		Run:               runStats,
+	}
+	cmd.Flags().Int("weeks", 12, "Aggregate the runs of this many weeks, the current one included")
+	cmd.Flags().String("since", "", "Only runs started on or after this date (YYYY-MM-DD, local time; overrides --weeks)")
+	cmd.Flags().String("until", "", "Only runs started before the end of this date (YYYY-MM-DD, local time)")
+	cmd.Flags().String("format", "table", "Output format: table or json")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"table", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func runStats(cmd *cobra.Command, args []string) {
+	weeks, _ := cmd.Flags().GetInt("weeks")
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+	format, _ := cmd.Flags().GetString("format")
+	if format != "table" && format != "json" {
+		errorColor.Printf("❌ Error: invalid format %q (supported: table, json)\n", format)
+		os.Exit(1)
+	}
+
+	var filter historyFilter
+	if len(args) > 0 {
+		filter.Module = args[0]
+	}
+	if err := filter.setDates(since, until); err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	if filter.Since.IsZero() && weeks > 0 {
+		filter.Since = weekStart(time.Now()).AddDate(0, 0, -7*(weeks-1))
+	}
+
+	db, err := openHistory()
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	stats, err := aggregateHistory(db, filter)
+	if err != nil {
+		errorColor.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if format == "json" {
+		data, _ := json.MarshalIndent(stats, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	window := "all recorded runs"
+	if !stats.Since.IsZero() {
+		window = "runs since " + stats.Since.Format("2006-01-02")
+	}
+	infoColor.Printf("📊 Run statistics: %s (%d)\n", window, stats.Runs)
+	if stats.Runs == 0 {
+		fmt.Fprintln(console, "No recorded runs match.")
+		return
+	}
+	printStats(stats)
+}
+
+// weekStart is the local midnight of the Monday starting t's week.
+func weekStart(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// aggregateHistory computes the statistics of the runs filter selects.
+func aggregateHistory(db *sql.DB, filter historyFilter) (*historyStats, error) {
+	stats := &historyStats{Since: filter.Since, Until: filter.Until, Weekly: []*weeklyRuns{}, Modules: []*moduleStats{}, Environments: []*envStats{}}
+	where, params := " WHERE 1 = 1", []any{}
+	if filter.Module != "" {
+		where += " AND r.module = ?"
+		params = append(params, filter.Module)
+	}
+	if !filter.Since.IsZero() {
+		where += " AND r.started_at >= ?"
+		params = append(params, filter.Since.Unix())
+	}
+	if !filter.Until.IsZero() {
+		where += " AND r.started_at < ?"
+		params = append(params, filter.Until.Unix())
+	}
+
+	rows, err := db.Query("SELECT r.module, r.started_at, r.duration_ms, r.status, r.targeted FROM runs r"+where, params...)
+	if err != nil {
+		return nil, err
+	}
+	weekly := make(map[string]*weeklyRuns)
+	modules := make(map[string]*moduleStats)
+	total := make(map[string]time.Duration)
+	for rows.Next() {
+		var module, status string
+		var started, durationMs int64
+		var targeted bool
+		if err := rows.Scan(&module, &started, &durationMs, &status, &targeted); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		startedAt := time.Unix(started, 0)
+		duration := time.Duration(durationMs) * time.Millisecond
+		stats.Runs++
+
+		week := weekStart(startedAt).Format("2006-01-02")
+		w := weekly[week+"\x00"+module]
+		if w == nil {
+			w = &weeklyRuns{Week: week, Module: module}
+			weekly[week+"\x00"+module] = w
+			stats.Weekly = append(stats.Weekly, w)
+		}
+		m := modules[module]
+		if m == nil {
+			m = &moduleStats{Module: module}
+			modules[module] = m
+			stats.Modules = append(stats.Modules, m)
+		}
+		w.Runs++
+		m.Runs++
+		if status == "failed" {
+			w.Failed++
+			m.Failed++
+		}
+		if !targeted {
+			m.FullRuns++
+		}
+		if status == "drift" {
+			m.Drift++
+		}
+		total[module] += duration
+		if duration > m.MaxDuration {
+			m.MaxDuration = duration
+		}
+		if startedAt.After(m.LastRun) {
+			m.LastRun = startedAt
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, m := range stats.Modules {
+		m.AvgDuration = (total[m.Module] / time.Duration(m.Runs)).Round(time.Second)
+	}
+	sort.Slice(stats.Weekly, func(i, j int) bool {
+		if stats.Weekly[i].Week != stats.Weekly[j].Week {
+			return stats.Weekly[i].Week < stats.Weekly[j].Week
+		}
+		return stats.Weekly[i].Module < stats.Weekly[j].Module
+	})
+	sort.Slice(stats.Modules, func(i, j int) bool {
+		if stats.Modules[i].Runs != stats.Modules[j].Runs {
+			return stats.Modules[i].Runs > stats.Modules[j].Runs
+		}
+		return stats.Modules[i].Module < stats.Modules[j].Module
+	})
+
+	rows, err = db.Query(`SELECT s.partition, s.env, COUNT(DISTINCT s.run_id), COUNT(*)
+		FROM results s JOIN runs r ON r.id = s.run_id`+where+`
+		AND COALESCE(s.adds, 0) + COALESCE(s.changes, 0) + COALESCE(s.destroys, 0) > 0
+		GROUP BY s.partition, s.env ORDER BY 3 DESC, 4 DESC, s.partition, s.env`, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		env := &envStats{}
+		if err := rows.Scan(&env.Partition, &env.Env, &env.Runs, &env.Plans); err != nil {
+			return nil, err
+		}
+		stats.Environments = append(stats.Environments, env)
+	}
+	return stats, rows.Err()
+}
+
+// printStats prints the statistics as tables.
+func printStats(stats *historyStats) {
+	// The tables themselves are data and go to stdout
+	fmt.Println("\nRuns per week")
+	var lines [][]string
+	for _, w := range stats.Weekly {
+		lines = append(lines, []string{w.Week, w.Module, strconv.Itoa(w.Runs), strconv.Itoa(w.Failed)})
+	}
+	printTable([]string{"WEEK", "MODULE", "RUNS", "FAILED"}, lines)
+
+	fmt.Println("\nModules")
+	lines = nil
+	for _, m := range stats.Modules {
+		drift := "-"
+		if m.FullRuns > 0 {
+			drift = fmt.Sprintf("%d/%d full runs", m.Drift, m.FullRuns)
+		}
+		lines = append(lines, []string{m.Module, strconv.Itoa(m.Runs), strconv.Itoa(m.Failed),
+			m.AvgDuration.String(), m.MaxDuration.Round(time.Second).String(), drift, m.LastRun.Format("2006-01-02")})
+	}
+	printTable([]string{"MODULE", "RUNS", "FAILED", "AVG DURATION", "LONGEST", "DRIFT", "LAST RUN"}, lines)
+
+	if len(stats.Environments) == 0 {
+		return
+	}
+	fmt.Println("\nMost changed environments")
+	lines = nil
+	for _, env := range stats.Environments {
+		lines = append(lines, []string{env.Partition, env.Env, strconv.Itoa(env.Runs), strconv.Itoa(env.Plans)})
+	}
+	printTable([]string{"PARTITION", "ENV", "RUNS CHANGING IT", "REGION PLANS"}, lines)
+}
@@ -0,0 +1,73 @@
+package planner
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAggregateHistory(t *testing.T) {
+	t.Setenv(historyDBEnv, filepath.Join(t.TempDir(), "history.db"))
+	monday := time.Date(2025, 6, 2, 10, 0, 0, 0, time.Local)
+	changed := []*PartitionResult{{
+		Partition: &Partition{Name: "commercial"},
+		Environments: []*Environment{{
+			Name:    "staging",
+			Regions: []string{"us-east-1", "us-west-2"},
+			Plans: map[string]string{
+				"us-east-1": "Plan: 1 to add, 0 to change, 0 to destroy.",
+				"us-west-2": "No changes. Your infrastructure matches the configuration.",
+			},
+		}},
+	}}
+	for _, entry := range []*historyEntry{
+		{Module: "vpc", StartedAt: monday, Duration: 10 * time.Second, Status: "succeeded", Targeted: true},
+		{Module: "vpc", StartedAt: monday.AddDate(0, 0, 4), Duration: 30 * time.Second, Status: "drift"},
+		{Module: "vpc", StartedAt: monday.AddDate(0, 0, 7), Duration: 20 * time.Second, Status: "failed", Targeted: true},
+		{Module: "dns", StartedAt: monday.AddDate(0, 0, 8), Duration: time.Second, Status: "succeeded"},
+	} {
+		if err := saveHistory(entry, changed); err != nil {
+			t.Fatalf("saveHistory: %v", err)
+		}
+	}
+
+	db, err := openHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stats, err := aggregateHistory(db, historyFilter{})
+	if err != nil {
+		t.Fatalf("aggregateHistory: %v", err)
+	}
+	if stats.Runs != 4 || len(stats.Weekly) != 3 {
+		t.Fatalf("got %d runs in %d weekly rows, want 4 in 3", stats.Runs, len(stats.Weekly))
+	}
+	if w := stats.Weekly[0]; w.Week != "2025-06-02" || w.Module != "vpc" || w.Runs != 2 {
+		t.Errorf("first week = %+v", w)
+	}
+	vpc := stats.Modules[0]
+	if vpc.Module != "vpc" || vpc.Runs != 3 || vpc.Failed != 1 || vpc.FullRuns != 1 || vpc.Drift != 1 {
+		t.Errorf("vpc = %+v", vpc)
+	}
+	if vpc.AvgDuration != 20*time.Second || vpc.MaxDuration != 30*time.Second {
+		t.Errorf("vpc durations = %v avg, %v max", vpc.AvgDuration, vpc.MaxDuration)
+	}
+	if len(stats.Environments) != 1 || stats.Environments[0].Runs != 4 || stats.Environments[0].Plans != 4 {
+		t.Errorf("environments = %+v", stats.Environments)
+	}
+
+	stats, err = aggregateHistory(db, historyFilter{Module: "dns"})
+	if err != nil || stats.Runs != 1 || stats.Environments[0].Runs != 1 {
+		t.Errorf("dns: %+v, %v", stats, err)
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	for day, want := range map[int]int{1: 26, 2: 2, 8: 2, 9: 9} {
+		got := weekStart(time.Date(2025, 6, day, 23, 0, 0, 0, time.Local))
+		if got.Day() != want || got.Weekday() != time.Monday || got.Hour() != 0 {
+			t.Errorf("weekStart(June %d) = %v, want the %dth", day, got, want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// defaultStatusContext names the commit statuses, one per partition
+	// below it, e.g. terraform-pr-generator/plan/commercial.
+	defaultStatusContext = "terraform-pr-generator/plan"
+	// githubStatusDescriptionLimit is the longest status description
+	// GitHub accepts.
+	githubStatusDescriptionLimit = 140
+)
+
+// CommitStatusConfig sets a GitHub commit status per partition, so branch
+// protection can require clean plans before merging.
+type CommitStatusConfig struct {
+	// Enabled sets the statuses on every run, like --github-status.
+	Enabled bool `yaml:"enabled"`
+	// Context is what the statuses are named below, the partition's name
+	// being appended; terraform-pr-generator/plan by default.
+	Context string `yaml:"context"`
+	// MaxDestroys fails a partition's status when its plans destroy more
+	// resources than this; 0 allows any.
+	MaxDestroys int `yaml:"max_destroys"`
+}
+
+func (c CommitStatusConfig) validate() error {
+	if c.MaxDestroys < 0 {
+		return fmt.Errorf("max_destroys must be 0 (no limit) or more, got %d", c.MaxDestroys)
+	}
+	return nil
+}
+
+// commitStatus is the state of one partition's status: pending, success,
+// failure or error.
+type commitStatus struct {
+	Context     string
+	State       string
+	Description string
+}
+
+// commitStatuses sets the partitions' statuses on the commit being
+// planned: pending while plans run, then their outcome. A nil
+// *commitStatuses does nothing, and API failures only warn.
+type commitStatuses struct {
+	client    *githubClient
+	sha       string
+	targetURL string
+}
+
+// startStatuses marks every partition's status pending.
+func (pg *PlanGenerator) startStatuses() error {
+	client, err := pg.githubClient()
+	if err != nil {
+		return fmt.Errorf("--github-status: %v", err)
+	}
+	sha := statusSHA()
+	if sha == "" {
+		return fmt.Errorf("--github-status: commit unknown: not in a git repository and GITHUB_SHA is not set")
+	}
+	pg.statuses = &commitStatuses{client: client, sha: sha, targetURL: workflowRunURL()}
+	for _, p := range pg.Config.Partitions {
+		pg.statuses.set(&commitStatus{Context: pg.statusContext(p), State: "pending", Description: pg.ModuleName + ": planning"})
+	}
+	if pg.Verbose {
+		fmt.Fprintf(console, "🚦 Setting commit statuses on %.12s\n", sha)
+	}
+	return nil
+}
+
+// finishStatuses sets every partition's status to the outcome of the run.
+func (pg *PlanGenerator) finishStatuses(runErr error) {
+	if pg.statuses == nil {
+		return
+	}
+	for _, p := range pg.Config.Partitions {
+		pg.statuses.set(pg.partitionStatus(p, runErr))
+	}
+}
+
+func (pg *PlanGenerator) statusContext(p *Partition) string {
+	context := pg.Config.CommitStatus.Context
+	if context == "" {
+		context = defaultStatusContext
+	}
+	return strings.TrimSuffix(context, "/") + "/" + p.Name
+}
+
+// partitionStatus judges a partition's plans: a failure when a state
+// failed, a region plan errored before its summary or the destroys exceed
+// commit_status.max_destroys, an error when the run failed otherwise, and
+// a success with the plan totals when they're clean.
+func (pg *PlanGenerator) partitionStatus(p *Partition, runErr error) *commitStatus {
+	status := &commitStatus{Context: pg.statusContext(p)}
+	var result *PartitionResult
+	for _, r := range pg.results {
+		if r.Partition == p {
+			result = r
+		}
+	}
+	var failed []string
+	for _, f := range pg.failures {
+		if f.Partition == p {
+			failed = append(failed, f.Name)
+		}
+	}
+	var counts PlanCounts
+	incomplete := 0
+	if result != nil {
+		for _, env := range result.Environments {
+			for _, region := range env.Regions {
+				if c, ok := ParsePlanCounts(env.Plans[region]); ok {
+					counts.Add += c.Add
+					counts.Change += c.Change
+					counts.Destroy += c.Destroy
+				}
+				if env.Incomplete[region] {
+					incomplete++
+				}
+			}
+		}
+	}
+
+	maxDestroys := pg.Config.CommitStatus.MaxDestroys
+	switch {
+	case len(failed) > 0:
+		status.State = "failure"
+		status.Description = fmt.Sprintf("%d state(s) failed: %s", len(failed), strings.Join(failed, ", "))
+	case incomplete > 0:
+		status.State = "failure"
+		status.Description = fmt.Sprintf("%d region plan(s) errored before their summary", incomplete)
+	case maxDestroys > 0 && counts.Destroy > maxDestroys:
+		status.State = "failure"
+		status.Description = fmt.Sprintf("%d to destroy, over the limit of %d", counts.Destroy, maxDestroys)
+	case runErr != nil:
+		status.State = "error"
+		status.Description = "Plan generation failed: " + runErr.Error()
+	case counts == PlanCounts{}:
+		status.State = "success"
+		status.Description = "No changes"
+	default:
+		status.State = "success"
+		status.Description = fmt.Sprintf("%d to add, %d to change, %d to destroy", counts.Add, counts.Change, counts.Destroy)
+	}
+	status.Description = pg.ModuleName + ": " + status.Description
+	if runes := []rune(status.Description); len(runes) > githubStatusDescriptionLimit {
+		status.Description = string(runes[:githubStatusDescriptionLimit-1]) + "…"
+	}
+	return status
+}
+
+func (s *commitStatuses) set(status *commitStatus) {
+	if s == nil {
+		return
+	}
+	if err := s.client.createStatus(s.sha, status, s.targetURL); err != nil {
+		warningColor.Printf("⚠️  Setting the %s commit status failed: %v\n", status.Context, err)
+	}
+}
+
+// statusSHA is the commit the statuses belong to: the pull request's head
+// rather than the merge commit pull_request workflows check out, else
+// GITHUB_SHA or HEAD.
+func statusSHA() string {
+	if path := os.Getenv("GITHUB_EVENT_PATH"); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			var event struct {
+				PullRequest struct {
+					Head struct {
+						SHA string `json:"sha"`
+					} `json:"head"`
+				} `json:"pull_request"`
+			}
+			if json.Unmarshal(data, &event) == nil && event.PullRequest.Head.SHA != "" {
+				return event.PullRequest.Head.SHA
+			}
+		}
+	}
+	if sha := os.Getenv("GITHUB_SHA"); sha != "" {
+		return sha
+	}
+	return gitHead()
+}
+
+// workflowRunURL links the statuses to the GitHub Actions run setting
+// them, "" outside one.
+func workflowRunURL() string {
+	runID, repo := os.Getenv("GITHUB_RUN_ID"), os.Getenv("GITHUB_REPOSITORY")
+	if runID == "" || repo == "" {
+		return ""
+	}
+	server := os.Getenv("GITHUB_SERVER_URL")
+	if server == "" {
+		server = "https://github.com"
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", strings.TrimSuffix(server, "/"), repo, runID)
+}
@@ -0,0 +1,39 @@
+package planner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPartitionStatus(t *testing.T) {
+	commercial, govcloud := &Partition{Name: "commercial"}, &Partition{Name: "govcloud"}
+	pg := &PlanGenerator{
+		ModuleName: "s3mod",
+		Config:     &Config{Partitions: []*Partition{commercial, govcloud}, CommitStatus: CommitStatusConfig{MaxDestroys: 2}},
+		results: append(
+			testResults(commercial, testEnvironment("production", map[string]string{"us-east-1": "Plan: 1 to add, 0 to change, 3 to destroy."})),
+			testResults(govcloud, testEnvironment("govcloud-staging", map[string]string{"us-gov-west-1": "Plan: 2 to add, 1 to change, 0 to destroy."}))...,
+		),
+	}
+	for _, tt := range []struct {
+		p     *Partition
+		err   error
+		state string
+		desc  string
+	}{
+		{commercial, nil, "failure", "s3mod: 3 to destroy, over the limit of 2"},
+		{govcloud, nil, "success", "s3mod: 2 to add, 1 to change, 0 to destroy"},
+		{govcloud, errors.New("warnings found"), "error", "s3mod: Plan generation failed: warnings found"},
+	} {
+		status := pg.partitionStatus(tt.p, tt.err)
+		if status.Context != "terraform-pr-generator/plan/"+tt.p.Name || status.State != tt.state || status.Description != tt.desc {
+			t.Errorf("%s (%v): got %+v, want %s %q", tt.p.Name, tt.err, status, tt.state, tt.desc)
+		}
+	}
+
+	pg.failures = []*stateFailure{{Partition: govcloud, Name: "govcloud-staging/us-gov-west-1", Err: errors.New("boom")}}
+	if status := pg.partitionStatus(govcloud, nil); status.State != "failure" || !strings.Contains(status.Description, "1 state(s) failed") {
+		t.Errorf("failed state: got %+v", status)
+	}
+}
@@ -0,0 +1,128 @@
+package planner
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The cloud backends transfer and sign through the provider's CLI (aws,
+// gcloud or az), so its usual credentials setup applies.
+
+// s3Storage is an s3://bucket/prefix destination.
+type s3Storage struct{ Bucket, Prefix string }
+
+// gcsStorage is a gs://bucket/prefix destination.
+type gcsStorage struct{ Bucket, Prefix string }
+
+// azureStorage is an az://account/container/prefix Blob Storage
+// destination.
+type azureStorage struct{ Account, Container, Prefix string }
+
+func newS3Storage(u *url.URL) (Storage, error) {
+	return &s3Storage{Bucket: u.Host, Prefix: strings.Trim(u.Path, "/")}, nil
+}
+
+func newGCSStorage(u *url.URL) (Storage, error) {
+	return &gcsStorage{Bucket: u.Host, Prefix: strings.Trim(u.Path, "/")}, nil
+}
+
+func newAzureStorage(u *url.URL) (Storage, error) {
+	container, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if container == "" {
+		return nil, fmt.Errorf("missing container (az://account/container/prefix)")
+	}
+	return &azureStorage{Account: u.Host, Container: container, Prefix: prefix}, nil
+}
+
+func (s *s3Storage) location(dir, rel string) string {
+	return fmt.Sprintf("s3://%s/%s", s.Bucket, runKey(s.Prefix, dir, rel))
+}
+
+func (s *s3Storage) upload(dir string) error {
+	return runStorageCLI([]string{"aws", "s3", "sync", "--only-show-errors", dir, s.location(dir, "")})
+}
+
+func (s *s3Storage) presign(dir, rel string, expires time.Duration) (string, error) {
+	return storageCLIOutput("signing "+rel, []string{"aws", "s3", "presign", s.location(dir, rel), "--expires-in", fmt.Sprint(int(expires.Seconds()))})
+}
+
+func (s *s3Storage) expiring() bool { return true }
+
+func (s *gcsStorage) location(dir, rel string) string {
+	return fmt.Sprintf("gs://%s/%s", s.Bucket, runKey(s.Prefix, dir, rel))
+}
+
+func (s *gcsStorage) upload(dir string) error {
+	return runStorageCLI([]string{"gcloud", "storage", "rsync", "--recursive", dir, s.location(dir, "")})
+}
+
+func (s *gcsStorage) presign(dir, rel string, expires time.Duration) (string, error) {
+	return storageCLIOutput("signing "+rel, []string{"gcloud", "storage", "sign-url", s.location(dir, rel), fmt.Sprintf("--duration=%ds", int(expires.Seconds())), "--format=value(signed_url)"})
+}
+
+func (s *gcsStorage) expiring() bool { return true }
+
+func (s *azureStorage) location(dir, rel string) string {
+	return fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", s.Account, s.Container, runKey(s.Prefix, dir, rel))
+}
+
+func (s *azureStorage) upload(dir string) error {
+	return runStorageCLI([]string{"az", "storage", "blob", "upload-batch", "--account-name", s.Account,
+		"--destination", s.Container, "--destination-path", strings.TrimSuffix(runKey(s.Prefix, dir, ""), "/"),
+		"--source", dir, "--overwrite", "--only-show-errors"})
+}
+
+// presign generates a read-only SAS URL. With AZURE_STORAGE_AUTH_MODE=login
+// there is no account key to sign with, so it's a user delegation SAS.
+func (s *azureStorage) presign(dir, rel string, expires time.Duration) (string, error) {
+	argv := []string{"az", "storage", "blob", "generate-sas", "--account-name", s.Account,
+		"--container-name", s.Container, "--name", runKey(s.Prefix, dir, rel), "--permissions", "r",
+		"--expiry", time.Now().Add(expires).UTC().Format("2006-01-02T15:04Z"), "--https-only", "--full-uri", "--output", "tsv"}
+	if os.Getenv("AZURE_STORAGE_AUTH_MODE") == "login" {
+		argv = append(argv, "--as-user")
+	}
+	return storageCLIOutput("signing "+rel, argv)
+}
+
+func (s *azureStorage) expiring() bool { return true }
+
+// runKey is the object key of a file of the run directory dir below
+// prefix, with a trailing slash for the folder itself.
+func runKey(prefix, dir, rel string) string {
+	key := path.Join(prefix, filepath.Base(dir), rel)
+	if rel == "" {
+		key += "/"
+	}
+	return strings.TrimPrefix(key, "/")
+}
+
+// runStorageCLI runs a storage provider's CLI, passing its output through
+// as progress.
+func runStorageCLI(argv []string) error {
+	c := exec.Command(argv[0], argv[1:]...)
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s failed: %v", strings.Join(argv[:3], " "), err)
+	}
+	return nil
+}
+
+// storageCLIOutput runs a storage provider's CLI and returns its trimmed
+// output.
+func storageCLIOutput(what string, argv []string) (string, error) {
+	out, err := exec.Command(argv[0], argv[1:]...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s: %s", what, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("%s: %v", what, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package planner
+
+// loadPerCPU is only implemented on Linux; elsewhere auto parallelism relies
+// on observed plan durations alone.
+func loadPerCPU() (float64, bool) {
+	return 0, false
+}
+
+// availableMemory is only implemented on Linux.
+func availableMemory() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,207 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TagPolicy lists the tags resources created by a plan must carry.
+type TagPolicy struct {
+	// Required tags must be set on every new taggable resource.
+	Required []string `yaml:"required"`
+	// AllowedValues restricts tags to a set of values, e.g. environment
+	// to production or staging. Values only known after apply pass.
+	AllowedValues map[string][]string `yaml:"allowed_values"`
+	// Exclude lists resource types (globs) the policy doesn't cover.
+	Exclude []string `yaml:"exclude"`
+}
+
+// tagPolicyResult is what checking the tag policy against the targeted
+// states' plans found.
+type tagPolicyResult struct {
+	// Resources is how many new taggable resources were checked.
+	Resources  int             `json:"resources"`
+	Violations []*tagViolation `json:"violations"`
+}
+
+// tagViolation is a new resource missing required tags or with a tag
+// value the policy doesn't allow.
+type tagViolation struct {
+	State    string            `json:"state"`
+	Location string            `json:"location"` // env/region, or the state's path
+	Address  string            `json:"address"`
+	Missing  []string          `json:"missing,omitempty"`
+	Invalid  map[string]string `json:"invalid,omitempty"` // tag -> value
+}
+
+// planJSON is the part of `terraform show -json` output the tag policy
+// reads.
+type planJSON struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Mode    string `json:"mode"`
+		Type    string `json:"type"`
+		Change  struct {
+			Actions      []string       `json:"actions"`
+			After        map[string]any `json:"after"`
+			AfterUnknown map[string]any `json:"after_unknown"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+func (t *TagPolicy) enabled() bool {
+	return len(t.Required) > 0 || len(t.AllowedValues) > 0
+}
+
+func (t *TagPolicy) validate() error {
+	for _, pattern := range t.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %v", pattern, err)
+		}
+	}
+	for tag, values := range t.AllowedValues {
+		if len(values) == 0 {
+			return fmt.Errorf("allowed_values of %s is empty", tag)
+		}
+	}
+	return nil
+}
+
+// excluded tells whether the policy leaves a resource type out.
+func (t *TagPolicy) excluded(resourceType string) bool {
+	for _, pattern := range t.Exclude {
+		if ok, _ := path.Match(pattern, resourceType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTagPolicy checks the tags of the resources every planned state
+// creates, including replacements.
+func (pg *PlanGenerator) checkTagPolicy() (*tagPolicyResult, error) {
+	result := &tagPolicyResult{Violations: []*tagViolation{}}
+	for _, state := range pg.plannedStates {
+		data, err := os.ReadFile(filepath.Join(pg.OutputDir, state.planJSONName()))
+		if os.IsNotExist(err) {
+			// Failed plans save nothing; they're listed as failures
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var plan planJSON
+		if err := json.Unmarshal(data, &plan); err != nil {
+			return nil, fmt.Errorf("reading the plan JSON of %s: %v", state, err)
+		}
+		location := pg.stateLocation(state.Path)
+		if state.Workspace != "" {
+			location = state.String()
+		}
+		for _, rc := range plan.ResourceChanges {
+			if rc.Mode != "managed" || !contains(rc.Change.Actions, "create") || pg.Config.TagPolicy.excluded(rc.Type) {
+				continue
+			}
+			tags, ok := resourceTags(rc.Change.After, rc.Change.AfterUnknown)
+			if !ok {
+				continue
+			}
+			result.Resources++
+			if v := pg.Config.TagPolicy.check(tags); v != nil {
+				v.State, v.Location, v.Address = state.String(), location, rc.Address
+				result.Violations = append(result.Violations, v)
+			}
+		}
+	}
+	sort.SliceStable(result.Violations, func(i, j int) bool { return result.Violations[i].Location < result.Violations[j].Location })
+	return result, nil
+}
+
+// resourceTags reads a new resource's tags from its planned values:
+// tags_all, which adds the provider's default_tags, where the plan knows
+// it, else tags. Values only known after apply are nil. ok is false for
+// resources without tags and those whose tags aren't known yet.
+func resourceTags(after, afterUnknown map[string]any) (map[string]*string, bool) {
+	for _, attr := range []string{"tags_all", "tags"} {
+		value, present := after[attr]
+		if !present {
+			continue
+		}
+		unknown, _ := afterUnknown[attr].(map[string]any)
+		if whole, _ := afterUnknown[attr].(bool); whole {
+			continue
+		}
+		tags := make(map[string]*string)
+		values, _ := value.(map[string]any)
+		for key, v := range values {
+			if s, ok := v.(string); ok {
+				tags[key] = &s
+			}
+		}
+		for key, v := range unknown {
+			if isUnknown, _ := v.(bool); isUnknown {
+				tags[key] = nil
+			}
+		}
+		return tags, true
+	}
+	return nil, false
+}
+
+// check returns what's wrong with a resource's tags, nil if nothing.
+func (t *TagPolicy) check(tags map[string]*string) *tagViolation {
+	v := &tagViolation{}
+	for _, tag := range t.Required {
+		if value, ok := tags[tag]; !ok || (value != nil && strings.TrimSpace(*value) == "") {
+			v.Missing = append(v.Missing, tag)
+		}
+	}
+	for tag, allowed := range t.AllowedValues {
+		if value := tags[tag]; value != nil && *value != "" && !contains(allowed, *value) {
+			if v.Invalid == nil {
+				v.Invalid = make(map[string]string)
+			}
+			v.Invalid[tag] = *value
+		}
+	}
+	if len(v.Missing) == 0 && len(v.Invalid) == 0 {
+		return nil
+	}
+	return v
+}
+
+// writeTagPolicy renders the new resources that don't meet the tag
+// policy.
+func (pg *PlanGenerator) writeTagPolicy(output *os.File) {
+	r := pg.tagPolicy
+	if r == nil || r.Resources == 0 {
+		return
+	}
+	if len(r.Violations) == 0 {
+		output.WriteString(fmt.Sprintf("> %sAll %d new resource(s) carry the tags the tag policy requires.\n\n", pg.renderer().Icon("🏷️"), r.Resources))
+		return
+	}
+	output.WriteString("## " + pg.renderer().Icon("🏷️") + "Tag policy\n\n")
+	output.WriteString(fmt.Sprintf("%d of %d new resource(s) don't meet the tag policy; fix their tags before applying:\n\n", len(r.Violations), r.Resources))
+	output.WriteString("| Resource | State | Problem |\n|---|---|---|\n")
+	for _, v := range r.Violations {
+		var problems []string
+		if len(v.Missing) > 0 {
+			problems = append(problems, "missing `"+strings.Join(v.Missing, "`, `")+"`")
+		}
+		var invalid []string
+		for tag := range v.Invalid {
+			invalid = append(invalid, tag)
+		}
+		sort.Strings(invalid)
+		for _, tag := range invalid {
+			problems = append(problems, fmt.Sprintf("`%s` is `%s` (allowed: %s)", tag, v.Invalid[tag], strings.Join(pg.Config.TagPolicy.AllowedValues[tag], ", ")))
+		}
+		output.WriteString(fmt.Sprintf("| `%s` | %s | %s |\n", v.Address, v.Location, strings.Join(problems, "; ")))
+	}
+	output.WriteString("\n")
+}
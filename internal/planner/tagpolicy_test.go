@@ -0,0 +1,49 @@
+package planner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTagPolicy(t *testing.T) {
+	policy := &TagPolicy{
+		Required:      []string{"owner", "environment"},
+		AllowedValues: map[string][]string{"environment": {"production", "staging"}},
+	}
+	var plan planJSON
+	err := json.Unmarshal([]byte(`{"resource_changes":[
+		{"address":"aws_s3_bucket.a","mode":"managed","type":"aws_s3_bucket","change":{"actions":["create"],
+			"after":{"tags":{"owner":"me"},"tags_all":{"owner":"me","environment":"prod"}},"after_unknown":{}}},
+		{"address":"aws_s3_bucket.b","mode":"managed","type":"aws_s3_bucket","change":{"actions":["create"],
+			"after":{"tags":{"environment":"staging"}},"after_unknown":{"tags":{"owner":true}}}},
+		{"address":"aws_s3_bucket.c","mode":"managed","type":"aws_s3_bucket","change":{"actions":["create"],
+			"after":{"tags_all":{}},"after_unknown":{}}}]}`), &plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []*tagViolation
+	for _, rc := range plan.ResourceChanges {
+		tags, ok := resourceTags(rc.Change.After, rc.Change.AfterUnknown)
+		if !ok {
+			t.Fatalf("%s: no tags", rc.Address)
+		}
+		got = append(got, policy.check(tags))
+	}
+	// tags_all wins over tags; values known only after apply count as set
+	if got[0] == nil || len(got[0].Missing) != 0 || got[0].Invalid["environment"] != "prod" {
+		t.Errorf("a = %+v", got[0])
+	}
+	if got[1] != nil {
+		t.Errorf("b = %+v", got[1])
+	}
+	if got[2] == nil || strings.Join(got[2].Missing, ",") != "owner,environment" {
+		t.Errorf("c = %+v", got[2])
+	}
+	if _, ok := resourceTags(map[string]any{"bucket": "x"}, nil); ok {
+		t.Error("resource without tags was checked")
+	}
+	if !(&TagPolicy{Exclude: []string{"aws_iam_*"}}).excluded("aws_iam_role") {
+		t.Error("aws_iam_role not excluded")
+	}
+}
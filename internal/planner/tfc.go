@@ -0,0 +1,418 @@
+package planner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultTFCHostname = "app.terraform.io"
+
+// tfcArchiveSkipDirs aren't uploaded with a workspace's configuration.
+var tfcArchiveSkipDirs = map[string]bool{".git": true, ".terraform": true, ".terragrunt-cache": true}
+
+// ansiRegex matches the color codes in remote plan logs.
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// TFCConfig plans the targeted states backed by Terraform Cloud/Enterprise
+// workspaces remotely: a speculative run per state, whose log goes into the
+// report like a local plan's output.
+type TFCConfig struct {
+	// Hostname is the Terraform Cloud or Enterprise host;
+	// app.terraform.io by default.
+	Hostname     string `yaml:"hostname"`
+	Organization string `yaml:"organization"`
+	// Workspaces map states to workspaces; the first matching a state
+	// plans it, and states matching none are planned locally.
+	Workspaces []*RemoteStack `yaml:"workspaces"`
+}
+
+func (c TFCConfig) validate() error {
+	if len(c.Workspaces) > 0 && c.Organization == "" {
+		return fmt.Errorf("organization is required")
+	}
+	return validateRemoteStacks("workspaces", c.Workspaces)
+}
+
+// tfcClient is the slice of the Terraform Cloud API remote plans need.
+type tfcClient struct {
+	apiURL string
+	appURL string
+	token  string
+	org    string
+	http   *http.Client
+}
+
+// tfcData is the data of a JSON:API document, with the attributes and
+// relationships of workspaces, configuration versions, runs and plans the
+// client reads.
+type tfcData struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Status           string `json:"status"`
+		UploadURL        string `json:"upload-url"`
+		WorkingDirectory string `json:"working-directory"`
+		LogReadURL       string `json:"log-read-url"`
+	} `json:"attributes"`
+	Relationships struct {
+		Plan struct {
+			Data struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		} `json:"plan"`
+	} `json:"relationships"`
+}
+
+// tfcClient is the run's Terraform Cloud client, created on first use.
+func (pg *PlanGenerator) tfcClient() (*tfcClient, error) {
+	pg.remoteMu.Lock()
+	defer pg.remoteMu.Unlock()
+	if pg.tfc == nil {
+		client, err := newTFCClient(pg.Config.TerraformCloud)
+		if err != nil {
+			return nil, err
+		}
+		pg.tfc = client
+	}
+	return pg.tfc, nil
+}
+
+// newTFCClient authenticates like terraform: TF_TOKEN_<hostname>, else
+// TFE_TOKEN, else the token terraform login saved for the host. Hostname
+// may be a URL, for hosts not served over https.
+func newTFCClient(cfg TFCConfig) (*tfcClient, error) {
+	hostname := cfg.Hostname
+	if hostname == "" {
+		hostname = defaultTFCHostname
+	}
+	baseURL := strings.TrimSuffix(hostname, "/")
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "https://" + baseURL
+	} else {
+		hostname = strings.SplitN(baseURL, "://", 2)[1]
+	}
+	token := os.Getenv("TF_TOKEN_" + strings.NewReplacer(".", "_", "-", "__").Replace(hostname))
+	if token == "" {
+		token = os.Getenv("TFE_TOKEN")
+	}
+	if token == "" {
+		token = savedTFCToken(hostname)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("no Terraform Cloud token for %s: set TFE_TOKEN or run terraform login", hostname)
+	}
+	return &tfcClient{
+		apiURL: baseURL + "/api/v2",
+		appURL: baseURL + "/app",
+		token:  token,
+		org:    cfg.Organization,
+		http:   &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// savedTFCToken is the token terraform login saved for hostname, if any.
+func savedTFCToken(hostname string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".terraform.d", "credentials.tfrc.json"))
+	if err != nil {
+		return ""
+	}
+	var credentials struct {
+		Credentials map[string]struct {
+			Token string `json:"token"`
+		} `json:"credentials"`
+	}
+	if json.Unmarshal(data, &credentials) != nil {
+		return ""
+	}
+	return credentials.Credentials[hostname].Token
+}
+
+// do sends an API request with payload, if any, as the data of a JSON:API
+// document and returns the response's data.
+func (c *tfcClient) do(method, path string, payload any) (*tfcData, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(map[string]any{"data": payload})
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.apiURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/vnd.api+json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	var doc struct {
+		Data *tfcData `json:"data"`
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &tfcData{}, nil
+	}
+	if err := json.Unmarshal(data, &doc); err != nil || doc.Data == nil {
+		return nil, fmt.Errorf("%s %s: unexpected response: %.200s", method, path, data)
+	}
+	return doc.Data, nil
+}
+
+// planTFC plans state in a speculative run of workspace: it uploads the
+// state's configuration (the repository, for workspaces with a working
+// directory), waits for the plan and returns its log as the state's
+// output. Runs are cancelled with the state.
+func (pg *PlanGenerator) planTFC(ctx context.Context, state *State, workspace string) ([]byte, error) {
+	client, err := pg.tfcClient()
+	if err != nil {
+		return nil, err
+	}
+	ws, err := client.do("GET", fmt.Sprintf("/organizations/%s/workspaces/%s", client.org, workspace), nil)
+	if err != nil {
+		return nil, fmt.Errorf("workspace %s: %v", workspace, err)
+	}
+	dir := state.Path
+	if ws.Attributes.WorkingDirectory != "" {
+		out, err := commandOutput(exec.Command("git", "rev-parse", "--show-toplevel"))
+		if err != nil {
+			return nil, fmt.Errorf("workspace %s has working directory %s, but the repository root is unknown: %v", workspace, ws.Attributes.WorkingDirectory, err)
+		}
+		dir = strings.TrimSpace(string(out))
+	}
+	archive, err := configArchive(dir)
+	if err != nil {
+		return nil, fmt.Errorf("packing %s: %v", dir, err)
+	}
+
+	cv, err := client.do("POST", "/workspaces/"+ws.ID+"/configuration-versions", map[string]any{
+		"type":       "configuration-versions",
+		"attributes": map[string]any{"auto-queue-runs": false, "speculative": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := client.upload(cv.Attributes.UploadURL, archive); err != nil {
+		return nil, fmt.Errorf("uploading the configuration: %v", err)
+	}
+	if cv, err = client.wait(ctx, "/configuration-versions/"+cv.ID, "uploaded", "errored"); err != nil {
+		return nil, err
+	} else if cv.Attributes.Status != "uploaded" {
+		return nil, fmt.Errorf("configuration version %s %s", cv.ID, cv.Attributes.Status)
+	}
+
+	attributes := map[string]any{
+		"plan-only":  true,
+		"is-destroy": pg.Destroy,
+		"message":    fmt.Sprintf("Speculative plan of %s by terraform-pr-generator", pg.ModuleName),
+	}
+	if len(pg.Targets) > 0 {
+		attributes["target-addrs"] = pg.Targets
+	}
+	run, err := client.do("POST", "/runs", map[string]any{
+		"type":       "runs",
+		"attributes": attributes,
+		"relationships": map[string]any{
+			"workspace":             map[string]any{"data": map[string]string{"type": "workspaces", "id": ws.ID}},
+			"configuration-version": map[string]any{"data": map[string]string{"type": "configuration-versions", "id": cv.ID}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	runURL := fmt.Sprintf("%s/%s/workspaces/%s/runs/%s", client.appURL, client.org, workspace, run.ID)
+	pg.recordRemoteRun(state, "Terraform Cloud", runURL)
+
+	plan, err := client.wait(ctx, "/plans/"+run.Relationships.Plan.Data.ID, "finished", "errored", "canceled", "unreachable")
+	if ctx.Err() != nil {
+		client.do("POST", "/runs/"+run.ID+"/actions/cancel", nil)
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run %s: %v", runURL, err)
+	}
+	log, err := client.download(plan.Attributes.LogReadURL)
+	if err != nil {
+		return nil, fmt.Errorf("run %s: reading the plan log: %v", runURL, err)
+	}
+	output := remotePlanOutput(log)
+	if plan.Attributes.Status != "finished" {
+		return nil, pg.commandError(fmt.Errorf("run %s: plan %s", runURL, plan.Attributes.Status), state.String(), output)
+	}
+	return output, nil
+}
+
+// wait polls path until its status is one of statuses.
+func (c *tfcClient) wait(ctx context.Context, path string, statuses ...string) (*tfcData, error) {
+	var data *tfcData
+	err := waitRemote(ctx, func() (bool, error) {
+		var err error
+		data, err = c.do("GET", path, nil)
+		return err == nil && slices.Contains(statuses, data.Attributes.Status), err
+	})
+	return data, err
+}
+
+// upload puts a configuration archive at a configuration version's upload
+// URL, which needs no token.
+func (c *tfcClient) upload(url string, archive []byte) error {
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(archive))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("PUT: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// download reads a plan's log from its log-read-url, which needs no
+// token.
+func (c *tfcClient) download(url string) ([]byte, error) {
+	resp, err := c.http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("GET: %s", resp.Status)
+	}
+	return data, nil
+}
+
+// configArchive packs dir into the .tar.gz a configuration version is
+// uploaded as, without .git, .terraform and .terragrunt-cache.
+func configArchive(dir string) ([]byte, error) {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && tfcArchiveSkipDirs[d.Name()] {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			header.Name += "/"
+			return tw.WriteHeader(header)
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// remoteActions are how plan output words the actions of structured run
+// output's planned changes.
+var remoteActions = map[string]string{
+	"create":  "will be created",
+	"update":  "will be updated in-place",
+	"delete":  "will be destroyed",
+	"replace": "must be replaced",
+	"read":    "will be read during apply",
+}
+
+// remotePlanOutput turns a remote plan's log into plan output: colors and
+// the log's framing go, and the JSON lines of structured run output become
+// the resource headers, summary and errors a local plan prints.
+func remotePlanOutput(log []byte) []byte {
+	log = bytes.Trim(ansiRegex.ReplaceAll(log, nil), "\x02\x03")
+	var b bytes.Buffer
+	for _, line := range strings.Split(string(log), "\n") {
+		var message struct {
+			Level   string `json:"@level"`
+			Message string `json:"@message"`
+			Type    string `json:"type"`
+			Change  struct {
+				Action   string `json:"action"`
+				Resource struct {
+					Addr string `json:"addr"`
+				} `json:"resource"`
+			} `json:"change"`
+		}
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &message) != nil || message.Message == "" {
+			b.WriteString(line + "\n")
+			continue
+		}
+		switch {
+		case message.Type == "planned_change" && remoteActions[message.Change.Action] != "":
+			fmt.Fprintf(&b, "  # %s %s\n", message.Change.Resource.Addr, remoteActions[message.Change.Action])
+		case message.Type == "change_summary":
+			fmt.Fprintf(&b, "\n%s\n", message.Message)
+		case message.Type == "diagnostic" && message.Level == "error":
+			fmt.Fprintf(&b, "Error: %s\n", strings.TrimPrefix(message.Message, "Error: "))
+		}
+	}
+	return append(bytes.TrimRight(b.Bytes(), "\n"), '\n')
+}
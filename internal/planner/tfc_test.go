@@ -0,0 +1,71 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanStateRemote(t *testing.T) {
+	state := &State{Path: filepath.Join(t.TempDir(), "terragrunt_vpc/organizations/staging/us-east-1")}
+	os.MkdirAll(state.Path, 0755)
+	os.WriteFile(filepath.Join(state.Path, "main.tf"), []byte("resource \"aws_vpc\" \"this\" {}\n"), 0644)
+
+	var server *httptest.Server
+	var uploaded int64
+	var run map[string]any
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v2/organizations/acme/workspaces/vpc-staging":
+			io.WriteString(w, `{"data":{"id":"ws-1","attributes":{}}}`)
+		case "POST /api/v2/workspaces/ws-1/configuration-versions":
+			io.WriteString(w, `{"data":{"id":"cv-1","attributes":{"status":"pending","upload-url":"`+server.URL+`/upload"}}}`)
+		case "PUT /upload":
+			uploaded, _ = io.Copy(io.Discard, r.Body)
+		case "GET /api/v2/configuration-versions/cv-1":
+			io.WriteString(w, `{"data":{"id":"cv-1","attributes":{"status":"uploaded"}}}`)
+		case "POST /api/v2/runs":
+			json.NewDecoder(r.Body).Decode(&run)
+			io.WriteString(w, `{"data":{"id":"run-1","relationships":{"plan":{"data":{"id":"plan-1"}}}}}`)
+		case "GET /api/v2/plans/plan-1":
+			io.WriteString(w, `{"data":{"id":"plan-1","attributes":{"status":"finished","log-read-url":"`+server.URL+`/log"}}}`)
+		case "GET /log":
+			io.WriteString(w, "\x02"+`{"@level":"info","@message":"aws_vpc.this: Plan to create","type":"planned_change","change":{"resource":{"addr":"aws_vpc.this"},"action":"create"}}`+"\n"+
+				`{"@level":"info","@message":"Plan: 1 to add, 0 to change, 0 to destroy.","type":"change_summary"}`+"\x03")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("TFE_TOKEN", "secret")
+
+	pg := newTestGenerator(t, &fakeExecutor{err: errors.New("planned locally")})
+	pg.Config.TerraformCloud = TFCConfig{
+		Hostname:     server.URL,
+		Organization: "acme",
+		Workspaces:   []*RemoteStack{{States: "**/terragrunt_vpc/**/staging/*", Name: "vpc-{{.Env}}"}},
+	}
+	if err := pg.Config.TerraformCloud.validate(); err != nil {
+		t.Fatal(err)
+	}
+	output, err := pg.planState(context.Background(), pg.Config.Partitions[0], state)
+	if err != nil {
+		t.Fatalf("planState: %v", err)
+	}
+	want := "  # aws_vpc.this will be created\n\nPlan: 1 to add, 0 to change, 0 to destroy.\n"
+	if string(output) != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+	if uploaded == 0 {
+		t.Error("no configuration was uploaded")
+	}
+	if attributes, _ := run["data"].(map[string]any)["attributes"].(map[string]any); attributes["plan-only"] != true {
+		t.Errorf("run = %v, want a plan-only run", run)
+	}
+}
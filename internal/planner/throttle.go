@@ -0,0 +1,129 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"time"
+)
+
+// throttledRegex matches the errors of AWS API calls refused for exceeding
+// a rate limit.
+var throttledRegex = regexp.MustCompile(`(ThrottlingException|Throttling: Rate exceeded|RequestLimitExceeded|TooManyRequestsException|RequestThrottled|SlowDown|Rate exceeded)`)
+
+const (
+	// throttleRetries is how many times a plan failing on AWS rate limits
+	// is run again, on top of --retries.
+	throttleRetries = 5
+	// throttleBackoff is the wait before a throttled plan's first retry;
+	// it doubles for each retry after, up to throttleMaxBackoff, with
+	// jitter.
+	throttleBackoff    = 10 * time.Second
+	throttleMaxBackoff = 2 * time.Minute
+	// throttleWindow is how long after cutting a pool's limit further
+	// throttled plans are taken for the same burst, not cutting it again.
+	throttleWindow = 30 * time.Second
+	// throttleRecovery is how many plans must finish without throttling
+	// before a throttled pool runs one more plan at once.
+	throttleRecovery = 5
+)
+
+// throttledError is a plan failing on AWS rate limits.
+type throttledError struct {
+	Err error
+}
+
+func (e *throttledError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *throttledError) Unwrap() error {
+	return e.Err
+}
+
+// throttleWait is the wait before the retry-th retry of a throttled plan:
+// between half and all of the exponential backoff, so the plans throttled
+// together don't all retry at once.
+func throttleWait(retry int) time.Duration {
+	backoff := throttleBackoff
+	for i := 1; i < retry && backoff < throttleMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > throttleMaxBackoff {
+		backoff = throttleMaxBackoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// waitThrottled slows a partition's plans down after one of them was
+// throttled and waits before that plan's retry, without holding its worker
+// slot, so the lowered limit applies to the retry too. It returns false
+// when ctx ended first.
+func (pg *PlanGenerator) waitThrottled(ctx context.Context, p *Partition, state *State, retry int) bool {
+	pool := pg.poolFor(p)
+	pool.throttle()
+	wait := throttleWait(retry).Round(time.Second)
+	if pg.Deterministic {
+		warningColor.Printf("🐢 Plan for %s hit AWS rate limits, retrying (%d of %d)\n", state, retry, throttleRetries)
+	} else {
+		warningColor.Printf("🐢 Plan for %s hit AWS rate limits, retrying in %s (%d of %d)\n", state, wait, retry, throttleRetries)
+	}
+	pg.progress.log(state.String(), fmt.Sprintf("--- throttled by AWS, retrying in %s ---", wait))
+
+	pool.releaseUntimed()
+	defer pool.acquire()
+	select {
+	case <-time.After(wait):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// throttle halves the pool's limit after a plan hit AWS rate limits, once
+// per burst of throttled plans.
+func (wp *workerPool) throttle() {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	if time.Since(wp.throttledAt) < throttleWindow {
+		return
+	}
+	wp.throttledAt = time.Now()
+	wp.unthrottledPlans = 0
+	if wp.ceiling == 0 {
+		wp.ceiling = wp.limit
+	}
+	previous := wp.limit
+	if wp.limit /= 2; wp.limit < 1 {
+		wp.limit = 1
+	}
+	if wp.limit != previous {
+		warningColor.Printf("🐢 AWS is rate limiting the plans, running %d at once instead of %d\n", wp.limit, previous)
+	}
+}
+
+// unthrottled counts a plan finished without throttling, raising the limit
+// of a throttled pool by one every throttleRecovery of them, back up to
+// where it was.
+func (wp *workerPool) unthrottled() {
+	wp.mu.Lock()
+	if wp.ceiling == 0 || wp.limit >= wp.ceiling {
+		wp.mu.Unlock()
+		return
+	}
+	if wp.unthrottledPlans++; wp.unthrottledPlans >= throttleRecovery {
+		wp.unthrottledPlans = 0
+		wp.limit++
+		if wp.verbose {
+			fmt.Fprintf(console, "  🐢 No rate limiting lately, running %d plans at once\n", wp.limit)
+		}
+	}
+	wp.mu.Unlock()
+	wp.cond.Broadcast()
+}
+
+// throttleHint is what the report suggests for a plan AWS kept throttling.
+func throttleHint() string {
+	return "AWS kept rate limiting this plan through its retries. Lower `--parallelism`, or the partition's `parallel` setting, to plan fewer states at once."
+}
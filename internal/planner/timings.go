@@ -0,0 +1,97 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateTiming is how long one state's plan took: a targeted state by its
+// path, a state of a full run by env/region.
+type stateTiming struct {
+	State   string        `json:"state"`
+	Status  string        `json:"status"`
+	Took    time.Duration `json:"-"`
+	Seconds float64       `json:"seconds"`
+}
+
+// timings lists the states that finished planning, slowest first.
+// Cancelled states and those that never started are left out.
+func (p *progress) timings() []*stateTiming {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var timings []*stateTiming
+	for _, st := range p.states {
+		if (st.Status != stateSucceeded && st.Status != stateFailed) || st.Took <= 0 {
+			continue
+		}
+		timings = append(timings, &stateTiming{
+			State:   st.Name,
+			Status:  st.Status,
+			Took:    st.Took,
+			Seconds: st.Took.Round(100 * time.Millisecond).Seconds(),
+		})
+	}
+	sort.SliceStable(timings, func(i, j int) bool {
+		if timings[i].Took != timings[j].Took {
+			return timings[i].Took > timings[j].Took
+		}
+		return timings[i].State < timings[j].State
+	})
+	return timings
+}
+
+// printSlowest names the Slowest states that took longest to plan.
+func (pg *PlanGenerator) printSlowest() {
+	if pg.Slowest <= 0 || len(pg.timings) < 2 {
+		return
+	}
+	slowest := pg.timings
+	if len(slowest) > pg.Slowest {
+		slowest = slowest[:pg.Slowest]
+	}
+	var parts []string
+	for _, t := range slowest {
+		parts = append(parts, fmt.Sprintf("%s (%s)", t.State, roundTook(t.Took)))
+	}
+	fmt.Fprintf(console, "🐢 Slowest plans: %s\n", strings.Join(parts, ", "))
+}
+
+// writeTimings renders how long every state's plan took as a collapsed
+// table, slowest first. --deterministic leaves it out.
+func (pg *PlanGenerator) writeTimings(output *os.File) {
+	if len(pg.timings) == 0 || pg.Deterministic {
+		return
+	}
+	var total time.Duration
+	for _, t := range pg.timings {
+		total += t.Took
+	}
+	pg.renderer().OpenSection(output, 2, pg.renderer().Icon("⏱️")+"Plan timings")
+	output.WriteString(fmt.Sprintf("%d plan(s) took %s in total, %s on average.\n\n",
+		len(pg.timings), roundTook(total), roundTook(total/time.Duration(len(pg.timings)))))
+	output.WriteString("| State | Duration | Share | Status |\n|---|---|---|---|\n")
+	for _, t := range pg.timings {
+		status := t.Status
+		if t.Status == stateFailed {
+			status = pg.renderer().Icon("❌") + status
+		}
+		output.WriteString(fmt.Sprintf("| %s | %s | %.0f%% | %s |\n", t.State, roundTook(t.Took), float64(t.Took)*100/float64(total), status))
+	}
+	output.WriteString("\n")
+	pg.renderer().CloseSection(output)
+}
+
+// roundTook rounds a plan duration for display: to the second, or the
+// tenth of a second below ten seconds.
+func roundTook(d time.Duration) time.Duration {
+	if d < 10*time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Second)
+}
@@ -0,0 +1,25 @@
+package planner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressTimings(t *testing.T) {
+	p := newProgress(4, "vpc", false)
+	for name, took := range map[string]time.Duration{"staging/us-east-1": 2 * time.Second, "production/us-east-1": 5 * time.Second, "staging/eu-west-1": time.Second} {
+		p.begin(name, nil)
+		p.end(name, stateSucceeded)
+		p.byName[name].Took = took
+	}
+	p.queue("production/eu-west-1")
+	p.end("production/eu-west-1", stateCancelled)
+
+	timings := p.timings()
+	if len(timings) != 3 {
+		t.Fatalf("got %d timings, want the 3 finished plans", len(timings))
+	}
+	if timings[0].State != "production/us-east-1" || timings[2].State != "staging/eu-west-1" || timings[0].Seconds != 5 {
+		t.Errorf("timings aren't slowest first: %+v, %+v, %+v", timings[0], timings[1], timings[2])
+	}
+}
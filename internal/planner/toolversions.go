@@ -0,0 +1,160 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// stateTools are the terraform and terragrunt versions a state was planned
+// with. Version managers (tfenv, tgenv, asdf, mise) pick them by directory,
+// so older modules can run on other versions than the rest of the repo.
+type stateTools struct {
+	State      string `json:"state"`
+	Location   string `json:"location"` // env/region, or the state's path
+	Terraform  string `json:"terraform,omitempty"`
+	Terragrunt string `json:"terragrunt,omitempty"`
+}
+
+// versionPinFiles are the files version managers read a directory's tool
+// versions from, in it or a parent.
+var versionPinFiles = []string{".terraform-version", ".terragrunt-version", ".opentofu-version", ".tool-versions", ".mise.toml", "mise.toml"}
+
+var toolVersionRegex = regexp.MustCompile(`\bv?(\d+\.\d+\.\d+[0-9A-Za-z.+-]*)`)
+
+// findToolVersions asks terraform and terragrunt for their versions in the
+// directory of every planned state: the targeted states, or in full runs
+// the module's states plan_all covers. States pinned by the same version
+// files share one lookup.
+func (pg *PlanGenerator) findToolVersions() []*stateTools {
+	states := pg.plannedStates
+	if states == nil {
+		dirs, err := findModuleStates(pg.Config.Runner.WorkingDir, pg.ModuleName)
+		if err != nil {
+			return nil
+		}
+		for _, dir := range dirs {
+			if pg.Config.PartitionFor(filepath.ToSlash(dir)+"/") != nil {
+				states = append(states, &State{Path: dir})
+			}
+		}
+	}
+
+	cache := make(map[string]*stateTools)
+	var found []*stateTools
+	for _, state := range states {
+		key := strings.Join(versionPins(state.Path), "\n")
+		tools := cache[key]
+		if tools == nil {
+			tools = &stateTools{Terraform: pg.toolVersion(state.Path, pg.terraformBinary(), "version")}
+			if !pg.Config.Runner.Workspaces {
+				tools.Terragrunt = pg.toolVersion(state.Path, "terragrunt", "--version")
+			}
+			cache[key] = tools
+		}
+		if tools.Terraform == "" && tools.Terragrunt == "" {
+			continue
+		}
+		location := pg.stateLocation(state.Path)
+		if state.Workspace != "" {
+			location = state.String()
+		}
+		found = append(found, &stateTools{
+			State:      state.String(),
+			Location:   location,
+			Terraform:  tools.Terraform,
+			Terragrunt: tools.Terragrunt,
+		})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Location < found[j].Location })
+	return found
+}
+
+// versionPins lists the version files that apply to dir, nearest first,
+// up to the directory the generator runs in.
+func versionPins(dir string) []string {
+	var pins []string
+	seen := make(map[string]bool)
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		for _, name := range versionPinFiles {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil && !seen[name] {
+				pins = append(pins, path)
+				seen[name] = true
+			}
+		}
+		if dir == "." || dir == filepath.Dir(dir) {
+			return pins
+		}
+	}
+}
+
+// terraformBinary is the terraform the runner ends up running: the
+// runner's own binary for plain terraform, TERRAGRUNT_TFPATH for terragrunt
+// when set.
+func (pg *PlanGenerator) terraformBinary() string {
+	if pg.Config.Runner.Workspaces {
+		return pg.Config.Runner.Binary
+	}
+	if path := os.Getenv("TERRAGRUNT_TFPATH"); path != "" {
+		return path
+	}
+	return "terraform"
+}
+
+// toolVersion runs a binary's version command in dir, returning the
+// version it reports, "" when it can't tell.
+func (pg *PlanGenerator) toolVersion(dir, binary, arg string) string {
+	ctx, cancel := context.WithTimeout(pg.ctx, versionTimeout)
+	defer cancel()
+	var stdout bytes.Buffer
+	if err := pg.execute(ctx, &Command{Args: []string{binary, arg}, Dir: dir, Stdout: &stdout}); err != nil {
+		return ""
+	}
+	if m := toolVersionRegex.FindStringSubmatch(stdout.String()); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// toolSkew lists the tools planned with more than one version, for the
+// version skew section.
+func toolSkew(found []*stateTools) []*versionSkew {
+	var skews []*versionSkew
+	for _, tool := range []string{"terraform", "terragrunt"} {
+		skew := &versionSkew{Kind: "tool", Name: tool, Versions: make(map[string][]string)}
+		for _, tools := range found {
+			version := tools.Terraform
+			if tool == "terragrunt" {
+				version = tools.Terragrunt
+			}
+			if version != "" && !contains(skew.Versions[version], tools.Location) {
+				skew.Versions[version] = append(skew.Versions[version], tools.Location)
+			}
+		}
+		if len(skew.Versions) > 1 {
+			skews = append(skews, skew)
+		}
+	}
+	return skews
+}
+
+// writeToolVersions renders the tool versions of the planned states as a
+// collapsed table.
+func (pg *PlanGenerator) writeToolVersions(output *os.File) {
+	if len(pg.toolVersions) == 0 {
+		return
+	}
+	pg.renderer().OpenSection(output, 2, pg.renderer().Icon("🧰")+"Tool versions")
+	output.WriteString("| State | Terraform | Terragrunt |\n|---|---|---|\n")
+	for _, tools := range pg.toolVersions {
+		output.WriteString(fmt.Sprintf("| %s | %s | %s |\n", tools.Location, orDash(tools.Terraform), orDash(tools.Terragrunt)))
+	}
+	output.WriteString("\n")
+	pg.renderer().CloseSection(output)
+}
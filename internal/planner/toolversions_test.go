@@ -0,0 +1,27 @@
+package planner
+
+import "testing"
+
+func TestFindToolVersionsSharesLookups(t *testing.T) {
+	fake := &fakeExecutor{stdout: "Terraform v1.5.7\non linux_amd64\n"}
+	pg := newTestGenerator(t, fake)
+	pg.plannedStates = []*State{
+		{Path: "terragrunt_vpc/organizations/staging/us-east-1"},
+		{Path: "terragrunt_vpc/organizations/production/us-east-1"},
+	}
+
+	found := pg.findToolVersions()
+	if len(found) != 2 {
+		t.Fatalf("found versions for %d states, want 2", len(found))
+	}
+	if found[0].Terraform != "1.5.7" {
+		t.Errorf("terraform = %q, want 1.5.7", found[0].Terraform)
+	}
+	// Neither state has version files, so one lookup per tool answers both
+	if len(fake.commands) != 2 {
+		t.Errorf("ran %d commands, want 2: %v", len(fake.commands), fake.commands)
+	}
+	if skews := toolSkew(found); len(skews) != 0 {
+		t.Errorf("skew = %v for states on the same versions", skews)
+	}
+}
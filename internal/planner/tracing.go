@@ -0,0 +1,321 @@
+package planner
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// otlpTracesPath is where an OTLP/HTTP collector takes spans, below
+	// OTEL_EXPORTER_OTLP_ENDPOINT.
+	otlpTracesPath = "/v1/traces"
+	// defaultServiceName is the service.name spans are exported under.
+	defaultServiceName = "terraform-pr-generator"
+)
+
+// TracingConfig exports OpenTelemetry spans of every run, its partitions
+// and its state plans to an OTLP/HTTP collector. The standard OTEL_*
+// environment variables override it.
+type TracingConfig struct {
+	// Endpoint is the collector's traces URL, e.g.
+	// http://otel-collector:4318/v1/traces. Tracing is off without one.
+	Endpoint string `yaml:"endpoint"`
+	// Headers are sent with every export, e.g. an API key.
+	Headers map[string]string `yaml:"headers"`
+	// ServiceName is the service.name of the spans.
+	ServiceName string `yaml:"service_name"`
+}
+
+func (c TracingConfig) validate() error {
+	if c.Endpoint == "" {
+		return nil
+	}
+	if u, err := url.Parse(c.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("endpoint %q isn't an http(s) URL", c.Endpoint)
+	}
+	return nil
+}
+
+// withEnv applies OTEL_EXPORTER_OTLP_TRACES_ENDPOINT (or
+// OTEL_EXPORTER_OTLP_ENDPOINT, the collector's base URL),
+// OTEL_EXPORTER_OTLP_HEADERS and OTEL_SERVICE_NAME. OTEL_SDK_DISABLED=true
+// turns tracing off.
+func (c TracingConfig) withEnv() TracingConfig {
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		return TracingConfig{}
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		c.Endpoint = endpoint
+	} else if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		c.Endpoint = strings.TrimSuffix(endpoint, "/") + otlpTracesPath
+	}
+	if headers := os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"); headers != "" {
+		merged := make(map[string]string)
+		for k, v := range c.Headers {
+			merged[k] = v
+		}
+		for _, pair := range strings.Split(headers, ",") {
+			if k, v, ok := strings.Cut(pair, "="); ok {
+				key, _ := url.QueryUnescape(strings.TrimSpace(k))
+				value, _ := url.QueryUnescape(strings.TrimSpace(v))
+				merged[key] = value
+			}
+		}
+		c.Headers = merged
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		c.ServiceName = name
+	}
+	return c
+}
+
+// tracer collects the spans of a run and exports them in one batch once it
+// ends. A nil tracer records nothing.
+type tracer struct {
+	config  TracingConfig
+	traceID string
+	// parentID is the span a TRACEPARENT from the environment names, e.g.
+	// the CI job's, that the run's span becomes a child of.
+	parentID string
+	http     *http.Client
+
+	mu    sync.Mutex
+	spans []*span
+}
+
+// span is one timed operation of a run. Its methods do nothing on a nil
+// span, so callers don't check whether tracing is on.
+type span struct {
+	tracer   *tracer
+	id       string
+	parentID string
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    map[string]any
+	err      string
+}
+
+// newTracer returns the tracer of a run, nil when no endpoint is
+// configured.
+func newTracer(cfg TracingConfig) (*tracer, error) {
+	cfg = cfg.withEnv()
+	if cfg.Endpoint == "" {
+		return nil, nil
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("tracing: %v", err)
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = defaultServiceName
+	}
+	t := &tracer{config: cfg, traceID: randomID(16), http: &http.Client{Timeout: 10 * time.Second}}
+	// W3C trace context: 00-<trace id>-<parent id>-<flags>
+	if parts := strings.Split(os.Getenv("TRACEPARENT"), "-"); len(parts) == 4 && len(parts[1]) == 32 && len(parts[2]) == 16 {
+		t.traceID, t.parentID = parts[1], parts[2]
+	}
+	return t, nil
+}
+
+func randomID(bytes int) string {
+	id := make([]byte, bytes)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// start begins a span, a child of parent or else the run's root.
+func (t *tracer) start(parent *span, name string) *span {
+	if t == nil {
+		return nil
+	}
+	s := &span{tracer: t, id: randomID(8), parentID: t.parentID, name: name, start: time.Now(), attrs: make(map[string]any)}
+	if parent != nil {
+		s.parentID = parent.id
+	}
+	return s
+}
+
+// set adds an attribute, a string, int or bool.
+func (s *span) set(key string, value any) *span {
+	if s != nil {
+		s.attrs[key] = value
+	}
+	return s
+}
+
+// finish ends the span, failed if err is set.
+func (s *span) finish(err error) {
+	if s == nil {
+		return
+	}
+	s.finishAt(time.Now(), err)
+}
+
+func (s *span) finishAt(end time.Time, err error) {
+	s.end = end
+	if err != nil {
+		s.err = err.Error()
+	}
+	s.tracer.mu.Lock()
+	s.tracer.spans = append(s.tracer.spans, s)
+	s.tracer.mu.Unlock()
+}
+
+// traceScannedStates records the states a plan_all command planned, as
+// their output timed them, under the partition's span.
+func (pg *PlanGenerator) traceScannedStates(parent *span, scanner *progressScanner) {
+	if parent == nil {
+		return
+	}
+	for _, st := range pg.progress.snapshot() {
+		if !scanner.closed[st.Name] || st.Began.IsZero() {
+			continue
+		}
+		s := pg.tracer.start(parent, "plan "+st.Name).set("tfprgen.state", st.Name).set("tfprgen.status", st.Status)
+		s.start = st.Began
+		var err error
+		if st.Status == stateFailed {
+			err = fmt.Errorf("plan failed")
+		}
+		s.finishAt(st.Began.Add(st.Took), err)
+	}
+}
+
+// OTLP/HTTP JSON encoding of spans; see opentelemetry-proto's
+// trace/v1/trace.proto.
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 1 ok, 2 error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func otlpAttributes(attrs map[string]any) []otlpAttribute {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var out []otlpAttribute
+	for _, key := range keys {
+		var value map[string]any
+		switch v := attrs[key].(type) {
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpAttribute{Key: key, Value: value})
+	}
+	return out
+}
+
+// payload encodes the finished spans.
+func (t *tracer) payload() ([]byte, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	build := CurrentBuild()
+	scope := otlpScopeSpans{}
+	scope.Scope.Name, scope.Scope.Version = defaultServiceName, build.Version
+	for _, s := range t.spans {
+		status := otlpStatus{Code: 1}
+		if s.err != "" {
+			status = otlpStatus{Code: 2, Message: s.err}
+		}
+		scope.Spans = append(scope.Spans, otlpSpan{
+			TraceID:           t.traceID,
+			SpanID:            s.id,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              1, // internal
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.attrs),
+			Status:            status,
+		})
+	}
+	resource := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	resource.Resource.Attributes = otlpAttributes(map[string]any{
+		"service.name":    t.config.ServiceName,
+		"service.version": build.Version,
+	})
+	data, _ := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{resource}})
+	return data, len(scope.Spans)
+}
+
+// export sends the finished spans to the collector.
+func (t *tracer) export() error {
+	if t == nil {
+		return nil
+	}
+	data, n := t.payload()
+	if n == 0 {
+		return nil
+	}
+	req, err := http.NewRequest("POST", t.config.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
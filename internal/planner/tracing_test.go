@@ -0,0 +1,59 @@
+package planner
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracerExport(t *testing.T) {
+	var got otlpTraces
+	var apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("X-Api-Key")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-api-key=secret")
+	t.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	tr, err := newTracer(TracingConfig{})
+	if err != nil || tr == nil {
+		t.Fatalf("newTracer: %v, %v", tr, err)
+	}
+	run := tr.start(nil, "run vpc")
+	tr.start(run, "plan staging").set("tfprgen.states", 2).finish(errors.New("plan failed"))
+	run.finish(nil)
+	if err := tr.export(); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	if apiKey != "secret" {
+		t.Errorf("export sent X-Api-Key %q", apiKey)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	plan, root := spans[0], spans[1]
+	if root.TraceID != "0af7651916cd43dd8448eb211c80319c" || root.ParentSpanID != "b7ad6b7169203331" {
+		t.Errorf("run span isn't a child of TRACEPARENT: %+v", root)
+	}
+	if plan.ParentSpanID != root.SpanID || plan.Status.Code != 2 || plan.Attributes[0].Value["intValue"] != "2" {
+		t.Errorf("plan span = %+v", plan)
+	}
+}
+
+func TestTracerOff(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+	if tr, err := newTracer(TracingConfig{Endpoint: "http://collector:4318/v1/traces"}); tr != nil || err != nil {
+		t.Errorf("newTracer = %v, %v with OTEL_SDK_DISABLED", tr, err)
+	}
+	// A nil tracer's spans are no-ops
+	var tr *tracer
+	tr.start(nil, "run").set("k", "v").finish(nil)
+}
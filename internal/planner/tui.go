@@ -0,0 +1,284 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// tuiRefresh is how often the TUI redraws.
+const tuiRefresh = 100 * time.Millisecond
+
+// tuiProgram is the --tui screen: a live table of states with their logs
+// and cancellation. While it's up, other console output is held back and
+// printed once it closes.
+type tuiProgram struct {
+	program *tea.Program
+	done    chan struct{}
+	held    *syncBuffer
+	console io.Writer
+	output  io.Writer
+}
+
+func newTUI() (*tuiProgram, error) {
+	if logger != nil {
+		return nil, fmt.Errorf("--tui needs the pretty --log-format")
+	}
+	for _, f := range []*os.File{os.Stdin, os.Stderr} {
+		if !isatty.IsTerminal(f.Fd()) && !isatty.IsCygwinTerminal(f.Fd()) {
+			return nil, fmt.Errorf("--tui needs an interactive terminal")
+		}
+	}
+	return &tuiProgram{done: make(chan struct{})}, nil
+}
+
+func (t *tuiProgram) start(p *progress) {
+	t.held = &syncBuffer{}
+	t.console, t.output = console, color.Output
+	console, color.Output = t.held, t.held
+
+	t.program = tea.NewProgram(&tuiModel{progress: p}, tea.WithAltScreen(), tea.WithOutput(os.Stderr))
+	go func() {
+		defer close(t.done)
+		if _, err := t.program.Run(); err != nil {
+			// The run goes on without the screen
+			t.held.WriteString(fmt.Sprintf("⚠️  TUI failed: %v\n", err))
+		}
+	}()
+}
+
+// stop closes the screen and prints the held back output.
+func (t *tuiProgram) stop() {
+	t.program.Quit()
+	<-t.done
+	console, color.Output = t.console, t.output
+	color.Output.Write(t.held.Bytes())
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) WriteString(s string) {
+	b.Write([]byte(s))
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+type tuiTick struct{}
+
+func tuiTickCmd() tea.Cmd {
+	return tea.Tick(tuiRefresh, func(time.Time) tea.Msg { return tuiTick{} })
+}
+
+// tuiModel renders progress: the state table, or the log of the selected
+// state.
+type tuiModel struct {
+	progress      *progress
+	states        []stateProgress
+	status        string
+	selected      int
+	viewing       bool
+	width, height int
+	frame         int
+	message       string
+	cancelling    bool
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	m.refresh()
+	return tuiTickCmd()
+}
+
+func (m *tuiModel) refresh() {
+	m.states = m.progress.snapshot()
+	m.progress.mu.Lock()
+	m.status = m.progress.status()
+	m.progress.mu.Unlock()
+	if m.selected >= len(m.states) {
+		m.selected = len(m.states) - 1
+	}
+	if m.selected < 0 {
+		m.selected = 0
+	}
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tuiTick:
+		m.frame++
+		m.refresh()
+		return m, tuiTickCmd()
+	case tea.KeyMsg:
+		m.message = ""
+		switch msg.String() {
+		case "up", "k":
+			if m.selected > 0 {
+				m.selected--
+			}
+		case "down", "j":
+			if m.selected < len(m.states)-1 {
+				m.selected++
+			}
+		case "home", "g":
+			m.selected = 0
+		case "end", "G":
+			m.selected = len(m.states) - 1
+		case "enter", "l":
+			m.viewing = !m.viewing && len(m.states) > 0
+		case "esc", "h":
+			m.viewing = false
+		case "c":
+			m.cancelState()
+		case "q", "ctrl+c":
+			if !m.cancelling {
+				m.cancelling = true
+				m.message = "Cancelling the run…"
+				m.progress.cancelRun()
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *tuiModel) cancelState() {
+	if len(m.states) == 0 {
+		return
+	}
+	st := m.states[m.selected]
+	switch {
+	case st.Status != stateRunning:
+		m.message = fmt.Sprintf("%s isn't running", st.Name)
+	case st.cancel == nil:
+		m.message = "States of full runs can't be cancelled one by one; q cancels the run"
+	case m.progress.cancel(st.Name):
+		m.message = fmt.Sprintf("Cancelling %s", st.Name)
+	}
+}
+
+func (m *tuiModel) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n\n", infoColor.Sprint(m.fit("terraform-pr-generator: "+m.progress.module, 0)), m.fit(m.status, 0))
+
+	// Header, blank line, footer lines
+	rows := m.height - 6
+	if rows < 3 {
+		rows = 3
+	}
+	if m.viewing && len(m.states) > 0 {
+		st := m.states[m.selected]
+		b.WriteString(m.icon(st) + m.fit(fmt.Sprintf(" %s (%s)", st.Name, st.Status), 1) + "\n")
+		lines := st.Log
+		if len(lines) > rows-1 {
+			lines = lines[len(lines)-(rows-1):]
+		}
+		if len(lines) == 0 {
+			lines = []string{"(no output yet)"}
+		}
+		for _, line := range lines {
+			b.WriteString(m.fit(line, 0) + "\n")
+		}
+	} else {
+		first := 0
+		if m.selected >= rows {
+			first = m.selected - rows + 1
+		}
+		for i := first; i < len(m.states) && i < first+rows; i++ {
+			st := m.states[i]
+			cursor := "  "
+			if i == m.selected {
+				cursor = "> "
+			}
+			took := st.Took
+			if st.Status == stateRunning {
+				took = time.Since(st.Began)
+			}
+			duration := ""
+			if st.Status != stateQueued {
+				duration = took.Round(time.Second).String()
+			}
+			b.WriteString(cursor + m.icon(st) + m.fit(fmt.Sprintf(" %-10s %8s  %s", st.Status, duration, st.Name), 3) + "\n")
+		}
+		if len(m.states) == 0 {
+			b.WriteString("  Waiting for the first state…\n")
+		}
+	}
+
+	b.WriteString("\n")
+	help := "↑/↓ select · enter logs · c cancel state · q cancel run"
+	if m.viewing {
+		help = "esc back · ↑/↓ other state · c cancel state · q cancel run"
+	}
+	if m.message != "" {
+		help = m.message
+	}
+	b.WriteString(m.fit(help, 0))
+	return b.String()
+}
+
+var tuiSpinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+var tuiSpinnerASCII = []string{"|", "/", "-", "\\"}
+
+func (m *tuiModel) icon(st stateProgress) string {
+	if consoleASCII {
+		switch st.Status {
+		case stateRunning:
+			return tuiSpinnerASCII[m.frame%len(tuiSpinnerASCII)]
+		case stateSucceeded:
+			return successColor.Sprint("+")
+		case stateFailed:
+			return errorColor.Sprint("x")
+		case stateCancelled:
+			return warningColor.Sprint("-")
+		}
+		return "."
+	}
+	switch st.Status {
+	case stateRunning:
+		return infoColor.Sprint(tuiSpinner[m.frame%len(tuiSpinner)])
+	case stateSucceeded:
+		return successColor.Sprint("✓")
+	case stateFailed:
+		return errorColor.Sprint("✗")
+	case stateCancelled:
+		return warningColor.Sprint("⊘")
+	}
+	return "·"
+}
+
+// fit cuts a line to the terminal width, less the used columns before it.
+func (m *tuiModel) fit(line string, used int) string {
+	line = strings.ReplaceAll(line, "\t", "    ")
+	width := m.width - used
+	if m.width <= 0 || width <= 0 {
+		return line
+	}
+	runes := []rune(line)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return line
+}
@@ -0,0 +1,107 @@
+package planner
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+// maxUploadExpiry is the longest S3, GCS and Azure user delegation SAS
+// accept for presigned URLs.
+const maxUploadExpiry = 7 * 24 * time.Hour
+
+// Storage is an object storage location run directories are copied to,
+// selected by the --upload URL's scheme.
+type Storage interface {
+	// location is the URL of a file of the run directory dir once
+	// uploaded; every run gets its own folder named after its output
+	// directory. An empty rel is the folder itself.
+	location(dir, rel string) string
+	// upload copies the run directory.
+	upload(dir string) error
+	// presign returns a download link for a file of the run directory.
+	// Links can be signed before the file is uploaded.
+	presign(dir, rel string, expires time.Duration) (string, error)
+	// expiring tells whether presign's links expire, or are plain links
+	// that need access to the storage.
+	expiring() bool
+}
+
+// storageBackends maps --upload URL schemes to their backends.
+var storageBackends = map[string]func(u *url.URL) (Storage, error){
+	"s3":               newS3Storage,
+	"gs":               newGCSStorage,
+	"az":               newAzureStorage,
+	"artifactory":      newArtifactoryStorage,
+	"artifactory+http": newArtifactoryStorage,
+}
+
+// artifactLink is a presigned download link for one uploaded file.
+type artifactLink struct {
+	Name string
+	URL  string
+}
+
+// parseUploadURL checks an --upload destination such as s3://bucket/prefix.
+func parseUploadURL(raw string) (Storage, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload destination %q: %v", raw, err)
+	}
+	backend, ok := storageBackends[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("invalid upload destination %q (supported: s3://bucket/prefix, gs://bucket/prefix, az://account/container/prefix, artifactory://host/path)", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid upload destination %q: missing bucket or host", raw)
+	}
+	store, err := backend(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload destination %q: %v", raw, err)
+	}
+	return store, nil
+}
+
+// presignArtifacts signs links to the plans files and additional reports
+// for the markdown, before they are written or uploaded.
+func (pg *PlanGenerator) presignArtifacts() ([]*artifactLink, error) {
+	var names []string
+	for _, p := range pg.Config.Partitions {
+		names = append(names, p.OutputFile)
+	}
+	for _, format := range pg.Formats {
+		if file := pg.formatFile(format); file != "" {
+			names = append(names, file)
+		}
+	}
+	names = append(names, manifestFile)
+
+	var links []*artifactLink
+	for _, name := range names {
+		link, err := pg.upload.presign(pg.OutputDir, name, pg.UploadExpires)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, &artifactLink{Name: name, URL: link})
+	}
+	return links, nil
+}
+
+// writeArtifacts lists the uploaded files' download links.
+func (pg *PlanGenerator) writeArtifacts(output *os.File) {
+	if len(pg.artifacts) == 0 {
+		return
+	}
+	output.WriteString("## " + pg.renderer().Icon("📦") + "Run artifacts\n\n")
+	if pg.upload.expiring() {
+		output.WriteString(fmt.Sprintf("Uploaded to `%s`; links expire %s.\n\n",
+			pg.upload.location(pg.OutputDir, ""), pg.now().Add(pg.UploadExpires).UTC().Format("2006-01-02 15:04 MST")))
+	} else {
+		output.WriteString(fmt.Sprintf("Uploaded to `%s`; links need read access to it.\n\n", pg.upload.location(pg.OutputDir, "")))
+	}
+	for _, link := range pg.artifacts {
+		output.WriteString(fmt.Sprintf("- [%s](%s)\n", link.Name, link.URL))
+	}
+	output.WriteString("\n")
+}
@@ -0,0 +1,109 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set with -ldflags at build time (see the Makefile):
+//
+//	-X github.com/backendken/terraform-pr-generator/internal/planner.Version=v1.4.0
+//
+// Builds without them, e.g. by go install, fall back to the module version
+// and VCS details the Go toolchain records in the binary.
+var (
+	Version   string
+	Commit    string
+	BuildDate string
+)
+
+// BuildInfo identifies the build of the generator, for bug reports and the
+// runs it produced.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// CurrentBuild is the BuildInfo of the running binary.
+func CurrentBuild() BuildInfo {
+	build := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if build.Version == "" && info.Main.Version != "(devel)" {
+			build.Version = info.Main.Version
+		}
+		modified := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if build.Commit == "" {
+					build.Commit = setting.Value
+				}
+			case "vcs.time":
+				if build.BuildDate == "" {
+					build.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && Commit == "" && build.Commit != "" {
+			build.Commit += "-dirty"
+		}
+	}
+	if build.Version == "" {
+		build.Version = "dev"
+	}
+	return build
+}
+
+func (b BuildInfo) String() string {
+	s := "terraform-pr-generator " + b.Version
+	if b.Commit != "" {
+		s += "\n  commit: " + b.Commit
+	}
+	if b.BuildDate != "" {
+		s += "\n  built:  " + b.BuildDate
+	}
+	return s + "\n  go:     " + b.GoVersion + " " + b.Platform
+}
+
+func newVersionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version, commit and build date",
+		Long: `Prints the version of the generator, with the commit and date it was
+built from, to identify the build in bug reports and CI logs. report.json
+records the same under "generator".
+
+Examples:
+  terraform-pr-generator version
+  terraform-pr-generator version --json`,
+		Args: cobra.NoArgs,
+		Run:  runVersion,
+	}
+	cmd.Flags().Bool("json", false, "Print the build metadata as JSON")
+	return cmd
+}
+
+func runVersion(cmd *cobra.Command, args []string) {
+	build := CurrentBuild()
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		data, _ := json.MarshalIndent(build, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Println(build)
+}
@@ -0,0 +1,178 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// watchPollInterval is how often --watch looks for changed files.
+	watchPollInterval = 500 * time.Millisecond
+	// watchDebounce is how long files must stay unchanged before --watch
+	// plans again, so an editor saving several files triggers one run.
+	watchDebounce = time.Second
+)
+
+// watchExtensions are the files whose changes --watch plans again for.
+var watchExtensions = []string{".tf", ".hcl", ".tfvars"}
+
+// watchModule runs targeted plans of a module, then again whenever its
+// configuration changes, into the same output directory, until
+// interrupted. The generator is rebuilt for every run so config changes
+// apply; a run that fails is reported and the watch goes on.
+func watchModule(cmd *cobra.Command, module string, extraArgs []string) {
+	if toStdout, _ := cmd.Flags().GetBool("stdout"); toStdout {
+		errorColor.Println("❌ Error: --watch regenerates pr-ready.md; it can't be combined with --stdout")
+		os.Exit(1)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	outputDir := ""
+	var roots []string
+	for {
+		pg, err := newPlanGenerator(cmd, module, "")
+		if err != nil {
+			errorColor.Printf("❌ Error: %v\n", err)
+			if outputDir == "" {
+				os.Exit(1)
+			}
+		} else {
+			pg.Targeted = true
+			pg.AutoMode = false
+			pg.ExtraArgs = append(pg.ExtraArgs, extraArgs...)
+			if outputDir == "" {
+				outputDir = pg.OutputDir
+			} else {
+				pg.OutputDir = outputDir
+				// Failures of the previous run would read as this one's
+				os.RemoveAll(filepath.Join(outputDir, errorLogDir))
+			}
+			if err := pg.RunContext(ctx); err != nil {
+				errorColor.Printf("❌ Error: %v\n", err)
+			}
+			roots = watchRoots(pg.moduleDir(), pg.plannedStates)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		infoColor.Printf("👀 Watching %s for changes (Ctrl-C to stop)\n", watchLabel(roots))
+		changed, ok := waitForChange(ctx, roots)
+		if !ok {
+			fmt.Fprintln(console, "Stopped")
+			return
+		}
+		infoColor.Printf("\n🔁 %s changed; planning again...\n", changed)
+	}
+}
+
+// watchRoots are the directories --watch looks at: the module's and those
+// of the states the last run planned.
+func watchRoots(moduleDir string, states []*State) []string {
+	var roots []string
+	if _, err := os.Stat(moduleDir); err == nil {
+		roots = append(roots, moduleDir)
+	}
+	return append(roots, stateDirs(states)...)
+}
+
+// watchLabel names the watched directories: the module's, and how many
+// state directories besides.
+func watchLabel(roots []string) string {
+	if len(roots) == 0 {
+		return "nothing"
+	}
+	if !strings.HasPrefix(filepath.Base(roots[0]), modulePrefix) {
+		return fmt.Sprintf("%d state director(ies)", len(roots))
+	}
+	if len(roots) == 1 {
+		return roots[0]
+	}
+	return fmt.Sprintf("%s and %d state director(ies)", roots[0], len(roots)-1)
+}
+
+// waitForChange polls roots until a watched file is added, changed or
+// removed and the files then stay unchanged for watchDebounce. It returns
+// the first file that changed, and false if ctx ended first.
+func waitForChange(ctx context.Context, roots []string) (string, bool) {
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	last := watchSnapshot(roots)
+	changed := ""
+	var settled time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return "", false
+		case now := <-ticker.C:
+			current := watchSnapshot(roots)
+			if file := snapshotDiff(last, current); file != "" {
+				if changed == "" {
+					changed = file
+				}
+				last = current
+				settled = now.Add(watchDebounce)
+			} else if changed != "" && !now.Before(settled) {
+				return changed, true
+			}
+		}
+	}
+}
+
+// watchSnapshot records the size and modification time of every watched
+// file below roots, leaving out hidden directories such as .terraform and
+// .terragrunt-cache.
+func watchSnapshot(roots []string) map[string]string {
+	snapshot := make(map[string]string)
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if name := d.Name(); path != root && strings.HasPrefix(name, ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !contains(watchExtensions, filepath.Ext(path)) {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				snapshot[path] = fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano())
+			}
+			return nil
+		})
+	}
+	return snapshot
+}
+
+// snapshotDiff returns a file that differs between two snapshots, the
+// first in sorted order, "" if none does.
+func snapshotDiff(before, after map[string]string) string {
+	var diff []string
+	for path, stamp := range after {
+		if before[path] != stamp {
+			diff = append(diff, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			diff = append(diff, path)
+		}
+	}
+	if len(diff) == 0 {
+		return ""
+	}
+	sort.Strings(diff)
+	return diff[0]
+}
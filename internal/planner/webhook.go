@@ -0,0 +1,257 @@
+package planner
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// webhookSecretEnv is the secret GitHub signs webhook payloads with; it
+// enables the webhook endpoint of serve.
+const webhookSecretEnv = "TFPRGEN_WEBHOOK_SECRET"
+
+// trustedForksEnv lists, comma-separated, the forks whose pull requests the
+// webhook plans. Pull requests from other forks are ignored: planning runs
+// their code, e.g. external data sources, with the server's credentials.
+const trustedForksEnv = "TFPRGEN_WEBHOOK_TRUSTED_FORKS"
+
+// plannedPullRequestActions are the pull_request actions that (re)plan.
+var plannedPullRequestActions = map[string]bool{
+	"opened":           true,
+	"reopened":         true,
+	"synchronize":      true,
+	"ready_for_review": true,
+}
+
+// pullRequestRef is the pull request a webhook run plans.
+type pullRequestRef struct {
+	Number  int    `json:"number"`
+	HeadSHA string `json:"head_sha"`
+	Base    string `json:"base"` // branch name
+}
+
+// pullRequestEvent is the part of a pull_request webhook payload used.
+type pullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head struct {
+			SHA  string `json:"sha"`
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"` // null once a fork is deleted
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// handleGitHubWebhook queues a run per module a pull request touches.
+func (s *planServer) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 25<<20))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validSignature(s.webhookSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		httpError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	switch event := r.Header.Get("X-GitHub-Event"); event {
+	case "ping":
+		writeJSONResponse(w, http.StatusOK, map[string]string{"status": "pong"})
+		return
+	case "pull_request":
+	default:
+		writeJSONResponse(w, http.StatusAccepted, map[string]string{"status": "ignored", "reason": event + " events aren't planned"})
+		return
+	}
+	var event pullRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("invalid payload: %v", err))
+		return
+	}
+	if !plannedPullRequestActions[event.Action] {
+		writeJSONResponse(w, http.StatusAccepted, map[string]string{"status": "ignored", "reason": event.Action + " isn't planned"})
+		return
+	}
+	client, err := newGitHubClient()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if reason := s.ignoredPullRequest(&event, client.repo); reason != "" {
+		writeJSONResponse(w, http.StatusAccepted, map[string]string{"status": "ignored", "reason": reason})
+		return
+	}
+
+	files, err := client.pullRequestFiles(event.Number)
+	if err != nil {
+		httpError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	modules := s.changedModules(files)
+	pr := &pullRequestRef{Number: event.Number, HeadSHA: event.PullRequest.Head.SHA, Base: event.PullRequest.Base.Ref}
+	var runs []*serverRun
+	for _, module := range modules {
+		run, err := s.enqueue(runRequest{Module: module}, pr)
+		if err != nil {
+			httpError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		runs = append(runs, run)
+	}
+	infoColor.Printf("🪝 PR #%d %s: queued %d run(s) for %s\n", pr.Number, event.Action, len(runs), strings.Join(modules, ", "))
+	writeJSONResponse(w, http.StatusAccepted, map[string]any{"status": "queued", "runs": runs})
+}
+
+// ignoredPullRequest tells why the webhook doesn't plan event's pull
+// request, "" if it does: it must be one of repo's, from a branch of repo
+// or of a trusted fork.
+func (s *planServer) ignoredPullRequest(event *pullRequestEvent, repo string) string {
+	if !strings.EqualFold(event.Repository.FullName, repo) {
+		return "not " + repo
+	}
+	head := event.PullRequest.Head.Repo.FullName
+	if strings.EqualFold(head, repo) {
+		return ""
+	}
+	for _, fork := range s.trustedForks {
+		if head != "" && strings.EqualFold(head, fork) {
+			return ""
+		}
+	}
+	if head == "" {
+		return "the head repository was deleted"
+	}
+	return head + " is a fork not in " + trustedForksEnv
+}
+
+// validSignature checks GitHub's "sha256=<hex HMAC>" payload signature.
+func validSignature(secret string, body []byte, signature string) bool {
+	given, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(given, mac.Sum(nil))
+}
+
+// changedModules maps the files a pull request changes to modules: files
+// in a module's terragrunt_<module> directory, in any module root, or below
+// a state directory with a path segment named after the module (the
+// convention findModuleStates follows). Modules the pull request adds count
+// too.
+func (s *planServer) changedModules(files []string) []string {
+	roots := []string{"."}
+	if cfg, err := LoadConfig(s.configPath); err == nil {
+		roots = cfg.moduleRoots()
+	}
+	known := make(map[string]bool)
+	existing, _ := findModules(s.root, roots)
+	for _, module := range existing {
+		known[module.Name] = true
+	}
+	for _, file := range files {
+		for _, root := range roots {
+			rel, ok := file, true
+			if root != "." {
+				rel, ok = strings.CutPrefix(file, filepath.ToSlash(root)+"/")
+			}
+			if name, found := strings.CutPrefix(rel, modulePrefix); ok && found && strings.Contains(name, "/") {
+				known[name[:strings.Index(name, "/")]] = true
+			}
+		}
+	}
+
+	changed := make(map[string]bool)
+	for _, file := range files {
+		for _, segment := range strings.Split(path.Dir(file), "/") {
+			if module := strings.TrimPrefix(segment, "terragrunt_"); known[module] {
+				changed[module] = true
+			}
+		}
+	}
+	var modules []string
+	for module := range changed {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// superseded tells whether a newer run of the same pull request and module
+// is queued, making this one pointless.
+func (s *planServer) superseded(run *serverRun) bool {
+	if run.PullRequest == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.runs {
+		if other.ID > run.ID && other.PullRequest != nil && other.PullRequest.Number == run.PullRequest.Number && other.Module == run.Module {
+			return true
+		}
+	}
+	return false
+}
+
+// addWorktree adds a temporary worktree of the pull request's head commit,
+// fetching it and its base, or of the checkout's HEAD if pr is nil. cleanup
+// removes it.
+func (s *planServer) addWorktree(pr *pullRequestRef) (worktree string, cleanup func(), err error) {
+	git := func(args ...string) error {
+		s.gitMu.Lock()
+		defer s.gitMu.Unlock()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = s.root
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s failed: %v\n%s", args[0], err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	pattern, commit := "tfprgen-run-", "HEAD"
+	if pr != nil {
+		if err := git("fetch", "--quiet", "origin",
+			fmt.Sprintf("+refs/pull/%d/head:refs/tfprgen/pr-%d", pr.Number, pr.Number),
+			fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", pr.Base, pr.Base)); err != nil {
+			return "", nil, err
+		}
+		pattern, commit = fmt.Sprintf("tfprgen-pr-%d-", pr.Number), pr.HeadSHA
+	}
+	dir, err := os.MkdirTemp("", pattern)
+	if err != nil {
+		return "", nil, err
+	}
+	worktree = filepath.Join(dir, filepath.Base(s.root))
+	if err := git("worktree", "add", "--quiet", "--detach", worktree, commit); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	return worktree, func() {
+		if err := git("worktree", "remove", "--force", worktree); err != nil {
+			warningColor.Printf("⚠️  Couldn't remove worktree %s: %v\n", worktree, err)
+		}
+		os.RemoveAll(dir)
+	}, nil
+}
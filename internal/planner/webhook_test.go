@@ -0,0 +1,145 @@
+package planner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChangedModules(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"terragrunt_vpc", "terragrunt_s3", "platform/terragrunt_iam"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	configPath := filepath.Join(root, ".tfprgen.yaml")
+	os.WriteFile(configPath, []byte("module_roots: [., platform]\n"), 0644)
+
+	for _, tc := range []struct {
+		name       string
+		configPath string
+		files      []string
+		want       string
+	}{
+		{"module directory", "", []string{"terragrunt_vpc/main.tf", "terragrunt_vpc/modules/subnets/main.tf"}, "vpc"},
+		{"state directories", "", []string{"live/staging/us-east-1/s3/terragrunt.hcl", "live/prod/terragrunt_vpc/us-east-1/terragrunt.hcl"}, "s3 vpc"},
+		{"added module", "", []string{"terragrunt_dns/main.tf", "live/staging/dns/terragrunt.hcl"}, "dns"},
+		{"unrelated files", "", []string{"README.md", "terragrunt_vpc.md", ".github/workflows/plan.yml"}, ""},
+		{"file named after a module", "", []string{"docs/vpc"}, ""},
+		{"module outside the roots", "", []string{"platform/terragrunt_iam/main.tf"}, ""},
+		{"module roots", configPath, []string{"platform/terragrunt_iam/main.tf", "platform/terragrunt_kms/main.tf", "terragrunt_vpc/outputs.tf"}, "iam kms vpc"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newPlanServer(nil, root, tc.configPath, "")
+			if got := strings.Join(s.changedModules(tc.files), " "); got != tc.want {
+				t.Errorf("changedModules(%q) = %q, want %q", tc.files, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddWorktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip(err)
+	}
+	root := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = root
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, output)
+		}
+		return string(output)
+	}
+	git("init", "--quiet")
+	os.MkdirAll(filepath.Join(root, "terragrunt_vpc"), 0755)
+	os.WriteFile(filepath.Join(root, "terragrunt_vpc", "main.tf"), []byte("# committed\n"), 0644)
+	git("add", ".")
+	git("commit", "--quiet", "-m", "vpc")
+	os.WriteFile(filepath.Join(root, "terragrunt_vpc", "main.tf"), []byte("# uncommitted\n"), 0644)
+
+	s := newPlanServer(nil, root, "", "")
+	worktree, cleanup, err := s.addWorktree(nil)
+	if err != nil {
+		t.Fatalf("addWorktree: %v", err)
+	}
+	if filepath.Base(worktree) != filepath.Base(root) {
+		t.Errorf("worktree %s isn't named after the checkout", worktree)
+	}
+	data, err := os.ReadFile(filepath.Join(worktree, "terragrunt_vpc", "main.tf"))
+	if string(data) != "# committed\n" {
+		t.Errorf("worktree has main.tf %q, %v; want HEAD's", data, err)
+	}
+	if list := git("worktree", "list"); !strings.Contains(list, worktree) {
+		t.Errorf("git worktree list doesn't have %s:\n%s", worktree, list)
+	}
+
+	cleanup()
+	if _, err := os.Stat(filepath.Dir(worktree)); !os.IsNotExist(err) {
+		t.Errorf("cleanup left %s: %v", filepath.Dir(worktree), err)
+	}
+	if list := git("worktree", "list"); strings.Contains(list, worktree) {
+		t.Errorf("git worktree list still has %s:\n%s", worktree, list)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "terragrunt_vpc", "main.tf")); string(data) != "# uncommitted\n" {
+		t.Errorf("the checkout's main.tf changed to %q", data)
+	}
+}
+
+func TestIgnoredPullRequest(t *testing.T) {
+	s := newPlanServer(nil, t.TempDir(), "", "")
+	s.trustedForks = []string{"partner/infra"}
+	for _, tc := range []struct {
+		name, repo, head string
+		planned          bool
+	}{
+		{"branch", "acme/infra", "acme/infra", true},
+		{"branch, other case", "acme/infra", "Acme/Infra", true},
+		{"trusted fork", "acme/infra", "Partner/infra", true},
+		{"fork", "acme/infra", "mallory/infra", false},
+		{"deleted fork", "acme/infra", "", false},
+		{"other repository", "acme/other", "acme/other", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var event pullRequestEvent
+			event.Repository.FullName = tc.repo
+			event.PullRequest.Head.Repo.FullName = tc.head
+			if reason := s.ignoredPullRequest(&event, "acme/infra"); (reason == "") != tc.planned {
+				t.Errorf("ignoredPullRequest = %q, planned %v", reason, tc.planned)
+			}
+		})
+	}
+}
+
+func TestChildConfigPath(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, ".tfprgen.yaml"), []byte("output_dir: server\n"), 0644)
+
+	s := newPlanServer(nil, root, ".tfprgen.yaml", "")
+	path, cleanup, err := s.childConfigPath()
+	if err != nil {
+		t.Fatalf("childConfigPath: %v", err)
+	}
+	cleanup()
+	if want := filepath.Join(root, ".tfprgen.yaml"); path != want {
+		t.Errorf("childConfigPath = %s, want the checkout's %s", path, want)
+	}
+
+	// Without a config in the checkout the child gets the defaults
+	s = newPlanServer(nil, root, "", "")
+	path, cleanup, err = s.childConfigPath()
+	if err != nil {
+		t.Fatalf("childConfigPath: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil || cfg.Path != "" {
+		t.Errorf("childConfigPath config %s = %+v, %v; want the defaults", path, cfg, err)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("cleanup left %s: %v", path, err)
+	}
+}
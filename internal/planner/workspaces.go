@@ -0,0 +1,132 @@
+package planner
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var backendBlockRegex = regexp.MustCompile(`(?m)^\s*backend\s+"[^"]+"`)
+
+// discoverWorkspaceStates finds the terraform root modules (directories
+// declaring a backend) below the runner's working directory whose path
+// contains the module name, and returns one state per workspace.
+func (pg *PlanGenerator) discoverWorkspaceStates() ([]*State, error) {
+	runner := &pg.Config.Runner
+	dirs, err := findRootModules(runner.WorkingDir, pg.ModuleName)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []*State
+	for _, dir := range dirs {
+		workspaces, err := listWorkspaces(runner.Binary, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list workspaces in %s: %v", dir, err)
+		}
+		for _, workspace := range workspaces {
+			state := &State{Path: dir, Workspace: workspace}
+			state.Env, state.Region = runner.mapWorkspace(workspace)
+			if pg.Verbose {
+				fmt.Fprintf(console, "  - %s → %s/%s\n", state, state.Env, state.Region)
+			}
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+// findRootModules returns directories below root with a backend block and a
+// path segment equal to moduleName.
+func findRootModules(root, moduleName string) ([]string, error) {
+	found := make(map[string]bool)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); path != root && (strings.HasPrefix(name, ".") || snapshotSkippedPaths[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".tf" {
+			return nil
+		}
+		dir := filepath.Dir(path)
+		if found[dir] || !containsSegment(dir, moduleName) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if backendBlockRegex.Match(data) {
+			found[dir] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for dir := range found {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+func containsSegment(path, segment string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == segment {
+			return true
+		}
+	}
+	return false
+}
+
+// listWorkspaces runs `terraform workspace list` in dir. The default
+// workspace is only returned when it's the only one.
+func listWorkspaces(binary, dir string) ([]string, error) {
+	out, err := commandOutput(exec.Command(binary, "-chdir="+dir, "workspace", "list"))
+	if err != nil {
+		return nil, err
+	}
+	var workspaces []string
+	for _, line := range strings.Split(string(out), "\n") {
+		name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+		if name != "" && name != "default" {
+			workspaces = append(workspaces, name)
+		}
+	}
+	if len(workspaces) == 0 {
+		workspaces = []string{"default"}
+	}
+	return workspaces, nil
+}
+
+// mapWorkspace derives the environment and region a workspace plans.
+func (r *RunnerConfig) mapWorkspace(workspace string) (env, region string) {
+	env, region = workspace, "default"
+	if r.workspaceRegex == nil {
+		return env, region
+	}
+	m := r.workspaceRegex.FindStringSubmatch(workspace)
+	if m == nil {
+		return env, region
+	}
+	if i := r.workspaceRegex.SubexpIndex("env"); i > 0 && m[i] != "" {
+		env = m[i]
+	}
+	if i := r.workspaceRegex.SubexpIndex("region"); i > 0 && m[i] != "" {
+		region = m[i]
+	}
+	return env, region
+}
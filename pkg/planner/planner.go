@@ -0,0 +1,176 @@
+// Package planner generates the terraform plans of a module across
+// partitions, environments and regions and the PR-ready report of them,
+// like the terraform-pr-generator command.
+//
+// Generate runs the whole thing. PlanParser only parses plans out of a
+// runner's output, for tools that run the plans themselves and render them
+// with pkg/render.
+package planner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/backendken/terraform-pr-generator/internal/planner"
+)
+
+type (
+	// Config is a .tfprgen.yaml: the runner, the partitions and the
+	// defaults of the options.
+	Config = planner.Config
+	// Partition is a group of accounts, e.g. commercial AWS or GovCloud,
+	// planned as a unit.
+	Partition = planner.Partition
+	// PlanParser parses a partition's plans out of runner output.
+	PlanParser = planner.PlanParser
+	// Environment is an environment's plans, by region.
+	Environment = planner.Environment
+	// PartitionResult is the parsed plans of a partition.
+	PartitionResult = planner.PartitionResult
+	// PlanCounts are the resources a plan adds, changes and destroys.
+	PlanCounts = planner.PlanCounts
+	// Executor runs the commands of a run.
+	Executor = planner.Executor
+	// Command is a command an Executor runs.
+	Command = planner.Command
+)
+
+// LoadConfig reads the config file at path, or returns the defaults when
+// path is empty.
+func LoadConfig(path string) (*Config, error) {
+	return planner.LoadConfig(path)
+}
+
+// FindConfigFile walks up from dir to the repo root looking for a
+// .tfprgen.yaml, "" if none exists.
+func FindConfigFile(dir string) string {
+	return planner.FindConfigFile(dir)
+}
+
+// ParsePlanCounts finds the Plan: summary in a plan body; false without
+// one, e.g. for a plan that errored.
+func ParsePlanCounts(body string) (PlanCounts, bool) {
+	return planner.ParsePlanCounts(body)
+}
+
+// Options configure a run. Those left zero come from the config file, as
+// they do for the command.
+type Options struct {
+	// Dir is the repository to plan, "" for the current directory.
+	// Relative paths in the other options are taken from it.
+	Dir string
+	// Module is the module to plan, e.g. "s3_malware_protection", found in
+	// the config's module roots below Dir.
+	Module string
+	// ConfigFile is the .tfprgen.yaml to use, "" for the one in Dir.
+	ConfigFile string
+	// OutputDir is where the report and plans are written, "" for the
+	// config's output_dir.
+	OutputDir string
+	// Mode is targeted, full or auto.
+	Mode string
+	// Select limits the states planned, e.g. "env=staging".
+	Select string
+	// Targets are resource addresses every plan is limited to.
+	Targets []string
+	// Destroy plans destroying the module's resources.
+	Destroy bool
+	// Parallelism is how many states plan at once.
+	Parallelism int
+	// Formats are the reports written besides pr-ready.md, e.g. json.
+	Formats []string
+	// Quiet prints only warnings and errors.
+	Quiet bool
+	// History records the run in the run history. Unlike the command's
+	// runs, which are recorded unless the config turns history off, a
+	// tool's runs rarely belong there, so it's up to the caller.
+	History bool
+	// Executor runs the run's commands; nil runs them as local processes.
+	Executor Executor
+}
+
+// Result is a run that planned.
+type Result struct {
+	// OutputDir holds pr-ready.md and the run's other outputs; absolute.
+	OutputDir string
+	// Partitions are the plans parsed per partition.
+	Partitions []*PartitionResult
+}
+
+// dirMu keeps runs with a Dir from moving the working directory under each
+// other.
+var dirMu sync.Mutex
+
+// Generate plans opts.Module and writes its reports, like the command with
+// the matching flags. A run whose plans partly failed returns both its
+// Result and the error; one stopped before planning only the error.
+// Cancelling ctx stops the plans still running.
+//
+// The run, like the command, plans from the working directory, so with a
+// Dir Generate changes the process's working directory to it until the run
+// ends. Runs with a Dir wait for each other, but anything else in the
+// process relying on the working directory meanwhile sees Dir.
+func Generate(ctx context.Context, opts Options) (*Result, error) {
+	if opts.Dir != "" {
+		dirMu.Lock()
+		defer dirMu.Unlock()
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chdir(opts.Dir); err != nil {
+			return nil, err
+		}
+		defer os.Chdir(cwd)
+	}
+
+	pg, err := planner.NewGenerator(opts.generatorOptions())
+	if err != nil {
+		return nil, err
+	}
+	if opts.Executor != nil {
+		pg.Executor = opts.Executor
+	}
+	err = pg.RunContext(ctx)
+	if pg.Results() == nil {
+		return nil, err
+	}
+	outputDir, absErr := filepath.Abs(pg.OutputDir)
+	if absErr != nil {
+		outputDir = pg.OutputDir
+	}
+	return &Result{OutputDir: outputDir, Partitions: pg.Results()}, err
+}
+
+// generatorOptions are the command's defaults with the options set over
+// them.
+func (opts *Options) generatorOptions() planner.GeneratorOptions {
+	g := planner.DefaultGeneratorOptions()
+	g.Module = opts.Module
+	g.ConfigFile = opts.ConfigFile
+	g.OutputDir = opts.OutputDir
+	g.Mode = opts.Mode
+	g.Select = opts.Select
+	g.Targets = opts.Targets
+	g.Destroy = opts.Destroy
+	g.Formats = opts.Formats
+	g.Quiet = opts.Quiet
+	if opts.Parallelism > 0 {
+		g.Parallelism = strconv.Itoa(opts.Parallelism)
+	}
+	g.NoHistory = !opts.History
+	g.Given = func(flag string) bool {
+		switch flag {
+		case "parallelism":
+			return opts.Parallelism > 0
+		case "no-history":
+			// History is the caller's choice, not the config's
+			return true
+		}
+		return false
+	}
+	return g
+}
@@ -0,0 +1,85 @@
+package planner
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeExecutor answers every command with a plan of staging/us-east-1.
+type fakeExecutor struct {
+	commands []string
+}
+
+func (f *fakeExecutor) Run(ctx context.Context, cmd *Command) error {
+	f.commands = append(f.commands, strings.Join(cmd.Args, " "))
+	io.WriteString(cmd.Stdout, `Running in /repo/terragrunt_vpc/organizations/staging/us-east-1/
+Terraform will perform the following actions:
+
+  # aws_vpc.this will be created
+  + resource "aws_vpc" "this" {}
+
+Plan: 1 to add, 0 to change, 0 to destroy.
+`)
+	return nil
+}
+
+func TestGenerate(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "terragrunt_vpc", "organizations", "staging", "us-east-1"), 0755)
+	historyDB := filepath.Join(t.TempDir(), "history.db")
+	t.Setenv("TFPRGEN_HISTORY_DB", historyDB)
+	cwd, _ := os.Getwd()
+
+	fake := &fakeExecutor{}
+	result, err := Generate(context.Background(), Options{Dir: root, Module: "vpc", OutputDir: "plans", Mode: "full", Formats: []string{"json"}, Quiet: true, Executor: fake})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if dir, _ := os.Getwd(); dir != cwd {
+		t.Errorf("working directory = %s after Generate, want %s", dir, cwd)
+	}
+	if _, err := os.Stat(historyDB); !os.IsNotExist(err) {
+		t.Errorf("run recorded in the history without History: %v", err)
+	}
+	if len(fake.commands) == 0 || !strings.HasPrefix(fake.commands[0], "kitman tg plan_all -m vpc ") {
+		t.Errorf("commands = %q, want the partitions' plan_all of vpc", fake.commands)
+	}
+	if !filepath.IsAbs(result.OutputDir) || filepath.Base(result.OutputDir) != "plans" {
+		t.Errorf("OutputDir = %q, want the absolute path of plans", result.OutputDir)
+	}
+	var staging *Environment
+	for _, partition := range result.Partitions {
+		if partition.Partition.Name == "commercial" && len(partition.Environments) == 1 {
+			staging = partition.Environments[0]
+		}
+	}
+	if staging == nil || staging.Name != "staging" {
+		t.Fatalf("Partitions = %+v, want staging in commercial", result.Partitions)
+	}
+	if counts, ok := ParsePlanCounts(staging.Plans["us-east-1"]); !ok || counts.Add != 1 {
+		t.Errorf("staging plans = %q, want us-east-1 adding 1", staging.Plans)
+	}
+	for _, file := range []string{"pr-ready.md", "report.json"} {
+		if _, err := os.Stat(filepath.Join(root, "plans", file)); err != nil {
+			t.Errorf("no %s: %v", file, err)
+		}
+	}
+}
+
+func TestGenerateHistory(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "terragrunt_vpc", "organizations", "staging", "us-east-1"), 0755)
+	historyDB := filepath.Join(t.TempDir(), "history.db")
+	t.Setenv("TFPRGEN_HISTORY_DB", historyDB)
+
+	if _, err := Generate(context.Background(), Options{Dir: root, Module: "vpc", Mode: "full", Quiet: true, History: true, Executor: &fakeExecutor{}}); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if _, err := os.Stat(historyDB); err != nil {
+		t.Errorf("run not recorded in the history with History: %v", err)
+	}
+}
@@ -0,0 +1,409 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HTML renders report markdown as a standalone page styled like a GitHub
+// comment, to preview a report before it's posted. It covers the
+// GitHub-flavored markdown reports are made of: headings, paragraphs, block
+// quotes, nested and task lists, tables, fenced code, emphasis, inline code
+// and links. HTML such as <details> sections passes through as GitHub shows
+// it, limited to the tags GitHub allows in comments.
+func HTML(title, markdown string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, htmlHead, html.EscapeString(title))
+	renderBlocks(&b, strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n"))
+	b.WriteString("</article>\n</body>\n</html>\n")
+	return b.String()
+}
+
+const htmlHead = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+:root { color-scheme: light dark; --fg: #1f2328; --bg: #ffffff; --muted: #59636e; --border: #d1d9e0; --code: #f6f8fa; --link: #0969da; }
+@media (prefers-color-scheme: dark) {
+  :root { --fg: #f0f6fc; --bg: #0d1117; --muted: #9198a1; --border: #3d444d; --code: #151b23; --link: #4493f8; }
+}
+body { margin: 0; padding: 2rem 1rem; background: var(--bg); color: var(--fg); font: 14px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif; }
+article { max-width: 980px; margin: 0 auto; padding: 1rem 1.5rem; border: 1px solid var(--border); border-radius: 6px; }
+h1, h2 { padding-bottom: .3em; border-bottom: 1px solid var(--border); }
+h1, h2, h3, h4, h5, h6 { margin: 1.5em 0 1em; line-height: 1.25; }
+a { color: var(--link); }
+code, pre { font: 12px/1.45 ui-monospace, SFMono-Regular, "SF Mono", Menlo, Consolas, monospace; background: var(--code); border-radius: 6px; }
+code { padding: .2em .4em; }
+pre { padding: 1em; overflow: auto; }
+pre code { padding: 0; }
+blockquote { margin: 0 0 1em; padding: 0 1em; color: var(--muted); border-left: .25em solid var(--border); }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { padding: 6px 13px; border: 1px solid var(--border); }
+details { margin-bottom: 1em; }
+summary { cursor: pointer; }
+li.task { list-style: none; }
+li.task input { margin: 0 .3em 0 -1.4em; }
+.diff-add { color: #1a7f37; }
+.diff-del { color: #d1242f; }
+</style>
+</head>
+<body>
+<article>
+`
+
+var (
+	headingRegex   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	ruleRegex      = regexp.MustCompile(`^(-{3,}|\*{3,}|_{3,})$`)
+	listItemRegex  = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])(\s+|$)`)
+	tableRuleRegex = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+	htmlBlockRegex = regexp.MustCompile(`^</?(details|summary|div|p|table|thead|tbody|tr|td|th|ul|ol|li|pre|blockquote|h[1-6]|hr|br)\b`)
+	tagRegex       = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)\b([^<>]*)>`)
+	linkRegex      = regexp.MustCompile(`^\[((?:[^\[\]]|\[[^\[\]]*\])*)\]\(([^()\s]*)\)`)
+	entityRegex    = regexp.MustCompile(`^&(#\d+|#x[0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+	urlRegex       = regexp.MustCompile(`^https?://[^\s<]*[^\s<.,:;"')\]]`)
+	hrefAttrRegex  = regexp.MustCompile(`\bhref\s*=\s*"([^"]*)"`)
+	openAttrRegex  = regexp.MustCompile(`\bopen\b`)
+)
+
+// allowedTags are the HTML tags passed through, without attributes but
+// details' open and links' href. Others are shown as text, as GitHub
+// strips them from comments.
+var allowedTags = map[string]bool{
+	"a": true, "b": true, "blockquote": true, "br": true, "code": true, "del": true, "details": true,
+	"div": true, "em": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"hr": true, "i": true, "kbd": true, "li": true, "ol": true, "p": true, "pre": true, "s": true,
+	"strong": true, "sub": true, "summary": true, "sup": true, "table": true, "tbody": true, "td": true,
+	"th": true, "thead": true, "tr": true, "ul": true,
+}
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+		switch {
+		case trimmed == "":
+			i++
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence := trimmed[:3]
+			j := i + 1
+			for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), fence) {
+				j++
+			}
+			writeCode(b, strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])), lines[i+1:min(j, len(lines))])
+			i = j + 1
+		case htmlBlockRegex.MatchString(trimmed):
+			// Up to the next blank line, like GitHub does
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				b.WriteString(renderInline(lines[i]) + "\n")
+			}
+		case headingRegex.MatchString(trimmed):
+			m := headingRegex.FindStringSubmatch(trimmed)
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", len(m[1]), renderInline(m[2]), len(m[1]))
+			i++
+		case ruleRegex.MatchString(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				line := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(line, " "))
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+		case listItemRegex.MatchString(lines[i]):
+			j := listEnd(lines, i)
+			renderList(b, lines[i:j])
+			i = j
+		case strings.Contains(trimmed, "|") && i+1 < len(lines) && tableRuleRegex.MatchString(strings.TrimSpace(lines[i+1])):
+			j := i + 2
+			for j < len(lines) && strings.Contains(lines[j], "|") && strings.TrimSpace(lines[j]) != "" {
+				j++
+			}
+			writeTable(b, lines[i], lines[i+2:j])
+			i = j
+		default:
+			j := paragraphEnd(lines, i)
+			b.WriteString("<p>" + renderInline(strings.Join(trimAll(lines[i:j]), "\n")) + "</p>\n")
+			i = j
+		}
+	}
+}
+
+// paragraphEnd is the index of the line after the paragraph starting at
+// lines[start].
+func paragraphEnd(lines []string, start int) int {
+	j := start + 1
+	for ; j < len(lines); j++ {
+		trimmed := strings.TrimSpace(lines[j])
+		if trimmed == "" || startsBlock(lines[j]) {
+			break
+		}
+	}
+	return j
+}
+
+// startsBlock tells whether a line interrupts a paragraph.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") || strings.HasPrefix(trimmed, ">") ||
+		headingRegex.MatchString(trimmed) || htmlBlockRegex.MatchString(trimmed) || listItemRegex.MatchString(line)
+}
+
+// listEnd is the index of the line after the list starting at lines[start]:
+// its items and their indented continuations, across single blank lines.
+func listEnd(lines []string, start int) int {
+	base := indent(lines[start])
+	j := start + 1
+	for j < len(lines) {
+		line := lines[j]
+		if strings.TrimSpace(line) == "" {
+			if j+1 < len(lines) && strings.TrimSpace(lines[j+1]) != "" && indent(lines[j+1]) > base {
+				j++
+				continue
+			}
+			break
+		}
+		if indent(line) < base || (indent(line) == base && !listItemRegex.MatchString(line)) {
+			break
+		}
+		j++
+	}
+	return j
+}
+
+// renderList renders the list listEnd found, its items' content as blocks
+// of their own.
+func renderList(b *strings.Builder, lines []string) {
+	first := listItemRegex.FindStringSubmatch(lines[0])
+	base := len(first[1])
+	tag, open := "ul", "<ul>"
+	if n, err := strconv.Atoi(strings.TrimRight(first[2], ".)")); err == nil {
+		tag, open = "ol", "<ol>"
+		if n != 1 {
+			open = fmt.Sprintf("<ol start=\"%d\">", n)
+		}
+	}
+	b.WriteString(open + "\n")
+
+	var items [][]string
+	for _, line := range lines {
+		if m := listItemRegex.FindStringSubmatch(line); m != nil && len(m[1]) <= base+1 {
+			items = append(items, []string{line[len(m[0]):]})
+			continue
+		}
+		// Continuation lines are relative to the item's content
+		dedented := line
+		if indent(line) > base {
+			dedented = line[min(indent(line), base+len(first[2])+1):]
+		}
+		items[len(items)-1] = append(items[len(items)-1], dedented)
+	}
+
+	for _, item := range items {
+		text := item[0]
+		class, box := "", ""
+		switch {
+		case strings.HasPrefix(text, "[ ] "):
+			class, box, text = ` class="task"`, `<input type="checkbox" disabled> `, text[4:]
+		case strings.HasPrefix(text, "[x] "), strings.HasPrefix(text, "[X] "):
+			class, box, text = ` class="task"`, `<input type="checkbox" checked disabled> `, text[4:]
+		}
+		// The item's first paragraph stays inline, as in a tight list
+		j := 1
+		for j < len(item) && strings.TrimSpace(item[j]) != "" && !startsBlock(item[j]) {
+			j++
+		}
+		lead := append([]string{text}, trimAll(item[1:j])...)
+		b.WriteString("<li" + class + ">" + box + renderInline(strings.Join(lead, "\n")))
+		if j < len(item) {
+			b.WriteString("\n")
+			renderBlocks(b, item[j:])
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+}
+
+func writeCode(b *strings.Builder, lang string, lines []string) {
+	if lang != "" {
+		fmt.Fprintf(b, "<pre><code class=\"language-%s\">", html.EscapeString(strings.Fields(lang)[0]))
+	} else {
+		b.WriteString("<pre><code>")
+	}
+	for _, line := range lines {
+		escaped := html.EscapeString(line)
+		switch {
+		case lang == "diff" && strings.HasPrefix(line, "+"):
+			escaped = `<span class="diff-add">` + escaped + "</span>"
+		case lang == "diff" && strings.HasPrefix(line, "-"):
+			escaped = `<span class="diff-del">` + escaped + "</span>"
+		}
+		b.WriteString(escaped + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+func writeTable(b *strings.Builder, header string, rows []string) {
+	b.WriteString("<table>\n<thead>\n<tr>")
+	for _, cell := range tableCells(header) {
+		b.WriteString("<th>" + renderInline(cell) + "</th>")
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, row := range rows {
+		b.WriteString("<tr>")
+		for _, cell := range tableCells(row) {
+			b.WriteString("<td>" + renderInline(cell) + "</td>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+}
+
+// tableCells splits a table row on the pipes not escaped as \|.
+func tableCells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// renderInline renders the spans of a block's text: code, emphasis, links
+// and allowed HTML tags. Line breaks are kept, as GitHub does in comments.
+func renderInline(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text) && strings.ContainsRune("\\`*_{}[]()#+-.!|<>~", rune(text[i+1])):
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			ticks := len(rest) - len(strings.TrimLeft(rest, "`"))
+			if end := strings.Index(rest[ticks:], rest[:ticks]); end >= 0 {
+				b.WriteString("<code>" + html.EscapeString(strings.TrimSpace(rest[ticks:ticks+end])) + "</code>")
+				i += 2*ticks + end
+				continue
+			}
+			b.WriteString(rest[:ticks])
+			i += ticks
+			continue
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				b.WriteString("<strong>" + renderInline(rest[2:2+end]) + "</strong>")
+				i += 4 + end
+				continue
+			}
+		case strings.HasPrefix(rest, "~~"):
+			if end := strings.Index(rest[2:], "~~"); end > 0 {
+				b.WriteString("<del>" + renderInline(rest[2:2+end]) + "</del>")
+				i += 4 + end
+				continue
+			}
+		case c == '*' && len(rest) > 1 && rest[1] != ' ':
+			if end := strings.IndexByte(rest[1:], '*'); end > 0 {
+				b.WriteString("<em>" + renderInline(rest[1:1+end]) + "</em>")
+				i += 2 + end
+				continue
+			}
+		case c == '[':
+			if m := linkRegex.FindStringSubmatch(rest); m != nil {
+				if href, ok := safeURL(m[2]); ok {
+					b.WriteString(`<a href="` + href + `">` + renderInline(m[1]) + "</a>")
+					i += len(m[0])
+					continue
+				}
+			}
+		case c == 'h' && (i == 0 || strings.ContainsRune(" \n(", rune(text[i-1]))):
+			if m := urlRegex.FindString(rest); m != "" {
+				b.WriteString(`<a href="` + html.EscapeString(m) + `">` + html.EscapeString(m) + "</a>")
+				i += len(m)
+				continue
+			}
+		case c == '<':
+			if m := tagRegex.FindStringSubmatch(rest); m != nil && allowedTags[strings.ToLower(m[2])] {
+				b.WriteString(sanitizeTag(m[1], strings.ToLower(m[2]), m[3]))
+				i += len(m[0])
+				continue
+			}
+		case c == '&':
+			if m := entityRegex.FindString(rest); m != "" {
+				b.WriteString(m)
+				i += len(m)
+				continue
+			}
+		case c == '\n':
+			b.WriteString("<br>\n")
+			i++
+			continue
+		}
+		b.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// sanitizeTag rewrites an allowed tag without its attributes, but for the
+// few GitHub keeps.
+func sanitizeTag(closing, name, attrs string) string {
+	if closing != "" {
+		return "</" + name + ">"
+	}
+	switch {
+	case name == "details" && openAttrRegex.MatchString(attrs):
+		return "<details open>"
+	case name == "a":
+		if m := hrefAttrRegex.FindStringSubmatch(attrs); m != nil {
+			if href, ok := safeURL(html.UnescapeString(m[1])); ok {
+				return `<a href="` + href + `">`
+			}
+		}
+	}
+	return "<" + name + ">"
+}
+
+// safeURL escapes a link target, refusing schemes other than http(s) and
+// mailto, such as javascript:.
+func safeURL(url string) (string, bool) {
+	scheme, _, found := strings.Cut(url, ":")
+	if found && !strings.ContainsAny(scheme, "/?#") {
+		switch strings.ToLower(scheme) {
+		case "http", "https", "mailto":
+		default:
+			return "", false
+		}
+	}
+	return html.EscapeString(url), true
+}
+
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+func trimAll(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSpace(line)
+	}
+	return trimmed
+}
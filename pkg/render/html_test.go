@@ -0,0 +1,39 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	var b strings.Builder
+	r := MarkdownRenderer{}
+	r.EnvironmentHeading(&b, "staging", "kitman tg plan_all", "vpc")
+	r.Plan(&b, "us-east-1", `+ resource "aws_vpc" "this" {`)
+	b.WriteString("> ⚠️ **Partial:** see [the logs](https://example.com/logs?a=1&b=2)\n\n")
+	b.WriteString("1. [ ] `staging / us-east-1`\n2. [x] `production / us-east-1`\n")
+
+	page := HTML("Terraform plan: vpc", b.String())
+	for _, want := range []string{
+		"<title>Terraform plan: vpc</title>",
+		"<h2>[environment: staging] - [command: kitman tg plan_all] - [module: vpc]</h2>",
+		"<details>\n<summary>us-east-1</summary>\n",
+		`<pre><code class="language-bash">+ resource &#34;aws_vpc&#34; &#34;this&#34; {` + "\n</code></pre>",
+		"<blockquote>\n<p>⚠️ <strong>Partial:</strong> see <a href=\"https://example.com/logs?a=1&amp;b=2\">the logs</a></p>\n</blockquote>",
+		"<li class=\"task\"><input type=\"checkbox\" disabled> <code>staging / us-east-1</code></li>",
+		"<li class=\"task\"><input type=\"checkbox\" checked disabled> <code>production / us-east-1</code></li>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("HTML() lacks %q:\n%s", want, page)
+		}
+	}
+}
+
+func TestHTMLStripsUnsafeMarkup(t *testing.T) {
+	page := HTML("notes", "<script>alert(1)</script> <a href=\"javascript:alert(1)\" onclick=\"x()\">x</a> [y](javascript:alert(1))\n")
+	for _, unwanted := range []string{"<script>", "javascript:alert(1)\"", "onclick", `href="javascript`} {
+		if strings.Contains(page, unwanted) {
+			t.Errorf("HTML() kept %q:\n%s", unwanted, page)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package render renders terraform plans as the markdown of a PR comment,
+// like the pr-ready.md reports of terraform-pr-generator.
+//
+// A report has a heading per environment and a collapsible section per
+// region holding its plan:
+//
+//	r := render.MarkdownRenderer{}
+//	r.EnvironmentHeading(&b, "staging", "kitman tg plan_all", "s3_malware_protection")
+//	r.Plan(&b, "us-east-1", plan)
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MarkdownRenderer renders the parts of a report. The zero value renders
+// emoji cues and HTML sections, as GitHub shows them.
+type MarkdownRenderer struct {
+	// Plain leaves out emoji and HTML, for wikis and tickets that show
+	// neither.
+	Plain bool
+	// Destroy marks every section as a destroy plan.
+	Destroy bool
+}
+
+// Icon is an emoji cue followed by a space. Plain reports leave it out, so
+// the text around it has to carry the meaning alone.
+func (r MarkdownRenderer) Icon(emoji string) string {
+	if r.Plain {
+		return ""
+	}
+	return emoji + " "
+}
+
+// Tag appends a marker to a section summary: " ⚠️ incomplete", or
+// " (incomplete)" in plain reports.
+func (r MarkdownRenderer) Tag(emoji, text string) string {
+	if r.Plain {
+		return " (" + text + ")"
+	}
+	return " " + emoji + " " + text
+}
+
+// DestroyTag marks the region summaries of destroy plans.
+func (r MarkdownRenderer) DestroyTag() string {
+	if r.Destroy {
+		return r.Tag("🔥", "DESTROY PLAN")
+	}
+	return ""
+}
+
+// OpenSection starts a collapsible section. Plain reports can't rely on
+// HTML surviving, so they use a heading of the given level instead.
+func (r MarkdownRenderer) OpenSection(output io.StringWriter, level int, summary string) {
+	if r.Plain {
+		output.WriteString(fmt.Sprintf("%s %s\n\n", strings.Repeat("#", level), summary))
+		return
+	}
+	output.WriteString(fmt.Sprintf("<details>\n<summary>%s</summary>\n\n", summary))
+}
+
+// CloseSection ends a section OpenSection started.
+func (r MarkdownRenderer) CloseSection(output io.StringWriter) {
+	if !r.Plain {
+		output.WriteString("</details>\n\n")
+	}
+}
+
+// CodeBlock writes plan output as a fenced code block, highlighted as bash
+// outside plain reports.
+func (r MarkdownRenderer) CodeBlock(output io.StringWriter, content string) {
+	if r.Plain {
+		output.WriteString("```\n")
+	} else {
+		output.WriteString("```bash\n")
+	}
+	output.WriteString(content)
+	output.WriteString("\n```\n\n")
+}
+
+// EnvironmentHeading starts the plans of an environment.
+func (r MarkdownRenderer) EnvironmentHeading(output io.StringWriter, env, command, module string) {
+	output.WriteString(fmt.Sprintf("## [environment: %s] - [command: %s] - [module: %s]\n\n", env, command, module))
+	if r.Destroy {
+		output.WriteString(fmt.Sprintf("> %s**DESTROY PLAN** for %s\n\n", r.Icon("🔥"), env))
+	}
+}
+
+// Plan writes a region's plan in a section of its own.
+func (r MarkdownRenderer) Plan(output io.StringWriter, region, plan string) {
+	r.OpenSection(output, 3, region+r.DestroyTag())
+	r.CodeBlock(output, plan)
+	r.CloseSection(output)
+}
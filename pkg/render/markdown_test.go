@@ -0,0 +1,78 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		renderer MarkdownRenderer
+		want     string
+	}{
+		{
+			name: "default",
+			want: "<details>\n<summary>us-east-1</summary>\n\n```bash\nPlan: 1 to add\n```\n\n</details>\n\n",
+		},
+		{
+			name:     "plain",
+			renderer: MarkdownRenderer{Plain: true},
+			want:     "### us-east-1\n\n```\nPlan: 1 to add\n```\n\n",
+		},
+		{
+			name:     "destroy",
+			renderer: MarkdownRenderer{Destroy: true},
+			want:     "<details>\n<summary>us-east-1 🔥 DESTROY PLAN</summary>\n\n```bash\nPlan: 1 to add\n```\n\n</details>\n\n",
+		},
+		{
+			name:     "plain destroy",
+			renderer: MarkdownRenderer{Plain: true, Destroy: true},
+			want:     "### us-east-1 (DESTROY PLAN)\n\n```\nPlan: 1 to add\n```\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			tt.renderer.Plan(&b, "us-east-1", "Plan: 1 to add")
+			if b.String() != tt.want {
+				t.Errorf("Plan() = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvironmentHeading(t *testing.T) {
+	var b strings.Builder
+	MarkdownRenderer{}.EnvironmentHeading(&b, "staging", "kitman tg plan_all", "vpc")
+	if want := "## [environment: staging] - [command: kitman tg plan_all] - [module: vpc]\n\n"; b.String() != want {
+		t.Errorf("EnvironmentHeading() = %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+	MarkdownRenderer{Destroy: true}.EnvironmentHeading(&b, "staging", "kitman tg plan_all", "vpc")
+	if !strings.HasSuffix(b.String(), "> 🔥 **DESTROY PLAN** for staging\n\n") {
+		t.Errorf("destroy heading = %q", b.String())
+	}
+}
+
+func TestIconAndTag(t *testing.T) {
+	r := MarkdownRenderer{}
+	if got := r.Icon("⚠️"); got != "⚠️ " {
+		t.Errorf("Icon() = %q", got)
+	}
+	if got := r.Tag("⚠️", "incomplete"); got != " ⚠️ incomplete" {
+		t.Errorf("Tag() = %q", got)
+	}
+
+	plain := MarkdownRenderer{Plain: true}
+	if got := plain.Icon("⚠️"); got != "" {
+		t.Errorf("plain Icon() = %q, want none", got)
+	}
+	if got := plain.Tag("⚠️", "incomplete"); got != " (incomplete)" {
+		t.Errorf("plain Tag() = %q", got)
+	}
+	if got := plain.DestroyTag(); got != "" {
+		t.Errorf("DestroyTag() of a plan = %q, want none", got)
+	}
+}